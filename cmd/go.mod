@@ -24,6 +24,7 @@ require (
 replace (
 	github.com/dosco/graphjin/auth/v3 => ../auth
 	github.com/dosco/graphjin/core/v3 => ../core
+	github.com/dosco/graphjin/mongodriver => ../mongodriver
 	github.com/dosco/graphjin/plugin/otel/v3 => ../plugin/otel
 	github.com/dosco/graphjin/serv/v3 => ../serv
 )
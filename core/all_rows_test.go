@@ -0,0 +1,58 @@
+package core_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestAllRows(t *testing.T) {
+	db := newTestDB(t, "allrowsdb1")
+
+	conf := &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		Roles: []core.Role{{
+			Name: "user",
+			Tables: []core.RoleTable{{
+				Name:   "products",
+				Update: &core.Update{AllowAll: true},
+			}},
+		}},
+	}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 1)
+
+	run := func(c context.Context, gql, expErr string) string {
+		t.Helper()
+		res, err := gj.GraphQL(c, gql, nil, nil)
+		if expErr == "" && err != nil {
+			t.Fatal(err)
+		}
+		if expErr != "" && (err == nil || !strings.Contains(err.Error(), expErr)) {
+			t.Fatalf("expected the error '%s' for %s, got: %v", expErr, gql, err)
+		}
+		return string(res.Data)
+	}
+
+	// an update or delete without a where clause is refused
+	run(ctx, `mutation { products(update: { price: 5 }) { id } }`, "where clause required")
+	run(ctx, `mutation { products(delete: true) { id } }`, "where clause required")
+
+	// unless it's meant for all the rows and the role allows it
+	out := run(ctx, `mutation { products(all: true, update: { price: 5 }) { id price } }`, "")
+	if exp := `{"products":[{"id":1,"price":5.0},{"id":2,"price":5.0}]}`; out != exp {
+		t.Fatalf("expected: %s, got: %s", exp, out)
+	}
+	run(ctx, `mutation { products(all: true, delete: true) { id } }`, "not allowed")
+	run(context.Background(), `mutation { products(all: true, update: { price: 6 }) { id } }`, "not allowed")
+
+	run(ctx, `mutation { products(all: true, insert: { id: 3, name: "P3" }) { id } }`, "can only be used with update or delete")
+	run(ctx, `mutation { products(all: false, update: { price: 5 }) { id } }`, "must be 'true'")
+}
@@ -0,0 +1,73 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestAllowListVarDefaults(t *testing.T) {
+	db := newTestDB(t, "allowdefaultsdb")
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "queries", "defaults"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"queries/getUsers.gql":           `query getUsers { users(order_by: { id: asc }, limit: $limit) { id } }`,
+		"queries/defaults/getUsers.json": `{ "limit": 1 }`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rc := &keyCache{}
+	conf := &core.Config{DBType: "sqlite", Production: true}
+	gj, err := core.NewGraphJin(conf, db,
+		core.OptionSetFS(core.NewOsFS(dir)),
+		core.OptionSetResponseCache(rc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the default is used when the client does not set the variable
+	res, err := gj.GraphQLByName(context.Background(), "getUsers", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":1}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+
+	// the variables of the client override the defaults
+	gql := `query getUsers { users { id } }`
+	res, err = gj.GraphQL(context.Background(), gql, json.RawMessage(`{"limit": 2}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":1},{"id":2}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+
+	// the defaults are part of the cache key
+	if _, err := gj.GraphQLByName(context.Background(), "getUsers", json.RawMessage(`{"limit": 1}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(rc.keys) != 3 || rc.keys[0] != rc.keys[2] || rc.keys[0] == rc.keys[1] {
+		t.Fatalf("unexpected cache keys: %v", rc.keys)
+	}
+
+	sq, err := gj.GetSavedQuery("getUsers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sq.Defaults["limit"] != float64(1) {
+		t.Fatalf("expected the defaults of the saved query, got: %v", sq.Defaults)
+	}
+}
@@ -58,6 +58,11 @@ type dbContext struct {
 	qcodeCompiler *qcode.Compiler  // GraphQL to QCode compiler (validates against this DB's schema)
 	psqlCompiler  *psql.Compiler   // QCode to SQL compiler (generates this DB's dialect)
 	schemas       []string         // Configured schemas for this database
+	stmts         *stmtCache       // Prepared statements of compiled queries (nil if disabled)
+	readOnly      bool             // Mutations against this database are blocked
+	discoveredAt  time.Time        // When the schema was last discovered
+	errMu         sync.Mutex       // Guards err, set by the schema watcher
+	err           error            // Last schema poll or reload error
 }
 
 // GraphJin struct is an instance of the GraphJin engine it holds all the required information like
@@ -70,8 +75,8 @@ type graphjinEngine struct {
 	allowList             *allow.List
 	encryptionKey         [32]byte
 	encryptionKeySet      bool
-	cache                 Cache
-	queries               sync.Map
+	cache                 CacheProvider
+	localCache            *Cache
 	roles                 map[string]*Role
 	roleStatement         string
 	roleStatementMetadata psql.Metadata
@@ -96,6 +101,25 @@ type graphjinEngine struct {
 	responseCache ResponseCacheProvider
 	// Cache key builder
 	cacheKeyBuilder *CacheKeyBuilder
+
+	// Query cost function (optional, set via OptionSetCostFn)
+	costFn CostFn
+	// Tracks the query cost spent by each role
+	costLimiter *costLimiter
+	// Metrics hook (optional, set via OptionSetMetrics)
+	metricsFn MetricsFn
+	// Query duration histograms (optional, set via OptionSetHistograms)
+	histograms *Histograms
+	// Computed fields keyed by table:field (set via OptionAddComputedField)
+	computed map[string]ComputedField
+	// Custom directives by name (set via OptionAddDirective)
+	directives map[string]DirectiveFn
+	// Role resolver (optional, set via OptionSetRoleResolver)
+	roleResolver RoleResolverFn
+	// Context vars function (optional, set via OptionSetContextVars)
+	contextVarsFn ContextVarsFn
+	// Attributes added to the span of a query
+	traceAttrs []string
 }
 
 // primaryDB returns the default database context.
@@ -197,6 +221,7 @@ func (g *GraphJin) newGraphJin(conf *Config,
 		opts:        options,
 		fs:          fs,
 		trace:       &tracer{},
+		costLimiter: newCostLimiter(),
 		done:        g.done,
 	}
 
@@ -214,6 +239,10 @@ func (g *GraphJin) newGraphJin(conf *Config,
 		return
 	}
 
+	if err = gj.initTraceAttrs(); err != nil {
+		return
+	}
+
 	// Set defaultDB from the normalized config (first entry, sorted for determinism)
 	if gj.defaultDB == "" {
 		names := make([]string, 0, len(gj.conf.Databases))
@@ -241,6 +270,7 @@ func (g *GraphJin) newGraphJin(conf *Config,
 		dbtype: dbtype,
 		dbinfo: dbinfo, // may be preset from watcher/tests
 	}
+	gj.databases[gj.defaultDB].readOnly = conf.Databases[gj.defaultDB].ReadOnly
 
 	// Populate schemas for the primary database
 	if dc, ok := conf.Databases[gj.defaultDB]; ok && dc.Schema != "" {
@@ -289,7 +319,10 @@ func (g *GraphJin) newGraphJin(conf *Config,
 		gj.encryptionKeySet = true
 	}
 
-	g.Store(gj)
+	// prepared statements of the previous schema are closed
+	if prev, ok := g.Swap(gj).(*graphjinEngine); ok && prev != nil {
+		prev.closeStmtCaches()
+	}
 	return
 }
 
@@ -342,6 +375,21 @@ func OptionSetResponseCache(cache ResponseCacheProvider) Option {
 
 type Error struct {
 	Message string `json:"message"`
+	// Path of the root field the error is for when the other roots returned data,
+	// of the remote field that was returned as null or of the unknown field that
+	// was left out
+	Path []string `json:"path,omitempty"`
+	// Extensions holds the code and details of errors like constraint violations
+	Extensions *ErrorExtensions `json:"extensions,omitempty"`
+}
+
+// ErrorExtensions holds the code of an error and the constraint and
+// columns of a constraint violation
+type ErrorExtensions struct {
+	Code       string   `json:"code"`
+	Kind       string   `json:"kind,omitempty"`
+	Constraint string   `json:"constraint,omitempty"`
+	Columns    []string `json:"columns,omitempty"`
 }
 
 // Result struct contains the output of the GraphQL function this includes resulting json from the
@@ -354,12 +402,15 @@ type Result struct {
 	role         string
 	cacheControl string
 	cacheHit     bool
+	truncated    bool
+	params       []QueryParam
+	qc           *qcode.QCode
 	Vars         json.RawMessage   `json:"-"`
 	Data         json.RawMessage   `json:"data,omitempty"`
 	Hash         [sha256.Size]byte `json:"-"`
 	Errors       []Error           `json:"errors,omitempty"`
 	Validation   []qcode.ValidErr  `json:"validation,omitempty"`
-	// Extensions   *extensions     `json:"extensions,omitempty"`
+	Extensions   *Extensions       `json:"extensions,omitempty"`
 }
 
 // RequestConfig is used to pass request specific config values to the GraphQL and Subscribe functions. Dynamic variables can be set here.
@@ -369,11 +420,33 @@ type RequestConfig struct {
 	// APQKey is set when using GraphJin with automatic persisted queries
 	APQKey string
 
+	// APQHash is the sha256 hash (hex) of the query sent with an automatic
+	// persisted query request. When set the query is verified against the hash
+	// and if only the hash is sent the query must be in the cache.
+	APQHash string
+
 	// Pass additional variables complex variables such as functions that return string values.
 	Vars map[string]interface{}
 
 	// Execute this query as part of a transaction
 	Tx *sql.Tx
+
+	// Dialect compiles the query with the compiler of this database type
+	// instead of the one of the database. It is only supported by Compile
+	// and not allowed in production mode
+	Dialect string
+
+	// AllowListBypass is set by trusted internal callers to the configured
+	// allow list bypass secret to run queries that are not in the allow list.
+	// It must never be set from request input.
+	AllowListBypass string
+
+	// Caller identifies the trusted caller in the audit log of bypassed queries
+	Caller string
+
+	// Timing returns the time spent in each phase of the query in the
+	// extensions of the result
+	Timing bool
 }
 
 // SetNamespace is used to set namespace requests within a single instance of GraphJin. For example queries with the same name
@@ -414,8 +487,10 @@ func (g *GraphJin) GraphQL(c context.Context,
 	var inCache bool
 
 	// get query from apq cache if apq key exists
-	if rc != nil && rc.APQKey != "" {
-		queryBytes, inCache = gj.cache.Get(APQ_PX + rc.APQKey)
+	if rc.apqCacheKey() != "" {
+		if queryBytes, inCache, err = gj.getAPQQuery(c, rc, query); err != nil {
+			return
+		}
 	}
 
 	// query not found in apq cache so use original query
@@ -429,10 +504,23 @@ func (g *GraphJin) GraphQL(c context.Context,
 		return
 	}
 	r := gj.newGraphqlReq(rc, h.Operation, h.Name, queryBytes, vars)
+	r.span = span
+
+	if err = gj.checkVarsLimits(r.operation, vars); err != nil {
+		return
+	}
+
+	if err = gj.checkReadOnly(r.operation); err != nil {
+		return
+	}
+
+	if r.bypass, err = gj.allowListBypassed(rc, h.Name, queryBytes); err != nil {
+		return
+	}
 
 	// if production security enabled then get query and metadata
 	// from allow list
-	if gj.prodSec {
+	if gj.prodSec && !r.bypass {
 		var item allow.Item
 		item, err = gj.allowList.GetByName(h.Name, true)
 		if err != nil {
@@ -450,8 +538,8 @@ func (g *GraphJin) GraphQL(c context.Context,
 	}
 
 	// save to apq cache is apq key exists and not already in cache
-	if !inCache && rc != nil && rc.APQKey != "" {
-		gj.cache.Set((APQ_PX + rc.APQKey), r.query)
+	if apqKey := rc.apqCacheKey(); !inCache && apqKey != "" {
+		gj.cacheSet(c, apqKey, r.query)
 	}
 
 	// if not production then save to allow list
@@ -502,6 +590,15 @@ func (g *GraphJin) GraphQLByName(c context.Context,
 
 	r := gj.newGraphqlReq(rc, "", name, nil, vars)
 	r.Set(item)
+	r.span = span
+
+	if err = gj.checkVarsLimits(r.operation, vars); err != nil {
+		return
+	}
+
+	if err = gj.checkReadOnly(r.operation); err != nil {
+		return
+	}
 
 	res, err = gj.queryWithResult(c1, r)
 	return
@@ -531,6 +628,10 @@ type GraphqlReq struct {
 	vars          json.RawMessage
 	aschema       map[string]json.RawMessage
 	requestconfig *RequestConfig
+	bypass        bool
+
+	// span of the request, it's annotated with the attributes of the query
+	span Spaner
 }
 
 type GraphqlResponse struct {
@@ -581,6 +682,10 @@ func (r *GraphqlReq) isIntro() bool {
 			}
 		}
 	}
+	// skip the alias of the field if any
+	if i := strings.IndexByte(q, ':'); i != -1 && !strings.ContainsAny(q[:i], "({") {
+		q = strings.TrimSpace(q[i+1:])
+	}
 	return strings.HasPrefix(q, "__schema") || strings.HasPrefix(q, "__type")
 }
 
@@ -591,6 +696,35 @@ func (r *GraphqlReq) Set(item allow.Item) {
 	r.name = item.Name
 	r.query = item.Query
 	r.aschema = item.ActionJSON
+	r.vars = mergeVarDefaults(r.vars, item.Defaults)
+}
+
+// mergeVarDefaults adds the default values of the variables of an allow list
+// query that are not in the variables of the client. Invalid variables are
+// returned as is and fail when the request is executed.
+func mergeVarDefaults(vars json.RawMessage, defaults map[string]json.RawMessage) json.RawMessage {
+	if len(defaults) == 0 {
+		return vars
+	}
+	vmap := make(map[string]json.RawMessage, len(defaults))
+	if len(bytes.TrimSpace(vars)) != 0 {
+		if err := json.Unmarshal(vars, &vmap); err != nil {
+			return vars
+		}
+		if vmap == nil {
+			vmap = make(map[string]json.RawMessage, len(defaults))
+		}
+	}
+	for k, v := range defaults {
+		if _, ok := vmap[k]; !ok {
+			vmap[k] = v
+		}
+	}
+	b, err := json.Marshal(vmap)
+	if err != nil {
+		return vars
+	}
+	return b
 }
 
 // GraphQL function is our main function it takes a GraphQL query compiles it
@@ -610,7 +744,7 @@ func (gj *graphjinEngine) query(c context.Context, r GraphqlReq) (
 	}
 
 	if !gj.prodSec && r.isIntro() {
-		resp.res.Data, err = gj.getIntroResult()
+		resp.res.Data, err = gj.introTypeResult(r)
 		return
 	}
 
@@ -619,6 +753,11 @@ func (gj *graphjinEngine) query(c context.Context, r GraphqlReq) (
 		return
 	}
 
+	if rc := r.requestconfig; rc != nil && rc.Dialect != "" {
+		err = errDialectOverride
+		return
+	}
+
 	if !gj.anyDatabaseReady() {
 		err = fmt.Errorf("no tables found in any database; schema not initialized")
 		return
@@ -628,26 +767,55 @@ func (gj *graphjinEngine) query(c context.Context, r GraphqlReq) (
 	if err != nil {
 		return
 	}
+	start := time.Now()
+	defer s.setExtensions(&resp.res, start)
+
 	err = s.compileAndExecuteWrapper(c)
+	s.recordMetrics(c, start, err)
 
 	resp.qc = s.qcode()
+	s.setSpanAttributes(r.span, resp.qc)
+	resp.res.qc = resp.qc
 	resp.res.sql = s.sql()
+	resp.res.params = s.params
 	resp.res.cacheControl = s.cacheHeader()
 	resp.res.Vars = r.vars
 	// Strip internal __gj_id fields unconditionally when cache tracking is enabled.
 	// This handles all code paths: cache hits, multi-DB queries, and regular queries.
+	t := s.timing.now()
 	if gj.conf.CacheTrackingEnabled {
 		s.data = stripGjIdFields(s.data)
 	}
-	resp.res.Data = json.RawMessage(s.data)
+	resp.res.Data = json.RawMessage(gj.outputData(s.data))
+	s.timing.add(timingAssemble, t)
 	resp.res.Hash = s.dhash
 	resp.res.role = s.role
 	resp.res.cacheHit = s.cacheHit
+	resp.res.truncated = s.truncated
 
 	if err != nil {
+		err = gj.redactError(err)
 		resp.res.Errors = newError(err)
 	}
 
+	for _, e := range s.rerrs {
+		re := newError(gj.redactError(e.err))[0]
+		re.Path = []string{e.key}
+		resp.res.Errors = append(resp.res.Errors, re)
+	}
+
+	for _, e := range s.fderrs {
+		fe := newError(gj.redactError(e.err))[0]
+		fe.Path = e.path
+		resp.res.Errors = append(resp.res.Errors, fe)
+	}
+
+	for _, e := range s.ferrs {
+		resp.res.Errors = append(resp.res.Errors, Error{Message: e.Error()})
+	}
+
+	resp.res.Errors = append(resp.res.Errors, unknownFieldErrors(resp.qc)...)
+
 	if len(s.verrs) != 0 {
 		resp.res.Validation = s.verrs
 	}
@@ -733,7 +901,18 @@ func getFS(conf *Config) (fs FS, err error) {
 
 // newError creates a new error list
 func newError(err error) (errList []Error) {
-	errList = []Error{{Message: err.Error()}}
+	e := Error{Message: err.Error()}
+
+	var ce *ConstraintError
+	if errors.As(err, &ce) {
+		e.Extensions = &ErrorExtensions{
+			Code:       ErrConstraintViolation.Error(),
+			Kind:       ce.Kind,
+			Constraint: ce.Constraint,
+			Columns:    ce.Columns,
+		}
+	}
+	errList = []Error{e}
 	return
 }
 
@@ -793,6 +972,7 @@ type ColumnInfo struct {
 	PrimaryKey bool   `json:"primary_key"`
 	ForeignKey string `json:"foreign_key,omitempty"` // "schema.table.column" if FK
 	Array      bool   `json:"array,omitempty"`
+	Default    string `json:"default,omitempty"` // set when the column is omitted on insert
 }
 
 // RelationInfo represents a relationship between tables
@@ -963,6 +1143,7 @@ func (gj *graphjinEngine) buildTableSchema(dbSchema *sdata.DBSchema, dbName, tab
 			Nullable:   !col.NotNull,
 			PrimaryKey: col.PrimaryKey,
 			Array:      col.Array,
+			Default:    col.Default,
 		}
 		if col.FKeyTable != "" {
 			ci.ForeignKey = fmt.Sprintf("%s.%s", col.FKeyTable, col.FKeyCol)
@@ -1155,8 +1336,10 @@ type OperationPermission struct {
 	Limit            int               `json:"limit,omitempty"`
 	Filters          []string          `json:"filters,omitempty"`
 	Columns          []string          `json:"columns,omitempty"`
+	Masks            map[string]string `json:"masks,omitempty"`
 	Presets          map[string]string `json:"presets,omitempty"`
 	DisableFunctions bool              `json:"disable_functions,omitempty"`
+	Materialize      []string          `json:"materialize,omitempty"`
 }
 
 // TablePermissions represents per-table permission details for a role
@@ -1634,7 +1817,9 @@ func buildQueryPermission(q *Query) *OperationPermission {
 		Limit:            q.Limit,
 		Filters:          q.Filters,
 		Columns:          q.Columns,
+		Masks:            q.Masks,
 		DisableFunctions: q.DisableFunctions,
+		Materialize:      q.Materialize,
 	}
 }
 
@@ -1715,6 +1900,7 @@ type SavedQueryDetails struct {
 	Operation string                 `json:"operation"`
 	Query     string                 `json:"query"`
 	Variables map[string]interface{} `json:"variables,omitempty"`
+	Defaults  map[string]interface{} `json:"defaults,omitempty"`
 }
 
 // ListSavedQueries returns all saved queries from the allow list
@@ -1770,6 +1956,16 @@ func (g *GraphJin) GetSavedQuery(name string) (*SavedQueryDetails, error) {
 		}
 	}
 
+	if len(item.Defaults) > 0 {
+		details.Defaults = make(map[string]interface{})
+		for k, v := range item.Defaults {
+			var val interface{}
+			if err := json.Unmarshal(v, &val); err == nil {
+				details.Defaults[k] = val
+			}
+		}
+	}
+
 	return details, nil
 }
 
@@ -1898,17 +2094,7 @@ func (g *GraphJin) GetAllDatabaseStats() []DatabaseStats {
 			ReadOnly:  readOnly,
 		}
 
-		// Get table count from schema
-		if ctx.schema != nil {
-			tables := ctx.schema.GetTables()
-			count := 0
-			for _, t := range tables {
-				if t.Type != "virtual" && !t.Blocked {
-					count++
-				}
-			}
-			ds.TableCount = count
-		}
+		ds.TableCount = ctx.tableCount()
 
 		// Get pool stats if DB connection exists
 		if ctx.db != nil {
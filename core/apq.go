@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrPersistedQueryNotFound is returned when only the hash of a persisted
+	// query is sent and the query is not in the cache. The client is expected
+	// to retry with the full query.
+	ErrPersistedQueryNotFound = errors.New("PERSISTED_QUERY_NOT_FOUND")
+
+	// ErrPersistedQueryHashMismatch is returned when the sha256 hash of the
+	// query does not match the hash sent with it
+	ErrPersistedQueryHashMismatch = errors.New("PERSISTED_QUERY_HASH_MISMATCH")
+)
+
+// apqCacheKey returns the cache key for the persisted query
+func (rc *RequestConfig) apqCacheKey() string {
+	if rc == nil {
+		return ""
+	}
+	if rc.APQKey != "" {
+		return APQ_PX + rc.APQKey
+	}
+	if rc.APQHash != "" {
+		return APQ_PX + strings.ToLower(rc.APQHash)
+	}
+	return ""
+}
+
+// getAPQQuery returns the query to execute for an automatic persisted query request.
+// When a hash is set the query sent is verified against it and when no query
+// is sent it must be found in the cache.
+func (gj *graphjinEngine) getAPQQuery(c context.Context, rc *RequestConfig, query string) (
+	queryBytes []byte, inCache bool, err error,
+) {
+	key := rc.apqCacheKey()
+
+	if rc.APQHash == "" {
+		queryBytes, inCache = gj.cacheGet(c, key)
+		return
+	}
+
+	if query == "" {
+		if queryBytes, inCache = gj.cacheGet(c, key); !inCache {
+			err = ErrPersistedQueryNotFound
+		}
+		return
+	}
+
+	h := sha256.Sum256([]byte(query))
+	if !strings.EqualFold(hex.EncodeToString(h[:]), rc.APQHash) {
+		err = ErrPersistedQueryHashMismatch
+		return
+	}
+	_, inCache = gj.cacheGet(c, key)
+	queryBytes = []byte(query)
+	return
+}
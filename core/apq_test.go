@@ -0,0 +1,197 @@
+package core_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestAPQHashVerification(t *testing.T) {
+	db := newTestDB(t, "apqdb1")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query getUsers { users(order_by: { id: asc }) { id } }`
+	h := sha256.Sum256([]byte(gql))
+	hash := hex.EncodeToString(h[:])
+	ctx := context.Background()
+
+	// only the hash is sent and the query is not cached yet
+	_, err = gj.GraphQL(ctx, "", nil, &core.RequestConfig{APQHash: hash})
+	if !errors.Is(err, core.ErrPersistedQueryNotFound) {
+		t.Fatalf("expected persisted query not found error, got: %v", err)
+	}
+
+	// the query does not match the hash
+	_, err = gj.GraphQL(ctx, `query getUsers { users { id full_name } }`, nil,
+		&core.RequestConfig{APQHash: hash})
+	if !errors.Is(err, core.ErrPersistedQueryHashMismatch) {
+		t.Fatalf("expected persisted query hash mismatch error, got: %v", err)
+	}
+
+	// the mismatched query must not be cached under the hash
+	_, err = gj.GraphQL(ctx, "", nil, &core.RequestConfig{APQHash: hash})
+	if !errors.Is(err, core.ErrPersistedQueryNotFound) {
+		t.Fatalf("expected persisted query not found error, got: %v", err)
+	}
+
+	if _, err := gj.GraphQL(ctx, gql, nil, &core.RequestConfig{APQHash: hash}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := gj.GraphQL(ctx, "", nil, &core.RequestConfig{APQHash: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":1},{"id":2}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+}
+
+func TestAPQKey(t *testing.T) {
+	db := newTestDB(t, "apqdb2")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query getUsers { users(order_by: { id: asc }) { id } }`
+	rc := &core.RequestConfig{APQKey: "getUsers1"}
+	ctx := context.Background()
+
+	if _, err := gj.GraphQL(ctx, gql, nil, rc); err != nil {
+		t.Fatal(err)
+	}
+
+	// the query is fetched from the cache using the key alone
+	res, err := gj.GraphQL(ctx, "", nil, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":1},{"id":2}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+}
+
+// sharedCache is a cache shared by several engines, get fails when broken
+type sharedCache struct {
+	vals   map[string][]byte
+	ttls   map[string]time.Duration
+	broken bool
+}
+
+func (c *sharedCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if c.broken {
+		return nil, false, errors.New("connection refused")
+	}
+	v, ok := c.vals[key]
+	return v, ok, nil
+}
+
+func (c *sharedCache) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	c.vals[key] = val
+	c.ttls[key] = ttl
+	return nil
+}
+
+func (c *sharedCache) Delete(ctx context.Context, key string) error {
+	delete(c.vals, key)
+	return nil
+}
+
+func TestAPQSharedCache(t *testing.T) {
+	db := newTestDB(t, "apqdb3")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true, APQCacheTTL: time.Hour}
+	sc := &sharedCache{vals: make(map[string][]byte), ttls: make(map[string]time.Duration)}
+
+	gj1, err := core.NewGraphJin(conf, db, core.OptionSetCache(sc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gj2, err := core.NewGraphJin(conf, db, core.OptionSetCache(sc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query getUsers { users(order_by: { id: asc }) { id } }`
+	h := sha256.Sum256([]byte(gql))
+	hash := hex.EncodeToString(h[:])
+	ctx := context.Background()
+
+	if _, err := gj1.GraphQL(ctx, gql, nil, &core.RequestConfig{APQHash: hash}); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := sc.ttls["_apq"+hash]; ttl != time.Hour {
+		t.Fatalf("expected the persisted query cached for an hour, got: %s", ttl)
+	}
+
+	// the query persisted by one instance is found by the other
+	res, err := gj2.GraphQL(ctx, "", nil, &core.RequestConfig{APQHash: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":1},{"id":2}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+
+	// errors of the cache are misses
+	sc.broken = true
+	_, err = gj2.GraphQL(ctx, "", nil, &core.RequestConfig{APQHash: hash})
+	if !errors.Is(err, core.ErrPersistedQueryNotFound) {
+		t.Fatalf("expected persisted query not found error, got: %v", err)
+	}
+	if _, err := gj2.GraphQL(ctx, gql, nil, &core.RequestConfig{APQHash: hash}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAPQInMemoryCacheProvider(t *testing.T) {
+	db := newTestDB(t, "apqdb4")
+
+	cache, err := core.NewCache(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj1, err := core.NewGraphJin(conf, db, core.OptionSetCache(core.NewCacheProvider(cache)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gj2, err := core.NewGraphJin(conf, db, core.OptionSetCache(core.NewCacheProvider(cache)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query getUsers { users(order_by: { id: asc }) { id } }`
+	h := sha256.Sum256([]byte(gql))
+	hash := hex.EncodeToString(h[:])
+	ctx := context.Background()
+
+	if _, err := gj1.GraphQL(ctx, gql, nil, &core.RequestConfig{APQHash: hash}); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := cache.Get("_apq" + hash); !ok || string(v) != gql {
+		t.Fatalf("expected the persisted query in the cache, got: %s", v)
+	}
+
+	res, err := gj2.GraphQL(ctx, "", nil, &core.RequestConfig{APQHash: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":1},{"id":2}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/dosco/graphjin/core/v3/internal/psql"
@@ -87,6 +88,9 @@ func (gj *graphjinEngine) argList(c context.Context,
 				}
 				ar.cindxs = append(ar.cindxs, i)
 			} else if v, ok := fields[p.Name]; ok {
+				if gj.conf.CoerceVariables {
+					v = coerceVar(p, v)
+				}
 				varIsNull := bytes.Equal(v, []byte("null"))
 
 				switch {
@@ -184,6 +188,48 @@ func parseVarVal(v json.RawMessage) interface{} {
 	}
 }
 
+// coerceVar converts a string value to a number or boolean when the
+// variable is bound to an integer, float or boolean column. Values of
+// other types and strings that are not valid for the column are returned as is
+func coerceVar(p psql.Param, v json.RawMessage) json.RawMessage {
+	if len(v) == 0 || v[0] != '"' || p.IsArray {
+		return v
+	}
+	gqlType, list := getType(p.Type)
+	if list {
+		return v
+	}
+
+	var s string
+	if err := json.Unmarshal(v, &s); err != nil {
+		return v
+	}
+
+	switch gqlType {
+	case "Int":
+		if _, err := strconv.ParseInt(s, 10, 64); err == nil && isJSONNumber(s) {
+			return json.RawMessage(s)
+		}
+	case "Float":
+		if isJSONNumber(s) {
+			return json.RawMessage(s)
+		}
+	case "Boolean":
+		if s == "true" || s == "false" {
+			return json.RawMessage(s)
+		}
+	}
+	return v
+}
+
+// isJSONNumber returns true if the string is a number in JSON syntax
+func isJSONNumber(s string) bool {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return false
+	}
+	return json.Valid([]byte(s))
+}
+
 func argErr(p psql.Param) error {
 	return fmt.Errorf("required variable '%s' of type '%s' must be set", p.Name, p.Type)
 }
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// defaultBulkInsertBatchSize is the number of rows inserted per batch
+// when bulk_insert_batch_size is not set
+const defaultBulkInsertBatchSize = 500
+
+// BulkInsertResult is returned by BulkInsert
+type BulkInsertResult struct {
+	// Rows is the number of rows read from the input
+	Rows int `json:"rows"`
+	// Inserted is the number of rows inserted
+	Inserted int `json:"inserted"`
+	// Batches is the number of batches sent to the database
+	Batches int `json:"batches"`
+	// Errors holds the batches that failed to insert
+	Errors []BulkInsertError `json:"errors,omitempty"`
+}
+
+// BulkInsertError is the error of a single failed batch
+type BulkInsertError struct {
+	// Batch is the index of the batch starting from zero
+	Batch int `json:"batch"`
+	// FirstLine and LastLine are the input lines held by the batch
+	FirstLine int   `json:"first_line"`
+	LastLine  int   `json:"last_line"`
+	Err       error `json:"-"`
+}
+
+func (e BulkInsertError) Error() string {
+	return fmt.Sprintf("batch %d (lines %d-%d): %s", e.Batch, e.FirstLine, e.LastLine, e.Err)
+}
+
+func (e BulkInsertError) Unwrap() error {
+	return e.Err
+}
+
+// BulkInsert reads newline delimited JSON objects from the reader and inserts
+// them into the table in batches. Each batch is run as an insert mutation so
+// the role's insert rules and presets are applied to every row. A failed batch
+// is recorded in the result and the import moves on to the next batch. Blank
+// lines are skipped, a line that is not a JSON object stops the import.
+// The context is checked before every batch is sent
+func (g *GraphJin) BulkInsert(c context.Context,
+	table string,
+	reader io.Reader,
+	rc *RequestConfig,
+) (res BulkInsertResult, err error) {
+	gj, err := g.getEngine()
+	if err != nil {
+		return
+	}
+
+	query, err := gj.bulkInsertQuery(table)
+	if err != nil {
+		return
+	}
+
+	size := gj.conf.BulkInsertBatchSize
+	if size <= 0 {
+		size = defaultBulkInsertBatchSize
+	}
+	if limit := gj.conf.MaxBulkInsert; limit > 0 && size > limit {
+		size = limit
+	}
+
+	var batch bytes.Buffer
+	var rows, firstLine, line int
+
+	flush := func() error {
+		if rows == 0 {
+			return nil
+		}
+		if err := c.Err(); err != nil {
+			return err
+		}
+		batch.WriteString("]}")
+
+		n, err := gj.bulkInsertBatch(c, query, batch.Bytes(), rc)
+		if err != nil {
+			res.Errors = append(res.Errors, BulkInsertError{
+				Batch:     res.Batches,
+				FirstLine: firstLine,
+				LastLine:  line,
+				Err:       err,
+			})
+		}
+		res.Inserted += n
+		res.Batches++
+		batch.Reset()
+		rows = 0
+		return nil
+	}
+
+	br := bufio.NewReader(reader)
+	for {
+		b, rerr := br.ReadBytes('\n')
+		if rerr != nil && rerr != io.EOF {
+			err = rerr
+			return
+		}
+		if len(b) != 0 {
+			line++
+		}
+
+		if b = bytes.TrimSpace(b); len(b) != 0 {
+			if b[0] != '{' || !json.Valid(b) {
+				err = fmt.Errorf("bulk insert: line %d is not a json object", line)
+				return
+			}
+			if rows == 0 {
+				firstLine = line
+				batch.WriteString(`{"data":[`)
+			} else {
+				batch.WriteByte(',')
+			}
+			batch.Write(b)
+			rows++
+			res.Rows++
+		}
+
+		if rows == size || (rerr == io.EOF && rows != 0) {
+			if err = flush(); err != nil {
+				return
+			}
+		}
+		if rerr == io.EOF {
+			return
+		}
+	}
+}
+
+// bulkInsertQuery returns the insert mutation used for each batch,
+// it selects the primary key to count the inserted rows
+func (gj *graphjinEngine) bulkInsertQuery(table string) (string, error) {
+	ts, err := gj.getTableSchema("", table)
+	if err != nil {
+		return "", err
+	}
+	col := ts.PrimaryKey
+	if col == "" && len(ts.Columns) != 0 {
+		col = ts.Columns[0].Name
+	}
+	if col == "" {
+		return "", fmt.Errorf("bulk insert: table has no columns: %s", table)
+	}
+	return fmt.Sprintf("mutation bulk_insert { %s(insert: $data) { %s } }",
+		ts.Name, col), nil
+}
+
+// bulkInsertBatch runs the insert mutation for a single batch and returns
+// the number of inserted rows. The query is generated by BulkInsert so it
+// does not go through the allow list
+func (gj *graphjinEngine) bulkInsertBatch(c context.Context,
+	query string,
+	vars json.RawMessage,
+	rc *RequestConfig,
+) (int, error) {
+	r := gj.newGraphqlReq(rc, "mutation", "bulk_insert", []byte(query), vars)
+	r.bypass = true
+
+	resp, err := gj.query(c, r)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.res.Errors) != 0 {
+		return 0, errors.New(resp.res.Errors[0].Message)
+	}
+
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(resp.res.Data, &data); err != nil {
+		return 0, err
+	}
+	for _, v := range data {
+		var rows []json.RawMessage
+		if err := json.Unmarshal(v, &rows); err == nil {
+			return len(rows), nil
+		}
+		return 1, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,73 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestBulkInsert(t *testing.T) {
+	db := newTestDB(t, "bulkinsert")
+
+	conf := &core.Config{
+		DBType:              "sqlite",
+		Production:          true,
+		BulkInsertBatchSize: 2,
+		Roles: []core.Role{{
+			Name: "user",
+			Tables: []core.RoleTable{{
+				Name:   "products",
+				Insert: &core.Insert{Presets: map[string]string{"owner_id": "$user_id"}},
+			}},
+		}},
+	}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 2)
+
+	// the second batch fails since product 1 already exists
+	input := `{"id": 10, "name": "P10", "owner_id": 1}
+{"id": 11, "name": "P11"}
+
+{"id": 1, "name": "P1"}
+{"id": 12, "name": "P12"}
+{"id": 13, "name": "P13"}`
+
+	res, err := gj.BulkInsert(ctx, "products", strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Rows != 5 || res.Inserted != 3 || res.Batches != 3 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Batch != 1 ||
+		res.Errors[0].FirstLine != 4 || res.Errors[0].LastLine != 5 {
+		t.Fatalf("unexpected errors: %+v", res.Errors)
+	}
+
+	var owners int
+	err = db.QueryRow(`SELECT COUNT(*) FROM products WHERE id IN (10, 11, 13) AND owner_id = 2`).Scan(&owners)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owners != 3 {
+		t.Fatalf("expected the preset to set the owner of every row, got %d", owners)
+	}
+
+	_, err = gj.BulkInsert(ctx, "products", strings.NewReader(`{"id": 20}`+"\n"+`[1]`), nil)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected an invalid line error, got: %v", err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = gj.BulkInsert(cctx, "products", strings.NewReader(`{"id": 21}`), nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled context error, got: %v", err)
+	}
+}
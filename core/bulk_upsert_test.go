@@ -0,0 +1,70 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestBulkUpsert(t *testing.T) {
+	db := newTestDB(t, "bulkupsertdb1")
+	if _, err := db.Exec(`CREATE UNIQUE INDEX users_email ON users (email)`); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the existing user is updated and the new one inserted, both are returned
+	gql := `mutation {
+		users(insert: $data, on_conflict: { target: [email], update: [full_name] }, order_by: { id: asc }) {
+			id
+			email
+			full_name
+		}
+	}`
+	vars := json.RawMessage(`{"data": [
+		{"email": "user1@test.com", "full_name": "User One Updated"},
+		{"email": "user3@test.com", "full_name": "User Three"}
+	]}`)
+	res, err := gj.GraphQL(context.Background(), gql, vars, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"users":[{"id":1,"email":"user1@test.com","full_name":"User One Updated"},` +
+		`{"id":3,"email":"user3@test.com","full_name":"User Three"}]}`
+	if string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 users, got %d", n)
+	}
+
+	// the conflict handling is only valid on inserts
+	gql = `mutation {
+		users(update: $data, where: { id: { eq: 1 } }, on_conflict: { target: [email] }) { id }
+	}`
+	vars = json.RawMessage(`{"data": {"full_name": "User One"}}`)
+	if _, err := gj.GraphQL(context.Background(), gql, vars, nil); err == nil {
+		t.Fatal("expected an error for on_conflict on an update")
+	}
+
+	// the target columns must be inserted
+	gql = `mutation {
+		users(insert: $data, on_conflict: { target: [email] }) { id }
+	}`
+	vars = json.RawMessage(`{"data": [{"full_name": "User Four"}]}`)
+	if _, err := gj.GraphQL(context.Background(), gql, vars, nil); err == nil {
+		t.Fatal("expected an error for a target column that is not inserted")
+	}
+}
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+// minBypassSecretLen is the minimum length of the allow list bypass secret
+const minBypassSecretLen = 16
+
+// ErrInvalidBypass is returned when the allow list bypass token
+// set in the request config does not match the configured secret
+var ErrInvalidBypass = errors.New("invalid allow list bypass token")
+
+// allowListBypassed returns true if the request is allowed to bypass the
+// allow list. The token can only be set in the request config by Go code
+// so untrusted request input can never bypass the allow list.
+func (gj *graphjinEngine) allowListBypassed(rc *RequestConfig, name string, query []byte) (bool, error) {
+	if rc == nil || rc.AllowListBypass == "" {
+		return false, nil
+	}
+
+	secret := gj.conf.AllowListBypassSecret
+	if secret == "" ||
+		subtle.ConstantTimeCompare([]byte(rc.AllowListBypass), []byte(secret)) != 1 {
+		gj.log.Printf("allow list bypass denied: caller=%q name=%q", rc.Caller, name)
+		return false, ErrInvalidBypass
+	}
+
+	if rc.Caller == "" {
+		return false, errors.New("allow list bypass requires a caller")
+	}
+
+	// only log when the allow list is actually enforced
+	if gj.prodSec {
+		gj.log.Printf("allow list bypassed: caller=%q name=%q query=%q", rc.Caller, name, query)
+	}
+	return true, nil
+}
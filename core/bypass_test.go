@@ -0,0 +1,64 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestAllowListBypass(t *testing.T) {
+	db := newTestDB(t, "bypassdb")
+
+	conf := &core.Config{
+		DBType:                "sqlite",
+		Production:            true,
+		AllowListBypassSecret: "0123456789abcdef",
+		Roles: []core.Role{{
+			Name:   "anon",
+			Tables: []core.RoleTable{{Name: "users", Query: &core.Query{Filters: []string{"{ id: 1 }"}}}},
+		}},
+	}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	gql := `query getUsers { users(order_by: { id: asc }) { id email } }`
+
+	// queries not in the allow list are rejected
+	if _, err := gj.GraphQL(ctx, gql, nil, nil); err == nil {
+		t.Fatal("expected an error for a query not in the allow list")
+	}
+
+	rc := &core.RequestConfig{AllowListBypass: "wrong-secret-value", Caller: "reports"}
+	if _, err := gj.GraphQL(ctx, gql, nil, rc); !errors.Is(err, core.ErrInvalidBypass) {
+		t.Fatalf("expected an invalid bypass error, got: %v", err)
+	}
+
+	rc = &core.RequestConfig{AllowListBypass: conf.AllowListBypassSecret}
+	if _, err := gj.GraphQL(ctx, gql, nil, rc); err == nil {
+		t.Fatal("expected an error for a bypass without a caller")
+	}
+
+	// role filters still apply to bypassed queries
+	rc.Caller = "reports"
+	res, err := gj.GraphQL(ctx, gql, nil, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":1,"email":"user1@test.com"}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+}
+
+func TestAllowListBypassSecretTooShort(t *testing.T) {
+	db := newTestDB(t, "bypassdb_short")
+
+	conf := &core.Config{DBType: "sqlite", Production: true, AllowListBypassSecret: "short"}
+	if _, err := core.NewGraphJin(conf, db); err == nil {
+		t.Fatal("expected an error for a short bypass secret")
+	}
+}
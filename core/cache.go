@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	lru "github.com/hashicorp/golang-lru/v2"
@@ -18,6 +19,7 @@ type ResponseCacheProvider interface {
 	// Set stores a response with row-level indices for invalidation.
 	// refs contains (table, row_id) pairs for fine-grained cache invalidation.
 	// queryStartTime is used for race condition detection.
+	// The max-age set with @cacheControl is on the context, see ResponseMaxAge.
 	Set(ctx context.Context, key string, data []byte, refs []RowRef, queryStartTime time.Time) error
 
 	// InvalidateRows invalidates cache entries for specific rows.
@@ -25,24 +27,160 @@ type ResponseCacheProvider interface {
 	InvalidateRows(ctx context.Context, refs []RowRef) error
 }
 
-// Cache provides local in-memory caching for APQ and introspection
+type maxAgeKey struct{}
+
+// ResponseMaxAge returns the max-age of the response being stored in the
+// response cache as set with @cacheControl, it should not be cached longer
+func ResponseMaxAge(ctx context.Context) (time.Duration, bool) {
+	v, ok := ctx.Value(maxAgeKey{}).(time.Duration)
+	return v, ok
+}
+
+// CacheProvider is the cache of the persisted queries (APQ). It is in
+// memory by default, set a cache shared by all the instances like Redis with
+// OptionSetCache so a persisted query sent to one instance can be used with
+// the others and after a restart. Errors are logged and treated as misses.
+type CacheProvider interface {
+	// Get returns the value of the key, found is false when it is not cached
+	Get(ctx context.Context, key string) (val []byte, found bool, err error)
+
+	// Set stores the value of the key, it expires after ttl unless ttl is 0
+	Set(ctx context.Context, key string, val []byte, ttl time.Duration) error
+
+	// Delete removes the key from the cache
+	Delete(ctx context.Context, key string) error
+}
+
+// OptionSetCache sets the cache of the persisted queries, the default is
+// an in-memory cache local to the instance
+func OptionSetCache(cache CacheProvider) Option {
+	return func(s *graphjinEngine) error {
+		if cache == nil {
+			return errors.New("cache: cannot be nil")
+		}
+		s.cache = cache
+		return nil
+	}
+}
+
+// defaultCacheSize is the number of values and of compiled queries held
+// by the in-memory cache of an instance
+const defaultCacheSize = 5000
+
+// Cache provides local in-memory caching for APQ, introspection and the
+// compiled queries. Compiled queries reference the schema so they are
+// always cached in memory, use NewCacheProvider to set a Cache as the
+// cache of the persisted queries
 type Cache struct {
-	cache *lru.TwoQueueCache[string, []byte]
+	cache   *lru.TwoQueueCache[string, cacheEntry]
+	queries *lru.Cache[string, *cstate]
+}
+
+type cacheEntry struct {
+	val []byte
+	exp time.Time
+}
+
+// NewCache returns an in-memory cache holding up to size values
+func NewCache(size int) (*Cache, error) {
+	c := &Cache{}
+	var err error
+	if c.cache, err = lru.New2Q[string, cacheEntry](size); err != nil {
+		return nil, err
+	}
+	if c.queries, err = lru.New[string, *cstate](size); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 // initCache initializes the cache
 func (gj *graphjinEngine) initCache() (err error) {
-	gj.cache.cache, err = lru.New2Q[string, []byte](5000)
+	if gj.localCache, err = NewCache(defaultCacheSize); err != nil {
+		return
+	}
+	gj.cache = NewCacheProvider(gj.localCache)
 	return
 }
 
 // Get returns the value from the cache
-func (c Cache) Get(key string) (val []byte, fromCache bool) {
-	val, fromCache = c.cache.Get(key)
-	return
+func (c *Cache) Get(key string) ([]byte, bool) {
+	e, ok := c.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if !e.exp.IsZero() && time.Now().After(e.exp) {
+		c.cache.Remove(key)
+		return nil, false
+	}
+	return e.val, true
 }
 
 // Set sets the value in the cache
-func (c Cache) Set(key string, val []byte) {
-	c.cache.Add(key, val)
+func (c *Cache) Set(key string, val []byte) {
+	c.cache.Add(key, cacheEntry{val: val})
+}
+
+// compiled returns the compiled query of the key, an empty one is added
+// when it is not cached and loaded is false
+func (c *Cache) compiled(key string) (cs *cstate, loaded bool) {
+	if cs, ok := c.queries.Get(key); ok {
+		return cs, true
+	}
+	cs = &cstate{}
+	if prev, ok, _ := c.queries.PeekOrAdd(key, cs); ok {
+		return prev, true
+	}
+	return cs, false
+}
+
+// NewCacheProvider returns a CacheProvider backed by the in-memory cache,
+// it lets a Cache be set with OptionSetCache
+func NewCacheProvider(c *Cache) CacheProvider {
+	return memCache{c}
+}
+
+// memCache is the CacheProvider of an in-memory cache
+type memCache struct {
+	c *Cache
+}
+
+// Get returns the value from the cache
+func (m memCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	val, ok := m.c.Get(key)
+	return val, ok, nil
+}
+
+// Set sets the value in the cache
+func (m memCache) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	e := cacheEntry{val: val}
+	if ttl > 0 {
+		e.exp = time.Now().Add(ttl)
+	}
+	m.c.cache.Add(key, e)
+	return nil
+}
+
+// Delete removes the value from the cache
+func (m memCache) Delete(ctx context.Context, key string) error {
+	m.c.cache.Remove(key)
+	return nil
+}
+
+// cacheGet returns the value from the cache, an error of the cache is
+// logged and returned as a miss
+func (gj *graphjinEngine) cacheGet(c context.Context, key string) ([]byte, bool) {
+	val, ok, err := gj.cache.Get(c, key)
+	if err != nil {
+		gj.log.Printf("cache: get '%s': %s", key, err)
+		return nil, false
+	}
+	return val, ok
+}
+
+// cacheSet sets the value in the cache, an error of the cache is logged
+func (gj *graphjinEngine) cacheSet(c context.Context, key string, val []byte) {
+	if err := gj.cache.Set(c, key, val, gj.conf.APQCacheTTL); err != nil {
+		gj.log.Printf("cache: set '%s': %s", key, err)
+	}
 }
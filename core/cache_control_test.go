@@ -0,0 +1,69 @@
+package core_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+// maxAgeCache records the max-age of the responses it stores
+type maxAgeCache struct {
+	sets    int
+	maxAges []time.Duration
+}
+
+func (c *maxAgeCache) Get(ctx context.Context, key string) ([]byte, bool, bool) {
+	return nil, false, false
+}
+
+func (c *maxAgeCache) Set(ctx context.Context, key string, data []byte, refs []core.RowRef, queryStartTime time.Time) error {
+	c.sets++
+	if maxAge, ok := core.ResponseMaxAge(ctx); ok {
+		c.maxAges = append(c.maxAges, maxAge)
+	}
+	return nil
+}
+
+func (c *maxAgeCache) InvalidateRows(ctx context.Context, refs []core.RowRef) error {
+	return nil
+}
+
+func TestCacheControl(t *testing.T) {
+	db := newTestDB(t, "cachecontroldb1")
+
+	rc := &maxAgeCache{}
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db, core.OptionSetResponseCache(rc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the field hints are combined with the hint of the operation
+	gql := `query getUsers @cacheControl(maxAge: 60, scope: "public") {
+		users @cacheControl(maxAge: 30) {
+			id
+			email @cacheControl(scope: "private")
+		}
+	}`
+	res, err := gj.GraphQL(context.Background(), gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "max-age=30, private"; res.CacheControl() != exp {
+		t.Fatalf("expected cache-control '%s', got '%s'", exp, res.CacheControl())
+	}
+	if len(rc.maxAges) != 1 || rc.maxAges[0] != 30*time.Second {
+		t.Fatalf("expected the response to be cached for 30s, got: %v", rc.maxAges)
+	}
+
+	// a max-age of 0 is not cached
+	gql = `query getProducts { products @cacheControl(maxAge: 0) { id } }`
+	if _, err := gj.GraphQL(context.Background(), gql, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if rc.sets != 1 {
+		t.Fatalf("expected the response not to be cached, got %d responses cached", rc.sets)
+	}
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCacheCompiled(t *testing.T) {
+	c, err := NewCache(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs, loaded := c.compiled("q1")
+	if loaded {
+		t.Fatal("expected a new compiled query")
+	}
+	if cs1, loaded := c.compiled("q1"); !loaded || cs1 != cs {
+		t.Fatal("expected the cached compiled query")
+	}
+
+	// the least recently used compiled query is evicted
+	c.compiled("q2")
+	c.compiled("q3")
+	if _, loaded := c.compiled("q1"); loaded {
+		t.Fatal("expected the compiled query to be evicted")
+	}
+}
+
+func TestCacheProviderTTL(t *testing.T) {
+	c, err := NewCache(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewCacheProvider(c)
+	ctx := context.Background()
+
+	if err := p.Set(ctx, "k1", []byte("v1"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Set(ctx, "k2", []byte("v2"), 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok, _ := p.Get(ctx, "k1"); ok {
+		t.Fatal("expected k1 to expire")
+	}
+	if v, ok := c.Get("k2"); !ok || string(v) != "v2" {
+		t.Fatalf("expected v2, got: %s", v)
+	}
+
+	if err := p.Delete(ctx, "k2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("k2"); ok {
+		t.Fatal("expected k2 to be deleted")
+	}
+}
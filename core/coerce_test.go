@@ -0,0 +1,83 @@
+package core_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestCoerceVariables(t *testing.T) {
+	gql := `query { features(where: { id: $id, name: $name, price: $price, active: $active }) { id } }`
+
+	tests := []struct {
+		name   string
+		coerce bool
+		vars   string
+		exp    map[string]interface{}
+	}{
+		{
+			name:   "coerce",
+			coerce: true,
+			vars:   `{"id": "1", "name": "10", "price": "10.5", "active": "true"}`,
+			exp:    map[string]interface{}{"id": int64(1), "name": "10", "price": 10.5, "active": true},
+		},
+		{
+			name:   "invalid values",
+			coerce: true,
+			vars:   `{"id": "1.5", "name": "true", "price": "1e", "active": "yes"}`,
+			exp:    map[string]interface{}{"id": "1.5", "name": "true", "price": "1e", "active": "yes"},
+		},
+		{
+			name:   "disabled",
+			coerce: false,
+			vars:   `{"id": "1", "name": "10", "price": "10.5", "active": "true"}`,
+			exp:    map[string]interface{}{"id": "1", "name": "10", "price": "10.5", "active": "true"},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, "coercedb"+string(rune('a'+i)))
+
+			_, err := db.Exec(`
+				CREATE TABLE features (
+					id INTEGER PRIMARY KEY,
+					name TEXT,
+					price REAL,
+					active BOOLEAN
+				);
+				INSERT INTO features (id, name, price, active) VALUES (1, '10', 10.5, 1);
+			`)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conf := &core.Config{
+				DBType:           "sqlite",
+				DisableAllowList: true,
+				Debug:            true,
+				CoerceVariables:  tt.coerce,
+			}
+			gj, err := core.NewGraphJin(conf, db)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := gj.GraphQL(context.Background(), gql, []byte(tt.vars), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			params := res.Params()
+			if len(params) != len(tt.exp) {
+				t.Fatalf("expected params: %v, got: %v", tt.exp, params)
+			}
+			for _, p := range params {
+				if v := tt.exp[p.Name]; p.Value != v {
+					t.Fatalf("expected %s to be %#v, got: %#v", p.Name, v, p.Value)
+				}
+			}
+		})
+	}
+}
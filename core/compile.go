@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dosco/graphjin/core/v3/internal/graph"
+	"github.com/dosco/graphjin/core/v3/internal/psql"
+)
+
+// errDialectOverride is returned when a query with a dialect override is executed
+var errDialectOverride = errors.New("dialect override is only supported by Compile")
+
+// CompiledQuery is a query compiled by Compile
+type CompiledQuery struct {
+	Query    string      `json:"query"`
+	Dialect  string      `json:"dialect"`
+	Database string      `json:"database,omitempty"`
+	Role     string      `json:"role"`
+	Params   []ParamInfo `json:"params,omitempty"`
+	// Tables are the tables the query reads and writes
+	Tables []TableAccess `json:"tables,omitempty"`
+}
+
+// Compile compiles a GraphQL query without executing it and returns the
+// compiled query. The Dialect set in the request config selects the dialect
+// used to compile the query instead of the one of the database, this is only
+// allowed when not in production mode.
+func (g *GraphJin) Compile(c context.Context,
+	query string,
+	vars json.RawMessage,
+	rc *RequestConfig,
+) (*CompiledQuery, error) {
+	gj, err := g.getEngine()
+	if err != nil {
+		return nil, err
+	}
+
+	if rc != nil && rc.Dialect != "" && gj.prod {
+		return nil, errors.New("dialect override is not allowed in production mode")
+	}
+
+	if !gj.anyDatabaseReady() {
+		return nil, fmt.Errorf("no tables found in any database; schema not initialized")
+	}
+
+	h, err := graph.FastParse(query)
+	if err != nil {
+		return nil, err
+	}
+	r := gj.newGraphqlReq(rc, h.Operation, h.Name, []byte(query), vars)
+
+	// in production the query must be in the allow list
+	if gj.prodSec {
+		item, err := gj.allowList.GetByName(h.Name, true)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, h.Name)
+		}
+		r.Set(item)
+	}
+
+	s, err := newGState(c, gj, r)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.compileQueryForRole(); err != nil {
+		return nil, err
+	}
+	if s.multiDB {
+		return nil, errors.New("queries across multiple databases cannot be compiled")
+	}
+
+	cq := &CompiledQuery{
+		Query:    s.cs.st.sql,
+		Dialect:  s.getTargetDBCtx().dbtype,
+		Database: s.database,
+		Role:     s.cs.st.role,
+		Tables:   tableAccess(s.cs.st.qc),
+	}
+	if rc != nil && rc.Dialect != "" {
+		cq.Dialect = rc.Dialect
+	}
+	for _, p := range s.cs.st.md.Params() {
+		cq.Params = append(cq.Params, ParamInfo{
+			Name:    p.Name,
+			Type:    p.Type,
+			IsArray: p.IsArray,
+		})
+	}
+	return cq, nil
+}
+
+// dialectCompiler returns a compiler for the dialect to compile queries
+// against the schema of the database. The dialect must be registered and
+// compatible with the database, document and SQL databases cannot be mixed.
+func (gj *graphjinEngine) dialectCompiler(dbCtx *dbContext, name string) (*psql.Compiler, error) {
+	if !isDialectRegistered(name) {
+		return nil, fmt.Errorf("dialect %q is not registered: registered dialects are %s",
+			name, strings.Join(SupportedDBTypes, ", "))
+	}
+	dbType := dbCtx.schema.DBType()
+	if (name == "mongodb") != (dbType == "mongodb") {
+		return nil, fmt.Errorf("dialect %q is not compatible with the %s database", name, dbType)
+	}
+	if name == dbType {
+		return dbCtx.psqlCompiler, nil
+	}
+
+	pc := psql.NewCompiler(psql.Config{
+		Vars:            gj.conf.Vars,
+		DBType:          name,
+		SecPrefix:       gj.printFormat,
+		EnableCamelcase: gj.conf.EnableCamelcase,
+		QueryComment:    gj.conf.QueryComment,
+	})
+	pc.SetSchemaInfo(dbCtx.schema.GetTables())
+	return pc, nil
+}
+
+// isDialectRegistered returns true if the dialect has a compiler
+func isDialectRegistered(name string) bool {
+	for _, t := range SupportedDBTypes {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
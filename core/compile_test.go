@@ -0,0 +1,79 @@
+package core_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestCompileWithDialect(t *testing.T) {
+	db := newTestDB(t, "compiledb")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	gql := `query { users(where: { id: $id }) { id email } }`
+
+	cq, err := gj.Compile(ctx, gql, []byte(`{"id": 1}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cq.Dialect != "sqlite" || cq.Query == "" || len(cq.Params) != 1 || cq.Params[0].Name != "id" {
+		t.Fatalf("unexpected compiled query: %+v", cq)
+	}
+
+	queries := map[string]string{}
+	for _, d := range []string{"postgres", "mysql", "mssql"} {
+		cq, err := gj.Compile(ctx, gql, nil, &core.RequestConfig{Dialect: d})
+		if err != nil {
+			t.Fatalf("%s: %v", d, err)
+		}
+		if cq.Dialect != d {
+			t.Fatalf("expected the %s dialect, got: %s", d, cq.Dialect)
+		}
+		queries[d] = cq.Query
+	}
+	if queries["postgres"] == queries["mysql"] || queries["mysql"] == queries["mssql"] {
+		t.Fatalf("expected a different query for every dialect: %v", queries)
+	}
+	if !strings.Contains(queries["mssql"], "FOR JSON") {
+		t.Fatalf("expected a mssql query: %s", queries["mssql"])
+	}
+
+	if _, err := gj.Compile(ctx, gql, nil, &core.RequestConfig{Dialect: "db2"}); err == nil ||
+		!strings.Contains(err.Error(), "not registered") {
+		t.Fatalf("expected an error for an unknown dialect, got: %v", err)
+	}
+	if _, err := gj.Compile(ctx, gql, nil, &core.RequestConfig{Dialect: "mongodb"}); err == nil ||
+		!strings.Contains(err.Error(), "not compatible") {
+		t.Fatalf("expected an error for an incompatible dialect, got: %v", err)
+	}
+
+	// the dialect override is never executed
+	_, err = gj.GraphQL(ctx, gql, []byte(`{"id": 1}`), &core.RequestConfig{Dialect: "postgres"})
+	if err == nil {
+		t.Fatal("expected an error for executing a query with a dialect override")
+	}
+}
+
+func TestCompileWithDialectInProduction(t *testing.T) {
+	db := newTestDB(t, "compiledb_prod")
+
+	conf := &core.Config{DBType: "sqlite", Production: true, DisableProdSecurity: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = gj.Compile(context.Background(), `query { users { id } }`, nil,
+		&core.RequestConfig{Dialect: "postgres"})
+	if err == nil {
+		t.Fatal("expected an error for a dialect override in production mode")
+	}
+}
@@ -0,0 +1,363 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// ComputedField defines a virtual field on a table whose value is computed
+// in Go from the other fields of the row after the query is executed
+type ComputedField struct {
+	// Table the field is added to
+	Table string
+
+	// Name of the field
+	Name string
+
+	// Columns needed to compute the value, these are fetched
+	// even when they are not selected in the query
+	Requires []string
+
+	// Function called with all the rows the field is selected for
+	Resolver FieldResolverFn
+}
+
+// FieldResolverFn computes the value of a computed field for a batch of rows.
+// It must return a result for every row and in the same order as the rows.
+// Returning an error fails the whole request, to fail a single row set the
+// Err of its result instead
+type FieldResolverFn func(ctx context.Context, req FieldResolverReq) ([]FieldResult, error)
+
+// FieldResolverReq holds the rows a computed field is resolved for
+type FieldResolverReq struct {
+	Table string
+	Field string
+
+	// Fields of each row keyed by name, including the required columns
+	Rows []map[string]json.RawMessage
+	*RequestConfig
+}
+
+// FieldResult is the computed value for a single row. When Err is set
+// the field is returned as null and the error is added to the response
+type FieldResult struct {
+	Value any
+	Err   error
+}
+
+// OptionAddComputedField adds a computed field to a table
+func OptionAddComputedField(cf ComputedField) Option {
+	return func(s *graphjinEngine) error {
+		if cf.Table == "" || cf.Name == "" {
+			return errors.New("computed field: table and name are required")
+		}
+		if cf.Resolver == nil {
+			return fmt.Errorf("computed field: %s.%s: resolver is required", cf.Table, cf.Name)
+		}
+		if s.computed == nil {
+			s.computed = make(map[string]ComputedField)
+		}
+		s.computed[(cf.Table + ":" + cf.Name)] = cf
+		return nil
+	}
+}
+
+// computedDeps returns the columns required by each computed field by table
+func (gj *graphjinEngine) computedDeps() map[string]map[string][]string {
+	if len(gj.computed) == 0 {
+		return nil
+	}
+	m := make(map[string]map[string][]string)
+
+	for _, cf := range gj.computed {
+		if _, ok := m[cf.Table]; !ok {
+			m[cf.Table] = make(map[string][]string)
+		}
+		m[cf.Table][cf.Name] = cf.Requires
+	}
+	return m
+}
+
+// jsonObj is a decoded json object that keeps the order of its keys,
+// values are either json.RawMessage, *jsonObj or []any
+type jsonObj struct {
+	keys []string
+	vals []any
+}
+
+// cfBatch holds all the rows in the response a computed field is resolved for
+type cfBatch struct {
+	cf   ComputedField
+	rows []map[string]json.RawMessage
+	objs []*jsonObj
+	name []string
+}
+
+// execComputedFields resolves the computed fields and adds them to the response
+func (s *gstate) execComputedFields(c context.Context) (err error) {
+	qc := s.cs.st.qc
+
+	root, err := decodeJSONObj(s.data)
+	if err != nil {
+		return
+	}
+
+	batches := make(map[string]*cfBatch)
+	for _, id := range qc.Roots {
+		sel := &qc.Selects[id]
+		i := root.index(sel.FieldName)
+		if i == -1 {
+			continue
+		}
+		if root.vals[i], err = s.collectComputed(qc, sel, root.vals[i], batches); err != nil {
+			return
+		}
+	}
+
+	for _, b := range batches {
+		if err = s.resolveComputed(c, b); err != nil {
+			return
+		}
+	}
+
+	var ob bytes.Buffer
+	if err = encodeJSONValue(&ob, root); err != nil {
+		return
+	}
+	s.data = ob.Bytes()
+	return
+}
+
+// collectComputed decodes the rows of the select (and its children) that have
+// computed fields and adds them to the batches to be resolved
+func (s *gstate) collectComputed(
+	qc *qcode.QCode, sel *qcode.Select, v any, batches map[string]*cfBatch,
+) (any, error) {
+	if !hasComputed(qc, sel) {
+		return v, nil
+	}
+	raw, ok := v.(json.RawMessage)
+	if !ok {
+		return v, nil
+	}
+	raw = bytes.TrimSpace(raw)
+
+	if len(raw) == 0 || raw[0] != '[' {
+		return s.collectComputedRow(qc, sel, raw, batches)
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+	rows := make([]any, len(list))
+	for i, item := range list {
+		row, err := s.collectComputedRow(qc, sel, item, batches)
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+func (s *gstate) collectComputedRow(
+	qc *qcode.QCode, sel *qcode.Select, raw json.RawMessage, batches map[string]*cfBatch,
+) (any, error) {
+	if len(raw) == 0 || raw[0] != '{' {
+		return raw, nil
+	}
+	obj, err := decodeJSONObj(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sel.Computed) != 0 {
+		row := make(map[string]json.RawMessage, len(obj.keys))
+		for i, k := range obj.keys {
+			if v, ok := obj.vals[i].(json.RawMessage); ok {
+				row[strings.TrimPrefix(k, qcode.ComputedDepPrefix)] = v
+			}
+		}
+
+		for _, f := range sel.Computed {
+			key := sel.Table + ":" + f.Name
+			b, ok := batches[key]
+			if !ok {
+				b = &cfBatch{cf: s.gj.computed[key]}
+				batches[key] = b
+			}
+			b.rows = append(b.rows, row)
+			b.objs = append(b.objs, obj)
+			b.name = append(b.name, f.FieldName)
+			obj.insert(f.Pos, f.FieldName, json.RawMessage("null"))
+		}
+	}
+
+	for _, cid := range sel.Children {
+		csel := &qc.Selects[cid]
+		i := obj.index(csel.FieldName)
+		if i == -1 {
+			continue
+		}
+		if obj.vals[i], err = s.collectComputed(qc, csel, obj.vals[i], batches); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+// resolveComputed calls the resolver for a batch of rows and sets the computed values
+func (s *gstate) resolveComputed(c context.Context, b *cfBatch) error {
+	cf := b.cf
+	if cf.Resolver == nil {
+		return fmt.Errorf("no resolver found for computed field: %s.%s", cf.Table, cf.Name)
+	}
+
+	c1, span := s.gj.spanStart(c, "Execute Computed Field")
+	res, err := cf.Resolver(c1, FieldResolverReq{
+		Table:         cf.Table,
+		Field:         cf.Name,
+		Rows:          b.rows,
+		RequestConfig: s.r.requestconfig,
+	})
+	if err != nil {
+		span.Error(err)
+	}
+	span.End()
+
+	if err != nil {
+		return fmt.Errorf("%s.%s: %w", cf.Table, cf.Name, err)
+	}
+	if len(res) != len(b.rows) {
+		return fmt.Errorf("%s.%s: resolver returned %d results for %d rows",
+			cf.Table, cf.Name, len(res), len(b.rows))
+	}
+
+	for i, r := range res {
+		err := r.Err
+		if err == nil {
+			var v json.RawMessage
+			if v, err = json.Marshal(r.Value); err == nil {
+				b.objs[i].set(b.name[i], v)
+				continue
+			}
+		}
+		s.ferrs = append(s.ferrs, fmt.Errorf("%s.%s: row %d: %w", cf.Table, cf.Name, i, err))
+	}
+	return nil
+}
+
+// hasComputed returns true if the select or any of its children has computed fields
+func hasComputed(qc *qcode.QCode, sel *qcode.Select) bool {
+	if len(sel.Computed) != 0 {
+		return true
+	}
+	for _, cid := range sel.Children {
+		if hasComputed(qc, &qc.Selects[cid]) {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeJSONObj(b []byte) (*jsonObj, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+
+	if t, err := dec.Token(); err != nil {
+		return nil, err
+	} else if d, ok := t.(json.Delim); !ok || d != '{' {
+		return nil, errors.New("expecting a json object")
+	}
+
+	obj := &jsonObj{}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		obj.keys = append(obj.keys, t.(string))
+		obj.vals = append(obj.vals, v)
+	}
+	return obj, nil
+}
+
+func (o *jsonObj) index(key string) int {
+	for i, k := range o.keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func (o *jsonObj) set(key string, v any) {
+	if i := o.index(key); i != -1 {
+		o.vals[i] = v
+	}
+}
+
+func (o *jsonObj) insert(pos int, key string, v any) {
+	if pos < 0 || pos > len(o.keys) {
+		pos = len(o.keys)
+	}
+	o.keys = append(o.keys[:pos], append([]string{key}, o.keys[pos:]...)...)
+	o.vals = append(o.vals[:pos], append([]any{v}, o.vals[pos:]...)...)
+}
+
+// encodeJSONValue writes the value dropping the hidden computed field dependencies
+func encodeJSONValue(w *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case json.RawMessage:
+		w.Write(v)
+
+	case *jsonObj:
+		w.WriteByte('{')
+		n := 0
+		for i, k := range v.keys {
+			if strings.HasPrefix(k, qcode.ComputedDepPrefix) {
+				continue
+			}
+			if n != 0 {
+				w.WriteByte(',')
+			}
+			kb, err := json.Marshal(k)
+			if err != nil {
+				return err
+			}
+			w.Write(kb)
+			w.WriteByte(':')
+			if err := encodeJSONValue(w, v.vals[i]); err != nil {
+				return err
+			}
+			n++
+		}
+		w.WriteByte('}')
+
+	case []any:
+		w.WriteByte('[')
+		for i, item := range v {
+			if i != 0 {
+				w.WriteByte(',')
+			}
+			if err := encodeJSONValue(w, item); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+
+	default:
+		return fmt.Errorf("unexpected json value: %T", v)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestComputedField(t *testing.T) {
+	db := newTestDB(t, "computeddb1")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+
+	var calls int
+	gj, err := core.NewGraphJin(conf, db,
+		core.OptionAddComputedField(core.ComputedField{
+			Table:    "products",
+			Name:     "label",
+			Requires: []string{"name", "price"},
+			Resolver: func(c context.Context, req core.FieldResolverReq) ([]core.FieldResult, error) {
+				calls++
+				res := make([]core.FieldResult, len(req.Rows))
+				for i, row := range req.Rows {
+					var name string
+					var price float64
+					if err := json.Unmarshal(row["name"], &name); err != nil {
+						return nil, err
+					}
+					if err := json.Unmarshal(row["price"], &price); err != nil {
+						return nil, err
+					}
+					if price > 20 {
+						res[i].Err = errors.New("too expensive")
+						continue
+					}
+					res[i].Value = strings.ToUpper(name)
+				}
+				return res, nil
+			},
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query { users(where: { id: 1 }) { id products(order_by: { id: asc }) { id label } } }`
+
+	res, err := gj.GraphQL(context.Background(), gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"users":[{"id":1,"products":[{"id":1,"label":"PRODUCT ONE"},{"id":2,"label":null}]}]}`
+	if got := string(res.Data); got != exp {
+		t.Fatalf("expected: %s, got: %s", exp, got)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected a single batched resolver call, got: %d", calls)
+	}
+
+	if len(res.Errors) != 1 || !strings.Contains(res.Errors[0].Message, "too expensive") {
+		t.Fatalf("expected a per-row error, got: %v", res.Errors)
+	}
+}
+
+func TestComputedFieldResolverError(t *testing.T) {
+	db := newTestDB(t, "computeddb2")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+
+	gj, err := core.NewGraphJin(conf, db,
+		core.OptionAddComputedField(core.ComputedField{
+			Table: "products",
+			Name:  "label",
+			Resolver: func(c context.Context, req core.FieldResolverReq) ([]core.FieldResult, error) {
+				return nil, errors.New("resolver failed")
+			},
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = gj.GraphQL(context.Background(), `query { products { id label } }`, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "resolver failed") {
+		t.Fatalf("expected the resolver error, got: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"sort"
@@ -56,6 +57,38 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	if c.AllowListBypassSecret != "" && len(c.AllowListBypassSecret) < minBypassSecretLen {
+		return fmt.Errorf("allow_list_bypass_secret: must be at least %d characters", minBypassSecretLen)
+	}
+
+	if err := validateMaxRowsMode(c.MaxResponseRowsMode); err != nil {
+		return err
+	}
+
+	if err := validateUnknownFields(c.UnknownFields); err != nil {
+		return err
+	}
+	for ns, v := range c.NamespaceUnknownFields {
+		if err := validateUnknownFields(v); err != nil {
+			return fmt.Errorf("namespace '%s': %w", ns, err)
+		}
+	}
+
+	if err := validateEmptyMutations(c.EmptyMutations); err != nil {
+		return err
+	}
+
+	switch c.OutputCase {
+	case "", "camel", "snake":
+	default:
+		return fmt.Errorf("output_case: invalid value '%s' (expected camel or snake)", c.OutputCase)
+	}
+	for _, r := range c.Roles {
+		if err := validateMaxRowsMode(r.MaxResponseRowsMode); err != nil {
+			return fmt.Errorf("role '%s': %w", r.Name, err)
+		}
+	}
+
 	// Validate multi-database types
 	for name, dbConf := range c.Databases {
 		if err := ValidateMultiDBType(dbConf.Type); err != nil {
@@ -152,6 +185,23 @@ type Config struct {
 	// Forces the database session variable 'user.id' to be set to the user id
 	SetUserID bool `mapstructure:"set_user_id" json:"set_user_id" yaml:"set_user_id" jsonschema:"title=Set User ID,default=false"`
 
+	// Number of times a mutation is retried when it fails with a serialization
+	// failure or a deadlock. Only the database statement is retried, mutations
+	// run in a transaction passed to GraphQLTx are never retried. Mutations
+	// run as several statements on MySQL or SQLite run in a transaction
+	// when retries are enabled
+	MutationRetries int `mapstructure:"mutation_retries" json:"mutation_retries" yaml:"mutation_retries" jsonschema:"title=Mutation Retries,default=0"`
+
+	// Delay before the first mutation retry, it doubles with every retry
+	MutationRetryDelay time.Duration `mapstructure:"mutation_retry_delay" json:"mutation_retry_delay" yaml:"mutation_retry_delay" jsonschema:"title=Mutation Retry Delay,default=50ms"`
+
+	// Number of times a query is retried on a new connection when the
+	// connection to the database is lost. Mutations are never retried this way
+	QueryRetries int `mapstructure:"query_retries" json:"query_retries" yaml:"query_retries" jsonschema:"title=Query Retries,default=0"`
+
+	// Delay before the first query retry, it doubles with every retry
+	QueryRetryDelay time.Duration `mapstructure:"query_retry_delay" json:"query_retry_delay" yaml:"query_retry_delay" jsonschema:"title=Query Retry Delay,default=50ms"`
+
 	// This ensures that for anonymous users (role 'anon') all tables are blocked
 	// from queries and mutations. To open access to tables for anonymous users
 	// they have to be added to the 'anon' role config
@@ -196,6 +246,60 @@ type Config struct {
 	// Log SQL Query variable values
 	LogVars bool `mapstructure:"log_vars" json:"log_vars" yaml:"log_vars" jsonschema:"title=Log Variables,default=false"`
 
+	// Redact the values of the query parameters returned with the result in
+	// debug mode, only their names and types are returned
+	RedactDebugParams bool `mapstructure:"redact_debug_params" json:"redact_debug_params" yaml:"redact_debug_params" jsonschema:"title=Redact Debug Parameters,default=false"`
+
+	// Variables whose values are always redacted in the query parameters
+	// returned with the result in debug mode
+	SensitiveVars []string `mapstructure:"sensitive_vars" json:"sensitive_vars" yaml:"sensitive_vars" jsonschema:"title=Sensitive Variables,example=password"`
+
+	// Convert string values of variables bound to integer, float and boolean
+	// columns to numbers and booleans, like "5" to 5 and "true" to true.
+	// Strings that are not valid values of the column type are left as is
+	CoerceVariables bool `mapstructure:"coerce_variables" json:"coerce_variables" yaml:"coerce_variables" jsonschema:"title=Coerce Variables,default=false"`
+
+	// Attributes added to the span of a query, all of them are added when not
+	// set. Options: name, operation, namespace, role, database, dialect,
+	// cache_hit and roots
+	TraceAttributes []string `mapstructure:"trace_attributes" json:"trace_attributes" yaml:"trace_attributes" jsonschema:"title=Trace Attributes,example=name,example=operation,example=role"`
+
+	// Add the namespace and a hash of the query to the sqlcommenter comment
+	// before the generated SQL, on MongoDB the comment is added to the
+	// aggregate command. Use it to attribute the database load to operations
+	QueryComment bool `mapstructure:"query_comment" json:"query_comment" yaml:"query_comment" jsonschema:"title=Query Comment,default=false"`
+
+	// On MongoDB _id is added as the last sort key of an ordering that is
+	// not unique so that pages don't skip or repeat documents with equal
+	// sort keys. Set this to sort only on the columns that are asked for
+	DisableSortTieBreak bool `mapstructure:"disable_sort_tie_break" json:"disable_sort_tie_break" yaml:"disable_sort_tie_break" jsonschema:"title=Disable Sort Tie Break,default=false"`
+
+	// Replace the errors of the database with a DATABASE_ERROR and a
+	// correlation id in production, the error is logged with the same id
+	RedactErrors bool `mapstructure:"redact_errors" json:"redact_errors" yaml:"redact_errors" jsonschema:"title=Redact Errors,default=false"`
+
+	// Maximum size in bytes of the variables of a request (0 disables).
+	// Larger variables are rejected with a VARIABLES_TOO_LARGE error before
+	// they are parsed
+	MaxVariablesSize int `mapstructure:"max_variables_size" json:"max_variables_size" yaml:"max_variables_size" jsonschema:"title=Maximum Variables Size,example=1048576"`
+
+	// Maximum nesting depth of the objects and arrays in the variables of a
+	// request (0 disables)
+	MaxVariablesDepth int `mapstructure:"max_variables_depth" json:"max_variables_depth" yaml:"max_variables_depth" jsonschema:"title=Maximum Variables Depth,example=10"`
+
+	// Maximum number of objects in an array variable of a mutation, like the
+	// rows of a bulk insert (0 disables). It is checked separately from the
+	// size since legitimate bulk inserts can be large
+	MaxBulkInsert int `mapstructure:"max_bulk_insert" json:"max_bulk_insert" yaml:"max_bulk_insert" jsonschema:"title=Maximum Bulk Insert Rows,example=1000"`
+
+	// Number of rows inserted per batch by BulkInsert (defaults to 500). It is
+	// capped by max_bulk_insert when that is set
+	BulkInsertBatchSize int `mapstructure:"bulk_insert_batch_size" json:"bulk_insert_batch_size" yaml:"bulk_insert_batch_size" jsonschema:"title=Bulk Insert Batch Size,default=500"`
+
+	// How long the persisted queries (APQ) are kept in the cache, they do
+	// not expire when not set
+	APQCacheTTL time.Duration `mapstructure:"apq_cache_ttl" json:"apq_cache_ttl" yaml:"apq_cache_ttl" jsonschema:"title=Persisted Query Cache TTL,example=24h"`
+
 	// Database polling duration (in seconds) used by subscriptions to
 	// query for updates.
 	SubsPollDuration time.Duration `mapstructure:"subs_poll_duration" json:"subs_poll_duration" yaml:"subs_poll_duration" jsonschema:"title=Subscription Polling Duration,default=5s"`
@@ -217,16 +321,74 @@ type Config struct {
 	// Enable automatic coversion of camel case in GraphQL to snake case in SQL
 	EnableCamelcase bool `mapstructure:"enable_camelcase" json:"enable_camelcase" yaml:"enable_camelcase" jsonschema:"title=Enable Camel Case,default=false"`
 
+	// How field names in GraphQL are mapped to database identifiers: 'preserve'
+	// uses them as is, 'lower' lowercases them and 'snake' converts camel case
+	// to snake case. Responses always use the field names from the query.
+	// When not set 'snake' is used if camel case is enabled
+	IdentifierCase string `mapstructure:"identifier_case" json:"identifier_case" yaml:"identifier_case" jsonschema:"title=Identifier Case,enum=preserve,enum=lower,enum=snake"`
+
+	// Case of the keys of the response data: 'camel' or 'snake', nested keys
+	// included. Fields with an alias keep it and the contents of json columns
+	// are left as they are. With 'camel' the field names of queries and
+	// mutations are mapped to snake case columns unless an identifier case is
+	// set. When not set the keys are the field names from the query
+	OutputCase string `mapstructure:"output_case" json:"output_case" yaml:"output_case" jsonschema:"title=Output Case,enum=camel,enum=snake"`
+
+	// Leave the fields whose value is null out of the response data, in
+	// nested objects as well, whatever the database. Null elements of lists
+	// are kept. When not set the null fields are returned as the database
+	// renders them
+	OmitNullFields bool `mapstructure:"omit_null_fields" json:"omit_null_fields" yaml:"omit_null_fields" jsonschema:"title=Omit Null Fields,default=false"`
+
 	// When enabled GraphJin runs with production level security defaults.
 	// For example allow lists are enforced.
 	Production bool `jsonschema:"title=Production Mode,default=false"`
 
+	// Read-only mode rejects all mutations and subscriptions with a READ_ONLY
+	// error before they are compiled, regardless of the roles. Queries and
+	// introspection still work, use it for instances backed by a replica
+	ReadOnly bool `mapstructure:"read_only" json:"read_only" yaml:"read_only" jsonschema:"title=Read Only,default=false"`
+
+	// Maximum number of relationships a query can traverse across all its
+	// selectors (0 disables). A many-to-many relationship also counts its
+	// join table
+	MaxRelationshipsPerQuery int `mapstructure:"max_relationships_per_query" json:"max_relationships_per_query" yaml:"max_relationships_per_query" jsonschema:"title=Maximum Relationships per Query,example=20"`
+
+	// Maximum number of rows a query can return across all its selectors
+	// including nested ones (0 disables). The limits of the selectors are
+	// lowered so that no more rows than needed are fetched
+	MaxResponseRows int `mapstructure:"max_response_rows" json:"max_response_rows" yaml:"max_response_rows" jsonschema:"title=Maximum Rows per Response"`
+
+	// What happens when a query can return more than the maximum rows,
+	// 'error' fails the query when the response has more rows and
+	// 'truncate' lowers the limits until the query fits. Defaults to 'error'
+	MaxResponseRowsMode string `mapstructure:"max_response_rows_mode" json:"max_response_rows_mode" yaml:"max_response_rows_mode" jsonschema:"title=Maximum Rows Mode,enum=error,enum=truncate"`
+
+	// What happens when a query selects a field that is not in the schema,
+	// 'strict' fails the query and 'drop' leaves the field out and reports
+	// it in the errors of the result. Defaults to 'strict'
+	UnknownFields string `mapstructure:"unknown_fields" json:"unknown_fields" yaml:"unknown_fields" jsonschema:"title=Unknown Fields,enum=strict,enum=drop"`
+
+	// Overrides unknown_fields for the queries of a namespace
+	NamespaceUnknownFields map[string]string `mapstructure:"namespace_unknown_fields" json:"namespace_unknown_fields" yaml:"namespace_unknown_fields" jsonschema:"title=Unknown Fields by Namespace"`
+
+	// What happens to an update with no columns to set, 'noop' returns the
+	// matched rows unchanged and 'error' fails the mutation. By default the
+	// rows are updated without a change
+	EmptyMutations string `mapstructure:"empty_mutations" json:"empty_mutations" yaml:"empty_mutations" jsonschema:"title=Empty Mutations,enum=noop,enum=error"`
+
 	// Duration for polling the database to detect schema changes
 	DBSchemaPollDuration time.Duration `mapstructure:"db_schema_poll_duration" json:"db_schema_poll_duration" yaml:"db_schema_poll_duration" jsonschema:"title=Schema Change Detection Polling Duration,default=10s"`
 
 	// When set to true it disables production security features like enforcing the allow list
 	DisableProdSecurity bool `mapstructure:"disable_production_security" json:"disable_production_security" yaml:"disable_production_security" jsonschema:"title=Disable Production Security"`
 
+	// Shared secret that trusted internal callers can set as the AllowListBypass
+	// token in the RequestConfig to run queries that are not in the allow list.
+	// Roles and filters still apply and every bypassed query is logged.
+	// Must be at least 16 characters, when not set the allow list cannot be bypassed
+	AllowListBypassSecret string `mapstructure:"allow_list_bypass_secret" json:"allow_list_bypass_secret" yaml:"allow_list_bypass_secret" jsonschema:"title=Allow List Bypass Secret"`
+
 	// The filesystem to use for this instance of GraphJin
 	FS interface{} `mapstructure:"-" jsonschema:"-" json:"-"`
 
@@ -272,6 +434,9 @@ type DatabaseConfig struct {
 	// Maximum number of idle connections
 	MaxIdleConns int `mapstructure:"max_idle_conns" json:"max_idle_conns" yaml:"max_idle_conns" jsonschema:"title=Max Idle Connections"`
 
+	// Maximum amount of time a connection may be reused
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime" json:"conn_max_lifetime" yaml:"conn_max_lifetime" jsonschema:"title=Connection Max Lifetime"`
+
 	// Schema name to use (for databases that support schemas)
 	Schema string `mapstructure:"schema" json:"schema" yaml:"schema" jsonschema:"title=Schema"`
 
@@ -297,11 +462,46 @@ type DatabaseConfig struct {
 	// MSSQL-specific: trust server certificate without validation
 	TrustServerCertificate *bool `mapstructure:"trust_server_certificate" json:"trust_server_certificate,omitempty" yaml:"trust_server_certificate,omitempty" jsonschema:"title=MSSQL Trust Server Certificate"`
 
+	// Number of compiled queries whose prepared statements are cached and
+	// reused across executions, 0 disables the cache
+	PreparedStatements int `mapstructure:"prepared_statements" json:"prepared_statements" yaml:"prepared_statements" jsonschema:"title=Prepared Statements Cache Size"`
+
 	// Read-only mode — blocks all mutations and DDL against this database.
 	// Once set in config, cannot be changed at runtime via MCP tools.
 	ReadOnly bool `mapstructure:"read_only" json:"read_only" yaml:"read_only" jsonschema:"title=Read Only"`
 }
 
+// ApplyPool sets the connection pool settings of the database on a pool
+// opened by GraphJin, pools passed in by the caller are left untouched.
+// MaxOpenConns, MaxIdleConns and ConnMaxLifetime take precedence over
+// max_connections, pool_size and max_connection_life_time, settings that
+// are not set keep the defaults of database/sql.
+func (dc DatabaseConfig) ApplyPool(db *sql.DB) {
+	maxOpen, maxIdle, lifetime := dc.MaxOpenConns, dc.MaxIdleConns, dc.ConnMaxLifetime
+	if maxOpen == 0 {
+		maxOpen = dc.MaxConnections
+	}
+	if maxIdle == 0 {
+		maxIdle = dc.PoolSize
+	}
+	if lifetime == 0 {
+		lifetime = dc.MaxConnLifeTime
+	}
+
+	if maxOpen > 0 {
+		db.SetMaxOpenConns(maxOpen)
+	}
+	if maxIdle > 0 {
+		db.SetMaxIdleConns(maxIdle)
+	}
+	if lifetime > 0 {
+		db.SetConnMaxLifetime(lifetime)
+	}
+	if dc.MaxConnIdleTime > 0 {
+		db.SetConnMaxIdleTime(dc.MaxConnIdleTime)
+	}
+}
+
 // Configuration for a database table
 type Table struct {
 	Name   string
@@ -315,6 +515,70 @@ type Table struct {
 	Columns   []Column
 	// Permitted order by options
 	OrderBy map[string][]string `mapstructure:"order_by" json:"order_by" yaml:"order_by" jsonschema:"title=Order By Options,example=created_at desc"`
+
+	// Version column used for optimistic concurrency. Updates must include the
+	// version they expect the row to have, the column is then incremented and
+	// a CONFLICT error is returned if the row was changed since it was read
+	VersionColumn string `mapstructure:"version_column" json:"version_column" yaml:"version_column" jsonschema:"title=Version Column,example=version"`
+
+	// Soft-delete column set to the current time by deletes instead of the row
+	// being deleted, rows with the column set are left out of all selects unless
+	// the selector has the argument with_deleted: true
+	SoftDeleteColumn string `mapstructure:"soft_delete_column" json:"soft_delete_column" yaml:"soft_delete_column" jsonschema:"title=Soft-Delete Column,example=deleted_at"`
+
+	// Collation locale used to sort and match strings ignoring case (MongoDB only).
+	// Indexes are only used when they are created with the same collation
+	Collation string `mapstructure:"collation" json:"collation" yaml:"collation" jsonschema:"title=Collation,example=en"`
+
+	// Ids of the table are ObjectIds (MongoDB only). String ids used in inserts,
+	// filters and connects are converted to ObjectIds, including the values of
+	// the columns that are foreign keys to the table
+	ObjectID bool `mapstructure:"object_id" json:"object_id" yaml:"object_id" jsonschema:"title=ObjectId Keys"`
+
+	// Rows of a static lookup table (type: static) defined in the config instead
+	// of a collection, it can only be used in relationships (MongoDB 5.1+ only)
+	Rows []map[string]interface{} `mapstructure:"rows" json:"rows" yaml:"rows" jsonschema:"title=Static Rows"`
+
+	// Default document returned instead of null when a singular relationship
+	// to the table finds no row, the keys are columns of the table and only
+	// the selected ones are returned (MongoDB only)
+	Default map[string]interface{} `mapstructure:"default" json:"default" yaml:"default" jsonschema:"title=Default Document"`
+
+	// Row limit of selects on the table that have no limit, it overrides the
+	// default_limit and is applied to nested selects of the table too
+	DefaultLimit int `mapstructure:"default_limit" json:"default_limit" yaml:"default_limit" jsonschema:"title=Default Row Limit"`
+
+	// Largest row limit allowed on selects of the table, larger limits are
+	// lowered to it unless max_limit_error is set and they fail instead
+	MaxLimit      int  `mapstructure:"max_limit" json:"max_limit" yaml:"max_limit" jsonschema:"title=Maximum Row Limit"`
+	MaxLimitError bool `mapstructure:"max_limit_error" json:"max_limit_error" yaml:"max_limit_error" jsonschema:"title=Fail Above Maximum Row Limit,default=false"`
+
+	// Updates of other collections run after the mutations of a document of
+	// the table, for example to keep a denormalized counter in sync. They run
+	// after the mutation and are not part of a transaction (MongoDB only)
+	SideEffects []SideEffect `mapstructure:"side_effects" json:"side_effects" yaml:"side_effects" jsonschema:"title=Side Effects"`
+}
+
+// Configuration for an update of another collection run after a mutation
+type SideEffect struct {
+	// Mutations it runs after, all of them when empty
+	On []string `jsonschema:"enum=insert,enum=update,enum=delete"`
+
+	// Collection updated
+	Collection string `jsonschema:"example=categories"`
+
+	// Column of the mutated document whose value selects the documents updated
+	Column string `jsonschema:"example=category_id"`
+
+	// Field of the updated documents matched with the value of the column
+	Key string `jsonschema:"default=_id"`
+
+	// Update document or pipeline run on the matching documents
+	Update interface{}
+
+	// Aggregation pipeline run on the documents of the table with the value of
+	// the column, its output is merged into the document with that key
+	Pipeline []interface{} `jsonschema:"title=Merge Pipeline"`
 }
 
 // Configuration for a database table column
@@ -325,6 +589,54 @@ type Column struct {
 	Array      bool
 	FullText   bool   `mapstructure:"full_text" json:"full_text" yaml:"full_text" jsonschema:"title=Full Text Search"`
 	ForeignKey string `mapstructure:"related_to" json:"related_to" yaml:"related_to" jsonschema:"title=Related To,example=other_table.id_column,example=users.id"`
+
+	// Side of the relationship that holds the array of ids: 'column' when it's
+	// this column, 'related' when it's the related column or 'none' for a join
+	// of two scalars. It overrides the side inferred from the columns (MongoDB only)
+	ArraySide string `mapstructure:"array_side" json:"array_side" yaml:"array_side" jsonschema:"title=Array Side,enum=column,enum=related,enum=none"`
+
+	// Collation locale used to ignore case when the column is sorted or
+	// filtered on (MongoDB only)
+	Collation string `mapstructure:"collation" json:"collation" yaml:"collation" jsonschema:"title=Collation,example=en"`
+
+	// Compare makes the column a boolean computed by comparing another
+	// column with a value, it is not stored in the database (MongoDB only)
+	Compare *Compare `mapstructure:"compare" json:"compare" yaml:"compare" jsonschema:"title=Compare"`
+
+	// Default value of the column set on insert when the field is omitted,
+	// an explicit null is kept (MongoDB only)
+	Default interface{} `jsonschema:"title=Default,example=active"`
+
+	// Labels maps the values stored in the column to the labels returned
+	// instead of them by the @label directive, keys that are numbers match
+	// numeric values (MongoDB only)
+	Labels map[string]string `jsonschema:"title=Labels"`
+
+	// Label returned by the @label directive for values without a label,
+	// null when not set
+	LabelDefault string `mapstructure:"label_default" json:"label_default" yaml:"label_default" jsonschema:"title=Default Label,example=unknown"`
+
+	// Deprecated marks the field of the column deprecated in the
+	// introspection schema, it can still be queried
+	Deprecated bool `jsonschema:"title=Deprecated,default=false"`
+
+	// Reason the field is deprecated, reported by introspection
+	DeprecationReason string `mapstructure:"deprecation_reason" json:"deprecation_reason" yaml:"deprecation_reason" jsonschema:"title=Deprecation Reason,example=Use full_name instead"`
+}
+
+// Configuration for a boolean column computed from a comparison
+type Compare struct {
+	// Column compared
+	Column string `jsonschema:"example=price"`
+
+	// Comparison operator
+	Op string `jsonschema:"enum=eq,enum=neq,enum=gt,enum=gte,enum=lt,enum=lte"`
+
+	// Value the column is compared with
+	Value interface{} `jsonschema:"example=100"`
+
+	// Column the column is compared with instead of a value
+	ValueColumn string `mapstructure:"value_column" json:"value_column" yaml:"value_column" jsonschema:"title=Value Column,example=cost"`
 }
 
 // Configuration for a database function
@@ -340,7 +652,29 @@ type Role struct {
 	Comment string
 	Match   string      `jsonschema:"title=Related To,example=other_table.id_column,example=users.id"`
 	Tables  []RoleTable `jsonschema:"title=Table Configuration for Role"`
-	tm      map[string]*RoleTable
+
+	// Maximum total query cost this role can spend within the cost window.
+	// Requests that exceed it fail with a RATE_LIMITED error (0 disables).
+	// Subscriptions are charged on subscribe and on every poll
+	CostBudget int `mapstructure:"cost_budget" json:"cost_budget" yaml:"cost_budget" jsonschema:"title=Query Cost Budget"`
+
+	// Duration of the window the cost budget applies to
+	CostWindow time.Duration `mapstructure:"cost_window" json:"cost_window" yaml:"cost_window" jsonschema:"title=Query Cost Window,default=1m"`
+
+	// Overrides the maximum number of rows per response for this role
+	MaxResponseRows int `mapstructure:"max_response_rows" json:"max_response_rows" yaml:"max_response_rows" jsonschema:"title=Maximum Rows per Response"`
+
+	// Overrides what happens when a response for this role exceeds the maximum rows
+	MaxResponseRowsMode string `mapstructure:"max_response_rows_mode" json:"max_response_rows_mode" yaml:"max_response_rows_mode" jsonschema:"title=Maximum Rows Mode,enum=error,enum=truncate"`
+
+	// Overrides the maximum number of relationships per query for this role
+	MaxRelationshipsPerQuery int `mapstructure:"max_relationships_per_query" json:"max_relationships_per_query" yaml:"max_relationships_per_query" jsonschema:"title=Maximum Relationships per Query"`
+
+	// The only tables this role can query or mutate, at the root or nested.
+	// Other tables fail with a FORBIDDEN error. All tables when not set
+	AllowedTables []string `mapstructure:"allowed_tables" json:"allowed_tables" yaml:"allowed_tables" jsonschema:"title=Allowed Tables,example=products,example=public.users"`
+
+	tm map[string]*RoleTable
 }
 
 // Table configuration for a specific role (user role)
@@ -360,10 +694,17 @@ type RoleTable struct {
 type Query struct {
 	Limit int
 	// Use filters to enforce table wide things like { disabled: false } where you never want disabled users to be shown.
-	Filters          []string
-	Columns          []string
+	Filters []string
+	Columns []string
+	// Use masks to return a redacted value for a column instead of the raw value.
+	// The key is the column name and the value one of 'redact', 'redact:<text>',
+	// 'first:<n>' or 'last:<n>' eg. { card_number: "last:4" }
+	Masks            map[string]string
 	DisableFunctions bool `mapstructure:"disable_functions" json:"disable_functions" yaml:"disable_functions"`
 	Block            bool
+	// Collections the results of the table can be cached in with
+	// @materialize (MongoDB), other collections are refused
+	Materialize []string
 }
 
 // Table configuration for inserting into a table with a role
@@ -380,6 +721,10 @@ type Update struct {
 	Columns []string
 	Presets map[string]string
 	Block   bool
+
+	// Allows the role to update all the rows of the table with the
+	// 'all: true' argument instead of a where clause
+	AllowAll bool `mapstructure:"allow_all" json:"allow_all" yaml:"allow_all" jsonschema:"title=Allow All Rows"`
 }
 
 // Table configuration for creating/updating (upsert) a table with a role
@@ -395,6 +740,10 @@ type Delete struct {
 	Filters []string
 	Columns []string
 	Block   bool
+
+	// Allows the role to delete all the rows of the table with the
+	// 'all: true' argument instead of a where clause
+	AllowAll bool `mapstructure:"allow_all" json:"allow_all" yaml:"allow_all" jsonschema:"title=Allow All Rows"`
 }
 
 // Resolver interface is used to create custom resolvers
@@ -469,8 +818,11 @@ type ResolverConfig struct {
 	Schema    string
 	Table     string
 	Column    string
-	StripPath string        `mapstructure:"strip_path" json:"strip_path" yaml:"strip_path"`
-	Props     ResolverProps `mapstructure:",remain"`
+	StripPath string `mapstructure:"strip_path" json:"strip_path" yaml:"strip_path"`
+	// Fatal fails the whole query when the resolver fails, by default the
+	// field is returned as null with an error for its path
+	Fatal bool          `mapstructure:"fatal" json:"fatal" yaml:"fatal"`
+	Props ResolverProps `mapstructure:",remain"`
 }
 
 type ResolverReq struct {
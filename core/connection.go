@@ -0,0 +1,232 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// pageInfo is the page info of a root selector with the @connection directive
+type pageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
+
+// jsonField is a field of a json object, the fields are kept in order
+type jsonField struct {
+	key string
+	val json.RawMessage
+}
+
+// connectionPages trims the extra row fetched for the root selectors with
+// the @connection directive and adds their page info. The cursors of the
+// first and last row are built from the hidden fields with the values of
+// the columns they are ordered by, they are encrypted like the other cursors.
+// With MongoDB the driver does this.
+func (s *gstate) connectionPages() error {
+	qc := s.qcode()
+	if qc == nil || len(s.data) == 0 || s.getTargetDBCtx().dbtype == "mongodb" {
+		return nil
+	}
+
+	var conns []*qcode.Select
+	for _, id := range qc.Roots {
+		if sel := &qc.Selects[id]; sel.Connection {
+			conns = append(conns, sel)
+		}
+	}
+	if len(conns) == 0 {
+		return nil
+	}
+
+	fields, err := jsonObjectFields(s.data)
+	if err != nil {
+		return fmt.Errorf("@connection: %w", err)
+	}
+
+	for _, sel := range conns {
+		i := jsonFieldIndex(fields, sel.FieldName)
+		if i == -1 {
+			continue
+		}
+		var rows []json.RawMessage
+		if err := json.Unmarshal(fields[i].val, &rows); err != nil || rows == nil {
+			continue
+		}
+
+		pi := pageInfo{HasPreviousPage: s.hasCursor(sel)}
+		if len(rows) > int(sel.PageSize) {
+			rows = rows[:sel.PageSize]
+			pi.HasNextPage = true
+		}
+
+		var vals [][]json.RawMessage
+		for j, row := range rows {
+			if rows[j], vals, err = s.splitConnectionRow(row, vals); err != nil {
+				return fmt.Errorf("@connection: %w", err)
+			}
+		}
+		if len(vals) != 0 {
+			start := s.connectionCursor(sel, vals[0])
+			end := s.connectionCursor(sel, vals[len(vals)-1])
+			pi.StartCursor, pi.EndCursor = &start, &end
+		}
+
+		fields[i].val = marshalJSONArray(rows)
+
+		b, err := json.Marshal(pi)
+		if err != nil {
+			return err
+		}
+		fields = setJSONField(fields, sel.FieldName+"_pageInfo", b)
+		if pi.EndCursor != nil {
+			fields = setJSONField(fields, sel.FieldName+"_cursor", jsonString(*pi.EndCursor))
+		}
+	}
+
+	s.data = marshalJSONFields(fields)
+	return nil
+}
+
+// splitConnectionRow removes the hidden cursor fields from the row and
+// appends their values to vals
+func (s *gstate) splitConnectionRow(row json.RawMessage, vals [][]json.RawMessage) (
+	json.RawMessage, [][]json.RawMessage, error,
+) {
+	fields, err := jsonObjectFields(row)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cur []json.RawMessage
+	n := 0
+	for _, f := range fields {
+		if strings.HasPrefix(f.key, qcode.ConnectionCursorField) {
+			cur = append(cur, f.val)
+			continue
+		}
+		fields[n] = f
+		n++
+	}
+	return marshalJSONFields(fields[:n]), append(vals, cur), nil
+}
+
+// connectionCursor builds the cursor of a row in the format of the cursors
+// of the database, the id of the selector followed by the values
+func (s *gstate) connectionCursor(sel *qcode.Select, vals []json.RawMessage) string {
+	sep := ","
+	switch s.getTargetDBCtx().dbtype {
+	case "mariadb", "mssql":
+		sep = ":"
+	}
+
+	var b strings.Builder
+	b.Write(s.gj.printFormat)
+	b.WriteString(strconv.Itoa(int(sel.ID)))
+	for _, v := range vals {
+		b.WriteString(sep)
+		var str string
+		switch {
+		case bytes.Equal(v, []byte("null")):
+		case len(v) != 0 && v[0] == '"':
+			if err := json.Unmarshal(v, &str); err != nil {
+				str = string(v)
+			}
+		default:
+			str = string(v)
+		}
+		b.WriteString(str)
+	}
+	return b.String()
+}
+
+// hasCursor returns true if the query continues from a cursor
+func (s *gstate) hasCursor(sel *qcode.Select) bool {
+	name := sel.Paging.CursorVar
+	if name == "" {
+		name = "cursor"
+	}
+	v, ok := s.vmap[name]
+	return ok && len(v) != 0 && !bytes.Equal(v, []byte("null")) && !bytes.Equal(v, []byte(`""`))
+}
+
+// jsonObjectFields returns the fields of a json object in order
+func jsonObjectFields(data []byte) ([]jsonField, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, fmt.Errorf("expected a json object")
+	}
+	var fields []jsonField
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := t.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a json object key")
+		}
+		var val json.RawMessage
+		if err := dec.Decode(&val); err != nil {
+			return nil, err
+		}
+		fields = append(fields, jsonField{key: key, val: val})
+	}
+	return fields, nil
+}
+
+func jsonFieldIndex(fields []jsonField, key string) int {
+	for i := range fields {
+		if fields[i].key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// setJSONField sets the value of a field, it's added if it does not exist
+func setJSONField(fields []jsonField, key string, val json.RawMessage) []jsonField {
+	if i := jsonFieldIndex(fields, key); i != -1 {
+		fields[i].val = val
+		return fields
+	}
+	return append(fields, jsonField{key: key, val: val})
+}
+
+func marshalJSONFields(fields []jsonField) []byte {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range fields {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		b.Write(jsonString(f.key))
+		b.WriteByte(':')
+		b.Write(f.val)
+	}
+	b.WriteByte('}')
+	return b.Bytes()
+}
+
+func marshalJSONArray(vals []json.RawMessage) []byte {
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for i, v := range vals {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		b.Write(v)
+	}
+	b.WriteByte(']')
+	return b.Bytes()
+}
+
+func jsonString(v string) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
@@ -0,0 +1,118 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestConnection(t *testing.T) {
+	db := newTestDB(t, "connectiondb")
+
+	_, err := db.Exec(`INSERT INTO products (id, name, price, owner_id) VALUES
+		(3, 'Product Three', 30, 1),
+		(4, 'Product Four', 40, 2),
+		(5, 'Product Five', 50, 2);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query {
+		products(first: 2, after: $cursor, order_by: { id: asc }) @connection {
+			id
+			name
+		}
+	}`
+
+	type result struct {
+		Products []struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		} `json:"products"`
+		ProductsCursor   string `json:"products_cursor"`
+		ProductsPageInfo struct {
+			HasNextPage     bool    `json:"hasNextPage"`
+			HasPreviousPage bool    `json:"hasPreviousPage"`
+			StartCursor     *string `json:"startCursor"`
+			EndCursor       *string `json:"endCursor"`
+		} `json:"products_pageInfo"`
+	}
+
+	page := func(cursor *string) (r result, raw string) {
+		t.Helper()
+		vars := json.RawMessage(`{}`)
+		if cursor != nil {
+			vars, _ = json.Marshal(map[string]string{"cursor": *cursor})
+		}
+		res, err := gj.GraphQL(context.Background(), gql, vars, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(res.Data, &r); err != nil {
+			t.Fatal(err)
+		}
+		return r, string(res.Data)
+	}
+
+	var ids []int
+	var cursor *string
+	for i := 0; i < 3; i++ {
+		r, raw := page(cursor)
+		pi := r.ProductsPageInfo
+
+		if pi.HasPreviousPage != (i != 0) {
+			t.Fatalf("page %d: unexpected hasPreviousPage: %s", i, raw)
+		}
+		if pi.HasNextPage != (i != 2) {
+			t.Fatalf("page %d: unexpected hasNextPage: %s", i, raw)
+		}
+		if pi.StartCursor == nil || pi.EndCursor == nil || r.ProductsCursor != *pi.EndCursor {
+			t.Fatalf("page %d: expected start and end cursors: %s", i, raw)
+		}
+		for _, p := range r.Products {
+			ids = append(ids, p.ID)
+		}
+		cursor = pi.EndCursor
+	}
+
+	if len(ids) != 5 {
+		t.Fatalf("expected all 5 products in 3 pages, got %v", ids)
+	}
+	for i, id := range ids {
+		if id != i+1 {
+			t.Fatalf("expected the products in order, got %v", ids)
+		}
+	}
+
+	// the start cursor continues after the first row of the page
+	r, _ := page(nil)
+	r, raw := page(r.ProductsPageInfo.StartCursor)
+	if len(r.Products) != 2 || r.Products[0].ID != 2 {
+		t.Fatalf("expected the page after the first row: %s", raw)
+	}
+
+	// the hidden cursor fields are not returned
+	if strings.Contains(raw, "__gj_cur_") {
+		t.Fatalf("expected no cursor fields in the rows: %s", raw)
+	}
+
+	for _, q := range []string{
+		`query { products(limit: 2) @connection { id } }`,
+		`query { products(first: 2, before: $cursor) @connection { id } }`,
+		`query { users { products(first: 2, after: $cursor) @connection { id } } }`,
+		`mutation { products(id: 1, update: { name: "x" }) @connection { id } }`,
+	} {
+		if _, err := gj.GraphQL(context.Background(), q, nil, nil); err == nil {
+			t.Fatalf("expected an error for %s", q)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// ErrConstraintViolation is returned when a mutation violates a unique,
+// foreign key, check or not null constraint of the database
+var ErrConstraintViolation = errors.New("CONSTRAINT_VIOLATION")
+
+// ConstraintError is the error of a mutation that violated a constraint of
+// the database. The constraint and the columns are only set when the
+// database reports them
+type ConstraintError struct {
+	// Kind is unique, foreign_key, check or not_null
+	Kind       string
+	Constraint string
+	Columns    []string
+	err        error
+}
+
+func (e *ConstraintError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %s constraint", ErrConstraintViolation,
+		strings.ReplaceAll(e.Kind, "_", " "))
+	if e.Constraint != "" {
+		sb.WriteString(" " + e.Constraint)
+	}
+	sb.WriteString(" violated")
+	if len(e.Columns) != 0 {
+		sb.WriteString(" on " + strings.Join(e.Columns, ", "))
+	}
+	return sb.String()
+}
+
+func (e *ConstraintError) Is(target error) bool { return target == ErrConstraintViolation }
+func (e *ConstraintError) Unwrap() error        { return e.err }
+
+// constraintError returns a ConstraintError when the error of a mutation is
+// a constraint violation the dialect of the database can parse, other errors
+// are returned as is
+func (s *gstate) constraintError(err error) error {
+	if err == nil || s.r.operation != qcode.QTMutation {
+		return err
+	}
+	v, ok := s.getTargetPsqlCompiler().GetDialect().ConstraintViolation(err)
+	if !ok {
+		return err
+	}
+	return &ConstraintError{
+		Kind:       v.Kind,
+		Constraint: v.Constraint,
+		Columns:    v.Columns,
+		err:        err,
+	}
+}
@@ -0,0 +1,45 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestConstraintViolation(t *testing.T) {
+	db := newTestDB(t, "constraint")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `mutation {
+		users(insert: { id: 1, full_name: "Again", email: "again@test.com" }) { id }
+	}`
+	res, err := gj.GraphQL(context.Background(), gql, nil, nil)
+	if !errors.Is(err, core.ErrConstraintViolation) {
+		t.Fatalf("expected a constraint violation, got: %v", err)
+	}
+
+	var ce *core.ConstraintError
+	if !errors.As(err, &ce) || ce.Kind != "unique" ||
+		len(ce.Columns) != 1 || ce.Columns[0] != "id" {
+		t.Fatalf("unexpected constraint error: %+v", ce)
+	}
+
+	b, err := json.Marshal(res.Errors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `[{"message":"CONSTRAINT_VIOLATION: unique constraint violated on id",` +
+		`"extensions":{"code":"CONSTRAINT_VIOLATION","kind":"unique","columns":["id"]}}]`
+	if string(b) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, b)
+	}
+
+}
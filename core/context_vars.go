@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ContextVarsFn returns the variables derived from the request context,
+// for example the organization id from a claim of the user
+type ContextVarsFn func(c context.Context) (map[string]interface{}, error)
+
+// OptionSetContextVars sets the function used to compute variables from the
+// context of every request. The variables can be used in the query, the
+// presets and the filters and take precedence over the variables of the
+// same name sent by the client.
+func OptionSetContextVars(fn ContextVarsFn) Option {
+	return func(s *graphjinEngine) error {
+		s.contextVarsFn = fn
+		return nil
+	}
+}
+
+// setContextVars merges the variables returned by the context vars function
+// into the variables of the request
+func (s *gstate) setContextVars(c context.Context) error {
+	if s.gj.contextVarsFn == nil {
+		return nil
+	}
+	vars, err := s.gj.contextVarsFn(c)
+	if err != nil {
+		return fmt.Errorf("context vars: %w", err)
+	}
+	if len(vars) == 0 {
+		return nil
+	}
+
+	s.cvars = make(map[string]json.RawMessage, len(vars))
+	for k, v := range vars {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("context vars: '%s': %w", k, err)
+		}
+		s.cvars[k] = b
+	}
+
+	if s.vmap == nil {
+		s.vmap = make(map[string]json.RawMessage, len(s.cvars))
+	}
+	for k, v := range s.cvars {
+		s.vmap[k] = v
+	}
+	return nil
+}
+
+// cacheVars returns the variables the cache key is built from, the
+// context vars are part of it so responses are not shared across contexts
+func (s *gstate) cacheVars() json.RawMessage {
+	if len(s.cvars) == 0 {
+		return s.r.vars
+	}
+	// map keys are sorted by the encoder so the key is stable
+	b, err := json.Marshal(s.cvars)
+	if err != nil {
+		return s.r.vars
+	}
+	vars := make(json.RawMessage, 0, len(s.r.vars)+len(b)+5)
+	vars = append(vars, s.r.vars...)
+	vars = append(vars, ":ctx:"...)
+	return append(vars, b...)
+}
@@ -0,0 +1,103 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+type orgKey struct{}
+
+// keyCache records the keys of the responses it stores
+type keyCache struct {
+	keys []string
+}
+
+func (c *keyCache) Get(ctx context.Context, key string) ([]byte, bool, bool) {
+	return nil, false, false
+}
+
+func (c *keyCache) Set(ctx context.Context, key string, data []byte, refs []core.RowRef, queryStartTime time.Time) error {
+	c.keys = append(c.keys, key)
+	return nil
+}
+
+func (c *keyCache) InvalidateRows(ctx context.Context, refs []core.RowRef) error {
+	return nil
+}
+
+func TestContextVars(t *testing.T) {
+	db := newTestDB(t, "contextvarsdb1")
+
+	conf := &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		Roles: []core.Role{{
+			Name: "anon",
+			Tables: []core.RoleTable{{
+				Name:  "users",
+				Query: &core.Query{Filters: []string{"{ id: { eq: $owner } }"}},
+			}},
+		}},
+	}
+
+	rc := &keyCache{}
+	gj, err := core.NewGraphJin(conf, db,
+		core.OptionSetResponseCache(rc),
+		core.OptionSetContextVars(func(c context.Context) (map[string]interface{}, error) {
+			switch v := c.Value(orgKey{}).(type) {
+			case int:
+				return map[string]interface{}{"owner": v}, nil
+			case string:
+				return nil, errors.New("unknown org")
+			}
+			return nil, nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query getUsers { users { id } }`
+
+	// the filter uses the variable from the context
+	c := context.WithValue(context.Background(), orgKey{}, 2)
+	res, err := gj.GraphQL(c, gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":2}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+
+	// the client cannot override the variable from the context
+	vars := json.RawMessage(`{"owner": 1}`)
+	res, err = gj.GraphQL(c, gql, vars, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":2}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+
+	// responses for other context vars are cached under another key
+	c1 := context.WithValue(context.Background(), orgKey{}, 1)
+	res, err = gj.GraphQL(c1, gql, vars, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":1}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+	if len(rc.keys) != 3 || rc.keys[1] == rc.keys[2] {
+		t.Fatalf("expected distinct cache keys, got: %v", rc.keys)
+	}
+
+	c = context.WithValue(context.Background(), orgKey{}, "broken")
+	if _, err := gj.GraphQL(c, gql, nil, nil); err == nil {
+		t.Fatal("expected the error of the context vars function")
+	}
+}
@@ -29,13 +29,13 @@ const (
 
 func (gj *graphjinEngine) getIntroResult() (data json.RawMessage, err error) {
 	var ok bool
-	if data, ok = gj.cache.Get("_intro"); ok {
+	if data, ok = gj.localCache.Get("_intro"); ok {
 		return
 	}
 	if data, err = gj.introQuery(); err != nil {
 		return
 	}
-	gj.cache.Set("_intro", data)
+	gj.localCache.Set("_intro", data)
 	return
 }
 
@@ -111,6 +111,7 @@ func (gj *graphjinEngine) executeRoleQuery(c context.Context,
 	})
 	if err != nil {
 		span.Error(err)
+		err = wrapDBError(err)
 		return
 	}
 
@@ -157,6 +158,12 @@ func (r *Result) SQL() string {
 	return r.sql
 }
 
+// Returns the parameters bound to the executed query in the order the
+// database received them, only set in debug mode and never in production
+func (r *Result) Params() []QueryParam {
+	return r.params
+}
+
 // Returns the cache control header value for the query result
 func (r *Result) CacheControl() string {
 	return r.cacheControl
@@ -167,6 +174,12 @@ func (r *Result) CacheHit() bool {
 	return r.cacheHit
 }
 
+// Truncated returns true if rows were left out of the response
+// to fit the maximum number of rows per response
+func (r *Result) Truncated() bool {
+	return r.truncated
+}
+
 // debugLogStmt logs the query statement for debugging
 func (s *gstate) debugLogStmt() {
 	st := s.cs.st
@@ -0,0 +1,184 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// ErrRateLimited is returned when a query would exceed the cost budget
+// configured for the role executing it
+var ErrRateLimited = errors.New("RATE_LIMITED")
+
+// defaultCostWindow is used when a role sets a cost budget without a window
+const defaultCostWindow = time.Minute
+
+// QueryShape summarizes a compiled query and is used to compute its cost
+type QueryShape struct {
+	Name      string
+	Operation OpType
+	Role      string
+
+	// Number of tables selected including nested selections
+	Selects int
+
+	// Number of joins or lookups required to fetch nested selections
+	Joins int
+
+	// Number of aggregate functions (count, sum, etc) requested
+	Aggregations int
+
+	// Estimated number of rows fetched, the product of the limits along
+	// each path of nested selections summed across all selections
+	FanOut int
+}
+
+// CostFn computes a numeric cost for a compiled query
+type CostFn func(qs QueryShape) int
+
+// DefaultQueryCost is the cost function used when none is set
+// with OptionSetCostFn
+func DefaultQueryCost(qs QueryShape) int {
+	return qs.Selects + (qs.Joins * 2) + qs.Aggregations + (qs.FanOut / 100)
+}
+
+// OptionSetCostFn sets the function used to compute the cost of a query
+func OptionSetCostFn(fn CostFn) Option {
+	return func(s *graphjinEngine) error {
+		s.costFn = fn
+		return nil
+	}
+}
+
+// newQueryShape builds the query shape for a compiled query
+func newQueryShape(qc *qcode.QCode, role string) QueryShape {
+	qs := QueryShape{
+		Name:      qc.Name,
+		Operation: opTypeOf(qc.Type),
+		Role:      role,
+	}
+
+	rows := make([]int, len(qc.Selects))
+
+	for i := range qc.Selects {
+		sel := &qc.Selects[i]
+		if sel.SkipRender != qcode.SkipTypeNone {
+			continue
+		}
+		qs.Selects++
+
+		if sel.ParentID != -1 {
+			qs.Joins += 1 + len(sel.Joins)
+		}
+
+		for _, f := range sel.Fields {
+			if f.Type == qcode.FieldTypeFunc && f.Func.Agg {
+				qs.Aggregations++
+			}
+		}
+
+		n := 1
+		if !sel.Singular {
+			if sel.Paging.Limit > 0 {
+				n = int(sel.Paging.Limit)
+			} else {
+				n = 20
+			}
+		}
+		// selects are ordered so a parent always comes before its children
+		if sel.ParentID != -1 && int(sel.ParentID) < len(rows) {
+			n *= rows[sel.ParentID]
+		}
+		rows[i] = n
+		qs.FanOut += n
+	}
+	return qs
+}
+
+// queryCost computes the cost of a compiled query
+func (gj *graphjinEngine) queryCost(qc *qcode.QCode, role string) int {
+	qs := newQueryShape(qc, role)
+	if gj.costFn != nil {
+		return gj.costFn(qs)
+	}
+	return DefaultQueryCost(qs)
+}
+
+// checkCostBudget spends the query cost from the role budget and
+// returns ErrRateLimited when the budget for the current window is exhausted.
+// The cost is charged once per request, the roots of a query retried one at
+// a time for a partial result are not charged again
+func (s *gstate) checkCostBudget() error {
+	if s.costCharged || s.cs == nil {
+		return nil
+	}
+	s.costCharged = true
+	return s.gj.spendCost(s.cs.st.roc, s.cs.st.cost)
+}
+
+// spendCost spends the cost from the role budget, all the queries,
+// mutations and subscription polls are charged through it
+func (gj *graphjinEngine) spendCost(roc *Role, cost int) error {
+	if roc == nil || roc.CostBudget <= 0 {
+		return nil
+	}
+	if !gj.costLimiter.spend(roc.Name, cost, roc.CostBudget, roc.CostWindow, time.Now()) {
+		return fmt.Errorf("%w: query cost %d exceeds the remaining budget for role '%s'",
+			ErrRateLimited, cost, roc.Name)
+	}
+	return nil
+}
+
+// costLimiter tracks the cost spent by each role within a fixed time window
+type costLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*costWindow
+}
+
+type costWindow struct {
+	start time.Time
+	spent int
+}
+
+func newCostLimiter() *costLimiter {
+	return &costLimiter{windows: make(map[string]*costWindow)}
+}
+
+// spend adds the cost to the role's current window if it fits within the budget
+func (cl *costLimiter) spend(role string, cost, budget int, window time.Duration, now time.Time) bool {
+	if window <= 0 {
+		window = defaultCostWindow
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	w, ok := cl.windows[role]
+	if !ok || now.Sub(w.start) >= window {
+		w = &costWindow{start: now}
+		cl.windows[role] = w
+	}
+
+	if w.spent+cost > budget {
+		return false
+	}
+	w.spent += cost
+	return true
+}
+
+// opTypeOf maps a qcode query type to the public operation type
+func opTypeOf(qt qcode.QType) OpType {
+	switch qt {
+	case qcode.QTQuery:
+		return OpQuery
+	case qcode.QTSubscription:
+		return OpSubscription
+	case qcode.QTMutation, qcode.QTInsert, qcode.QTUpdate, qcode.QTUpsert, qcode.QTDelete:
+		return OpMutation
+	default:
+		return OpUnknown
+	}
+}
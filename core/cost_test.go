@@ -0,0 +1,171 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestQueryCostRateLimit(t *testing.T) {
+	db := newTestDB(t, "costdb1")
+
+	conf := &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		Roles: []core.Role{
+			{Name: "user", CostBudget: 10},
+		},
+	}
+
+	var costs []int
+	gj, err := core.NewGraphJin(conf, db,
+		core.OptionSetCostFn(func(qs core.QueryShape) int {
+			return qs.Selects * 3
+		}),
+		core.OptionSetMetrics(func(c context.Context, m core.QueryMetrics) {
+			costs = append(costs, m.Cost)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query { users { id products { id } } }`
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 1)
+
+	// each request costs 6 so the second one exceeds the budget of 10
+	if _, err := gj.GraphQL(ctx, gql, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = gj.GraphQL(ctx, gql, nil, nil)
+	if !errors.Is(err, core.ErrRateLimited) {
+		t.Fatalf("expected rate limited error, got: %v", err)
+	}
+
+	// the anon role has no budget configured
+	if _, err := gj.GraphQL(context.Background(), gql, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(costs) != 3 || costs[0] != 6 {
+		t.Fatalf("expected the cost to be reported to the metrics hook, got: %v", costs)
+	}
+}
+
+func TestDefaultQueryCost(t *testing.T) {
+	flat := core.DefaultQueryCost(core.QueryShape{Selects: 1, FanOut: 20})
+	nested := core.DefaultQueryCost(core.QueryShape{Selects: 2, Joins: 1, FanOut: 420})
+
+	if nested <= flat {
+		t.Fatalf("expected a nested query (%d) to cost more than a flat one (%d)", nested, flat)
+	}
+}
+
+func TestQueryCostMultiDB(t *testing.T) {
+	var costs []int
+	gj := newMultiTestGraphJin(t, "costdb2", &core.Config{
+		DisableAllowList: true,
+		Roles:            []core.Role{{Name: "user", CostBudget: 10}},
+	},
+		core.OptionSetCostFn(func(qs core.QueryShape) int {
+			return qs.Selects * 3
+		}),
+		core.OptionSetMetrics(func(c context.Context, m core.QueryMetrics) {
+			costs = append(costs, m.Cost)
+		}))
+
+	gql := `query { users { id } audit_logs { id } }`
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 1)
+
+	// the roots on both databases cost 6 together
+	if _, err := gj.GraphQL(ctx, gql, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	_, err := gj.GraphQL(ctx, gql, nil, nil)
+	if !errors.Is(err, core.ErrRateLimited) {
+		t.Fatalf("expected rate limited error, got: %v", err)
+	}
+	if len(costs) == 0 || costs[0] != 6 {
+		t.Fatalf("expected the cost of both databases, got: %v", costs)
+	}
+}
+
+func TestQueryCostPartialResult(t *testing.T) {
+	db := newTestDB(t, "costdb3")
+	if _, err := db.Exec(`CREATE TABLE drafts (id INTEGER PRIMARY KEY)`); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		Roles:            []core.Role{{Name: "user", CostBudget: 6}},
+	}
+	gj, err := core.NewGraphJin(conf, db,
+		core.OptionSetCostFn(func(qs core.QueryShape) int {
+			return qs.Selects * 3
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the query fails once the table is gone and its roots are retried
+	// one at a time for a partial result
+	if _, err := db.Exec(`DROP TABLE drafts`); err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query { users(id: 1) { id } drafts { id } }`
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 1)
+
+	// the query spends the whole budget so the retried roots must not be
+	// charged again
+	res, err := gj.GraphQL(ctx, gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":{"id":1},"drafts":null}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+	for _, e := range res.Errors {
+		if strings.Contains(e.Message, core.ErrRateLimited.Error()) {
+			t.Fatalf("expected the retried roots not to be charged, got: %v", res.Errors)
+		}
+	}
+}
+
+func TestSubscriptionCost(t *testing.T) {
+	db := newTestDB(t, "costdb4")
+
+	conf := &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		SubsPollDuration: 200 * time.Millisecond,
+		Roles:            []core.Role{{Name: "user", CostBudget: 12}},
+	}
+	gj, err := core.NewGraphJin(conf, db,
+		core.OptionSetCostFn(func(qs core.QueryShape) int { return 5 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 1)
+
+	// subscribing costs 5 and every poll of the subscription 5 more
+	m, err := gj.Subscribe(ctx, `subscription { users(id: 1) { id } }`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Unsubscribe()
+	<-m.Result
+
+	time.Sleep(500 * time.Millisecond)
+
+	_, err = gj.GraphQL(ctx, `query { users(id: 1) { id } }`, nil, nil)
+	if !errors.Is(err, core.ErrRateLimited) {
+		t.Fatalf("expected the polls to be charged, got: %v", err)
+	}
+}
@@ -12,7 +12,6 @@ import (
 
 	"github.com/dosco/graphjin/core/v3/internal/graph"
 	"github.com/dosco/graphjin/core/v3/internal/jsn"
-	"github.com/dosco/graphjin/core/v3/internal/psql"
 	"github.com/dosco/graphjin/core/v3/internal/qcode"
 )
 
@@ -201,7 +200,7 @@ func (s *gstate) executeDatabaseJoinQuery(
 			}
 			return []byte(`{"` + sel.Table + `": []}`), nil
 		}
-		return nil, fmt.Errorf("query execution failed: %w", err)
+		return nil, fmt.Errorf("query execution failed: %w", wrapDBError(err))
 	}
 
 	return data, nil
@@ -340,19 +339,29 @@ func (s *gstate) groupSelectsByDatabase() []dbGroup {
 // dbResult holds the result from executing a query against one database.
 type dbResult struct {
 	database string
+	roots    []string
 	data     json.RawMessage
 	err      error
+	rerrs    []rootError
 }
 
 // mergeRootResults merges results from multiple databases into a single JSON response.
 // Root-level results are JSON objects that need to be combined.
 func (s *gstate) mergeRootResults(results []dbResult) error {
-	// Check for errors first
+	// Check for errors first, for queries the roots of a failed database
+	// are returned as null as long as another database returned data
+	var ok int
+	var ferr error
 	for _, r := range results {
-		if r.err != nil {
-			return fmt.Errorf("database %s: %w", r.database, r.err)
+		if r.err == nil {
+			ok++
+		} else if ferr == nil {
+			ferr = fmt.Errorf("database %s: %w", r.database, r.err)
 		}
 	}
+	if ferr != nil && (s.r.operation != qcode.QTQuery || ok == 0) {
+		return ferr
+	}
 
 	if len(results) == 0 {
 		return nil
@@ -360,6 +369,7 @@ func (s *gstate) mergeRootResults(results []dbResult) error {
 
 	if len(results) == 1 {
 		s.data = results[0].data
+		s.rerrs = append(s.rerrs, results[0].rerrs...)
 		return nil
 	}
 
@@ -369,6 +379,17 @@ func (s *gstate) mergeRootResults(results []dbResult) error {
 	merged := make(map[string]json.RawMessage)
 
 	for _, r := range results {
+		s.rerrs = append(s.rerrs, r.rerrs...)
+
+		if r.err != nil {
+			err := fmt.Errorf("database %s: %w", r.database, r.err)
+			for _, rf := range s.namedRootFields(r.roots) {
+				merged[rf.key] = json.RawMessage(`null`)
+				s.rerrs = append(s.rerrs, rootError{key: rf.key, err: err})
+			}
+			continue
+		}
+
 		if len(r.data) == 0 {
 			continue
 		}
@@ -404,21 +425,27 @@ func (gj *graphjinEngine) isMultiDB() bool {
 // It parses the original query, filters to include only the given fields, and reconstructs
 // a valid GraphQL query string.
 func (s *gstate) buildDatabaseQuery(rootFields []string) ([]byte, error) {
-	op, err := graph.Parse(s.r.query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse query: %w", err)
-	}
-
 	// Build a set of allowed root field names
 	allowed := make(map[string]bool, len(rootFields))
 	for _, f := range rootFields {
 		allowed[f] = true
 	}
+	return s.buildRootsQuery(func(f *graph.Field) bool { return allowed[f.Name] })
+}
+
+// buildRootsQuery creates a new GraphQL query containing only the root fields
+// that keep returns true for
+func (s *gstate) buildRootsQuery(keep func(f *graph.Field) bool) ([]byte, error) {
+	op, err := graph.Parse(s.r.query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse query: %w", err)
+	}
 
 	// Find the root field IDs we want to keep
 	keepFieldIDs := make(map[int32]bool)
-	for _, f := range op.Fields {
-		if f.ParentID == -1 && allowed[f.Name] {
+	for i := range op.Fields {
+		f := &op.Fields[i]
+		if f.ParentID == -1 && keep(f) {
 			keepFieldIDs[f.ID] = true
 			// Also mark all descendants
 			markDescendants(op.Fields, f.ID, keepFieldIDs)
@@ -513,6 +540,24 @@ func writeFieldsRecursive(buf *bytes.Buffer, fields []graph.Field, parentID int3
 			buf.WriteString(")")
 		}
 
+		// Write directives if present
+		for _, d := range f.Directives {
+			buf.WriteString(" @")
+			buf.WriteString(d.Name)
+			if len(d.Args) > 0 {
+				buf.WriteString("(")
+				for i, arg := range d.Args {
+					if i > 0 {
+						buf.WriteString(", ")
+					}
+					buf.WriteString(arg.Name)
+					buf.WriteString(": ")
+					writeNode(buf, arg.Val)
+				}
+				buf.WriteString(")")
+			}
+		}
+
 		// Check if this field has children
 		hasChildren := false
 		for _, child := range fields {
@@ -582,78 +627,131 @@ func (s *gstate) executeParallelRoots(c context.Context) error {
 		return fmt.Errorf("executeParallelRoots called without multi-DB configuration")
 	}
 
-	var wg sync.WaitGroup
-	results := make([]dbResult, len(s.dbGroups))
-
-	i := 0
+	// the sub-queries are compiled before any of them runs so the cost
+	// of the whole query is charged once
+	results := make([]dbResult, 0, len(s.dbGroups))
+	s.dbStmts = make([]stmt, 0, len(s.dbGroups))
+	var cost int
 	for dbName, rootFields := range s.dbGroups {
+		r := dbResult{database: dbName, roots: rootFields}
+		st, err := s.compileForDatabaseRoots(dbName, rootFields)
+		if err != nil {
+			r.err = err
+		} else {
+			cost += s.gj.queryCost(st.qc, s.role)
+		}
+		results = append(results, r)
+		s.dbStmts = append(s.dbStmts, st)
+	}
+
+	s.cs = &cstate{st: stmt{role: s.role, roc: s.gj.roles[s.role], cost: cost}}
+	if err := s.checkCostBudget(); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for i := range results {
 		wg.Add(1)
-		go func(idx int, db string, fields []string) {
+		go func(r *dbResult, qc *qcode.QCode) {
 			defer wg.Done()
 
 			ctx1, span := s.gj.spanStart(c, "Execute Parallel Root")
-			span.SetAttributesString(StringAttr{"query.database", db})
+			span.SetAttributesString(StringAttr{"query.database", r.database})
 			defer span.End()
 
-			data, err := s.executeForDatabaseRoots(ctx1, db, fields)
+			var data json.RawMessage
+			err := r.err
+			if err == nil {
+				data, err = s.executeDatabaseQC(ctx1, r.database, qc)
+			}
+
+			// Retry the roots one at a time to return the ones that succeed,
+			// the retries are part of the query whose cost is already charged
+			var rerrs []rootError
+			if roots := s.namedRootFields(r.roots); err != nil &&
+				s.r.operation == qcode.QTQuery && len(roots) > 1 {
+				d, re, _, err1 := s.execRootsSeparately(roots,
+					func(rf rootField) (json.RawMessage, []error, error) {
+						q, err := s.buildRootQuery(rf)
+						if err != nil {
+							return nil, nil, err
+						}
+						d, err := s.executeForDatabaseQuery(ctx1, r.database, q)
+						return d, nil, err
+					})
+				if err1 == nil {
+					data, rerrs, err = d, re, nil
+				}
+			}
 			if err != nil {
 				span.Error(err)
 			}
 
-			results[idx] = dbResult{
-				database: db,
-				data:     data,
-				err:      err,
-			}
-		}(i, dbName, rootFields)
-		i++
+			r.data, r.err, r.rerrs = data, err, rerrs
+		}(&results[i], s.dbStmts[i].qc)
 	}
 
 	wg.Wait()
 	return s.mergeRootResults(results)
 }
 
-// executeForDatabaseRoots builds a sub-query for the specified root fields,
-// compiles it using the target database's compilers, and executes it.
-func (s *gstate) executeForDatabaseRoots(ctx context.Context, dbName string, rootFields []string) (json.RawMessage, error) {
-	// Get database context
-	var db *sql.DB
-	var qcodeCompiler *qcode.Compiler
-	var psqlCompiler *psql.Compiler
+// compileForDatabaseRoots builds a sub-query for the specified root fields
+// and compiles it using the target database's compilers.
+func (s *gstate) compileForDatabaseRoots(dbName string, rootFields []string) (stmt, error) {
+	// Build a sub-query with only this database's root fields
+	subQuery, err := s.buildDatabaseQuery(rootFields)
+	if err != nil {
+		return stmt{}, fmt.Errorf("failed to build sub-query for %s: %w", dbName, err)
+	}
+	return s.compileForDatabaseQuery(dbName, subQuery)
+}
 
+// executeForDatabaseQuery compiles the sub-query using the target database's
+// compilers and executes it.
+func (s *gstate) executeForDatabaseQuery(ctx context.Context, dbName string, subQuery []byte) (json.RawMessage, error) {
+	st, err := s.compileForDatabaseQuery(dbName, subQuery)
+	if err != nil {
+		return nil, err
+	}
+	return s.executeDatabaseQC(ctx, dbName, st.qc)
+}
+
+// compileForDatabaseQuery compiles the sub-query to the QCode of the target
+// database, its limits are lowered to enforce the maximum rows.
+func (s *gstate) compileForDatabaseQuery(dbName string, subQuery []byte) (st stmt, err error) {
 	dbCtx, ok := s.gj.GetDatabase(dbName)
 	if !ok {
-		return nil, fmt.Errorf("database not found: %s", dbName)
+		return st, fmt.Errorf("database not found: %s", dbName)
 	}
-	db = dbCtx.db
-	qcodeCompiler = dbCtx.qcodeCompiler
-	psqlCompiler = dbCtx.psqlCompiler
 
 	// Block mutations on read-only databases (absolute, independent of roles)
-	if s.r.operation == qcode.QTMutation {
-		if dbConf, ok := s.gj.conf.Databases[dbName]; ok && dbConf.ReadOnly {
-			return nil, fmt.Errorf("mutations blocked: database %s is read-only", dbName)
-		}
+	if err = s.gj.checkReadOnlyDB(s.r.operation, dbName); err != nil {
+		return st, err
 	}
 
-	// Build a sub-query with only this database's root fields
-	subQuery, err := s.buildDatabaseQuery(rootFields)
-	if err != nil {
-		return nil, fmt.Errorf("failed to build sub-query for %s: %w", dbName, err)
+	st = stmt{role: s.role, roc: s.gj.roles[s.role]}
+	if st.qc, err = dbCtx.qcodeCompiler.Compile(subQuery, s.compileVars(), s.role, s.r.namespace); err != nil {
+		return st, fmt.Errorf("qcode compile failed for %s: %w", dbName, err)
 	}
-
-	// Compile QCode
-	var vars map[string]json.RawMessage
-	if len(s.r.aschema) != 0 {
-		vars = s.r.aschema
-	} else {
-		vars = s.vmap
+	if err = s.gj.checkReadOnlyMaterialize(st.qc, dbName); err != nil {
+		return st, err
 	}
 
-	qc, err := qcodeCompiler.Compile(subQuery, vars, s.role, s.r.namespace)
-	if err != nil {
-		return nil, fmt.Errorf("qcode compile failed for %s: %w", dbName, err)
+	max, truncate := s.gj.maxRows(st.roc)
+	st.capped = limitRows(st.qc, max, truncate)
+	return st, nil
+}
+
+// executeDatabaseQC compiles the QCode to SQL using the target database's
+// compilers and executes it.
+func (s *gstate) executeDatabaseQC(ctx context.Context, dbName string, qc *qcode.QCode) (json.RawMessage, error) {
+	dbCtx, ok := s.gj.GetDatabase(dbName)
+	if !ok {
+		return nil, fmt.Errorf("database not found: %s", dbName)
 	}
+	db := dbCtx.db
+	psqlCompiler := dbCtx.psqlCompiler
+	vars := s.compileVars()
 
 	// Compile SQL
 	var sqlBuf bytes.Buffer
@@ -661,7 +759,6 @@ func (s *gstate) executeForDatabaseRoots(ctx context.Context, dbName string, roo
 	if err != nil {
 		return nil, fmt.Errorf("sql compile failed for %s: %w", dbName, err)
 	}
-	_ = md // metadata not used for now
 
 	// Get connection
 	conn, err := db.Conn(ctx)
@@ -687,7 +784,7 @@ func (s *gstate) executeForDatabaseRoots(ctx context.Context, dbName string, roo
 		if err == sql.ErrNoRows {
 			return json.RawMessage(`{}`), nil
 		}
-		return nil, fmt.Errorf("query execution failed for %s: %w", dbName, err)
+		return nil, fmt.Errorf("query execution failed for %s: %w", dbName, wrapDBError(err))
 	}
 
 	// Handle encryption if needed
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// DirectiveFn handles a custom directive, it is called when a query using the
+// directive is compiled and can change the selector or field it is used on
+type DirectiveFn func(d *Directive) error
+
+// Directive is a custom directive used on a selector or field
+type Directive struct {
+	Name string
+
+	// Argument values, variables are set as $name
+	Args map[string]string
+
+	Role  string
+	Table string
+
+	// Field is set when the directive is used on a column
+	Field string
+
+	d *qcode.CustomDirective
+}
+
+// Skip returns null for the selector or field
+func (d *Directive) Skip() {
+	d.d.Skip()
+}
+
+// SetSingular returns a single object for the selector instead of a list
+func (d *Directive) SetSingular() error {
+	return d.d.SetSingular()
+}
+
+// AddFilter adds a where filter to the selector. It uses the same syntax
+// as the role filters, eg. { tenant_id: { eq: $tenant_id } }
+func (d *Directive) AddFilter(filter string) error {
+	return d.d.AddFilter(filter)
+}
+
+// OptionAddDirective adds a custom directive that can be used on selectors and fields.
+// Built-in directives cannot be replaced and using a directive that is not
+// registered is still an error
+func OptionAddDirective(name string, fn DirectiveFn) Option {
+	return func(s *graphjinEngine) error {
+		if name == "" || fn == nil {
+			return errors.New("directive: name and handler are required")
+		}
+		if s.directives == nil {
+			s.directives = make(map[string]DirectiveFn)
+		}
+		s.directives[name] = fn
+		return nil
+	}
+}
+
+// directiveFns returns the custom directives for the query compiler
+func (gj *graphjinEngine) directiveFns() map[string]qcode.DirectiveFn {
+	if len(gj.directives) == 0 {
+		return nil
+	}
+	m := make(map[string]qcode.DirectiveFn, len(gj.directives))
+
+	for name, fn := range gj.directives {
+		fn := fn
+		m[name] = func(cd *qcode.CustomDirective) error {
+			return fn(&Directive{
+				Name:  cd.Name,
+				Args:  cd.Args,
+				Role:  cd.Role,
+				Table: cd.Table,
+				Field: cd.Field,
+				d:     cd,
+			})
+		}
+	}
+	return m
+}
+
+// directiveNames returns the names of the custom directives in sorted order
+func (gj *graphjinEngine) directiveNames() []string {
+	names := make([]string, 0, len(gj.directives))
+	for name := range gj.directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,81 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestCustomDirective(t *testing.T) {
+	db := newTestDB(t, "directivedb1")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db,
+		core.OptionAddDirective("owner", func(d *core.Directive) error {
+			id, ok := d.Args["id"]
+			if !ok {
+				return errors.New("argument id is required")
+			}
+			return d.AddFilter(`{ owner_id: { eq: ` + id + ` } }`)
+		}),
+		core.OptionAddDirective("first", func(d *core.Directive) error {
+			return d.SetSingular()
+		}),
+		core.OptionAddDirective("hidden", func(d *core.Directive) error {
+			d.Skip()
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	gql := `query { products(order_by: { id: asc }) @owner(id: $owner) { id name @hidden } }`
+
+	res, err := gj.GraphQL(ctx, gql, []byte(`{"owner": 1}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"products":[{"id":1,"name":null},{"id":2,"name":null}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+
+	res, err = gj.GraphQL(ctx, gql, []byte(`{"owner": 2}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"products":[]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+
+	gql = `query { products(order_by: { id: desc }) @first { id } }`
+
+	res, err = gj.GraphQL(ctx, gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"products":{"id":2}}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+
+	_, err = gj.GraphQL(ctx, `query { products @unknown { id } }`, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Fatalf("expected an unknown directive error, got: %v", err)
+	}
+}
+
+func TestCustomDirectiveBuiltinName(t *testing.T) {
+	db := newTestDB(t, "directivedb2")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	_, err := core.NewGraphJin(conf, db,
+		core.OptionAddDirective("skip", func(d *core.Directive) error {
+			return nil
+		}))
+	if err == nil {
+		t.Fatal("expected an error when replacing a built-in directive")
+	}
+}
@@ -0,0 +1,27 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// ErrEmptyMutation is returned when an update has no columns to set and
+// empty_mutations is 'error'
+var ErrEmptyMutation = qcode.ErrEmptyMutation
+
+// Values of the empty_mutations config
+const (
+	EmptyMutationsNoop  = qcode.EmptyMutationsNoop
+	EmptyMutationsError = qcode.EmptyMutationsError
+)
+
+// validateEmptyMutations checks the empty_mutations config value
+func validateEmptyMutations(mode string) error {
+	switch mode {
+	case "", EmptyMutationsNoop, EmptyMutationsError:
+		return nil
+	}
+	return fmt.Errorf("empty_mutations: invalid value '%s': must be '%s' or '%s'",
+		mode, EmptyMutationsNoop, EmptyMutationsError)
+}
@@ -0,0 +1,57 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestEmptyMutations(t *testing.T) {
+	gql := `mutation { users(id: 1, update: $data) { id full_name } }`
+	vars := json.RawMessage(`{"data": {}}`)
+
+	for _, mode := range []string{"", core.EmptyMutationsNoop, core.EmptyMutationsError} {
+		db := newTestDB(t, "emptymutationsdb"+mode)
+
+		conf := &core.Config{DBType: "sqlite", DisableAllowList: true, EmptyMutations: mode}
+		gj, err := core.NewGraphJin(conf, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := gj.GraphQL(context.Background(), gql, vars, nil)
+		switch mode {
+		case core.EmptyMutationsError:
+			if !errors.Is(err, core.ErrEmptyMutation) {
+				t.Fatalf("expected an empty mutation error, got: %v", err)
+			}
+		default:
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exp := `{"users":{"id":1,"full_name":"User One"}}`; string(res.Data) != exp {
+				t.Fatalf("expected: %s, got: %s", exp, res.Data)
+			}
+			// the matched rows are returned without being updated
+			if hasUpdate := strings.Contains(res.SQL(), "UPDATE"); hasUpdate != (mode == "") {
+				t.Fatalf("unexpected sql for mode '%s': %s", mode, res.SQL())
+			}
+		}
+
+		// a delete with an empty filter is always rejected
+		_, err = gj.GraphQL(context.Background(),
+			`mutation { users(where: {}, delete: true) { id } }`, nil, nil)
+		if err == nil {
+			t.Fatalf("expected the delete to be rejected with mode '%s'", mode)
+		}
+	}
+
+	conf := &core.Config{DBType: "sqlite", EmptyMutations: "skip"}
+	if _, err := core.NewGraphJin(conf, newTestDB(t, "emptymutationsdb2")); err == nil {
+		t.Fatal("expected an invalid empty_mutations error")
+	}
+}
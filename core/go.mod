@@ -3,8 +3,22 @@ module github.com/dosco/graphjin/core/v3
 go 1.24.0
 
 require (
+	github.com/dosco/graphjin/mongodriver v0.0.0
 	github.com/hashicorp/golang-lru/v2 v2.0.7
 	github.com/mattn/go-sqlite3 v1.14.32
 	golang.org/x/sync v0.19.0
 	golang.org/x/text v0.33.0
 )
+
+require (
+	github.com/golang/snappy v0.0.4 // indirect
+	github.com/klauspost/compress v1.16.7 // indirect
+	github.com/xdg-go/pbkdf2 v1.0.0 // indirect
+	github.com/xdg-go/scram v1.1.2 // indirect
+	github.com/xdg-go/stringprep v1.0.4 // indirect
+	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78 // indirect
+	go.mongodb.org/mongo-driver/v2 v2.0.0 // indirect
+	golang.org/x/crypto v0.29.0 // indirect
+)
+
+replace github.com/dosco/graphjin/mongodriver => ../mongodriver
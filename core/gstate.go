@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,14 +21,21 @@ import (
 )
 
 type gstate struct {
-	gj    *graphjinEngine
-	r     GraphqlReq
-	cs    *cstate
-	vmap  map[string]json.RawMessage
-	data  []byte
-	dhash [sha256.Size]byte
-	role  string
-	verrs []qcode.ValidErr
+	gj     *graphjinEngine
+	r      GraphqlReq
+	cs     *cstate
+	vmap   map[string]json.RawMessage
+	cvars  map[string]json.RawMessage // variables set by the context vars function
+	data   []byte
+	dhash  [sha256.Size]byte
+	role   string
+	verrs  []qcode.ValidErr
+	ferrs  []error      // per-row computed field errors
+	rerrs  []rootError  // roots that failed in a partial result
+	fderrs []fieldError // remote fields that failed and were returned as null
+	// params are the parameters bound to the executed query, they are
+	// only set in debug mode
+	params []QueryParam
 	// database is the target database name for multi-database support.
 	// Empty string means default database (backward compatible single-DB mode).
 	database string
@@ -37,12 +45,22 @@ type gstate struct {
 	// dbGroups maps database names to their root field names for multi-DB queries.
 	// Only populated when multiDB is true.
 	dbGroups map[string][]string
+	// dbStmts are the sub-queries compiled for each database of a multi-DB
+	// query, used to count the rows of the merged response
+	dbStmts []stmt
+	// roleResolved is true when the role was set by the role resolver
+	roleResolved bool
 
 	// Cache-related fields
 	cacheKey     string    // Cache key for this query
 	queryStarted time.Time // When query started (for race condition detection)
 	cacheHit     bool      // True if response was served from cache
 	skipCache    bool      // True if caching should be skipped for this query
+	truncated    bool      // True if rows were left out to fit the maximum rows
+	costCharged  bool      // True once the cost is spent from the role budget
+
+	// timing is the time spent in each phase, nil unless requested
+	timing *Timing
 }
 
 type cstate struct {
@@ -57,12 +75,19 @@ type stmt struct {
 	qc   *qcode.QCode
 	md   psql.Metadata
 	sql  string
+	cost int
+	// selectors whose limit was lowered to enforce the maximum rows
+	capped map[int32]bool
 }
 
 func newGState(c context.Context, gj *graphjinEngine, r GraphqlReq) (s gstate, err error) {
 	s.gj = gj
 	s.r = r
 
+	if rc := r.requestconfig; rc != nil && rc.Timing {
+		s.timing = &Timing{}
+	}
+
 	if v, ok := c.Value(UserRoleKey).(string); ok {
 		s.role = v
 	} else {
@@ -74,6 +99,10 @@ func newGState(c context.Context, gj *graphjinEngine, r GraphqlReq) (s gstate, e
 		}
 	}
 
+	if err = s.resolveRole(c); err != nil {
+		return
+	}
+
 	// convert variable json to a go map also decrypted encrypted values
 	if len(r.vars) != 0 {
 		var vars json.RawMessage
@@ -87,11 +116,14 @@ func newGState(c context.Context, gj *graphjinEngine, r GraphqlReq) (s gstate, e
 			return
 		}
 	}
+
+	err = s.setContextVars(c)
 	return
 }
 
 func (s *gstate) compile() (err error) {
-	if !s.gj.prodSec {
+	// bypassed queries are not in the allow list so are not cached
+	if !s.gj.prodSec || s.r.bypass {
 		err = s.compileQueryForRole()
 		return
 	}
@@ -103,8 +135,8 @@ func (s *gstate) compile() (err error) {
 }
 
 func (s *gstate) compileQueryForRoleOnce() (err error) {
-	val, loaded := s.gj.queries.LoadOrStore(s.key(), &cstate{})
-	s.cs = val.(*cstate)
+	var loaded bool
+	s.cs, loaded = s.gj.localCache.compiled(s.key())
 
 	if !loaded {
 		s.cs.Do(func() {
@@ -125,12 +157,7 @@ func (s *gstate) compileQueryForRole() (err error) {
 		return
 	}
 
-	var vars map[string]json.RawMessage
-	if len(s.r.aschema) != 0 { // compile in prod (once)
-		vars = s.r.aschema
-	} else { // compiling in dev
-		vars = s.vmap
-	}
+	vars := s.compileVars()
 
 	// Multi-DB mode: check if query spans multiple databases
 	if s.gj.isMultiDB() {
@@ -171,6 +198,15 @@ func (s *gstate) compileQueryForRole() (err error) {
 	return s.compileWithCompilers(st, vars, pdb.qcodeCompiler, pdb.psqlCompiler, "")
 }
 
+// compileVars returns the variables a query is compiled with, the
+// variables of the allow list in production and of the request in dev
+func (s *gstate) compileVars() map[string]json.RawMessage {
+	if len(s.r.aschema) != 0 {
+		return s.r.aschema
+	}
+	return s.vmap
+}
+
 // compileForDatabase compiles the query using a specific database's compilers.
 func (s *gstate) compileForDatabase(st stmt, vars map[string]json.RawMessage, dbCtx *dbContext) (err error) {
 	s.database = dbCtx.name
@@ -179,6 +215,7 @@ func (s *gstate) compileForDatabase(st stmt, vars map[string]json.RawMessage, db
 
 // compileWithCompilers performs the actual compilation with the given compilers.
 func (s *gstate) compileWithCompilers(st stmt, vars map[string]json.RawMessage, qcc *qcode.Compiler, pc *psql.Compiler, dbName string) (err error) {
+	t := s.timing.now()
 	if st.qc, err = qcc.Compile(
 		s.r.query,
 		vars,
@@ -186,11 +223,26 @@ func (s *gstate) compileWithCompilers(st stmt, vars map[string]json.RawMessage,
 		s.r.namespace); err != nil {
 		return
 	}
+	s.timing.add(timingCompile, t)
+
+	max, truncate := s.gj.maxRows(st.roc)
+	st.capped = limitRows(st.qc, max, truncate)
+	st.cost = s.gj.queryCost(st.qc, s.role)
+
+	// the dialect override is used to compile but never to execute a query
+	if rc := s.r.requestconfig; rc != nil && rc.Dialect != "" {
+		dbCtx, _ := s.gj.GetDatabase(dbName)
+		if pc, err = s.gj.dialectCompiler(dbCtx, rc.Dialect); err != nil {
+			return
+		}
+	}
 
+	t = s.timing.now()
 	var w bytes.Buffer
 	if st.md, err = pc.Compile(&w, st.qc); err != nil {
 		return
 	}
+	s.timing.add(timingGenerate, t)
 
 	st.sql = w.String()
 	s.database = dbName
@@ -291,13 +343,26 @@ func (s *gstate) compileAndExecuteWrapper(c context.Context) (err error) {
 				if err = s.executeParallelRoots(c); err != nil {
 					return
 				}
-				return
+				return s.checkMaxRows()
 			}
 		}
 	}
 
 	// Single database execution path (handles compilation internally)
 	if err = s.compileAndExecute(c); err != nil {
+		// Return the roots that succeed and an error for each one that
+		// failed, partial results are not cached
+		if s.canExecRootsSeparately(err) {
+			err = s.execQueryRootsSeparately(c, err)
+		}
+		return
+	}
+
+	if err = s.checkVersionConflict(); err != nil {
+		return
+	}
+
+	if err = s.checkMaxRows(); err != nil {
 		return
 	}
 
@@ -309,6 +374,8 @@ func (s *gstate) compileAndExecuteWrapper(c context.Context) (err error) {
 		return
 	}
 
+	defer s.timing.add(timingAssemble, s.timing.now())
+
 	cs := s.cs
 
 	// Handle remote joins (HTTP calls to external APIs)
@@ -325,6 +392,17 @@ func (s *gstate) compileAndExecuteWrapper(c context.Context) (err error) {
 		}
 	}
 
+	// Handle computed fields (resolved in Go from the row values)
+	if cs.st.qc.Computed != 0 {
+		if err = s.execComputedFields(c); err != nil {
+			return
+		}
+		// Don't cache responses with failed computed fields
+		if len(s.ferrs) != 0 {
+			s.skipCache = true
+		}
+	}
+
 	// Cache the response for queries, or invalidate cache for mutations
 	if s.gj.responseCache != nil {
 		if s.r.operation == qcode.QTQuery && !s.skipCache {
@@ -344,6 +422,10 @@ func (s *gstate) compileAndExecute(c context.Context) (err error) {
 			return
 		}
 
+		if err = s.checkCostBudget(); err != nil {
+			return
+		}
+
 		// set default variables
 		s.setDefaultVars()
 
@@ -355,7 +437,7 @@ func (s *gstate) compileAndExecute(c context.Context) (err error) {
 	var defaultConn *sql.Conn
 
 	// For ABAC, we need to execute role query first using default database
-	if s.role == "user" && s.gj.abacEnabled && s.tx() == nil {
+	if s.useRoleQuery() && s.tx() == nil {
 		c1, span1 := s.gj.spanStart(c, "Get Default Connection for ABAC")
 		defer span1.End()
 
@@ -379,24 +461,42 @@ func (s *gstate) compileAndExecute(c context.Context) (err error) {
 		return
 	}
 
+	if err = s.checkCostBudget(); err != nil {
+		return
+	}
+
 	// Block mutations on read-only databases (absolute, independent of roles)
-	if s.r.operation == qcode.QTMutation {
-		dbName := s.database
-		if dbName == "" {
-			dbName = s.gj.defaultDB
-		}
-		if dbConf, ok := s.gj.conf.Databases[dbName]; ok && dbConf.ReadOnly {
-			err = fmt.Errorf("mutations blocked: database %s is read-only", dbName)
-			return
-		}
+	if err = s.gj.checkReadOnlyDB(s.r.operation, s.database); err != nil {
+		return
+	}
+	if err = s.gj.checkReadOnlyMaterialize(s.cs.st.qc, s.database); err != nil {
+		return
 	}
 
 	// set default variables
 	s.setDefaultVars()
 
+	// execute query, mutations are retried when they fail with a
+	// serialization failure or a deadlock and queries when the
+	// connection to the database was lost
+	exec := func() error {
+		return s.connectAndExecute(c)
+	}
+	if s.r.operation == qcode.QTMutation {
+		err = s.constraintError(s.retryMutation(c, exec))
+	} else {
+		err = s.retryQuery(c, exec)
+	}
+	return
+}
+
+// connectAndExecute executes the query on a new connection from the target
+// database unless it's part of a transaction
+func (s *gstate) connectAndExecute(c context.Context) (err error) {
 	var conn *sql.Conn
 
-	if s.tx() == nil {
+	// queries on cached prepared statements run on the pool
+	if s.tx() == nil && s.stmtCache() == nil {
 		// get a database connection from the target database
 		c1, span1 := s.gj.spanStart(c, "Get Connection")
 		defer span1.End()
@@ -408,6 +508,7 @@ func (s *gstate) compileAndExecute(c context.Context) (err error) {
 		})
 		if err != nil {
 			span1.Error(err)
+			err = wrapDBError(err)
 			return
 		}
 		defer conn.Close() //nolint:errcheck
@@ -423,12 +524,15 @@ func (s *gstate) compileAndExecute(c context.Context) (err error) {
 		})
 		if err != nil {
 			span2.Error(err)
+			err = wrapDBError(err)
 			return
 		}
 	}
 
 	// execute query
+	t := s.timing.now()
 	err = s.execute(c, conn)
+	s.timing.add(timingExecute, t)
 	return
 }
 
@@ -438,6 +542,9 @@ func (s *gstate) setDefaultVars() {
 	}
 
 	for _, v := range s.cs.st.qc.Vars {
+		if _, ok := s.cvars[v.Name]; ok {
+			continue
+		}
 		s.vmap[v.Name] = v.Val
 	}
 }
@@ -452,6 +559,7 @@ func (s *gstate) execute(c context.Context, conn *sql.Conn) (err error) {
 	if args, err = s.argList(c); err != nil {
 		return
 	}
+	s.setDebugParams(args)
 
 	cs := s.cs
 
@@ -464,6 +572,21 @@ func (s *gstate) execute(c context.Context, conn *sql.Conn) (err error) {
 		c1, span := s.gj.spanStart(c, "Execute Script")
 		defer span.End()
 
+		tx, ownTx, err1 := s.scriptTx(c1, conn)
+		if err1 != nil {
+			span.Error(err1)
+			return wrapDBError(err1)
+		}
+		if ownTx {
+			defer func() {
+				if err != nil {
+					tx.Rollback() //nolint:errcheck
+				} else if err = tx.Commit(); err != nil {
+					err = wrapDBError(err)
+				}
+			}()
+		}
+
 		argIdx := 0
 		for i, stmt := range parts {
 			// Count parameters (?) in this statement to slice arguments
@@ -507,10 +630,10 @@ func (s *gstate) execute(c context.Context, conn *sql.Conn) (err error) {
 				// Bulk Capture Path for SQLite (handles RETURNING and SELECT)
 				var rows *sql.Rows
 				var err1 error
-				if tx := s.tx(); tx != nil {
+				if tx != nil {
 					rows, err1 = tx.QueryContext(c1, stmt, stmtArgs...)
 				} else {
-					err1 = retryOperation(c1, func() (err2 error) {
+					err1 = s.retryStatement(c1, func() (err2 error) {
 						rows, err2 = conn.QueryContext(c1, stmt, stmtArgs...)
 						return
 					})
@@ -525,7 +648,7 @@ func (s *gstate) execute(c context.Context, conn *sql.Conn) (err error) {
 					for rows.Next() {
 						var b []byte
 						if err = rows.Scan(&b); err != nil {
-							return err
+							return wrapDBError(err)
 						}
 						// b is JSON object from RETURNING json_object(...)
 
@@ -541,7 +664,7 @@ func (s *gstate) execute(c context.Context, conn *sql.Conn) (err error) {
 					}
 
 					if err = rows.Err(); err != nil {
-						return err
+						return wrapDBError(err)
 					}
 
 					// Note: We do NOT set s.data here - the final SELECT will set the response
@@ -559,7 +682,7 @@ func (s *gstate) execute(c context.Context, conn *sql.Conn) (err error) {
 						}
 						insertSQL := ib.String()
 
-						if tx := s.tx(); tx != nil {
+						if tx != nil {
 							_, err = tx.ExecContext(c1, insertSQL)
 						} else {
 							_, err = conn.ExecContext(c1, insertSQL)
@@ -569,11 +692,11 @@ func (s *gstate) execute(c context.Context, conn *sql.Conn) (err error) {
 			} else if isReturning || isSelect {
 				// Statement returns data (e.g. INSERT ... RETURNING or SELECT ...)
 				var row *sql.Row
-				if tx := s.tx(); tx != nil {
+				if tx != nil {
 					row = tx.QueryRowContext(c1, stmt, stmtArgs...)
 					err = row.Scan(&s.data)
 				} else {
-					err = retryOperation(c1, func() (err1 error) {
+					err = s.retryStatement(c1, func() (err1 error) {
 						row = conn.QueryRowContext(c1, stmt, stmtArgs...)
 						return row.Scan(&s.data)
 					})
@@ -581,10 +704,10 @@ func (s *gstate) execute(c context.Context, conn *sql.Conn) (err error) {
 
 			} else {
 				// Intermediate statement: Use Exec
-				if tx := s.tx(); tx != nil {
+				if tx != nil {
 					_, err = tx.ExecContext(c1, stmt, stmtArgs...)
 				} else {
-					err = retryOperation(c1, func() (err1 error) {
+					err = s.retryStatement(c1, func() (err1 error) {
 						_, err1 = conn.ExecContext(c1, stmt, stmtArgs...)
 						return
 					})
@@ -595,6 +718,7 @@ func (s *gstate) execute(c context.Context, conn *sql.Conn) (err error) {
 				if err != sql.ErrNoRows {
 					span.Error(err)
 				}
+				err = wrapDBError(err)
 				return
 			}
 		}
@@ -621,8 +745,12 @@ func (s *gstate) execute(c context.Context, conn *sql.Conn) (err error) {
 	if tx := s.tx(); tx != nil {
 		row = tx.QueryRowContext(c1, querySQL, queryArgs...)
 		err = row.Scan(&s.data)
+	} else if sc := s.stmtCache(); sc != nil {
+		err = s.retryStatement(c1, func() error {
+			return sc.queryRow(c1, querySQL, queryArgs, &s.data)
+		})
 	} else {
-		err = retryOperation(c1, func() (err1 error) {
+		err = s.retryStatement(c1, func() (err1 error) {
 			row = conn.QueryRowContext(c1, querySQL, queryArgs...)
 			return row.Scan(&s.data)
 		})
@@ -650,6 +778,15 @@ func (s *gstate) execute(c context.Context, conn *sql.Conn) (err error) {
 		err = nil
 	}
 	if err != nil {
+		err = wrapDBError(err)
+		return
+	}
+
+	if err = s.connectionPages(); err != nil {
+		return
+	}
+
+	if err = s.writeErrors(); err != nil {
 		return
 	}
 
@@ -721,6 +858,11 @@ func (s *gstate) validateAndUpdateVars(c context.Context) (err error) {
 			return
 		}
 	}
+
+	if s.vmap == nil {
+		s.vmap = make(map[string]json.RawMessage)
+	}
+	err = qc.ProcessLimits(s.vmap)
 	return
 }
 
@@ -745,6 +887,22 @@ func (s *gstate) qcode() (qc *qcode.QCode) {
 	return
 }
 
+// stmtCache returns the prepared statement cache of the target database if
+// the query can run on a cached prepared statement. These are prepared on the
+// pool so the query must not need a connection of its own, it can't be part
+// of a transaction or set the user id on the connection and must be a single
+// statement.
+func (s *gstate) stmtCache() *stmtCache {
+	dbCtx := s.getTargetDBCtx()
+	if dbCtx == nil || dbCtx.stmts == nil || s.tx() != nil || s.gj.conf.SetUserID {
+		return nil
+	}
+	if parts := dbCtx.psqlCompiler.GetDialect().SplitQuery(s.cs.st.sql); len(parts) > 1 {
+		return nil
+	}
+	return dbCtx.stmts
+}
+
 func (s *gstate) tx() (tx *sql.Tx) {
 	if s.r.requestconfig != nil {
 		tx = s.r.requestconfig.Tx
@@ -766,6 +924,11 @@ func (s *gstate) key() (key string) {
 	} else {
 		key = s.r.namespace + s.r.name + s.role + s.database
 	}
+	// bypassed queries can reuse the name of a query in the allow list
+	if s.r.bypass {
+		h := sha256.Sum256(s.r.query)
+		key += "#" + hex.EncodeToString(h[:])
+	}
 	return
 }
 
@@ -777,7 +940,7 @@ func (s *gstate) tryCacheGet(c context.Context) bool {
 	}
 
 	// Build cache key
-	s.cacheKey = s.gj.cacheKeyBuilder.Build(c, s.r.name, s.getAPQKey(), s.r.query, s.r.vars, s.role)
+	s.cacheKey = s.gj.cacheKeyBuilder.Build(c, s.r.name, s.getAPQKey(), s.r.query, s.cacheVars(), s.role)
 
 	// Skip if anonymous query (no operation name or APQ key)
 	if s.cacheKey == "" || !s.gj.cacheKeyBuilder.ShouldCache(s.r.name, s.getAPQKey()) {
@@ -824,6 +987,14 @@ func (s *gstate) tryCacheSet(c context.Context) {
 		return
 	}
 
+	// the max-age set with @cacheControl limits how long the response is cached
+	if qc.Cache.HasMaxAge {
+		if qc.Cache.MaxAge == 0 {
+			return
+		}
+		c = context.WithValue(c, maxAgeKey{}, time.Duration(qc.Cache.MaxAge)*time.Second)
+	}
+
 	// Process response to extract row refs and clean __gj_id fields
 	processor := NewResponseProcessor(qc)
 	cleaned, refs, err := processor.ProcessForCache(s.data)
@@ -0,0 +1,84 @@
+package core
+
+import "time"
+
+// Health reports the state of the engine for liveness and readiness probes,
+// it's read from memory and never queries the databases
+type Health struct {
+	Production      bool             `json:"production"`
+	AllowListLoaded bool             `json:"allowListLoaded"`
+	Databases       []DatabaseHealth `json:"databases"`
+}
+
+// DatabaseHealth reports the schema status of a configured database
+type DatabaseHealth struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Connected is true when the database has a connection and the last
+	// poll of its schema did not fail
+	Connected    bool      `json:"connected"`
+	SchemaLoaded bool      `json:"schemaLoaded"`
+	TableCount   int       `json:"tableCount"`
+	DiscoveredAt time.Time `json:"discoveredAt"`
+	// Error is the last schema poll or reload error, a failed reload
+	// leaves the previous schema in use
+	Error string `json:"error,omitempty"`
+}
+
+// Health returns the schema status of all configured databases
+func (g *GraphJin) Health() Health {
+	gj, err := g.getEngine()
+	if err != nil {
+		return Health{}
+	}
+
+	h := Health{
+		Production:      gj.prod,
+		AllowListLoaded: gj.allowList != nil,
+		Databases:       make([]DatabaseHealth, 0, len(gj.databases)),
+	}
+	for _, name := range gj.sortedDatabaseNames() {
+		ctx := gj.databases[name]
+		dh := DatabaseHealth{
+			Name:         name,
+			Type:         ctx.dbtype,
+			SchemaLoaded: ctx.schema != nil,
+			TableCount:   ctx.tableCount(),
+			DiscoveredAt: ctx.discoveredAt,
+		}
+		err := ctx.getErr()
+		if err != nil {
+			dh.Error = err.Error()
+		}
+		dh.Connected = ctx.db != nil && err == nil
+		h.Databases = append(h.Databases, dh)
+	}
+	return h
+}
+
+// tableCount returns the number of queryable tables in the schema
+func (ctx *dbContext) tableCount() (n int) {
+	if ctx.schema == nil {
+		return 0
+	}
+	for _, t := range ctx.schema.GetTables() {
+		if t.Type != "virtual" && !t.Blocked {
+			n++
+		}
+	}
+	return n
+}
+
+// setErr records the last schema poll or reload error of the database
+func (ctx *dbContext) setErr(err error) {
+	ctx.errMu.Lock()
+	ctx.err = err
+	ctx.errMu.Unlock()
+}
+
+// getErr returns the last schema poll or reload error of the database
+func (ctx *dbContext) getErr() error {
+	ctx.errMu.Lock()
+	defer ctx.errMu.Unlock()
+	return ctx.err
+}
@@ -0,0 +1,40 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestHealth(t *testing.T) {
+	db := newTestDB(t, "healthdb1")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := gj.Health()
+	if h.Production || !h.AllowListLoaded {
+		t.Fatalf("unexpected engine health: %+v", h)
+	}
+	if len(h.Databases) != 1 {
+		t.Fatalf("expected one database: %+v", h.Databases)
+	}
+	d := h.Databases[0]
+	if d.Type != "sqlite" || !d.Connected || !d.SchemaLoaded || d.Error != "" {
+		t.Fatalf("unexpected database health: %+v", d)
+	}
+	if d.TableCount != 2 {
+		t.Fatalf("expected 2 tables, got %d", d.TableCount)
+	}
+	if d.DiscoveredAt.IsZero() {
+		t.Fatal("expected the discovery time")
+	}
+
+	// the health of an engine that isn't initialized
+	if h := (&core.GraphJin{}).Health(); len(h.Databases) != 0 {
+		t.Fatalf("expected no databases: %+v", h)
+	}
+}
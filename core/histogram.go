@@ -0,0 +1,150 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHistogramBuckets are the upper bounds of the buckets of the query
+// duration histograms when none are set
+var DefaultHistogramBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+const (
+	// DefaultHistogramOperations is the default maximum number of
+	// operations with their own histogram
+	DefaultHistogramOperations = 100
+
+	// OtherOperation is the name of the histogram of the operations over
+	// the maximum number of operations
+	OtherOperation = "_other"
+)
+
+// Histograms keeps in memory histograms of the durations of the queries by
+// operation name, like Prometheus histograms. The number of operations is
+// bounded, the durations of the operations over the maximum are recorded
+// under OtherOperation. It is safe for concurrent use.
+type Histograms struct {
+	buckets []time.Duration
+	maxOps  int
+
+	mu  sync.Mutex
+	ops map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    time.Duration
+}
+
+// HistogramSnapshot is the state of the histogram of an operation. The
+// counts of the buckets are cumulative, the count of all the queries is
+// the count of the +Inf bucket
+type HistogramSnapshot struct {
+	Name    string
+	Buckets []HistogramBucket
+	Count   uint64
+	Sum     time.Duration
+}
+
+// HistogramBucket is the number of queries that took at most Le
+type HistogramBucket struct {
+	Le    time.Duration
+	Count uint64
+}
+
+// NewHistograms returns the histograms of the query durations with the
+// upper bounds of the buckets and the maximum number of operations, the
+// defaults are used when they are not set
+func NewHistograms(buckets []time.Duration, maxOperations int) *Histograms {
+	if len(buckets) == 0 {
+		buckets = DefaultHistogramBuckets
+	}
+	b := make([]time.Duration, len(buckets))
+	copy(b, buckets)
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+
+	if maxOperations <= 0 {
+		maxOperations = DefaultHistogramOperations
+	}
+	return &Histograms{
+		buckets: b,
+		maxOps:  maxOperations,
+		ops:     make(map[string]*histogram),
+	}
+}
+
+// OptionSetHistograms records the duration of every request in the
+// histograms, the metrics hook set with OptionSetMetrics is still called
+func OptionSetHistograms(h *Histograms) Option {
+	return func(s *graphjinEngine) error {
+		s.histograms = h
+		return nil
+	}
+}
+
+// Observe records the duration of a request, it can also be used as the
+// metrics hook
+func (h *Histograms) Observe(c context.Context, m QueryMetrics) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hg, ok := h.ops[m.Name]
+	if !ok {
+		name := m.Name
+		if len(h.ops) >= h.maxOps {
+			name = OtherOperation
+		}
+		if hg, ok = h.ops[name]; !ok {
+			hg = &histogram{counts: make([]uint64, len(h.buckets))}
+			h.ops[name] = hg
+		}
+	}
+
+	// the buckets are cumulative so the first one is enough
+	if i := sort.Search(len(h.buckets), func(i int) bool {
+		return m.Duration <= h.buckets[i]
+	}); i < len(h.buckets) {
+		hg.counts[i]++
+	}
+	hg.count++
+	hg.sum += m.Duration
+}
+
+// Snapshot returns the current histograms sorted by operation name
+func (h *Histograms) Snapshot() []HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snap := make([]HistogramSnapshot, 0, len(h.ops))
+	for name, hg := range h.ops {
+		hs := HistogramSnapshot{
+			Name:    name,
+			Buckets: make([]HistogramBucket, len(h.buckets)),
+			Count:   hg.count,
+			Sum:     hg.sum,
+		}
+		var n uint64
+		for i, le := range h.buckets {
+			n += hg.counts[i]
+			hs.Buckets[i] = HistogramBucket{Le: le, Count: n}
+		}
+		snap = append(snap, hs)
+	}
+	sort.Slice(snap, func(i, j int) bool { return snap[i].Name < snap[j].Name })
+	return snap
+}
@@ -0,0 +1,90 @@
+package core_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestHistograms(t *testing.T) {
+	db := newTestDB(t, "histogramdb1")
+
+	var calls int
+	h := core.NewHistograms(nil, 2)
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db,
+		core.OptionSetHistograms(h),
+		core.OptionSetMetrics(func(c context.Context, m core.QueryMetrics) { calls++ }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, gql := range []string{
+		`query getUsers { users { id } }`,
+		`query getUsers { users { id } }`,
+		`query getProducts { products { id } }`,
+		`query getUser { users(id: 1) { id } }`,
+		`query getProduct { products(id: 1) { id } }`,
+	} {
+		if _, err := gj.GraphQL(context.Background(), gql, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 5 {
+		t.Fatalf("expected the metrics hook to be called 5 times, got: %d", calls)
+	}
+
+	// operations over the cap are recorded under the other operation
+	snap := h.Snapshot()
+	exp := map[string]uint64{"_other": 2, "getProducts": 1, "getUsers": 2}
+	if len(snap) != len(exp) {
+		t.Fatalf("unexpected histograms: %+v", snap)
+	}
+	for _, hs := range snap {
+		if hs.Count != exp[hs.Name] || hs.Sum <= 0 {
+			t.Fatalf("unexpected histogram: %+v", hs)
+		}
+		if n := len(hs.Buckets); n != len(core.DefaultHistogramBuckets) ||
+			hs.Buckets[n-1].Count > hs.Count {
+			t.Fatalf("unexpected buckets: %+v", hs.Buckets)
+		}
+	}
+}
+
+func TestHistogramsBuckets(t *testing.T) {
+	h := core.NewHistograms([]time.Duration{time.Second, 10 * time.Millisecond}, 0)
+
+	var wg sync.WaitGroup
+	for _, d := range []time.Duration{time.Millisecond, 20 * time.Millisecond, 2 * time.Second} {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(d time.Duration) {
+				defer wg.Done()
+				h.Observe(context.Background(), core.QueryMetrics{Name: "getUsers", Duration: d})
+			}(d)
+		}
+	}
+	wg.Wait()
+
+	snap := h.Snapshot()
+	if len(snap) != 1 {
+		t.Fatalf("unexpected histograms: %+v", snap)
+	}
+	hs := snap[0]
+	if hs.Count != 30 || hs.Sum != 10*(time.Millisecond+20*time.Millisecond+2*time.Second) {
+		t.Fatalf("unexpected count or sum: %+v", hs)
+	}
+	// the buckets are sorted and cumulative
+	exp := []core.HistogramBucket{
+		{Le: 10 * time.Millisecond, Count: 10},
+		{Le: time.Second, Count: 20},
+	}
+	for i, b := range exp {
+		if hs.Buckets[i] != b {
+			t.Fatalf("expected buckets: %+v, got: %+v", exp, hs.Buckets)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package core_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestIdentifierCase(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		gql    string
+		exp    string
+	}{
+		{
+			name:   "snake",
+			policy: "snake",
+			gql:    `query { users(where: { id: 1 }) { id fullName } }`,
+			exp:    `{"users":[{"id":1,"fullName":"User One"}]}`,
+		},
+		{
+			name:   "lower",
+			policy: "lower",
+			gql:    `query { USERS(where: { ID: 2 }, order_by: { ID: asc }) { ID EMAIL } }`,
+			exp:    `{"USERS":[{"ID":2,"EMAIL":"user2@test.com"}]}`,
+		},
+		{
+			name:   "preserve",
+			policy: "preserve",
+			gql:    `query { users(where: { id: 1 }) { id full_name } }`,
+			exp:    `{"users":[{"id":1,"full_name":"User One"}]}`,
+		},
+		{
+			name:   "snake with alias",
+			policy: "snake",
+			gql:    `query { users(where: { id: 1 }) { id name: fullName } }`,
+			exp:    `{"users":[{"id":1,"name":"User One"}]}`,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, "identcasedb"+string(rune('a'+i)))
+
+			conf := &core.Config{DBType: "sqlite", DisableAllowList: true, IdentifierCase: tt.policy}
+			gj, err := core.NewGraphJin(conf, db)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := gj.GraphQL(context.Background(), tt.gql, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(res.Data); got != tt.exp {
+				t.Fatalf("expected: %s, got: %s", tt.exp, got)
+			}
+		})
+	}
+}
+
+func TestIdentifierCasePreserveCamelName(t *testing.T) {
+	db := newTestDB(t, "identcasedb_preserve")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true, IdentifierCase: "preserve"}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// camel case names are not converted when the case is preserved
+	_, err = gj.GraphQL(context.Background(), `query { users { id fullName } }`, nil, nil)
+	if err == nil {
+		t.Fatal("expected an error for the unknown column")
+	}
+}
+
+func TestIdentifierCaseInvalid(t *testing.T) {
+	db := newTestDB(t, "identcasedb_invalid")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true, IdentifierCase: "upper"}
+	if _, err := core.NewGraphJin(conf, db); err == nil {
+		t.Fatal("expected an error for an invalid identifier case")
+	}
+}
@@ -1,7 +1,10 @@
 package core
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"unicode"
 
@@ -105,13 +108,160 @@ func (gj *graphjinEngine) addTableInfo(t Table) error {
 			obm[k] = append(obm[k], [2]string{vals[0], vals[1]})
 		}
 	}
+	var ccm map[string]string
+	for _, c := range t.Columns {
+		if c.Collation == "" {
+			continue
+		}
+		if ccm == nil {
+			ccm = make(map[string]string)
+		}
+		ccm[c.Name] = c.Collation
+	}
+
 	if gj.tmap == nil {
 		gj.tmap = make(map[string]qcode.TConfig)
 	}
-	gj.tmap[(t.Schema + t.Name)] = qcode.TConfig{OrderBy: obm}
+	tc := qcode.TConfig{
+		OrderBy:          obm,
+		VersionColumn:    t.VersionColumn,
+		SoftDeleteColumn: t.SoftDeleteColumn,
+		Collation:        t.Collation,
+		ColCollation:     ccm,
+		DefaultLimit:     int32(t.DefaultLimit),
+		MaxLimit:         int32(t.MaxLimit),
+		MaxLimitError:    t.MaxLimitError,
+	}
+
+	if t.Type == "static" {
+		rows := t.Rows
+		if rows == nil {
+			rows = []map[string]interface{}{}
+		}
+		b, err := json.Marshal(rows)
+		if err != nil {
+			return fmt.Errorf("static table: %s: %w", t.Name, err)
+		}
+		tc.StaticRows = b
+	}
+
+	if len(t.Default) != 0 {
+		tc.Default = make(map[string]json.RawMessage, len(t.Default))
+		for k, v := range t.Default {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("default: %s.%s: %w", t.Name, k, err)
+			}
+			tc.Default[k] = b
+		}
+	}
+
+	for _, c := range t.Columns {
+		if !c.Deprecated && c.DeprecationReason == "" {
+			continue
+		}
+		if tc.Deprecated == nil {
+			tc.Deprecated = make(map[string]string)
+		}
+		tc.Deprecated[c.Name] = c.DeprecationReason
+		if c.DeprecationReason == "" {
+			tc.Deprecated[c.Name] = defaultDeprecationReason
+		}
+	}
+
+	for _, c := range t.Columns {
+		if c.Compare == nil {
+			continue
+		}
+		tcmp, err := newTCompare(*c.Compare)
+		if err != nil {
+			return fmt.Errorf("compare: %s.%s: %w", t.Name, c.Name, err)
+		}
+		if tc.Compare == nil {
+			tc.Compare = make(map[string]qcode.TCompare)
+		}
+		tc.Compare[c.Name] = tcmp
+	}
+
+	for _, c := range t.Columns {
+		if len(c.Labels) == 0 {
+			continue
+		}
+		l, err := newLabels(c)
+		if err != nil {
+			return fmt.Errorf("labels: %s.%s: %w", t.Name, c.Name, err)
+		}
+		if tc.Labels == nil {
+			tc.Labels = make(map[string]qcode.Labels)
+		}
+		tc.Labels[c.Name] = l
+	}
+
+	ses, err := newSideEffects(t)
+	if err != nil {
+		return err
+	}
+	tc.SideEffects = ses
+
+	gj.tmap[(t.Schema + t.Name)] = tc
 	return nil
 }
 
+// newLabels returns the labels of the values of a column sorted by value,
+// keys that are json numbers match numeric values and the others strings
+func newLabels(c Column) (l qcode.Labels, err error) {
+	keys := make([]string, 0, len(c.Labels))
+	for k := range c.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var val json.RawMessage
+		if k != "" && (k[0] == '-' || unicode.IsDigit(rune(k[0]))) && json.Valid([]byte(k)) {
+			val = json.RawMessage(k)
+		} else if val, err = json.Marshal(k); err != nil {
+			return
+		}
+		l.Cases = append(l.Cases, qcode.Label{Val: val, Label: c.Labels[k]})
+	}
+
+	l.Default = json.RawMessage(`null`)
+	if c.LabelDefault != "" {
+		if l.Default, err = json.Marshal(c.LabelDefault); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// newTCompare validates the comparison of a computed boolean column
+func newTCompare(c Compare) (qcode.TCompare, error) {
+	tc := qcode.TCompare{Col: c.Column, Op: c.Op, ValCol: c.ValueColumn}
+
+	switch c.Op {
+	case "eq", "neq", "gt", "gte", "lt", "lte":
+	default:
+		return tc, fmt.Errorf("invalid operator '%s'", c.Op)
+	}
+	if c.Column == "" {
+		return tc, errors.New("column is required")
+	}
+	if c.ValueColumn != "" {
+		if c.Value != nil {
+			return tc, errors.New("only one of value and value_column can be set")
+		}
+		return tc, nil
+	}
+
+	b, err := json.Marshal(c.Value)
+	if err != nil {
+		return tc, err
+	}
+	tc.Val = b
+	return tc, nil
+}
+
 // getDBTableAliases returns a map of table aliases
 func getDBTableAliases(c *Config) map[string][]string {
 	m := make(map[string][]string, len(c.Tables))
@@ -149,6 +299,9 @@ func addTables(conf *Config, dbInfo *sdata.DBInfo, targetDB string) error {
 		case "polymorphic":
 			err = addVirtualTable(conf, dbInfo, t)
 
+		case "static":
+			err = addStaticTable(conf, dbInfo, t)
+
 		default:
 			err = updateTable(conf, dbInfo, t)
 		}
@@ -175,6 +328,19 @@ func updateTable(conf *Config, dbInfo *sdata.DBInfo, table Table) error {
 	}
 
 	for _, c := range table.Columns {
+		// computed boolean columns are not stored in the database
+		if c.Compare != nil {
+			if dbInfo.Type != "mongodb" {
+				return fmt.Errorf("compare: '%s.%s' is only supported on mongodb",
+					table.Name, c.Name)
+			}
+			if _, ok := t1.ColumnExists(c.Name); ok {
+				return fmt.Errorf("compare: '%s.%s' is a column of the table",
+					table.Name, c.Name)
+			}
+			continue
+		}
+
 		c1, err := dbInfo.GetColumn(schema, table.Name, c.Name)
 		if err != nil {
 			return err
@@ -188,9 +354,26 @@ func updateTable(conf *Config, dbInfo *sdata.DBInfo, table Table) error {
 		if c.Array {
 			c1.Array = true
 		}
+
+		if c.Default != nil {
+			if dbInfo.Type != "mongodb" {
+				return fmt.Errorf("default: '%s.%s' is only supported on mongodb",
+					table.Name, c.Name)
+			}
+			b, err := json.Marshal(c.Default)
+			if err != nil {
+				return fmt.Errorf("default: '%s.%s': %w", table.Name, c.Name, err)
+			}
+			c1.Default = string(b)
+		}
+
+		if len(c.Labels) != 0 && dbInfo.Type != "mongodb" {
+			return fmt.Errorf("labels: '%s.%s' are only supported on mongodb",
+				table.Name, c.Name)
+		}
 	}
 
-	return nil
+	return checkSideEffects(dbInfo, t1, table)
 }
 
 // addJsonTable adds a json table to the database info
@@ -256,6 +439,62 @@ func addJsonTable(conf *Config, dbInfo *sdata.DBInfo, table Table) error {
 	return nil
 }
 
+// addStaticTable adds a lookup table whose rows are defined in the config
+func addStaticTable(conf *Config, dbInfo *sdata.DBInfo, table Table) error {
+	if dbInfo.Type != "mongodb" {
+		return fmt.Errorf("static table: '%s' is only supported on mongodb", table.Name)
+	}
+	if len(table.Columns) == 0 {
+		return fmt.Errorf("static table: no columns defined for '%s'", table.Name)
+	}
+
+	schema := table.Schema
+	if schema == "" {
+		schema = dbInfo.Schema
+	}
+
+	columns := make([]sdata.DBColumn, 0, len(table.Columns))
+	for i, c := range table.Columns {
+		if c.Type == "" {
+			return fmt.Errorf("static table: type parameter missing for column: %s.%s",
+				table.Name, c.Name)
+		}
+		columns = append(columns, sdata.DBColumn{
+			ID:         -1,
+			Name:       c.Name,
+			Type:       c.Type,
+			Array:      c.Array,
+			PrimaryKey: c.Primary || (i == 0 && c.Name == "id"),
+		})
+	}
+
+	for i, row := range table.Rows {
+		for k := range row {
+			if _, ok := table.getColumn(k); !ok {
+				return fmt.Errorf("static table: row %d of '%s' has unknown column '%s'",
+					i, table.Name, k)
+			}
+		}
+	}
+
+	nt := sdata.NewDBTable(schema, table.Name, "static", columns)
+	if nt.PrimaryCol.Name == "" {
+		return fmt.Errorf("static table: no primary key column for '%s'", table.Name)
+	}
+	dbInfo.AddTable(nt)
+	return nil
+}
+
+// getColumn returns the column config with the name
+func (t Table) getColumn(name string) (Column, bool) {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
 // addVirtualTable adds a virtual table to the database info
 func addVirtualTable(conf *Config, di *sdata.DBInfo, t Table) error {
 	if len(t.Columns) == 0 {
@@ -361,6 +600,63 @@ func addForeignKey(conf *Config, di *sdata.DBInfo, c Column, t Table) error {
 	return nil
 }
 
+// addArraySides sets which side of an array relationship holds the array of
+// ids, overriding the side inferred from the columns
+func (gj *graphjinEngine) addArraySides(di *sdata.DBInfo, targetDB string) error {
+	for _, t := range gj.conf.Tables {
+		if t.Database != targetDB {
+			continue
+		}
+		for _, c := range t.Columns {
+			if c.ArraySide == "" {
+				continue
+			}
+			switch {
+			case c.ArraySide != "column" && c.ArraySide != "related" && c.ArraySide != "none":
+				return fmt.Errorf("array_side: '%s.%s' must be one of column, related or none: %s",
+					t.Name, c.Name, c.ArraySide)
+			case c.ForeignKey == "":
+				return fmt.Errorf("array_side: '%s.%s' requires related_to",
+					t.Name, c.Name)
+			case di.Type != "mongodb":
+				return fmt.Errorf("array_side: '%s.%s' is only supported on mongodb",
+					t.Name, c.Name)
+			}
+
+			schema := t.Schema
+			if schema == "" {
+				schema = di.Schema
+			}
+			c1, err := di.GetColumn(schema, t.Name, c.Name)
+			if err != nil {
+				return fmt.Errorf("array_side: %w", err)
+			}
+
+			var related bool
+			if c2, err := di.GetColumn(c1.FKeySchema, c1.FKeyTable, c1.FKeyCol); err == nil {
+				related = c2.Array
+			}
+
+			var inferred string
+			switch {
+			case c1.Array:
+				inferred = "column"
+			case related:
+				inferred = "related"
+			default:
+				inferred = "none"
+			}
+
+			if c.ArraySide != inferred {
+				gj.log.Printf("array_side: '%s.%s' overrides the inferred '%s' with '%s'",
+					t.Name, c.Name, inferred, c.ArraySide)
+			}
+			c1.FKArraySide = c.ArraySide
+		}
+	}
+	return nil
+}
+
 // addFullTextColumns applies full-text search configuration to database columns
 // targetDB is the database name to process (after normalization, all tables have Database set)
 func addFullTextColumns(conf *Config, di *sdata.DBInfo, targetDB string) error {
@@ -394,6 +690,44 @@ func addFullTextColumns(conf *Config, di *sdata.DBInfo, targetDB string) error {
 	return nil
 }
 
+// addObjectIDColumns marks the id columns of the tables with ObjectId ids
+// and the columns that are foreign keys to them
+// targetDB is the database name to process (after normalization, all tables have Database set)
+func addObjectIDColumns(conf *Config, di *sdata.DBInfo, targetDB string) error {
+	for _, t := range conf.Tables {
+		if t.Database != targetDB || !t.ObjectID {
+			continue
+		}
+		schema := t.Schema
+		if schema == "" {
+			schema = di.Schema
+		}
+		ti, err := di.GetTable(schema, t.Name)
+		if err != nil {
+			return fmt.Errorf("config: object_id: %w", err)
+		}
+		for i := range ti.Columns {
+			if c := &ti.Columns[i]; c.PrimaryKey || c.Name == "id" || c.Name == "_id" {
+				c.ObjectID = true
+			}
+		}
+		if ti.PrimaryCol.Name != "" {
+			ti.PrimaryCol.ObjectID = true
+		}
+
+		for i := range di.Tables {
+			for j := range di.Tables[i].Columns {
+				c := &di.Tables[i].Columns[j]
+				if c.FKeyTable == t.Name && (c.FKeySchema == "" || c.FKeySchema == schema) &&
+					(c.FKeyCol == "id" || c.FKeyCol == "_id") {
+					c.ObjectID = true
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // addFunctions updates function configurations in the database info
 func addFunctions(conf *Config, di *sdata.DBInfo) error {
 	for _, f := range conf.Functions {
@@ -440,8 +774,10 @@ func addRole(qc *qcode.Compiler, r Role, t RoleTable, defaultBlock bool) error {
 			Limit:            t.Query.Limit,
 			Filters:          t.Query.Filters,
 			Columns:          t.Query.Columns,
+			Masks:            t.Query.Masks,
 			DisableFunctions: t.Query.DisableFunctions,
 			Block:            t.Query.Block,
+			Materialize:      t.Query.Materialize,
 		}
 	}
 
@@ -455,10 +791,11 @@ func addRole(qc *qcode.Compiler, r Role, t RoleTable, defaultBlock bool) error {
 
 	if t.Update != nil {
 		update = qcode.UpdateConfig{
-			Filters: t.Update.Filters,
-			Columns: t.Update.Columns,
-			Presets: t.Update.Presets,
-			Block:   t.Update.Block,
+			Filters:  t.Update.Filters,
+			Columns:  t.Update.Columns,
+			Presets:  t.Update.Presets,
+			Block:    t.Update.Block,
+			AllowAll: t.Update.AllowAll,
 		}
 	}
 
@@ -473,9 +810,10 @@ func addRole(qc *qcode.Compiler, r Role, t RoleTable, defaultBlock bool) error {
 
 	if t.Delete != nil {
 		del = qcode.DeleteConfig{
-			Filters: t.Delete.Filters,
-			Columns: t.Delete.Columns,
-			Block:   t.Delete.Block,
+			Filters:  t.Delete.Filters,
+			Columns:  t.Delete.Columns,
+			Block:    t.Delete.Block,
+			AllowAll: t.Delete.AllowAll,
 		}
 	}
 
@@ -20,6 +20,9 @@ func (gj *graphjinEngine) discoverAllDatabases() error {
 		if err := gj.discoverDatabase(ctx); err != nil {
 			return err
 		}
+		if ctx.dbinfo != nil {
+			ctx.discoveredAt = time.Now()
+		}
 	}
 	return nil
 }
@@ -173,6 +176,16 @@ func (gj *graphjinEngine) finalizeDatabaseSchema(ctx *dbContext) error {
 		return fmt.Errorf("database %s: add foreign keys failed: %w", ctx.name, err)
 	}
 
+	// Apply the array side configured for the foreign keys of this database
+	if err := gj.addArraySides(ctx.dbinfo, ctx.name); err != nil {
+		return fmt.Errorf("database %s: add array sides failed: %w", ctx.name, err)
+	}
+
+	// Mark the ObjectId columns of the tables configured for this database
+	if err := addObjectIDColumns(gj.conf, ctx.dbinfo, ctx.name); err != nil {
+		return fmt.Errorf("database %s: add object id columns failed: %w", ctx.name, err)
+	}
+
 	// Process full-text search configuration for this database
 	if err := addFullTextColumns(gj.conf, ctx.dbinfo, ctx.name); err != nil {
 		return fmt.Errorf("database %s: add fulltext columns failed: %w", ctx.name, err)
@@ -198,9 +211,25 @@ func (gj *graphjinEngine) finalizeDatabaseSchema(ctx *dbContext) error {
 		DisableAgg:          gj.conf.DisableAgg,
 		DisableFuncs:        gj.conf.DisableFuncs,
 		EnableCamelcase:     gj.conf.EnableCamelcase,
+		IdentifierCase:      gj.identifierCase(),
+		OutputCase:          gj.conf.OutputCase,
 		DBSchema:            ctx.schema.DBSchema(),
 		EnableCacheTracking: gj.conf.CacheTrackingEnabled,
+		Computed:            gj.computedDeps(),
+		Directives:          gj.directiveFns(),
+		MaxRelationships:    gj.conf.MaxRelationshipsPerQuery,
+		EmptyMutations:      gj.conf.EmptyMutations,
+	}
+	for _, r := range gj.conf.Roles {
+		if r.MaxRelationshipsPerQuery > 0 {
+			if qcc.RoleMaxRelationships == nil {
+				qcc.RoleMaxRelationships = make(map[string]int)
+			}
+			qcc.RoleMaxRelationships[r.Name] = r.MaxRelationshipsPerQuery
+		}
 	}
+	gj.setUnknownFields(&qcc)
+	gj.setRoleTables(&qcc)
 
 	ctx.qcodeCompiler, err = qcode.NewCompiler(ctx.schema, qcc)
 	if err != nil {
@@ -214,14 +243,19 @@ func (gj *graphjinEngine) finalizeDatabaseSchema(ctx *dbContext) error {
 
 	// Create SQL compiler for this database's dialect
 	ctx.psqlCompiler = psql.NewCompiler(psql.Config{
-		Vars:            gj.conf.Vars,
-		DBType:          ctx.schema.DBType(),
-		DBVersion:       ctx.schema.DBVersion(),
-		SecPrefix:       gj.printFormat,
-		EnableCamelcase: gj.conf.EnableCamelcase,
+		Vars:                gj.conf.Vars,
+		DBType:              ctx.schema.DBType(),
+		DBVersion:           ctx.schema.DBVersion(),
+		SecPrefix:           gj.printFormat,
+		EnableCamelcase:     gj.conf.EnableCamelcase,
+		QueryComment:        gj.conf.QueryComment,
+		DisableSortTieBreak: gj.conf.DisableSortTieBreak,
 	})
 	ctx.psqlCompiler.SetSchemaInfo(ctx.schema.GetTables())
 
+	if err := gj.initStmtCache(ctx); err != nil {
+		return fmt.Errorf("database %s: prepared statements: %w", ctx.name, err)
+	}
 	return nil
 }
 
@@ -229,9 +263,10 @@ func (gj *graphjinEngine) finalizeDatabaseSchema(ctx *dbContext) error {
 // This is used by AddDatabase after GraphJin is already running.
 func (gj *graphjinEngine) initDBContext(name string, db *sql.DB, dbConf DatabaseConfig) (*dbContext, error) {
 	ctx := &dbContext{
-		name:   name,
-		db:     db,
-		dbtype: dbConf.Type,
+		name:     name,
+		db:       db,
+		dbtype:   dbConf.Type,
+		readOnly: dbConf.ReadOnly,
 	}
 
 	if err := gj.discoverDatabase(ctx); err != nil {
@@ -285,6 +320,9 @@ func (gj *graphjinEngine) RemoveDatabase(name string) error {
 		return fmt.Errorf("cannot remove default database %s", name)
 	}
 
+	if ctx := gj.databases[name]; ctx.stmts != nil {
+		ctx.stmts.purge()
+	}
 	delete(gj.databases, name)
 	return nil
 }
@@ -393,10 +431,11 @@ func OptionSetDatabases(connections map[string]*sql.DB) Option {
 
 			// Store bare context — full init happens later
 			gj.databases[name] = &dbContext{
-				name:    name,
-				db:      db,
-				dbtype:  dbConf.Type,
-				schemas: []string{dbConf.Schema},
+				name:     name,
+				db:       db,
+				dbtype:   dbConf.Type,
+				schemas:  []string{dbConf.Schema},
+				readOnly: dbConf.ReadOnly,
 			}
 		}
 
@@ -33,6 +33,9 @@ type Item struct {
 	ActionJSON map[string]json.RawMessage
 	Query      []byte
 	Fragments  []Fragment
+	// Defaults are the values of the variables the client does not set,
+	// stored in the defaults folder next to the query
+	Defaults map[string]json.RawMessage
 }
 
 type Fragment struct {
@@ -168,6 +171,10 @@ func (al *List) get(queryPath, name, ext string, useCache bool) (item Item, err
 		}
 	}
 
+	if item.Defaults, err = readDefaults(al.fs, filepath.Join(queryPath, "defaults", (name+".json"))); err != nil {
+		return
+	}
+
 	if useCache {
 		al.cache.Add(name, item)
 	}
@@ -236,6 +243,35 @@ func (al *List) saveItem(item Item) (err error) {
 			return
 		}
 		err = al.fs.Put(jf, vars)
+		if err != nil {
+			return
+		}
+	}
+
+	if len(item.Defaults) != 0 {
+		var defaults []byte
+		df := filepath.Join(QUERY_PATH, "defaults", (queryFile + ".json"))
+		defaults, err = json.MarshalIndent(item.Defaults, "", "  ")
+		if err != nil {
+			return
+		}
+		err = al.fs.Put(df, defaults)
+	}
+	return
+}
+
+// readDefaults reads the default values of the variables of a query
+func readDefaults(fs FS, fp string) (defaults map[string]json.RawMessage, err error) {
+	ok, err := fs.Exists(fp)
+	if !ok || err != nil {
+		return
+	}
+	b, err := fs.Get(fp)
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(b, &defaults); err != nil {
+		err = fmt.Errorf("variable defaults: %s: %w", fp, err)
 	}
 	return
 }
@@ -1,7 +1,17 @@
 package dialect
 
 import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"syscall"
 	"github.com/dosco/graphjin/core/v3/internal/qcode"
 	"github.com/dosco/graphjin/core/v3/internal/sdata"
 )
@@ -119,6 +129,7 @@ type Dialect interface {
 	RenderAssign(ctx Context, col string, val string)
 	RenderCast(ctx Context, val func(), typ string)
 	RenderTryCast(ctx Context, val func(), typ string)
+	RenderMask(ctx Context, m qcode.Mask, col func())
 	
 	RenderSubscriptionUnbox(ctx Context, params []Param, innerSQL string)
 
@@ -140,6 +151,7 @@ type Dialect interface {
 
 	ModifySelectsForMutation(qc *qcode.QCode)
 	RenderQueryPrefix(ctx Context, qc *qcode.QCode)
+	RenderQueryComment(ctx Context, comment string) // sqlcommenter comment identifying the query
 	SplitQuery(query string) []string
 
 	// Role Statement rendering (moves db-specific code from core/rolestmt.go)
@@ -153,6 +165,11 @@ type Dialect interface {
 	RequiresJSONAsString() bool          // Oracle/MSSQL need JSON as string
 	RequiresLowercaseIdentifiers() bool  // Oracle needs lowercase schemas
 	RequiresBooleanAsInt() bool          // Oracle needs bool as 1/0 (PL/SQL BOOLEAN can't be used in SQL)
+	IsRetryableError(err error) bool     // Serialization failures and deadlocks that can be retried
+	IsConnectionError(err error) bool    // Lost connections that can be retried on a new connection
+
+	// ConstraintViolation parses unique, foreign key, check and not null violations
+	ConstraintViolation(err error) (ConstraintViolation, bool)
 
 	// Recursive CTE Syntax (moves db-specific code from psql/recur.go)
 	RequiresRecursiveKeyword() bool      // Oracle doesn't use RECURSIVE
@@ -243,3 +260,188 @@ func GenericRenderMutationPostamble(ctx Context, qc *qcode.QCode) {
 }
 
 
+
+// renderMaskText writes the text used to mask a column as a string literal
+func renderMaskText(ctx Context, text string) {
+	ctx.WriteString(`'`)
+	ctx.WriteString(strings.ReplaceAll(text, `'`, `''`))
+	ctx.WriteString(`'`)
+}
+
+// sqlStateError is implemented by driver errors that expose their SQLSTATE code
+type sqlStateError interface {
+	SQLState() string
+}
+
+// errorSQLState returns the SQLSTATE code of a driver error if it has one
+func errorSQLState(err error) string {
+	var e sqlStateError
+	if errors.As(err, &e) {
+		return e.SQLState()
+	}
+	return ""
+}
+
+// errorContains returns true if the error message contains any of the values,
+// used for drivers that only return the error code in the message
+func errorContains(err error, values ...string) bool {
+	msg := err.Error()
+	for _, v := range values {
+		if strings.Contains(msg, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNetworkError returns true when the connection to the database was lost
+// or could not be made, timeouts are not included since retrying a query
+// that timed out is unlikely to help
+func isNetworkError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		return !ne.Timeout()
+	}
+	return false
+}
+
+// ConstraintViolation is a constraint of the database violated by a statement,
+// the constraint and the columns are only set when the database reports them
+type ConstraintViolation struct {
+	// Kind is unique, foreign_key, check or not_null
+	Kind       string
+	Constraint string
+	Columns    []string
+}
+
+// errorField returns the value of the first string field with one of the
+// names in the driver errors of the chain, used for the details drivers
+// only expose as fields like the constraint name of a Postgres error
+func errorField(err error, names ...string) string {
+	for ; err != nil; err = errors.Unwrap(err) {
+		v := reflect.ValueOf(err)
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			continue
+		}
+		for _, n := range names {
+			if f := v.FieldByName(n); f.IsValid() && f.Kind() == reflect.String && f.String() != "" {
+				return f.String()
+			}
+		}
+	}
+	return ""
+}
+
+// between returns the text following the first prefix in the message up
+// to the end
+func between(msg, prefix, end string) string {
+	i := strings.Index(msg, prefix)
+	if i == -1 {
+		return ""
+	}
+	msg = msg[i+len(prefix):]
+	if j := strings.Index(msg, end); j != -1 {
+		return msg[:j]
+	}
+	return ""
+}
+
+// splitColumns splits a list of column names, the quotes and the table
+// prefix are removed: "users"."email", `name` becomes email, name
+func splitColumns(s string) []string {
+	var cols []string
+	for _, c := range strings.Split(s, ",") {
+		c = strings.TrimSpace(c)
+		if i := strings.LastIndexByte(c, '.'); i != -1 {
+			c = c[i+1:]
+		}
+		if c = strings.Trim(c, "\"`'[] "); c != "" {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// conflictTargetFilter returns the filter selecting the rows of a bulk upsert
+// with a JSON input, the inserted and the updated rows are found by the values
+// of the target columns in the input
+func conflictTargetFilter(qc *qcode.QCode, m *qcode.Mutate) *qcode.Exp {
+	var exps []*qcode.Exp
+	for _, tc := range m.OnConflict.Target {
+		key := tc.Name
+		for _, mc := range m.Cols {
+			if mc.Col.Name == tc.Name && mc.FieldName != "" {
+				key = mc.FieldName
+			}
+		}
+		ex := &qcode.Exp{Op: qcode.OpIn}
+		ex.Left.Col = tc
+		ex.Left.Col.Table = m.Ti.Name
+		ex.Left.ID = -1
+		ex.Right.ValType = qcode.ValVar
+		ex.Right.Val = fmt.Sprintf("__gj_json_pk:gj_sep:%s:gj_sep:%s:gj_sep:%s", qc.ActionVar, key, tc.Type)
+		exps = append(exps, ex)
+	}
+	if len(exps) == 1 {
+		return exps[0]
+	}
+	return &qcode.Exp{Op: qcode.OpAnd, Children: exps}
+}
+
+// hasColumn returns true if a column of the name is in the list
+func hasColumn(cols []sdata.DBColumn, name string) bool {
+	for _, col := range cols {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryCommentText returns the sqlcommenter key values of the comment added
+// to a query. With identity the namespace and a hash of the query are added
+// so the load on the database can be attributed to the operation. The values
+// are url encoded, a value can't close the comment or the quotes around it.
+func QueryCommentText(qc *qcode.QCode, identity bool) string {
+	var sb strings.Builder
+	writeCommentAttr(&sb, "action", qc.Name)
+	writeCommentAttr(&sb, "controller", "graphql")
+	writeCommentAttr(&sb, "framework", "graphjin")
+	if identity {
+		if qc.Namespace != "" {
+			writeCommentAttr(&sb, "namespace", qc.Namespace)
+		}
+		h := sha256.Sum256(qc.Query)
+		writeCommentAttr(&sb, "query_hash", hex.EncodeToString(h[:8]))
+	}
+	return sb.String()
+}
+
+func writeCommentAttr(sb *strings.Builder, key, val string) {
+	if sb.Len() != 0 {
+		sb.WriteString(`,`)
+	}
+	sb.WriteString(key)
+	sb.WriteString(`='`)
+	sb.WriteString(strings.ReplaceAll(url.QueryEscape(val), "+", "%20"))
+	sb.WriteString(`'`)
+}
+
+// renderSQLComment renders the comment before the statement
+func renderSQLComment(ctx Context, comment string) {
+	ctx.WriteString(`/* `)
+	ctx.WriteString(comment)
+	ctx.WriteString(` */ `)
+}
@@ -517,11 +517,12 @@ func (d *MariaDBDialect) renderInlineJSONFields(ctx Context, r InlineChildRender
 			}
 			// For JSON columns, wrap with JSON_QUERY to preserve JSON structure
 			// MariaDB stores JSON as LONGTEXT, so without this, JSON values get stringified
-			isJSON := f.Col.Type == "json" || f.Col.Array
+			isJSON := (f.Col.Type == "json" || f.Col.Array) &&
+				f.Mask.Type == qcode.MaskTypeNone
 			if isJSON {
 				ctx.WriteString(`JSON_QUERY(`)
 			}
-			r.ColWithTable(t, f.Col.Name)
+			d.RenderMask(ctx, f.Mask, func() { r.ColWithTable(t, f.Col.Name) })
 			if isJSON {
 				ctx.WriteString(`, '$')`)
 			}
@@ -587,7 +588,8 @@ func (d *MariaDBDialect) renderSubqueryJSONFields(ctx Context, r InlineChildRend
 		}
 
 		// For JSON columns, wrap with JSON_QUERY to preserve JSON structure
-		isJSON := f.Col.Type == "json" || f.Col.Array
+		isJSON := (f.Col.Type == "json" || f.Col.Array) &&
+			f.Mask.Type == qcode.MaskTypeNone
 		if isJSON {
 			ctx.WriteString(`JSON_QUERY(`)
 		}
@@ -668,7 +670,7 @@ func (d *MariaDBDialect) renderBaseColumns(ctx Context, r InlineChildRenderer, s
 			}
 			ctx.WriteString(`)`)
 		} else {
-			r.ColWithTable(t, f.Col.Name)
+			d.RenderMask(ctx, f.Mask, func() { r.ColWithTable(t, f.Col.Name) })
 		}
 
 		if f.FieldFilter.Exp != nil {
@@ -2132,8 +2134,10 @@ func (d *MariaDBDialect) renderRecursiveJSONFields(ctx Context, r InlineChildRen
 			d.renderFunctionForRecursive(ctx, f)
 		default:
 			// Regular column
-			ctx.WriteString(`t.`)
-			ctx.Quote(f.Col.Name)
+			d.RenderMask(ctx, f.Mask, func() {
+				ctx.WriteString(`t.`)
+				ctx.Quote(f.Col.Name)
+			})
 		}
 		i++
 	}
@@ -1,6 +1,7 @@
 package dialect
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -14,9 +15,14 @@ import (
 // The JSON is parsed and executed by the mongodriver package.
 type MongoDBDialect struct {
 	EnableCamelcase bool
-	pipelineDepth   int
-	inPipeline      bool
-	paramIndex      int
+	// QueryComment adds a comment identifying the query to the aggregates
+	QueryComment bool
+	// DisableSortTieBreak stops _id being added as the last sort key of
+	// orderings that are not unique
+	DisableSortTieBreak bool
+	pipelineDepth       int
+	inPipeline          bool
+	paramIndex          int
 }
 
 func (d *MongoDBDialect) Name() string {
@@ -135,9 +141,9 @@ func (d *MongoDBDialect) renderGroupStage(ctx Context, sel *qcode.Select) {
 		case "count":
 			ctx.WriteString(`{"$sum":1}`)
 		case "sum":
-			d.renderAggOp(ctx, "$sum", f.Args)
+			d.renderDecimalAggOp(ctx, "$sum", f.Args)
 		case "avg":
-			d.renderAggOp(ctx, "$avg", f.Args)
+			d.renderDecimalAggOp(ctx, "$avg", f.Args)
 		case "max":
 			d.renderAggOp(ctx, "$max", f.Args)
 		case "min":
@@ -178,6 +184,29 @@ func (d *MongoDBDialect) renderAggOp(ctx Context, op string, args []qcode.Arg) {
 	ctx.WriteString(`"}`)
 }
 
+// renderDecimalAggOp renders a sum or average, on decimal columns the values
+// are converted with $toDecimal so the result stays a Decimal128 and is exact
+func (d *MongoDBDialect) renderDecimalAggOp(ctx Context, op string, args []qcode.Arg) {
+	if len(args) == 0 || !isDecimalType(args[0].Col.Type) {
+		d.renderAggOp(ctx, op, args)
+		return
+	}
+	ctx.WriteString(`{"`)
+	ctx.WriteString(op)
+	ctx.WriteString(`":{"$toDecimal":"$`)
+	ctx.WriteString(args[0].Col.Name)
+	ctx.WriteString(`"}}`)
+}
+
+// isDecimalType returns true for the column types stored as Decimal128
+func isDecimalType(t string) bool {
+	switch strings.ToLower(t) {
+	case "numeric", "decimal", "decimal128":
+		return true
+	}
+	return false
+}
+
 func (d *MongoDBDialect) RenderJSONPlural(ctx Context, sel *qcode.Select) {
 	// For plural results, we just close the aggregate
 	// The driver will return results as an array
@@ -256,6 +285,8 @@ func (d *MongoDBDialect) renderGraphLookup(ctx Context, sel *qcode.Select) {
 		ctx.WriteString(`"`)
 	}
 
+	d.renderRestrictSearch(ctx, sel)
+
 	ctx.WriteString(`,"as":"`)
 	ctx.WriteString(sel.FieldName)
 	ctx.WriteString(`"}}`)
@@ -421,6 +452,8 @@ func (d *MongoDBDialect) RenderOp(op qcode.ExpOp) (string, error) {
 		return "$regex", nil
 	case qcode.OpILike:
 		return "$regex", nil // with "i" option
+	case qcode.OpNotLike, qcode.OpNotILike:
+		return "$not", nil // wraps $regex
 	case qcode.OpIsNull:
 		return "$eq", nil // check for null
 	case qcode.OpIsNotNull:
@@ -653,7 +686,7 @@ func (d *MongoDBDialect) RenderUpdate(ctx Context, m *qcode.Mutate, set func(),
 	ctx.WriteString(`{"operation":"updateMany","collection":"`)
 	ctx.WriteString(m.Ti.Name)
 	ctx.WriteString(`","filter":{`)
-	where()
+	d.renderManyFilter(ctx, m)
 	ctx.WriteString(`},"update":{"$set":{`)
 	set()
 	ctx.WriteString(`}}}`)
@@ -663,10 +696,26 @@ func (d *MongoDBDialect) RenderDelete(ctx Context, m *qcode.Mutate, where func()
 	ctx.WriteString(`{"operation":"deleteMany","collection":"`)
 	ctx.WriteString(m.Ti.Name)
 	ctx.WriteString(`","filter":{`)
-	where()
+	d.renderManyFilter(ctx, m)
 	ctx.WriteString(`}}`)
 }
 
+// renderManyFilter renders the filter of an updateMany or a deleteMany
+// from the where clause of the mutation, the where func renders SQL. An
+// empty filter would change every document so without a where clause all
+// the documents are only matched for the mutations of all the rows and
+// none are matched otherwise
+func (d *MongoDBDialect) renderManyFilter(ctx Context, m *qcode.Mutate) {
+	switch {
+	case m.Where.Exp != nil:
+		d.renderExpression(ctx, m.Where.Exp)
+	case m.All:
+		ctx.WriteString(`"_id":{"$exists":true}`)
+	default:
+		ctx.WriteString(`"_id":{"$exists":false}`)
+	}
+}
+
 func (d *MongoDBDialect) RenderUpsert(ctx Context, m *qcode.Mutate, insert func(), updateSet func()) {
 	ctx.WriteString(`{"operation":"updateOne","collection":"`)
 	ctx.WriteString(m.Ti.Name)
@@ -696,6 +745,43 @@ func (d *MongoDBDialect) RenderTryCast(ctx Context, val func(), typ string) {
 	val()
 }
 
+// RenderMask writes a MongoDB expression that masks the field, col must
+// write the field path (eg. "$card_number")
+func (d *MongoDBDialect) RenderMask(ctx Context, m qcode.Mask, col func()) {
+	text := `"` + escapeJSONString(m.Text) + `"`
+
+	switch m.Type {
+	case qcode.MaskTypeRedact:
+		ctx.WriteString(`{"$literal":`)
+		ctx.WriteString(text)
+		ctx.WriteString(`}`)
+
+	case qcode.MaskTypeFirst:
+		ctx.WriteString(`{"$concat":[{"$substrCP":[{"$toString":{"$ifNull":[`)
+		col()
+		ctx.WriteString(`,""]}},0,`)
+		ctx.WriteString(strconv.Itoa(m.Len))
+		ctx.WriteString(`]},`)
+		ctx.WriteString(text)
+		ctx.WriteString(`]}`)
+
+	case qcode.MaskTypeLast:
+		n := strconv.Itoa(m.Len)
+		ctx.WriteString(`{"$let":{"vars":{"s":{"$toString":{"$ifNull":[`)
+		col()
+		ctx.WriteString(`,""]}}},"in":{"$concat":[`)
+		ctx.WriteString(text)
+		ctx.WriteString(`,{"$substrCP":["$$s",{"$max":[0,{"$subtract":[{"$strLenCP":"$$s"},`)
+		ctx.WriteString(n)
+		ctx.WriteString(`]}]},`)
+		ctx.WriteString(n)
+		ctx.WriteString(`]}]}}}`)
+
+	default:
+		col()
+	}
+}
+
 func (d *MongoDBDialect) RenderSubscriptionUnbox(ctx Context, params []Param, innerSQL string) {
 	// MongoDB change streams
 	ctx.WriteString(innerSQL)
@@ -735,6 +821,14 @@ func (d *MongoDBDialect) ModifySelectsForMutation(qc *qcode.QCode) {}
 
 func (d *MongoDBDialect) RenderQueryPrefix(ctx Context, qc *qcode.QCode) {}
 
+// RenderQueryComment renders the comment field of an aggregate operation,
+// the driver adds it to the aggregate command
+func (d *MongoDBDialect) RenderQueryComment(ctx Context, comment string) {
+	ctx.WriteString(`,"comment":"`)
+	ctx.WriteString(escapeJSONString(comment))
+	ctx.WriteString(`"`)
+}
+
 func (d *MongoDBDialect) SplitQuery(query string) []string {
 	return []string{query}
 }
@@ -771,6 +865,71 @@ func (d *MongoDBDialect) RequiresBooleanAsInt() bool {
 	return false
 }
 
+// IsRetryableError returns false since the MongoDB driver already retries writes
+func (d *MongoDBDialect) IsRetryableError(err error) bool {
+	return false
+}
+
+// IsConnectionError returns false since the MongoDB driver already retries reads
+func (d *MongoDBDialect) IsConnectionError(err error) bool {
+	return false
+}
+
+// ConstraintViolation returns the unique index of duplicate key errors (11000)
+// and the fields of the duplicate key:
+// E11000 duplicate key error collection: db.users index: email_1 dup key: { email: "a@b.com" }
+func (d *MongoDBDialect) ConstraintViolation(err error) (v ConstraintViolation, ok bool) {
+	msg := err.Error()
+	if !strings.Contains(msg, "E11000") {
+		return v, false
+	}
+	v.Kind = "unique"
+	v.Constraint = between(msg, "index: ", " ")
+
+	i := strings.Index(msg, "dup key: {")
+	if i == -1 {
+		return v, true
+	}
+	// the field names are read up to the colon of each top level value
+	var key strings.Builder
+	var depth int
+	inKey, inStr, esc := true, false, false
+	for _, c := range msg[i+len("dup key: {"):] {
+		if inStr {
+			switch {
+			case esc:
+				esc = false
+			case c == '\\':
+				esc = true
+			case c == '"':
+				inStr = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inStr = true
+		case c == '{', c == '[':
+			depth++
+		case c == '}', c == ']':
+			if depth == 0 {
+				return v, true
+			}
+			depth--
+		case depth != 0:
+		case c == ':' && inKey:
+			v.Columns = append(v.Columns, strings.TrimSpace(key.String()))
+			key.Reset()
+			inKey = false
+		case c == ',':
+			inKey = true
+		case inKey:
+			key.WriteRune(c)
+		}
+	}
+	return v, true
+}
+
 // Recursive CTE methods (not supported)
 
 func (d *MongoDBDialect) RequiresRecursiveKeyword() bool {
@@ -891,6 +1050,12 @@ func (d *MongoDBDialect) CompileFullMutation(ctx Context, qc *qcode.QCode) bool
 	}
 	rootMutations = orderedRootMutations
 
+	// Inserts with on_conflict are run as a bulk write of upserts
+	if rootMutations[0].Type == qcode.MTInsert && rootMutations[0].OnConflict != nil {
+		d.renderBulkUpsertMutation(ctx, qc, rootMutations)
+		return true
+	}
+
 	// Multi-root update/delete/upsert mutations should return one sub-result per root alias.
 	if len(rootMutations) > 1 {
 		switch rootMutations[0].Type {
@@ -929,8 +1094,10 @@ func (d *MongoDBDialect) CompileFullMutation(ctx Context, qc *qcode.QCode) bool
 				hasChildMutations = true
 				break
 			}
-			// For update mutations, detect child updates/connect/disconnect
-			if m.Type == qcode.MTUpdate || m.Type == qcode.MTConnect || m.Type == qcode.MTDisconnect {
+			// For update mutations, detect child updates/connect/disconnect.
+			// Embedded json array updates are part of the parent's updateOne
+			if (m.Type == qcode.MTUpdate && m.Rel.Type != sdata.RelEmbedded) ||
+				m.Type == qcode.MTConnect || m.Type == qcode.MTDisconnect {
 				hasUpdateChildMutations = true
 			}
 			// For connect operations, only include recursive connects (same table) for inserts
@@ -1033,6 +1200,8 @@ func (d *MongoDBDialect) renderInsertMutation(ctx Context, qc *qcode.QCode, m *q
 
 		// Also output presets separately - driver will merge them into the document
 		d.renderPresets(ctx, m)
+		d.renderDefaults(ctx, m)
+		d.renderObjectIDColumns(ctx, m.Ti)
 	} else {
 		// Case 2: Individual field variables - build document inline
 		ctx.WriteString(`,"document":{`)
@@ -1069,6 +1238,8 @@ func (d *MongoDBDialect) renderInsertMutation(ctx Context, qc *qcode.QCode, m *q
 		}
 	}
 
+	d.renderSideEffects(ctx, m)
+
 	// Add field_name for result wrapping
 	var rootSel *qcode.Select
 	if sel := getMutationRootSelect(qc, m); sel != nil {
@@ -1078,6 +1249,11 @@ func (d *MongoDBDialect) renderInsertMutation(ctx Context, qc *qcode.QCode, m *q
 		ctx.WriteString(`"`)
 	}
 
+	// A variable holding an array of documents is run as an insertMany by the driver
+	if qc.ActionVar != "" {
+		d.renderInsertOptions(ctx, rootSel)
+	}
+
 	// Add return_pipeline for fetching related data after insert
 	// This is the aggregation pipeline to run after the insert to get the response
 	if rootSel != nil && (len(rootSel.Fields) > 0 || len(rootSel.Children) > 0) {
@@ -1141,6 +1317,8 @@ func (d *MongoDBDialect) renderInsertManyMutation(ctx Context, qc *qcode.QCode,
 		ctx.WriteString(`"`)
 	}
 
+	d.renderInsertOptions(ctx, rootSel)
+
 	// Add return_pipeline for fetching related data after insert
 	if rootSel != nil && (len(rootSel.Fields) > 0 || len(rootSel.Children) > 0 || len(rootSel.OrderBy) > 0) {
 		ctx.WriteString(`,"return_pipeline":[`)
@@ -1164,6 +1342,124 @@ func (d *MongoDBDialect) renderInsertManyMutation(ctx Context, qc *qcode.QCode,
 	ctx.WriteString(`}`)
 }
 
+// renderBulkUpsertMutation generates a bulkUpsert operation for inserts with on_conflict.
+// The driver upserts each document on the values of its conflict_target fields, the
+// conflict_update fields are set on the existing documents and the others only on insert.
+func (d *MongoDBDialect) renderBulkUpsertMutation(ctx Context, qc *qcode.QCode, mutations []*qcode.Mutate) {
+	m := mutations[0]
+	ctx.WriteString(`{"operation":"bulkUpsert","collection":"`)
+	ctx.WriteString(m.Ti.Name)
+	ctx.WriteString(`"`)
+
+	if qc.ActionVar != "" {
+		ctx.WriteString(`,"raw_document":"`)
+		ctx.AddParam(Param{Name: qc.ActionVar, Type: "json"})
+		ctx.WriteString(`"`)
+		d.renderPresets(ctx, m)
+		d.renderDefaults(ctx, m)
+		d.renderObjectIDColumns(ctx, m.Ti)
+	} else {
+		ctx.WriteString(`,"documents":[`)
+		for i, mut := range mutations {
+			if i > 0 {
+				ctx.WriteString(`,`)
+			}
+			ctx.WriteString(`{`)
+			d.renderInsertDocument(ctx, mut)
+			ctx.WriteString(`}`)
+		}
+		ctx.WriteString(`]`)
+	}
+
+	for _, v := range []struct {
+		key  string
+		cols []sdata.DBColumn
+	}{
+		{"conflict_target", m.OnConflict.Target},
+		{"conflict_update", m.OnConflict.Update},
+	} {
+		if v.key == "conflict_update" && len(v.cols) == 0 {
+			continue
+		}
+		ctx.WriteString(`,"`)
+		ctx.WriteString(v.key)
+		ctx.WriteString(`":[`)
+		for i, col := range v.cols {
+			if i > 0 {
+				ctx.WriteString(`,`)
+			}
+			colName := col.Name
+			if colName == "id" {
+				colName = "_id"
+			}
+			ctx.WriteString(`"`)
+			ctx.WriteString(colName)
+			ctx.WriteString(`"`)
+		}
+		ctx.WriteString(`]`)
+	}
+
+	// the existing documents are only updated when they match the update
+	// filter of the role, the update presets are set on them
+	d.renderPresetColumns(ctx, "conflict_presets", m.OnConflict.Presets)
+	if m.OnConflict.Where.Exp != nil {
+		ctx.WriteString(`,"filter":{`)
+		d.renderExpression(ctx, m.OnConflict.Where.Exp)
+		ctx.WriteString(`}`)
+	}
+
+	rootSel := getMutationRootSelect(qc, m)
+	if rootSel != nil {
+		ctx.WriteString(`,"field_name":"`)
+		ctx.WriteString(rootSel.FieldName)
+		ctx.WriteString(`"`)
+	}
+
+	// The inserted and the updated documents are returned
+	if rootSel != nil && (len(rootSel.Fields) > 0 || len(rootSel.Children) > 0 || len(rootSel.OrderBy) > 0) {
+		ctx.WriteString(`,"return_pipeline":[`)
+
+		pipelineDepth := 0
+		for _, childID := range rootSel.Children {
+			child := &qc.Selects[childID]
+			if child.SkipRender != qcode.SkipTypeNone {
+				continue
+			}
+			if pipelineDepth > 0 {
+				ctx.WriteString(`,`)
+			}
+			d.renderLookupStageWithQC(ctx, rootSel, child, qc)
+			pipelineDepth++
+		}
+		if len(rootSel.OrderBy) > 0 {
+			if pipelineDepth > 0 {
+				ctx.WriteString(`,`)
+			}
+			d.renderSortStage(ctx, rootSel)
+			pipelineDepth++
+		}
+		if pipelineDepth > 0 {
+			ctx.WriteString(`,`)
+		}
+		d.renderProjectStageWithChildren(ctx, rootSel, qc)
+
+		ctx.WriteString(`]`)
+	}
+
+	ctx.WriteString(`}`)
+}
+
+// renderInsertOptions adds the insertMany options set with the @insertOptions directive.
+// Unordered inserts continue past failed documents and only return the inserted ones.
+func (d *MongoDBDialect) renderInsertOptions(ctx Context, sel *qcode.Select) {
+	if sel == nil {
+		return
+	}
+	if arg, ok := sel.GetInternalArg("ordered"); ok && arg.Val == "false" {
+		ctx.WriteString(`,"options":{"ordered":false}`)
+	}
+}
+
 // renderNestedInsertMutation generates a nested_insert operation for inserting into multiple related collections.
 func (d *MongoDBDialect) renderNestedInsertMutation(ctx Context, qc *qcode.QCode, rootMutate *qcode.Mutate) {
 	ctx.WriteString(`{"operation":"nested_insert","root_collection":"`)
@@ -1422,7 +1718,12 @@ func (d *MongoDBDialect) renderConnectExpression(ctx Context, exp *qcode.Exp, fi
 		ctx.WriteString(`"`)
 		ctx.WriteString(colName)
 		ctx.WriteString(`":`)
-		ctx.WriteString(exp.Right.Val)
+		if exp.Left.Col.ObjectID {
+			// string ids are converted to ObjectIds by the driver
+			d.renderValue(ctx, exp)
+		} else {
+			ctx.WriteString(exp.Right.Val)
+		}
 		return false
 	}
 
@@ -1524,16 +1825,26 @@ func (d *MongoDBDialect) topologicalSortMutates(mutates []qcode.Mutate) []*qcode
 	return result
 }
 
-// renderUpdateMutation generates a MongoDB updateOne operation
+// renderUpdateMutation generates a MongoDB updateOne operation or an
+// updateMany one for the updates of all the documents
 func (d *MongoDBDialect) renderUpdateMutation(ctx Context, qc *qcode.QCode, m *qcode.Mutate) {
-	ctx.WriteString(`{"operation":"updateOne","collection":"`)
+	rootSel := getMutationRootSelect(qc, m)
+
+	switch {
+	case m.All:
+		ctx.WriteString(`{"operation":"updateMany","collection":"`)
+	// @returnBefore returns the document as it was before the update
+	case isReturnBefore(rootSel):
+		ctx.WriteString(`{"operation":"findOneAndUpdate","collection":"`)
+	default:
+		ctx.WriteString(`{"operation":"updateOne","collection":"`)
+	}
 	ctx.WriteString(m.Ti.Name)
 	ctx.WriteString(`","filter":{`)
 
 	// Render where clause for the filter
 	// Check both the mutation's WHERE and the root select's WHERE (for id: $id style filters)
 	hasFilter := false
-	rootSel := getMutationRootSelect(qc, m)
 	if m.ParentID == -1 && rootSel != nil && rootSel.Where.Exp != nil {
 		d.renderExpression(ctx, rootSel.Where.Exp)
 		hasFilter = true
@@ -1545,12 +1856,36 @@ func (d *MongoDBDialect) renderUpdateMutation(ctx Context, qc *qcode.QCode, m *q
 			// This is a simplification - proper implementation would need $and wrapper
 		}
 		d.renderExpression(ctx, m.Where.Exp)
+		hasFilter = true
+	}
+	// updateMany is never rendered with an empty filter
+	if !hasFilter && m.All {
+		ctx.WriteString(`"_id":{"$exists":true}`)
+		hasFilter = true
+	}
+
+	// Only update the document if it still has the expected version
+	vc := m.VersionCol()
+	if vc != nil {
+		if hasFilter {
+			ctx.WriteString(`,`)
+		}
+		ctx.WriteString(`"`)
+		ctx.WriteString(vc.Col.Name)
+		ctx.WriteString(`":`)
+		d.renderUpdateValue(ctx, m, *vc)
 	}
 
 	ctx.WriteString(`},"update":{"$set":{`)
 
+	embedded := embeddedUpdates(qc, m)
+
 	first := true
 	for _, col := range m.Cols {
+		// The embedded array is updated element wise below
+		if isEmbeddedUpdateCol(embedded, col.Col.Name) || col.Version {
+			continue
+		}
 		if !first {
 			ctx.WriteString(`,`)
 		}
@@ -1561,39 +1896,53 @@ func (d *MongoDBDialect) renderUpdateMutation(ctx Context, qc *qcode.QCode, m *q
 		ctx.WriteString(`"`)
 		ctx.WriteString(colName)
 		ctx.WriteString(`":`)
+		d.renderUpdateValue(ctx, m, col)
+		first = false
+	}
 
-		if col.Set {
-			// Preset value (e.g., owner_id: "$user_id")
-			if col.Value != "" && col.Value[0] == '$' {
-				ctx.WriteString(`"`)
-				ctx.AddParam(Param{Name: col.Value[1:], Type: col.Col.Type})
-				ctx.WriteString(`"`)
-			} else {
-				ctx.WriteString(`"`)
-				ctx.WriteString(col.Value)
-				ctx.WriteString(`"`)
+	// Update the matching elements of embedded arrays using the
+	// filtered positional operator: "items.$[e1].qty": 5
+	for _, em := range embedded {
+		for _, col := range em.Cols {
+			if !first {
+				ctx.WriteString(`,`)
 			}
-		} else if m.Data != nil && m.Data.CMap != nil {
-			// Get value from parsed mutation data
-			field := m.Data.CMap[col.FieldName]
-			if field == nil {
-				ctx.WriteString(`null`)
-			} else if field.Type == graph.NodeVar {
-				// Variable reference - add parameter placeholder
-				ctx.WriteString(`"`)
-				ctx.AddParam(Param{Name: field.Val, Type: col.Col.Type})
-				ctx.WriteString(`"`)
+			ctx.WriteString(`"`)
+			ctx.WriteString(em.Rel.Left.Col.Name)
+			if em.Where.Exp != nil {
+				ctx.WriteString(`.$[`)
+				ctx.WriteString(arrayFilterIdent(em))
+				ctx.WriteString(`].`)
 			} else {
-				// Literal value - render directly
-				d.renderGraphNodeValue(ctx, field)
+				ctx.WriteString(`.$[].`)
 			}
-		} else {
-			ctx.WriteString(`null`)
+			ctx.WriteString(col.Col.Name)
+			ctx.WriteString(`":`)
+			d.renderUpdateValue(ctx, em, col)
+			first = false
 		}
-		first = false
 	}
 
-	ctx.WriteString(`}}`)
+	ctx.WriteString(`}`)
+	if vc != nil {
+		ctx.WriteString(`,"$inc":{"`)
+		ctx.WriteString(vc.Col.Name)
+		ctx.WriteString(`":1}}`)
+		ctx.WriteString(`,"version_field":"`)
+		ctx.WriteString(vc.Col.Name)
+		ctx.WriteString(`"`)
+	} else {
+		ctx.WriteString(`}`)
+	}
+	d.renderSideEffects(ctx, m)
+
+	// the collation of the table matches the filter of an updateMany,
+	// updateOne and findOneAndUpdate match on the id of the document
+	var collation string
+	if m.All && rootSel != nil {
+		collation = rootSel.Collation
+	}
+	d.renderUpdateOptions(ctx, embedded, collation)
 
 	// Add field_name for result wrapping
 	if rootSel != nil {
@@ -1637,40 +1986,264 @@ func (d *MongoDBDialect) renderUpdateMutation(ctx Context, qc *qcode.QCode, m *q
 	ctx.WriteString(`}`)
 }
 
-// renderNestedUpdateMutation generates a nested_update operation for updating multiple related collections.
-func (d *MongoDBDialect) renderNestedUpdateMutation(ctx Context, qc *qcode.QCode, rootMutate *qcode.Mutate) {
-	ctx.WriteString(`{"operation":"nested_update","root_collection":"`)
-	ctx.WriteString(rootMutate.Ti.Name)
-	ctx.WriteString(`","root_mutate_id":`)
-	ctx.WriteString(strconv.Itoa(int(rootMutate.ID)))
-
-	// Build a map of mutation IDs to mutations for parent lookup
-	mutateMap := make(map[int32]*qcode.Mutate)
-	for i := range qc.Mutates {
-		mutateMap[qc.Mutates[i].ID] = &qc.Mutates[i]
-	}
-
-	// Topologically sort mutations
-	allSortedMutates := d.topologicalSortMutates(qc.Mutates)
+// renderUpdateValue renders the value a column is set to by an update
+func (d *MongoDBDialect) renderUpdateValue(ctx Context, m *qcode.Mutate, col qcode.MColumn) {
+	switch {
+	case col.Set:
+		// Preset value (e.g., owner_id: "$user_id")
+		if col.Value != "" && col.Value[0] == '$' {
+			ctx.WriteString(`"`)
+			ctx.AddParam(Param{Name: col.Value[1:], Type: col.Col.Type})
+			ctx.WriteString(`"`)
+		} else {
+			ctx.WriteString(`"`)
+			ctx.WriteString(col.Value)
+			ctx.WriteString(`"`)
+		}
 
-	// Filter mutations: include updates, connects, and disconnects
-	var filteredMutates []*qcode.Mutate
-	for _, m := range allSortedMutates {
-		if m.Type == qcode.MTUpdate || m.Type == qcode.MTConnect || m.Type == qcode.MTDisconnect {
-			filteredMutates = append(filteredMutates, m)
+	case m.Data != nil && m.Data.CMap != nil:
+		// Get value from parsed mutation data
+		field := m.Data.CMap[col.FieldName]
+		if field == nil {
+			ctx.WriteString(`null`)
+		} else if col.Replace {
+			// A list replacing the whole embedded array
+			d.renderEmbeddedArray(ctx, field)
+		} else if field.Type == graph.NodeVar {
+			// Variable reference - add parameter placeholder
+			ctx.WriteString(`"`)
+			ctx.AddParam(Param{Name: field.Val, Type: col.Col.Type})
+			ctx.WriteString(`"`)
+		} else {
+			// Literal value - render directly
+			d.renderGraphNodeValue(ctx, field)
 		}
+
+	default:
+		ctx.WriteString(`null`)
 	}
+}
 
-	ctx.WriteString(`,"updates":[`)
-	for i, m := range filteredMutates {
-		if i > 0 {
+// renderEmbeddedArray renders the list replacing an embedded array, the id
+// of every element is stored as _id like the id of a document
+func (d *MongoDBDialect) renderEmbeddedArray(ctx Context, list *graph.Node) {
+	ctx.WriteString(`[`)
+	for i, elem := range list.Children {
+		if i != 0 {
 			ctx.WriteString(`,`)
 		}
-		d.renderNestedUpdateItem(ctx, qc, m)
+		if elem.Type != graph.NodeObj {
+			d.renderEmbeddedValue(ctx, elem)
+			continue
+		}
+		ctx.WriteString(`{`)
+		for j, f := range elem.Children {
+			if j != 0 {
+				ctx.WriteString(`,`)
+			}
+			ctx.WriteString(`"`)
+			if f.Name == "id" {
+				ctx.WriteString(`_id`)
+			} else {
+				ctx.WriteString(escapeJSONString(f.Name))
+			}
+			ctx.WriteString(`":`)
+			d.renderEmbeddedValue(ctx, f)
+		}
+		ctx.WriteString(`}`)
 	}
 	ctx.WriteString(`]`)
+}
 
-	// Add field_name for result wrapping
+// renderEmbeddedValue renders a value inside an embedded array element in
+// the order it was given, variables are added as parameters
+func (d *MongoDBDialect) renderEmbeddedValue(ctx Context, node *graph.Node) {
+	switch node.Type {
+	case graph.NodeVar:
+		ctx.WriteString(`"`)
+		ctx.AddParam(Param{Name: node.Val, Type: "json"})
+		ctx.WriteString(`"`)
+	case graph.NodeObj:
+		ctx.WriteString(`{`)
+		for i, f := range node.Children {
+			if i != 0 {
+				ctx.WriteString(`,`)
+			}
+			ctx.WriteString(`"`)
+			ctx.WriteString(escapeJSONString(f.Name))
+			ctx.WriteString(`":`)
+			d.renderEmbeddedValue(ctx, f)
+		}
+		ctx.WriteString(`}`)
+	case graph.NodeList:
+		ctx.WriteString(`[`)
+		for i, child := range node.Children {
+			if i != 0 {
+				ctx.WriteString(`,`)
+			}
+			d.renderEmbeddedValue(ctx, child)
+		}
+		ctx.WriteString(`]`)
+	default:
+		d.renderGraphNodeValue(ctx, node)
+	}
+}
+
+// embeddedUpdates returns the child updates of an embedded json array of the mutation
+func embeddedUpdates(qc *qcode.QCode, m *qcode.Mutate) (ml []*qcode.Mutate) {
+	for i := range qc.Mutates {
+		em := &qc.Mutates[i]
+		if em.ParentID == m.ID && em.Type == qcode.MTUpdate &&
+			em.Rel.Type == sdata.RelEmbedded && len(em.Cols) != 0 {
+			ml = append(ml, em)
+		}
+	}
+	return
+}
+
+func isEmbeddedUpdateCol(ml []*qcode.Mutate, colName string) bool {
+	for _, em := range ml {
+		if em.Rel.Left.Col.Name == colName {
+			return true
+		}
+	}
+	return false
+}
+
+// arrayFilterIdent returns the identifier used for the array filter of an
+// embedded update, it must start with a lowercase letter
+func arrayFilterIdent(m *qcode.Mutate) string {
+	return "e" + strconv.Itoa(int(m.ID))
+}
+
+// renderUpdateOptions renders the options of an update, arrayFilters
+// select the embedded array elements to update and the collation matches
+// strings ignoring case
+func (d *MongoDBDialect) renderUpdateOptions(ctx Context, ml []*qcode.Mutate, collation string) {
+	first := true
+	for _, em := range ml {
+		if em.Where.Exp == nil {
+			continue
+		}
+		if first {
+			ctx.WriteString(`,"options":{"arrayFilters":[`)
+		} else {
+			ctx.WriteString(`,`)
+		}
+		ctx.WriteString(`{`)
+		d.renderArrayFilterExp(ctx, arrayFilterIdent(em), em.Where.Exp)
+		ctx.WriteString(`}`)
+		first = false
+	}
+	if !first {
+		ctx.WriteString(`]`)
+	}
+
+	if collation != "" {
+		if first {
+			ctx.WriteString(`,"options":{`)
+		} else {
+			ctx.WriteString(`,`)
+		}
+		renderCollation(ctx, collation)
+		first = false
+	}
+	if !first {
+		ctx.WriteString(`}`)
+	}
+}
+
+// renderCollation renders the collation matching strings of the locale
+// ignoring case
+func renderCollation(ctx Context, locale string) {
+	ctx.WriteString(`"collation":{"locale":"`)
+	ctx.WriteString(escapeJSONString(locale))
+	ctx.WriteString(`","strength":2}`)
+}
+
+// renderArrayFilterExp renders a filter on the fields of an array element
+// referenced by the identifier: {"e1.sku": "X"}
+func (d *MongoDBDialect) renderArrayFilterExp(ctx Context, ident string, exp *qcode.Exp) {
+	switch exp.Op {
+	case qcode.OpAnd, qcode.OpOr:
+		if len(exp.Children) == 1 {
+			d.renderArrayFilterExp(ctx, ident, exp.Children[0])
+			return
+		}
+		if exp.Op == qcode.OpAnd {
+			ctx.WriteString(`"$and":[`)
+		} else {
+			ctx.WriteString(`"$or":[`)
+		}
+		for i, child := range exp.Children {
+			if i != 0 {
+				ctx.WriteString(`,`)
+			}
+			ctx.WriteString(`{`)
+			d.renderArrayFilterExp(ctx, ident, child)
+			ctx.WriteString(`}`)
+		}
+		ctx.WriteString(`]`)
+
+	case qcode.OpNot:
+		if len(exp.Children) != 0 {
+			ctx.WriteString(`"$nor":[{`)
+			d.renderArrayFilterExp(ctx, ident, exp.Children[0])
+			ctx.WriteString(`}]`)
+		}
+
+	default:
+		colName := exp.Left.Col.Name
+		if colName == "" {
+			colName = exp.Left.ColName
+		}
+		ctx.WriteString(`"`)
+		ctx.WriteString(ident)
+		ctx.WriteString(`.`)
+		ctx.WriteString(colName)
+		for _, p := range exp.Left.Path {
+			ctx.WriteString(`.`)
+			ctx.WriteString(p)
+		}
+		ctx.WriteString(`":`)
+		d.renderComparisonValue(ctx, exp)
+	}
+}
+
+// renderNestedUpdateMutation generates a nested_update operation for updating multiple related collections.
+func (d *MongoDBDialect) renderNestedUpdateMutation(ctx Context, qc *qcode.QCode, rootMutate *qcode.Mutate) {
+	ctx.WriteString(`{"operation":"nested_update","root_collection":"`)
+	ctx.WriteString(rootMutate.Ti.Name)
+	ctx.WriteString(`","root_mutate_id":`)
+	ctx.WriteString(strconv.Itoa(int(rootMutate.ID)))
+
+	// Build a map of mutation IDs to mutations for parent lookup
+	mutateMap := make(map[int32]*qcode.Mutate)
+	for i := range qc.Mutates {
+		mutateMap[qc.Mutates[i].ID] = &qc.Mutates[i]
+	}
+
+	// Topologically sort mutations
+	allSortedMutates := d.topologicalSortMutates(qc.Mutates)
+
+	// Filter mutations: include updates, connects, and disconnects
+	var filteredMutates []*qcode.Mutate
+	for _, m := range allSortedMutates {
+		if m.Type == qcode.MTUpdate || m.Type == qcode.MTConnect || m.Type == qcode.MTDisconnect {
+			filteredMutates = append(filteredMutates, m)
+		}
+	}
+
+	ctx.WriteString(`,"updates":[`)
+	for i, m := range filteredMutates {
+		if i > 0 {
+			ctx.WriteString(`,`)
+		}
+		d.renderNestedUpdateItem(ctx, qc, m)
+	}
+	ctx.WriteString(`]`)
+
+	// Add field_name for result wrapping
 	var rootSel *qcode.Select
 	if sel := getMutationRootSelect(qc, rootMutate); sel != nil {
 		rootSel = sel
@@ -1845,21 +2418,47 @@ func (d *MongoDBDialect) renderNestedUpdateItem(ctx Context, qc *qcode.QCode, m
 	ctx.WriteString(`}`)
 }
 
-// renderDeleteMutation generates a MongoDB deleteOne operation
+// renderDeleteMutation generates a MongoDB deleteOne operation or a
+// deleteMany one for the deletes of all the documents
 func (d *MongoDBDialect) renderDeleteMutation(ctx Context, qc *qcode.QCode, m *qcode.Mutate) {
-	ctx.WriteString(`{"operation":"deleteOne","collection":"`)
+	if m.All {
+		ctx.WriteString(`{"operation":"deleteMany","collection":"`)
+	} else {
+		ctx.WriteString(`{"operation":"deleteOne","collection":"`)
+	}
 	ctx.WriteString(m.Ti.Name)
 	ctx.WriteString(`","filter":{`)
 
+	// the filter of a soft delete leaves out the documents already deleted
+	softDelete := m.SoftDeleteCol()
+
 	rootSel := getMutationRootSelect(qc, m)
-	if m.ParentID == -1 && rootSel != nil && rootSel.Where.Exp != nil {
+	if softDelete == nil && m.ParentID == -1 && rootSel != nil && rootSel.Where.Exp != nil {
 		d.renderExpression(ctx, rootSel.Where.Exp)
 	} else if m.Where.Exp != nil {
 		d.renderExpression(ctx, m.Where.Exp)
+	} else if m.All {
+		// deleteMany is never rendered with an empty filter
+		ctx.WriteString(`"_id":{"$exists":true}`)
 	}
 
 	ctx.WriteString(`}`)
 
+	if softDelete != nil {
+		ctx.WriteString(`,"soft_delete":"`)
+		ctx.WriteString(softDelete.Col.Name)
+		ctx.WriteString(`"`)
+	}
+
+	d.renderSideEffects(ctx, m)
+
+	// the collation of the table matches the filter of a deleteMany
+	if m.All && rootSel != nil && rootSel.Collation != "" {
+		ctx.WriteString(`,"options":{`)
+		renderCollation(ctx, rootSel.Collation)
+		ctx.WriteString(`}`)
+	}
+
 	if rootSel != nil {
 		ctx.WriteString(`,"field_name":"`)
 		ctx.WriteString(rootSel.FieldName)
@@ -1896,6 +2495,41 @@ func (d *MongoDBDialect) renderDeleteMutation(ctx Context, qc *qcode.QCode, m *q
 	ctx.WriteString(`}`)
 }
 
+// renderSideEffects renders the updates of other collections the driver
+// runs after the mutation of the document
+func (d *MongoDBDialect) renderSideEffects(ctx Context, m *qcode.Mutate) {
+	if len(m.SideEffects) == 0 {
+		return
+	}
+	ctx.WriteString(`,"side_effects":[`)
+	for i, se := range m.SideEffects {
+		if i != 0 {
+			ctx.WriteString(`,`)
+		}
+		col := se.Col
+		if col == "id" {
+			col = "_id"
+		}
+		ctx.WriteString(`{"collection":"`)
+		ctx.WriteString(escapeJSONString(se.Collection))
+		ctx.WriteString(`","field":"`)
+		ctx.WriteString(escapeJSONString(col))
+		ctx.WriteString(`","key":"`)
+		ctx.WriteString(escapeJSONString(se.Key))
+		ctx.WriteString(`"`)
+		if len(se.Update) != 0 {
+			ctx.WriteString(`,"update":`)
+			ctx.WriteString(string(se.Update))
+		}
+		if len(se.Pipeline) != 0 {
+			ctx.WriteString(`,"pipeline":`)
+			ctx.WriteString(string(se.Pipeline))
+		}
+		ctx.WriteString(`}`)
+	}
+	ctx.WriteString(`]`)
+}
+
 // renderUpsertMutation generates a MongoDB updateOne operation with upsert: true
 func (d *MongoDBDialect) renderUpsertMutation(ctx Context, qc *qcode.QCode, m *qcode.Mutate) {
 	ctx.WriteString(`{"operation":"updateOne","collection":"`)
@@ -1909,7 +2543,13 @@ func (d *MongoDBDialect) renderUpsertMutation(ctx Context, qc *qcode.QCode, m *q
 		d.renderExpression(ctx, m.Where.Exp)
 	}
 
-	ctx.WriteString(`},"update":{"$set":{`)
+	// With @getOrCreate the fields are only set when the document is
+	// inserted, a matching document is left unchanged
+	if isGetOrCreate(rootSel) {
+		ctx.WriteString(`},"update":{"$setOnInsert":{`)
+	} else {
+		ctx.WriteString(`},"update":{"$set":{`)
+	}
 
 	first := true
 	for _, col := range m.Cols {
@@ -1923,14 +2563,7 @@ func (d *MongoDBDialect) renderUpsertMutation(ctx Context, qc *qcode.QCode, m *q
 		ctx.WriteString(`"`)
 		ctx.WriteString(colName)
 		ctx.WriteString(`":`)
-
-		if col.Value != "" {
-			ctx.WriteString(`"`)
-			ctx.WriteString(col.Value)
-			ctx.WriteString(`"`)
-		} else {
-			ctx.WriteString(`null`)
-		}
+		d.renderUpdateValue(ctx, m, col)
 		first = false
 	}
 
@@ -1971,6 +2604,24 @@ func (d *MongoDBDialect) renderUpsertMutation(ctx Context, qc *qcode.QCode, m *q
 	ctx.WriteString(`}`)
 }
 
+// isGetOrCreate returns true if the upsert of the selector has the @getOrCreate directive
+func isGetOrCreate(sel *qcode.Select) bool {
+	if sel == nil {
+		return false
+	}
+	arg, ok := sel.GetInternalArg("get_or_create")
+	return ok && arg.Val == "true"
+}
+
+// isReturnBefore returns true if the update of the selector has the @returnBefore directive
+func isReturnBefore(sel *qcode.Select) bool {
+	if sel == nil {
+		return false
+	}
+	arg, ok := sel.GetInternalArg("return_before")
+	return ok && arg.Val == "true"
+}
+
 // renderInsertDocument builds the document for insert mutations with individual field variables
 func (d *MongoDBDialect) renderInsertDocument(ctx Context, m *qcode.Mutate) {
 	first := true
@@ -1988,6 +2639,7 @@ func (d *MongoDBDialect) renderInsertDocument(ctx Context, m *qcode.Mutate) {
 
 		if col.Set {
 			// Preset value (e.g., owner_id: "$user_id")
+			oidOpen(ctx, col.Col)
 			if col.Value != "" && col.Value[0] == '$' {
 				ctx.WriteString(`"`)
 				ctx.AddParam(Param{Name: col.Value[1:], Type: col.Col.Type})
@@ -1997,6 +2649,7 @@ func (d *MongoDBDialect) renderInsertDocument(ctx Context, m *qcode.Mutate) {
 				ctx.WriteString(col.Value)
 				ctx.WriteString(`"`)
 			}
+			oidClose(ctx, col.Col)
 		} else if m.Data != nil && m.Data.CMap != nil {
 			// Get value from parsed mutation data
 			field := m.Data.CMap[col.FieldName]
@@ -2004,24 +2657,87 @@ func (d *MongoDBDialect) renderInsertDocument(ctx Context, m *qcode.Mutate) {
 				ctx.WriteString(`null`)
 			} else if field.Type == graph.NodeVar {
 				// Variable reference - add parameter placeholder
+				oidOpen(ctx, col.Col)
 				ctx.WriteString(`"`)
 				ctx.AddParam(Param{Name: field.Val, Type: col.Col.Type})
 				ctx.WriteString(`"`)
+				oidClose(ctx, col.Col)
 			} else {
 				// Literal value - render directly
+				oidOpen(ctx, col.Col)
 				d.renderGraphNodeValue(ctx, field)
+				oidClose(ctx, col.Col)
 			}
 		} else {
 			ctx.WriteString(`null`)
 		}
 		first = false
 	}
+
+	// the fields omitted from the document are set to their default
+	for _, col := range insertDefaults(m) {
+		if !first {
+			ctx.WriteString(`,`)
+		}
+		ctx.WriteString(`"`)
+		ctx.WriteString(col.Name)
+		ctx.WriteString(`":`)
+		ctx.WriteString(col.Default)
+		first = false
+	}
+}
+
+// renderDefaults outputs the defaults of the columns, the driver sets them
+// on the fields missing from the raw_document
+func (d *MongoDBDialect) renderDefaults(ctx Context, m *qcode.Mutate) {
+	cols := insertDefaults(m)
+	if len(cols) == 0 {
+		return
+	}
+	ctx.WriteString(`,"defaults":{`)
+	for i, col := range cols {
+		if i > 0 {
+			ctx.WriteString(`,`)
+		}
+		ctx.WriteString(`"`)
+		ctx.WriteString(col.Name)
+		ctx.WriteString(`":`)
+		ctx.WriteString(col.Default)
+	}
+	ctx.WriteString(`}`)
+}
+
+// insertDefaults returns the columns with a default that are not set by
+// the mutation, a field set to null keeps the null
+func insertDefaults(m *qcode.Mutate) (cols []sdata.DBColumn) {
+	for _, col := range m.Ti.Columns {
+		// defaults not declared in the config are not json values
+		if col.Default == "" || !json.Valid([]byte(col.Default)) {
+			continue
+		}
+		set := false
+		for _, mc := range m.Cols {
+			if mc.Col.Name == col.Name {
+				set = true
+				break
+			}
+		}
+		if !set {
+			cols = append(cols, col)
+		}
+	}
+	return cols
 }
 
 // renderPresets outputs preset values that need to be merged with raw_document
 func (d *MongoDBDialect) renderPresets(ctx Context, m *qcode.Mutate) {
+	d.renderPresetColumns(ctx, "presets", m.Cols)
+}
+
+// renderPresetColumns renders the preset values of the columns under the key
+func (d *MongoDBDialect) renderPresetColumns(ctx Context, key string, cols []qcode.MColumn) {
 	hasPresets := false
-	for _, col := range m.Cols {
+	for _, col := range cols {
 		if col.Set {
 			hasPresets = true
 			break
@@ -2032,9 +2748,11 @@ func (d *MongoDBDialect) renderPresets(ctx Context, m *qcode.Mutate) {
 		return
 	}
 
-	ctx.WriteString(`,"presets":{`)
+	ctx.WriteString(`,"`)
+	ctx.WriteString(key)
+	ctx.WriteString(`":{`)
 	first := true
-	for _, col := range m.Cols {
+	for _, col := range cols {
 		if !col.Set {
 			continue
 		}
@@ -2049,6 +2767,7 @@ func (d *MongoDBDialect) renderPresets(ctx Context, m *qcode.Mutate) {
 		ctx.WriteString(colName)
 		ctx.WriteString(`":`)
 
+		oidOpen(ctx, col.Col)
 		if col.Value != "" && col.Value[0] == '$' {
 			// Parameter reference (e.g., "$user_id")
 			ctx.WriteString(`"`)
@@ -2060,12 +2779,16 @@ func (d *MongoDBDialect) renderPresets(ctx Context, m *qcode.Mutate) {
 			ctx.WriteString(col.Value)
 			ctx.WriteString(`"`)
 		}
+		oidClose(ctx, col.Col)
 		first = false
 	}
 	ctx.WriteString(`}`)
 }
 
-// renderGraphNodeValue renders a graph.Node value as JSON
+// renderGraphNodeValue renders a graph.Node value as JSON. Objects are
+// rendered as sub-documents with their keys in order and as is, an id key
+// of a sub-document is not the id of the document. Variables are rendered
+// as parameters.
 func (d *MongoDBDialect) renderGraphNodeValue(ctx Context, node *graph.Node) {
 	switch node.Type {
 	case graph.NodeStr:
@@ -2076,18 +2799,20 @@ func (d *MongoDBDialect) renderGraphNodeValue(ctx Context, node *graph.Node) {
 		ctx.WriteString(node.Val)
 	case graph.NodeBool:
 		ctx.WriteString(node.Val)
+	case graph.NodeVar:
+		ctx.WriteString(`"`)
+		ctx.AddParam(Param{Name: node.Val})
+		ctx.WriteString(`"`)
 	case graph.NodeObj:
 		ctx.WriteString(`{`)
-		first := true
-		for k, v := range node.CMap {
-			if !first {
+		for i, child := range node.Children {
+			if i > 0 {
 				ctx.WriteString(`,`)
 			}
 			ctx.WriteString(`"`)
-			ctx.WriteString(k)
+			ctx.WriteString(escapeJSONString(child.Name))
 			ctx.WriteString(`":`)
-			d.renderGraphNodeValue(ctx, v)
-			first = false
+			d.renderGraphNodeValue(ctx, child)
 		}
 		ctx.WriteString(`}`)
 	case graph.NodeList:
@@ -2170,51 +2895,133 @@ func (d *MongoDBDialect) CompileFullQuery(ctx Context, qc *qcode.QCode) bool {
 		return true
 	}
 
+	if sel.ChangeStream != qcode.ChangeStreamNone {
+		d.renderWatchQuery(ctx, sel)
+		return true
+	}
+
 	d.renderAggregateQuery(ctx, qc, sel)
 
 	return true
 }
 
-// effectiveSkipRender returns the effective skip render status for a selection.
-// It checks both sel.SkipRender (for auth/block status) and sel.Field.SkipRender
-// (for @skip/@include/@add/@remove directives on selectors).
-func (d *MongoDBDialect) effectiveSkipRender(sel *qcode.Select) qcode.SkipType {
-	// First check selector-level directive status (from @skip/@include/@add/@remove on the selector)
-	if sel.Field.SkipRender != qcode.SkipTypeNone {
-		return sel.Field.SkipRender
-	}
-	// Then check selection-level status (from auth/block checks)
-	return sel.SkipRender
-}
-
-// renderNulledQuery generates a null placeholder for a skipped selection.
-func (d *MongoDBDialect) renderNulledQuery(ctx Context, sel *qcode.Select) {
-	ctx.WriteString(`{"operation":"null","field_name":"`)
-	ctx.WriteString(escapeJSONString(sel.FieldName))
-	ctx.WriteString(`"}`)
-}
-
-// renderAggregateQuery generates a single aggregate query for a root selection
-func (d *MongoDBDialect) renderAggregateQuery(ctx Context, qc *qcode.QCode, sel *qcode.Select) {
-	// Start the JSON query
-	ctx.WriteString(`{"operation":"aggregate","collection":"`)
+// renderWatchQuery renders a subscription that watches the change stream of
+// the collection, the filter is matched against the changed documents and
+// fields maps the GraphQL field names to the columns they select
+func (d *MongoDBDialect) renderWatchQuery(ctx Context, sel *qcode.Select) {
+	ctx.WriteString(`{"operation":"watch","collection":"`)
 	ctx.WriteString(sel.Table)
 	ctx.WriteString(`","field_name":"`)
 	ctx.WriteString(sel.FieldName)
+	ctx.WriteString(`","changes":"`)
+	if sel.ChangeStream == qcode.ChangeStreamFields {
+		ctx.WriteString(`fields`)
+	} else {
+		ctx.WriteString(`document`)
+	}
 	ctx.WriteString(`"`)
 
-	// Include singular flag for proper result wrapping
-	if sel.Singular {
-		ctx.WriteString(`,"singular":true`)
+	if sel.Where.Exp != nil {
+		if exp := filterOutVariableConditions(sel.Where.Exp); exp != nil {
+			ctx.WriteString(`,"filter":{`)
+			d.renderExpression(ctx, exp)
+			ctx.WriteString(`}`)
+		}
 	}
 
-	// Include typename (table name) if requested for __typename field
-	if sel.Typename {
-		ctx.WriteString(`,"typename":"`)
-		ctx.WriteString(escapeJSONString(sel.Table))
+	ctx.WriteString(`,"fields":{`)
+	first := true
+	for _, f := range sel.Fields {
+		if f.SkipRender == qcode.SkipTypeDrop {
+			continue
+		}
+		if !first {
+			ctx.WriteString(`,`)
+		}
+		first = false
+
+		ctx.WriteString(`"`)
+		ctx.WriteString(f.FieldName)
+		ctx.WriteString(`":`)
+
+		// fields skipped for the role are always null
+		if f.SkipRender != qcode.SkipTypeNone {
+			ctx.WriteString(`null`)
+			continue
+		}
+		colName := f.Col.Name
+		if colName == "id" {
+			colName = "_id"
+		}
+		ctx.WriteString(`"`)
+		ctx.WriteString(colName)
+		ctx.WriteString(`"`)
+	}
+	ctx.WriteString(`}}`)
+}
+
+// effectiveSkipRender returns the effective skip render status for a selection.
+// It checks both sel.SkipRender (for auth/block status) and sel.Field.SkipRender
+// (for @skip/@include/@add/@remove directives on selectors).
+func (d *MongoDBDialect) effectiveSkipRender(sel *qcode.Select) qcode.SkipType {
+	// First check selector-level directive status (from @skip/@include/@add/@remove on the selector)
+	if sel.Field.SkipRender != qcode.SkipTypeNone {
+		return sel.Field.SkipRender
+	}
+	// Then check selection-level status (from auth/block checks)
+	return sel.SkipRender
+}
+
+// renderNulledQuery generates a null placeholder for a skipped selection.
+func (d *MongoDBDialect) renderNulledQuery(ctx Context, sel *qcode.Select) {
+	ctx.WriteString(`{"operation":"null","field_name":"`)
+	ctx.WriteString(escapeJSONString(sel.FieldName))
+	ctx.WriteString(`"}`)
+}
+
+// renderAggregateQuery generates a single aggregate query for a root selection
+func (d *MongoDBDialect) renderAggregateQuery(ctx Context, qc *qcode.QCode, sel *qcode.Select) {
+	// Start the JSON query
+	ctx.WriteString(`{"operation":"aggregate","collection":"`)
+	ctx.WriteString(sel.Table)
+	ctx.WriteString(`","field_name":"`)
+	ctx.WriteString(sel.FieldName)
+	ctx.WriteString(`"`)
+
+	// Include singular flag for proper result wrapping
+	if sel.Singular {
+		ctx.WriteString(`,"singular":true`)
+	}
+
+	// Include typename (table name) if requested for __typename field
+	if sel.Typename {
+		ctx.WriteString(`,"typename":"`)
+		ctx.WriteString(escapeJSONString(sel.Table))
 		ctx.WriteString(`"`)
 	}
 
+	if d.QueryComment {
+		d.RenderQueryComment(ctx, QueryCommentText(qc, true))
+	}
+
+	// Sort and match strings ignoring case, the collation applies to the
+	// whole operation including the $lookup stages
+	if locale := queryCollation(qc, sel); locale != "" {
+		ctx.WriteString(`,"options":{`)
+		renderCollation(ctx, locale)
+		ctx.WriteString(`}`)
+	}
+
+	// The driver caches the results in the collection and reads them from
+	// it until they are older than the TTL
+	if m := sel.Materialize; m.Collection != "" {
+		ctx.WriteString(`,"materialize":{"collection":"`)
+		ctx.WriteString(escapeJSONString(m.Collection))
+		ctx.WriteString(`","ttl":`)
+		ctx.WriteString(strconv.Itoa(m.TTL))
+		ctx.WriteString(`}`)
+	}
+
 	ctx.WriteString(`,"pipeline":[`)
 
 	pipelineDepth := 0
@@ -2235,6 +3042,24 @@ func (d *MongoDBDialect) renderAggregateQuery(ctx Context, qc *qcode.QCode, sel
 	if sel.Where.Exp != nil {
 		filteredExp := filterOutVariableConditions(sel.Where.Exp)
 		filteredExp = filterOutGeoExpressions(filteredExp)
+
+		// $text can only be used in the first $match stage of the pipeline
+		textExp, filteredExp := splitTextSearch(filteredExp)
+
+		// The counts of related documents are compared after the lookups
+		// of the related documents, the rest of the filter is matched first
+		filteredExp, countExp := splitSelectCounts(filteredExp)
+
+		if textExp != nil {
+			if pipelineDepth > 0 {
+				ctx.WriteString(`,`)
+			}
+			d.renderMatchStage(ctx, textExp)
+			if searchRanked(sel) {
+				ctx.WriteString(`,{"$addFields":{"` + searchRankField + `":{"$meta":"textScore"}}}`)
+			}
+			pipelineDepth++
+		}
 		if filteredExp != nil {
 			if pipelineDepth > 0 {
 				ctx.WriteString(`,`)
@@ -2242,6 +3067,24 @@ func (d *MongoDBDialect) renderAggregateQuery(ctx Context, qc *qcode.QCode, sel
 			d.renderMatchStage(ctx, filteredExp)
 			pipelineDepth++
 		}
+		if countExp != nil {
+			if pipelineDepth > 0 {
+				ctx.WriteString(`,`)
+			}
+			d.renderCountLookups(ctx, countExp, map[string]bool{})
+			ctx.WriteString(`,`)
+			d.renderMatchStage(ctx, countExp)
+			pipelineDepth++
+		}
+	}
+
+	// a group column and its count ordered by the count are grouped and
+	// ordered by a single $sortByCount stage
+	sbcCol, sbcCount := sortByCountFields(sel)
+
+	if f := countField(sel); f != nil && sbcCol == nil {
+		d.renderCountQuery(ctx, sel, f, pipelineDepth)
+		return
 	}
 
 	// Add $lookup stages for each child (related table)
@@ -2257,8 +3100,19 @@ func (d *MongoDBDialect) renderAggregateQuery(ctx Context, qc *qcode.QCode, sel
 		pipelineDepth++
 	}
 
-	// Add $sort stage if there's ordering
-	if len(sel.OrderBy) > 0 {
+	// Add $sort stage if there's ordering, the documents of other aggregates
+	// are grouped into a single result so the order by a count is ignored
+	switch {
+	case sbcCol != nil:
+		if pipelineDepth > 0 {
+			ctx.WriteString(`,`)
+		}
+		ctx.WriteString(`{"$sortByCount":"$`)
+		ctx.WriteString(sbcCol.Col.Name)
+		ctx.WriteString(`"}`)
+		pipelineDepth++
+
+	case len(sel.OrderBy) > 0 && !countOrdered(sel):
 		if pipelineDepth > 0 {
 			ctx.WriteString(`,`)
 		}
@@ -2267,7 +3121,7 @@ func (d *MongoDBDialect) renderAggregateQuery(ctx Context, qc *qcode.QCode, sel
 	}
 
 	// Add $skip stage if there's an offset (skip for aggregation queries)
-	if !sel.GroupCols && (sel.Paging.Offset > 0 || sel.Paging.OffsetVar != "") {
+	if (!sel.GroupCols || sbcCol != nil) && (sel.Paging.Offset > 0 || sel.Paging.OffsetVar != "") {
 		if pipelineDepth > 0 {
 			ctx.WriteString(`,`)
 		}
@@ -2284,7 +3138,8 @@ func (d *MongoDBDialect) renderAggregateQuery(ctx Context, qc *qcode.QCode, sel
 	}
 
 	// Add $limit stage (skip for aggregation queries - they return a single result)
-	if !sel.Paging.NoLimit && !sel.GroupCols && (sel.Paging.Limit > 0 || sel.Paging.LimitVar != "") {
+	if !sel.Paging.NoLimit && (!sel.GroupCols || sbcCol != nil) &&
+		(sel.Paging.Limit > 0 || sel.Paging.LimitVar != "") {
 		if pipelineDepth > 0 {
 			ctx.WriteString(`,`)
 		}
@@ -2303,7 +3158,15 @@ func (d *MongoDBDialect) renderAggregateQuery(ctx Context, qc *qcode.QCode, sel
 	// Add $project stage for field selection (including children)
 	// We need a projection stage even if sel.Fields is empty (all fields dropped)
 	// to produce empty objects instead of full documents
-	if len(sel.Fields) > 0 || len(sel.Children) > 0 {
+	if sbcCol != nil {
+		// $sortByCount returns the value of the column as _id
+		ctx.WriteString(`,{"$project":{"_id":0,"`)
+		ctx.WriteString(sbcCol.Col.Name)
+		ctx.WriteString(`":"$_id","`)
+		ctx.WriteString(sbcCount.FieldName)
+		ctx.WriteString(`":"$count"}}`)
+		pipelineDepth++
+	} else if len(sel.Fields) > 0 || len(sel.Children) > 0 {
 		if pipelineDepth > 0 {
 			ctx.WriteString(`,`)
 		}
@@ -2317,6 +3180,7 @@ func (d *MongoDBDialect) renderAggregateQuery(ctx Context, qc *qcode.QCode, sel
 		ctx.WriteString(`{"$replaceRoot":{"newRoot":{}}}`)
 		pipelineDepth++
 	}
+	d.renderHelperCleanup(ctx, sel, qc)
 
 	// Close pipeline array
 	ctx.WriteString(`]`)
@@ -2329,12 +3193,143 @@ func (d *MongoDBDialect) renderAggregateQuery(ctx Context, qc *qcode.QCode, sel
 	// Add cursor info for cursor-based pagination
 	if sel.Paging.Cursor && len(sel.OrderBy) > 0 {
 		d.renderCursorInfo(ctx, sel)
+
+		// the driver fetches a document more than the $limit to know
+		// if there is a next page and adds the page info
+		if sel.Connection {
+			ctx.WriteString(`,"connection":true`)
+		}
 	}
 
 	// Close root object
 	ctx.WriteString(`}`)
 }
 
+// countOrdered returns true if the selector is ordered by a count
+func countOrdered(sel *qcode.Select) bool {
+	for _, ob := range sel.OrderBy {
+		if ob.Count {
+			return true
+		}
+	}
+	return false
+}
+
+// sortByCountFields returns the column and the count of a selector that
+// only selects a column and the count of its documents ordered by the count
+// descending, any other shape takes the general aggregate path
+func sortByCountFields(sel *qcode.Select) (col, count *qcode.Field) {
+	if !sel.GroupCols || len(sel.Children) != 0 || sel.Typename || sel.Paging.Cursor ||
+		len(sel.DistinctOn) != 0 || len(sel.OrderBy) != 1 {
+		return nil, nil
+	}
+	pk := sel.Ti.PrimaryCol.Name
+	switch ob := sel.OrderBy[0]; {
+	case !ob.Count || ob.Col.Name != pk:
+		return nil, nil
+	case ob.Order != qcode.OrderDesc && ob.Order != qcode.OrderDescNullsFirst &&
+		ob.Order != qcode.OrderDescNullsLast:
+		return nil, nil
+	}
+
+	for i := range sel.Fields {
+		f := &sel.Fields[i]
+		if f.SkipRender == qcode.SkipTypeDrop {
+			continue
+		}
+		if f.SkipRender != qcode.SkipTypeNone || f.FieldFilter.Exp != nil {
+			return nil, nil
+		}
+		switch {
+		case f.Type == qcode.FieldTypeCol && col == nil && f.Col.Name != pk &&
+			f.Mask.Type == qcode.MaskTypeNone && f.ArrayProj.Type == qcode.ArrayProjNone &&
+			f.Compare == nil && len(f.JSONPaths) == 0:
+			col = f
+		case f.Type == qcode.FieldTypeFunc && count == nil && f.Func.Name == "count" &&
+			len(f.Args) != 0 && f.Args[0].Col.Name == pk:
+			count = f
+		default:
+			return nil, nil
+		}
+	}
+	if col == nil || count == nil {
+		return nil, nil
+	}
+	return col, count
+}
+
+// countField returns the count function of a selector that selects nothing
+// else, these are counted with $count instead of grouping the documents
+func countField(sel *qcode.Select) *qcode.Field {
+	if !sel.GroupCols || len(sel.Children) != 0 || sel.Typename || sel.Paging.Cursor {
+		return nil
+	}
+	var cf *qcode.Field
+	for i := range sel.Fields {
+		f := &sel.Fields[i]
+		// columns are not part of an aggregate result
+		if f.SkipRender == qcode.SkipTypeDrop || f.Type == qcode.FieldTypeCol {
+			continue
+		}
+		if cf != nil || f.Type != qcode.FieldTypeFunc || f.Func.Name != "count" ||
+			f.SkipRender != qcode.SkipTypeNone {
+			return nil
+		}
+		cf = f
+	}
+	return cf
+}
+
+// renderCountQuery completes the pipeline of a count query with a $count
+// stage, the driver returns a count without a column as a scalar and
+// count_<column> as the only field of the result
+func (d *MongoDBDialect) renderCountQuery(ctx Context, sel *qcode.Select, f *qcode.Field, pipelineDepth int) {
+	// count_<column> only counts documents where the column is set
+	if len(f.Args) != 0 && f.Args[0].Col.Name != sel.Ti.PrimaryCol.Name {
+		if pipelineDepth > 0 {
+			ctx.WriteString(`,`)
+		}
+		ctx.WriteString(`{"$match":{"`)
+		ctx.WriteString(f.Args[0].Col.Name)
+		ctx.WriteString(`":{"$ne":null}}}`)
+		pipelineDepth++
+	}
+	if pipelineDepth > 0 {
+		ctx.WriteString(`,`)
+	}
+	ctx.WriteString(`{"$count":"total"}]`)
+
+	if sel.Field.FieldFilter.Exp != nil {
+		d.renderQueryCondition(ctx, sel.Field.FieldFilter.Exp)
+	}
+
+	ctx.WriteString(`,"count":true`)
+	if !f.IsScalarCount() {
+		ctx.WriteString(`,"count_field":"`)
+		ctx.WriteString(escapeJSONString(f.FieldName))
+		ctx.WriteString(`"`)
+	}
+	ctx.WriteString(`}`)
+}
+
+// queryCollation returns the collation locale of the selector or the first
+// of its children that uses one, an operation can only have a single collation
+func queryCollation(qc *qcode.QCode, sel *qcode.Select) string {
+	if sel.Collation != "" {
+		return sel.Collation
+	}
+	for _, id := range sel.Children {
+		child := &qc.Selects[id]
+		if child.SkipRender != qcode.SkipTypeNone {
+			continue
+		}
+		if v := queryCollation(qc, child); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 // renderCursorInfo generates cursor metadata for the driver to extract cursor values
 // and to apply seek-based filtering for cursor pagination.
 func (d *MongoDBDialect) renderCursorInfo(ctx Context, sel *qcode.Select) {
@@ -2346,7 +3341,7 @@ func (d *MongoDBDialect) renderCursorInfo(ctx Context, sel *qcode.Select) {
 	ctx.WriteString(ctx.GetSecPrefix())
 	ctx.WriteString(`","order_by":[`)
 
-	for i, ob := range sel.OrderBy {
+	for i, ob := range d.sortOrder(sel) {
 		if i > 0 {
 			ctx.WriteString(`,`)
 		}
@@ -2354,7 +3349,14 @@ func (d *MongoDBDialect) renderCursorInfo(ctx Context, sel *qcode.Select) {
 		// The driver will handle id -> _id translation when building $match
 		colName := ob.Col.Name
 		ctx.WriteString(`{"col":"`)
-		ctx.WriteString(colName)
+		ctx.WriteString(cursorColName(sel, ob))
+		if orderByRelated(sel, ob) {
+			// The seek filter matches on the looked up related document
+			ctx.WriteString(`","field":"`)
+			ctx.WriteString(orderByLookupField(ob.Col.Table))
+			ctx.WriteString(`.`)
+			ctx.WriteString(colName)
+		}
 		ctx.WriteString(`","order":"`)
 		if ob.Order == qcode.OrderDesc {
 			ctx.WriteString(`desc`)
@@ -2458,81 +3460,44 @@ func (d *MongoDBDialect) renderLookupStageWithQC(ctx Context, parent, child *qco
 	}
 
 	// Check for M2M via join table (sel.Joins contains intermediate tables)
-	if len(child.Joins) > 0 {
+	// the joins of an ordering on columns of related tables are local
+	if len(child.Joins) > 0 && !child.Joins[0].Local {
 		d.renderM2MLookupViaJoinTable(ctx, parent, child, qc)
 		return
 	}
 
-	ctx.WriteString(`{"$lookup":{`)
-	ctx.WriteString(`"from":"`)
-	ctx.WriteString(child.Table)
-	ctx.WriteString(`"`)
-
-	// Determine local and foreign fields based on relationship
-	// rel.Left = referenced table (users), rel.Right = table with FK (products)
-	// For products->owner (users): localField=owner_id (from products), foreignField=_id (from users)
-	rel := child.Rel
-
-	var localField, foreignField string
-	var isLocalArray, isForeignArray bool
+	// Static lookup tables have no collection, their rows are added to the
+	// lookup pipeline with $documents (MongoDB 5.1+)
+	static := child.StaticRows != nil
 
-	switch rel.Type {
-	case sdata.RelOneToOne, sdata.RelOneToMany:
-		// rel.Right = table with FK (e.g., products.owner_id)
-		// rel.Left = referenced table (e.g., users.id)
-		// We need to determine which side is local (parent) vs foreign (child)
-		if rel.Right.Ti.Name == parent.Table {
-			// FK is on parent: products -> owner lookup (products.owner_id -> users._id)
-			localField = rel.Right.Col.Name  // owner_id (FK on parent)
-			foreignField = rel.Left.Col.Name // id (PK on child)
-			isLocalArray = rel.Right.Col.Array
-			isForeignArray = rel.Left.Col.Array
-		} else {
-			// FK is on child: users -> products lookup (users._id <- products.owner_id)
-			localField = rel.Left.Col.Name    // id (PK on parent)
-			foreignField = rel.Right.Col.Name // owner_id (FK on child)
-			isLocalArray = rel.Left.Col.Array
-			isForeignArray = rel.Right.Col.Array
-		}
-		if localField == "id" {
-			localField = "_id"
-		}
-		if foreignField == "id" {
-			foreignField = "_id"
-		}
-	default:
-		// Default: assume parent._id -> child.parent_id
-		localField = "_id"
-		foreignField = parent.Table + "_id"
+	ctx.WriteString(`{"$lookup":{`)
+	if !static {
+		ctx.WriteString(`"from":"`)
+		ctx.WriteString(child.Table)
+		ctx.WriteString(`",`)
 	}
 
+	localField, foreignField, isLocalArray, isForeignArray := lookupJoin(parent.Table, child.Rel)
+
 	// Use $lookup with pipeline to select only requested fields and apply aliases
-	ctx.WriteString(`,"let":{"joinValue":"$`)
+	ctx.WriteString(`"let":{"joinValue":"$`)
 	ctx.WriteString(localField)
-	ctx.WriteString(`"},"pipeline":[{"$match":{"$expr":{`)
-
-	// For array columns, use $in instead of $eq
-	// - If localField is an array (e.g., category_ids), check if foreignField is IN the array
-	// - If foreignField is an array (reverse lookup), check if localField is IN that array
-	if isLocalArray {
-		// Forward array lookup: products.category_ids -> categories._id
-		// Check if category._id is IN the category_ids array
-		ctx.WriteString(`"$in":["$`)
-		ctx.WriteString(foreignField)
-		ctx.WriteString(`","$$joinValue"]`)
-	} else if isForeignArray {
-		// Reverse array lookup: categories._id -> products.category_ids
-		// Check if the category ID is IN products.category_ids
-		ctx.WriteString(`"$in":["$$joinValue","$`)
-		ctx.WriteString(foreignField)
-		ctx.WriteString(`"]`)
-	} else {
-		// Standard scalar lookup: use $eq
-		ctx.WriteString(`"$eq":["$`)
-		ctx.WriteString(foreignField)
-		ctx.WriteString(`","$$joinValue"]`)
+	ctx.WriteString(`"},"pipeline":[`)
+	if static {
+		ctx.WriteString(`{"$documents":`)
+		ctx.WriteString(string(child.StaticRows))
+		ctx.WriteString(`},`)
+	}
+	d.renderLookupCondition(ctx, child)
+	d.renderLookupJoinMatch(ctx, foreignField, isLocalArray, isForeignArray)
+
+	// the related documents that are soft deleted are left out
+	if child.SoftDelete != nil {
+		ctx.WriteString(`,{"$match":{`)
+		d.renderExpression(ctx, child.SoftDelete)
+		ctx.WriteString(`}}`)
 	}
-	ctx.WriteString(`}}}`)
+	d.renderLookupWhere(ctx, child)
 
 	// Add nested lookups for grandchildren FIRST (before $project)
 	// This is important for embedded JSON tables which use $unwind/$group
@@ -2553,6 +3518,14 @@ func (d *MongoDBDialect) renderLookupStageWithQC(ctx Context, parent, child *qco
 		}
 	}
 
+	// An ordering on columns of related tables is sorted before the $project
+	// since the looked up related documents are not projected
+	sortRelated := hasOrderByJoins(child) && len(child.OrderBy) > 0
+	if sortRelated {
+		ctx.WriteString(`,`)
+		d.renderSortStage(ctx, child)
+	}
+
 	// Add $project stage within the pipeline to select only requested fields
 	// Note: Skip $project if there was embedded processing - it handles projection differently
 	// (The $group stage in embedded processing already renames fields to aliases)
@@ -2576,122 +3549,258 @@ func (d *MongoDBDialect) renderLookupStageWithQC(ctx Context, parent, child *qco
 			}
 		}
 
-		ctx.WriteString(`,{"$project":{`)
-		// Only exclude _id if we're not including id field
-		// If we're including id, we'll rename it and translateIDFieldsBack will handle conversion
-		first := true
-		if !hasIdField {
-			ctx.WriteString(`"_id":0`)
-			first = false
+		// A projection of only "_id":0 would exclude _id and keep the rest of
+		// the document, an intermediate node whose fields and grandchildren
+		// are all skipped is an empty object
+		if !hasLookupProjection(child, qc) {
+			ctx.WriteString(`,{"$replaceRoot":{"newRoot":{}}}`)
+			d.renderFlattenStages(ctx, child, qc)
+		} else {
+			d.renderLookupProjection(ctx, child, qc, hasIdField)
 		}
-		for _, f := range child.Fields {
-			// Skip function fields - MongoDB doesn't support SQL-style aggregations
-			if f.Type == qcode.FieldTypeFunc {
-				continue
-			}
-			// SkipTypeDrop: completely skip field (@add/@remove directives)
-			if f.SkipRender == qcode.SkipTypeDrop {
-				continue
-			}
-			if !first {
+	} else if child.Exists && !hasEmbeddedChild {
+		// only whether there is a match is returned
+		ctx.WriteString(`,{"$project":{"_id":1}}`)
+	}
+
+	// Add $sort stage if there's ordering, or default sort by _id for consistent results
+	// Use $sort_ordered to preserve field order (Go maps don't preserve order)
+	switch {
+	case sortRelated:
+		// sorted before the $project
+	case len(child.OrderBy) > 0:
+		ctx.WriteString(`,{"$sort_ordered":[`)
+		for i, ob := range child.OrderBy {
+			if i > 0 {
 				ctx.WriteString(`,`)
 			}
-			// Use alias if present, otherwise use column name
-			outputName := f.FieldName
-			colName := f.Col.Name
-
-			// Translate id -> _id for MongoDB (both source and output)
-			// The translateIDFieldsBack in pipeline.go will convert _id back to id
+			colName := ob.Col.Name
 			if colName == "id" {
 				colName = "_id"
 			}
-			if outputName == "id" {
-				outputName = "_id"
-			}
-			ctx.WriteString(`"`)
-			ctx.WriteString(outputName)
-			ctx.WriteString(`":`)
-
-			// Handle based on directive type
-			if f.FieldFilter.Exp != nil {
-				// Variable-based directive: use $cond for runtime evaluation
-				d.renderFieldWithCondition(ctx, f, colName)
-			} else if f.SkipRender == qcode.SkipTypeNulled ||
-				f.SkipRender == qcode.SkipTypeUserNeeded ||
-				f.SkipRender == qcode.SkipTypeBlocked {
-				// Role-based @skip/@include: static null
-				ctx.WriteString(`null`)
+			ctx.WriteString(`["`)
+			ctx.WriteString(colName)
+			ctx.WriteString(`",`)
+			if ob.Order == qcode.OrderDesc {
+				ctx.WriteString(`-1`)
 			} else {
-				// Normal field - use $colName syntax for child lookups
-				ctx.WriteString(`"$`)
-				ctx.WriteString(colName)
-				ctx.WriteString(`"`)
-			}
-			first = false
-		}
-		// Also include grandchild field names (for embedded or looked up fields)
-		if qc != nil {
-			for _, grandchildID := range child.Children {
-				grandchild := &qc.Selects[grandchildID]
-				if grandchild.SkipRender != qcode.SkipTypeNone {
-					continue
-				}
-				if !first {
-					ctx.WriteString(`,`)
-				}
-				ctx.WriteString(`"`)
-				ctx.WriteString(grandchild.FieldName)
-				ctx.WriteString(`":1`)
-				first = false
+				ctx.WriteString(`1`)
 			}
+			ctx.WriteString(`]`)
 		}
-		ctx.WriteString(`}}`)
+		ctx.WriteString(`]}`)
+	default:
+		// Default sort by _id for consistent ordering
+		ctx.WriteString(`,{"$sort_ordered":[["_id",1]]}`)
 	}
 
-	// Add $sort stage if there's ordering, or default sort by _id for consistent results
-	// Use $sort_ordered to preserve field order (Go maps don't preserve order)
-	if len(child.OrderBy) > 0 {
-		ctx.WriteString(`,{"$sort_ordered":[`)
-		for i, ob := range child.OrderBy {
-			if i > 0 {
+	// Add $limit stage for nested queries
+	d.renderNestedLimit(ctx, child)
+	d.renderHelperCleanup(ctx, child, qc)
+
+	ctx.WriteString(`],"as":"`)
+	ctx.WriteString(child.FieldName)
+	ctx.WriteString(`"}}`)
+}
+
+// renderLookupProjection renders the $project of a lookup pipeline, it keeps
+// the requested fields of the child and the fields of its grandchildren
+func (d *MongoDBDialect) renderLookupProjection(ctx Context, child *qcode.Select, qc *qcode.QCode, hasIdField bool) {
+	ctx.WriteString(`,{"$project":{`)
+	// Only exclude _id if we're not including id field
+	// If we're including id, we'll rename it and translateIDFieldsBack will handle conversion
+	first := true
+	if !hasIdField {
+		ctx.WriteString(`"_id":0`)
+		first = false
+	}
+	for _, f := range child.Fields {
+		// Skip function fields - MongoDB doesn't support SQL-style aggregations
+		if f.Type == qcode.FieldTypeFunc {
+			continue
+		}
+		// SkipTypeDrop: completely skip field (@add/@remove directives)
+		if f.SkipRender == qcode.SkipTypeDrop {
+			continue
+		}
+		if !first {
+			ctx.WriteString(`,`)
+		}
+		// Use alias if present, otherwise use column name
+		outputName := f.FieldName
+		colName := f.Col.Name
+
+		// Translate id -> _id for MongoDB (both source and output)
+		// The translateIDFieldsBack in pipeline.go will convert _id back to id
+		if colName == "id" {
+			colName = "_id"
+		}
+		if outputName == "id" {
+			outputName = "_id"
+		}
+		ctx.WriteString(`"`)
+		ctx.WriteString(outputName)
+		ctx.WriteString(`":`)
+
+		// Handle based on directive type
+		if f.FieldFilter.Exp != nil {
+			// Variable-based directive: use $cond for runtime evaluation
+			d.renderFieldWithCondition(ctx, f, colName)
+		} else if f.SkipRender == qcode.SkipTypeNulled ||
+			f.SkipRender == qcode.SkipTypeUserNeeded ||
+			f.SkipRender == qcode.SkipTypeBlocked {
+			// Role-based @skip/@include: static null
+			ctx.WriteString(`null`)
+		} else {
+			// Normal field - use $colName syntax for child lookups
+			d.renderFieldRef(ctx, f, colName)
+		}
+		first = false
+	}
+	// Also include grandchild field names (for embedded or looked up fields)
+	if qc != nil {
+		for _, grandchildID := range child.Children {
+			grandchild := &qc.Selects[grandchildID]
+			if grandchild.SkipRender != qcode.SkipTypeNone && !lookupChildNulled(grandchild) {
+				continue
+			}
+			if !first {
 				ctx.WriteString(`,`)
 			}
-			colName := ob.Col.Name
-			if colName == "id" {
-				colName = "_id"
+			if lookupChildNulled(grandchild) {
+				ctx.WriteString(`"`)
+				ctx.WriteString(grandchild.FieldName)
+				ctx.WriteString(`":null`)
+				first = false
+				continue
 			}
-			ctx.WriteString(`["`)
-			ctx.WriteString(colName)
-			ctx.WriteString(`",`)
-			if ob.Order == qcode.OrderDesc {
-				ctx.WriteString(`-1`)
+			if grandchild.Rel.Type == sdata.RelPolymorphic {
+				ctx.WriteString(`"`)
+				ctx.WriteString(grandchild.FieldName)
+				ctx.WriteString(`":1`)
 			} else {
-				ctx.WriteString(`1`)
+				d.renderRelationshipProjectField(ctx, grandchild, qc)
 			}
-			ctx.WriteString(`]`)
+			first = false
 		}
-		ctx.WriteString(`]}`)
-	} else {
-		// Default sort by _id for consistent ordering
-		ctx.WriteString(`,{"$sort_ordered":[["_id",1]]}`)
 	}
+	ctx.WriteString(`}}`)
+	d.renderFlattenStages(ctx, child, qc)
+}
 
-	// Add $limit stage for nested queries
-	if !child.Paging.NoLimit && (child.Paging.Limit > 0 || child.Paging.LimitVar != "") {
-		ctx.WriteString(`,{"$limit":`)
-		if child.Paging.LimitVar != "" {
-			ctx.WriteString(`"`)
-			ctx.AddParam(Param{Name: child.Paging.LimitVar, Type: "integer"})
-			ctx.WriteString(`"`)
+// hasLookupProjection returns true if the $project of a lookup pipeline
+// includes any field of the child or of its grandchildren
+func hasLookupProjection(child *qcode.Select, qc *qcode.QCode) bool {
+	for _, f := range child.Fields {
+		if f.Type != qcode.FieldTypeFunc && f.SkipRender != qcode.SkipTypeDrop {
+			return true
+		}
+	}
+	if qc == nil {
+		return false
+	}
+	for _, id := range child.Children {
+		gc := &qc.Selects[id]
+		if gc.SkipRender == qcode.SkipTypeNone || lookupChildNulled(gc) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupChildNulled returns true if a skipped relationship is rendered as null
+func lookupChildNulled(sel *qcode.Select) bool {
+	return sel.SkipRender == qcode.SkipTypeUserNeeded ||
+		sel.SkipRender == qcode.SkipTypeBlocked ||
+		sel.SkipRender == qcode.SkipTypeNulled
+}
+
+// lookupJoin returns the fields of the parent and the child a lookup joins
+// on and whether they are arrays.
+// rel.Left = referenced table (users), rel.Right = table with FK (products)
+func lookupJoin(parentTable string, rel sdata.DBRel) (localField, foreignField string, isLocalArray, isForeignArray bool) {
+	switch rel.Type {
+	case sdata.RelOneToOne, sdata.RelOneToMany:
+		leftArray, rightArray := joinArrays(rel)
+
+		// rel.Right = table with FK (e.g., products.owner_id)
+		// rel.Left = referenced table (e.g., users.id)
+		// We need to determine which side is local (parent) vs foreign (child)
+		if rel.Right.Ti.Name == parentTable {
+			// FK is on parent: products -> owner lookup (products.owner_id -> users._id)
+			localField = rel.Right.Col.Name  // owner_id (FK on parent)
+			foreignField = rel.Left.Col.Name // id (PK on child)
+			isLocalArray = rightArray
+			isForeignArray = leftArray
 		} else {
-			ctx.WriteString(strconv.Itoa(int(child.Paging.Limit)))
+			// FK is on child: users -> products lookup (users._id <- products.owner_id)
+			localField = rel.Left.Col.Name    // id (PK on parent)
+			foreignField = rel.Right.Col.Name // owner_id (FK on child)
+			isLocalArray = leftArray
+			isForeignArray = rightArray
 		}
-		ctx.WriteString(`}`)
+		if localField == "id" {
+			localField = "_id"
+		}
+		if foreignField == "id" {
+			foreignField = "_id"
+		}
+	default:
+		// Default: assume parent._id -> child.parent_id
+		localField = "_id"
+		foreignField = parentTable + "_id"
 	}
+	return
+}
 
-	ctx.WriteString(`],"as":"`)
-	ctx.WriteString(child.FieldName)
-	ctx.WriteString(`"}}`)
+// joinArrays returns whether the left and right columns of a relationship
+// hold the array of ids. The array side configured on the foreign key column
+// overrides the one inferred from the columns
+func joinArrays(rel sdata.DBRel) (left, right bool) {
+	fkRight := rel.Right.Col.FKArraySide != ""
+	side := rel.Right.Col.FKArraySide
+	if !fkRight {
+		side = rel.Left.Col.FKArraySide
+	}
+
+	fk, related := side == "column", side == "related"
+	switch {
+	case side == "":
+		return rel.Left.Col.Array, rel.Right.Col.Array
+	case fkRight:
+		return related, fk
+	default:
+		return fk, related
+	}
+}
+
+// renderLookupJoinMatch renders the $match stage of a lookup pipeline that
+// matches the foreign field against the $$joinValue of the parent
+func (d *MongoDBDialect) renderLookupJoinMatch(ctx Context, foreignField string, isLocalArray, isForeignArray bool) {
+	ctx.WriteString(`{"$match":{"$expr":{`)
+
+	// For array columns, use $in instead of $eq
+	// - If localField is an array (e.g., category_ids), check if foreignField is IN the array
+	// - If foreignField is an array (reverse lookup), check if localField is IN that array
+	if isLocalArray {
+		// Forward array lookup: products.category_ids -> categories._id
+		// Check if category._id is IN the category_ids array
+		ctx.WriteString(`"$in":["$`)
+		ctx.WriteString(foreignField)
+		ctx.WriteString(`","$$joinValue"]`)
+	} else if isForeignArray {
+		// Reverse array lookup: categories._id -> products.category_ids
+		// Check if the category ID is IN products.category_ids
+		ctx.WriteString(`"$in":["$$joinValue","$`)
+		ctx.WriteString(foreignField)
+		ctx.WriteString(`"]`)
+	} else {
+		// Standard scalar lookup: use $eq
+		ctx.WriteString(`"$eq":["$`)
+		ctx.WriteString(foreignField)
+		ctx.WriteString(`","$$joinValue"]`)
+	}
+	ctx.WriteString(`}}}`)
 }
 
 // renderRecursiveLookup handles recursive (self-referential) relationships using $graphLookup
@@ -2735,6 +3844,8 @@ func (d *MongoDBDialect) renderRecursiveLookup(ctx Context, parent, child *qcode
 		ctx.WriteString(`"`)
 	}
 
+	d.renderRestrictSearch(ctx, child)
+
 	// Add depthField to track hierarchy level
 	ctx.WriteString(`,"depthField":"__depth"`)
 
@@ -2746,6 +3857,91 @@ func (d *MongoDBDialect) renderRecursiveLookup(ctx Context, parent, child *qcode
 	d.renderRecursiveLookupPostProcessing(ctx, child, qc, find)
 }
 
+// renderRestrictSearch adds the predicates of the where clause that can be
+// matched during the traversal as restrictSearchWithMatch, so $graphLookup
+// neither returns the documents they exclude nor walks past them
+func (d *MongoDBDialect) renderRestrictSearch(ctx Context, sel *qcode.Select) {
+	exp := restrictSearchExp(sel.Where.Exp, "__rcte_"+sel.Rel.Right.Ti.Name)
+	if exp == nil {
+		return
+	}
+	ctx.WriteString(`,"restrictSearchWithMatch":{`)
+	d.renderExpression(ctx, exp)
+	ctx.WriteString(`}`)
+}
+
+// restrictSearchExp returns the part of the where clause of a recursive
+// selector that can be used as restrictSearchWithMatch or nil if there is
+// none. The recursive join conditions referencing the rcte table are left
+// out, $graphLookup follows the relationship itself. Predicates of an and
+// that can't be pushed are left to the filter applied after the traversal
+func restrictSearchExp(exp *qcode.Exp, rcte string) *qcode.Exp {
+	if exp == nil {
+		return nil
+	}
+	if exp.Op != qcode.OpAnd {
+		if !hasTableRef(exp, rcte) && canRestrictSearch(exp) {
+			return exp
+		}
+		return nil
+	}
+
+	// the recursive join conditions are an and of their own
+	for _, c := range exp.Children {
+		if c.Left.Table == rcte || c.Right.Table == rcte {
+			return nil
+		}
+	}
+
+	var children []*qcode.Exp
+	same := true
+	for _, c := range exp.Children {
+		c1 := restrictSearchExp(c, rcte)
+		if c1 != nil {
+			children = append(children, c1)
+		}
+		same = same && (c1 == c)
+	}
+	switch {
+	case same:
+		return exp
+	case len(children) == 0:
+		return nil
+	case len(children) == 1:
+		return children[0]
+	}
+	return &qcode.Exp{Op: qcode.OpAnd, Children: children}
+}
+
+// canRestrictSearch returns true if the expression can be matched with the
+// query filter syntax of restrictSearchWithMatch, the recursive join
+// conditions and predicates that need a lookup or an aggregation
+// expression can't
+func canRestrictSearch(exp *qcode.Exp) bool {
+	switch exp.Op {
+	case qcode.OpAnd, qcode.OpOr, qcode.OpNot:
+		if len(exp.Children) == 0 {
+			return false
+		}
+		for _, c := range exp.Children {
+			if !canRestrictSearch(c) {
+				return false
+			}
+		}
+		return true
+
+	case qcode.OpEquals, qcode.OpNotEquals, qcode.OpGreaterThan,
+		qcode.OpGreaterOrEquals, qcode.OpLesserThan, qcode.OpLesserOrEquals,
+		qcode.OpIn, qcode.OpNotIn, qcode.OpLike, qcode.OpILike,
+		qcode.OpNotLike, qcode.OpNotILike, qcode.OpRegex, qcode.OpIRegex,
+		qcode.OpIsNull, qcode.OpIsNotNull:
+		return exp.Left.Col.Name != "" && exp.Right.Col.Name == "" &&
+			!strings.HasPrefix(exp.Left.Table, "__") &&
+			!strings.HasPrefix(exp.Right.Table, "__")
+	}
+	return false
+}
+
 // renderRecursiveLookupPostProcessing adds $addFields and other stages to process
 // the $graphLookup results (filtering, ordering, limiting)
 func (d *MongoDBDialect) renderRecursiveLookupPostProcessing(ctx Context, child *qcode.Select, qc *qcode.QCode, find string) {
@@ -2769,9 +3965,15 @@ func (d *MongoDBDialect) renderRecursiveLookupPostProcessing(ctx Context, child
 
 	ctx.WriteString(`}}},"sortBy":{`)
 
-	// Apply ordering
+	// Apply ordering, when the order columns are not unique _id is added
+	// as the last sort key so nodes with equal values keep a stable order,
+	// in the same direction as the default order of the nodes
 	if len(child.OrderBy) > 0 {
-		for i, ob := range child.OrderBy {
+		obs := d.sortOrder(child)
+		if len(obs) > len(child.OrderBy) && (find == "parents" || find == "parent") {
+			obs[len(obs)-1].Order = qcode.OrderDesc
+		}
+		for i, ob := range obs {
 			if i > 0 {
 				ctx.WriteString(`,`)
 			}
@@ -2959,6 +4161,22 @@ func (d *MongoDBDialect) renderRecursiveWhereCondition(ctx Context, exp *qcode.E
 		ctx.WriteString(`",`)
 		d.renderRecursiveComparisonValue(ctx, exp)
 		ctx.WriteString(`]`)
+	case qcode.OpIn, qcode.OpNotIn:
+		colName := exp.Left.Col.Name
+		if colName == "id" {
+			colName = "_id"
+		}
+		if exp.Op == qcode.OpNotIn {
+			ctx.WriteString(`"$not":[{`)
+		}
+		ctx.WriteString(`"$in":["$$item.`)
+		ctx.WriteString(colName)
+		ctx.WriteString(`",`)
+		d.renderListValue(ctx, exp)
+		ctx.WriteString(`]`)
+		if exp.Op == qcode.OpNotIn {
+			ctx.WriteString(`}]`)
+		}
 	default:
 		// Fallback: true
 		ctx.WriteString(`"$literal":true`)
@@ -2967,11 +4185,7 @@ func (d *MongoDBDialect) renderRecursiveWhereCondition(ctx Context, exp *qcode.E
 
 // renderRecursiveComparisonValue renders a value for comparison in recursive where
 func (d *MongoDBDialect) renderRecursiveComparisonValue(ctx Context, exp *qcode.Exp) {
-	if exp.Right.ValType == qcode.ValVar {
-		ctx.AddParam(Param{Name: exp.Right.Val, Type: "any"})
-	} else {
-		ctx.WriteString(exp.Right.Val)
-	}
+	d.renderValue(ctx, exp)
 }
 
 // renderPolymorphicLookups handles polymorphic (union type) relationships
@@ -2987,15 +4201,17 @@ func (d *MongoDBDialect) renderPolymorphicLookups(ctx Context, parent, polyChild
 	}
 
 	// For each union member (e.g., users, products), render a $lookup
-	for i, childID := range polyChild.Children {
+	firstMember := true
+	for _, childID := range polyChild.Children {
 		unionMember := &qc.Selects[childID]
 		if unionMember.SkipRender != qcode.SkipTypeNone {
 			continue
 		}
 
-		if i > 0 {
+		if !firstMember {
 			ctx.WriteString(`,`)
 		}
+		firstMember = false
 
 		// Use a special field name for the lookup result (e.g., "__poly_users")
 		lookupFieldName := "__poly_" + unionMember.Table
@@ -3013,8 +4229,21 @@ func (d *MongoDBDialect) renderPolymorphicLookups(ctx Context, parent, polyChild
 		ctx.WriteString(unionMember.Table)
 		ctx.WriteString(`"]},{"$eq":["$_id","$$idVal"]}]}}}`)
 
+		// Nested lookups for the member's own children (e.g., the author of a post)
+		// are rendered within the member pipeline before its $project
+		var children []*qcode.Select
+		for _, grandchildID := range unionMember.Children {
+			grandchild := &qc.Selects[grandchildID]
+			if grandchild.SkipRender != qcode.SkipTypeNone {
+				continue
+			}
+			ctx.WriteString(`,`)
+			d.renderLookupStageWithQC(ctx, unionMember, grandchild, qc)
+			children = append(children, grandchild)
+		}
+
 		// Add $project stage within the pipeline to select only requested fields
-		if len(unionMember.Fields) > 0 {
+		if len(unionMember.Fields) > 0 || len(children) > 0 {
 			hasIdField := false
 			for _, f := range unionMember.Fields {
 				if f.Type == qcode.FieldTypeFunc {
@@ -3048,6 +4277,17 @@ func (d *MongoDBDialect) renderPolymorphicLookups(ctx Context, parent, polyChild
 				ctx.WriteString(`":1`)
 				first = false
 			}
+			for _, grandchild := range children {
+				if !first {
+					ctx.WriteString(`,`)
+				}
+				if grandchild.Rel.Type == sdata.RelPolymorphic {
+					d.renderPolymorphicProjectField(ctx, grandchild, qc)
+				} else {
+					d.renderRelationshipProjectField(ctx, grandchild, qc)
+				}
+				first = false
+			}
 			ctx.WriteString(`}}`)
 		}
 
@@ -3097,18 +4337,29 @@ func (d *MongoDBDialect) renderM2MLookupViaJoinTable(ctx Context, parent, child
 	ctx.WriteString(`"`)
 	ctx.WriteString(`,"let":{"parentId":"$_id"}`)
 	ctx.WriteString(`,"pipeline":[`)
+	d.renderLookupCondition(ctx, child)
 
 	// Match join table records where FK matches parent ID
 	ctx.WriteString(`{"$match":{"$expr":{"$eq":["$`)
 	ctx.WriteString(parentToJoinFK)
 	ctx.WriteString(`","$$parentId"]}}}`)
 
+	// Targets linked more than once by the join table are looked up once
+	// from the distinct target ids unless the duplicates are kept
+	localField := joinToTargetFK
+	if !child.KeepDuplicates {
+		ctx.WriteString(`,{"$group":{"_id":null,"_targetIds":{"$addToSet":"$`)
+		ctx.WriteString(joinToTargetFK)
+		ctx.WriteString(`"}}}`)
+		localField = "_targetIds"
+	}
+
 	// Nested lookup to target table
 	ctx.WriteString(`,{"$lookup":{"from":"`)
 	ctx.WriteString(targetTable)
 	ctx.WriteString(`"`)
 	ctx.WriteString(`,"localField":"`)
-	ctx.WriteString(joinToTargetFK)
+	ctx.WriteString(localField)
 	ctx.WriteString(`"`)
 	ctx.WriteString(`,"foreignField":"`)
 	ctx.WriteString(targetPK)
@@ -3118,6 +4369,13 @@ func (d *MongoDBDialect) renderM2MLookupViaJoinTable(ctx Context, parent, child
 	// Unwind and replace root with target
 	ctx.WriteString(`,{"$unwind":"$_target"}`)
 	ctx.WriteString(`,{"$replaceRoot":{"newRoot":"$_target"}}`)
+	if child.SoftDelete != nil {
+		ctx.WriteString(`,{"$match":{`)
+		d.renderExpression(ctx, child.SoftDelete)
+		ctx.WriteString(`}}`)
+	}
+	d.renderLookupWhere(ctx, child)
+	d.renderNestedLimit(ctx, child)
 
 	// Add $project for requested fields if specified
 	// Note: mongodriver's translateFieldsInMap converts "id" -> "_id" in keys,
@@ -3179,7 +4437,7 @@ func (d *MongoDBDialect) renderProjectStageWithChildren(ctx Context, sel *qcode.
 	// First, count how many visible fields we have (excluding dropped fields)
 	visibleFieldCount := 0
 	for _, f := range sel.Fields {
-		if f.Type == qcode.FieldTypeFunc {
+		if f.Type == qcode.FieldTypeFunc && !isSearchRank(f) && !isGeoDistance(f) {
 			continue
 		}
 		if f.SkipRender != qcode.SkipTypeDrop {
@@ -3235,7 +4493,7 @@ func (d *MongoDBDialect) renderProjectStageWithChildren(ctx Context, sel *qcode.
 
 	// Add parent fields (skip function fields for regular projection)
 	for _, f := range sel.Fields {
-		if f.Type == qcode.FieldTypeFunc {
+		if f.Type == qcode.FieldTypeFunc && !isSearchRank(f) && !isGeoDistance(f) {
 			continue
 		}
 		// SkipTypeDrop: completely skip field (@add/@remove directives)
@@ -3246,16 +4504,38 @@ func (d *MongoDBDialect) renderProjectStageWithChildren(ctx Context, sel *qcode.
 			ctx.WriteString(`,`)
 		}
 
-		// Source column name (for MongoDB field reference)
-		sourceCol := f.Col.Name
-		if sourceCol == "id" {
-			sourceCol = "_id"
-		}
-
-		// Output field name - use FieldName for remote ID fields (prefixed with __)
+		// The text score was added to the documents after the $text match
+		if isSearchRank(f) {
+			ctx.WriteString(`"`)
+			ctx.WriteString(f.FieldName)
+			ctx.WriteString(`":"$` + searchRankField + `"`)
+			first = false
+			continue
+		}
+
+		// The distance was added to the documents by the $geoNear stage
+		if isGeoDistance(f) {
+			ctx.WriteString(`"`)
+			ctx.WriteString(f.FieldName)
+			ctx.WriteString(`":`)
+			d.renderGeoDistance(ctx, sel)
+			first = false
+			continue
+		}
+
+		// Source column name (for MongoDB field reference)
+		sourceCol := f.Col.Name
+		if sourceCol == "id" {
+			sourceCol = "_id"
+		}
+
+		// Output field name - use FieldName for remote ID fields (prefixed with __)
 		// Remote ID fields have FieldName like "__payments_stripe_id" but Col.Name is "stripe_id"
+		// The aggregates of an array column are aliased like scores_sum: scores @sum
 		outputName := f.Col.Name
-		if strings.HasPrefix(f.FieldName, "__") {
+		if strings.HasPrefix(f.FieldName, "__") ||
+			f.ArrayProj.Type == qcode.ArrayProjSum || f.ArrayProj.Type == qcode.ArrayProjAvg ||
+			f.Labels != nil || f.DateDiff != nil {
 			outputName = f.FieldName
 		}
 		if outputName == "id" {
@@ -3275,6 +4555,10 @@ func (d *MongoDBDialect) renderProjectStageWithChildren(ctx Context, sel *qcode.
 			f.SkipRender == qcode.SkipTypeBlocked {
 			// Role-based @skip/@include: static null
 			ctx.WriteString(`null`)
+		} else if f.Mask.Type != qcode.MaskTypeNone || f.ArrayProj.Type != qcode.ArrayProjNone ||
+			f.Compare != nil || len(f.JSONPaths) != 0 || f.Labels != nil || f.DateDiff != nil {
+			// Role-based column mask, array size/slice, comparison or json paths
+			d.renderFieldRef(ctx, f, sourceCol)
 		} else if outputName != sourceCol {
 			// Remote ID field - reference the source column with $ prefix
 			ctx.WriteString(`"$`)
@@ -3295,9 +4579,10 @@ func (d *MongoDBDialect) renderProjectStageWithChildren(ctx Context, sel *qcode.
 			projectedCols[f.Col.Name] = true
 		}
 
-		for _, ob := range sel.OrderBy {
+		for _, ob := range d.sortOrder(sel) {
 			colName := ob.Col.Name
-			if projectedCols[colName] {
+			related := orderByRelated(sel, ob)
+			if projectedCols[colName] && !related {
 				continue // Already projected
 			}
 			if !first {
@@ -3306,11 +4591,13 @@ func (d *MongoDBDialect) renderProjectStageWithChildren(ctx Context, sel *qcode.
 			// Use __cursor_ prefix for order-by columns not in result fields
 			// This allows driver to extract cursor values without polluting result
 			mongoCol := colName
-			if mongoCol == "id" {
+			if related {
+				mongoCol = orderByLookupField(ob.Col.Table) + "." + colName
+			} else if mongoCol == "id" {
 				mongoCol = "_id"
 			}
 			ctx.WriteString(`"__cursor_`)
-			ctx.WriteString(colName)
+			ctx.WriteString(cursorColName(sel, ob))
 			ctx.WriteString(`":"$`)
 			ctx.WriteString(mongoCol)
 			ctx.WriteString(`"`)
@@ -3351,21 +4638,185 @@ func (d *MongoDBDialect) renderProjectStageWithChildren(ctx Context, sel *qcode.
 			continue
 		}
 
-		// For singular relationships (e.g., owner), extract first element
-		if child.Singular {
-			ctx.WriteString(`"`)
-			ctx.WriteString(child.FieldName)
-			ctx.WriteString(`":{"$arrayElemAt":["$`)
-			ctx.WriteString(child.FieldName)
-			ctx.WriteString(`",0]}`)
+		d.renderRelationshipProjectField(ctx, child, qc)
+		first = false
+	}
+
+	ctx.WriteString(`}}`)
+	d.renderFlattenStages(ctx, sel, qc)
+}
+
+// renderFlattenStages merges the fields of the singular children marked with
+// @flatten into the parent document. The parent's fields are merged last so
+// they win on a name collision and a child with no match leaves the parent
+// unchanged. The child's own field is then removed.
+func (d *MongoDBDialect) renderFlattenStages(ctx Context, sel *qcode.Select, qc *qcode.QCode) {
+	if qc == nil {
+		return
+	}
+	for _, cid := range sel.Children {
+		child := &qc.Selects[cid]
+		if !child.Flatten || !child.Singular || child.SkipRender != qcode.SkipTypeNone {
+			continue
+		}
+		ctx.WriteString(`,{"$replaceRoot":{"newRoot":{"$mergeObjects":[{"$ifNull":["$`)
+		ctx.WriteString(child.FieldName)
+		ctx.WriteString(`",{}]},"$$ROOT"]}}},{"$project":{"`)
+		ctx.WriteString(child.FieldName)
+		ctx.WriteString(`":0}}`)
+	}
+}
+
+// renderRelationshipProjectField projects a looked-up relationship with a
+// predictable shape: singular relationships resolve to their first match or
+// null, plural relationships always resolve to an array (empty when there
+// are no matches) instead of being left missing from the document.
+// Singular relationships to a table with a default document resolve to it
+// instead of null.
+// A relationship with a variable @include or @skip is null when excluded.
+// A relationship with @exists is true when it has a match and its looked
+// up documents are left out.
+func (d *MongoDBDialect) renderRelationshipProjectField(ctx Context, child *qcode.Select, qc *qcode.QCode) {
+	ctx.WriteString(`"`)
+	ctx.WriteString(child.FieldName)
+	ctx.WriteString(`":`)
+	if child.Field.FieldFilter.Exp != nil {
+		ctx.WriteString(`{"$cond":{"if":`)
+		d.renderBoolExpression(ctx, child.Field.FieldFilter.Exp)
+		ctx.WriteString(`,"then":`)
+	}
+	switch {
+	case child.Exists:
+		ctx.WriteString(`{"$gt":[{"$size":{"$ifNull":["$`)
+		ctx.WriteString(child.FieldName)
+		ctx.WriteString(`",[]]}},0]}`)
+	case child.Singular:
+		ctx.WriteString(`{"$ifNull":[{"$arrayElemAt":["$`)
+		ctx.WriteString(child.FieldName)
+		ctx.WriteString(`",0]},`)
+		d.renderDefaultDoc(ctx, child, qc)
+		ctx.WriteString(`]}`)
+	default:
+		ctx.WriteString(`{"$ifNull":["$`)
+		ctx.WriteString(child.FieldName)
+		ctx.WriteString(`",[]]}`)
+	}
+	if child.Field.FieldFilter.Exp != nil {
+		ctx.WriteString(`,"else":null}}`)
+	}
+}
+
+// renderLookupWhere renders the filter of a related selector as a $match of
+// its lookup pipeline. The filter includes the filters of the role so the
+// related documents the role cannot see are left out like at the root.
+// The counts of the related documents of the child are not looked up in
+// the pipeline and are left out of the $match.
+func (d *MongoDBDialect) renderLookupWhere(ctx Context, child *qcode.Select) {
+	exp := filterOutJoinConditions(child.Where.Exp)
+	exp = filterOutGeoExpressions(filterOutVariableConditions(exp))
+	if exp, _ = splitSelectCounts(exp); exp == nil {
+		return
+	}
+	ctx.WriteString(`,`)
+	d.renderMatchStage(ctx, exp)
+}
+
+// filterOutJoinConditions removes the conditions comparing columns with the
+// columns of the parent from a filter, the lookup joins on them instead
+func filterOutJoinConditions(exp *qcode.Exp) *qcode.Exp {
+	if exp == nil {
+		return nil
+	}
+	if exp.Left.ID != -1 || exp.Right.ID != -1 ||
+		(exp.Right.Col.Name != "" && exp.Right.Val == "") {
+		return nil
+	}
+	if exp.Op != qcode.OpAnd {
+		return exp
+	}
+
+	var children []*qcode.Exp
+	for _, c := range exp.Children {
+		if c1 := filterOutJoinConditions(c); c1 != nil {
+			children = append(children, c1)
+		}
+	}
+	switch len(children) {
+	case 0:
+		return nil
+	case 1:
+		return children[0]
+	}
+	return &qcode.Exp{Op: qcode.OpAnd, Children: children}
+}
+
+// renderLookupCondition renders a $match on the variable of an @include or
+// @skip directive as the first stage of a lookup pipeline. The condition is
+// a constant for the query so when it is false MongoDB does not read the
+// related collection at all.
+func (d *MongoDBDialect) renderLookupCondition(ctx Context, child *qcode.Select) {
+	if child.Field.FieldFilter.Exp == nil {
+		return
+	}
+	ctx.WriteString(`{"$match":{"$expr":`)
+	d.renderBoolExpression(ctx, child.Field.FieldFilter.Exp)
+	ctx.WriteString(`}},`)
+}
+
+// renderDefaultDoc renders the default document of a singular relationship
+// with the fields selected from it, or null if it has none. Selected fields
+// without a default are null and related lists are empty.
+func (d *MongoDBDialect) renderDefaultDoc(ctx Context, sel *qcode.Select, qc *qcode.QCode) {
+	if !sel.Singular || sel.Default == nil {
+		ctx.WriteString(`null`)
+		return
+	}
+
+	ctx.WriteString(`{"$literal":{`)
+	first := true
+	for _, f := range sel.Fields {
+		if f.Type == qcode.FieldTypeFunc || f.SkipRender == qcode.SkipTypeDrop {
+			continue
+		}
+		if !first {
+			ctx.WriteString(`,`)
+		}
+		outputName := f.FieldName
+		if outputName == "id" {
+			outputName = "_id"
+		}
+		ctx.WriteString(`"`)
+		ctx.WriteString(outputName)
+		ctx.WriteString(`":`)
+		if v, ok := sel.Default[f.Col.Name]; ok && f.SkipRender == qcode.SkipTypeNone {
+			ctx.WriteString(string(v))
 		} else {
+			ctx.WriteString(`null`)
+		}
+		first = false
+	}
+	if qc != nil {
+		for _, cid := range sel.Children {
+			child := &qc.Selects[cid]
+			if child.SkipRender != qcode.SkipTypeNone {
+				continue
+			}
+			if !first {
+				ctx.WriteString(`,`)
+			}
 			ctx.WriteString(`"`)
 			ctx.WriteString(child.FieldName)
-			ctx.WriteString(`":1`)
+			ctx.WriteString(`":`)
+			if child.Exists {
+				ctx.WriteString(`false`)
+			} else if child.Singular || child.Rel.Type == sdata.RelPolymorphic {
+				ctx.WriteString(`null`)
+			} else {
+				ctx.WriteString(`[]`)
+			}
+			first = false
 		}
-		first = false
 	}
-
 	ctx.WriteString(`}}`)
 }
 
@@ -3375,9 +4826,208 @@ func (d *MongoDBDialect) renderFieldWithCondition(ctx Context, f qcode.Field, co
 	// MongoDB $cond format: { $cond: { if: <expr>, then: "$field", else: null } }
 	ctx.WriteString(`{"$cond":{"if":`)
 	d.renderBoolExpression(ctx, f.FieldFilter.Exp)
-	ctx.WriteString(`,"then":"$`)
+	ctx.WriteString(`,"then":`)
+	d.renderFieldRef(ctx, f, colName)
+	ctx.WriteString(`,"else":null}}`)
+}
+
+// renderFieldRef renders a reference to the field's column, masking the
+// value when the role has a mask configured for the column
+func (d *MongoDBDialect) renderFieldRef(ctx Context, f qcode.Field, colName string) {
+	if f.Compare != nil {
+		d.renderCompare(ctx, f.Compare)
+		return
+	}
+	if f.ArrayProj.Type != qcode.ArrayProjNone {
+		d.renderArrayProj(ctx, f.ArrayProj, colName)
+		return
+	}
+	if len(f.JSONPaths) != 0 && f.Mask.Type == qcode.MaskTypeNone {
+		d.renderJSONPaths(ctx, f.JSONPaths, colName)
+		return
+	}
+	d.RenderMask(ctx, f.Mask, func() {
+		if f.Labels != nil {
+			d.renderLabels(ctx, f.Labels, colName)
+			return
+		}
+		if f.DateDiff != nil {
+			d.renderDateDiff(ctx, f.DateDiff, colName)
+			return
+		}
+		ctx.WriteString(`"$`)
+		ctx.WriteString(colName)
+		ctx.WriteString(`"`)
+	})
+}
+
+// renderDateDiff renders the time from the date of a column to the date of
+// the end column or to now, it is null when either date is null or missing
+func (d *MongoDBDialect) renderDateDiff(ctx Context, dd *qcode.DateDiff, colName string) {
+	ctx.WriteString(`{"$dateDiff":{"startDate":"$`)
 	ctx.WriteString(colName)
-	ctx.WriteString(`","else":null}}`)
+	ctx.WriteString(`","endDate":`)
+	if dd.EndCol != "" {
+		ctx.WriteString(`"$`)
+		ctx.WriteString(dd.EndCol)
+		ctx.WriteString(`"`)
+	} else {
+		ctx.WriteString(`"$$NOW"`)
+	}
+	ctx.WriteString(`,"unit":"`)
+	ctx.WriteString(dd.Unit)
+	ctx.WriteString(`"}}`)
+}
+
+// renderLabels renders the label of the value of a column with a $switch,
+// values without a label return the default label
+func (d *MongoDBDialect) renderLabels(ctx Context, l *qcode.Labels, colName string) {
+	ctx.WriteString(`{"$switch":{"branches":[`)
+	for i, c := range l.Cases {
+		if i != 0 {
+			ctx.WriteString(`,`)
+		}
+		ctx.WriteString(`{"case":{"$eq":["$`)
+		ctx.WriteString(colName)
+		ctx.WriteString(`",{"$literal":`)
+		ctx.WriteString(string(c.Val))
+		ctx.WriteString(`}]},"then":{"$literal":"`)
+		ctx.WriteString(escapeJSONString(c.Label))
+		ctx.WriteString(`"}}`)
+	}
+	ctx.WriteString(`],"default":{"$literal":`)
+	ctx.WriteString(string(l.Default))
+	ctx.WriteString(`}}}`)
+}
+
+// renderJSONPaths renders the keys selected from a json column as an
+// object of dot notation paths. A missing key is null and so is an object
+// whose value is not a document
+func (d *MongoDBDialect) renderJSONPaths(ctx Context, paths []qcode.JSONPath, colName string) {
+	d.renderJSONObject(ctx, paths, colName, "")
+}
+
+func (d *MongoDBDialect) renderJSONObject(ctx Context, paths []qcode.JSONPath, colName, path string) {
+	ref := colName
+	if path != "" {
+		ref += "." + path
+	}
+	ctx.WriteString(`{"$cond":[{"$eq":[{"$type":"$`)
+	ctx.WriteString(ref)
+	ctx.WriteString(`"},"object"]},{`)
+	for i, p := range paths {
+		if i != 0 {
+			ctx.WriteString(`,`)
+		}
+		ctx.WriteString(`"`)
+		ctx.WriteString(p.FieldName)
+		ctx.WriteString(`":`)
+		if len(p.Paths) != 0 {
+			d.renderJSONObject(ctx, p.Paths, colName, p.Path)
+			continue
+		}
+		ctx.WriteString(`{"$ifNull":["$`)
+		ctx.WriteString(colName)
+		ctx.WriteString(`.`)
+		ctx.WriteString(p.Path)
+		ctx.WriteString(`",null]}`)
+	}
+	ctx.WriteString(`},null]}`)
+}
+
+// compareOps maps the operators of computed boolean fields to MongoDB
+var compareOps = map[string]string{
+	"eq":  "$eq",
+	"neq": "$ne",
+	"gt":  "$gt",
+	"gte": "$gte",
+	"lt":  "$lt",
+	"lte": "$lte",
+}
+
+// renderCompare renders a boolean computed by comparing a column with a
+// value or another column. MongoDB orders null and missing values before
+// all others, so the result is null when either side is null instead of
+// the comparison being true or false.
+func (d *MongoDBDialect) renderCompare(ctx Context, cmp *qcode.Compare) {
+	col := func(c sdata.DBColumn) {
+		ctx.WriteString(`"$`)
+		if c.Name == "id" {
+			ctx.WriteString("_id")
+		} else {
+			ctx.WriteString(c.Name)
+		}
+		ctx.WriteString(`"`)
+	}
+	isNull := func(c sdata.DBColumn) {
+		ctx.WriteString(`{"$eq":[{"$ifNull":[`)
+		col(c)
+		ctx.WriteString(`,null]},null]}`)
+	}
+
+	ctx.WriteString(`{"$cond":[`)
+	if cmp.ValCol.Name != "" {
+		ctx.WriteString(`{"$or":[`)
+		isNull(cmp.Col)
+		ctx.WriteString(`,`)
+		isNull(cmp.ValCol)
+		ctx.WriteString(`]}`)
+	} else {
+		isNull(cmp.Col)
+	}
+	ctx.WriteString(`,null,{"`)
+	ctx.WriteString(compareOps[cmp.Op])
+	ctx.WriteString(`":[`)
+	col(cmp.Col)
+	ctx.WriteString(`,`)
+	if cmp.ValCol.Name != "" {
+		col(cmp.ValCol)
+	} else {
+		// $literal keeps a string value starting with $ from being
+		// read as a field path
+		ctx.WriteString(`{"$literal":`)
+		ctx.WriteString(string(cmp.Val))
+		ctx.WriteString(`}`)
+	}
+	ctx.WriteString(`]}]}`)
+}
+
+// renderArrayProj renders the size or a slice of an array column (@size and
+// @slice directives), a missing array is treated as an empty one. The sum and
+// average of the elements (@sum and @avg) are computed within the document,
+// the sum of an empty or missing array is 0 and the average is null
+func (d *MongoDBDialect) renderArrayProj(ctx Context, ap qcode.ArrayProj, colName string) {
+	arr := func() {
+		ctx.WriteString(`{"$ifNull":["$`)
+		ctx.WriteString(colName)
+		ctx.WriteString(`",[]]}`)
+	}
+
+	switch ap.Type {
+	case qcode.ArrayProjSize:
+		ctx.WriteString(`{"$size":`)
+		arr()
+		ctx.WriteString(`}`)
+
+	case qcode.ArrayProjSlice:
+		ctx.WriteString(`{"$slice":[`)
+		arr()
+		ctx.WriteString(`,`)
+		ctx.WriteString(strconv.Itoa(ap.Skip))
+		ctx.WriteString(`,`)
+		ctx.WriteString(strconv.Itoa(ap.Limit))
+		ctx.WriteString(`]}`)
+
+	case qcode.ArrayProjSum:
+		ctx.WriteString(`{"$sum":"$`)
+		ctx.WriteString(colName)
+		ctx.WriteString(`"}`)
+
+	case qcode.ArrayProjAvg:
+		ctx.WriteString(`{"$avg":"$`)
+		ctx.WriteString(colName)
+		ctx.WriteString(`"}`)
+	}
 }
 
 // renderBoolExpression renders a boolean expression for $cond evaluation.
@@ -3405,8 +5055,19 @@ func (d *MongoDBDialect) renderBoolExpression(ctx Context, exp *qcode.Exp) {
 	}
 }
 
+// conditionOps maps the comparisons of field-level conditions to MongoDB
+var conditionOps = map[qcode.ExpOp]string{
+	qcode.OpEquals:          "$eq",
+	qcode.OpNotEquals:       "$ne",
+	qcode.OpGreaterThan:     "$gt",
+	qcode.OpGreaterOrEquals: "$gte",
+	qcode.OpLesserThan:      "$lt",
+	qcode.OpLesserOrEquals:  "$lte",
+}
+
 // renderConditionExpression renders a condition expression for field-level filters.
-// Used for includeIf/skipIf with row data conditions.
+// Used for includeIf/skipIf with row data conditions, the columns of the
+// document are compared to literals or variables.
 func (d *MongoDBDialect) renderConditionExpression(ctx Context, exp *qcode.Exp) {
 	if exp == nil {
 		ctx.WriteString(`true`)
@@ -3414,25 +5075,19 @@ func (d *MongoDBDialect) renderConditionExpression(ctx Context, exp *qcode.Exp)
 	}
 
 	switch exp.Op {
-	case qcode.OpAnd:
+	case qcode.OpAnd, qcode.OpOr:
 		if len(exp.Children) > 0 {
-			ctx.WriteString(`{"$and":[`)
-			for i, child := range exp.Children {
-				if i > 0 {
-					ctx.WriteString(`,`)
-				}
-				d.renderConditionExpression(ctx, child)
+			if exp.Op == qcode.OpAnd {
+				ctx.WriteString(`{"$and":[`)
+			} else {
+				ctx.WriteString(`{"$or":[`)
 			}
-			ctx.WriteString(`]}`)
-		}
-	case qcode.OpOr:
-		if len(exp.Children) > 0 {
-			ctx.WriteString(`{"$or":[`)
 			for i, child := range exp.Children {
 				if i > 0 {
 					ctx.WriteString(`,`)
 				}
-				d.renderConditionExpression(ctx, child)
+				// children can be variable conditions of @include and @skip
+				d.renderBoolExpression(ctx, child)
 			}
 			ctx.WriteString(`]}`)
 		}
@@ -3440,54 +5095,73 @@ func (d *MongoDBDialect) renderConditionExpression(ctx Context, exp *qcode.Exp)
 		// NOT is used by skipIf to negate the condition
 		if len(exp.Children) > 0 {
 			ctx.WriteString(`{"$not":[`)
-			d.renderConditionExpression(ctx, exp.Children[0])
+			d.renderBoolExpression(ctx, exp.Children[0])
 			ctx.WriteString(`]}`)
 		}
-	case qcode.OpEquals:
-		ctx.WriteString(`{"$eq":["$`)
-		colName := exp.Left.Col.Name
-		if colName == "" {
-			colName = exp.Left.ColName
-		}
-		if colName == "id" {
-			colName = "_id"
-		}
-		ctx.WriteString(colName)
+	case qcode.OpEquals, qcode.OpNotEquals, qcode.OpGreaterThan,
+		qcode.OpGreaterOrEquals, qcode.OpLesserThan, qcode.OpLesserOrEquals:
+		ctx.WriteString(`{"`)
+		ctx.WriteString(conditionOps[exp.Op])
+		ctx.WriteString(`":["$`)
+		ctx.WriteString(conditionColName(exp))
 		ctx.WriteString(`",`)
 		d.renderConditionValue(ctx, exp)
 		ctx.WriteString(`]}`)
-	case qcode.OpNotEquals:
-		ctx.WriteString(`{"$ne":["$`)
-		colName := exp.Left.Col.Name
-		if colName == "" {
-			colName = exp.Left.ColName
-		}
-		if colName == "id" {
-			colName = "_id"
+	case qcode.OpIn, qcode.OpNotIn:
+		if exp.Op == qcode.OpNotIn {
+			ctx.WriteString(`{"$not":[`)
 		}
-		ctx.WriteString(colName)
+		ctx.WriteString(`{"$in":["$`)
+		ctx.WriteString(conditionColName(exp))
 		ctx.WriteString(`",`)
-		d.renderConditionValue(ctx, exp)
+		d.renderListValue(ctx, exp)
 		ctx.WriteString(`]}`)
+		if exp.Op == qcode.OpNotIn {
+			ctx.WriteString(`]}`)
+		}
+	case qcode.OpIsNull, qcode.OpIsNotNull:
+		// a missing field is null as well
+		isNull := (exp.Op == qcode.OpIsNull) == (exp.Right.Val != "false")
+		if isNull {
+			ctx.WriteString(`{"$eq":[{"$ifNull":["$`)
+		} else {
+			ctx.WriteString(`{"$ne":[{"$ifNull":["$`)
+		}
+		ctx.WriteString(conditionColName(exp))
+		ctx.WriteString(`",null]},null]}`)
 	default:
 		// Fallback: always true for unsupported operators
 		ctx.WriteString(`true`)
 	}
 }
 
+// conditionColName returns the field a field-level condition compares
+func conditionColName(exp *qcode.Exp) string {
+	colName := exp.Left.Col.Name
+	if colName == "" {
+		colName = exp.Left.ColName
+	}
+	if colName == "id" {
+		colName = "_id"
+	}
+	return colName
+}
+
 // renderConditionValue renders a value for condition expressions.
 func (d *MongoDBDialect) renderConditionValue(ctx Context, exp *qcode.Exp) {
+	if exp.Left.Col.ObjectID && len(exp.Left.Path) == 0 {
+		d.renderValue(ctx, exp)
+		return
+	}
 	switch exp.Right.ValType {
-	case qcode.ValVar:
-		ctx.WriteString(`"`)
-		ctx.AddParam(Param{Name: exp.Right.Val, Type: "any"})
-		ctx.WriteString(`"`)
+	case qcode.ValVar, qcode.ValStr:
+		// $literal keeps a string value starting with $ from being
+		// read as a field path
+		ctx.WriteString(`{"$literal":`)
+		d.renderExpValue(ctx, exp)
+		ctx.WriteString(`}`)
 	case qcode.ValNum:
 		ctx.WriteString(exp.Right.Val)
-	case qcode.ValStr:
-		ctx.WriteString(`"`)
-		ctx.WriteString(exp.Right.Val)
-		ctx.WriteString(`"`)
 	case qcode.ValBool:
 		ctx.WriteString(exp.Right.Val)
 	default:
@@ -3773,6 +5447,13 @@ func (d *MongoDBDialect) renderExpression(ctx Context, exp *qcode.Exp) {
 			// For $or/$and, these need to be at top level with FK column in each condition
 			d.renderSelectExistsWithFK(ctx, exp.Children[0], fkColName)
 		}
+	case qcode.OpSelectCount:
+		// Related document count: e.g., orders: { _count: { gt: 3 } }
+		// The related documents were looked up into a temporary field
+		d.renderSelectCount(ctx, exp)
+	case qcode.OpArraySize:
+		// Array length: e.g., items: { _size: { gte: 2 } }
+		d.renderArraySize(ctx, exp)
 	case qcode.OpTsQuery:
 		// MongoDB full-text search uses $text operator
 		// Note: MongoDB's $text returns all documents matching any token, sorted by relevance
@@ -3830,6 +5511,22 @@ func (d *MongoDBDialect) renderExpression(ctx Context, exp *qcode.Exp) {
 			colName = "_id"
 		}
 
+		// {"tags":null} also matches arrays with a null element
+		// so null checks on arrays are rendered explicitly
+		if exp.Left.Col.Array && len(exp.Left.Path) == 0 {
+			switch exp.Op {
+			case qcode.OpIsNull:
+				d.renderArrayIsNull(ctx, colName, exp.Right.Val != "false")
+				return
+			case qcode.OpIsNotNull:
+				d.renderArrayIsNull(ctx, colName, exp.Right.Val == "false")
+				return
+			case qcode.OpHasNull:
+				d.renderArrayHasNull(ctx, colName, exp.Right.Val != "false")
+				return
+			}
+		}
+
 		ctx.WriteString(`"`)
 		ctx.WriteString(colName)
 		// Add JSON path using dot notation if present
@@ -3845,8 +5542,47 @@ func (d *MongoDBDialect) renderExpression(ctx Context, exp *qcode.Exp) {
 	}
 }
 
+// renderArrayIsNull renders a null check on an array column, an array is
+// null when the field is missing, null or an empty array. Arrays that contain
+// null elements are not null, use has_null to match them
+func (d *MongoDBDialect) renderArrayIsNull(ctx Context, colName string, isNull bool) {
+	col := escapeJSONString(colName)
+	if !isNull {
+		ctx.WriteString(`"$nor":[{`)
+	}
+	ctx.WriteString(`"$or":[{"`)
+	ctx.WriteString(col)
+	ctx.WriteString(`":{"$size":0}},{"$and":[{"`)
+	ctx.WriteString(col)
+	ctx.WriteString(`":null},{"`)
+	ctx.WriteString(col)
+	ctx.WriteString(`":{"$not":{"$type":"array"}}}]}]`)
+	if !isNull {
+		ctx.WriteString(`}]`)
+	}
+}
+
+// renderArrayHasNull renders a check for a null element in an array column
+func (d *MongoDBDialect) renderArrayHasNull(ctx Context, colName string, hasNull bool) {
+	col := escapeJSONString(colName)
+	if hasNull {
+		ctx.WriteString(`"`)
+		ctx.WriteString(col)
+		ctx.WriteString(`":{"$elemMatch":{"$eq":null}}`)
+		return
+	}
+	ctx.WriteString(`"$nor":[{"`)
+	ctx.WriteString(col)
+	ctx.WriteString(`":{"$elemMatch":{"$eq":null}}}]`)
+}
+
 // renderComparisonValue renders the right side of a comparison
 func (d *MongoDBDialect) renderComparisonValue(ctx Context, exp *qcode.Exp) {
+	if exp.Left.Col.Array && len(exp.Left.Path) == 0 && isRegexOp(exp.Op) {
+		d.renderArrayRegex(ctx, exp)
+		return
+	}
+
 	switch exp.Op {
 	case qcode.OpEquals:
 		d.renderValue(ctx, exp)
@@ -3875,69 +5611,147 @@ func (d *MongoDBDialect) renderComparisonValue(ctx Context, exp *qcode.Exp) {
 		// OpHasInCommon: array field has any element matching values in list
 		// MongoDB's $in handles both cases with the same syntax
 		ctx.WriteString(`{"$in":`)
-		if exp.Right.ValType == qcode.ValList {
-			// Static list of values
-			ctx.WriteString(`[`)
-			for i, v := range exp.Right.ListVal {
-				if i > 0 {
-					ctx.WriteString(`,`)
-				}
-				d.renderLiteralValue(ctx, v, exp.Right.ListType)
-			}
-			ctx.WriteString(`]`)
-		} else if exp.Right.Val != "" {
-			// Variable reference for list operations
-			// Note: setListVal in qcode doesn't set ValType for variables,
-			// but sets Val to the variable name
-			ctx.WriteString(`"`)
-			ctx.AddParam(Param{Name: exp.Right.Val, Type: "json", IsArray: true})
-			ctx.WriteString(`"`)
-		} else {
-			// Fallback
-			d.renderValue(ctx, exp)
-		}
+		d.renderListValue(ctx, exp)
 		ctx.WriteString(`}`)
 	case qcode.OpNotIn:
-		ctx.WriteString(`{"$nin":[`)
+		ctx.WriteString(`{"$nin":`)
+		d.renderListValue(ctx, exp)
+		ctx.WriteString(`}`)
+	case qcode.OpLike:
+		d.renderLikeRegex(ctx, exp, false)
+	case qcode.OpILike:
+		d.renderLikeRegex(ctx, exp, true)
+	case qcode.OpNotLike:
+		ctx.WriteString(`{"$not":`)
+		d.renderLikeRegex(ctx, exp, false)
+		ctx.WriteString(`}`)
+	case qcode.OpNotILike:
+		ctx.WriteString(`{"$not":`)
+		d.renderLikeRegex(ctx, exp, true)
+		ctx.WriteString(`}`)
+	case qcode.OpRegex:
+		d.renderRegex(ctx, exp, false)
+	case qcode.OpIRegex:
+		d.renderRegex(ctx, exp, true)
+	case qcode.OpIsNull, qcode.OpIsNotNull:
+		// is_null: false matches the values that are not null
+		if (exp.Op == qcode.OpIsNull) == (exp.Right.Val != "false") {
+			ctx.WriteString(`null`)
+		} else {
+			ctx.WriteString(`{"$ne":null}`)
+		}
+	default:
+		d.renderValue(ctx, exp)
+	}
+}
+
+// renderListValue renders the list of values of an in or not in
+// expression, the values compared to ObjectId columns are wrapped in a
+// $oid marker
+func (d *MongoDBDialect) renderListValue(ctx Context, exp *qcode.Exp) {
+	oidOpen(ctx, exp.Left.Col)
+	if exp.Right.ValType == qcode.ValList {
+		// Static list of values
+		ctx.WriteString(`[`)
 		for i, v := range exp.Right.ListVal {
 			if i > 0 {
 				ctx.WriteString(`,`)
 			}
 			d.renderLiteralValue(ctx, v, exp.Right.ListType)
 		}
-		ctx.WriteString(`]}`)
-	case qcode.OpLike:
-		ctx.WriteString(`{"$regex":"`)
-		// Convert SQL LIKE pattern to regex
-		pattern := strings.ReplaceAll(exp.Right.Val, "%", ".*")
-		pattern = strings.ReplaceAll(pattern, "_", ".")
-		ctx.WriteString(escapeJSONString(pattern))
-		ctx.WriteString(`"}`)
-	case qcode.OpILike:
-		ctx.WriteString(`{"$regex":"`)
-		pattern := strings.ReplaceAll(exp.Right.Val, "%", ".*")
-		pattern = strings.ReplaceAll(pattern, "_", ".")
-		ctx.WriteString(escapeJSONString(pattern))
-		ctx.WriteString(`","$options":"i"}`)
+		ctx.WriteString(`]`)
+	} else if exp.Right.Val != "" {
+		// Variable reference for list operations
+		// Note: setListVal in qcode doesn't set ValType for variables,
+		// but sets Val to the variable name
+		ctx.WriteString(`"`)
+		ctx.AddParam(Param{Name: exp.Right.Val, Type: "json", IsArray: true})
+		ctx.WriteString(`"`)
+	} else {
+		// Fallback
+		d.renderExpValue(ctx, exp)
+	}
+	oidClose(ctx, exp.Left.Col)
+}
+
+// isRegexOp returns true if the operator is rendered as a $regex
+func isRegexOp(op qcode.ExpOp) bool {
+	switch op {
+	case qcode.OpLike, qcode.OpILike, qcode.OpNotLike, qcode.OpNotILike,
+		qcode.OpRegex, qcode.OpIRegex:
+		return true
+	}
+	return false
+}
+
+// renderArrayRegex matches the pattern against the elements of an array
+// with $elemMatch, a document matches when any of its elements does and
+// with the negated operators when none of them does
+func (d *MongoDBDialect) renderArrayRegex(ctx Context, exp *qcode.Exp) {
+	not := exp.Op == qcode.OpNotLike || exp.Op == qcode.OpNotILike
+	if not {
+		ctx.WriteString(`{"$not":`)
+	}
+	ctx.WriteString(`{"$elemMatch":`)
+	switch exp.Op {
+	case qcode.OpLike, qcode.OpNotLike:
+		d.renderLikeRegex(ctx, exp, false)
+	case qcode.OpILike, qcode.OpNotILike:
+		d.renderLikeRegex(ctx, exp, true)
 	case qcode.OpRegex:
-		ctx.WriteString(`{"$regex":`)
-		d.renderValue(ctx, exp)
-		ctx.WriteString(`}`)
+		d.renderRegex(ctx, exp, false)
 	case qcode.OpIRegex:
-		ctx.WriteString(`{"$regex":`)
-		d.renderValue(ctx, exp)
+		d.renderRegex(ctx, exp, true)
+	}
+	ctx.WriteString(`}`)
+	if not {
+		ctx.WriteString(`}`)
+	}
+}
+
+// renderRegex renders a regular expression as a $regex expression
+func (d *MongoDBDialect) renderRegex(ctx Context, exp *qcode.Exp, caseInsensitive bool) {
+	ctx.WriteString(`{"$regex":`)
+	d.renderValue(ctx, exp)
+	if caseInsensitive {
 		ctx.WriteString(`,"$options":"i"}`)
-	case qcode.OpIsNull:
-		ctx.WriteString(`null`)
-	case qcode.OpIsNotNull:
-		ctx.WriteString(`{"$ne":null}`)
-	default:
-		d.renderValue(ctx, exp)
+	} else {
+		ctx.WriteString(`}`)
 	}
 }
 
-// renderValue renders a value from an expression
+// renderLikeRegex renders a SQL LIKE pattern as a $regex expression
+func (d *MongoDBDialect) renderLikeRegex(ctx Context, exp *qcode.Exp, caseInsensitive bool) {
+	ctx.WriteString(`{"$regex":"`)
+	ctx.WriteString(escapeJSONString(likeToRegex(exp.Right.Val)))
+	if caseInsensitive {
+		ctx.WriteString(`","$options":"i"}`)
+	} else {
+		ctx.WriteString(`"}`)
+	}
+}
+
+// likeToRegex converts a SQL LIKE pattern to a regular expression, '%'
+// matches any sequence of characters and '_' a single character
+func likeToRegex(pattern string) string {
+	pattern = strings.ReplaceAll(pattern, "%", ".*")
+	return strings.ReplaceAll(pattern, "_", ".")
+}
+
+// renderValue renders a value from an expression, the values compared
+// to ObjectId columns are wrapped in a $oid marker
 func (d *MongoDBDialect) renderValue(ctx Context, exp *qcode.Exp) {
+	if len(exp.Left.Path) != 0 {
+		d.renderExpValue(ctx, exp)
+		return
+	}
+	oidOpen(ctx, exp.Left.Col)
+	d.renderExpValue(ctx, exp)
+	oidClose(ctx, exp.Left.Col)
+}
+
+// renderExpValue renders the value of an expression
+func (d *MongoDBDialect) renderExpValue(ctx Context, exp *qcode.Exp) {
 	switch exp.Right.ValType {
 	case qcode.ValVar:
 		// Check if this is a config-level static variable
@@ -3975,6 +5789,48 @@ func (d *MongoDBDialect) renderValue(ctx Context, exp *qcode.Exp) {
 	}
 }
 
+// oidOpen starts a $oid marker around the value of an ObjectId column, the
+// driver converts the hex strings in the marker to ObjectIds
+func oidOpen(ctx Context, col sdata.DBColumn) {
+	if col.ObjectID {
+		ctx.WriteString(`{"$oid":`)
+	}
+}
+
+// oidClose ends the $oid marker started by oidOpen
+func oidClose(ctx Context, col sdata.DBColumn) {
+	if col.ObjectID {
+		ctx.WriteString(`}`)
+	}
+}
+
+// renderObjectIDColumns lists the ObjectId columns of a table for the driver
+// to convert in documents it reads from a variable
+func (d *MongoDBDialect) renderObjectIDColumns(ctx Context, ti sdata.DBTable) {
+	first := true
+	for _, c := range ti.Columns {
+		if !c.ObjectID {
+			continue
+		}
+		if first {
+			ctx.WriteString(`,"object_ids":[`)
+		} else {
+			ctx.WriteString(`,`)
+		}
+		colName := c.Name
+		if colName == "id" {
+			colName = "_id"
+		}
+		ctx.WriteString(`"`)
+		ctx.WriteString(colName)
+		ctx.WriteString(`"`)
+		first = false
+	}
+	if !first {
+		ctx.WriteString(`]`)
+	}
+}
+
 // renderLiteralValue renders a literal value
 func (d *MongoDBDialect) renderLiteralValue(ctx Context, val string, valType qcode.ValType) {
 	switch valType {
@@ -4031,6 +5887,329 @@ func (d *MongoDBDialect) renderSelectExistsWithFK(ctx Context, exp *qcode.Exp, f
 	}
 }
 
+// hasSelectCount returns true if the filter compares the count of related documents
+func hasSelectCount(exp *qcode.Exp) bool {
+	if exp == nil {
+		return false
+	}
+	if exp.Op == qcode.OpSelectCount {
+		return true
+	}
+	for _, c := range exp.Children {
+		if hasSelectCount(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSelectCounts splits the filter into the conditions that can be matched
+// before the lookups and the conditions that compare the count of related
+// documents. Conditions combined with or / not are all matched after the lookups.
+func splitSelectCounts(exp *qcode.Exp) (pre, post *qcode.Exp) {
+	if !hasSelectCount(exp) {
+		return exp, nil
+	}
+	if exp.Op != qcode.OpAnd {
+		return nil, exp
+	}
+
+	var preList, postList []*qcode.Exp
+	for _, c := range exp.Children {
+		if hasSelectCount(c) {
+			postList = append(postList, c)
+		} else {
+			preList = append(preList, c)
+		}
+	}
+	return andExp(preList), andExp(postList)
+}
+
+// searchRankField is the temporary field the text score of a search is set
+// on, the search rank is projected and sorted on from it
+const searchRankField = "__search_rank"
+
+// splitTextSearch takes the $text search out of the filter so it can be
+// matched in the first stage. Only a search combined with the rest of the
+// filter by and can be taken out, MongoDB rejects it anywhere else.
+func splitTextSearch(exp *qcode.Exp) (text, rest *qcode.Exp) {
+	if exp == nil {
+		return nil, nil
+	}
+	switch exp.Op {
+	case qcode.OpTsQuery:
+		return exp, nil
+	case qcode.OpAnd:
+		var list []*qcode.Exp
+		for _, c := range exp.Children {
+			t, r := splitTextSearch(c)
+			if t != nil {
+				text = t
+			}
+			if r != nil {
+				list = append(list, r)
+			}
+		}
+		if text == nil {
+			return nil, exp
+		}
+		return text, andExp(list)
+	}
+	return nil, exp
+}
+
+// isSearchRank returns true if the field is the search_rank of a search
+func isSearchRank(f qcode.Field) bool {
+	return f.Type == qcode.FieldTypeFunc && f.Func.Name == "search_rank"
+}
+
+// isGeoDistance returns true if the field is the _distance of a geo filter
+func isGeoDistance(f qcode.Field) bool {
+	return f.Type == qcode.FieldTypeFunc && f.Func.Name == "_distance"
+}
+
+// renderGeoDistance renders the distance the $geoNear stage added to the
+// documents in meters converted back to the unit of the filter
+func (d *MongoDBDialect) renderGeoDistance(ctx Context, sel *qcode.Select) {
+	var geo *qcode.GeoExp
+	if exp := extractGeoExpression(sel.Where.Exp); exp != nil {
+		geo = exp.Geo
+	}
+	if geo == nil || geo.Unit.ToMeters(1) == 1 {
+		ctx.WriteString(`"$__geo_dist"`)
+		return
+	}
+	ctx.WriteString(`{"$divide":["$__geo_dist",`)
+	ctx.WriteString(strconv.FormatFloat(geo.Unit.ToMeters(1), 'f', -1, 64))
+	ctx.WriteString(`]}`)
+}
+
+// searchRanked returns true if the search rank is selected or ordered on,
+// the text score is then added to the documents
+func searchRanked(sel *qcode.Select) bool {
+	for _, f := range sel.Fields {
+		if isSearchRank(f) && f.SkipRender != qcode.SkipTypeDrop {
+			return true
+		}
+	}
+	for _, ob := range sel.OrderBy {
+		if ob.SearchRank {
+			return true
+		}
+	}
+	return false
+}
+
+// helperFields returns the internal fields the stages of the select add to
+// its documents. The $project of the select already leaves them out, the
+// cleanup stage makes sure they never reach the client whatever the path.
+// A requested field of the same name is kept. The __depth of recursive
+// lookups only exists on the elements the $map replaces and the __cursor_
+// fields are removed by the driver after it reads the cursor.
+func helperFields(sel *qcode.Select, qc *qcode.QCode) (fields []string) {
+	add := func(name string) {
+		for _, f := range sel.Fields {
+			if f.FieldName == name {
+				return
+			}
+		}
+		for _, f := range fields {
+			if f == name {
+				return
+			}
+		}
+		fields = append(fields, name)
+	}
+
+	if sel.Where.Exp != nil {
+		if extractGeoExpression(sel.Where.Exp) != nil {
+			add("__geo_dist")
+		}
+		addSelectCountFields(sel.Where.Exp, add)
+	}
+	if searchRanked(sel) {
+		add(searchRankField)
+	}
+	for _, ob := range sel.OrderBy {
+		switch {
+		case ob.Var != "":
+			add("__sort_pos_" + ob.Col.Name)
+		case orderByRelated(sel, ob):
+			add(orderByLookupField(ob.Col.Table))
+		}
+	}
+	if qc == nil {
+		return fields
+	}
+	for _, cid := range sel.Children {
+		child := &qc.Selects[cid]
+		if child.Rel.Type != sdata.RelPolymorphic || child.SkipRender != qcode.SkipTypeNone {
+			continue
+		}
+		for _, mid := range child.Children {
+			if m := &qc.Selects[mid]; m.SkipRender == qcode.SkipTypeNone {
+				add("__poly_" + m.Table)
+			}
+		}
+	}
+	return fields
+}
+
+// addSelectCountFields adds the fields the related documents of the counts
+// in the expression are looked up into
+func addSelectCountFields(exp *qcode.Exp, add func(string)) {
+	if exp.Op == qcode.OpSelectCount {
+		add(selectCountField(exp.Joins[0].Rel))
+		return
+	}
+	for _, c := range exp.Children {
+		addSelectCountFields(c, add)
+	}
+}
+
+// renderHelperCleanup renders the last stage of the pipeline of a select, it
+// excludes the internal helper fields added by the earlier stages
+func (d *MongoDBDialect) renderHelperCleanup(ctx Context, sel *qcode.Select, qc *qcode.QCode) {
+	fields := helperFields(sel, qc)
+	if len(fields) == 0 {
+		return
+	}
+	ctx.WriteString(`,{"$project":{`)
+	for i, f := range fields {
+		if i > 0 {
+			ctx.WriteString(`,`)
+		}
+		ctx.WriteString(`"`)
+		ctx.WriteString(f)
+		ctx.WriteString(`":0`)
+	}
+	ctx.WriteString(`}}`)
+}
+
+// andExp combines the expressions with an and
+func andExp(list []*qcode.Exp) *qcode.Exp {
+	switch len(list) {
+	case 0:
+		return nil
+	case 1:
+		return list[0]
+	}
+	return &qcode.Exp{Op: qcode.OpAnd, Children: list}
+}
+
+// selectCountField returns the temporary field the related documents of
+// a count are looked up into, they are never part of the projection
+func selectCountField(rel sdata.DBRel) string {
+	return "__count_" + rel.Left.Ti.Name + "_" + rel.Left.Col.Name + "_" + rel.Right.Col.Name
+}
+
+// renderCountLookups renders a $lookup stage for every relationship whose
+// documents are counted, only the _id of the related documents is kept
+func (d *MongoDBDialect) renderCountLookups(ctx Context, exp *qcode.Exp, done map[string]bool) {
+	if exp.Op != qcode.OpSelectCount {
+		for _, c := range exp.Children {
+			d.renderCountLookups(ctx, c, done)
+		}
+		return
+	}
+
+	// rel.Left is the related table and rel.Right the filtered table
+	rel := exp.Joins[0].Rel
+	field := selectCountField(rel)
+	if done[field] {
+		return
+	}
+	if len(done) != 0 {
+		ctx.WriteString(`,`)
+	}
+	done[field] = true
+
+	localField, foreignField, isLocalArray, isForeignArray := lookupJoin(rel.Right.Ti.Name, countLookupRel(rel))
+
+	ctx.WriteString(`{"$lookup":{"from":"`)
+	ctx.WriteString(rel.Left.Ti.Name)
+	ctx.WriteString(`","let":{"joinValue":"$`)
+	ctx.WriteString(localField)
+	ctx.WriteString(`"},"pipeline":[`)
+	d.renderLookupJoinMatch(ctx, foreignField, isLocalArray, isForeignArray)
+	ctx.WriteString(`,{"$project":{"_id":1}}],"as":"`)
+	ctx.WriteString(field)
+	ctx.WriteString(`"}}`)
+}
+
+// countLookupRel returns the relationship of a count in the orientation of
+// a child lookup where rel.Right is the table holding the foreign key
+func countLookupRel(rel sdata.DBRel) sdata.DBRel {
+	if rel.Left.Col.FKeyTable != "" && rel.Left.Col.FKeyTable == rel.Right.Ti.Name {
+		return sdata.DBRel{
+			Type:  rel.Type,
+			Left:  sdata.DBRelLeft{Ti: rel.Right.Ti, Col: rel.Right.Col},
+			Right: sdata.DBRelRight{Ti: rel.Left.Ti, Col: rel.Left.Col},
+		}
+	}
+	return rel
+}
+
+// renderSelectCount compares the number of related documents
+// e.g., "$expr":{"$gt":[{"$size":"$__count_orders_user_id_id"},3]}
+func (d *MongoDBDialect) renderSelectCount(ctx Context, exp *qcode.Exp) {
+	field := selectCountField(exp.Joins[0].Rel)
+	d.renderSizeCompare(ctx, exp, `{"$size":"$`+field+`"}`)
+}
+
+// renderArraySize compares the length of an array, a missing array has a length of 0
+// e.g., "$expr":{"$gte":[{"$size":{"$ifNull":["$items",[]]}},2]}
+func (d *MongoDBDialect) renderArraySize(ctx Context, exp *qcode.Exp) {
+	colName := exp.Left.Col.Name
+	if colName == "" {
+		colName = exp.Left.ColName
+	}
+	d.renderSizeCompare(ctx, exp, `{"$size":{"$ifNull":["$`+colName+`",[]]}}`)
+}
+
+// renderSizeCompare renders the comparisons of the children of the expression
+// with the size, they are all combined with $and
+func (d *MongoDBDialect) renderSizeCompare(ctx Context, exp *qcode.Exp, size string) {
+	ctx.WriteString(`"$expr":{`)
+	if len(exp.Children) > 1 {
+		ctx.WriteString(`"$and":[`)
+	}
+	for i, c := range exp.Children {
+		if i > 0 {
+			ctx.WriteString(`,`)
+		}
+		if len(exp.Children) > 1 {
+			ctx.WriteString(`{`)
+		}
+		switch c.Op {
+		case qcode.OpNotEquals:
+			ctx.WriteString(`"$ne"`)
+		case qcode.OpGreaterThan:
+			ctx.WriteString(`"$gt"`)
+		case qcode.OpGreaterOrEquals:
+			ctx.WriteString(`"$gte"`)
+		case qcode.OpLesserThan:
+			ctx.WriteString(`"$lt"`)
+		case qcode.OpLesserOrEquals:
+			ctx.WriteString(`"$lte"`)
+		default:
+			ctx.WriteString(`"$eq"`)
+		}
+		ctx.WriteString(`:[`)
+		ctx.WriteString(size)
+		ctx.WriteString(`,`)
+		d.renderValue(ctx, c)
+		ctx.WriteString(`]`)
+		if len(exp.Children) > 1 {
+			ctx.WriteString(`}`)
+		}
+	}
+	if len(exp.Children) > 1 {
+		ctx.WriteString(`]`)
+	}
+	ctx.WriteString(`}`)
+}
+
 // renderSortStage renders a $sort pipeline stage
 func (d *MongoDBDialect) renderSortStage(ctx Context, sel *qcode.Select) {
 	// Check if we need list-based ordering (order by position in array)
@@ -4042,6 +6221,9 @@ func (d *MongoDBDialect) renderSortStage(ctx Context, sel *qcode.Select) {
 		}
 	}
 
+	// Columns of related tables are looked up first
+	d.renderOrderByLookups(ctx, sel)
+
 	// If we have list-based ordering, first add $addFields stage to compute positions
 	if hasListOrder {
 		ctx.WriteString(`{"$addFields":{`)
@@ -4073,7 +6255,7 @@ func (d *MongoDBDialect) renderSortStage(ctx Context, sel *qcode.Select) {
 	// MongoDB sort order depends on key order, but Go maps don't preserve order
 	// So we use $sort_ordered: [[field, order], ...] format
 	ctx.WriteString(`{"$sort_ordered":[`)
-	for i, ob := range sel.OrderBy {
+	for i, ob := range d.sortOrder(sel) {
 		if i > 0 {
 			ctx.WriteString(`,`)
 		}
@@ -4082,6 +6264,12 @@ func (d *MongoDBDialect) renderSortStage(ctx Context, sel *qcode.Select) {
 			// Use computed position field for list-based ordering
 			ctx.WriteString(`__sort_pos_`)
 			ctx.WriteString(ob.Col.Name)
+		} else if ob.SearchRank {
+			ctx.WriteString(searchRankField)
+		} else if orderByRelated(sel, ob) {
+			ctx.WriteString(orderByLookupField(ob.Col.Table))
+			ctx.WriteString(`.`)
+			ctx.WriteString(ob.Col.Name)
 		} else {
 			colName := ob.Col.Name
 			// Translate "id" to "_id"
@@ -4102,6 +6290,111 @@ func (d *MongoDBDialect) renderSortStage(ctx Context, sel *qcode.Select) {
 	ctx.WriteString(`]}`)
 }
 
+// sortOrder returns the ordering of the select with _id added as the last
+// sort key when the ordering is not unique, without it documents with equal
+// sort keys come back in any order and pages skip or repeat them
+func (d *MongoDBDialect) sortOrder(sel *qcode.Select) []qcode.OrderBy {
+	if d.DisableSortTieBreak || len(sel.OrderBy) == 0 {
+		return sel.OrderBy
+	}
+	for _, ob := range sel.OrderBy {
+		if ob.Var != "" || ob.SearchRank || orderByRelated(sel, ob) {
+			continue
+		}
+		if ob.Col.Name == "id" || ob.Col.Name == "_id" ||
+			ob.Col.PrimaryKey || ob.Col.UniqueKey {
+			return sel.OrderBy
+		}
+	}
+	obs := make([]qcode.OrderBy, len(sel.OrderBy), len(sel.OrderBy)+1)
+	copy(obs, sel.OrderBy)
+	return append(obs, qcode.OrderBy{
+		Col:   sdata.DBColumn{Table: sel.Table, Name: "_id", Type: "objectId", PrimaryKey: true},
+		Order: qcode.OrderAsc,
+	})
+}
+
+// orderByRelated returns true if the ordering is on a column of a related table
+func orderByRelated(sel *qcode.Select, ob qcode.OrderBy) bool {
+	return ob.Col.Table != "" && ob.Col.Table != sel.Table && hasOrderByJoins(sel)
+}
+
+// hasOrderByJoins returns true if the select is ordered by columns of related tables
+func hasOrderByJoins(sel *qcode.Select) bool {
+	for _, j := range sel.Joins {
+		if j.Local {
+			return true
+		}
+	}
+	return false
+}
+
+// cursorColName returns the name the value of an order-by column is kept
+// under in the cursor, columns of related tables are prefixed with the
+// lookup field so they don't collide with the columns of the table
+func cursorColName(sel *qcode.Select, ob qcode.OrderBy) string {
+	if orderByRelated(sel, ob) {
+		return orderByLookupField(ob.Col.Table) + "_" + ob.Col.Name
+	}
+	return ob.Col.Name
+}
+
+// orderByLookupField returns the field a related document ordered on is looked up into
+func orderByLookupField(table string) string {
+	return "__ob_" + table
+}
+
+// renderOrderByLookups renders a $lookup for each related table the select is
+// ordered by followed by an $addFields that surfaces the related document so
+// the $sort can use its columns. The fields are not part of the $project that
+// follows so they are left out of the results.
+func (d *MongoDBDialect) renderOrderByLookups(ctx Context, sel *qcode.Select) {
+	if !hasOrderByJoins(sel) {
+		return
+	}
+
+	// the tables reached so far and the fields they are looked up into
+	reached := map[string]string{sel.Table: ""}
+
+	for _, j := range sel.Joins {
+		if !j.Local {
+			continue
+		}
+		table, next := j.Rel.Left.Ti.Name, j.Rel.Right.Ti.Name
+		if _, ok := reached[table]; !ok {
+			table, next = next, table
+		}
+		prefix, ok := reached[table]
+		if !ok {
+			continue
+		}
+		if _, ok := reached[next]; ok {
+			continue
+		}
+
+		localField, foreignField, _, _ := lookupJoin(table, j.Rel)
+		if prefix != "" {
+			localField = prefix + "." + localField
+		}
+		as := orderByLookupField(next)
+		reached[next] = as
+
+		ctx.WriteString(`{"$lookup":{"from":"`)
+		ctx.WriteString(next)
+		ctx.WriteString(`","localField":"`)
+		ctx.WriteString(localField)
+		ctx.WriteString(`","foreignField":"`)
+		ctx.WriteString(foreignField)
+		ctx.WriteString(`","as":"`)
+		ctx.WriteString(as)
+		ctx.WriteString(`"}},{"$addFields":{"`)
+		ctx.WriteString(as)
+		ctx.WriteString(`":{"$arrayElemAt":["$`)
+		ctx.WriteString(as)
+		ctx.WriteString(`",0]}}},`)
+	}
+}
+
 // renderProjectStage renders a $project pipeline stage
 func (d *MongoDBDialect) renderProjectStage(ctx Context, sel *qcode.Select) {
 	ctx.WriteString(`{"$project":{`)
@@ -4143,7 +6436,8 @@ func (d *MongoDBDialect) renderEmbeddedJSONStage(ctx Context, parent, child *qco
 	if qc != nil {
 		for _, grandchildID := range child.Children {
 			grandchild := &qc.Selects[grandchildID]
-			if grandchild.SkipRender != qcode.SkipTypeNone {
+			// nested embedded objects are projected from the element in step 5
+			if grandchild.SkipRender != qcode.SkipTypeNone || grandchild.Rel.Type == sdata.RelEmbedded {
 				continue
 			}
 			// Generate temp field name for lookup result
@@ -4214,43 +6508,144 @@ func (d *MongoDBDialect) renderEmbeddedJSONStage(ctx Context, parent, child *qco
 	if qc != nil && (len(child.Fields) > 0 || len(child.Children) > 0) {
 		ctx.WriteString(`,{"$addFields":{"`)
 		ctx.WriteString(embeddedField)
-		ctx.WriteString(`":{"$map":{"input":"$`)
-		ctx.WriteString(embeddedField)
-		ctx.WriteString(`","as":"elem","in":{`)
+		ctx.WriteString(`":{"$map":{"input":`)
+		d.renderEmbeddedSlice(ctx, embeddedField, child)
+		ctx.WriteString(`,"as":"elem","in":{`)
 
-		first := true
-		// Include requested child fields
-		for _, f := range child.Fields {
-			if !first {
-				ctx.WriteString(`,`)
-			}
-			ctx.WriteString(`"`)
-			ctx.WriteString(f.FieldName)
-			ctx.WriteString(`":"$$elem.`)
-			ctx.WriteString(f.Col.Name)
-			ctx.WriteString(`"`)
-			first = false
+		d.renderEmbeddedElemFields(ctx, "$$elem", child, qc)
+		ctx.WriteString(`}}}}}`)
+	}
+}
+
+// renderEmbeddedElemFields renders the requested fields of an embedded element
+// under their aliases, nested embedded objects are projected one level deep
+func (d *MongoDBDialect) renderEmbeddedElemFields(ctx Context, elem string, sel *qcode.Select, qc *qcode.QCode) {
+	first := true
+	for _, f := range sel.Fields {
+		if !first {
+			ctx.WriteString(`,`)
 		}
+		ctx.WriteString(`"`)
+		ctx.WriteString(f.FieldName)
+		ctx.WriteString(`":`)
+		d.renderEmbeddedElemValue(ctx, elem, f.Col.Name)
+		first = false
+	}
 
-		// Include grandchildren (looked up relationships)
-		for _, grandchildID := range child.Children {
-			grandchild := &qc.Selects[grandchildID]
-			if grandchild.SkipRender != qcode.SkipTypeNone {
-				continue
-			}
-			if !first {
-				ctx.WriteString(`,`)
-			}
+	for _, id := range sel.Children {
+		child := &qc.Selects[id]
+		if child.SkipRender != qcode.SkipTypeNone {
+			continue
+		}
+		if !first {
+			ctx.WriteString(`,`)
+		}
+		ctx.WriteString(`"`)
+		ctx.WriteString(child.FieldName)
+		ctx.WriteString(`":`)
+		first = false
+
+		// looked up relationships were merged into the element by name
+		if child.Rel.Type != sdata.RelEmbedded {
 			ctx.WriteString(`"`)
-			ctx.WriteString(grandchild.FieldName)
-			ctx.WriteString(`":"$$elem.`)
-			ctx.WriteString(grandchild.FieldName)
+			ctx.WriteString(elem)
+			ctx.WriteString(`.`)
+			ctx.WriteString(child.FieldName)
 			ctx.WriteString(`"`)
-			first = false
+			continue
 		}
 
-		ctx.WriteString(`}}}}}`)
+		// nested embedded object or array of objects, deeper levels
+		// are returned as stored
+		path := elem + "." + child.Rel.Left.Col.Name
+		if child.Singular {
+			ctx.WriteString(`{"$cond":[{"$eq":[{"$type":"`)
+			ctx.WriteString(path)
+			ctx.WriteString(`"},"object"]},{`)
+			d.renderEmbeddedNestedFields(ctx, path, child)
+			ctx.WriteString(`},null]}`)
+		} else {
+			// a single object is selected as a list with one element
+			ctx.WriteString(`{"$map":{"input":{"$switch":{"branches":[{"case":{"$isArray":"`)
+			ctx.WriteString(path)
+			ctx.WriteString(`"},"then":"`)
+			ctx.WriteString(path)
+			ctx.WriteString(`"},{"case":{"$eq":[{"$type":"`)
+			ctx.WriteString(path)
+			ctx.WriteString(`"},"object"]},"then":["`)
+			ctx.WriteString(path)
+			ctx.WriteString(`"]}],"default":[]}},"as":"sub","in":{`)
+			d.renderEmbeddedNestedFields(ctx, "$$sub", child)
+			ctx.WriteString(`}}}`)
+		}
+	}
+}
+
+// renderEmbeddedNestedFields renders the fields of a nested embedded object
+func (d *MongoDBDialect) renderEmbeddedNestedFields(ctx Context, elem string, sel *qcode.Select) {
+	for i, f := range sel.Fields {
+		if i != 0 {
+			ctx.WriteString(`,`)
+		}
+		ctx.WriteString(`"`)
+		ctx.WriteString(f.FieldName)
+		ctx.WriteString(`":`)
+		d.renderEmbeddedElemValue(ctx, elem, f.Col.Name)
+	}
+}
+
+// renderEmbeddedElemValue renders a field of an embedded element, the id of
+// an element written through the driver is stored as _id like a document's
+func (d *MongoDBDialect) renderEmbeddedElemValue(ctx Context, elem, col string) {
+	if col == "id" || col == "_id" {
+		ctx.WriteString(`{"$ifNull":["`)
+		ctx.WriteString(elem)
+		ctx.WriteString(`._id","`)
+		ctx.WriteString(elem)
+		ctx.WriteString(`.id"]}`)
+		return
+	}
+	ctx.WriteString(`"`)
+	ctx.WriteString(elem)
+	ctx.WriteString(`.`)
+	ctx.WriteString(col)
+	ctx.WriteString(`"`)
+}
+
+// renderNestedLimit renders a $limit stage with the limit of a nested selector
+func (d *MongoDBDialect) renderNestedLimit(ctx Context, child *qcode.Select) {
+	if child.Paging.NoLimit || (child.Paging.Limit <= 0 && child.Paging.LimitVar == "") {
+		return
+	}
+	ctx.WriteString(`,{"$limit":`)
+	d.renderLimitValue(ctx, child)
+	ctx.WriteString(`}`)
+}
+
+// renderEmbeddedSlice renders the embedded array bounded by the limit of the selector
+func (d *MongoDBDialect) renderEmbeddedSlice(ctx Context, embeddedField string, child *qcode.Select) {
+	if child.Paging.NoLimit || (child.Paging.Limit <= 0 && child.Paging.LimitVar == "") {
+		ctx.WriteString(`"$`)
+		ctx.WriteString(embeddedField)
+		ctx.WriteString(`"`)
+		return
+	}
+	ctx.WriteString(`{"$slice":["$`)
+	ctx.WriteString(embeddedField)
+	ctx.WriteString(`",`)
+	d.renderLimitValue(ctx, child)
+	ctx.WriteString(`]}`)
+}
+
+// renderLimitValue renders the limit of the selector or its variable
+func (d *MongoDBDialect) renderLimitValue(ctx Context, sel *qcode.Select) {
+	if sel.Paging.LimitVar != "" {
+		ctx.WriteString(`"`)
+		ctx.AddParam(Param{Name: sel.Paging.LimitVar, Type: "integer"})
+		ctx.WriteString(`"`)
+		return
 	}
+	ctx.WriteString(strconv.Itoa(int(sel.Paging.Limit)))
 }
 
 // renderNestedLookupForEmbedded generates a $lookup for FK relationships within embedded JSON elements
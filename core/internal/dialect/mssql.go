@@ -1,7 +1,9 @@
 package dialect
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/dosco/graphjin/core/v3/internal/graph"
@@ -72,9 +74,6 @@ import (
 // ## Variable LIMIT
 // Dynamic LIMIT from variables may not apply correctly.
 //
-// ## Skip/Include Directives
-// Some skip/include directive patterns fail.
-//
 // # MSSQL-Specific Implementation Notes
 //
 // - Uses [brackets] for identifier quoting instead of "double quotes"
@@ -175,6 +174,11 @@ func (d *MSSQLDialect) SplitQuery(query string) []string {
 // MSSQL requires ORDER BY when using OFFSET/FETCH.
 // If no ORDER BY is specified, we add a fallback ORDER BY (SELECT NULL).
 func (d *MSSQLDialect) RenderLimit(ctx Context, sel *qcode.Select) {
+	// TOP (n) WITH TIES / TOP (n) PERCENT is rendered after SELECT by renderTop
+	if sel.Paging.WithTies || sel.Paging.Percent {
+		return
+	}
+
 	// MSSQL uses OFFSET n ROWS FETCH NEXT m ROWS ONLY
 	// This requires ORDER BY clause to be present
 	// If no ORDER BY, add a fallback ORDER BY (SELECT NULL)
@@ -211,6 +215,37 @@ func (d *MSSQLDialect) RenderLimit(ctx Context, sel *qcode.Select) {
 	}
 }
 
+// renderTop renders TOP (n) WITH TIES or TOP (n) PERCENT in place of
+// OFFSET/FETCH. The compiler ensures an ORDER BY is present and no offset is set.
+func (d *MSSQLDialect) renderTop(ctx Context, sel *qcode.Select) {
+	if !sel.Paging.WithTies && !sel.Paging.Percent {
+		return
+	}
+
+	ctx.WriteString(`TOP (`)
+	if sel.Paging.LimitVar != "" {
+		ctx.WriteString(`CAST(`)
+		if sel.Paging.Percent {
+			ctx.AddParam(Param{Name: sel.Paging.LimitVar, Type: "float"})
+			ctx.WriteString(` AS FLOAT)`)
+		} else {
+			ctx.AddParam(Param{Name: sel.Paging.LimitVar, Type: "int"})
+			ctx.WriteString(` AS INT)`)
+		}
+	} else {
+		ctx.Write(fmt.Sprintf("%d", sel.Paging.Limit))
+	}
+	ctx.WriteString(`)`)
+
+	if sel.Paging.Percent {
+		ctx.WriteString(` PERCENT`)
+	}
+	if sel.Paging.WithTies {
+		ctx.WriteString(` WITH TIES`)
+	}
+	ctx.WriteString(` `)
+}
+
 func (d *MSSQLDialect) RenderJSONRoot(ctx Context, sel *qcode.Select) {
 	ctx.WriteString(`SELECT (SELECT `)
 }
@@ -1036,15 +1071,24 @@ func (d *MSSQLDialect) RenderInlineChild(ctx Context, r InlineChildRenderer, pse
 	} else {
 		// Root query - use FOR JSON PATH directly instead of STRING_AGG with subquery
 		// MSSQL doesn't allow subqueries inside STRING_AGG
-		skipVarName, isSkip, hasSkipVar := d.findSkipVarExp(sel.Where.Exp)
+		skipVars := d.findSkipVarExp(sel.Where.Exp)
+		hasSkipVar := len(skipVars) != 0
 		if hasSkipVar {
-			ctx.WriteString(`CASE WHEN (`)
-			ctx.AddParam(Param{Name: skipVarName, Type: "bit"})
-			if isSkip {
-				ctx.WriteString(` != 1) THEN `)
-			} else {
-				ctx.WriteString(` = 1) THEN `)
+			// Every @skip/@include variable on the selection must allow it
+			ctx.WriteString(`CASE WHEN `)
+			for i, ex := range skipVars {
+				if i != 0 {
+					ctx.WriteString(` AND `)
+				}
+				ctx.WriteString(`(`)
+				ctx.AddParam(Param{Name: ex.Right.Val, Type: "bit"})
+				if ex.Op == qcode.OpNotEqualsTrue {
+					ctx.WriteString(` != 1)`)
+				} else {
+					ctx.WriteString(` = 1)`)
+				}
 			}
+			ctx.WriteString(` THEN `)
 		}
 
 		if !d.hasRenderableFields(sel, r) {
@@ -1054,7 +1098,9 @@ func (d *MSSQLDialect) RenderInlineChild(ctx Context, r InlineChildRenderer, pse
 			} else {
 				// Use subquery to limit rows BEFORE STRING_AGG aggregation
 				ctx.WriteString(`(SELECT COALESCE('[' + STRING_AGG('{}', ',') + ']', '[]')`)
-				ctx.WriteString(` FROM (SELECT 1 AS __x FROM `)
+				ctx.WriteString(` FROM (SELECT `)
+				d.renderTop(ctx, sel)
+				ctx.WriteString(`1 AS __x FROM `)
 				d.renderFromTable(ctx, r, sel, psel)
 				if sel.Rel.Type != sdata.RelEmbedded {
 					t := sel.Ti.Name
@@ -1175,6 +1221,7 @@ func (d *MSSQLDialect) RenderInlineChild(ctx Context, r InlineChildRenderer, pse
 				ctx.WriteString(`) AS [cursor] FROM (SELECT 1 AS _x) AS _ FOR JSON PATH, WITHOUT_ARRAY_WRAPPER)`)
 			} else {
 				ctx.WriteString(`COALESCE((SELECT `)
+				d.renderTop(ctx, sel)
 				d.renderInlineJSONFields(ctx, r, sel)
 
 				ctx.WriteString(` FROM `)
@@ -1454,11 +1501,12 @@ func (d *MSSQLDialect) renderInlineJSONFields(ctx Context, r InlineChildRenderer
 			ctx.WriteString(`)`)
 		} else {
 			// Schema detection now returns "json" for NVARCHAR(MAX) columns with ISJSON constraints
-			isJSON := f.Col.Type == "json" || f.Col.Array
+			isJSON := (f.Col.Type == "json" || f.Col.Array) &&
+				f.Mask.Type == qcode.MaskTypeNone
 			if isJSON {
 				ctx.WriteString(`JSON_QUERY(`)
 			}
-			r.ColWithTable(t, f.Col.Name)
+			d.RenderMask(ctx, f.Mask, func() { r.ColWithTable(t, f.Col.Name) })
 			if isJSON {
 				ctx.WriteString(`, '$')`)
 			}
@@ -1525,7 +1573,8 @@ func (d *MSSQLDialect) renderSubqueryJSONFields(ctx Context, r InlineChildRender
 			continue
 		}
 
-		isJSON := f.Col.Type == "json" || f.Col.Type == "nvarchar(max)" || f.Col.Array
+		isJSON := (f.Col.Type == "json" || f.Col.Type == "nvarchar(max)" || f.Col.Array) &&
+			f.Mask.Type == qcode.MaskTypeNone
 		if isJSON {
 			ctx.WriteString(`JSON_QUERY(`)
 		}
@@ -1622,7 +1671,7 @@ func (d *MSSQLDialect) renderBaseColumns(ctx Context, r InlineChildRenderer, sel
 			}
 			ctx.WriteString(`)`)
 		} else {
-			r.ColWithTable(t, f.Col.Name)
+			d.RenderMask(ctx, f.Mask, func() { r.ColWithTable(t, f.Col.Name) })
 		}
 
 		if f.FieldFilter.Exp != nil {
@@ -2464,27 +2513,29 @@ func (d *MSSQLDialect) renderArrayColumnExists(ctx Context, r InlineChildRendere
 	ctx.WriteString(`)))`)
 }
 
-func (d *MSSQLDialect) findSkipVarExp(exp *qcode.Exp) (varName string, isSkip bool, found bool) {
+// findSkipVarExp collects every skip/include variable expression reachable
+// through the AND chain of a Where clause, regardless of its position.
+// For @skip(ifVar: $var) the Op will be OpNotEqualsTrue
+// For @include(ifVar: $var) the Op will be OpEqualsTrue
+func (d *MSSQLDialect) findSkipVarExp(exp *qcode.Exp) []*qcode.Exp {
 	if exp == nil {
-		return "", false, false
+		return nil
 	}
 
 	switch exp.Op {
-	case qcode.OpEqualsTrue:
-		if exp.Right.ValType == qcode.ValVar {
-			return exp.Right.Val, false, true
-		}
-	case qcode.OpNotEqualsTrue:
+	case qcode.OpEqualsTrue, qcode.OpNotEqualsTrue:
 		if exp.Right.ValType == qcode.ValVar {
-			return exp.Right.Val, true, true
+			return []*qcode.Exp{exp}
 		}
 	case qcode.OpAnd:
-		if len(exp.Children) > 0 {
-			return d.findSkipVarExp(exp.Children[0])
+		var exps []*qcode.Exp
+		for _, c := range exp.Children {
+			exps = append(exps, d.findSkipVarExp(c)...)
 		}
+		return exps
 	}
 
-	return "", false, false
+	return nil
 }
 
 // hasRenderableFields checks if a select has any fields that will be rendered
@@ -2522,6 +2573,9 @@ func (d *MSSQLDialect) RenderMutationPostamble(ctx Context, qc *qcode.QCode) {
 	GenericRenderMutationPostamble(ctx, qc)
 }
 
+// RenderInsert is not used for mutations since MSSQL supports linear execution,
+// the inserted row is re-selected by its captured key for the returning
+// selection so computed columns and values set by triggers are accurate.
 func (d *MSSQLDialect) RenderInsert(ctx Context, m *qcode.Mutate, values func()) {
 	ctx.WriteString(`INSERT INTO `)
 	if m.Ti.Schema != "" && m.Ti.Schema != "dbo" {
@@ -2629,6 +2683,34 @@ func (d *MSSQLDialect) RenderTryCast(ctx Context, val func(), typ string) {
 	ctx.WriteString(`)`)
 }
 
+func (d *MSSQLDialect) RenderMask(ctx Context, m qcode.Mask, col func()) {
+	switch m.Type {
+	case qcode.MaskTypeRedact:
+		renderMaskText(ctx, m.Text)
+
+	case qcode.MaskTypeFirst:
+		ctx.WriteString(`CONCAT(LEFT(CAST(`)
+		col()
+		ctx.WriteString(` AS NVARCHAR(MAX)), `)
+		ctx.WriteString(strconv.Itoa(m.Len))
+		ctx.WriteString(`), `)
+		renderMaskText(ctx, m.Text)
+		ctx.WriteString(`)`)
+
+	case qcode.MaskTypeLast:
+		ctx.WriteString(`CONCAT(`)
+		renderMaskText(ctx, m.Text)
+		ctx.WriteString(`, RIGHT(CAST(`)
+		col()
+		ctx.WriteString(` AS NVARCHAR(MAX)), `)
+		ctx.WriteString(strconv.Itoa(m.Len))
+		ctx.WriteString(`))`)
+
+	default:
+		col()
+	}
+}
+
 func (d *MSSQLDialect) RenderSubscriptionUnbox(ctx Context, params []Param, innerSQL string) {
 	// MSSQL subscription unboxing using OPENJSON
 	sql := strings.TrimSpace(innerSQL)
@@ -2835,6 +2917,11 @@ func (d *MSSQLDialect) RenderSetSessionVar(ctx Context, name, value string) bool
 }
 
 func (d *MSSQLDialect) RenderLinearInsert(ctx Context, m *qcode.Mutate, qc *qcode.QCode, varName string, renderColVal func(qcode.MColumn)) {
+	if m.OnConflict != nil {
+		d.renderLinearMerge(ctx, m, qc, varName, renderColVal)
+		return
+	}
+
 	// For linear execution, we don't use OUTPUT INSERTED.* because we need to capture
 	// the ID into a variable using SCOPE_IDENTITY()
 	ctx.WriteString(`INSERT INTO `)
@@ -2988,6 +3075,116 @@ func (d *MSSQLDialect) RenderLinearInsert(ctx Context, m *qcode.Mutate, qc *qcod
 	}
 }
 
+// renderLinearMerge renders a bulk upsert as a MERGE, the source rows matching
+// a row of the table on the conflict target update it when it matches the
+// update filter of the role and the others are inserted. A single row captures
+// the id of the inserted or updated row.
+func (d *MSSQLDialect) renderLinearMerge(ctx Context, m *qcode.Mutate, qc *qcode.QCode, varName string, renderColVal func(qcode.MColumn)) {
+	ctx.WriteString(`MERGE INTO `)
+	if m.Ti.Schema != "" && m.Ti.Schema != "dbo" {
+		ctx.Quote(m.Ti.Schema)
+		ctx.WriteString(`.`)
+	}
+	ctx.Quote(m.Ti.Name)
+	// the table is not aliased so that the update filter can refer to it
+	ctx.WriteString(` WITH (HOLDLOCK) USING (SELECT `)
+	for i, col := range m.Cols {
+		if i != 0 {
+			ctx.WriteString(`, `)
+		}
+		if m.IsJSON && !col.Set {
+			ctx.ColWithTable("t", col.FieldName)
+		} else {
+			renderColVal(col)
+		}
+		ctx.WriteString(` AS `)
+		ctx.Quote(col.Col.Name)
+	}
+	if m.IsJSON {
+		ctx.WriteString(` FROM `)
+		d.RenderMutateToRecordSet(ctx, m, 0, func() {
+			ctx.AddParam(Param{Name: qc.ActionVar, Type: "json"})
+		})
+	}
+	ctx.WriteString(`) AS [_gj_s] ON (`)
+	for i, col := range m.OnConflict.Target {
+		if i != 0 {
+			ctx.WriteString(` AND `)
+		}
+		ctx.ColWithTable(m.Ti.Name, col.Name)
+		ctx.WriteString(` = `)
+		ctx.ColWithTable("_gj_s", col.Name)
+	}
+	ctx.WriteString(`) WHEN MATCHED `)
+	if m.OnConflict.Where.Exp != nil {
+		ctx.WriteString(`AND (`)
+		ctx.RenderExp(m.Ti, m.OnConflict.Where.Exp)
+		ctx.WriteString(`) `)
+	}
+	ctx.WriteString(`THEN UPDATE SET `)
+	for i, col := range m.OnConflict.Update {
+		if i != 0 {
+			ctx.WriteString(`, `)
+		}
+		ctx.ColWithTable(m.Ti.Name, col.Name)
+		ctx.WriteString(` = `)
+		ctx.ColWithTable("_gj_s", col.Name)
+	}
+	for i, col := range m.OnConflict.Presets {
+		if i != 0 || len(m.OnConflict.Update) != 0 {
+			ctx.WriteString(`, `)
+		}
+		ctx.ColWithTable(m.Ti.Name, col.Col.Name)
+		ctx.WriteString(` = `)
+		renderColVal(col)
+	}
+	ctx.WriteString(` WHEN NOT MATCHED THEN INSERT (`)
+	for i, col := range m.Cols {
+		if i != 0 {
+			ctx.WriteString(`, `)
+		}
+		ctx.Quote(col.Col.Name)
+	}
+	ctx.WriteString(`) VALUES (`)
+	for i, col := range m.Cols {
+		if i != 0 {
+			ctx.WriteString(`, `)
+		}
+		ctx.ColWithTable("_gj_s", col.Col.Name)
+	}
+	ctx.WriteString(`); `)
+
+	// the rows of a JSON input are returned by their conflict target values
+	if m.IsJSON {
+		return
+	}
+	ctx.WriteString(`SELECT @`)
+	ctx.WriteString(varName)
+	ctx.WriteString(` = `)
+	ctx.Quote(m.Ti.PrimaryCol.Name)
+	ctx.WriteString(` FROM `)
+	if m.Ti.Schema != "" && m.Ti.Schema != "dbo" {
+		ctx.Quote(m.Ti.Schema)
+		ctx.WriteString(`.`)
+	}
+	ctx.Quote(m.Ti.Name)
+	ctx.WriteString(` WHERE `)
+	i := 0
+	for _, col := range m.Cols {
+		if !hasColumn(m.OnConflict.Target, col.Col.Name) {
+			continue
+		}
+		if i != 0 {
+			ctx.WriteString(` AND `)
+		}
+		ctx.Quote(col.Col.Name)
+		ctx.WriteString(` = `)
+		renderColVal(col)
+		i++
+	}
+	ctx.WriteString(`; `)
+}
+
 func (d *MSSQLDialect) getVarName(m qcode.Mutate) string {
 	return m.Ti.Name + "_" + fmt.Sprintf("%d", m.ID)
 }
@@ -3274,7 +3471,11 @@ func (d *MSSQLDialect) ModifySelectsForMutation(qc *qcode.QCode) {
 				}
 			}
 
-			if hasExplicitPK {
+			if m.OnConflict != nil {
+				// Bulk upserts: the inserted and updated rows have the
+				// conflict target values of the JSON
+				exp = conflictTargetFilter(qc, &m)
+			} else if hasExplicitPK {
 				// Filter by IDs from JSON: WHERE id IN (SELECT ... FROM OPENJSON(...))
 				exp = &qcode.Exp{Op: qcode.OpIn}
 				col := m.Ti.PrimaryCol
@@ -3333,6 +3534,10 @@ func (d *MSSQLDialect) RenderQueryPrefix(ctx Context, qc *qcode.QCode) {
 	// MSSQL query prefix
 }
 
+func (d *MSSQLDialect) RenderQueryComment(ctx Context, comment string) {
+	renderSQLComment(ctx, comment)
+}
+
 // Helper function to convert types to MSSQL equivalents
 func (d *MSSQLDialect) mssqlType(t string) string {
 	tLower := strings.ToLower(t)
@@ -3609,6 +3814,73 @@ func (d *MSSQLDialect) RequiresBooleanAsInt() bool {
 	return false // MSSQL handles boolean as BIT natively
 }
 
+// IsRetryableError returns true when the statement was chosen as a deadlock victim (1205)
+func (d *MSSQLDialect) IsRetryableError(err error) bool {
+	var e interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &e) {
+		return e.SQLErrorNumber() == 1205
+	}
+	return errorContains(err, "deadlock victim")
+}
+
+// IsConnectionError returns true for lost connections and the transient errors
+// of a database that is being moved or failed over (40613, 40197, 40501)
+func (d *MSSQLDialect) IsConnectionError(err error) bool {
+	if isNetworkError(err) {
+		return true
+	}
+	var e interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &e) {
+		switch e.SQLErrorNumber() {
+		case 40613, 40197, 40501:
+			return true
+		}
+	}
+	return errorContains(err, "connection reset", "broken pipe")
+}
+
+// ConstraintViolation returns the violated constraint of unique constraints
+// (2627) and indexes (2601), foreign key and check constraints (547) and null
+// columns (515)
+func (d *MSSQLDialect) ConstraintViolation(err error) (v ConstraintViolation, ok bool) {
+	var e interface{ SQLErrorNumber() int32 }
+	if !errors.As(err, &e) {
+		return v, false
+	}
+	msg := err.Error()
+
+	switch e.SQLErrorNumber() {
+	case 2627:
+		// Violation of UNIQUE KEY constraint 'uq_users_email'
+		v.Kind = "unique"
+		v.Constraint = between(msg, "constraint '", "'")
+
+	case 2601:
+		// Cannot insert duplicate key row in object 'dbo.users' with unique index 'ix_email'
+		v.Kind = "unique"
+		v.Constraint = between(msg, "unique index '", "'")
+
+	case 547:
+		// The INSERT statement conflicted with the CHECK constraint "ck_price".
+		// ... table "dbo.products", column 'price'
+		v.Kind = "foreign_key"
+		if strings.Contains(msg, "CHECK constraint") {
+			v.Kind = "check"
+			v.Columns = splitColumns(between(msg, "column '", "'"))
+		}
+		v.Constraint = between(msg, `constraint "`, `"`)
+
+	case 515:
+		// Cannot insert the value NULL into column 'email'
+		v.Kind = "not_null"
+		v.Columns = splitColumns(between(msg, "column '", "'"))
+
+	default:
+		return v, false
+	}
+	return v, true
+}
+
 // Recursive CTE Syntax
 func (d *MSSQLDialect) RequiresRecursiveKeyword() bool {
 	return true // MSSQL uses WITH RECURSIVE (actually just WITH, but keyword is used)
@@ -833,6 +833,34 @@ func (d *MySQLDialect) RenderTryCast(ctx Context, val func(), typ string) {
 	}
 }
 
+func (d *MySQLDialect) RenderMask(ctx Context, m qcode.Mask, col func()) {
+	switch m.Type {
+	case qcode.MaskTypeRedact:
+		renderMaskText(ctx, m.Text)
+
+	case qcode.MaskTypeFirst:
+		ctx.WriteString(`CONCAT(LEFT(CAST(`)
+		col()
+		ctx.WriteString(` AS CHAR), `)
+		ctx.WriteString(strconv.Itoa(m.Len))
+		ctx.WriteString(`), `)
+		renderMaskText(ctx, m.Text)
+		ctx.WriteString(`)`)
+
+	case qcode.MaskTypeLast:
+		ctx.WriteString(`CONCAT(`)
+		renderMaskText(ctx, m.Text)
+		ctx.WriteString(`, RIGHT(CAST(`)
+		col()
+		ctx.WriteString(` AS CHAR), `)
+		ctx.WriteString(strconv.Itoa(m.Len))
+		ctx.WriteString(`))`)
+
+	default:
+		col()
+	}
+}
+
 func (d *MySQLDialect) RenderSubscriptionUnbox(ctx Context, params []Param, innerSQL string) {
 	ctx.WriteString(`WITH _gj_sub AS (SELECT * FROM JSON_TABLE(?, '$[*]' COLUMNS(`)
 	for i, p := range params {
@@ -1122,6 +1150,7 @@ func (d *MySQLDialect) RenderLinearInsert(ctx Context, m *qcode.Mutate, qc *qcod
 			// (there's no _sg_input CTE in linear execution)
 			ctx.AddParam(Param{Name: qc.ActionVar, Type: "json"})
 		})
+		d.renderOnDuplicateKey(ctx, m, renderColVal)
 		ctx.WriteString("; ")
 		// For JSON inserts where PK wasn't captured inline, capture LAST_INSERT_ID
 		if !hasExplicitPK {
@@ -1129,6 +1158,7 @@ func (d *MySQLDialect) RenderLinearInsert(ctx Context, m *qcode.Mutate, qc *qcod
 		}
 	} else {
 		ctx.WriteString(")")
+		d.renderOnDuplicateKey(ctx, m, renderColVal)
 		ctx.WriteString("; ")
 		if !hasExplicitPK {
 			d.RenderIDCapture(ctx, varName)
@@ -1136,6 +1166,68 @@ func (d *MySQLDialect) RenderLinearInsert(ctx Context, m *qcode.Mutate, qc *qcod
 	}
 }
 
+// renderOnDuplicateKey renders the conflict handling of a bulk upsert, the
+// conflicting rows are updated with the values of the insert and the update
+// presets. A single row sets the id of the updated row as the LAST_INSERT_ID
+// so it's returned. There is no WHERE on the update, each column keeps its
+// value when the row doesn't match the update filter of the role. The filter
+// is evaluated once into a variable as the columns are set one after the other.
+func (d *MySQLDialect) renderOnDuplicateKey(ctx Context, m *qcode.Mutate, renderColVal func(qcode.MColumn)) {
+	if m.OnConflict == nil {
+		return
+	}
+	ctx.WriteString(" ON DUPLICATE KEY UPDATE ")
+	if !m.IsJSON && m.Ti.PrimaryCol.Name != "" {
+		ctx.Quote(m.Ti.PrimaryCol.Name)
+		ctx.WriteString(" = LAST_INSERT_ID(")
+		ctx.Quote(m.Ti.PrimaryCol.Name)
+		ctx.WriteString("), ")
+	}
+	oc := m.OnConflict
+	for i, col := range oc.Update {
+		if i != 0 {
+			ctx.WriteString(", ")
+		}
+		d.renderConflictAssign(ctx, m, col, i == 0, func() {
+			ctx.WriteString("VALUES(")
+			ctx.Quote(col.Name)
+			ctx.WriteString(")")
+		})
+	}
+	for i, col := range oc.Presets {
+		if i != 0 || len(oc.Update) != 0 {
+			ctx.WriteString(", ")
+		}
+		d.renderConflictAssign(ctx, m, col.Col, i == 0 && len(oc.Update) == 0, func() {
+			renderColVal(col)
+		})
+	}
+}
+
+// renderConflictAssign sets a column of a conflicting row, the column is
+// only changed when the row matches the update filter of the role
+func (d *MySQLDialect) renderConflictAssign(ctx Context, m *qcode.Mutate, col sdata.DBColumn, first bool, val func()) {
+	ctx.Quote(col.Name)
+	ctx.WriteString(" = ")
+	if m.OnConflict.Where.Exp == nil {
+		val()
+		return
+	}
+	ctx.WriteString("IF(")
+	if first {
+		ctx.WriteString("(@gj_conflict := (")
+		ctx.RenderExp(m.Ti, m.OnConflict.Where.Exp)
+		ctx.WriteString("))")
+	} else {
+		ctx.WriteString("@gj_conflict")
+	}
+	ctx.WriteString(", ")
+	val()
+	ctx.WriteString(", ")
+	ctx.Quote(col.Name)
+	ctx.WriteString(")")
+}
+
 func (d *MySQLDialect) RenderLinearUpdate(ctx Context, m *qcode.Mutate, qc *qcode.QCode, varName string, renderColVal func(qcode.MColumn), renderWhere func()) {
 
 	// Check if there are child mutations that depend on this parent
@@ -1542,7 +1634,12 @@ func (d *MySQLDialect) ModifySelectsForMutation(qc *qcode.QCode) {
 				}
 			}
 
-			if hasExplicitPK {
+			if m.OnConflict != nil {
+				// Bulk upserts update rows without changing LAST_INSERT_ID so
+				// the rows are found by the conflict target values in the JSON
+				exp = conflictTargetFilter(qc, &m)
+
+			} else if hasExplicitPK {
 				// If PK is provided in JSON, we filter by the IDs in the JSON input
 				// id IN (SELECT id FROM JSON_TABLE(..., '$[*]' COLUMNS (id TYPE PATH '$.id')))
 
@@ -1623,6 +1720,10 @@ func (d *MySQLDialect) ModifySelectsForMutation(qc *qcode.QCode) {
 
 func (d *MySQLDialect) RenderQueryPrefix(ctx Context, qc *qcode.QCode) {}
 
+func (d *MySQLDialect) RenderQueryComment(ctx Context, comment string) {
+	renderSQLComment(ctx, comment)
+}
+
 func (d *MySQLDialect) RenderChildCursor(ctx Context, renderChild func()) {
 	// MySQL cursor workaround handled in RenderInlineChild
 }
@@ -1661,6 +1762,57 @@ func (d *MySQLDialect) RequiresBooleanAsInt() bool {
 	return false // MySQL handles boolean as TINYINT(1) natively
 }
 
+// IsRetryableError returns true for deadlocks (1213) and lock wait timeouts (1205)
+func (d *MySQLDialect) IsRetryableError(err error) bool {
+	if errorSQLState(err) == "40001" {
+		return true
+	}
+	return errorContains(err, "Error 1213", "Error 1205")
+}
+
+// IsConnectionError returns true for lost connections, a server that has gone
+// away (2006), a connection lost during a query (2013) or a server shutdown (1053)
+func (d *MySQLDialect) IsConnectionError(err error) bool {
+	return isNetworkError(err) ||
+		errorContains(err, "invalid connection", "Error 2006", "Error 2013", "Error 1053")
+}
+
+// ConstraintViolation returns the violated constraint of duplicate keys (1062),
+// foreign keys (1451, 1452), check constraints (3819) and null columns (1048)
+func (d *MySQLDialect) ConstraintViolation(err error) (v ConstraintViolation, ok bool) {
+	msg := err.Error()
+	switch {
+	case errorContains(err, "Error 1062"):
+		// Duplicate entry 'a@b.com' for key 'users.email'
+		v.Kind = "unique"
+		v.Constraint = between(msg, "for key '", "'")
+		if i := strings.LastIndexByte(v.Constraint, '.'); i != -1 {
+			v.Constraint = v.Constraint[i+1:]
+		}
+
+	case errorContains(err, "Error 1451", "Error 1452"):
+		// a foreign key constraint fails (`db`.`products`, CONSTRAINT `fk_owner`
+		// FOREIGN KEY (`owner_id`) REFERENCES `users` (`id`))
+		v.Kind = "foreign_key"
+		v.Constraint = between(msg, "CONSTRAINT `", "`")
+		v.Columns = splitColumns(between(msg, "FOREIGN KEY (", ")"))
+
+	case errorContains(err, "Error 3819"):
+		// Check constraint 'price_positive' is violated
+		v.Kind = "check"
+		v.Constraint = between(msg, "Check constraint '", "'")
+
+	case errorContains(err, "Error 1048"):
+		// Column 'email' cannot be null
+		v.Kind = "not_null"
+		v.Columns = splitColumns(between(msg, "Column '", "'"))
+
+	default:
+		return v, false
+	}
+	return v, true
+}
+
 // Recursive CTE Syntax
 func (d *MySQLDialect) RequiresRecursiveKeyword() bool {
 	return true // MySQL uses WITH RECURSIVE
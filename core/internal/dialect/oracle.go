@@ -830,6 +830,38 @@ func (d *OracleDialect) RenderTryCast(ctx Context, val func(), typ string) {
 	}
 }
 
+func (d *OracleDialect) RenderMask(ctx Context, m qcode.Mask, col func()) {
+	switch m.Type {
+	case qcode.MaskTypeRedact:
+		renderMaskText(ctx, m.Text)
+
+	case qcode.MaskTypeFirst:
+		ctx.WriteString(`(SUBSTR(TO_CHAR(`)
+		col()
+		ctx.WriteString(`), 1, `)
+		ctx.WriteString(strconv.Itoa(m.Len))
+		ctx.WriteString(`) || `)
+		renderMaskText(ctx, m.Text)
+		ctx.WriteString(`)`)
+
+	case qcode.MaskTypeLast:
+		// SUBSTR returns NULL when the negative position is before the
+		// start of the string so it's capped at the string length
+		ctx.WriteString(`(`)
+		renderMaskText(ctx, m.Text)
+		ctx.WriteString(` || SUBSTR(TO_CHAR(`)
+		col()
+		ctx.WriteString(`), -LEAST(`)
+		ctx.WriteString(strconv.Itoa(m.Len))
+		ctx.WriteString(`, LENGTH(TO_CHAR(`)
+		col()
+		ctx.WriteString(`)))))`)
+
+	default:
+		col()
+	}
+}
+
 func (d *OracleDialect) RenderSubscriptionUnbox(ctx Context, params []Param, innerSQL string) {
 	// Oracle subscription batching with cursor CTE extraction
 	// CTEs cannot be inside CROSS APPLY, so we extract cursor CTE and merge with subscription CTE.
@@ -1540,6 +1572,10 @@ func (d *OracleDialect) RenderQueryPrefix(ctx Context, qc *qcode.QCode) {
 	ctx.WriteString("OPEN c FOR ")
 }
 
+func (d *OracleDialect) RenderQueryComment(ctx Context, comment string) {
+	renderSQLComment(ctx, comment)
+}
+
 func (d *OracleDialect) SplitQuery(query string) (parts []string) { return []string{query} }
 
 func (d *OracleDialect) RenderChildCursor(ctx Context, renderChild func()) {
@@ -1581,6 +1617,47 @@ func (d *OracleDialect) RequiresBooleanAsInt() bool {
 	return true // Oracle's PL/SQL BOOLEAN can't be used in SQL WHERE clauses
 }
 
+// IsRetryableError returns true for deadlocks (ORA-00060) and serialization failures (ORA-08177)
+func (d *OracleDialect) IsRetryableError(err error) bool {
+	return errorContains(err, "ORA-00060", "ORA-08177")
+}
+
+// IsConnectionError returns true for lost connections (ORA-03113, ORA-03114,
+// ORA-03135) and a database that is shutting down (ORA-01089)
+func (d *OracleDialect) IsConnectionError(err error) bool {
+	return isNetworkError(err) ||
+		errorContains(err, "ORA-03113", "ORA-03114", "ORA-03135", "ORA-01089")
+}
+
+// ConstraintViolation returns the violated constraint of unique (ORA-00001),
+// foreign key (ORA-02291, ORA-02292), check (ORA-02290) and not null
+// (ORA-01400) violations
+func (d *OracleDialect) ConstraintViolation(err error) (v ConstraintViolation, ok bool) {
+	msg := err.Error()
+	switch {
+	case errorContains(err, "ORA-00001"):
+		v.Kind = "unique"
+	case errorContains(err, "ORA-02291", "ORA-02292"):
+		v.Kind = "foreign_key"
+	case errorContains(err, "ORA-02290"):
+		v.Kind = "check"
+	case errorContains(err, "ORA-01400"):
+		// cannot insert NULL into ("APP"."USERS"."EMAIL")
+		v.Kind = "not_null"
+		v.Columns = splitColumns(strings.ToLower(between(msg, "into (", ")")))
+		return v, true
+	default:
+		return v, false
+	}
+
+	// unique constraint (APP.USERS_EMAIL_UK) violated
+	v.Constraint = between(msg, "constraint (", ")")
+	if i := strings.LastIndexByte(v.Constraint, '.'); i != -1 {
+		v.Constraint = v.Constraint[i+1:]
+	}
+	return v, true
+}
+
 // Recursive CTE Syntax
 func (d *OracleDialect) RequiresRecursiveKeyword() bool {
 	return false // Oracle doesn't use RECURSIVE keyword
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/dosco/graphjin/core/v3/internal/qcode"
@@ -738,6 +739,34 @@ func (d *PostgresDialect) RenderTryCast(ctx Context, val func(), typ string) {
 	}
 }
 
+func (d *PostgresDialect) RenderMask(ctx Context, m qcode.Mask, col func()) {
+	switch m.Type {
+	case qcode.MaskTypeRedact:
+		renderMaskText(ctx, m.Text)
+
+	case qcode.MaskTypeFirst:
+		ctx.WriteString(`(LEFT(CAST(`)
+		col()
+		ctx.WriteString(` AS text), `)
+		ctx.WriteString(strconv.Itoa(m.Len))
+		ctx.WriteString(`) || `)
+		renderMaskText(ctx, m.Text)
+		ctx.WriteString(`)`)
+
+	case qcode.MaskTypeLast:
+		ctx.WriteString(`(`)
+		renderMaskText(ctx, m.Text)
+		ctx.WriteString(` || RIGHT(CAST(`)
+		col()
+		ctx.WriteString(` AS text), `)
+		ctx.WriteString(strconv.Itoa(m.Len))
+		ctx.WriteString(`))`)
+
+	default:
+		col()
+	}
+}
+
 func (d *PostgresDialect) RenderSubscriptionUnbox(ctx Context, params []Param, innerSQL string) {
 	ctx.WriteString(`WITH _gj_sub AS (SELECT `)
 	for i, p := range params {
@@ -880,6 +909,10 @@ func (d *PostgresDialect) ModifySelectsForMutation(qc *qcode.QCode) {}
 
 func (d *PostgresDialect) RenderQueryPrefix(ctx Context, qc *qcode.QCode) {}
 
+func (d *PostgresDialect) RenderQueryComment(ctx Context, comment string) {
+	renderSQLComment(ctx, comment)
+}
+
 func (d *PostgresDialect) SplitQuery(query string) (parts []string) { return []string{query} }
 
 func (d *PostgresDialect) RenderChildCursor(ctx Context, renderChild func()) {}
@@ -918,6 +951,70 @@ func (d *PostgresDialect) RequiresBooleanAsInt() bool {
 	return false // PostgreSQL has native boolean support
 }
 
+// IsRetryableError returns true for serialization failures (40001) and deadlocks (40P01)
+func (d *PostgresDialect) IsRetryableError(err error) bool {
+	switch errorSQLState(err) {
+	case "40001", "40P01":
+		return true
+	}
+	return errorContains(err, "SQLSTATE 40001", "SQLSTATE 40P01",
+		"could not serialize access", "deadlock detected")
+}
+
+// IsConnectionError returns true for lost connections, connection exceptions (08)
+// and a server that is shutting down or starting up (57P01, 57P02, 57P03)
+func (d *PostgresDialect) IsConnectionError(err error) bool {
+	if isNetworkError(err) {
+		return true
+	}
+	switch code := errorSQLState(err); {
+	case strings.HasPrefix(code, "08"):
+		return true
+	case code == "57P01", code == "57P02", code == "57P03":
+		return true
+	}
+	return errorContains(err, "SQLSTATE 08", "SQLSTATE 57P01", "SQLSTATE 57P02",
+		"SQLSTATE 57P03", "conn closed", "unexpected EOF")
+}
+
+// ConstraintViolation returns the violated constraint of unique (23505), foreign
+// key (23503), check (23514) and not null (23502) violations
+func (d *PostgresDialect) ConstraintViolation(err error) (v ConstraintViolation, ok bool) {
+	kinds := map[string]string{
+		"23505": "unique",
+		"23503": "foreign_key",
+		"23514": "check",
+		"23502": "not_null",
+	}
+	msg := err.Error()
+	if v.Kind, ok = kinds[errorSQLState(err)]; !ok {
+		for code, kind := range kinds {
+			if strings.Contains(msg, "SQLSTATE "+code) {
+				v.Kind, ok = kind, true
+			}
+		}
+	}
+	if !ok {
+		return
+	}
+
+	if v.Constraint = errorField(err, "ConstraintName", "Constraint"); v.Constraint == "" {
+		v.Constraint = between(msg, `constraint "`, `"`)
+	}
+
+	// the detail names the columns of the key: Key (email)=(a@b.com) already exists
+	detail := errorField(err, "Detail") + " " + msg
+	switch {
+	case errorField(err, "ColumnName", "Column") != "":
+		v.Columns = []string{errorField(err, "ColumnName", "Column")}
+	case strings.Contains(detail, "Key ("):
+		v.Columns = splitColumns(between(detail, "Key (", ")="))
+	case v.Kind == "not_null":
+		v.Columns = splitColumns(between(msg, `column "`, `"`))
+	}
+	return v, true
+}
+
 // Recursive CTE Syntax
 func (d *PostgresDialect) RequiresRecursiveKeyword() bool {
 	return true // PostgreSQL uses WITH RECURSIVE
@@ -44,6 +44,10 @@ func (d *SnowflakeDialect) UseNamedParams() bool {
 	return false
 }
 
+// RenderQueryComment renders no comment, the Snowflake emulator drops the
+// result rows of a statement that starts with a block comment
+func (d *SnowflakeDialect) RenderQueryComment(ctx Context, comment string) {}
+
 func (d *SnowflakeDialect) RenderJSONRoot(ctx Context, sel *qcode.Select) {
 	ctx.WriteString(`SELECT CAST(json_object(`)
 }
@@ -2,6 +2,7 @@ package dialect
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/dosco/graphjin/core/v3/internal/qcode"
@@ -748,6 +749,34 @@ func (d *SQLiteDialect) RenderTryCast(ctx Context, val func(), typ string) {
 	val()
 }
 
+func (d *SQLiteDialect) RenderMask(ctx Context, m qcode.Mask, col func()) {
+	switch m.Type {
+	case qcode.MaskTypeRedact:
+		renderMaskText(ctx, m.Text)
+
+	case qcode.MaskTypeFirst:
+		ctx.WriteString(`(substr(CAST(`)
+		col()
+		ctx.WriteString(` AS TEXT), 1, `)
+		ctx.WriteString(strconv.Itoa(m.Len))
+		ctx.WriteString(`) || `)
+		renderMaskText(ctx, m.Text)
+		ctx.WriteString(`)`)
+
+	case qcode.MaskTypeLast:
+		ctx.WriteString(`(`)
+		renderMaskText(ctx, m.Text)
+		ctx.WriteString(` || substr(CAST(`)
+		col()
+		ctx.WriteString(` AS TEXT), -`)
+		ctx.WriteString(strconv.Itoa(m.Len))
+		ctx.WriteString(`))`)
+
+	default:
+		col()
+	}
+}
+
 func (d *SQLiteDialect) RenderSubscriptionUnbox(ctx Context, params []Param, innerSQL string) {
 	// SQLite doesn't support LATERAL joins, use subquery approach
 	ctx.WriteString(`WITH _gj_sub AS (SELECT `)
@@ -856,6 +885,14 @@ func (d *SQLiteDialect) RenderLinearInsert(ctx Context, m *qcode.Mutate, qc *qco
 		ctx.WriteString(")")
 	}
 
+	if m.OnConflict != nil {
+		if m.IsJSON {
+			// the WHERE keeps the ON CONFLICT from being parsed as a join constraint
+			ctx.WriteString(" WHERE true")
+		}
+		d.renderOnConflict(ctx, m, renderColVal)
+	}
+
     // Render RETURNING clause - execution layer (gstate.go) captures IDs via @gj_ids hint
     d.RenderReturning(ctx, m)
 
@@ -864,6 +901,40 @@ func (d *SQLiteDialect) RenderLinearInsert(ctx Context, m *qcode.Mutate, qc *qco
 	ctx.WriteString("\n; ")
 }
 
+// renderOnConflict renders the conflict handling of a bulk upsert, the
+// conflicting rows matching the update filter of the role are updated with
+// the values of the insert and the update presets
+func (d *SQLiteDialect) renderOnConflict(ctx Context, m *qcode.Mutate, renderColVal func(qcode.MColumn)) {
+	ctx.WriteString(" ON CONFLICT (")
+	for i, col := range m.OnConflict.Target {
+		if i != 0 {
+			ctx.WriteString(", ")
+		}
+		ctx.Quote(col.Name)
+	}
+	ctx.WriteString(") DO UPDATE SET ")
+	for i, col := range m.OnConflict.Update {
+		if i != 0 {
+			ctx.WriteString(", ")
+		}
+		ctx.Quote(col.Name)
+		ctx.WriteString(" = excluded.")
+		ctx.Quote(col.Name)
+	}
+	for i, col := range m.OnConflict.Presets {
+		if i != 0 || len(m.OnConflict.Update) != 0 {
+			ctx.WriteString(", ")
+		}
+		ctx.Quote(col.Col.Name)
+		ctx.WriteString(" = ")
+		renderColVal(col)
+	}
+	if m.OnConflict.Where.Exp != nil {
+		ctx.WriteString(" WHERE ")
+		ctx.RenderExp(m.Ti, m.OnConflict.Where.Exp)
+	}
+}
+
 func (d *SQLiteDialect) RenderLinearUpdate(ctx Context, m *qcode.Mutate, qc *qcode.QCode, varName string, renderColVal func(qcode.MColumn), renderWhere func()) {
 	var fromFunc func()
 	if m.IsJSON {
@@ -1348,6 +1419,10 @@ func (d *SQLiteDialect) RenderQueryPrefix(ctx Context, qc *qcode.QCode) {
 	}
 }
 
+func (d *SQLiteDialect) RenderQueryComment(ctx Context, comment string) {
+	renderSQLComment(ctx, comment)
+}
+
 func (d *SQLiteDialect) SplitQuery(query string) (parts []string) {
 	var buf strings.Builder
 	var inStr, inQuote, inComment bool
@@ -1590,6 +1665,43 @@ func (d *SQLiteDialect) RequiresBooleanAsInt() bool {
 	return false // SQLite handles boolean as INTEGER natively
 }
 
+// IsRetryableError returns true when the database or a table is locked by another writer
+func (d *SQLiteDialect) IsRetryableError(err error) bool {
+	return errorContains(err, "database is locked", "database table is locked")
+}
+
+// IsConnectionError returns true only when the driver reports a lost
+// connection since SQLite is an embedded database
+func (d *SQLiteDialect) IsConnectionError(err error) bool {
+	return isNetworkError(err)
+}
+
+// ConstraintViolation returns the violated constraint of unique, foreign key,
+// check and not null violations. SQLite names the columns of unique and
+// not null violations but only the constraint of check violations
+func (d *SQLiteDialect) ConstraintViolation(err error) (v ConstraintViolation, ok bool) {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "UNIQUE constraint failed: "):
+		// UNIQUE constraint failed: users.email
+		v.Kind = "unique"
+		v.Columns = splitColumns(msg[strings.Index(msg, "failed: ")+8:])
+	case strings.Contains(msg, "NOT NULL constraint failed: "):
+		v.Kind = "not_null"
+		v.Columns = splitColumns(msg[strings.Index(msg, "failed: ")+8:])
+	case strings.Contains(msg, "CHECK constraint failed"):
+		v.Kind = "check"
+		if i := strings.Index(msg, "failed: "); i != -1 {
+			v.Constraint = strings.TrimSpace(msg[i+8:])
+		}
+	case strings.Contains(msg, "FOREIGN KEY constraint failed"):
+		v.Kind = "foreign_key"
+	default:
+		return v, false
+	}
+	return v, true
+}
+
 // Recursive CTE Syntax
 func (d *SQLiteDialect) RequiresRecursiveKeyword() bool {
 	return true // SQLite uses WITH RECURSIVE
@@ -366,6 +366,24 @@ func TestStrip(t *testing.T) {
 	}
 }
 
+func TestStripNulls(t *testing.T) {
+	in := `{"id":null,"data":{"a":null,"b":"null","c":[null,{"d":null}],"e":{"f": null, "g":1},"h":null},"i":null}`
+	expected := `{"data":{"b":"null","c":[null,{}],"e":{ "g":1}}}`
+
+	var b bytes.Buffer
+	if err := jsn.StripNulls(&b, []byte(in)); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != expected {
+		t.Errorf("expected %s, got %s", expected, b.String())
+	}
+
+	b.Reset()
+	if err := jsn.StripNulls(&b, []byte(`{"a":null`)); err == nil {
+		t.Error("expected an error for unbalanced json")
+	}
+}
+
 func TestValidateTrue(t *testing.T) {
 	json := []byte(`  [{"id":1,"embed":{"id":8}},{"id":2},{"id":3},{"id":4},{"id":5},{"id":6},{"id":7},{"id":8},{"id":9},{"id":10},{"id":11},{"id":12},{"id":13}]`)
 
@@ -0,0 +1,96 @@
+package jsn
+
+import (
+	"bytes"
+	"errors"
+)
+
+// StripNulls copies the JSON data to the buffer leaving out the keys whose
+// value is null from every object, including the objects nested in objects
+// and lists. Null elements of lists are kept.
+func StripNulls(w *bytes.Buffer, b []byte) error {
+	type frame struct {
+		obj     bool // an object and not a list
+		written bool // a key of the object was written
+	}
+
+	// the open objects and lists
+	st := make([]frame, 0, 8)
+	key := false
+
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+
+		switch c {
+		case '{', '[':
+			st = append(st, frame{obj: c == '{'})
+			key = c == '{'
+
+		case '}', ']':
+			if len(st) == 0 {
+				return errors.New("jsn: unbalanced json")
+			}
+			st = st[:len(st)-1]
+
+		case ',':
+			if len(st) != 0 && st[len(st)-1].obj {
+				// the comma is written before the next key that is kept
+				key = true
+				continue
+			}
+
+		case '"':
+			e := stringEnd(b, i)
+			if e == -1 {
+				return errors.New("jsn: unterminated string")
+			}
+			if key {
+				key = false
+				if v := valueStart(b, e+1); bytes.HasPrefix(b[v:], []byte("null")) {
+					i = v + 3
+					continue
+				}
+				f := &st[len(st)-1]
+				if f.written {
+					w.WriteByte(',')
+				}
+				f.written = true
+			}
+			w.Write(b[i : e+1])
+			i = e
+			continue
+		}
+		w.WriteByte(c)
+	}
+
+	if len(st) != 0 {
+		return errors.New("jsn: unbalanced json")
+	}
+	return nil
+}
+
+// valueStart returns the index of the value following the colon after a key
+func valueStart(b []byte, i int) int {
+	for ; i < len(b); i++ {
+		switch b[i] {
+		case ' ', '\t', '\n', '\r', ':':
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// stringEnd returns the index of the quote that closes the string
+// starting at s or -1 if the string is not closed
+func stringEnd(b []byte, s int) int {
+	for i := s + 1; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
@@ -44,9 +44,13 @@ func (c *compilerContext) renderStdColumn(sel *qcode.Select, f qcode.Field) {
 		c.w.WriteString(` THEN `)
 	}
 
-
-
-	c.colWithTableID(sel.Table, sel.ID, f.Col.Name)
+	if f.Mask.Type != qcode.MaskTypeNone {
+		c.dialect.RenderMask(c, f.Mask, func() {
+			c.colWithTableID(sel.Table, sel.ID, f.Col.Name)
+		})
+	} else {
+		c.colWithTableID(sel.Table, sel.ID, f.Col.Name)
+	}
 
 	if f.FieldFilter.Exp != nil {
 		c.w.WriteString(` ELSE null END)`)
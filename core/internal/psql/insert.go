@@ -38,8 +38,46 @@ func (c *compilerContext) renderInsertStmt(m qcode.Mutate, embedded bool) {
 
 		c.renderValues(m, false)
 
+		if m.OnConflict != nil {
+			c.renderOnConflict(m)
+		}
+
 		if !embedded {
 			c.dialect.RenderReturning(c, &m)
 		}
 	})
 }
+
+// renderOnConflict renders the conflict handling of a bulk upsert, the
+// conflicting rows matching the update filter of the role are updated with
+// the values of the insert and the update presets
+func (c *compilerContext) renderOnConflict(m qcode.Mutate) {
+	c.w.WriteString(` ON CONFLICT (`)
+	for i, col := range m.OnConflict.Target {
+		if i != 0 {
+			c.w.WriteString(`, `)
+		}
+		c.quoted(col.Name)
+	}
+	c.w.WriteString(`) DO UPDATE SET `)
+	for i, col := range m.OnConflict.Update {
+		if i != 0 {
+			c.w.WriteString(`, `)
+		}
+		c.quoted(col.Name)
+		c.w.WriteString(` = EXCLUDED.`)
+		c.quoted(col.Name)
+	}
+	for i, col := range m.OnConflict.Presets {
+		if i != 0 || len(m.OnConflict.Update) != 0 {
+			c.w.WriteString(`, `)
+		}
+		c.quoted(col.Col.Name)
+		c.w.WriteString(` = `)
+		c.renderColumnValue(m, col)
+	}
+	if m.OnConflict.Where.Exp != nil {
+		c.w.WriteString(` WHERE `)
+		c.renderExp(m.Ti, m.OnConflict.Where.Exp, false)
+	}
+}
@@ -2,7 +2,11 @@ package psql_test
 
 import (
 	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
 )
 
 func simpleInsert(t *testing.T) {
@@ -343,3 +347,154 @@ func TestCompileInsert(t *testing.T) {
 	t.Run("nestedInsertOneToOneWithConnectArray", nestedInsertOneToOneWithConnectArray)
 	t.Run("nestedInsertRecursive", nestedInsertRecursive)
 }
+
+func TestCompileBulkUpsert(t *testing.T) {
+	gql := `mutation {
+		users(insert: $data, on_conflict: { target: [email], update: [full_name] }) {
+			id
+			email
+		}
+	}`
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`[{"email": "one@test.com", "full_name": "One"}, {"email": "two@test.com", "full_name": "Two"}]`),
+	}
+
+	tests := []struct {
+		dbType string
+		exp    []string
+	}{
+		{"postgres", []string{
+			`ON CONFLICT ("email") DO UPDATE SET "full_name" = EXCLUDED."full_name" RETURNING "public"."users".*`,
+		}},
+		{"mysql", []string{
+			"ON DUPLICATE KEY UPDATE `full_name` = VALUES(`full_name`);",
+			// the inserted and updated rows are returned by their email
+			"WHERE `users`.`email` IN (SELECT _gj_ids.id FROM JSON_TABLE(?, '$[*]' COLUMNS (id TEXT PATH '$.email'",
+		}},
+		{"mssql", []string{
+			`MERGE INTO [public].[users] WITH (HOLDLOCK) USING (SELECT `,
+			`[t].[email] AS [email]`,
+			`AS [_gj_s] ON ([users].[email] = [_gj_s].[email]) WHEN MATCHED THEN UPDATE SET [users].[full_name] = [_gj_s].[full_name] ` +
+				`WHEN NOT MATCHED THEN INSERT (`,
+			`WHERE ([users_0].[email] IN (SELECT [email] FROM OPENJSON(`,
+		}},
+		{"mongodb", []string{
+			`{"operation":"bulkUpsert","collection":"users","raw_document":"$1",` +
+				`"conflict_target":["email"],"conflict_update":["full_name"],"field_name":"users"`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dbType, func(t *testing.T) {
+			out := compileForDialect(t, tt.dbType, gql, vars, "admin")
+			for _, exp := range tt.exp {
+				if !strings.Contains(out, exp) {
+					t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+				}
+			}
+		})
+	}
+
+	// a single row captures the id of the inserted or updated row
+	gql = `mutation {
+		users(insert: { email: $email, full_name: "One" }, on_conflict: { target: email }) { id }
+	}`
+	vars = map[string]json.RawMessage{"email": json.RawMessage(`"one@test.com"`)}
+	out := compileForDialect(t, "mysql", gql, vars, "admin")
+	exp := "ON DUPLICATE KEY UPDATE `id` = LAST_INSERT_ID(`id`), `full_name` = VALUES(`full_name`);"
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+}
+
+func TestCompileBulkUpsertRole(t *testing.T) {
+	// the existing rows are updated with the update filter and presets of the role
+	gql := `mutation {
+		products(insert: $data, on_conflict: { target: [id], update: [name] }) { id }
+	}`
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`[{"id": 1, "name": "One"}, {"id": 2, "name": "Two"}]`),
+	}
+
+	tests := []struct {
+		dbType string
+		exp    string
+	}{
+		{"postgres", `ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", ` +
+			`"updated_at" = 'now' :: timestamp without time zone WHERE (("products"."user_id") = $3)`},
+		{"sqlite", `ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name", ` +
+			`"updated_at" = CAST('now' AS timestamp without time zone) WHERE (("products"."user_id") = ?)`},
+		{"mysql", "ON DUPLICATE KEY UPDATE `name` = IF((@gj_conflict := (((`products`.`user_id`) = ?))), VALUES(`name`), `name`), " +
+			"`updated_at` = IF(@gj_conflict, CAST('now' AS DATETIME), `updated_at`);"},
+		{"mssql", `WHEN MATCHED AND ((([products].[user_id]) = @p4)) THEN UPDATE SET [products].[name] = [_gj_s].[name], ` +
+			`[products].[updated_at] = CAST('now' AS DATETIME2) WHEN NOT MATCHED`},
+		{"mongodb", `"conflict_target":["_id"],"conflict_update":["name"],` +
+			`"conflict_presets":{"updated_at":"now"},"filter":{"user_id":"$3"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dbType, func(t *testing.T) {
+			out := compileForDialect(t, tt.dbType, gql, vars, "user")
+			if !strings.Contains(out, tt.exp) {
+				t.Fatalf("expected:\n%s\ngot:\n%s", tt.exp, out)
+			}
+		})
+	}
+
+	// the columns set by an update preset are not updated with the inserted values
+	gql = `mutation {
+		products(insert: $data, on_conflict: { target: [id], update: [name, updated_at] }) { id }
+	}`
+	vars = map[string]json.RawMessage{
+		"data": json.RawMessage(`[{"id": 1, "name": "One", "updated_at": "2020-01-01"}]`),
+	}
+	out := compileForDialect(t, "postgres", gql, vars, "user")
+	if exp := `DO UPDATE SET "name" = EXCLUDED."name", "updated_at" = 'now'`; !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+}
+
+func TestCompileBulkUpsertForbidden(t *testing.T) {
+	err := qcompile.AddRole("reader", "public", "users", qcode.TRConfig{
+		Update: qcode.UpdateConfig{Block: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = qcompile.AddRole("editor", "public", "users", qcode.TRConfig{
+		Update: qcode.UpdateConfig{Columns: []string{"full_name"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`[{"email": "one@test.com", "full_name": "One", "avatar": "one.png"}]`),
+	}
+	tests := []struct {
+		role string
+		gql  string
+	}{
+		// the role cannot update the table
+		{"reader", `mutation { users(insert: $data, on_conflict: { target: [email] }) { id } }`},
+		// the role cannot update the column
+		{"editor", `mutation { users(insert: $data, on_conflict: { target: [email], update: [avatar] }) { id } }`},
+	}
+	for _, tt := range tests {
+		_, err := qcompile.Compile([]byte(tt.gql), vars, tt.role, "")
+		if !errors.Is(err, qcode.ErrForbidden) {
+			t.Fatalf("expected a forbidden error for role %s, got: %v", tt.role, err)
+		}
+	}
+
+	// the update columns default to the ones the role can update
+	qc, err := qcompile.Compile([]byte(`mutation {
+		users(insert: $data, on_conflict: { target: [email] }) { id }
+	}`), vars, "editor", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := qc.Mutates[0].OnConflict.Update; len(u) != 1 || u[0].Name != "full_name" {
+		t.Fatalf("expected only full_name to be updated, got: %v", u)
+	}
+}
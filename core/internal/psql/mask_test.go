@@ -0,0 +1,52 @@
+package psql_test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColumnMasks(t *testing.T) {
+	gql := `query {
+		users {
+			id
+			full_name
+			email
+		}
+	}`
+
+	tests := []struct {
+		dbType string
+		want   []string
+	}{
+		{"postgres", []string{`'****' AS "full_name"`, `('****' || RIGHT(CAST("users_0"."email" AS text), 4)) AS "email"`}},
+		{"mysql", []string{"'****' AS `full_name`", "CONCAT('****', RIGHT(CAST(`users_0`.`email` AS CHAR), 4)) AS `email`"}},
+		{"sqlite", []string{`'****' AS "full_name"`, `('****' || substr(CAST("users_0"."email" AS TEXT), -4)) AS "email"`}},
+		{"mssql", []string{`'****' AS [full_name]`, `CONCAT('****', RIGHT(CAST([users_0].[email] AS NVARCHAR(MAX)), 4)) AS [email]`}},
+		{"mongodb", []string{`"full_name":{"$literal":"****"}`, `"$strLenCP":"$$s"},4]}]},4]}`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dbType, func(t *testing.T) {
+			out := compileForDialect(t, tt.dbType, gql, nil, "support")
+			for _, w := range tt.want {
+				if !strings.Contains(out, w) {
+					t.Errorf("expected masked column %s in: %s", w, out)
+				}
+			}
+		})
+	}
+}
+
+func TestColumnMasksOtherRoles(t *testing.T) {
+	gql := `query {
+		users {
+			id
+			email
+		}
+	}`
+
+	out := compileForDialect(t, "postgres", gql, nil, "user")
+	if strings.Contains(out, `'****'`) {
+		t.Fatalf("masks must only apply to the configured role: %s", out)
+	}
+}
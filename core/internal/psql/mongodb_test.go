@@ -0,0 +1,2736 @@
+package psql_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3/internal/psql"
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+	"github.com/dosco/graphjin/core/v3/internal/sdata"
+)
+
+func TestMongoDBRelationshipShape(t *testing.T) {
+	// Users without any products must still get an empty products array,
+	// and products without an owner must get a null user.
+	gql := `query {
+		users {
+			id
+			products {
+				id
+				user {
+					id
+				}
+			}
+		}
+	}`
+
+	out := compileForDialect(t, "mongodb", gql, nil, "admin")
+
+	if !strings.Contains(out, `"products":{"$ifNull":["$products",[]]}`) {
+		t.Fatalf("expected plural relationship to default to an empty array: %s", out)
+	}
+	if !strings.Contains(out, `"user":{"$ifNull":[{"$arrayElemAt":["$user",0]},null]}`) {
+		t.Fatalf("expected singular relationship to default to null: %s", out)
+	}
+}
+
+func TestMongoDBRootSingularRelationship(t *testing.T) {
+	gql := `query {
+		products {
+			id
+			user {
+				id
+			}
+		}
+	}`
+
+	out := compileForDialect(t, "mongodb", gql, nil, "admin")
+
+	if !strings.Contains(out, `"user":{"$ifNull":[{"$arrayElemAt":["$user",0]},null]}`) {
+		t.Fatalf("expected singular relationship to default to null: %s", out)
+	}
+	if strings.Contains(out, `"user":1`) {
+		t.Fatalf("singular relationship must not be projected as a raw array: %s", out)
+	}
+}
+
+func TestMongoDBUnorderedInsertMany(t *testing.T) {
+	gql := `mutation {
+		users(insert: $data) @insertOptions(ordered: false) {
+			id
+		}
+	}`
+
+	vars := map[string]json.RawMessage{
+		"data": json.RawMessage(`[{"id": 1, "email": "a@test.com"}, {"id": 2, "email": "b@test.com"}]`),
+	}
+
+	out := compileForDialect(t, "mongodb", gql, vars, "admin")
+
+	// Variable arrays are switched to insertMany by the driver at runtime
+	if !strings.Contains(out, `"options":{"ordered":false}`) {
+		t.Fatalf("expected unordered insert option: %s", out)
+	}
+}
+
+func TestMongoDBUnorderedInsertManyInline(t *testing.T) {
+	gql := `mutation {
+		users(insert: [{ id: 1, email: "a@test.com" }, { id: 2, email: "b@test.com" }]) @insertOptions(ordered: false) {
+			id
+		}
+	}`
+
+	out := compileForDialect(t, "mongodb", gql, nil, "admin")
+
+	if !strings.Contains(out, `"operation":"insertMany"`) {
+		t.Fatalf("expected an insertMany operation: %s", out)
+	}
+	if !strings.Contains(out, `"options":{"ordered":false}`) {
+		t.Fatalf("expected unordered insert option: %s", out)
+	}
+}
+
+func TestMongoDBOrderedInsertManyByDefault(t *testing.T) {
+	gql := `mutation {
+		users(insert: [{ id: 1, email: "a@test.com" }, { id: 2, email: "b@test.com" }]) {
+			id
+		}
+	}`
+
+	out := compileForDialect(t, "mongodb", gql, nil, "admin")
+
+	if strings.Contains(out, `"ordered"`) {
+		t.Fatalf("ordered inserts must not emit an ordered option: %s", out)
+	}
+}
+
+func TestMongoDBLikeOperators(t *testing.T) {
+	tests := []struct {
+		name  string
+		where string
+		want  string
+	}{
+		{"like", `{ name: { like: "admin%" } }`, `"name":{"$regex":"admin.*"}`},
+		{"ilike", `{ name: { ilike: "admin%" } }`, `"name":{"$regex":"admin.*","$options":"i"}`},
+		{"nlike", `{ name: { nlike: "admin%" } }`, `"name":{"$not":{"$regex":"admin.*"}}`},
+		{"nilike", `{ name: { nilike: "admin%" } }`, `"name":{"$not":{"$regex":"admin.*","$options":"i"}}`},
+		{"wildcards", `{ name: { nlike: "a_c%" } }`, `"name":{"$not":{"$regex":"a.c.*"}}`},
+		// the patterns are not anchored so a value spanning several lines
+		// still matches when one of its lines does
+		{"contains", `{ name: { like: "%admin%" } }`, `"name":{"$regex":".*admin.*"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gql := `query { products(where: ` + tt.where + `) { id } }`
+			out := compileForDialect(t, "mongodb", gql, nil, "admin")
+
+			if !strings.Contains(out, tt.want) {
+				t.Fatalf("expected %s in: %s", tt.want, out)
+			}
+		})
+	}
+}
+
+// compileMongoEmbedded compiles the query against an orders collection
+// with an embedded items array
+func compileMongoEmbedded(t *testing.T, gql string, vars map[string]json.RawMessage) string {
+	t.Helper()
+
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "orders", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "orders", Name: "status", Type: "text"},
+		{Schema: "public", Table: "orders", Name: "items", Type: "json"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	bt, err := di.GetTable("public", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := di.GetColumn("public", "orders", "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// json column exposed as a table, same as a 'json' table in the config
+	nt := sdata.NewDBTable("public", "items", "json", []sdata.DBColumn{
+		{ID: -1, Schema: "public", Table: "items", Name: "sku", Type: "text"},
+		{ID: -1, Schema: "public", Table: "items", Name: "qty", Type: "int"},
+	})
+	nt.PrimaryCol = *bc
+	nt.PrimaryCol.PrimaryKey = true
+	nt.SecondaryCol = bt.PrimaryCol
+	di.AddTable(nt)
+
+	return compileMongoSchema(t, di, gql, vars)
+}
+
+// compileMongoSchema compiles the query as admin against the database info
+func compileMongoSchema(t *testing.T, di *sdata.DBInfo, gql string, vars map[string]json.RawMessage) string {
+	t.Helper()
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qc, err := co.Compile([]byte(gql), vars, "admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, out, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestMongoDBUpdateEmbeddedArrayByFilter(t *testing.T) {
+	gql := `mutation {
+		orders(id: 1, update: { status: "shipped", items: { where: { sku: { eq: "X" } }, qty: 5 } }) {
+			id
+			items {
+				sku
+				qty
+			}
+		}
+	}`
+
+	out := compileMongoEmbedded(t, gql, nil)
+
+	if !strings.Contains(out, `"operation":"updateOne","collection":"orders"`) {
+		t.Fatalf("expected a single updateOne on the parent collection: %s", out)
+	}
+	if !strings.Contains(out, `"$set":{"status":"shipped","items.$[e1].qty":5}`) {
+		t.Fatalf("expected the positional path for the matching elements: %s", out)
+	}
+	if !strings.Contains(out, `"options":{"arrayFilters":[{"e1.sku":"X"}]}`) {
+		t.Fatalf("expected the arrayFilters option: %s", out)
+	}
+	if strings.Contains(out, `"where"`) {
+		t.Fatalf("the element filter must not be written to the document: %s", out)
+	}
+}
+
+func TestMongoDBUpdateEmbeddedArrayAllElements(t *testing.T) {
+	gql := `mutation {
+		orders(id: 1, update: { items: { qty: 0 } }) {
+			id
+		}
+	}`
+
+	out := compileMongoEmbedded(t, gql, nil)
+
+	if !strings.Contains(out, `"$set":{"items.$[].qty":0}`) {
+		t.Fatalf("expected all elements to be updated without a filter: %s", out)
+	}
+	if strings.Contains(out, `arrayFilters`) {
+		t.Fatalf("expected no arrayFilters without a filter: %s", out)
+	}
+}
+
+func TestMongoDBUpdateEmbeddedArrayFilterVariable(t *testing.T) {
+	gql := `mutation {
+		orders(id: 1, update: { items: { where: { sku: { eq: $sku }, qty: { lt: 10 } }, qty: 10 } }) {
+			id
+		}
+	}`
+	vars := map[string]json.RawMessage{"sku": json.RawMessage(`"X"`)}
+
+	out := compileMongoEmbedded(t, gql, vars)
+
+	if !strings.Contains(out, `"arrayFilters":[{"$and":[`) ||
+		!strings.Contains(out, `{"e1.sku":"$1"}`) ||
+		!strings.Contains(out, `{"e1.qty":{"$lt":10}}`) {
+		t.Fatalf("expected all conditions on the array element: %s", out)
+	}
+}
+
+func TestMongoDBUpdateEmbeddedArrayReplace(t *testing.T) {
+	// a list replaces the three items of the order with two
+	gql := `mutation {
+		orders(id: 1, update: { status: "packed", items: [{ id: "a", sku: "A", qty: 1 }, { sku: "B", qty: $qty }] }) {
+			id
+			items { sku }
+		}
+	}`
+	vars := map[string]json.RawMessage{"qty": json.RawMessage(`2`)}
+
+	out := compileMongoEmbedded(t, gql, vars)
+
+	exp := `"items":[{"_id":"a","sku":"A","qty":1},{"sku":"B","qty":"$1"}]`
+	if !strings.Contains(out, exp) || !strings.Contains(out, `"status":"packed"`) {
+		t.Fatalf("expected the whole array to be set:\n%s\ngot:\n%s", exp, out)
+	}
+	if strings.Contains(out, `$[`) || strings.Contains(out, `arrayFilters`) {
+		t.Fatalf("expected no element wise update: %s", out)
+	}
+
+	// the list can also come from a variable
+	vars = map[string]json.RawMessage{"data": json.RawMessage(`{"items":[{"id":"a","sku":"A"},{"id":"b","sku":"B"}]}`)}
+	out = compileMongoEmbedded(t, `mutation { orders(id: 1, update: $data) { id } }`, vars)
+
+	if exp := `"$set":{"items":[{"_id":"a","sku":"A"},{"_id":"b","sku":"B"}]}`; !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// an object still updates the matching elements
+	out = compileMongoEmbedded(t, `mutation {
+		orders(id: 1, update: { items: { where: { sku: { eq: "A" } }, qty: 3 } }) { id }
+	}`, nil)
+	if !strings.Contains(out, `"$set":{"items.$[e1].qty":3}`) {
+		t.Fatalf("expected an element wise update: %s", out)
+	}
+}
+
+func TestMongoDBEmbeddedElementProjection(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "orders", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "orders", Name: "items", Type: "json"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	bt, err := di.GetTable("public", "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := di.GetColumn("public", "orders", "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := sdata.NewDBTable("public", "items", "json", []sdata.DBColumn{
+		{ID: -1, Schema: "public", Table: "items", Name: "id", Type: "text"},
+		{ID: -1, Schema: "public", Table: "items", Name: "sku", Type: "text"},
+		{ID: -1, Schema: "public", Table: "items", Name: "dims", Type: "json"},
+	})
+	it.PrimaryCol = *bc
+	it.PrimaryCol.PrimaryKey = true
+	it.SecondaryCol = bt.PrimaryCol
+	di.AddTable(it)
+
+	// a json column of the embedded element exposed as a table
+	ic, err := di.GetColumn("public", "items", "dims")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dt := sdata.NewDBTable("public", "dims", "json", []sdata.DBColumn{
+		{ID: -1, Schema: "public", Table: "dims", Name: "width", Type: "int"},
+	})
+	dt.PrimaryCol = *ic
+	dt.PrimaryCol.PrimaryKey = true
+	dt.SecondaryCol = it.PrimaryCol
+	di.AddTable(dt)
+
+	tests := []struct {
+		name string
+		gql  string
+		want string
+	}{
+		{
+			name: "alias",
+			gql:  `query { orders { id items { code: sku } } }`,
+			want: `"in":{"code":"$$elem.sku"}`,
+		},
+		{
+			name: "id",
+			gql:  `query { orders { id items { id } } }`,
+			want: `"in":{"id":{"$ifNull":["$$elem._id","$$elem.id"]}}`,
+		},
+		{
+			name: "nested path",
+			gql:  `query { orders { id items { sku dims { w: width } } } }`,
+			want: `"dims":{"$map":{"input":{"$switch":{"branches":[{"case":{"$isArray":"$$elem.dims"},"then":"$$elem.dims"},` +
+				`{"case":{"$eq":[{"$type":"$$elem.dims"},"object"]},"then":["$$elem.dims"]}],"default":[]}},` +
+				`"as":"sub","in":{"w":"$$sub.width"}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := compileMongoSchema(t, di, tt.gql, nil)
+			if !strings.Contains(out, tt.want) {
+				t.Fatalf("expected %s in: %s", tt.want, out)
+			}
+			// nested embedded objects are not looked up from a collection
+			if strings.Contains(out, `"from":"dims"`) {
+				t.Fatalf("expected no lookup for the nested embedded object: %s", out)
+			}
+		})
+	}
+}
+
+func TestMongoDBCursorDefaultsToIDOrder(t *testing.T) {
+	// A collection without a discovered primary key
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "events", Name: "name", Type: "string"},
+		{Schema: "public", Table: "events", Name: "score", Type: "long"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+	vars := map[string]json.RawMessage{"cursor": json.RawMessage(`null`)}
+
+	out := compileMongoSchema(t, di, `query { events(first: 5, after: $cursor) { name } }`, vars)
+
+	if !strings.Contains(out, `{"$sort_ordered":[["_id",1]]}`) {
+		t.Fatalf("expected a default sort on _id: %s", out)
+	}
+	if !strings.Contains(out, `"cursor_info":{"sel_id":0,"prefix":"","order_by":[{"col":"_id","order":"asc"}]}`) {
+		t.Fatalf("expected cursor info keyed on _id: %s", out)
+	}
+	if !strings.Contains(out, `"__cursor__id":"$_id"`) {
+		t.Fatalf("expected _id to be projected for the cursor: %s", out)
+	}
+
+	out = compileMongoSchema(t, di, `query { events(last: 5, before: $cursor) { name } }`, vars)
+
+	if !strings.Contains(out, `{"$sort_ordered":[["_id",-1]]}`) ||
+		!strings.Contains(out, `{"col":"_id","order":"desc"}`) {
+		t.Fatalf("expected a descending _id order for last: %s", out)
+	}
+
+	// _id is added as the tie-breaker after the requested order
+	out = compileMongoSchema(t, di,
+		`query { events(first: 5, after: $cursor, order_by: { score: desc }) { name } }`, vars)
+
+	if !strings.Contains(out, `"order_by":[{"col":"score","order":"desc"},{"col":"_id","order":"asc"}]`) {
+		t.Fatalf("expected _id as the tie-breaker: %s", out)
+	}
+}
+
+func TestMongoDBDecimalAggregates(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "payments", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "payments", Name: "amount", Type: "numeric"},
+		{Schema: "public", Table: "payments", Name: "fee", Type: "double precision"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	out := compileMongoSchema(t, di,
+		`query { payments { sum_amount avg_amount max_amount sum_fee } }`, nil)
+
+	if !strings.Contains(out, `"sum_amount":{"$sum":{"$toDecimal":"$amount"}}`) {
+		t.Fatalf("expected the decimal sum to use $toDecimal: %s", out)
+	}
+	if !strings.Contains(out, `"avg_amount":{"$avg":{"$toDecimal":"$amount"}}`) {
+		t.Fatalf("expected the decimal avg to use $toDecimal: %s", out)
+	}
+	if !strings.Contains(out, `"max_amount":{"$max":"$amount"}`) {
+		t.Fatalf("expected max to be unchanged: %s", out)
+	}
+	if !strings.Contains(out, `"sum_fee":{"$sum":"$fee"}`) {
+		t.Fatalf("expected non-decimal columns to be unchanged: %s", out)
+	}
+}
+
+func TestMongoDBArraySizeAndSlice(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "posts", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "posts", Name: "title", Type: "text"},
+		{Schema: "public", Table: "posts", Name: "tags", Type: "jsonb", Array: true},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	out := compileMongoSchema(t, di, `query { posts { id tags @size } }`, nil)
+
+	if !strings.Contains(out, `"tags":{"$size":{"$ifNull":["$tags",[]]}}`) {
+		t.Fatalf("expected the array size: %s", out)
+	}
+
+	out = compileMongoSchema(t, di, `query { posts { id tags @slice(skip: 2, limit: 5) } }`, nil)
+
+	if !strings.Contains(out, `"tags":{"$slice":[{"$ifNull":["$tags",[]]},2,5]}`) {
+		t.Fatalf("expected the array slice: %s", out)
+	}
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []string{
+		`query { posts { tags @size @slice(limit: 2) } }`,
+		`query { posts { title @size } }`,
+		`query { posts { tags @slice(skip: 1) } }`,
+		`query { posts { tags @slice(limit: 0) } }`,
+		`query { posts { tags @slice(limit: -1) } }`,
+		`query { posts { tags @size(limit: 1) } }`,
+	}
+	for _, gql := range invalid {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+			t.Errorf("expected an error for: %s", gql)
+		}
+	}
+}
+
+func TestMongoDBArrayAggregates(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "players", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "players", Name: "name", Type: "text"},
+		{Schema: "public", Table: "players", Name: "scores", Type: "bigint", Array: true},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	// the aggregates are computed over the elements of each document
+	out := compileMongoSchema(t, di, `query { players { id scores_sum: scores @sum scores_avg: scores @avg } }`, nil)
+	if !strings.Contains(out, `"scores_sum":{"$sum":"$scores"}`) {
+		t.Fatalf("expected the sum of the array: %s", out)
+	}
+	if !strings.Contains(out, `"scores_avg":{"$avg":"$scores"}`) {
+		t.Fatalf("expected the average of the array: %s", out)
+	}
+	if strings.Contains(out, `"$group"`) {
+		t.Fatalf("expected no grouping of the documents: %s", out)
+	}
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []string{
+		`query { players { name @sum } }`,
+		`query { players { scores @sum @avg } }`,
+		`query { players { scores @avg(limit: 1) } }`,
+	}
+	for _, gql := range invalid {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+			t.Errorf("expected an error for: %s", gql)
+		}
+	}
+}
+
+func TestMongoDBFlattenSingularRelationship(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "users", Name: "full_name", Type: "text"},
+		{Schema: "public", Table: "users", Name: "email", Type: "text"},
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "name", Type: "text"},
+		{Schema: "public", Table: "products", Name: "owner_id", Type: "bigint", FKeySchema: "public", FKeyTable: "users", FKeyCol: "id"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	out := compileMongoSchema(t, di,
+		`query { products { id name owner @flatten { full_name email } } }`, nil)
+
+	exp := `{"$replaceRoot":{"newRoot":{"$mergeObjects":[{"$ifNull":["$owner",{}]},"$$ROOT"]}}},{"$project":{"owner":0}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected the owner to be merged into the product: %s", out)
+	}
+	// the parent is merged last so its fields win on a collision
+	if strings.Index(out, `"owner":{"$ifNull":[{"$arrayElemAt":["$owner",0]},null]}`) > strings.Index(out, exp) {
+		t.Fatalf("expected the singular owner to be projected before the merge: %s", out)
+	}
+
+	out = compileMongoSchema(t, di, `query { products { id owner { full_name } } }`, nil)
+	if strings.Contains(out, `$mergeObjects`) {
+		t.Fatalf("expected no merge without @flatten: %s", out)
+	}
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []string{
+		`query { users { id products @flatten { name } } }`,
+		`query { products @flatten { id } }`,
+	}
+	for _, gql := range invalid {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+			t.Errorf("expected an error for: %s", gql)
+		}
+	}
+}
+
+func TestMongoDBVersionColumnUpdate(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "notes", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "notes", Name: "body", Type: "text"},
+		{Schema: "public", Table: "notes", Name: "version", Type: "bigint"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{
+		DBSchema: schema.DBSchema(),
+		TConfig:  map[string]qcode.TConfig{"publicnotes": {VersionColumn: "version"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qc, err := co.Compile([]byte(`mutation {
+		notes(id: 1, update: { body: "Second", version: 1 }) { id body version }
+	}`), nil, "admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, b, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+
+	if !strings.Contains(out, `,"version":1},"update":{"$set":{"body":"Second"},"$inc":{"version":1}}`) {
+		t.Fatalf("expected the expected version in the filter and the version incremented: %s", out)
+	}
+	if !strings.Contains(out, `"version_field":"version"`) {
+		t.Fatalf("expected the version field to be set: %s", out)
+	}
+
+	// the expected version is required
+	_, err = co.Compile([]byte(`mutation { notes(id: 1, update: { body: "Third" }) { id } }`), nil, "admin", "")
+	if err == nil {
+		t.Fatal("expected an error for the missing version")
+	}
+}
+
+func TestMongoDBCollation(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "users", Name: "full_name", Type: "text"},
+		{Schema: "public", Table: "users", Name: "email", Type: "text"},
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "name", Type: "text"},
+		{Schema: "public", Table: "products", Name: "owner_id", Type: "bigint", FKeySchema: "public", FKeyTable: "users", FKeyCol: "id"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{
+		DBSchema: schema.DBSchema(),
+		TConfig: map[string]qcode.TConfig{
+			"publicproducts": {Collation: "en"},
+			"publicusers":    {ColCollation: map[string]string{"email": "fr"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compile := func(gql string) string {
+		t.Helper()
+		qc, err := co.Compile([]byte(gql), nil, "admin", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, out, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+
+	tests := []struct {
+		name string
+		gql  string
+		exp  string
+	}{
+		{
+			name: "table",
+			gql:  `query { products(order_by: { name: asc }) { id name } }`,
+			exp:  `"options":{"collation":{"locale":"en","strength":2}}`,
+		},
+		{
+			name: "column filter",
+			gql:  `query { users(where: { email: { eq: "BOB@test.com" } }) { id } }`,
+			exp:  `"options":{"collation":{"locale":"fr","strength":2}}`,
+		},
+		{
+			name: "column sort",
+			gql:  `query { users(order_by: { email: desc }) { id } }`,
+			exp:  `"options":{"collation":{"locale":"fr","strength":2}}`,
+		},
+		{
+			name: "child table",
+			gql:  `query { users { id products { name } } }`,
+			exp:  `"options":{"collation":{"locale":"en","strength":2}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if out := compile(tt.gql); !strings.Contains(out, tt.exp) {
+				t.Fatalf("expected the collation %s: %s", tt.exp, out)
+			}
+		})
+	}
+
+	// other columns use the default collation
+	if out := compile(`query { users(order_by: { full_name: asc }) { id } }`); strings.Contains(out, `"collation"`) {
+		t.Fatalf("expected no collation: %s", out)
+	}
+}
+
+func TestMongoDBStaticLookupTable(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "orders", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "orders", Name: "status_code", Type: "text", FKeySchema: "public", FKeyTable: "statuses", FKeyCol: "code"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+	di.AddTable(sdata.NewDBTable("public", "statuses", "static", []sdata.DBColumn{
+		{ID: -1, Name: "code", Type: "text", PrimaryKey: true},
+		{ID: -1, Name: "label", Type: "text"},
+	}))
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{
+		DBSchema: schema.DBSchema(),
+		TConfig: map[string]qcode.TConfig{"publicstatuses": {
+			StaticRows: json.RawMessage(`[{"code":"N","label":"New"},{"code":"S","label":"Shipped"}]`),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qc, err := co.Compile([]byte(`query { orders { id statuses { label } } }`), nil, "admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, b, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+
+	exp := `{"$lookup":{"let":{"joinValue":"$status_code"},"pipeline":[` +
+		`{"$documents":[{"code":"N","label":"New"},{"code":"S","label":"Shipped"}]},` +
+		`{"$match":{"$expr":{"$eq":["$code","$$joinValue"]}}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected a lookup on the static rows: %s", out)
+	}
+	if strings.Contains(out, `"from":"statuses"`) {
+		t.Fatalf("expected no lookup on a collection: %s", out)
+	}
+
+	invalid := []string{
+		`query { statuses { code } }`,
+		`mutation { orders(insert: { id: 1, statuses: { code: "X", label: "Lost" } }) { id } }`,
+	}
+	for _, gql := range invalid {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+			t.Errorf("expected an error for: %s", gql)
+		}
+	}
+}
+
+func TestMongoDBChangeStreamSubscription(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "users", Name: "full_name", Type: "text"},
+		{Schema: "public", Table: "users", Name: "active", Type: "boolean"},
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "owner_id", Type: "bigint", FKeySchema: "public", FKeyTable: "users", FKeyCol: "id"},
+	}
+	schema, err := sdata.NewDBSchema(sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `subscription {
+		users(where: { active: true }) @changeStream(mode: "fields") { id name: full_name }
+	}`
+	qc, err := co.Compile([]byte(gql), nil, "admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, b, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var q map[string]any
+	if err := json.Unmarshal(b, &q); err != nil {
+		t.Fatalf("invalid query DSL: %s: %v", b, err)
+	}
+	if q["operation"] != "watch" || q["collection"] != "users" || q["changes"] != "fields" {
+		t.Fatalf("expected a watch on the users collection: %s", b)
+	}
+	if exp := `"filter":{"active":true}`; !strings.Contains(string(b), exp) {
+		t.Fatalf("expected the filter %s: %s", exp, b)
+	}
+	if exp := `"fields":{"id":"_id","name":"full_name"}`; !strings.Contains(string(b), exp) {
+		t.Fatalf("expected the fields %s: %s", exp, b)
+	}
+
+	qc, err = co.Compile([]byte(`subscription { users @changeStream { id } }`), nil, "admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, b, err = psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"changes":"document"`) {
+		t.Fatalf("expected the document mode by default: %s", b)
+	}
+
+	invalid := []string{
+		`query { users @changeStream { id } }`,
+		`subscription { users @changeStream { id products { id } } }`,
+		`subscription { users { id products @changeStream { id } } }`,
+		`subscription { users @changeStream(mode: "diff") { id } }`,
+		`subscription { users @changeStream { id } products { id } }`,
+	}
+	for _, gql := range invalid {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+			t.Errorf("expected an error for: %s", gql)
+		}
+	}
+}
+
+func TestMongoDBRootCount(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "users", Name: "email", Type: "text"},
+		{Schema: "public", Table: "users", Name: "active", Type: "boolean"},
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "owner_id", Type: "bigint", FKeySchema: "public", FKeyTable: "users", FKeyCol: "id"},
+	}
+	schema, err := sdata.NewDBSchema(sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compile := func(gql string) string {
+		t.Helper()
+		qc, err := co.Compile([]byte(gql), nil, "admin", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, b, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var q map[string]any
+		if err := json.Unmarshal(b, &q); err != nil {
+			t.Fatalf("invalid query DSL: %s: %v", b, err)
+		}
+		return string(b)
+	}
+
+	// the filter is matched before the documents are counted
+	b := compile(`query { users(where: { active: true }) { count } }`)
+	if exp := `"pipeline":[{"$match":{"active":true}},{"$count":"total"}],"count":true}`; !strings.Contains(b, exp) {
+		t.Fatalf("expected %s: %s", exp, b)
+	}
+
+	// count_<column> only counts documents where the column is set
+	b = compile(`query { users { count_email } }`)
+	if exp := `"pipeline":[{"$match":{"email":{"$ne":null}}},{"$count":"total"}],"count":true,"count_field":"count_email"}`; !strings.Contains(b, exp) {
+		t.Fatalf("expected %s: %s", exp, b)
+	}
+	b = compile(`query { users { count_id } }`)
+	if exp := `"pipeline":[{"$count":"total"}],"count":true,"count_field":"count_id"}`; !strings.Contains(b, exp) {
+		t.Fatalf("expected %s: %s", exp, b)
+	}
+
+	// counts compose with other roots
+	b = compile(`query { users { count } products { id } }`)
+	if !strings.HasPrefix(b, `{"operation":"multi_aggregate"`) ||
+		!strings.Contains(b, `{"$count":"total"}],"count":true}`) {
+		t.Fatalf("expected a count in a multi aggregate: %s", b)
+	}
+
+	// other aggregates are still grouped
+	b = compile(`query { users { count_id max_id } }`)
+	if strings.Contains(b, `$count`) || !strings.Contains(b, `$group`) {
+		t.Fatalf("expected a $group stage: %s", b)
+	}
+
+	invalid := []string{
+		`query { users { id count } }`,
+		`query { users { count products { id } } }`,
+		`query { products { id owner: users { count } } }`,
+	}
+	for _, gql := range invalid {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+			t.Fatalf("expected an error: %s", gql)
+		}
+	}
+}
+
+func TestMongoDBMaterialize(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "orders", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "orders", Name: "status", Type: "text"},
+		{Schema: "public", Table: "orders", Name: "total", Type: "numeric"},
+	}
+	schema, err := sdata.NewDBSchema(sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = co.AddRole("admin", "public", "orders", qcode.TRConfig{
+		Query: qcode.QueryConfig{Materialize: []string{"order_totals"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query {
+		orders(where: { status: $status }) @materialize(collection: "order_totals", ttlSeconds: 300) {
+			status
+			sum_total
+		}
+	}`
+	vars := map[string]json.RawMessage{"status": json.RawMessage(`"paid"`)}
+	qc, err := co.Compile([]byte(gql), vars, "admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, b, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"operation":"aggregate","collection":"orders","field_name":"orders",` +
+		`"materialize":{"collection":"order_totals","ttl":300},"pipeline":[`
+	if !strings.HasPrefix(string(b), exp) {
+		t.Fatalf("expected %s: %s", exp, b)
+	}
+
+	invalid := []string{
+		`query { orders @materialize(collection: "order_totals") { id } }`,
+		`query { orders @materialize(ttlSeconds: 60) { id } }`,
+		`query { orders @materialize(collection: "orders", ttlSeconds: 60) { id } }`,
+		`query { orders @materialize(collection: "$totals", ttlSeconds: 60) { id } }`,
+		`mutation { orders(insert: { status: "paid" }) @materialize(collection: "order_totals", ttlSeconds: 60) { id } }`,
+	}
+	for _, gql := range invalid {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+			t.Fatalf("expected an error: %s", gql)
+		}
+	}
+
+	// only the collections configured for the table and role can be used
+	forbidden := []struct{ gql, role string }{
+		{`query { orders @materialize(collection: "users", ttlSeconds: 60) { id } }`, "admin"},
+		{`query { orders @materialize(collection: "order_totals", ttlSeconds: 60) { id } }`, "user"},
+	}
+	for _, tt := range forbidden {
+		if _, err := co.Compile([]byte(tt.gql), nil, tt.role, ""); !errors.Is(err, qcode.ErrForbidden) {
+			t.Fatalf("expected a forbidden error for role %s: %s: %v", tt.role, tt.gql, err)
+		}
+	}
+
+	// only supported on mongodb
+	gql = `query { products @materialize(collection: "cache", ttlSeconds: 60) { id } }`
+	if _, err := qcompile.Compile([]byte(gql), nil, "admin", ""); err == nil {
+		t.Fatal("expected an error for a sql database")
+	}
+}
+
+func TestMongoDBArrayNullChecks(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "posts", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "posts", Name: "title", Type: "text"},
+		{Schema: "public", Table: "posts", Name: "tags", Type: "text[]", Array: true},
+	}
+	schema, err := sdata.NewDBSchema(sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isNull := `{"$or":[{"tags":{"$size":0}},{"$and":[{"tags":null},{"tags":{"$not":{"$type":"array"}}}]}]}`
+	hasNull := `{"tags":{"$elemMatch":{"$eq":null}}}`
+
+	tests := []struct {
+		where string
+		match string
+	}{
+		{`{ tags: { eq: null } }`, isNull},
+		{`{ tags: { is_null: true } }`, isNull},
+		{`{ tags: { neq: null } }`, `{"$nor":[` + isNull + `]}`},
+		{`{ tags: { is_null: false } }`, `{"$nor":[` + isNull + `]}`},
+		{`{ tags: { has_null: true } }`, hasNull},
+		{`{ tags: { has_null: false } }`, `{"$nor":[` + hasNull + `]}`},
+		{`{ title: { is_null: true } }`, `{"title":null}`},
+	}
+
+	for _, tt := range tests {
+		gql := `query { posts(where: ` + tt.where + `) { id } }`
+		qc, err := co.Compile([]byte(gql), nil, "admin", "")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.where, err)
+		}
+		_, b, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.where, err)
+		}
+		if exp := `{"$match":` + tt.match + `}`; !strings.Contains(string(b), exp) {
+			t.Fatalf("%s: expected %s: %s", tt.where, exp, b)
+		}
+	}
+
+	// has_null is only supported on array columns
+	gql := `query { posts(where: { title: { has_null: true } }) { id } }`
+	if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+		t.Fatal("expected an error for has_null on a column that is not an array")
+	}
+}
+
+func TestMongoDBSelectCount(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "users", Name: "email", Type: "text"},
+		{Schema: "public", Table: "orders", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "orders", Name: "total", Type: "numeric"},
+		{Schema: "public", Table: "orders", Name: "user_id", Type: "bigint", FKeySchema: "public", FKeyTable: "users", FKeyCol: "id"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	lookup := `{"$lookup":{"from":"orders","let":{"joinValue":"$_id"},"pipeline":[` +
+		`{"$match":{"$expr":{"$eq":["$user_id","$$joinValue"]}}},{"$project":{"_id":1}}],` +
+		`"as":"__count_orders_user_id_id"}}`
+	size := `{"$size":"$__count_orders_user_id_id"}`
+
+	// the orders are looked up for the count even though they are not selected
+	out := compileMongoSchema(t, di,
+		`query { users(where: { email: { eq: "a" }, orders: { _count: { gt: 3 } } }) { id } }`, nil)
+	exp := `"pipeline":[{"$match":{"email":"a"}},` + lookup +
+		`,{"$match":{"$expr":{"$gt":[` + size + `,3]}}},{"$limit":20},{"$project":{"_id":1}},{"$project":{"__count_orders_user_id_id":0}}]`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected %s: %s", exp, out)
+	}
+
+	// a range of counts is compared with $and
+	out = compileMongoSchema(t, di,
+		`query { users(where: { orders: { _count: { gte: 1, lt: 10 } } }) { id } }`, nil)
+	exp = `{"$match":{"$expr":{"$and":[{"$gte":[` + size + `,1]},{"$lt":[` + size + `,10]}]}}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected %s: %s", exp, out)
+	}
+
+	// the whole filter is matched after the lookups when the count is in an or
+	out = compileMongoSchema(t, di,
+		`query { users(where: { or: [{ id: 1 }, { orders: { _count: { eq: 0 } } }] }) { id orders { id } } }`, nil)
+	exp = `"pipeline":[` + lookup + `,{"$match":{"$or":[{"$expr":{"$eq":[` + size + `,0]}},{"_id":1}]}},{"$lookup":{"from":"orders"`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected %s: %s", exp, out)
+	}
+	if strings.Contains(out, `"__count_orders_user_id_id":1`) ||
+		!strings.HasSuffix(out, `{"$project":{"__count_orders_user_id_id":0}}]}`) {
+		t.Fatalf("expected the counted orders not to be projected: %s", out)
+	}
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := []string{
+		`query { users(where: { orders: { _count: { like: "1" } } }) { id } }`,
+		`query { users(where: { orders: { _count: { gt: "1" } } }) { id } }`,
+		`query { users { id orders(where: { user: { _count: { eq: 1 } } }) { id } } }`,
+		`mutation { users(delete: true, where: { orders: { _count: { eq: 0 } } }) { id } }`,
+	}
+	for _, gql := range invalid {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+			t.Errorf("expected an error: %s", gql)
+		}
+	}
+}
+
+func TestMongoDBArraySize(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "orders", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "orders", Name: "total", Type: "numeric"},
+		{Schema: "public", Table: "orders", Name: "items", Type: "text[]", Array: true},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	// a missing array has a length of 0
+	size := `{"$size":{"$ifNull":["$items",[]]}}`
+
+	tests := []struct {
+		where string
+		match string
+	}{
+		{`{ items: { _size: { eq: 0 } } }`, `{"$expr":{"$eq":[` + size + `,0]}}`},
+		{`{ items: { _size: { neq: 0 } } }`, `{"$expr":{"$ne":[` + size + `,0]}}`},
+		{`{ items: { _size: { gt: 1 } } }`, `{"$expr":{"$gt":[` + size + `,1]}}`},
+		{`{ items: { _size: { gte: 2 } } }`, `{"$expr":{"$gte":[` + size + `,2]}}`},
+		{`{ items: { _size: { lt: 3 } } }`, `{"$expr":{"$lt":[` + size + `,3]}}`},
+		{`{ items: { _size: { lte: 3 } } }`, `{"$expr":{"$lte":[` + size + `,3]}}`},
+		{`{ items: { _size: { gte: 2, lte: 5 } } }`,
+			`{"$expr":{"$and":[{"$gte":[` + size + `,2]},{"$lte":[` + size + `,5]}]}}`},
+		{`{ total: { gt: 10 }, items: { _size: { gte: 2 } } }`,
+			`{"$and":[{"$expr":{"$gte":[` + size + `,2]}},{"total":{"$gt":10}}]}`},
+	}
+	for _, tt := range tests {
+		out := compileMongoSchema(t, di, `query { orders(where: `+tt.where+`) { id } }`, nil)
+		if exp := `{"$match":` + tt.match + `}`; !strings.Contains(out, exp) {
+			t.Errorf("%s: expected %s: %s", tt.where, exp, out)
+		}
+	}
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := []string{
+		`query { orders(where: { total: { _size: { eq: 1 } } }) { id } }`,
+		`query { orders(where: { items: { _size: { like: "1" } } }) { id } }`,
+		`query { orders(where: { items: { _size: { gt: "1" } } }) { id } }`,
+		`query { orders(where: { items: { _size: {} } }) { id } }`,
+	}
+	for _, gql := range invalid {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+			t.Errorf("expected an error: %s", gql)
+		}
+	}
+}
+
+func TestMongoDBPolymorphicNestedLookups(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "users", Name: "name", Type: "text"},
+		{Schema: "public", Table: "posts", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "posts", Name: "title", Type: "text"},
+		{Schema: "public", Table: "posts", Name: "author_id", Type: "bigint", FKeySchema: "public", FKeyTable: "users", FKeyCol: "id"},
+		{Schema: "public", Table: "comments", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "comments", Name: "body", Type: "text"},
+		{Schema: "public", Table: "comments", Name: "post_id", Type: "bigint", FKeySchema: "public", FKeyTable: "posts", FKeyCol: "id"},
+		{Schema: "public", Table: "notifications", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "notifications", Name: "subject_type", Type: "text"},
+		{Schema: "public", Table: "notifications", Name: "subject_id", Type: "bigint"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+	di.VTables = []sdata.VirtualTable{{
+		Name:       "subject",
+		IDColumn:   "subject_id",
+		TypeColumn: "subject_type",
+		FKeyColumn: "id",
+	}}
+
+	out := compileMongoSchema(t, di, `query {
+		notifications {
+			id
+			subject {
+				...on posts { id title author { name } }
+				...on comments { body post { title author { name } } }
+			}
+		}
+	}`, nil)
+
+	author := `{"$lookup":{"from":"users","let":{"joinValue":"$author_id"},"pipeline":[` +
+		`{"$match":{"$expr":{"$eq":["$_id","$$joinValue"]}}},{"$project":{"_id":0,"name":"$name"}},` +
+		`{"$sort_ordered":[["_id",1]]},{"$limit":20}],"as":"author"}}`
+	authorField := `"author":{"$ifNull":[{"$arrayElemAt":["$author",0]},null]}`
+
+	// the author of a post is looked up within the member pipeline
+	exp := `{"$lookup":{"from":"posts","let":{"typeVal":"$subject_type","idVal":"$subject_id"},"pipeline":[` +
+		`{"$match":{"$expr":{"$and":[{"$eq":["$$typeVal","posts"]},{"$eq":["$_id","$$idVal"]}]}}},` +
+		author + `,{"$project":{"_id":1,"title":1,` + authorField + `}}],"as":"__poly_posts"}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected %s: %s", exp, out)
+	}
+
+	// the post of a comment and its author are looked up two levels deep
+	exp = `{"$lookup":{"from":"comments","let":{"typeVal":"$subject_type","idVal":"$subject_id"},"pipeline":[` +
+		`{"$match":{"$expr":{"$and":[{"$eq":["$$typeVal","comments"]},{"$eq":["$_id","$$idVal"]}]}}},` +
+		`{"$lookup":{"from":"posts","let":{"joinValue":"$post_id"},"pipeline":[` +
+		`{"$match":{"$expr":{"$eq":["$_id","$$joinValue"]}}},` + author +
+		`,{"$project":{"_id":0,"title":"$title",` + authorField + `}},{"$sort_ordered":[["_id",1]]},{"$limit":20}],"as":"post"}},` +
+		`{"$project":{"_id":0,"body":1,"post":{"$ifNull":[{"$arrayElemAt":["$post",0]},null]}}}],"as":"__poly_comments"}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected %s: %s", exp, out)
+	}
+}
+
+func TestMongoDBSoftDelete(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "users", Name: "full_name", Type: "text"},
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "name", Type: "text"},
+		{Schema: "public", Table: "products", Name: "deleted_at", Type: "timestamp"},
+		{Schema: "public", Table: "products", Name: "owner_id", Type: "bigint", FKeySchema: "public", FKeyTable: "users", FKeyCol: "id"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{
+		DBSchema: schema.DBSchema(),
+		TConfig:  map[string]qcode.TConfig{"publicproducts": {SoftDeleteColumn: "deleted_at"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compile := func(gql string) string {
+		t.Helper()
+		qc, err := co.Compile([]byte(gql), nil, "admin", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, b, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	// the delete sets the column, documents already deleted are not matched
+	out := compile(`mutation { products(id: 1, delete: true) { id } }`)
+	if !strings.Contains(out, `"filter":{"$and":[{"deleted_at":null},{"_id":1}]},"soft_delete":"deleted_at"`) {
+		t.Fatalf("expected a soft delete: %s", out)
+	}
+
+	out = compile(`query { products { id } }`)
+	if !strings.Contains(out, `{"$match":{"deleted_at":null}}`) {
+		t.Fatalf("expected the soft deleted documents to be filtered out: %s", out)
+	}
+
+	out = compile(`query { users { id products { id } } }`)
+	if !strings.Contains(out, `"pipeline":[{"$match":{"$expr":{"$eq":["$owner_id","$$joinValue"]}}},{"$match":{"deleted_at":null}}`) {
+		t.Fatalf("expected the soft deleted related documents to be filtered out: %s", out)
+	}
+
+	out = compile(`query { products(with_deleted: true) { id } }`)
+	if strings.Contains(out, `deleted_at`) {
+		t.Fatalf("expected the soft deleted documents to be included: %s", out)
+	}
+
+	// restore
+	out = compile(`mutation { products(id: 1, with_deleted: true, update: { deleted_at: null }) { id } }`)
+	if !strings.Contains(out, `"filter":{"_id":1},"update":{"$set":{"deleted_at":null}}`) {
+		t.Fatalf("expected the soft-delete column to be cleared: %s", out)
+	}
+
+	if _, err := co.Compile([]byte(`query { users(with_deleted: true) { id } }`), nil, "admin", ""); err == nil {
+		t.Fatal("expected an error for with_deleted on a table without a soft-delete column")
+	}
+}
+
+func TestMongoDBSearchRank(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "name", Type: "text", FullText: true},
+		{Schema: "public", Table: "products", Name: "price", Type: "numeric"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	// the $text search is matched first and the text score is added for
+	// the rank to be sorted on and projected
+	out := compileMongoSchema(t, di, `query {
+		products(search: $q, where: { price: { gt: 10 } }, order_by: { search_rank: desc, id: asc }) {
+			id
+			rank: search_rank
+		}
+	}`, nil)
+	exp := `"pipeline":[{"$match":{"$text":{"$search":"$1"}}},` +
+		`{"$addFields":{"__search_rank":{"$meta":"textScore"}}},` +
+		`{"$match":{"price":{"$gt":10}}},` +
+		`{"$sort_ordered":[["__search_rank",-1],["_id",1]]},{"$limit":20},` +
+		`{"$project":{"_id":1,"rank":"$__search_rank"}},{"$project":{"__search_rank":0}}]`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// no text score without the rank
+	out = compileMongoSchema(t, di, `query { products(search: $q) { id } }`, nil)
+	if strings.Contains(out, `textScore`) {
+		t.Fatalf("expected no text score: %s", out)
+	}
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gql := range []string{
+		`query { products(order_by: { search_rank: desc }) { id } }`,
+		`query { products(search: $q, first: 10, order_by: { search_rank: desc }) { id } }`,
+	} {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+			t.Errorf("expected an error for: %s", gql)
+		}
+	}
+}
+
+func TestMongoDBOrderByRelated(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "users", Name: "name", Type: "text"},
+		{Schema: "public", Table: "categories", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "categories", Name: "rank", Type: "bigint"},
+		{Schema: "public", Table: "posts", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "posts", Name: "title", Type: "text"},
+		{Schema: "public", Table: "posts", Name: "user_id", Type: "bigint", FKeySchema: "public", FKeyTable: "users", FKeyCol: "id"},
+		{Schema: "public", Table: "posts", Name: "category_id", Type: "bigint", FKeySchema: "public", FKeyTable: "categories", FKeyCol: "id"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	// the category is looked up and sorted on before the $project that
+	// leaves it out of the nested posts
+	out := compileMongoSchema(t, di, `query {
+		users {
+			id
+			posts(order_by: { categories: { rank: desc } }) { id title }
+		}
+	}`, nil)
+	exp := `{"$match":{"$expr":{"$eq":["$user_id","$$joinValue"]}}},` +
+		`{"$lookup":{"from":"categories","localField":"category_id","foreignField":"_id","as":"__ob_categories"}},` +
+		`{"$addFields":{"__ob_categories":{"$arrayElemAt":["$__ob_categories",0]}}},` +
+		`{"$sort_ordered":[["__ob_categories.rank",-1],["_id",1]]},` +
+		`{"$project":{"_id":"$_id","title":"$title"}},{"$limit":20},{"$project":{"__ob_categories":0}}]`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// the same ordering at the root
+	out = compileMongoSchema(t, di, `query {
+		posts(order_by: { categories: { rank: asc } }) { id title }
+	}`, nil)
+	exp = `"pipeline":[` +
+		`{"$lookup":{"from":"categories","localField":"category_id","foreignField":"_id","as":"__ob_categories"}},` +
+		`{"$addFields":{"__ob_categories":{"$arrayElemAt":["$__ob_categories",0]}}},` +
+		`{"$sort_ordered":[["__ob_categories.rank",1],["_id",1]]},{"$limit":20},` +
+		`{"$project":{"_id":1,"title":1}},{"$project":{"__ob_categories":0}}]`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// descending at the root through the singular owner of the post
+	out = compileMongoSchema(t, di, `query {
+		posts(order_by: { users: { name: desc } }) { id title }
+	}`, nil)
+	exp = `"pipeline":[` +
+		`{"$lookup":{"from":"users","localField":"user_id","foreignField":"_id","as":"__ob_users"}},` +
+		`{"$addFields":{"__ob_users":{"$arrayElemAt":["$__ob_users",0]}}},` +
+		`{"$sort_ordered":[["__ob_users.name",-1],["_id",1]]},{"$limit":20},` +
+		`{"$project":{"_id":1,"title":1}},{"$project":{"__ob_users":0}}]`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// the cursor keeps the value of the related field and the driver
+	// seeks on the looked up document
+	out = compileMongoSchema(t, di, `query {
+		posts(first: 5, after: $cursor, order_by: { users: { name: desc } }) { id title }
+	}`, nil)
+	for _, exp := range []string{
+		`"__cursor___ob_users_name":"$__ob_users.name"`,
+		`{"col":"__ob_users_name","field":"__ob_users.name","order":"desc"}`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+		}
+	}
+}
+
+func TestMongoDBSortTieBreak(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "sku", Type: "text", UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "price", Type: "numeric"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	// _id is added as the last sort key of an ordering that is not unique
+	out := compileMongoSchema(t, di, `query {
+		products(order_by: { price: desc }) { id price }
+	}`, nil)
+	if exp := `{"$sort_ordered":[["price",-1],["_id",1]]}`; !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// but not to an ordering that already has a unique column
+	for gql, exp := range map[string]string{
+		`query { products(order_by: { price: desc, id: desc }) { id } }`: `{"$sort_ordered":[["price",-1],["_id",-1]]}`,
+		`query { products(order_by: { sku: asc }) { id } }`:              `{"$sort_ordered":[["sku",1]]}`,
+	} {
+		if out := compileMongoSchema(t, di, gql, nil); !strings.Contains(out, exp) {
+			t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+		}
+	}
+
+	// nor when it's disabled
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	qc, err := co.Compile([]byte(`query { products(order_by: { price: desc }) { id } }`), nil, "admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, b, err := psql.NewCompiler(psql.Config{DBType: "mongodb", DisableSortTieBreak: true}).CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"$sort_ordered":[["price",-1]]}`; !strings.Contains(string(b), exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, b)
+	}
+}
+
+func TestMongoDBRelationshipDefault(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "profiles", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "profiles", Name: "bio", Type: "text"},
+		{Schema: "public", Table: "profiles", Name: "avatar", Type: "text"},
+		{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "users", Name: "name", Type: "text"},
+		{Schema: "public", Table: "users", Name: "profile_id", Type: "bigint", FKeySchema: "public", FKeyTable: "profiles", FKeyCol: "id"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := map[string]json.RawMessage{"bio": json.RawMessage(`"No bio yet"`), "avatar": json.RawMessage(`"default.png"`)}
+	co, err := qcode.NewCompiler(schema, qcode.Config{
+		DBSchema: schema.DBSchema(),
+		TConfig:  map[string]qcode.TConfig{"publicprofiles": {Default: def}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compile := func(gql string) string {
+		t.Helper()
+		qc, err := co.Compile([]byte(gql), nil, "admin", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, b, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	// a user without a profile gets the default with the selected fields
+	out := compile(`query { users { id profiles { id bio } } }`)
+	exp := `"profiles":{"$ifNull":[{"$arrayElemAt":["$profiles",0]},{"$literal":{"_id":null,"bio":"No bio yet"}}]}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// the default is not used for plural relationships
+	out = compile(`query { profiles { id users { id } } }`)
+	if strings.Contains(out, `$literal`) {
+		t.Fatalf("expected no default for a plural relationship: %s", out)
+	}
+
+	// nor when the table is selected at the root
+	out = compile(`query { profiles(id: 1) { id bio } }`)
+	if strings.Contains(out, `$literal`) {
+		t.Fatalf("expected no default at the root: %s", out)
+	}
+
+	// the default can only have columns of the table
+	co, err = qcode.NewCompiler(schema, qcode.Config{
+		DBSchema: schema.DBSchema(),
+		TConfig:  map[string]qcode.TConfig{"publicprofiles": {Default: map[string]json.RawMessage{"nope": json.RawMessage(`1`)}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := co.Compile([]byte(`query { users { id profiles { id } } }`), nil, "admin", ""); err == nil {
+		t.Fatal("expected an error for a default with an unknown column")
+	}
+}
+
+func TestMongoDBConnection(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "name", Type: "text"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	// the $limit is left to the driver to extend
+	out := compileMongoSchema(t, di, `query {
+		products(first: 2, after: $cursor) @connection { id name }
+	}`, nil)
+	for _, exp := range []string{`{"$limit":2}`, `"cursor_info":{`, `,"connection":true`} {
+		if !strings.Contains(out, exp) {
+			t.Fatalf("expected %s in:\n%s", exp, out)
+		}
+	}
+}
+
+func TestMongoDBObjectIDs(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "users", Name: "id", Type: "text", NotNull: true, PrimaryKey: true, UniqueKey: true, ObjectID: true},
+		{Schema: "public", Table: "users", Name: "full_name", Type: "text"},
+		{Schema: "public", Table: "products", Name: "id", Type: "text", NotNull: true, PrimaryKey: true, UniqueKey: true, ObjectID: true},
+		{Schema: "public", Table: "products", Name: "name", Type: "text"},
+		{Schema: "public", Table: "products", Name: "owner_id", Type: "text", FKeySchema: "public", FKeyTable: "users", FKeyCol: "id", ObjectID: true},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	id := json.RawMessage(`"65a8b3c0d1e2f3a4b5c6d7e8"`)
+
+	tests := []struct {
+		name string
+		gql  string
+		vars map[string]json.RawMessage
+		exp  string
+	}{
+		{
+			"filter",
+			`query { products(where: { id: { eq: $id } }) { id } }`,
+			map[string]json.RawMessage{"id": id},
+			`{"$match":{"_id":{"$oid":"$1"}}}`,
+		},
+		{
+			"filter on a list",
+			`query { products(where: { owner_id: { in: ["65a8b3c0d1e2f3a4b5c6d7e8"] } }) { id } }`,
+			nil,
+			`{"$match":{"owner_id":{"$in":{"$oid":["65a8b3c0d1e2f3a4b5c6d7e8"]}}}}`,
+		},
+		{
+			"filter on a string",
+			`query { products(where: { id: { eq: "65a8b3c0d1e2f3a4b5c6d7e8" } }) { id } }`,
+			nil,
+			`{"$match":{"_id":{"$oid":"65a8b3c0d1e2f3a4b5c6d7e8"}}}`,
+		},
+		{
+			"filter on not equals",
+			`query { products(where: { id: { neq: $id } }) { id } }`,
+			map[string]json.RawMessage{"id": id},
+			`{"$match":{"_id":{"$ne":{"$oid":"$1"}}}}`,
+		},
+		{
+			"filter on not in a variable",
+			`query { products(where: { id: { nin: $ids } }) { id } }`,
+			map[string]json.RawMessage{"ids": json.RawMessage(`["65a8b3c0d1e2f3a4b5c6d7e8"]`)},
+			`{"$match":{"_id":{"$nin":{"$oid":"$1"}}}}`,
+		},
+		{
+			"filter on a relationship",
+			`query { products(where: { owner: { id: { eq: $id } } }) { id } }`,
+			map[string]json.RawMessage{"id": id},
+			`{"$match":{"owner_id":{"$oid":"$1"}}}`,
+		},
+		{
+			"insert",
+			`mutation { products(insert: { id: $id, name: "Apple" }) { id } }`,
+			map[string]json.RawMessage{"id": id},
+			`"document":{"_id":{"$oid":"$1"},"name":"Apple"}`,
+		},
+		{
+			"insert with a connect",
+			`mutation { products(insert: $data) { id } }`,
+			map[string]json.RawMessage{"data": json.RawMessage(`{"name":"Apple","owner":{"connect":{"id":"65a8b3c0d1e2f3a4b5c6d7e8"}}}`)},
+			`"raw_document":"$1","object_ids":["_id","owner_id"],"fk_connect":{"path":"owner","column":"owner_id"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := compileMongoSchema(t, di, tt.gql, tt.vars)
+			if !strings.Contains(out, tt.exp) {
+				t.Fatalf("expected %s in: %s", tt.exp, out)
+			}
+		})
+	}
+
+	// the filter applied after a recursive traversal compares ObjectIds too
+	rdi := sdata.NewDBInfo("mongodb", 0, "public", "db", []sdata.DBColumn{
+		{Schema: "public", Table: "comments", Name: "id", Type: "text", NotNull: true, PrimaryKey: true, UniqueKey: true, ObjectID: true},
+		{Schema: "public", Table: "comments", Name: "reply_to_id", Type: "text", FKeySchema: "public", FKeyTable: "comments", FKeyCol: "id", FKRecursive: true, ObjectID: true},
+	}, nil, nil)
+	out := compileMongoSchema(t, rdi, `query {
+		comments(id: $id) {
+			id
+			replies: comments(find: "children", where: { and: [{ id: { neq: $id } }, { id: { in: ["65a8b3c0d1e2f3a4b5c6d7e9"] } }] }) { id }
+		}
+	}`, map[string]json.RawMessage{"id": id})
+	for _, exp := range []string{
+		`{"$ne":["$$item._id",{"$oid":"$1"}]}`,
+		`{"$in":["$$item._id",{"$oid":["65a8b3c0d1e2f3a4b5c6d7e9"]}]}`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Fatalf("expected %s in: %s", exp, out)
+		}
+	}
+
+	// ids of collections without ObjectIds are left as they are
+	cols[0].ObjectID = false
+	di = sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	out = compileMongoSchema(t, di, `query { users(where: { id: { eq: $id } }) { id } }`,
+		map[string]json.RawMessage{"id": id})
+	if !strings.Contains(out, `{"$match":{"_id":"$1"}}`) {
+		t.Fatalf("expected the id without a $oid marker: %s", out)
+	}
+}
+
+func TestMongoDBGetOrCreate(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "name", Type: "text"},
+		{Schema: "public", Table: "products", Name: "price", Type: "numeric"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	vars := map[string]json.RawMessage{"name": json.RawMessage(`"Apple"`)}
+
+	out := compileMongoSchema(t, di, `mutation {
+		products(upsert: { name: $name, price: 1 }, where: { name: { eq: $name } }) @getOrCreate { id name price }
+	}`, vars)
+
+	// the order of the fields is not fixed
+	for _, exp := range []string{
+		`"filter":{"name":"$1"},"update":{"$setOnInsert":{`,
+		`"name":"$1"`, `"price":1`, `}},"options":{"upsert":true}`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Fatalf("expected the fields to be set on insert only: %s", out)
+		}
+	}
+	if !strings.Contains(out, `"return_pipeline":[{"$project":{"_id":1,"name":1,"price":1}}]`) {
+		t.Fatalf("expected the document to be returned: %s", out)
+	}
+
+	// a plain upsert sets the fields of the matching document
+	out = compileMongoSchema(t, di, `mutation {
+		products(upsert: { name: $name, price: 1 }, where: { name: { eq: $name } }) { id }
+	}`, vars)
+
+	if !strings.Contains(out, `"update":{"$set":{`) || !strings.Contains(out, `"price":1`) {
+		t.Fatalf("expected the fields to be set: %s", out)
+	}
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = co.Compile([]byte(`mutation { products(insert: { name: $name }) @getOrCreate { id } }`), vars, "admin", "")
+	if err == nil {
+		t.Fatal("expected an error for @getOrCreate on an insert")
+	}
+}
+
+func TestMongoDBReturnBefore(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "users", Name: "email", Type: "text"},
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "price", Type: "numeric"},
+		{Schema: "public", Table: "products", Name: "owner_id", Type: "bigint", FKeySchema: "public", FKeyTable: "users", FKeyCol: "id"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	vars := map[string]json.RawMessage{"id": json.RawMessage(`1`), "price": json.RawMessage(`20`)}
+
+	// the previous document is returned with the selected fields
+	out := compileMongoSchema(t, di, `mutation {
+		products(id: $id, update: { price: $price }) @returnBefore { id price owner { email } }
+	}`, vars)
+	for _, exp := range []string{
+		`{"operation":"findOneAndUpdate","collection":"products","filter":{"_id":"$1"},"update":{"$set":{"price":"$2"}}`,
+		`"return_pipeline":[{"$lookup":{"from":"users"`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+		}
+	}
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gql := range []string{
+		`mutation { products(insert: { price: $price }) @returnBefore { id } }`,
+		`mutation { products(id: $id, update: { price: $price, owner: { email: "a" } }) @returnBefore { id } }`,
+		`mutation { products(where: { price: { gt: 1 } }, update: $list) @returnBefore { id } }`,
+	} {
+		v := map[string]json.RawMessage{"id": json.RawMessage(`1`), "price": json.RawMessage(`20`),
+			"list": json.RawMessage(`[{"price": 1}, {"price": 2}]`)}
+		if _, err := co.Compile([]byte(gql), v, "admin", ""); err == nil {
+			t.Errorf("expected an error for: %s", gql)
+		}
+	}
+}
+
+func TestMongoDBLookupWithoutFields(t *testing.T) {
+	// products selects no fields of its own, only the user is projected
+	out := compileForDialect(t, "mongodb", `query {
+		users {
+			id
+			products {
+				user {
+					id
+				}
+			}
+		}
+	}`, nil, "admin")
+
+	exp := `{"$project":{"_id":0,"user":{"$ifNull":[{"$arrayElemAt":["$user",0]},null]}}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected %s: %s", exp, out)
+	}
+
+	// the user needs a signed in user and is null for anon
+	out = compileForDialect(t, "mongodb", `query {
+		users {
+			id
+			products {
+				user(where: { id: { eq: $user_id } }) {
+					id
+				}
+			}
+		}
+	}`, nil, "anon")
+
+	exp = `{"$project":{"_id":0,"user":null}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected %s: %s", exp, out)
+	}
+}
+
+func TestMongoDBArrayRegex(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "posts", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "posts", Name: "title", Type: "text"},
+		{Schema: "public", Table: "posts", Name: "tags", Type: "text[]", Array: true},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	tests := []struct {
+		where string
+		match string
+	}{
+		// any element of the array matches, "Prod-A" and "prod-b" for ilike
+		{`{ tags: { ilike: "prod%" } }`, `{"tags":{"$elemMatch":{"$regex":"prod.*","$options":"i"}}}`},
+		{`{ tags: { like: "Prod%" } }`, `{"tags":{"$elemMatch":{"$regex":"Prod.*"}}}`},
+		{`{ tags: { iregex: "^prod" } }`, `{"tags":{"$elemMatch":{"$regex":"^prod","$options":"i"}}}`},
+		{`{ tags: { regex: "^Prod" } }`, `{"tags":{"$elemMatch":{"$regex":"^Prod"}}}`},
+		// none of the elements matches
+		{`{ tags: { nilike: "prod%" } }`, `{"tags":{"$not":{"$elemMatch":{"$regex":"prod.*","$options":"i"}}}}`},
+		{`{ tags: { nlike: "Prod%" } }`, `{"tags":{"$not":{"$elemMatch":{"$regex":"Prod.*"}}}}`},
+		// scalar columns are matched directly
+		{`{ title: { ilike: "prod%" } }`, `{"title":{"$regex":"prod.*","$options":"i"}}`},
+	}
+
+	for _, tt := range tests {
+		out := compileMongoSchema(t, di, `query { posts(where: `+tt.where+`) { id } }`, nil)
+		if exp := `{"$match":` + tt.match + `}`; !strings.Contains(out, exp) {
+			t.Fatalf("%s: expected %s: %s", tt.where, exp, out)
+		}
+	}
+}
+
+func TestMongoDBCompareField(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "price", Type: "numeric"},
+		{Schema: "public", Table: "products", Name: "cost", Type: "numeric"},
+		{Schema: "public", Table: "products", Name: "owner_id", Type: "bigint", FKeySchema: "public", FKeyTable: "users", FKeyCol: "id"},
+		{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{
+		DBSchema: schema.DBSchema(),
+		TConfig: map[string]qcode.TConfig{"publicproducts": {Compare: map[string]qcode.TCompare{
+			"is_expensive": {Col: "price", Op: "gt", Val: json.RawMessage(`100`)},
+			"at_loss":      {Col: "price", Op: "lt", ValCol: "cost"},
+		}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compile := func(gql string, vars map[string]json.RawMessage) string {
+		t.Helper()
+		qc, err := co.Compile([]byte(gql), vars, "admin", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, b, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	isExpensive := `{"$cond":[{"$eq":[{"$ifNull":["$price",null]},null]},null,{"$gt":["$price",{"$literal":100}]}]}`
+
+	// the comparison is projected under the field name
+	out := compile(`query { products { id is_expensive } }`, nil)
+	if exp := `"is_expensive":` + isExpensive; !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// a column is compared with another column
+	out = compile(`query { products { id at_loss } }`, nil)
+	exp := `"at_loss":{"$cond":[{"$or":[{"$eq":[{"$ifNull":["$price",null]},null]},` +
+		`{"$eq":[{"$ifNull":["$cost",null]},null]}]},null,{"$lt":["$price","$cost"]}]}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// variable @include wraps the comparison in its own $cond
+	out = compile(`query { products { id is_expensive @include(ifVar: $show) } }`,
+		map[string]json.RawMessage{"show": json.RawMessage(`true`)})
+	if exp := `,"then":` + isExpensive + `,"else":null}}`; !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// and in the projection of a lookup
+	out = compile(`query { users { id products { id is_expensive } } }`, nil)
+	if exp := `"is_expensive":` + isExpensive; !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+}
+
+func TestMongoDBConditionalLookup(t *testing.T) {
+	gql := `query {
+		users {
+			id
+			products @include(ifVar: $withProducts) {
+				id
+				user @skip(ifVar: $noOwner) {
+					id
+				}
+			}
+		}
+	}`
+	vars := map[string]json.RawMessage{
+		"withProducts": json.RawMessage(`true`),
+		"noOwner":      json.RawMessage(`false`),
+	}
+	out := compileForDialect(t, "mongodb", gql, vars, "admin")
+
+	// the lookup pipeline starts with a match on the variable so an excluded
+	// relationship reads nothing
+	exp := `"pipeline":[{"$match":{"$expr":{"$eq":["$1",true]}}},{"$match":{"$expr":{"$eq":["$user_id","$$joinValue"]}}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+	exp = `"pipeline":[{"$match":{"$expr":{"$ne":["$2",true]}}},{"$match":{"$expr":{"$eq":["$_id","$$joinValue"]}}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// and the relationship is null when excluded
+	exp = `"products":{"$cond":{"if":{"$eq":["$1",true]},"then":{"$ifNull":["$products",[]]},"else":null}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+	exp = `"user":{"$cond":{"if":{"$ne":["$2",true]},"then":{"$ifNull":[{"$arrayElemAt":["$user",0]},null]},"else":null}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+}
+
+func TestMongoDBRelationshipExists(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "users", Name: "full_name", Type: "text"},
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "owner_id", Type: "bigint", FKeySchema: "public", FKeyTable: "users", FKeyCol: "id"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	// a plural relationship without fields is looked up for a single match
+	out := compileMongoSchema(t, di, `query { users { id has_products: products @exists } }`, nil)
+	exp := `"pipeline":[{"$match":{"$expr":{"$eq":["$owner_id","$$joinValue"]}}},{"$project":{"_id":1}},{"$sort_ordered":[["_id",1]]},{"$limit":1}],"as":"has_products"}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+	// and its size is projected as a boolean instead of the array
+	exp = `"has_products":{"$gt":[{"$size":{"$ifNull":["$has_products",[]]}},0]}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// a singular relationship in a lookup
+	out = compileMongoSchema(t, di, `query { users { id products { id has_owner: owner @exists { id } } } }`, nil)
+	exp = `"has_owner":{"$gt":[{"$size":{"$ifNull":["$has_owner",[]]}},0]}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gql := range []string{
+		`query { users @exists { id } }`,
+		`query { products { id owner @exists @flatten { id } } }`,
+		`query { users { id products @exists(limit: 1) } }`,
+	} {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+			t.Errorf("expected an error for: %s", gql)
+		}
+	}
+}
+
+func TestMongoDBLookupArraySide(t *testing.T) {
+	newDI := func(array bool, side string) *sdata.DBInfo {
+		cols := []sdata.DBColumn{
+			{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+			{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+			{Schema: "public", Table: "products", Name: "owner_id", Type: "bigint", Array: array,
+				FKeySchema: "public", FKeyTable: "users", FKeyCol: "id", FKArraySide: side},
+		}
+		return sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+	}
+
+	// the array side is taken from the config when it's not inferred
+	di := newDI(false, "column")
+	out := compileMongoSchema(t, di, `query { products { id owner { id } } }`, nil)
+	if exp := `"$in":["$_id","$$joinValue"]`; !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+	out = compileMongoSchema(t, di, `query { users { id products { id } } }`, nil)
+	if exp := `"$in":["$$joinValue","$owner_id"]`; !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// and an array column can be joined as a scalar
+	out = compileMongoSchema(t, newDI(true, "none"), `query { products { id owner { id } } }`, nil)
+	if exp := `"$eq":["$_id","$$joinValue"]`; !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+}
+
+func TestMongoDBRecursiveRestrictSearch(t *testing.T) {
+	gql := `query {
+		comments(id: 50) {
+			id
+			replies: comments(find: "children", where: { and: [{ body: { iregex: $body } }, { id: { gt: 10 } }] }) {
+				id
+				body
+			}
+		}
+	}`
+	vars := map[string]json.RawMessage{"body": json.RawMessage(`"ok"`)}
+	out := compileForDialect(t, "mongodb", gql, vars, "admin")
+
+	// the where clause prunes the traversal and its variables are parameters
+	exp := `"restrictSearchWithMatch":{"$and":[{"_id":{"$gt":10}},{"body":{"$regex":"$1","$options":"i"}}]}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// the filter after the traversal is kept
+	if !strings.Contains(out, `"$gt":["$$item._id",10]`) {
+		t.Fatalf("expected the filter after the traversal: %s", out)
+	}
+
+	// with no where clause there is nothing to restrict
+	gql = `query { comments(id: 50) { id replies: comments(find: "children") { id } } }`
+	out = compileForDialect(t, "mongodb", gql, nil, "admin")
+	if strings.Contains(out, `restrictSearchWithMatch`) {
+		t.Fatalf("expected no restrictSearchWithMatch: %s", out)
+	}
+}
+
+func TestMongoDBJSONColumnPaths(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "users", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "users", Name: "metadata", Type: "jsonb"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	out := compileMongoSchema(t, di,
+		`query { users { id metadata { theme lang: language prefs { font } } } }`, nil)
+
+	exp := `"metadata":{"$cond":[{"$eq":[{"$type":"$metadata"},"object"]},{` +
+		`"theme":{"$ifNull":["$metadata.theme",null]},` +
+		`"lang":{"$ifNull":["$metadata.language",null]},` +
+		`"prefs":{"$cond":[{"$eq":[{"$type":"$metadata.prefs"},"object"]},{"font":{"$ifNull":["$metadata.prefs.font",null]}},null]}` +
+		`},null]}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// without a sub-selection the whole column is returned
+	out = compileMongoSchema(t, di, `query { users { id metadata } }`, nil)
+	if !strings.Contains(out, `"metadata":1`) {
+		t.Fatalf("expected the whole column, got:\n%s", out)
+	}
+}
+
+func TestMongoDBFieldCondition(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "tickets", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "tickets", Name: "status", Type: "text"},
+		{Schema: "public", Table: "tickets", Name: "priority", Type: "bigint"},
+		{Schema: "public", Table: "tickets", Name: "internal_notes", Type: "text"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	// the field is null unless the status of the ticket is closed
+	out := compileMongoSchema(t, di, `query {
+		tickets { id internal_notes(includeIf: { status: { eq: "closed" } }) }
+	}`, nil)
+	exp := `"internal_notes":{"$cond":{"if":{"$eq":["$status",{"$literal":"closed"}]},"then":"$internal_notes","else":null}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// combinations of conditions on sibling columns and variables
+	out = compileMongoSchema(t, di, `query {
+		tickets {
+			id
+			internal_notes(includeIf: { or: [
+				{ status: { eq: "closed" } },
+				{ and: [{ priority: { gt: $p } }, { status: { in: ["open", "new"] } }] }
+			] }) @include(ifVar: $show)
+		}
+	}`, nil)
+	exp = `"internal_notes":{"$cond":{"if":{"$and":[{"$or":[` +
+		`{"$and":[{"$in":["$status",["open","new"]]},{"$gt":["$priority",{"$literal":"$1"}]}]},` +
+		`{"$eq":["$status",{"$literal":"closed"}]}]},{"$eq":["$2",true]}]},` +
+		`"then":"$internal_notes","else":null}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	// a missing status is null
+	out = compileMongoSchema(t, di, `query {
+		tickets { id internal_notes(skipIf: { status: { is_null: true } }) }
+	}`, nil)
+	exp = `"if":{"$not":[{"$eq":[{"$ifNull":["$status",null]},null]}]}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+	}
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gql := `query { tickets { id internal_notes(includeIf: { status: { like: "clos%" } }) } }`
+	if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+		t.Fatal("expected an error for an unsupported operator")
+	}
+}
+
+func TestMongoDBInsertDefaults(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "orders", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "orders", Name: "status", Type: "text", Default: `"pending"`},
+		{Schema: "public", Table: "orders", Name: "qty", Type: "int", Default: `1`},
+		{Schema: "public", Table: "orders", Name: "note", Type: "text", Default: `now()`},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	tests := []struct {
+		name string
+		gql  string
+		vars map[string]json.RawMessage
+		exp  []string
+	}{
+		{
+			"omitted fields are set to the default",
+			`mutation { orders(insert: { id: 1, qty: 5 }) { id } }`, nil,
+			[]string{`"qty":5`, `"status":"pending"`},
+		},
+		{
+			"an explicit null is kept",
+			`mutation { orders(insert: { id: 1, status: null }) { id } }`, nil,
+			[]string{`"status":null`, `"qty":1`},
+		},
+		{
+			"the driver sets the defaults of a variable",
+			`mutation { orders(insert: $data) { id } }`,
+			map[string]json.RawMessage{"data": json.RawMessage(`{"id": 1}`)},
+			[]string{`"raw_document":"$1","defaults":{"status":"pending","qty":1}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := compileMongoSchema(t, di, tt.gql, tt.vars)
+			for _, exp := range tt.exp {
+				if !strings.Contains(out, exp) {
+					t.Fatalf("expected:\n%s\ngot:\n%s", exp, out)
+				}
+			}
+			// a default that isn't a json value is not set
+			if strings.Contains(out, "now()") {
+				t.Fatalf("unexpected default:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestMongoDBHelperFieldCleanup(t *testing.T) {
+	// the distance added by $geoNear is excluded at the end of the pipeline
+	out := compileForDialect(t, "mongodb", `query {
+		locations(where: { geom: { st_dwithin: { point: [-122.4194, 37.7749], distance: 1000 } } }) { id name }
+	}`, nil, "user")
+	if !strings.Contains(out, `"distanceField":"__geo_dist"`) ||
+		!strings.HasSuffix(out, `{"$project":{"_id":1,"name":1}},{"$project":{"__geo_dist":0}}]}`) {
+		t.Fatalf("expected the geo distance to be excluded:\n%s", out)
+	}
+
+	// the positions of a list ordering are excluded too
+	out = compileForDialect(t, "mongodb", `query {
+		products(order_by: { id: [$list, "asc"] }) { id }
+	}`, map[string]json.RawMessage{"list": json.RawMessage(`[3, 1, 2]`)}, "user")
+	if !strings.Contains(out, `{"$project":{"__sort_pos_id":0}}]`) {
+		t.Fatalf("expected the sort positions to be excluded:\n%s", out)
+	}
+
+	// the depth of recursive lookups is on the looked up elements, which are
+	// replaced with the requested fields
+	out = compileForDialect(t, "mongodb", `query {
+		comments(id: 50) {
+			id
+			replies: comments(find: "children") { id body }
+		}
+	}`, nil, "user")
+	if !strings.Contains(out, `"depthField":"__depth"`) ||
+		!strings.Contains(out, `"in":{"id":"$$elem._id","body":"$$elem.body"}`) {
+		t.Fatalf("expected the recursive elements to be projected:\n%s", out)
+	}
+
+	// pipelines without helper fields have no cleanup stage
+	out = compileForDialect(t, "mongodb", `query { products { id } }`, nil, "user")
+	if strings.Contains(out, `":0}}]`) {
+		t.Fatalf("unexpected cleanup stage:\n%s", out)
+	}
+}
+
+func TestMongoDBGeoDistanceField(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "stores", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "stores", Name: "name", Type: "text"},
+		{Schema: "public", Table: "stores", Name: "location", Type: "geometry"},
+		{Schema: "public", Table: "branches", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "branches", Name: "store_id", Type: "bigint", FKeySchema: "public", FKeyTable: "stores", FKeyCol: "id"},
+		{Schema: "public", Table: "branches", Name: "location", Type: "geometry"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	// the distance is converted back from meters to the unit of the filter
+	out := compileMongoSchema(t, di, `query {
+		stores(where: { location: { st_dwithin: { point: [-122.4194, 37.7749], distance: 5, unit: "miles" } } }) {
+			id
+			_distance
+		}
+	}`, nil)
+	if !strings.Contains(out, `"_distance":{"$divide":["$__geo_dist",1609.344]}`) ||
+		!strings.HasSuffix(out, `{"$project":{"__geo_dist":0}}]}`) {
+		t.Fatalf("expected the distance in miles:\n%s", out)
+	}
+
+	// distances in meters are returned as is and can be aliased
+	out = compileMongoSchema(t, di, `query {
+		stores(where: { location: { st_dwithin: { point: [-122.4194, 37.7749], distance: 1000 } } }) {
+			id
+			dist: _distance
+		}
+	}`, nil)
+	if !strings.Contains(out, `"dist":"$__geo_dist"`) {
+		t.Fatalf("expected the distance in meters:\n%s", out)
+	}
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the distance requires a distance filter at the query root
+	invalid := []string{
+		`query { stores { id _distance } }`,
+		`query { stores(where: { location: { st_dwithin: { point: [-122.4, 37.7], distance: 5 } } }) {
+			id branches { id _distance }
+		} }`,
+	}
+	for _, gql := range invalid {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil ||
+			!strings.Contains(err.Error(), "_distance") {
+			t.Fatalf("expected an error for %s, got: %v", gql, err)
+		}
+	}
+
+	// only supported on mongodb
+	gql := `query {
+		locations(where: { geom: { st_dwithin: { point: [-122.4, 37.7], distance: 5 } } }) { id _distance }
+	}`
+	if _, err := qcompile.Compile([]byte(gql), nil, "admin", ""); err == nil {
+		t.Fatal("expected an error for a sql database")
+	}
+}
+
+func TestMongoDBSortByCount(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "category", Type: "text"},
+		{Schema: "public", Table: "products", Name: "brand", Type: "text"},
+		{Schema: "public", Table: "products", Name: "price", Type: "numeric"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	// a column and its count ordered by the count use $sortByCount
+	out := compileMongoSchema(t, di, `query {
+		products(where: { price: { gt: 10 } }, order_by: { count_id: desc }, limit: 5) {
+			category
+			total: count_id
+		}
+	}`, nil)
+	exp := `{"$match":{"price":{"$gt":10}}},{"$sortByCount":"$category"},{"$limit":5},` +
+		`{"$project":{"_id":0,"category":"$_id","total":"$count"}}]`
+	if !strings.Contains(out, exp) || strings.Contains(out, `"$group"`) {
+		t.Fatalf("expected a $sortByCount stage:\n%s", out)
+	}
+
+	// other shapes use the general aggregate path
+	for _, gql := range []string{
+		`query { products(order_by: { count_id: asc }) { category count_id } }`,
+		`query { products(order_by: { count_id: desc }) { category brand count_id } }`,
+		`query { products(order_by: { count_id: desc }) { category count_id max_price } }`,
+	} {
+		out := compileMongoSchema(t, di, gql, nil)
+		if strings.Contains(out, `$sort`) {
+			t.Fatalf("expected the general aggregate path for %s:\n%s", gql, out)
+		}
+	}
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the selector must be an aggregate
+	gql := `query { products(order_by: { count_id: desc }) { id category } }`
+	if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil {
+		t.Fatal("expected an error for a selector without aggregates")
+	}
+
+	// only supported on mongodb
+	gql = `query { products(order_by: { count_id: desc }) { name count_id } }`
+	if _, err := qcompile.Compile([]byte(gql), nil, "admin", ""); err == nil {
+		t.Fatal("expected an error for a sql database")
+	}
+}
+
+func TestMongoDBRecursiveSortTieBreak(t *testing.T) {
+	// siblings with the same body keep the order of their ids
+	out := compileForDialect(t, "mongodb", `query {
+		comments(id: 50) {
+			id
+			replies: comments(find: "children", order_by: { body: asc }) { id body }
+		}
+	}`, nil, "user")
+	if !strings.Contains(out, `"sortBy":{"body":1,"_id":1}`) {
+		t.Fatalf("expected _id as the last sort key:\n%s", out)
+	}
+
+	// the closest parent stays first
+	out = compileForDialect(t, "mongodb", `query {
+		comments(id: 50) {
+			id
+			parents: comments(find: "parents", order_by: { body: desc }) { id body }
+		}
+	}`, nil, "user")
+	if !strings.Contains(out, `"sortBy":{"body":-1,"_id":-1}`) {
+		t.Fatalf("expected _id descending as the last sort key:\n%s", out)
+	}
+
+	// a unique order needs no tie break
+	out = compileForDialect(t, "mongodb", `query {
+		comments(id: 50) {
+			id
+			replies: comments(find: "children", order_by: { id: desc }) { id body }
+		}
+	}`, nil, "user")
+	if !strings.Contains(out, `"sortBy":{"_id":-1}`) {
+		t.Fatalf("expected no tie break:\n%s", out)
+	}
+}
+
+func TestMongoDBLabels(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "orders", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "orders", Name: "status", Type: "integer"},
+		{Schema: "public", Table: "orders", Name: "tags", Type: "text", Array: true},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{
+		DBSchema: schema.DBSchema(),
+		TConfig: map[string]qcode.TConfig{
+			"publicorders": {Labels: map[string]qcode.Labels{
+				"status": {
+					Cases: []qcode.Label{
+						{Val: json.RawMessage(`1`), Label: "active"},
+						{Val: json.RawMessage(`2`), Label: "closed"},
+					},
+					Default: json.RawMessage(`"unknown"`),
+				},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compile := func(gql string, vars map[string]json.RawMessage) string {
+		t.Helper()
+		qc, err := co.Compile([]byte(gql), vars, "admin", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, out, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+
+	sw := `{"$switch":{"branches":[` +
+		`{"case":{"$eq":["$status",{"$literal":1}]},"then":{"$literal":"active"}},` +
+		`{"case":{"$eq":["$status",{"$literal":2}]},"then":{"$literal":"closed"}}],` +
+		`"default":{"$literal":"unknown"}}}`
+
+	out := compile(`query { orders { id status_label: status @label } }`, nil)
+	if !strings.Contains(out, `"status_label":`+sw) {
+		t.Fatalf("expected the label of the status:\n%s", out)
+	}
+
+	// the value and its label can be selected together
+	out = compile(`query { orders { id status label: status @label } }`, nil)
+	if !strings.Contains(out, `"status":1`) || !strings.Contains(out, `"label":`+sw) {
+		t.Fatalf("expected the status and its label:\n%s", out)
+	}
+
+	// composes with a conditional field
+	out = compile(`query { orders { id status_label: status @label @include(ifVar: $show) } }`,
+		map[string]json.RawMessage{"show": json.RawMessage(`true`)})
+	if !strings.Contains(out, sw) || !strings.Contains(out, `"$cond"`) {
+		t.Fatalf("expected the label inside the condition:\n%s", out)
+	}
+
+	invalid := map[string]string{
+		`query { orders { id status_label: id @label } }`:     "no labels configured",
+		`query { orders { id tags @label } }`:                 "can only be used on a column",
+		`query { orders { id status @label(default: "x") } }`: "default",
+	}
+	for gql, msg := range invalid {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil ||
+			!strings.Contains(err.Error(), msg) {
+			t.Fatalf("expected an error containing '%s' for %s, got: %v", msg, gql, err)
+		}
+	}
+
+	// only supported on mongodb
+	if _, err := qcompile.Compile([]byte(`query { products { id price @label } }`),
+		nil, "admin", ""); err == nil {
+		t.Fatal("expected an error for a sql database")
+	}
+}
+
+func TestMongoDBLookupRoleFilter(t *testing.T) {
+	// the filters of the role on the related collection are matched in
+	// the lookup pipeline after the join
+	out := compileForDialect(t, "mongodb", `query { users { id products { id } } }`, nil, "user")
+	exp := `{"$match":{"$expr":{"$eq":["$user_id","$$joinValue"]}}},` +
+		`{"$match":{"$and":[{"price":{"$gt":0}},{"price":{"$lt":8}}]}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected the role filter in the lookup pipeline:\n%s", out)
+	}
+
+	// combined with the where of the related selector
+	out = compileForDialect(t, "mongodb",
+		`query { users { id products(where: { name: { eq: "x" } }) { id } } }`, nil, "user")
+	if !strings.Contains(out, `{"$match":{"$and":[{"$and":[{"price":{"$gt":0}},{"price":{"$lt":8}}]},{"name":"x"}]}}`) {
+		t.Fatalf("expected the role filter and the where in the lookup pipeline:\n%s", out)
+	}
+
+	// without filters only the join is matched
+	out = compileForDialect(t, "mongodb", `query { users { id products { id } } }`, nil, "admin")
+	if strings.Count(out, `"$match"`) != 1 {
+		t.Fatalf("expected only the join match:\n%s", out)
+	}
+
+	// and on many-to-many lookups through a join collection
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "tags", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "tags", Name: "name", Type: "text"},
+		{Schema: "public", Table: "product_tags", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "product_tags", Name: "product_id", Type: "bigint", FKeySchema: "public", FKeyTable: "products", FKeyCol: "id"},
+		{Schema: "public", Table: "product_tags", Name: "tag_id", Type: "bigint", FKeySchema: "public", FKeyTable: "tags", FKeyCol: "id"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+	out = compileMongoSchema(t, di,
+		`query { products { id tags(where: { name: { eq: "x" } }) { id } } }`, nil)
+	if !strings.Contains(out, `{"$replaceRoot":{"newRoot":"$_target"}},{"$match":{"name":"x"}}`) {
+		t.Fatalf("expected the where in the many-to-many lookup pipeline:\n%s", out)
+	}
+}
+
+func TestMongoDBInsertSubDocument(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "name", Type: "text"},
+		{Schema: "public", Table: "products", Name: "metadata", Type: "json"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	// the object is inserted as a sub-document with its keys in order, its
+	// id keys are kept and variables inside it are parameters
+	out := compileMongoSchema(t, di, `mutation {
+		products(insert: {
+			name: "lamp",
+			metadata: { id: 7, kind: "x", tags: [{ id: 1, v: $v }, { id: 2 }], deep: { a: { b: null } } }
+		}) { id }
+	}`, map[string]json.RawMessage{"v": json.RawMessage(`3`)})
+
+	exp := `"metadata":{"id":7,"kind":"x",` +
+		`"tags":[{"id":1,"v":"$1"},{"id":2}],"deep":{"a":{"b":null}}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected the nested sub-document:\n%s", out)
+	}
+}
+
+func TestMongoDBDateDiff(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "orders", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "orders", Name: "created_at", Type: "timestamp"},
+		{Schema: "public", Table: "orders", Name: "shipped_at", Type: "timestamp"},
+		{Schema: "public", Table: "orders", Name: "tags", Type: "text", Array: true},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	// the age in days by default, next to the date it is computed from
+	out := compileMongoSchema(t, di, `query {
+		orders { id created_at age: created_at @dateDiff }
+	}`, nil)
+	if !strings.Contains(out, `"created_at":1,"age":{"$dateDiff":{"startDate":"$created_at","endDate":"$$NOW","unit":"day"}}`) {
+		t.Fatalf("expected the age in days:\n%s", out)
+	}
+
+	// the time to another date in another unit
+	out = compileMongoSchema(t, di, `query {
+		orders { id hours_to_ship: created_at @dateDiff(unit: "hour", end: "shipped_at") }
+	}`, nil)
+	if !strings.Contains(out, `"hours_to_ship":{"$dateDiff":{"startDate":"$created_at","endDate":"$shipped_at","unit":"hour"}}`) {
+		t.Fatalf("expected the hours to the shipping date:\n%s", out)
+	}
+
+	// composes with a conditional field
+	out = compileMongoSchema(t, di, `query {
+		orders { id age: created_at @dateDiff @include(ifVar: $show) }
+	}`, map[string]json.RawMessage{"show": json.RawMessage(`true`)})
+	if !strings.Contains(out, `"$cond"`) || !strings.Contains(out, `"$dateDiff"`) {
+		t.Fatalf("expected the age inside the condition:\n%s", out)
+	}
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := map[string]string{
+		`query { orders { id created_at @dateDiff(unit: "days") } }`:      "invalid unit 'days'",
+		`query { orders { id created_at @dateDiff(end: "deleted_at") } }`: "deleted_at",
+		`query { orders { id tags @dateDiff } }`:                          "can only be used on a column",
+		`query { orders { id created_at @dateDiff(start: "x") } }`:        "unknown argument 'start'",
+	}
+	for gql, msg := range invalid {
+		if _, err := co.Compile([]byte(gql), nil, "admin", ""); err == nil ||
+			!strings.Contains(err.Error(), msg) {
+			t.Fatalf("expected an error containing '%s' for %s, got: %v", msg, gql, err)
+		}
+	}
+
+	// only supported on mongodb
+	if _, err := qcompile.Compile([]byte(`query { products { id created_at @dateDiff } }`),
+		nil, "admin", ""); err == nil {
+		t.Fatal("expected an error for a sql database")
+	}
+}
+
+func TestMongoDBM2MDuplicates(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "tags", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "tags", Name: "name", Type: "text"},
+		{Schema: "public", Table: "product_tags", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "product_tags", Name: "product_id", Type: "bigint", FKeySchema: "public", FKeyTable: "products", FKeyCol: "id"},
+		{Schema: "public", Table: "product_tags", Name: "tag_id", Type: "bigint", FKeySchema: "public", FKeyTable: "tags", FKeyCol: "id"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	// a tag linked twice to a product is looked up once from the distinct
+	// tag ids of the join rows
+	out := compileMongoSchema(t, di, `query { products { id tags { id } } }`, nil)
+	exp := `{"$match":{"$expr":{"$eq":["$product_id","$$parentId"]}}},` +
+		`{"$group":{"_id":null,"_targetIds":{"$addToSet":"$tag_id"}}},` +
+		`{"$lookup":{"from":"tags","localField":"_targetIds","foreignField":"_id","as":"_target"}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected the distinct tags:\n%s", out)
+	}
+
+	// the tag is returned for every join row when the duplicates are kept
+	out = compileMongoSchema(t, di, `query { products { id tags @keepDuplicates { id } } }`, nil)
+	exp = `{"$match":{"$expr":{"$eq":["$product_id","$$parentId"]}}},` +
+		`{"$lookup":{"from":"tags","localField":"tag_id","foreignField":"_id","as":"_target"}}`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected a tag for every join row:\n%s", out)
+	}
+}
+
+func TestMongoDBAllRows(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "price", Type: "numeric"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{
+		DBSchema: schema.DBSchema(),
+		TConfig:  map[string]qcode.TConfig{"publicproducts": {Collation: "en"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = co.AddRole("user", "public", "products", qcode.TRConfig{
+		Update: qcode.UpdateConfig{AllowAll: true},
+		Delete: qcode.DeleteConfig{AllowAll: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compile := func(gql string) (string, error) {
+		t.Helper()
+		qc, err := co.Compile([]byte(gql), nil, "user", "")
+		if err != nil {
+			return "", err
+		}
+		_, b, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b), nil
+	}
+
+	// an update or delete without a filter is refused
+	for _, gql := range []string{
+		`mutation { products(update: { price: 5 }) { id } }`,
+		`mutation { products(delete: true) { id } }`,
+	} {
+		if _, err := compile(gql); err == nil {
+			t.Fatalf("expected %s to be refused", gql)
+		}
+	}
+
+	// all the documents are changed with updateMany and deleteMany
+	// filtered on the documents having an _id that is not null
+	out, err := compile(`mutation { products(all: true, update: { price: 5 }) { id } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"operation":"updateMany","collection":"products","filter":{"_id":{"$ne":null}}`) {
+		t.Fatalf("expected an updateMany of all the documents: %s", out)
+	}
+	// the collation of the table matches the documents to update
+	if !strings.Contains(out, `"options":{"collation":{"locale":"en","strength":2}}`) {
+		t.Fatalf("expected the collation of the table: %s", out)
+	}
+
+	out, err = compile(`mutation { products(all: true, delete: true) { id } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"operation":"deleteMany","collection":"products","filter":{"_id":{"$ne":null}}`) {
+		t.Fatalf("expected a deleteMany of all the documents: %s", out)
+	}
+	if !strings.Contains(out, `"options":{"collation":{"locale":"en","strength":2}}`) {
+		t.Fatalf("expected the collation of the table: %s", out)
+	}
+
+	// the previous version of a single document is returned
+	_, err = compile(`mutation { products(all: true, update: { price: 5 }) @returnBefore { id } }`)
+	if err == nil || !strings.Contains(err.Error(), "@returnBefore") {
+		t.Fatalf("expected an error for @returnBefore on all the documents: %v", err)
+	}
+}
+
+func TestMongoDBSideEffects(t *testing.T) {
+	cols := []sdata.DBColumn{
+		{Schema: "public", Table: "categories", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "categories", Name: "product_count", Type: "bigint"},
+		{Schema: "public", Table: "products", Name: "id", Type: "bigint", NotNull: true, PrimaryKey: true, UniqueKey: true},
+		{Schema: "public", Table: "products", Name: "name", Type: "text"},
+		{Schema: "public", Table: "products", Name: "category_id", Type: "bigint", FKeySchema: "public", FKeyTable: "categories", FKeyCol: "id"},
+	}
+	di := sdata.NewDBInfo("mongodb", 0, "public", "db", cols, nil, nil)
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := qcode.NewCompiler(schema, qcode.Config{
+		DBSchema: schema.DBSchema(),
+		TConfig: map[string]qcode.TConfig{"publicproducts": {SideEffects: []qcode.SideEffect{{
+			On:         []qcode.MType{qcode.MTInsert},
+			Collection: "categories",
+			Col:        "category_id",
+			Key:        "_id",
+			Update:     json.RawMessage(`{"$inc":{"product_count":1}}`),
+		}, {
+			Collection: "categories",
+			Col:        "category_id",
+			Key:        "_id",
+			Pipeline:   json.RawMessage(`[{"$group":{"_id":"$category_id","product_count":{"$sum":1}}}]`),
+		}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compile := func(gql string) (string, error) {
+		t.Helper()
+		qc, err := co.Compile([]byte(gql), nil, "admin", "")
+		if err != nil {
+			return "", err
+		}
+		_, b, err := psql.NewCompiler(psql.Config{DBType: "mongodb"}).CompileEx(qc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b), nil
+	}
+
+	inc := `{"collection":"categories","field":"category_id","key":"_id","update":{"$inc":{"product_count":1}}}`
+	merge := `{"collection":"categories","field":"category_id","key":"_id","pipeline":[{"$group":{"_id":"$category_id","product_count":{"$sum":1}}}]}`
+
+	out, err := compile(`mutation { products(insert: { name: "P", category_id: 1 }) { id } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"side_effects":[`+inc+`,`+merge+`]`) {
+		t.Fatalf("expected both side effects on the insert: %s", out)
+	}
+
+	out, err = compile(`mutation { products(id: 1, update: { category_id: 2 }) { id } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"side_effects":[`+merge+`]`) {
+		t.Fatalf("expected only the side effect of all mutations on the update: %s", out)
+	}
+
+	out, err = compile(`mutation { products(id: 1, delete: true) { id } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"operation":"deleteOne"`) || !strings.Contains(out, `"side_effects":[`+merge+`]`) {
+		t.Fatalf("expected the side effect on the delete: %s", out)
+	}
+
+	// the side effects only run after the mutation of a single document
+	_, err = compile(`mutation { products(insert: [{ name: "A", category_id: 1 }, { name: "B", category_id: 1 }]) { id } }`)
+	if err == nil || !strings.Contains(err.Error(), "side effects of 'products'") {
+		t.Fatalf("expected an error for a bulk insert of a table with side effects: %v", err)
+	}
+	_, err = compile(`mutation { products(where: { id: { eq: 1 } }, upsert: { id: 1, name: "P" }) { id } }`)
+	if err == nil || !strings.Contains(err.Error(), "side effects of 'products'") {
+		t.Fatalf("expected an error for an upsert of a table with side effects: %v", err)
+	}
+	_, err = compile(`mutation { products(insert: { id: 1, name: "P", category_id: 1 }, on_conflict: { target: [id], update: [name] }) { id } }`)
+	if err == nil || !strings.Contains(err.Error(), "side effects of 'products'") {
+		t.Fatalf("expected an error for a bulk upsert of a table with side effects: %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package psql_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3/internal/psql"
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+	"github.com/dosco/graphjin/core/v3/internal/sdata"
+)
+
+func TestMSSQLIncludeDirectivesOnMultipleRoots(t *testing.T) {
+	gql := `query {
+		products @include(ifVar: $showProducts) {
+			id
+		}
+		users @include(ifVar: $showUsers) {
+			id
+		}
+	}`
+	vars := map[string]json.RawMessage{
+		"showProducts": json.RawMessage(`true`),
+		"showUsers":    json.RawMessage(`false`),
+	}
+
+	// The user role adds its own filters to products ahead of the
+	// directive variable, so the guard must be found regardless of position.
+	out := compileForDialect(t, "mssql", gql, vars, "user")
+
+	if n := strings.Count(out, `CASE WHEN (@`); n != 2 {
+		t.Fatalf("expected a CASE WHEN guard for each root, got %d: %s", n, out)
+	}
+	if n := strings.Count(out, `ELSE NULL END`); n != 2 {
+		t.Fatalf("expected a NULL fallback for each root, got %d: %s", n, out)
+	}
+}
+
+func TestMSSQLSkipAndIncludeDirectivesCombined(t *testing.T) {
+	gql := `query {
+		products @include(ifVar: $show) @skip(ifVar: $hide) {
+			id
+		}
+	}`
+	vars := map[string]json.RawMessage{
+		"show": json.RawMessage(`true`),
+		"hide": json.RawMessage(`false`),
+	}
+
+	out := compileForDialect(t, "mssql", gql, vars, "user")
+
+	if !strings.Contains(out, `CASE WHEN (@p1 != 1) AND (@p2 = 1) THEN`) {
+		t.Fatalf("expected both directive variables in the CASE WHEN guard: %s", out)
+	}
+}
+
+// newMSSQLCompiler returns a query compiler for a test schema
+// discovered from an mssql database
+func newMSSQLCompiler(t *testing.T) *qcode.Compiler {
+	t.Helper()
+
+	di := sdata.GetTestDBInfo()
+	di.Type = "mssql"
+
+	schema, err := sdata.NewDBSchema(di, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qc, err := qcode.NewCompiler(schema, qcode.Config{DBSchema: schema.DBSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return qc
+}
+
+func TestMSSQLTopWithTiesAndPercent(t *testing.T) {
+	co := newMSSQLCompiler(t)
+	pc := psql.NewCompiler(psql.Config{DBType: "mssql"})
+
+	tests := []struct {
+		gql string
+		exp string
+	}{
+		{
+			`query { products(limit: 3, with_ties: true, order_by: { price: desc }) { id price } }`,
+			`COALESCE((SELECT TOP (3) WITH TIES `,
+		},
+		{
+			`query { products(limit: 10, percent: true, order_by: { price: desc }) { id } }`,
+			`COALESCE((SELECT TOP (10) PERCENT `,
+		},
+		{
+			`query { products(limit: 10, percent: true, withTies: true, order_by: { price: desc }) { id } }`,
+			`COALESCE((SELECT TOP (10) PERCENT WITH TIES `,
+		},
+	}
+
+	for _, tc := range tests {
+		qc, err := co.Compile([]byte(tc.gql), nil, "admin", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, out, err := pc.CompileEx(qc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sql := string(out)
+
+		if !strings.Contains(sql, tc.exp) {
+			t.Fatalf("expected %q in: %s", tc.exp, sql)
+		}
+		if strings.Contains(sql, `FETCH NEXT`) {
+			t.Fatalf("expected no OFFSET/FETCH with TOP: %s", sql)
+		}
+	}
+}
+
+func TestMSSQLTopWithTiesValidation(t *testing.T) {
+	co := newMSSQLCompiler(t)
+
+	tests := []struct {
+		gql string
+		err string
+	}{
+		{
+			`query { products(limit: 3, offset: 5, with_ties: true, order_by: { price: desc }) { id } }`,
+			`cannot be combined with 'offset'`,
+		},
+		{
+			`query { products(limit: 3, with_ties: true) { id } }`,
+			`require 'order_by'`,
+		},
+		{
+			`query { products(limit: 300, percent: true, order_by: { price: desc }) { id } }`,
+			`between 0 and 100`,
+		},
+	}
+
+	for _, tc := range tests {
+		_, err := co.Compile([]byte(tc.gql), nil, "admin", "")
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("expected error %q, got: %v", tc.err, err)
+		}
+	}
+
+	// only supported on mssql
+	_, err := qcompile.Compile([]byte(`query { products(limit: 3, with_ties: true, order_by: { price: desc }) { id } }`), nil, "admin", "")
+	if err == nil || !strings.Contains(err.Error(), "only supported on mssql") {
+		t.Fatalf("expected an unsupported error, got: %v", err)
+	}
+}
+
+func TestMSSQLMutationReturningReselectsRow(t *testing.T) {
+	// updated_at stands in for a computed column or one set by a trigger,
+	// its value is only accurate when read from the table after the write
+	tests := []struct {
+		name  string
+		gql   string
+		vars  string
+		where string
+	}{
+		{
+			name:  "insert",
+			gql:   `mutation { products(insert: $data) { id updated_at } }`,
+			vars:  `{"name": "Product", "price": 1.5}`,
+			where: `WHERE ([products_0].[id] = @products_0)`,
+		},
+		{
+			name:  "update",
+			gql:   `mutation { products(id: 1, update: $data) { id updated_at } }`,
+			vars:  `{"name": "Product"}`,
+			where: `WHERE ([products_0].[id] = 1)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := map[string]json.RawMessage{"data": json.RawMessage(tt.vars)}
+			out := compileForDialect(t, "mssql", tt.gql, vars, "admin")
+
+			if strings.Contains(out, `OUTPUT INSERTED`) {
+				t.Fatalf("expected the row not to be read from INSERTED: %s", out)
+			}
+			sel := `[products_0].[updated_at] AS [updated_at] FROM [public].[products] AS [products_0]  ` + tt.where
+			if !strings.Contains(out, sel) {
+				t.Fatalf("expected the returning selection to re-select the row by its key: %s", out)
+			}
+		})
+	}
+}
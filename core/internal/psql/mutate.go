@@ -119,7 +119,7 @@ func (c *compilerContext) compileLinearMutation() {
 
 		vName := c.getVarName(m)
 		renderColVal := func(col qcode.MColumn) {
-			c.renderColumnValue(m, col)
+			c.renderUpdateColumnValue(m, col)
 		}
 
 		switch m.Type {
@@ -187,6 +187,9 @@ func (c *compilerContext) compileLinearMutation() {
 						c.renderExp(m.Ti, c.qc.Selects[m.SelID].Where.Exp, false)
 						hasWhere = true
 					}
+					if hasWhere {
+						c.renderVersionCheck(m)
+					}
 				}
 
 				if m.Where.Exp != nil {
@@ -239,6 +242,10 @@ func (c *compilerContext) compileLinearMutation() {
 			c.dialect.RenderLinearUpdate(c, &m, c.qc, vName, renderColVal, renderWhere)
 		case qcode.MTDelete:
 			renderWhere := func() {
+				if m.SoftDeleteCol() != nil {
+					c.renderExp(m.Ti, m.Where.Exp, false)
+					return
+				}
 				if m.ParentID == -1 && m.SelID >= 0 && int(m.SelID) < len(c.qc.Selects) {
 					sel := c.qc.Selects[m.SelID]
 					if sel.Where.Exp != nil {
@@ -252,7 +259,11 @@ func (c *compilerContext) compileLinearMutation() {
 					c.renderExpPath(m.Ti, m.Where.Exp, false, nil)
 				}
 			}
-			c.dialect.RenderDelete(c, &m, renderWhere)
+			if m.SoftDeleteCol() != nil {
+				c.dialect.RenderLinearUpdate(c, &m, c.qc, vName, renderColVal, renderWhere)
+			} else {
+				c.dialect.RenderDelete(c, &m, renderWhere)
+			}
 		case qcode.MTConnect:
 			renderFilter := func() {
 				if c.dialect.Name() == "postgres" {
@@ -590,15 +601,31 @@ func (c *compilerContext) renderDelete() {
 			c.quoted(sel.Table)
 		}
 		c.w.WriteString(` AS (`)
-		c.dialect.RenderDelete(c, &m, func() {
-			c.renderExp(sel.Ti, sel.Where.Exp, false)
-		})
+		if m.SoftDeleteCol() != nil {
+			c.dialect.RenderUpdate(c, &m, func() {
+				c.renderSoftDeleteSet(m)
+			}, nil, func() {
+				c.renderExp(m.Ti, m.Where.Exp, false)
+			})
+		} else {
+			c.dialect.RenderDelete(c, &m, func() {
+				c.renderExp(sel.Ti, sel.Where.Exp, false)
+			})
+		}
 		c.dialect.RenderReturning(c, &m)
 		c.w.WriteString(`)`)
 		deleteCount++
 	}
 }
 
+// renderSoftDeleteSet sets the soft-delete column of a delete to the current time
+func (c *compilerContext) renderSoftDeleteSet(m qcode.Mutate) {
+	col := m.SoftDeleteCol()
+	c.w.WriteString(col.Col.Name)
+	c.w.WriteString(` = `)
+	c.renderColumnValue(m, *col)
+}
+
 func (c *compilerContext) renderOneToManyConnectStmt(m qcode.Mutate) {
 	// Render only for parent-to-child relationship of one-to-one
 	// For this to work the json child needs to found first so it's primary key
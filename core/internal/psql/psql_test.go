@@ -89,6 +89,19 @@ func TestMain(m *testing.M) {
 		log.Fatal(err)
 	}
 
+	err = qcompile.AddRole("support", "public", "users", qcode.TRConfig{
+		Query: qcode.QueryConfig{
+			Columns: []string{"id", "full_name", "email"},
+			Masks: map[string]string{
+				"email":     "last:4",
+				"full_name": "redact",
+			},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	err = qcompile.AddRole("bad_dude", "public", "users", qcode.TRConfig{
 		Query: qcode.QueryConfig{
 			Filters:          []string{"false"},
@@ -199,3 +212,24 @@ func _compileGQLToPSQL(t *testing.T, gql string, vars json.RawMessage, role stri
 
 	return nil
 }
+
+// compileForDialect compiles a GraphQL query against the shared test compiler
+// and returns the output of the given database dialect.
+func compileForDialect(t *testing.T, dbType, gql string,
+	vars map[string]json.RawMessage,
+	role string,
+) string {
+	t.Helper()
+
+	qc, err := qcompile.Compile([]byte(gql), vars, role, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pc := psql.NewCompiler(psql.Config{DBType: dbType})
+	_, out, err := pc.CompileEx(qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
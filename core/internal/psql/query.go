@@ -47,6 +47,11 @@ type Config struct {
 	DBVersion       int
 	SecPrefix       []byte
 	EnableCamelcase bool
+	// QueryComment adds the namespace and a hash of the query to the
+	// comment identifying the query
+	QueryComment bool
+	// DisableSortTieBreak stops _id being added to non-unique orderings on MongoDB
+	DisableSortTieBreak bool
 }
 
 type Compiler struct {
@@ -55,6 +60,7 @@ type Compiler struct {
 	cv              int    // db version
 	pf              []byte // security prefix
 	enableCamelcase bool
+	queryComment    bool
 }
 
 func (c *Compiler) GetDialect() dialect.Dialect {
@@ -96,7 +102,11 @@ func NewCompiler(conf Config) *Compiler {
 			},
 		}
 	case "mongodb":
-		d = &dialect.MongoDBDialect{EnableCamelcase: conf.EnableCamelcase}
+		d = &dialect.MongoDBDialect{
+			EnableCamelcase:     conf.EnableCamelcase,
+			QueryComment:        conf.QueryComment,
+			DisableSortTieBreak: conf.DisableSortTieBreak,
+		}
 	default:
 		d = &dialect.PostgresDialect{
 			DBVersion:       conf.DBVersion,
@@ -111,6 +121,7 @@ func NewCompiler(conf Config) *Compiler {
 		cv:              conf.DBVersion,
 		pf:              conf.SecPrefix,
 		enableCamelcase: conf.EnableCamelcase,
+		queryComment:    conf.QueryComment,
 	}
 }
 
@@ -132,10 +143,10 @@ func (co *Compiler) Compile(w *bytes.Buffer, qc *qcode.QCode) (Metadata, error)
 		return md, fmt.Errorf("qcode is nil")
 	}
 
-	// Skip SQL comment for MongoDB (it generates JSON, not SQL) and Snowflake emulator.
-	// The current Snowflake emulator drops result rows when a leading block comment is present.
-	if co.dialect.Name() != "mongodb" && co.dialect.Name() != "snowflake" {
-		w.WriteString(`/* action='` + qc.Name + `',controller='graphql',framework='graphjin' */ `)
+	// MongoDB generates JSON, not SQL, its aggregates carry the comment
+	if _, ok := co.dialect.(dialect.FullQueryCompiler); !ok {
+		c := &compilerContext{w: w, qc: qc, Compiler: co}
+		co.dialect.RenderQueryComment(c, dialect.QueryCommentText(qc, co.queryComment))
 	}
 
 	switch qc.Type {
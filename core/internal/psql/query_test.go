@@ -3,7 +3,10 @@ package psql_test
 import (
 	"bytes"
 	"encoding/json"
+	"strings"
 	"testing"
+
+	"github.com/dosco/graphjin/core/v3/internal/psql"
 )
 
 func simpleQuery(t *testing.T) {
@@ -795,3 +798,63 @@ func BenchmarkCompileParallel(b *testing.B) {
 		}
 	})
 }
+
+func TestQueryComment(t *testing.T) {
+	gql := `query getProducts { products { id } }`
+
+	compile := func(dbType, namespace string, enabled bool) string {
+		t.Helper()
+		qc, err := qcompile.Compile([]byte(gql), nil, "admin", namespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pc := psql.NewCompiler(psql.Config{DBType: dbType, QueryComment: enabled})
+		_, out, err := pc.CompileEx(qc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+
+	// the operation is named by default
+	out := compile("postgres", "shop", false)
+	exp := `/* action='getProducts',controller='graphql',framework='graphjin' */ SELECT`
+	if !strings.HasPrefix(out, exp) {
+		t.Fatalf("expected %s: %s", exp, out)
+	}
+
+	// the namespace and the query hash identify the query when enabled
+	for _, dbType := range []string{"postgres", "mysql", "sqlite", "mssql", "oracle"} {
+		out = compile(dbType, "shop", true)
+		exp = `/* action='getProducts',controller='graphql',framework='graphjin',namespace='shop',query_hash='`
+		if !strings.HasPrefix(out, exp) {
+			t.Fatalf("%s: expected %s: %s", dbType, exp, out)
+		}
+	}
+
+	// the values can't close the comment or the quotes
+	out = compile("postgres", "a' */ DROP TABLE users; --", true)
+	exp = `namespace='a%27%20%2A%2F%20DROP%20TABLE%20users%3B%20--'`
+	if !strings.Contains(out, exp) {
+		t.Fatalf("expected %s: %s", exp, out)
+	}
+	if strings.Count(out, "*/") != 1 {
+		t.Fatalf("expected a single end of comment: %s", out)
+	}
+
+	// snowflake statements have no comment
+	if out = compile("snowflake", "", true); strings.Contains(out, "/*") {
+		t.Fatalf("expected no comment: %s", out)
+	}
+
+	// the comment is set on the mongodb aggregate only when enabled
+	if out = compile("mongodb", "", false); strings.Contains(out, `"comment"`) {
+		t.Fatalf("expected no comment: %s", out)
+	}
+	out = compile("mongodb", "shop", true)
+	exp = `{"operation":"aggregate","collection":"products","field_name":"products",` +
+		`"comment":"action='getProducts',controller='graphql',framework='graphjin',namespace='shop',query_hash='`
+	if !strings.HasPrefix(out, exp) {
+		t.Fatalf("expected %s: %s", exp, out)
+	}
+}
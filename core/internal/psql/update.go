@@ -63,7 +63,7 @@ func (c *compilerContext) renderUpdateStmt(m qcode.Mutate, embedded bool) {
 				}
 				c.w.WriteString(col.Col.Name)
 				c.w.WriteString(` = `)
-				c.renderColumnValue(m, col)
+				c.renderUpdateColumnValue(m, col)
 				i++
 			}
 		}, fromFunc, func() {
@@ -81,6 +81,7 @@ func (c *compilerContext) renderUpdateStmt(m qcode.Mutate, embedded bool) {
 				}
 			} else {
 				c.renderExp(m.Ti, sel.Where.Exp, false)
+				c.renderVersionCheck(m)
 			}
 		})
 
@@ -89,3 +90,29 @@ func (c *compilerContext) renderUpdateStmt(m qcode.Mutate, embedded bool) {
 		}
 	})
 }
+
+// renderUpdateColumnValue renders the value of an updated column, the version
+// column is incremented instead of being set to the expected version
+func (c *compilerContext) renderUpdateColumnValue(m qcode.Mutate, col qcode.MColumn) {
+	if !col.Version {
+		c.renderColumnValue(m, col)
+		return
+	}
+	c.w.WriteString(`(`)
+	c.colWithTable(m.Ti.Name, col.Col.Name)
+	c.w.WriteString(` + 1)`)
+}
+
+// renderVersionCheck adds the expected version to the where clause of the update
+// so that no row is updated when the row has been changed since it was read
+func (c *compilerContext) renderVersionCheck(m qcode.Mutate) {
+	col := m.VersionCol()
+	if col == nil {
+		return
+	}
+	c.w.WriteString(` AND ((`)
+	c.colWithTable(m.Ti.Name, col.Col.Name)
+	c.w.WriteString(`) = (`)
+	c.renderColumnValue(m, *col)
+	c.w.WriteString(`))`)
+}
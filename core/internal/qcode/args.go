@@ -34,6 +34,15 @@ func (co *Compiler) compileSelectArgs(sel *Select, args []graph.Arg, role string
 		case "offset":
 			err = co.compileArgOffset(sel, a)
 
+		case "withTies", "with_ties":
+			err = co.compileArgBool(a, &sel.Paging.WithTies)
+
+		case "percent":
+			err = co.compileArgBool(a, &sel.Paging.Percent)
+
+		case "withDeleted", "with_deleted":
+			err = co.compileArgWithDeleted(sel, a)
+
 		case "first":
 			err = co.compileArgFirstLast(sel, a, OrderAsc)
 
@@ -58,7 +67,7 @@ func (co *Compiler) compileSelectArgs(sel *Select, args []graph.Arg, role string
 		// case "skipIf", "skip_if":
 		// 	err = co.compileArgSkipIncludeIf(true, sel, &sel.Field, a, role)
 
-		case "insert", "update", "upsert", "delete":
+		case "insert", "update", "upsert", "delete", "onConflict", "on_conflict", "all":
 
 		default:
 			return unknownArg(a)
@@ -217,6 +226,11 @@ func (co *Compiler) compileArgSkipIncludeIf(skip bool, sel *Select, f *Field, ar
 	if err != nil {
 		return
 	}
+	if co.s.DBType() == "mongodb" {
+		if err = validateFieldCondition(ex); err != nil {
+			return fmt.Errorf("argument '%s': %w", arg.Name, err)
+		}
+	}
 	if skip {
 		addNotFilter(&f.FieldFilter, ex)
 	} else {
@@ -225,6 +239,24 @@ func (co *Compiler) compileArgSkipIncludeIf(skip bool, sel *Select, f *Field, ar
 	return
 }
 
+// validateFieldCondition checks that a condition on the value of a field only
+// compares the columns of the document, MongoDB evaluates it in the $project
+func validateFieldCondition(ex *Exp) error {
+	switch ex.Op {
+	case OpAnd, OpOr, OpNot:
+		for _, c := range ex.Children {
+			if err := validateFieldCondition(c); err != nil {
+				return err
+			}
+		}
+	case OpEquals, OpNotEquals, OpGreaterThan, OpGreaterOrEquals,
+		OpLesserThan, OpLesserOrEquals, OpIn, OpNotIn, OpIsNull, OpIsNotNull:
+	default:
+		return fmt.Errorf("operator not supported on mongodb: %s", ex.Op)
+	}
+	return nil
+}
+
 func (co *Compiler) compileArgArgs(sel *Select, arg graph.Arg) (err error) {
 	if sel.Ti.Type != "function" {
 		return fmt.Errorf("'%s' does not have any argument", sel.Ti.Name)
@@ -328,6 +360,14 @@ func (co *Compiler) compileArgOffset(sel *Select, arg graph.Arg) (err error) {
 	return nil
 }
 
+func (co *Compiler) compileArgBool(arg graph.Arg, v *bool) (err error) {
+	if err = validateArg(arg, graph.NodeBool); err != nil {
+		return
+	}
+	*v = (arg.Val.Val == "true")
+	return
+}
+
 func (co *Compiler) compileArgFirstLast(sel *Select, arg graph.Arg, order Order) (err error) {
 	if err := co.compileArgLimit(sel, arg); err != nil {
 		return err
@@ -1,5 +1,28 @@
 package qcode
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/dosco/graphjin/core/v3/internal/sdata"
+)
+
+// ErrForbidden is returned when a query or a mutation uses a table its
+// role is not allowed to use
+var ErrForbidden = errors.New("FORBIDDEN")
+
+// ErrEmptyMutation is returned when an update has no columns to set and
+// empty mutations are not allowed
+var ErrEmptyMutation = errors.New("EMPTY_MUTATION")
+
+// Handling of the updates with no columns to set
+const (
+	EmptyMutationsNoop  = "noop"
+	EmptyMutationsError = "error"
+)
+
 type Config struct {
 	Vars            map[string]string
 	TConfig         map[string]TConfig
@@ -14,11 +37,155 @@ type Config struct {
 	// EnableCacheTracking injects __gj_id fields with primary keys for cache row tracking
 	EnableCacheTracking bool
 
+	// IdentifierCase is how field names are mapped to database
+	// identifiers (preserve, lower or snake)
+	IdentifierCase string
+
+	// OutputCase is the case the selected fields are returned in (camel or
+	// snake), the fields with an alias are returned as they are
+	OutputCase string
+
+	// Computed maps a table name to its computed fields and the
+	// columns each of them requires to compute its value
+	Computed map[string]map[string][]string
+
+	// Directives are custom selector and field directives by name
+	Directives map[string]DirectiveFn
+
+	// MaxRelationships is the maximum number of relationships a query can
+	// traverse (0 disables), RoleMaxRelationships overrides it by role
+	MaxRelationships     int
+	RoleMaxRelationships map[string]int
+
+	// DropUnknownFields leaves the fields that are not in the schema out of
+	// the query instead of failing it, NamespaceDropUnknownFields overrides
+	// it by namespace
+	DropUnknownFields          bool
+	NamespaceDropUnknownFields map[string]bool
+
+	// RoleTables are the only tables a role can query or mutate by role,
+	// by table name or schema qualified name. A role without an entry can
+	// use all tables
+	RoleTables map[string]map[string]bool
+
+	// EmptyMutations is how updates with no columns to set are handled,
+	// 'noop' returns the matched rows unchanged and 'error' fails them.
+	// By default the rows are updated without a change
+	EmptyMutations string
+
 	defTrv trval
 }
 
 type TConfig struct {
 	OrderBy map[string][][2]string
+
+	// VersionColumn is checked against the expected version on updates
+	// and incremented when the row is updated
+	VersionColumn string
+
+	// SoftDeleteColumn is set to the current time by deletes instead of
+	// the row being deleted, rows with the column set are not selected
+	SoftDeleteColumn string
+
+	// Collation is the locale used to sort and match the strings of the
+	// table ignoring case, ColCollation is the same for a single column
+	// and is only used when the column is sorted or filtered on
+	Collation    string
+	ColCollation map[string]string
+
+	// StaticRows are the rows of a static lookup table as a json array
+	StaticRows json.RawMessage
+
+	// Default is the document returned by a singular relationship to the
+	// table that finds no row, the values are json by column name
+	Default map[string]json.RawMessage
+
+	// Compare are the boolean fields computed by comparing a column with
+	// a value or another column, by field name
+	Compare map[string]TCompare
+
+	// Labels are the labels of the values of the columns returned by the
+	// @label directive, by column name
+	Labels map[string]Labels
+
+	// Deprecated maps the deprecated columns to the reason they are
+	// deprecated, it is only used by introspection
+	Deprecated map[string]string
+
+	// DefaultLimit is the limit of selects on the table without one and
+	// MaxLimit the largest limit allowed, larger limits are lowered to it
+	// unless MaxLimitError is set and they fail instead
+	DefaultLimit  int32
+	MaxLimit      int32
+	MaxLimitError bool
+
+	// SideEffects are the updates of other collections run after the
+	// mutations of a document of the table
+	SideEffects []SideEffect
+}
+
+// SideEffect is an update of the Collection run after the mutations On
+// a document. The documents whose Key is the value of the Col of the
+// mutated document are updated with the json Update, or the Pipeline is
+// run on the documents of the table with that value and merged into them
+type SideEffect struct {
+	On         []MType
+	Collection string
+	Col        string
+	Key        string
+	Update     json.RawMessage
+	Pipeline   json.RawMessage
+}
+
+// sideEffects returns the side effects of the table run after the
+// mutation type
+func (tc TConfig) sideEffects(mt MType) (ses []SideEffect) {
+	for _, se := range tc.SideEffects {
+		if len(se.On) == 0 || slices.Contains(se.On, mt) {
+			ses = append(ses, se)
+		}
+	}
+	return
+}
+
+// TCompare is a boolean field computed by comparing the column Col with
+// the json value Val or the column ValCol
+type TCompare struct {
+	Col    string
+	Op     string
+	Val    json.RawMessage
+	ValCol string
+}
+
+// collation returns the locale used to sort and match strings ignoring case
+// for the selector, it is empty if the selector uses the default collation
+func (tc TConfig) collation(sel *Select) string {
+	if tc.Collation != "" || len(tc.ColCollation) == 0 {
+		return tc.Collation
+	}
+	for _, ob := range sel.OrderBy {
+		if v, ok := tc.ColCollation[ob.Col.Name]; ok {
+			return v
+		}
+	}
+	return tc.expCollation(sel.Ti.Name, sel.Where.Exp)
+}
+
+func (tc TConfig) expCollation(table string, ex *Exp) string {
+	if ex == nil {
+		return ""
+	}
+	if ex.Left.Col.Name != "" && (ex.Left.Col.Table == "" || ex.Left.Col.Table == table) {
+		if v, ok := tc.ColCollation[ex.Left.Col.Name]; ok {
+			return v
+		}
+	}
+	for _, c := range ex.Children {
+		if v := tc.expCollation(table, c); v != "" {
+			return v
+		}
+	}
+	return ""
 }
 
 type TRConfig struct {
@@ -33,8 +200,11 @@ type QueryConfig struct {
 	Limit            int
 	Filters          []string
 	Columns          []string
+	Masks            map[string]string
 	DisableFunctions bool
 	Block            bool
+	// Materialize are the collections the results can be cached in
+	Materialize []string
 }
 
 type InsertConfig struct {
@@ -48,6 +218,9 @@ type UpdateConfig struct {
 	Columns []string
 	Presets map[string]string
 	Block   bool
+	// AllowAll lets the role update all the rows of the
+	// table with the 'all: true' argument instead of a where clause
+	AllowAll bool
 }
 
 type UpsertConfig struct {
@@ -61,18 +234,23 @@ type DeleteConfig struct {
 	Filters []string
 	Columns []string
 	Block   bool
+	// AllowAll lets the role delete all the rows of the
+	// table with the 'all: true' argument instead of a where clause
+	AllowAll bool
 }
 
 type trval struct {
 	role string
 
 	query struct {
-		limit   int32
-		fil     *Exp
-		filNU   bool
-		cols    map[string]struct{}
-		disable struct{ funcs bool }
-		block   bool
+		limit       int32
+		fil         *Exp
+		filNU       bool
+		cols        map[string]struct{}
+		masks       map[string]Mask
+		disable     struct{ funcs bool }
+		block       bool
+		materialize map[string]struct{}
 	}
 
 	insert struct {
@@ -82,11 +260,12 @@ type trval struct {
 	}
 
 	update struct {
-		fil     *Exp
-		filNU   bool
-		cols    map[string]struct{}
-		presets map[string]string
-		block   bool
+		fil      *Exp
+		filNU    bool
+		cols     map[string]struct{}
+		presets  map[string]string
+		block    bool
+		allowAll bool
 	}
 
 	upsert struct {
@@ -98,10 +277,11 @@ type trval struct {
 	}
 
 	delete struct {
-		fil   *Exp
-		filNU bool
-		cols  map[string]struct{}
-		block bool
+		fil      *Exp
+		filNU    bool
+		cols     map[string]struct{}
+		block    bool
+		allowAll bool
 	}
 }
 
@@ -125,8 +305,13 @@ func (co *Compiler) AddRole(role, schema, table string, trc TRConfig) error {
 		trv.query.limit = int32(trc.Query.Limit)
 	}
 	trv.query.cols = makeSet(trc.Query.Columns)
+	trv.query.masks, err = compileMasks(trc.Query.Masks)
+	if err != nil {
+		return fmt.Errorf("masks: %w", err)
+	}
 	trv.query.disable.funcs = trc.Query.DisableFunctions
 	trv.query.block = trc.Query.Block
+	trv.query.materialize = makeSet(trc.Query.Materialize)
 
 	// insert config
 	trv.insert.cols = makeSet(trc.Insert.Columns)
@@ -141,6 +326,7 @@ func (co *Compiler) AddRole(role, schema, table string, trc TRConfig) error {
 	trv.update.cols = makeSet(trc.Update.Columns)
 	trv.update.presets = trc.Update.Presets
 	trv.update.block = trc.Update.Block
+	trv.update.allowAll = trc.Update.AllowAll
 
 	// upsert config
 	trv.upsert.fil, trv.upsert.filNU, err = compileFilter(co.s, ti, trc.Upsert.Filters, false)
@@ -158,6 +344,7 @@ func (co *Compiler) AddRole(role, schema, table string, trc TRConfig) error {
 	}
 	trv.delete.cols = makeSet(trc.Delete.Columns)
 	trv.delete.block = trc.Delete.Block
+	trv.delete.allowAll = trc.Delete.AllowAll
 
 	if schema == "" {
 		schema = co.s.DBSchema()
@@ -190,6 +377,16 @@ func (co *Compiler) getTConfig(schema, name string) TConfig {
 	return co.c.TConfig[(schema + name)]
 }
 
+// checkRoleTable fails with ErrForbidden when the table is not one of the
+// tables the role is allowed to use
+func (co *Compiler) checkRoleTable(role string, ti sdata.DBTable) error {
+	tables, ok := co.c.RoleTables[role]
+	if !ok || tables[ti.Name] || tables[ti.Schema+"."+ti.Name] {
+		return nil
+	}
+	return fmt.Errorf("%w: table '%s' is not allowed for role '%s'", ErrForbidden, ti.Name, role)
+}
+
 func (trv *trval) filter(qt QType) (*Exp, bool) {
 	switch qt {
 	case QTQuery:
@@ -227,6 +424,16 @@ func (trv *trval) columnAllowed(qt *QCode, name string) bool {
 	return false
 }
 
+// updateColumnAllowed returns true if the role can update the column
+func (trv *trval) updateColumnAllowed(name string) bool {
+	_, ok := trv.update.cols[name]
+	return ok || len(trv.update.cols) == 0
+}
+
+func (trv *trval) mask(name string) Mask {
+	return trv.query.masks[name]
+}
+
 func (trv *trval) limit(qt QType) int32 {
 	if qt == QTQuery && trv.query.limit != 0 {
 		return trv.query.limit
@@ -250,10 +457,29 @@ func (trv *trval) isBlocked(qt QType) bool {
 	return false
 }
 
+// isAllAllowed returns true if the role can update or delete all the rows
+// of the table
+func (trv *trval) isAllAllowed(qt QType) bool {
+	switch qt {
+	case QTUpdate:
+		return trv.update.allowAll
+	case QTDelete:
+		return trv.delete.allowAll
+	}
+	return false
+}
+
 func (trv *trval) isFuncsBlocked() bool {
 	return trv.query.disable.funcs
 }
 
+// materializeAllowed returns true if the role can cache the results of
+// the table in the collection
+func (trv *trval) materializeAllowed(collection string) bool {
+	_, ok := trv.query.materialize[collection]
+	return ok
+}
+
 // func (trv *trval) isMutationBlocked(mt MType, name string) error {
 // 	var blocked bool
 // 	switch mt {
@@ -0,0 +1,191 @@
+package qcode
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dosco/graphjin/core/v3/internal/graph"
+	"github.com/dosco/graphjin/core/v3/internal/sdata"
+)
+
+// compileOnConflict compiles the 'on_conflict' argument of an insert that
+// turns it into a bulk upsert. The target are the columns the rows conflict
+// on and update the columns updated on the existing rows, it defaults to
+// all the inserted columns. The existing rows are updated like with an update
+// by the role, its update columns, presets and filter apply.
+//
+//	users(insert: $data, on_conflict: { target: [email], update: [full_name] })
+func (co *Compiler) compileOnConflict(qc *QCode, sel *Select, arg graph.Arg, role string) (*OnConflict, error) {
+	switch co.s.DBType() {
+	case "oracle", "snowflake":
+		return nil, fmt.Errorf("not supported on %s", co.s.DBType())
+	}
+	if co.s.DBType() == "mongodb" && len(qc.Roots) > 1 {
+		return nil, errors.New("the insert must be the only root in the mutation on mongodb")
+	}
+	if arg.Val.Type != graph.NodeObj {
+		return nil, errors.New("expecting an object with the target and update columns")
+	}
+
+	oc := &OnConflict{}
+	for _, n := range arg.Val.Children {
+		var err error
+		switch n.Name {
+		case "target":
+			oc.Target, err = conflictColumns(sel, n)
+		case "update":
+			oc.Update, err = conflictColumns(sel, n)
+		default:
+			err = fmt.Errorf("unknown key '%s'", n.Name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", n.Name, err)
+		}
+	}
+
+	if len(oc.Target) == 0 {
+		return nil, errors.New("target columns required")
+	}
+
+	tr := co.getRole(role, sel.Ti.Schema, sel.Ti.Name, sel.FieldName)
+	if tr.isBlocked(QTUpdate) || tr.isBlocked(QTUpsert) {
+		return nil, fmt.Errorf("%w: update of '%s' not allowed for role '%s'",
+			ErrForbidden, sel.FieldName, role)
+	}
+	for _, col := range oc.Update {
+		if !tr.updateColumnAllowed(col.Name) {
+			return nil, fmt.Errorf("%w: update of column '%s' not allowed for role '%s'",
+				ErrForbidden, col.Name, role)
+		}
+	}
+	if err := co.setConflictPresets(oc, sel, tr); err != nil {
+		return nil, err
+	}
+
+	if fil, userNeeded := tr.filter(QTUpdate); fil != nil && fil.Op != OpNop {
+		if userNeeded && role == "anon" {
+			return nil, errUserIDReq
+		}
+		oc.Where.Exp = fil
+	}
+	return oc, nil
+}
+
+// setConflictPresets adds the update presets of the role to the columns set
+// on the existing rows, they are sorted to render the same query every time
+func (co *Compiler) setConflictPresets(oc *OnConflict, sel *Select, tr trval) error {
+	for k, v := range tr.getPresets(MTUpdate) {
+		col, err := sel.Ti.GetColumn(co.ParseName(k))
+		if err != nil {
+			return err
+		}
+		oc.Presets = append(oc.Presets, MColumn{Col: col, FieldName: k, Alias: col.Name, Value: v, Set: true})
+	}
+	sort.Slice(oc.Presets, func(i, j int) bool {
+		return oc.Presets[i].Col.Name < oc.Presets[j].Col.Name
+	})
+	return nil
+}
+
+// conflictColumns returns the columns of a column name or a list of them
+func conflictColumns(sel *Select, node *graph.Node) ([]sdata.DBColumn, error) {
+	names := []*graph.Node{node}
+	if node.Type == graph.NodeList {
+		names = node.Children
+	}
+
+	var cols []sdata.DBColumn
+	for _, n := range names {
+		if n.Type != graph.NodeLabel && n.Type != graph.NodeStr {
+			return nil, errors.New("expecting a column name or a list of them")
+		}
+		col, err := sel.Ti.GetColumn(n.Val)
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// checkOnConflict checks the bulk upserts once the inserted columns are known,
+// the target and update columns must be inserted. The update columns default to
+// the inserted columns that are not part of the target and that the role can
+// update, the columns set by a preset are not updated with the inserted values.
+func (co *Compiler) checkOnConflict(qc *QCode, role string) error {
+	for i := range qc.Mutates {
+		m := &qc.Mutates[i]
+		if m.OnConflict == nil {
+			continue
+		}
+		// the items of an inline list share the argument
+		oc := *m.OnConflict
+		m.OnConflict = &oc
+
+		for j := range qc.Mutates {
+			if qc.Mutates[j].ParentID == m.ID {
+				return errors.New("nested inserts are not supported")
+			}
+		}
+
+		for _, col := range m.OnConflict.Target {
+			if !m.hasCol(col.Name) {
+				return fmt.Errorf("target column '%s' is not inserted", col.Name)
+			}
+		}
+
+		if len(m.OnConflict.Update) == 0 {
+			tr := co.getRole(role, m.Ti.Schema, m.Ti.Name, m.Key)
+			for _, mc := range m.Cols {
+				if !mc.Set && tr.updateColumnAllowed(mc.Col.Name) &&
+					!hasConflictCol(m.OnConflict.Target, mc.Col.Name) {
+					m.OnConflict.Update = append(m.OnConflict.Update, mc.Col)
+				}
+			}
+		}
+
+		var update []sdata.DBColumn
+		for _, col := range m.OnConflict.Update {
+			if !m.hasCol(col.Name) {
+				return fmt.Errorf("update column '%s' is not inserted", col.Name)
+			}
+			if !hasConflictPreset(m.OnConflict.Presets, col.Name) {
+				update = append(update, col)
+			}
+		}
+		m.OnConflict.Update = update
+
+		if len(m.OnConflict.Update) == 0 && len(m.OnConflict.Presets) == 0 {
+			return errors.New("no columns to update")
+		}
+	}
+	return nil
+}
+
+func (m *Mutate) hasCol(name string) bool {
+	for _, mc := range m.Cols {
+		if mc.Col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasConflictCol(cols []sdata.DBColumn, name string) bool {
+	for _, col := range cols {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasConflictPreset(cols []MColumn, name string) bool {
+	for _, mc := range cols {
+		if mc.Col.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package qcode
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ConnectionCursorField is the prefix of the hidden fields with the values
+// of the columns a connection is ordered by, they are used to build the
+// cursors of its first and last row
+const ConnectionCursorField = "__gj_cur_"
+
+// setConnection checks that the selector can return the page info of its
+// cursor pagination and sets the size of its pages. One row more than the
+// page size is fetched to know if there is a next page, with MongoDB the
+// driver adds the row and builds the cursors itself.
+func (co *Compiler) setConnection(qc *QCode, sel *Select) error {
+	switch {
+	case qc.Type != QTQuery:
+		return fmt.Errorf("can only be used in a query")
+	case sel.ParentID != -1:
+		return fmt.Errorf("can only be used on a root selector")
+	case sel.Singular:
+		return fmt.Errorf("cannot be used on a singular selector")
+	case !sel.Paging.Cursor || sel.Paging.NoLimit:
+		return fmt.Errorf("requires cursor pagination (first, after)")
+	case sel.Paging.Type == PTBackward:
+		return fmt.Errorf("can only page forward with after")
+	}
+	sel.PageSize = sel.Paging.Limit
+
+	if co.s.DBType() == "mongodb" {
+		return nil
+	}
+	if sel.Paging.LimitVar != "" {
+		return fmt.Errorf("requires a constant limit")
+	}
+	sel.Paging.Limit++
+
+	for i, ob := range sel.OrderBy {
+		if ob.Var != "" || ob.Col.Table != sel.Table {
+			return fmt.Errorf("can only be ordered by the columns of the table")
+		}
+		name := ConnectionCursorField + strconv.Itoa(i)
+		sel.Fields = append(sel.Fields, Field{
+			ID:        int32(len(sel.Fields)),
+			ParentID:  sel.ID,
+			Type:      FieldTypeCol,
+			Col:       ob.Col,
+			FieldName: name,
+		})
+		if sel.bcolExists(ob.Col.Name) == -1 {
+			sel.BCols = append(sel.BCols, Column{Col: ob.Col, FieldName: name})
+		}
+	}
+	return nil
+}
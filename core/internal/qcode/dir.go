@@ -2,7 +2,9 @@ package qcode
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dosco/graphjin/core/v3/internal/graph"
 	"github.com/dosco/graphjin/core/v3/internal/sdata"
@@ -19,6 +21,9 @@ func (co *Compiler) compileOpDirectives(qc *QCode, dirs []graph.Directive) error
 		case "constraint", "validate":
 			err = co.compileDirectiveConstraint(qc, d)
 
+		case "throttle":
+			err = compileDirectiveThrottle(qc, d)
+
 		default:
 			err = fmt.Errorf("unknown operation directive: %s", d.Name)
 		}
@@ -57,12 +62,56 @@ func (co *Compiler) compileSelectorDirectives(qc *QCode,
 		case "through":
 			err = co.compileDirectiveThrough(sel, d)
 
+		case "insertOptions", "insert_options":
+			err = co.compileDirectiveInsertOptions(sel, d)
+
+		case "getOrCreate", "get_or_create":
+			err = co.compileDirectiveGetOrCreate(qc, sel, d)
+
+		case "returnBefore", "return_before":
+			err = co.compileDirectiveReturnBefore(qc, sel, d)
+
 		case "object":
 			sel.Singular = true
 			sel.Paging.Limit = 1
 
+		case "flatten":
+			if len(d.Args) != 0 {
+				err = unknownArg(d.Args[0])
+			}
+			sel.Flatten = true
+
+		case "exists":
+			if len(d.Args) != 0 {
+				err = unknownArg(d.Args[0])
+			}
+			// a single match is enough to know if there is one
+			sel.Exists = true
+			sel.Paging.Limit = 1
+
+		case "keepDuplicates", "keep_duplicates":
+			err = co.compileDirectiveKeepDuplicates(sel, d)
+
+		case "changeStream", "change_stream":
+			err = co.compileDirectiveChangeStream(sel, d)
+
+		case "materialize":
+			err = co.compileDirectiveMaterialize(sel, d)
+
+		case "connection":
+			if len(d.Args) != 0 {
+				err = unknownArg(d.Args[0])
+			}
+			sel.Connection = true
+
+		case "cacheControl":
+			err = co.compileDirectiveCacheControl(qc, d)
+
 		default:
-			err = fmt.Errorf("no such selector directive: %s", d.Name)
+			// custom directives run once the table is known
+			if _, ok := co.c.Directives[d.Name]; !ok {
+				err = fmt.Errorf("no such selector directive: %s", d.Name)
+			}
 		}
 
 		if err != nil {
@@ -72,11 +121,14 @@ func (co *Compiler) compileSelectorDirectives(qc *QCode,
 	return
 }
 
-func (co *Compiler) compileFieldDirectives(sel *Select,
+func (co *Compiler) compileFieldDirectives(qc *QCode, sel *Select,
 	f *Field, dirs []graph.Directive, role string,
 ) (err error) {
 	for _, d := range dirs {
 		switch d.Name {
+		case "cacheControl":
+			err = co.compileDirectiveCacheControl(qc, d)
+
 		case "add":
 			err = co.compileDirectiveAddRemove(false, sel, f, d, role)
 
@@ -89,8 +141,30 @@ func (co *Compiler) compileFieldDirectives(sel *Select,
 		case "skip":
 			err = co.compileDirectiveSkipInclude(true, sel, f, d, role)
 
+		case "size":
+			err = co.compileDirectiveArray(ArrayProjSize, f, d)
+
+		case "slice":
+			err = co.compileDirectiveArray(ArrayProjSlice, f, d)
+
+		case "sum":
+			err = co.compileDirectiveArray(ArrayProjSum, f, d)
+
+		case "avg":
+			err = co.compileDirectiveArray(ArrayProjAvg, f, d)
+
+		case "label":
+			err = co.compileDirectiveLabel(sel, f, d)
+
+		case "dateDiff":
+			err = co.compileDirectiveDateDiff(sel, f, d)
+
 		default:
-			err = fmt.Errorf("unknown field directive: %s", d.Name)
+			if fn, ok := co.c.Directives[d.Name]; ok {
+				err = co.compileCustomDirective(fn, sel, f, d, role)
+			} else {
+				err = fmt.Errorf("unknown field directive: %s", d.Name)
+			}
 		}
 		if err != nil {
 			return fmt.Errorf("directive @%s: %w", d.Name, err)
@@ -108,6 +182,128 @@ func (co *Compiler) compileDirectiveSchema(sel *Select, d graph.Directive) (err
 	return
 }
 
+func (co *Compiler) compileDirectiveChangeStream(sel *Select, d graph.Directive) (err error) {
+	sel.ChangeStream = ChangeStreamDocument
+
+	for _, a := range d.Args {
+		switch a.Name {
+		case "mode":
+			if err = validateArg(a, graph.NodeStr); err != nil {
+				return
+			}
+			switch a.Val.Val {
+			case "document":
+				sel.ChangeStream = ChangeStreamDocument
+			case "fields":
+				sel.ChangeStream = ChangeStreamFields
+			default:
+				return fmt.Errorf("valid values for 'mode' are 'document' and 'fields'")
+			}
+
+		default:
+			return unknownArg(a)
+		}
+	}
+	return
+}
+
+func (co *Compiler) compileDirectiveMaterialize(sel *Select, d graph.Directive) (err error) {
+	for _, a := range d.Args {
+		switch a.Name {
+		case "collection":
+			if err = validateArg(a, graph.NodeStr); err != nil {
+				return
+			}
+			name := a.Val.Val
+			if name == "" || strings.ContainsAny(name, "$\x00") || strings.HasPrefix(name, "system.") {
+				return fmt.Errorf("invalid collection name: %s", name)
+			}
+			sel.Materialize.Collection = name
+
+		case "ttlSeconds", "ttl_seconds":
+			if sel.Materialize.TTL, err = arrayArgInt(a); err != nil {
+				return
+			}
+
+		default:
+			return unknownArg(a)
+		}
+	}
+
+	switch {
+	case sel.Materialize.Collection == "":
+		return fmt.Errorf("required argument 'collection'")
+	case sel.Materialize.TTL <= 0:
+		return fmt.Errorf("required argument 'ttlSeconds' must be greater than 0")
+	}
+	return
+}
+
+func (co *Compiler) compileDirectiveInsertOptions(sel *Select, d graph.Directive) (err error) {
+	if len(d.Args) == 0 {
+		return fmt.Errorf("required argument 'ordered'")
+	}
+
+	for _, a := range d.Args {
+		switch a.Name {
+		case "ordered":
+			if err = validateArg(a, graph.NodeBool); err != nil {
+				return
+			}
+			sel.addIArg(Arg{Name: "ordered", Val: a.Val.Val})
+
+		default:
+			return unknownArg(a)
+		}
+	}
+	return
+}
+
+// compileDirectiveGetOrCreate makes an upsert only insert the document when
+// no document matches its filter, a matching document is returned unchanged
+func (co *Compiler) compileDirectiveGetOrCreate(qc *QCode, sel *Select, d graph.Directive) (err error) {
+	switch {
+	case len(d.Args) != 0:
+		return unknownArg(d.Args[0])
+	case co.s.DBType() != "mongodb":
+		return fmt.Errorf("only supported on mongodb")
+	case qc.SType != QTUpsert || sel.ParentID != -1:
+		return fmt.Errorf("can only be used on the root selector of an upsert")
+	}
+	sel.addIArg(Arg{Name: "get_or_create", Val: "true"})
+	return
+}
+
+// compileDirectiveKeepDuplicates makes a many-to-many relationship return
+// a related document for every document of the join collection linking it
+func (co *Compiler) compileDirectiveKeepDuplicates(sel *Select, d graph.Directive) (err error) {
+	switch {
+	case len(d.Args) != 0:
+		return unknownArg(d.Args[0])
+	case co.s.DBType() != "mongodb":
+		return fmt.Errorf("only supported on mongodb")
+	case sel.ParentID == -1:
+		return fmt.Errorf("can only be used on a related selector")
+	}
+	sel.KeepDuplicates = true
+	return
+}
+
+// compileDirectiveReturnBefore makes an update return the document as it
+// was before it was updated
+func (co *Compiler) compileDirectiveReturnBefore(qc *QCode, sel *Select, d graph.Directive) (err error) {
+	switch {
+	case len(d.Args) != 0:
+		return unknownArg(d.Args[0])
+	case co.s.DBType() != "mongodb":
+		return fmt.Errorf("only supported on mongodb")
+	case qc.SType != QTUpdate || sel.ParentID != -1:
+		return fmt.Errorf("can only be used on the root selector of an update")
+	}
+	sel.addIArg(Arg{Name: "return_before", Val: "true"})
+	return
+}
+
 func (co *Compiler) compileDirectiveAddRemove(
 	remove bool,
 	sel *Select,
@@ -179,38 +375,200 @@ func (co *Compiler) compileDirectiveSkipInclude(
 	return
 }
 
-func (co *Compiler) compileDirectiveCacheControl(qc *QCode, d graph.Directive) (err error) {
-	var hdr []string
+// compileDirectiveArray compiles the @size, @slice, @sum and @avg directives
+// that return the length, a window or an aggregate of the elements of an
+// array column
+func (co *Compiler) compileDirectiveArray(t ArrayProjType, f *Field, d graph.Directive) (err error) {
+	switch {
+	case co.s.DBType() != "mongodb":
+		return fmt.Errorf("only supported on mongodb")
+	case f.Type != FieldTypeCol || !f.Col.Array:
+		return fmt.Errorf("can only be used on an array column")
+	case f.ArrayProj.Type != ArrayProjNone:
+		return fmt.Errorf("only one of @size, @slice, @sum and @avg can be used")
+	}
+
+	if t != ArrayProjSlice {
+		if len(d.Args) != 0 {
+			return unknownArg(d.Args[0])
+		}
+		f.ArrayProj.Type = t
+		return
+	}
+
+	f.ArrayProj.Limit = -1
+	for _, arg := range d.Args {
+		switch arg.Name {
+		case "skip":
+			if f.ArrayProj.Skip, err = arrayArgInt(arg); err != nil {
+				return
+			}
+		case "limit":
+			if f.ArrayProj.Limit, err = arrayArgInt(arg); err != nil {
+				return
+			}
+			if f.ArrayProj.Limit == 0 {
+				return fmt.Errorf("argument 'limit' must be greater than 0")
+			}
+		default:
+			return unknownArg(arg)
+		}
+	}
+	if f.ArrayProj.Limit == -1 {
+		return reqArgMissing("limit")
+	}
+	f.ArrayProj.Type = t
+	return
+}
+
+// compileDirectiveLabel returns the label of the value of a column from the
+// labels of the column config instead of the value
+func (co *Compiler) compileDirectiveLabel(sel *Select, f *Field, d graph.Directive) error {
+	if len(d.Args) != 0 {
+		return unknownArg(d.Args[0])
+	}
+	switch {
+	case co.s.DBType() != "mongodb":
+		return fmt.Errorf("only supported on mongodb")
+	case f.Type != FieldTypeCol || f.Compare != nil || f.Col.Array:
+		return fmt.Errorf("can only be used on a column")
+	}
+	l, ok := sel.tc.Labels[f.Col.Name]
+	if !ok {
+		return fmt.Errorf("no labels configured for column '%s'", f.Col.Name)
+	}
+	f.Labels = &l
+	return nil
+}
+
+// dateDiffUnits are the units of the @dateDiff directive
+var dateDiffUnits = map[string]bool{
+	"year": true, "quarter": true, "month": true, "week": true, "day": true,
+	"hour": true, "minute": true, "second": true, "millisecond": true,
+}
+
+// compileDirectiveDateDiff returns the time from the date of a column to
+// the date of the end column or to now, like the age of a document
+func (co *Compiler) compileDirectiveDateDiff(sel *Select, f *Field, d graph.Directive) error {
+	switch {
+	case co.s.DBType() != "mongodb":
+		return fmt.Errorf("only supported on mongodb")
+	case f.Type != FieldTypeCol || f.Compare != nil || f.Col.Array:
+		return fmt.Errorf("can only be used on a column")
+	case f.Labels != nil:
+		return fmt.Errorf("cannot be used with @label")
+	}
+
+	dd := DateDiff{Unit: "day"}
+	for _, arg := range d.Args {
+		if err := validateArg(arg, graph.NodeStr, graph.NodeLabel); err != nil {
+			return err
+		}
+		switch arg.Name {
+		case "unit":
+			if !dateDiffUnits[arg.Val.Val] {
+				return fmt.Errorf("invalid unit '%s'", arg.Val.Val)
+			}
+			dd.Unit = arg.Val.Val
+		case "end":
+			col, err := sel.Ti.GetColumn(arg.Val.Val)
+			if err != nil {
+				return err
+			}
+			dd.EndCol = col.Name
+		default:
+			return unknownArg(arg)
+		}
+	}
+	f.DateDiff = &dd
+	return nil
+}
 
+func arrayArgInt(arg graph.Arg) (int, error) {
+	if err := validateArg(arg, graph.NodeNum); err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(arg.Val.Val)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("argument '%s' must be a positive integer", arg.Name)
+	}
+	return n, nil
+}
+
+// compileDirectiveThrottle sets the minimum time in milliseconds between
+// the results delivered to a subscriber
+func compileDirectiveThrottle(qc *QCode, d graph.Directive) error {
+	if qc.Type != QTSubscription {
+		return fmt.Errorf("directive @throttle: can only be used in a subscription")
+	}
+	if len(d.Args) != 1 || d.Args[0].Name != "ms" {
+		return fmt.Errorf("directive @throttle: argument 'ms' expected")
+	}
+	n, err := arrayArgInt(d.Args[0])
+	if err != nil {
+		return fmt.Errorf("directive @throttle: %w", err)
+	}
+	qc.Throttle = time.Duration(n) * time.Millisecond
+	return nil
+}
+
+// compileDirectiveCacheControl adds the cache hint of the operation, a
+// selector or a field to the cache-control of the response
+func (co *Compiler) compileDirectiveCacheControl(qc *QCode, d graph.Directive) (err error) {
 	if len(d.Args) == 0 {
-		err = fmt.Errorf("arguments 'maxAge' or 'maxAge' expected")
+		err = fmt.Errorf("arguments 'maxAge' or 'scope' expected")
 		return
 	}
 
+	c := &qc.Cache
 	for _, arg := range d.Args {
 		switch arg.Name {
 		case "maxAge":
-			if err = validateArg(arg, graph.NodeNum); err != nil {
+			var n int
+			if n, err = arrayArgInt(arg); err != nil {
 				return
 			}
-			hdr = append(hdr, "max-age="+arg.Val.Val)
+			if !c.HasMaxAge || n < c.MaxAge {
+				c.MaxAge = n
+			}
+			c.HasMaxAge = true
+
 		case "scope":
 			if err = validateArg(arg, graph.NodeStr); err != nil {
 				return
 			}
-			hdr = append(hdr, arg.Val.Val)
+			switch strings.ToLower(arg.Val.Val) {
+			case "private":
+				c.Private = true
+			case "public":
+				c.public = true
+			default:
+				return fmt.Errorf("argument 'scope' must be 'public' or 'private'")
+			}
 
 		default:
 			return unknownArg(arg)
-
 		}
 	}
-	if len(hdr) != 0 {
-		qc.Cache.Header = strings.Join(hdr, " ")
-	}
+	c.setHeader()
 	return nil
 }
 
+// setHeader sets the cache-control header from the combined cache hints
+func (c *Cache) setHeader() {
+	var hdr []string
+	if c.HasMaxAge {
+		hdr = append(hdr, "max-age="+strconv.Itoa(c.MaxAge))
+	}
+	switch {
+	case c.Private:
+		hdr = append(hdr, "private")
+	case c.public:
+		hdr = append(hdr, "public")
+	}
+	c.Header = strings.Join(hdr, ", ")
+}
+
 func (co *Compiler) compileDirectiveConstraint(qc *QCode, d graph.Directive) (err error) {
 	a, err := getArg(d.Args, "variable", graph.NodeStr)
 	if err != nil {
@@ -262,3 +620,117 @@ func (co *Compiler) compileDirectiveThrough(sel *Select, d graph.Directive) (err
 	}
 	return
 }
+
+// builtinDirectives cannot be replaced by custom directives
+var builtinDirectives = map[string]struct{}{
+	"add": {}, "remove": {}, "include": {}, "skip": {}, "schema": {},
+	"notRelated": {}, "not_related": {}, "through": {}, "object": {},
+	"insertOptions": {}, "insert_options": {}, "cacheControl": {},
+	"getOrCreate": {}, "get_or_create": {},
+	"returnBefore": {}, "return_before": {},
+	"constraint": {}, "validate": {}, "size": {}, "slice": {},
+	"flatten": {}, "changeStream": {}, "change_stream": {}, "exists": {},
+}
+
+// DirectiveFn handles a custom directive on a selector or a field
+type DirectiveFn func(d *CustomDirective) error
+
+// CustomDirective is passed to the handler of a custom directive
+// and is used to change the selector or field it is used on
+type CustomDirective struct {
+	Name string
+
+	// Argument values, variables are set as $name
+	Args map[string]string
+
+	Role  string
+	Table string
+
+	// Field is set when the directive is used on a column
+	Field string
+
+	co  *Compiler
+	sel *Select
+	f   *Field
+}
+
+// Skip renders the selector or field as null
+func (d *CustomDirective) Skip() {
+	if d.f != nil {
+		d.f.SkipRender = SkipTypeNulled
+	} else {
+		d.sel.SkipRender = SkipTypeNulled
+	}
+}
+
+// SetSingular returns a single object for the selector instead of a list
+func (d *CustomDirective) SetSingular() error {
+	if d.f != nil {
+		return fmt.Errorf("can only be used on a selector")
+	}
+	d.sel.Singular = true
+	d.sel.Paging.Limit = 1
+	return nil
+}
+
+// AddFilter adds a where filter (eg. { tenant_id: { eq: $tenant_id } })
+// to the selector
+func (d *CustomDirective) AddFilter(filter string) error {
+	if d.f != nil {
+		return fmt.Errorf("filters can only be added to a selector")
+	}
+	ex, nu, err := compileFilter(d.co.s, d.sel.Ti, []string{filter}, false)
+	if err != nil {
+		return err
+	}
+	if nu && d.Role == "anon" {
+		d.sel.SkipRender = SkipTypeUserNeeded
+	}
+	addAndFilter(&d.sel.Where, ex)
+	return nil
+}
+
+// compileCustomDirectives runs the custom directives used on the selector
+func (co *Compiler) compileCustomDirectives(sel *Select,
+	dirs []graph.Directive, role string,
+) error {
+	for _, d := range dirs {
+		fn, ok := co.c.Directives[d.Name]
+		if !ok {
+			continue
+		}
+		if err := co.compileCustomDirective(fn, sel, nil, d, role); err != nil {
+			return fmt.Errorf("directive @%s: %w", d.Name, err)
+		}
+	}
+	return nil
+}
+
+func (co *Compiler) compileCustomDirective(fn DirectiveFn,
+	sel *Select, f *Field, d graph.Directive, role string,
+) error {
+	cd := CustomDirective{
+		Name:  d.Name,
+		Args:  make(map[string]string, len(d.Args)),
+		Role:  role,
+		Table: sel.Table,
+		co:    co,
+		sel:   sel,
+		f:     f,
+	}
+	if f != nil {
+		cd.Field = f.FieldName
+	}
+
+	for _, a := range d.Args {
+		switch a.Val.Type {
+		case graph.NodeVar:
+			cd.Args[a.Name] = "$" + a.Val.Val
+		case graph.NodeStr, graph.NodeNum, graph.NodeBool, graph.NodeLabel:
+			cd.Args[a.Name] = a.Val.Val
+		default:
+			return fmt.Errorf("argument '%s' must be a variable or a scalar value", a.Name)
+		}
+	}
+	return fn(&cd)
+}
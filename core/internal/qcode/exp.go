@@ -167,6 +167,14 @@ func (ast *aexpst) parseNode(av aexp, node *graph.Node, selID int32) (*Exp, erro
 			return ex, nil
 		}
 
+		// { column: { _size: { op: value } } }
+		if node.Children[0].Name == "_size" {
+			if _, err := ast.processColumn(av, ex, node, selID); err != nil {
+				return nil, err
+			}
+			return ex, ast.processArraySize(av, ex, node.Children[0])
+		}
+
 		// Check for JSON path operations on nested objects
 		if ok, err := ast.processJSONPath(av, ex, node, selID); err != nil {
 			return nil, err
@@ -200,6 +208,12 @@ func (ast *aexpst) parseNode(av aexp, node *graph.Node, selID int32) (*Exp, erro
 			ex.Right.Path = append(ex.Right.Path, vn.Name)
 		}
 
+		// the null compared with an array is not a value
+		if ex.Op == OpIsNull && vn.Type == graph.NodeLabel {
+			ex.Right.ValType = ValBool
+			return ex, nil
+		}
+
 		if ex.Right.ValType, err = getExpType(vn); err != nil {
 			return nil, err
 		}
@@ -311,9 +325,19 @@ func (ast *aexpst) processOpAndVal(av aexp, ex *Exp, node *graph.Node) (bool, er
 
 	switch name {
 	case "eq", "equals":
+		if ast.isArrayNullCheck(ex, node) {
+			ex.Op = OpIsNull
+			ex.Right.Val = "true"
+			break
+		}
 		ex.Op = OpEquals
 		ex.Right.Val = node.Val
 	case "neq", "notEquals", "not_equals":
+		if ast.isArrayNullCheck(ex, node) {
+			ex.Op = OpIsNull
+			ex.Right.Val = "false"
+			break
+		}
 		ex.Op = OpNotEquals
 		ex.Right.Val = node.Val
 	case "gt", "greaterThan", "greater_than":
@@ -389,6 +413,12 @@ func (ast *aexpst) processOpAndVal(av aexp, ex *Exp, node *graph.Node) (bool, er
 	case "isNull", "is_null":
 		ex.Op = OpIsNull
 		ex.Right.Val = node.Val
+	case "hasNull", "has_null":
+		if ast.co.s.DBType() != "mongodb" || !ex.Left.Col.Array {
+			return false, fmt.Errorf("operator '%s' is only supported on array columns on mongodb", name)
+		}
+		ex.Op = OpHasNull
+		ex.Right.Val = node.Val
 	case "notDistinct", "ndis", "not_distinct":
 		ex.Op = OpNotDistinct
 		ex.Right.Val = node.Val
@@ -423,6 +453,14 @@ func (ast *aexpst) processOpAndVal(av aexp, ex *Exp, node *graph.Node) (bool, er
 	return true, nil
 }
 
+// isArrayNullCheck returns true if an array column on mongodb is compared
+// with null, this is a null check since comparing an array with null also
+// matches arrays with a null element
+func (ast *aexpst) isArrayNullCheck(ex *Exp, node *graph.Node) bool {
+	return node.Type == graph.NodeLabel && node.Val == "null" &&
+		ex.Left.Col.Array && len(ex.Left.Path) == 0 && ast.co.s.DBType() == "mongodb"
+}
+
 func getExpType(node *graph.Node) (ValType, error) {
 	switch node.Type {
 	case graph.NodeStr:
@@ -837,7 +875,7 @@ func (ast *aexpst) isOperator(name string) (bool, error) {
 		"contains", "containedIn", "contained_in",
 		"hasInCommon", "has_in_common",
 		"hasKey", "has_key", "hasKeyAny", "has_key_any", "hasKeyAll", "has_key_all",
-		"isNull", "is_null", "notDistinct", "ndis", "not_distinct",
+		"isNull", "is_null", "hasNull", "has_null", "notDistinct", "ndis", "not_distinct",
 		"dis", "distinct",
 		// GIS/Spatial operators
 		"st_dwithin", "stDWithin", "st_d_within", "dwithin",
@@ -903,6 +941,10 @@ func (ast *aexpst) processNestedTable(av aexp, ex *Exp, node *graph.Node) (bool,
 		n = n.Children[0]
 	}
 
+	if len(joins) != 0 && n.Name == "_count" {
+		return true, ast.processSelectCount(av, ex, joins, n)
+	}
+
 	if len(joins) != 0 {
 		ex.Op = OpSelectExists
 		ex.Joins = joins
@@ -912,6 +954,73 @@ func (ast *aexpst) processNestedTable(av aexp, ex *Exp, node *graph.Node) (bool,
 	return false, nil
 }
 
+// processSelectCount compares the number of related documents
+// e.g. { orders: { _count: { gt: 3 } } }
+func (ast *aexpst) processSelectCount(av aexp, ex *Exp, joins []Join, node *graph.Node) error {
+	if ast.co.s.DBType() != "mongodb" {
+		return fmt.Errorf("[Where] count of related rows is only supported on mongodb: %s", av.ti.Name)
+	}
+	if len(joins) != 1 {
+		return fmt.Errorf("[Where] count of related rows requires a direct relationship: %s", av.ti.Name)
+	}
+	switch joins[0].Rel.Type {
+	case sdata.RelOneToOne, sdata.RelOneToMany:
+	default:
+		return fmt.Errorf("[Where] count of related rows is not supported on this relationship: %s", av.ti.Name)
+	}
+	if len(node.Children) == 0 {
+		return errors.New("[Where] missing comparison after '_count'")
+	}
+
+	ex.Op = OpSelectCount
+	ex.Joins = joins
+	return ast.processSizeOps(av, ex, node)
+}
+
+// processArraySize compares the length of an array, a missing array has a length of 0
+// e.g. { items: { _size: { gte: 2 } } }
+func (ast *aexpst) processArraySize(av aexp, ex *Exp, node *graph.Node) error {
+	if ast.co.s.DBType() != "mongodb" {
+		return fmt.Errorf("[Where] length of arrays is only supported on mongodb: %s", av.ti.Name)
+	}
+	if !ex.Left.Col.Array {
+		return fmt.Errorf("[Where] '_size' is only supported on array columns: %s", ex.Left.Col.Name)
+	}
+	if len(node.Children) == 0 {
+		return errors.New("[Where] missing comparison after '_size'")
+	}
+
+	ex.Op = OpArraySize
+	return ast.processSizeOps(av, ex, node)
+}
+
+// processSizeOps adds the comparisons of a count or length to the expression
+func (ast *aexpst) processSizeOps(av aexp, ex *Exp, node *graph.Node) error {
+	for _, vn := range node.Children {
+		cex := newExp()
+		if ok, err := ast.processOpAndVal(av, cex, vn); err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("[Where] unknown operator: %s", vn.Name)
+		}
+		switch cex.Op {
+		case OpEquals, OpNotEquals, OpGreaterThan, OpGreaterOrEquals,
+			OpLesserThan, OpLesserOrEquals:
+		default:
+			return fmt.Errorf("[Where] operator not supported on '%s': %s", node.Name, vn.Name)
+		}
+		var err error
+		if cex.Right.ValType, err = getExpType(vn); err != nil {
+			return err
+		}
+		if cex.Right.ValType != ValNum && cex.Right.ValType != ValVar {
+			return fmt.Errorf("[Where] '%s' must be compared with a number: %s", node.Name, vn.Name)
+		}
+		ex.Children = append(ex.Children, cex)
+	}
+	return nil
+}
+
 func (ast *aexpst) pushChildren(av aexp, ex *Exp, node *graph.Node) {
 	var path []string
 	var ti sdata.DBTable
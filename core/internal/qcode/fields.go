@@ -64,6 +64,7 @@ func (co *Compiler) compileChildColumns(
 
 		if f.Alias != "" {
 			field.FieldName = f.Alias
+			field.aliased = true
 		} else {
 			field.FieldName = f.Name
 		}
@@ -75,12 +76,20 @@ func (co *Compiler) compileChildColumns(
 			continue
 		}
 
-		if len(f.Children) != 0 {
-			val := f.ID | (sel.ID << 16)
-			st.Push(val)
+		// a relationship with @exists needs no fields
+		if len(f.Children) == 0 && hasDirective(f, "exists") {
+			st.Push(f.ID | (sel.ID << 16))
 			continue
 		}
 
+		if len(f.Children) != 0 {
+			if field.JSONPaths = co.jsonPaths(op, sel, name, f); field.JSONPaths == nil {
+				val := f.ID | (sel.ID << 16)
+				st.Push(val)
+				continue
+			}
+		}
+
 		switch {
 		case name == "__typename":
 			sel.Typename = true
@@ -95,6 +104,34 @@ func (co *Compiler) compileChildColumns(
 
 		field.Col, isCol = sel.Ti.ColumnExists(name)
 
+		if !isCol {
+			if field.Compare, err = co.compareField(sel, name); err != nil {
+				return err
+			}
+			if isCol = (field.Compare != nil); isCol {
+				field.Col = sdata.DBColumn{
+					ID:     -1,
+					Schema: sel.Ti.Schema,
+					Table:  sel.Ti.Name,
+					Name:   name,
+					Type:   "boolean",
+				}
+			}
+		}
+
+		if !isCol && co.isComputed(sel, name) {
+			if len(f.Directives) != 0 || len(f.Args) != 0 {
+				return fmt.Errorf("computed field '%s' does not support arguments or directives", name)
+			}
+			sel.Computed = append(sel.Computed, ComputedField{
+				Name:      name,
+				FieldName: field.FieldName,
+				Pos:       len(sel.Fields) + len(sel.Computed),
+				aliased:   field.aliased,
+			})
+			continue
+		}
+
 		if !isCol {
 			fn, isFunc, err = co.isFunction(sel, name, f)
 			if err != nil {
@@ -104,16 +141,20 @@ func (co *Compiler) compileChildColumns(
 
 		switch {
 		case isCol:
+			field.Mask = tr.mask(field.Col.Name)
 		case isFunc:
 			field.Type = FieldTypeFunc
 			field.Func = fn.Func
 			field.Args = fn.Args
 			aggExists = fn.Agg
+		case co.dropUnknownFields(qc.Namespace):
+			qc.UnknownFields = append(qc.UnknownFields, selectPath(qc, sel)+"."+field.FieldName)
+			continue
 		default:
 			return fmt.Errorf("field '%s' is not a column or a function", name)
 		}
 
-		if err := co.compileFieldDirectives(sel, &field, f.Directives, role); err != nil {
+		if err := co.compileFieldDirectives(qc, sel, &field, f.Directives, role); err != nil {
 			return err
 		}
 
@@ -146,6 +187,95 @@ func (co *Compiler) compileChildColumns(
 	if aggExists {
 		sel.GroupCols = true
 	}
+
+	if len(sel.Computed) != 0 {
+		qc.Computed++
+		return co.addComputedDeps(sel)
+	}
+	return nil
+}
+
+func (co *Compiler) isComputed(sel *Select, name string) bool {
+	_, ok := co.c.Computed[sel.Ti.Name][name]
+	return ok
+}
+
+// jsonPaths returns the keys selected from a json column that is not
+// exposed as a table, it is nil when the field is not such a column
+func (co *Compiler) jsonPaths(op *graph.Operation, sel *Select, name string, f graph.Field) []JSONPath {
+	if co.s.DBType() != "mongodb" {
+		return nil
+	}
+	col, ok := sel.Ti.ColumnExists(name)
+	if !ok || col.Array || (col.Type != "json" && col.Type != "jsonb") {
+		return nil
+	}
+	if _, err := co.s.Find(sel.Ti.Schema, name); err == nil {
+		return nil
+	}
+	return buildJSONPaths(op, f, "")
+}
+
+func buildJSONPaths(op *graph.Operation, f graph.Field, prefix string) []JSONPath {
+	paths := make([]JSONPath, 0, len(f.Children))
+	for _, cid := range f.Children {
+		cf := op.Fields[cid]
+		p := JSONPath{FieldName: cf.Name, Path: prefix + cf.Name}
+		if cf.Alias != "" {
+			p.FieldName = cf.Alias
+		}
+		if len(cf.Children) != 0 {
+			p.Paths = buildJSONPaths(op, cf, p.Path+".")
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// compareField returns the comparison of a boolean field computed from a
+// comparison, it is nil when the field is not one
+func (co *Compiler) compareField(sel *Select, name string) (*Compare, error) {
+	tc, ok := sel.tc.Compare[name]
+	if !ok {
+		return nil, nil
+	}
+	cmp := &Compare{Op: tc.Op, Val: tc.Val}
+
+	var err error
+	if cmp.Col, err = sel.Ti.GetColumn(tc.Col); err != nil {
+		return nil, fmt.Errorf("compare field '%s': %w", name, err)
+	}
+	if tc.ValCol != "" {
+		if cmp.ValCol, err = sel.Ti.GetColumn(tc.ValCol); err != nil {
+			return nil, fmt.Errorf("compare field '%s': %w", name, err)
+		}
+	}
+	return cmp, nil
+}
+
+// addComputedDeps adds the columns required by the computed fields as hidden
+// fields when they are not already selected
+func (co *Compiler) addComputedDeps(sel *Select) error {
+	for _, cf := range sel.Computed {
+		for _, name := range co.c.Computed[sel.Ti.Name][cf.Name] {
+			if i := sel.fieldExists(name); i != -1 &&
+				sel.Fields[i].Type == FieldTypeCol && sel.Fields[i].Col.Name == name &&
+				co.outputName(sel.Fields[i]) == name {
+				continue
+			}
+			col, err := sel.Ti.GetColumn(name)
+			if err != nil {
+				return fmt.Errorf("computed field '%s': %w", cf.Name, err)
+			}
+			sel.addField(Field{
+				ID:        int32(len(sel.Fields)),
+				ParentID:  sel.ID,
+				Type:      FieldTypeCol,
+				Col:       col,
+				FieldName: ComputedDepPrefix + name,
+			})
+		}
+	}
 	return nil
 }
 
@@ -279,6 +409,18 @@ func (co *Compiler) addRelColumns(qc *QCode, sel *Select, rel sdata.DBRel) error
 func (co *Compiler) orderByIDCol(sel *Select) error {
 	idCol := sel.Ti.PrimaryCol
 
+	// Every MongoDB document has an _id so use it even when
+	// no primary key was discovered for the collection
+	if idCol.Name == "" && co.s.DBType() == "mongodb" {
+		idCol = sdata.DBColumn{
+			Schema:     sel.Ti.Schema,
+			Table:      sel.Ti.Name,
+			Name:       "_id",
+			Type:       "objectId",
+			PrimaryKey: true,
+		}
+	}
+
 	if idCol.Name == "" {
 		return fmt.Errorf("table requires primary key: %s", sel.Ti.Name)
 	}
@@ -394,3 +536,22 @@ func (co *Compiler) addCacheTrackingField(sel *Select) {
 		sel.BCols = append(sel.BCols, Column{Col: pk, FieldName: "__gj_id"})
 	}
 }
+
+// hasDirective returns true if the field has the directive
+func hasDirective(f graph.Field, name string) bool {
+	for _, d := range f.Directives {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// dropUnknownFields returns true if the fields that are not in the schema
+// are left out of the queries of the namespace
+func (co *Compiler) dropUnknownFields(namespace string) bool {
+	if v, ok := co.c.NamespaceDropUnknownFields[namespace]; ok {
+		return v
+	}
+	return co.c.DropUnknownFields
+}
@@ -14,13 +14,37 @@ func (co *Compiler) isFunction(sel *Select, name string, f graph.Field) (
 	switch {
 	case name == "search_rank":
 		isFunc = true
+		fn.Name = name
+		fn.Func.Name = name
 		if _, ok := sel.GetInternalArg("search"); !ok {
 			err = fmt.Errorf("search argument not found: %s", name)
 		}
 
+	// on mongodb the distance computed by the $geoNear stage of a distance
+	// filter at the query root, returned in the unit of the filter
+	case name == "_distance":
+		isFunc = true
+		fn.Name = name
+		fn.Func.Name = name
+		switch {
+		case co.s.DBType() != "mongodb":
+			err = fmt.Errorf("%s: only supported on mongodb", name)
+		case sel.ParentID != -1 || !hasGeoDistance(sel.Where.Exp):
+			err = fmt.Errorf("%s: requires a st_dwithin or near filter at the query root", name)
+		}
+
+	// on mongodb a count without a column counts the documents of the
+	// root selector and is returned as a scalar
+	case name == "count" && co.s.DBType() == "mongodb" && len(f.Args) == 0:
+		isFunc = true
+		fn.Name = name
+		fn.Func = co.s.GetFunctions()[name]
+		fn.Agg = true
+
 	case strings.HasPrefix(name, "search_headline_"):
 		isFunc = true
 		fn.Name = "search_headline"
+		fn.Func.Name = fn.Name
 		fn.Args = []Arg{{Type: ArgTypeCol}}
 		fn.Args[0].Col, err = sel.Ti.GetColumn(name[(len(fn.Name) + 1):])
 		if err != nil {
@@ -87,3 +111,20 @@ func (co *Compiler) isFunctionEx(sel *Select, name string, f graph.Field) (
 
 	return
 }
+
+// hasGeoDistance returns true if the expression has a distance based geo
+// filter, on mongodb it is matched with a $geoNear stage
+func hasGeoDistance(exp *Exp) bool {
+	if exp == nil {
+		return false
+	}
+	if exp.Op == OpGeoDistance || exp.Op == OpGeoNear {
+		return true
+	}
+	for _, c := range exp.Children {
+		if hasGeoDistance(c) {
+			return true
+		}
+	}
+	return false
+}
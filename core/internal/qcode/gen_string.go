@@ -237,11 +237,14 @@ func _() {
 	_ = x[OpGeoTouches-45]
 	_ = x[OpGeoOverlaps-46]
 	_ = x[OpGeoNear-47]
+	_ = x[OpHasNull-48]
+	_ = x[OpSelectCount-49]
+	_ = x[OpArraySize-50]
 }
 
-const _ExpOp_name = "OpNopOpAndOpOrOpNotOpEqualsOpNotEqualsOpGreaterOrEqualsOpLesserOrEqualsOpGreaterThanOpLesserThanOpInOpNotInOpLikeOpNotLikeOpILikeOpNotILikeOpSimilarOpNotSimilarOpRegexOpNotRegexOpIRegexOpNotIRegexOpContainsOpContainedInOpHasInCommonOpHasKeyOpHasKeyAnyOpHasKeyAllOpIsNullOpIsNotNullOpTsQueryOpFalseOpNotDistinctOpDistinctOpEqualsTrueOpNotEqualsTrueOpSelectExistsJSON path operator (->)JSON path text operator (->>)ST_DWithin - distance-based filteringST_Within - geometry A within BST_Contains - geometry A contains BST_Intersects - geometries intersectST_CoveredBy - geometry A covered by BST_Covers - geometry A covers BST_Touches - geometries touch at boundaryST_Overlaps - geometries overlapMongoDB $near / $nearSphere"
+const _ExpOp_name = "OpNopOpAndOpOrOpNotOpEqualsOpNotEqualsOpGreaterOrEqualsOpLesserOrEqualsOpGreaterThanOpLesserThanOpInOpNotInOpLikeOpNotLikeOpILikeOpNotILikeOpSimilarOpNotSimilarOpRegexOpNotRegexOpIRegexOpNotIRegexOpContainsOpContainedInOpHasInCommonOpHasKeyOpHasKeyAnyOpHasKeyAllOpIsNullOpIsNotNullOpTsQueryOpFalseOpNotDistinctOpDistinctOpEqualsTrueOpNotEqualsTrueOpSelectExistsJSON path operator (->)JSON path text operator (->>)ST_DWithin - distance-based filteringST_Within - geometry A within BST_Contains - geometry A contains BST_Intersects - geometries intersectST_CoveredBy - geometry A covered by BST_Covers - geometry A covers BST_Touches - geometries touch at boundaryST_Overlaps - geometries overlapMongoDB $near / $nearSphereMongoDB array has a null elementMongoDB count of the related documentsMongoDB length of an array"
 
-var _ExpOp_index = [...]uint16{0, 5, 10, 14, 19, 27, 38, 55, 71, 84, 96, 100, 107, 113, 122, 129, 139, 148, 160, 167, 177, 185, 196, 206, 219, 232, 240, 251, 262, 270, 281, 290, 297, 310, 320, 332, 347, 361, 384, 413, 450, 481, 516, 552, 590, 621, 662, 694, 721}
+var _ExpOp_index = [...]uint16{0, 5, 10, 14, 19, 27, 38, 55, 71, 84, 96, 100, 107, 113, 122, 129, 139, 148, 160, 167, 177, 185, 196, 206, 219, 232, 240, 251, 262, 270, 281, 290, 297, 310, 320, 332, 347, 361, 384, 413, 450, 481, 516, 552, 590, 621, 662, 694, 721, 753, 791, 817}
 
 func (i ExpOp) String() string {
 	idx := int(i) - 0
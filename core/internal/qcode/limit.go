@@ -0,0 +1,101 @@
+package qcode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// checkMaxLimit lowers the limit of a selector to the maximum limit of its
+// table or fails if the table is configured to reject larger limits. A limit
+// set with a variable is checked when the query is run by ProcessLimits
+func (co *Compiler) checkMaxLimit(sel *Select) error {
+	max := sel.tc.MaxLimit
+	if max <= 0 || sel.Singular || sel.Paging.Percent || sel.Paging.LimitVar != "" {
+		return nil
+	}
+	if !sel.Paging.NoLimit && sel.Paging.Limit <= max {
+		return nil
+	}
+	if sel.tc.MaxLimitError {
+		return fmt.Errorf("argument 'limit' exceeds the maximum of %d for '%s'", max, sel.Table)
+	}
+	sel.Paging.NoLimit = false
+	sel.Paging.Limit = max
+	return nil
+}
+
+// checkMaxRelationships fails when the query traverses more relationships
+// than the maximum of the role. Every nested selector is a relationship, a
+// many-to-many relationship also counts its join table and an ordering on
+// columns of related tables counts each of them. The error names the path of
+// the relationship that exceeds the maximum
+func (co *Compiler) checkMaxRelationships(qc *QCode, role string) error {
+	max := co.c.MaxRelationships
+	if v, ok := co.c.RoleMaxRelationships[role]; ok {
+		max = v
+	}
+	if max <= 0 {
+		return nil
+	}
+
+	n := 0
+	for i := range qc.Selects {
+		sel := &qc.Selects[i]
+		if sel.SkipRender != SkipTypeNone {
+			continue
+		}
+		if sel.ParentID != -1 {
+			n++
+		}
+		n += len(sel.Joins)
+		if n > max {
+			return fmt.Errorf("too many relationships: '%s' exceeds the maximum of %d per query",
+				selectPath(qc, sel), max)
+		}
+	}
+	return nil
+}
+
+// selectPath returns the field names from the root to the selector
+func selectPath(qc *QCode, sel *Select) string {
+	path := []string{sel.FieldName}
+	for p := sel.ParentID; p != -1; p = qc.Selects[p].ParentID {
+		path = append(path, qc.Selects[p].FieldName)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return strings.Join(path, ".")
+}
+
+// ProcessLimits checks the values of the variables used as the limit of
+// selectors on tables with a maximum limit. A larger value or a missing one
+// is lowered to the maximum, or fails if the table rejects larger limits
+func (qc *QCode) ProcessLimits(vmap map[string]json.RawMessage) error {
+	for i := range qc.Selects {
+		sel := &qc.Selects[i]
+		max := sel.tc.MaxLimit
+		if max <= 0 || sel.Paging.LimitVar == "" || sel.Paging.Percent {
+			continue
+		}
+		name := sel.Paging.LimitVar
+
+		var n int64
+		if v, ok := vmap[name]; ok && string(v) != "null" {
+			var err error
+			if n, err = strconv.ParseInt(string(v), 10, 32); err != nil {
+				return fmt.Errorf("variable '%s' must be an integer", name)
+			}
+			if n <= int64(max) {
+				continue
+			}
+		}
+		if sel.tc.MaxLimitError {
+			return fmt.Errorf("variable '%s' exceeds the maximum limit of %d for '%s'", name, max, sel.Table)
+		}
+		vmap[name] = json.RawMessage(strconv.Itoa(int(max)))
+	}
+	return nil
+}
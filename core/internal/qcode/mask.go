@@ -0,0 +1,75 @@
+package qcode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type MaskType int8
+
+const (
+	MaskTypeNone MaskType = iota
+	MaskTypeRedact
+	MaskTypeFirst
+	MaskTypeLast
+)
+
+const defaultMaskText = "****"
+
+// Mask replaces the value of a column with a redacted version of it
+// for roles that are only allowed to see part of the value
+type Mask struct {
+	Type MaskType
+	Len  int
+	Text string
+}
+
+// parseMask parses a mask definition. The supported masks are
+// 'redact' or 'redact:<text>' to replace the whole value,
+// 'first:<n>' to keep only the first n characters and
+// 'last:<n>' to keep only the last n characters.
+func parseMask(def string) (m Mask, err error) {
+	name, arg, hasArg := strings.Cut(strings.TrimSpace(def), ":")
+	m.Text = defaultMaskText
+
+	switch strings.ToLower(name) {
+	case "redact":
+		m.Type = MaskTypeRedact
+		if hasArg && arg != "" {
+			m.Text = arg
+		}
+		return
+
+	case "first":
+		m.Type = MaskTypeFirst
+
+	case "last":
+		m.Type = MaskTypeLast
+
+	default:
+		err = fmt.Errorf("invalid mask '%s': must be redact, first:<n> or last:<n>", def)
+		return
+	}
+
+	if m.Len, err = strconv.Atoi(arg); err != nil || m.Len <= 0 {
+		err = fmt.Errorf("invalid mask '%s': length must be a positive number", def)
+	}
+	return
+}
+
+func compileMasks(masks map[string]string) (map[string]Mask, error) {
+	if len(masks) == 0 {
+		return nil, nil
+	}
+	mm := make(map[string]Mask, len(masks))
+
+	for col, def := range masks {
+		m, err := parseMask(def)
+		if err != nil {
+			return nil, fmt.Errorf("column '%s': %w", col, err)
+		}
+		mm[col] = m
+	}
+	return mm, nil
+}
@@ -52,17 +52,39 @@ type Mutate struct {
 	DependsOn map[int32]struct{}
 	Type      MType
 	// CType     uint8
-	Key      string
-	Path     []string
-	Val      json.RawMessage
-	Cols     []MColumn
-	RCols    []MRColumn
-	Ti       sdata.DBTable
-	Rel      sdata.DBRel
-	Where    Filter
-	Multi    bool
-	children []int32
-	render   bool
+	Key   string
+	Path  []string
+	Val   json.RawMessage
+	Cols  []MColumn
+	RCols []MRColumn
+	Ti    sdata.DBTable
+	Rel   sdata.DBRel
+	Where Filter
+	Multi bool
+	// OnConflict is set on bulk upserts, inserts that update the
+	// rows that already exist
+	OnConflict *OnConflict
+	children   []int32
+	render     bool
+	// SideEffects are the updates of other collections run after the
+	// mutation of the document
+	SideEffects []SideEffect
+	// All is set on the root updates and deletes of all the rows
+	All bool
+	// replace holds the embedded arrays replaced as a whole
+	replace map[string]struct{}
+}
+
+// OnConflict is the conflict handling of an insert, the rows conflicting
+// with an existing row on the Target columns update its Update columns
+type OnConflict struct {
+	Target []sdata.DBColumn
+	Update []sdata.DBColumn
+	// Presets are the update presets of the role set on the existing
+	// rows and Where the update filter of the role, the existing rows
+	// that do not match it are left unchanged
+	Presets []MColumn
+	Where   Filter
 }
 
 type MColumn struct {
@@ -71,6 +93,19 @@ type MColumn struct {
 	Alias     string
 	Value     string
 	Set       bool
+
+	// Version is set on the version column of a table using optimistic
+	// concurrency, the value is the expected version and the column
+	// is incremented instead of set
+	Version bool
+
+	// Replace is set on an embedded json array given a list in an update,
+	// the whole array is replaced by the list (MongoDB only)
+	Replace bool
+
+	// SoftDelete is set on the soft-delete column of a delete, the column
+	// is set to the current time instead of the row being deleted
+	SoftDelete bool
 }
 
 type MRColumn struct {
@@ -117,13 +152,23 @@ func (co *Compiler) compileMutation(qc *QCode,
 	mids := map[string][]int32{}
 	st := util.NewStackInf()
 	var nextID int32
+	var empty int
 
 	// Process each root select as a separate root mutation
 	for _, rootID := range qc.Roots {
 		sel := &qc.Selects[rootID]
 
+		var all bool
 		if whereReq && sel.Where.Exp == nil {
-			return errors.New("where clause required")
+			if err := co.matchAllRows(qc, sel, role); err != nil {
+				return err
+			}
+			all = true
+		}
+		if whereReq && qc.SType != QTDelete {
+			if err := co.addSoftDeleteFilter(sel); err != nil {
+				return err
+			}
 		}
 
 		m := Mutate{
@@ -133,6 +178,7 @@ func (co *Compiler) compileMutation(qc *QCode,
 			Key:      sel.Table,
 			Ti:       sel.Ti,
 			SelID:    rootID,
+			All:      all,
 		}
 		nextID++
 
@@ -148,6 +194,9 @@ func (co *Compiler) compileMutation(qc *QCode,
 		}
 
 		if m.Type == MTDelete {
+			if err := co.setSoftDelete(&m, sel); err != nil {
+				return err
+			}
 			m.render = true
 			st.Push(m)
 			continue
@@ -158,6 +207,23 @@ func (co *Compiler) compileMutation(qc *QCode,
 			return err
 		}
 
+		if m.Type == MTUpdate && isEmptyData(m.Data) {
+			switch co.c.EmptyMutations {
+			case EmptyMutationsError:
+				return fmt.Errorf("%w: no columns to set in the update of '%s'",
+					ErrEmptyMutation, sel.FieldName)
+			case EmptyMutationsNoop:
+				empty++
+				continue
+			}
+		}
+
+		if arg, ok := qc.conflictArgs[sel.FieldName]; ok {
+			if m.OnConflict, err = co.compileOnConflict(qc, sel, arg, role); err != nil {
+				return fmt.Errorf("on_conflict: %w", err)
+			}
+		}
+
 		if m.Data.Type == graph.NodeList {
 			for _, v := range co.processList(m) {
 				st.Push(v)
@@ -167,6 +233,18 @@ func (co *Compiler) compileMutation(qc *QCode,
 		}
 	}
 
+	// the rows matched by updates with no columns to set are returned
+	// unchanged by a query instead of being updated
+	if empty != 0 {
+		if empty != len(qc.Roots) {
+			return fmt.Errorf("%w: an update with no columns to set cannot be combined with other updates",
+				ErrEmptyMutation)
+		}
+		qc.Type = QTQuery
+		qc.SType = QTQuery
+		return nil
+	}
+
 	// Convert QType to MType for mState
 	var mt MType
 	switch qc.SType {
@@ -249,6 +327,55 @@ func (co *Compiler) compileMutation(qc *QCode,
 		}
 	}
 	qc.Mutates = mutates
+
+	if err := co.checkOnConflict(qc, role); err != nil {
+		return fmt.Errorf("on_conflict: %w", err)
+	}
+
+	if err := co.setSideEffects(qc); err != nil {
+		return err
+	}
+
+	// the previous document is returned by the single update of a root
+	if len(qc.Mutates) > len(qc.Roots) {
+		for _, id := range qc.Roots {
+			if _, ok := qc.Selects[id].GetInternalArg("return_before"); ok {
+				return errors.New("directive @returnBefore: cannot be used with nested or bulk updates")
+			}
+		}
+	}
+
+	// a version conflict on one root must not leave the other roots updated
+	if qc.HasVersionCheck() {
+		if len(qc.Roots) > 1 {
+			return errors.New("updates on a table with a version column must be the only root in the mutation")
+		}
+		if co.s.DBType() == "mongodb" && len(qc.Mutates) > 1 {
+			return errors.New("nested updates on a table with a version column are not supported on mongodb")
+		}
+	}
+	return nil
+}
+
+// HasVersionCheck returns true if the mutation updates a table
+// using a version column for optimistic concurrency
+func (qc *QCode) HasVersionCheck() bool {
+	for i := range qc.Mutates {
+		if qc.Mutates[i].VersionCol() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// VersionCol returns the version column of the update or nil
+// if the table does not use one
+func (m *Mutate) VersionCol() *MColumn {
+	for i := range m.Cols {
+		if m.Cols[i].Version {
+			return &m.Cols[i]
+		}
+	}
 	return nil
 }
 
@@ -273,6 +400,39 @@ func parseDataValue(qc *QCode, actionVal *graph.Node, isJSON bool) (mData, error
 	return md, nil
 }
 
+// matchAllRows checks that an update or delete without a where clause is
+// meant to change all the rows with 'all: true' and that the role can,
+// the rows are then matched on their primary key so that no dialect
+// renders an update or delete without a filter
+func (co *Compiler) matchAllRows(qc *QCode, sel *Select, role string) error {
+	if !qc.allArgs[sel.FieldName] {
+		return errors.New("where clause required, use 'all: true' to change all the rows")
+	}
+	tr := co.getRole(role, sel.Ti.Schema, sel.Ti.Name, sel.FieldName)
+	if !tr.isAllAllowed(qc.SType) {
+		return fmt.Errorf("all: %s of all the rows not allowed: %s (role: %s)",
+			qc.SType, sel.FieldName, role)
+	}
+	if sel.Ti.PrimaryCol.Name == "" {
+		return fmt.Errorf("all: no primary key column defined for '%s'", sel.Table)
+	}
+	if arg, ok := sel.GetInternalArg("return_before"); ok && arg.Val == "true" {
+		return errors.New("all: cannot be used with @returnBefore")
+	}
+
+	ex := newExpOp(OpIsNull)
+	ex.Left.Col = sel.Ti.PrimaryCol
+	ex.Right.ValType = ValBool
+	ex.Right.Val = "false"
+	sel.Where.Exp = ex
+	return nil
+}
+
+// isEmptyData returns true if the data of a mutation is an empty object
+func isEmptyData(n *graph.Node) bool {
+	return n != nil && n.Type == graph.NodeObj && len(n.Children) == 0
+}
+
 func parseMutationData(qc *QCode) (mData, error) {
 	var md mData
 	var err error
@@ -337,6 +497,14 @@ func parseMutationDataFromArg(qc *QCode, key string, vmap map[string]json.RawMes
 // the child path needs to be exluded in the json sent to insert or update
 
 func (co *Compiler) newMutate(ms *mState, m Mutate, role string) error {
+	if m.Ti.Type == "static" {
+		return fmt.Errorf("static table '%s' cannot be mutated", m.Ti.Name)
+	}
+
+	if err := co.checkRoleTable(role, m.Ti); err != nil {
+		return err
+	}
+
 	trv := co.getRole(role, m.Ti.Schema, m.Ti.Name, m.Key)
 	data := m.Data
 
@@ -453,6 +621,17 @@ func (co *Compiler) processNestedMutations(ms *mState, m *Mutate, data *graph.No
 			rel := sdata.PathToRel(paths[0])
 			ti := rel.Left.Ti
 
+			// On MongoDB a list replaces the whole embedded array, it is set
+			// on the parent like its other columns
+			if co.s.DBType() == "mongodb" && m.Type == MTUpdate &&
+				rel.Type == sdata.RelEmbedded && md.Data.Type == graph.NodeList {
+				if m.replace == nil {
+					m.replace = make(map[string]struct{})
+				}
+				m.replace[rel.Left.Col.Name] = struct{}{}
+				continue
+			}
+
 			if rel.Type != sdata.RelRecursive &&
 				ms.id == 1 && ti.Name == ms.qc.Selects[ms.rootSelID].Ti.Name {
 				return nil, fmt.Errorf("remove json root '%s' from '%s' data", k, ms.qc.SType)
@@ -547,6 +726,12 @@ func (co *Compiler) processDirectives(ms *mState, m *Mutate, data *graph.Node, t
 				return errors.New("missing argument: where")
 			}
 		}
+
+	// Filter on the elements of an embedded json array to update
+	case m.Type == MTUpdate && m.Rel.Type == sdata.RelEmbedded:
+		if v, ok := data.CMap["where"]; ok {
+			filterNode = v
+		}
 	}
 
 	if filterNode != nil {
@@ -673,9 +858,37 @@ func (co *Compiler) addTablesAndColumns(m *Mutate, items []Mutate, data *graph.N
 		return err
 	}
 
+	if m.Type == MTUpdate {
+		return co.setVersionColumn(m)
+	}
 	return nil
 }
 
+// setVersionColumn marks the version column of a table using optimistic
+// concurrency, the update must include the version it expects the row to have
+func (co *Compiler) setVersionColumn(m *Mutate) error {
+	vc := co.getTConfig(m.Ti.Schema, m.Ti.Name).VersionColumn
+	if vc == "" {
+		return nil
+	}
+
+	if m.ParentID != -1 {
+		return fmt.Errorf("%s: nested updates are not supported on a table with a version column", m.Ti.Name)
+	}
+
+	for i, col := range m.Cols {
+		if col.Col.Name != vc {
+			continue
+		}
+		if col.Set {
+			return fmt.Errorf("%s: version column '%s' cannot be set by a preset", m.Ti.Name, vc)
+		}
+		m.Cols[i].Version = true
+		return nil
+	}
+	return fmt.Errorf("%s: expected version required in column '%s'", m.Ti.Name, vc)
+}
+
 func (co *Compiler) getColumnsFromData(m *Mutate, data *graph.Node, trv trval, cm map[string]struct{}) ([]MColumn, error) {
 	var cols []MColumn
 
@@ -720,9 +933,13 @@ func (co *Compiler) getColumnsFromData(m *Mutate, data *graph.Node, trv trval, c
 	// but randomized maps in go make testing harder
 	// put this back in once we have integration testing
 
-	for k := range data.CMap {
-		k1 := k
-		k := co.ParseName(k)
+	// the columns are in the order of the fields of the data
+	for _, f := range data.Children {
+		if _, ok := data.CMap[f.Name]; !ok {
+			continue
+		}
+		k1 := f.Name
+		k := co.ParseName(k1)
 
 		if _, ok := cm[k]; ok {
 			continue
@@ -737,7 +954,8 @@ func (co *Compiler) getColumnsFromData(m *Mutate, data *graph.Node, trv trval, c
 			return nil, fmt.Errorf("column blocked: %s", k)
 		}
 
-		cols = append(cols, MColumn{Col: col, FieldName: k1, Alias: k})
+		_, replace := m.replace[k]
+		cols = append(cols, MColumn{Col: col, FieldName: k1, Alias: k, Replace: replace})
 	}
 
 	return cols, nil
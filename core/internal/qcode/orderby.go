@@ -2,6 +2,7 @@ package qcode
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/dosco/graphjin/core/v3/internal/graph"
 	"github.com/dosco/graphjin/core/v3/internal/sdata"
@@ -84,15 +85,29 @@ func (co *Compiler) compileArgOrderByObj(sel *Select, parent *graph.Node, cm map
 			}
 		}
 
-		if err = co.setOrderByColName(ti, &ob, cn); err != nil {
+		name := co.ParseName(cn.Name)
+
+		switch {
+		case node.Type != graph.NodeObj && name == "search_rank":
+			err = co.setOrderBySearchRank(sel, &ob)
+		case node.Type != graph.NodeObj && isCountOrder(ti, name):
+			err = co.setOrderByCount(sel, &ob, name)
+		default:
+			err = co.setOrderByColName(ti, &ob, cn)
+		}
+		if err != nil {
 			continue
 		}
 
-		if _, ok := cm[ob.Col.Name]; ok {
+		key := ob.Col.Name
+		if ob.Count {
+			key = "count_" + key
+		}
+		if _, ok := cm[key]; ok {
 			err = fmt.Errorf("can only be defined once")
 			continue
 		}
-		cm[ob.Col.Name] = struct{}{}
+		cm[key] = struct{}{}
 		obList = append(obList, ob)
 	}
 
@@ -137,6 +152,42 @@ func (co *Compiler) setOrderByColName(ti sdata.DBTable, ob *OrderBy, node *graph
 	return nil
 }
 
+// setOrderBySearchRank orders by the relevance of the full-text search, on
+// mongodb this is the text score of the $text search
+func (co *Compiler) setOrderBySearchRank(sel *Select, ob *OrderBy) error {
+	if co.s.DBType() != "mongodb" {
+		return fmt.Errorf("ordering by search_rank is only supported on mongodb")
+	}
+	ob.SearchRank = true
+	ob.Col = sdata.DBColumn{Name: "search_rank", Table: sel.Ti.Name}
+	return nil
+}
+
+// isCountOrder returns true if the name is a count_<column> that is not a
+// column of the table
+func isCountOrder(ti sdata.DBTable, name string) bool {
+	if !strings.HasPrefix(name, "count_") {
+		return false
+	}
+	_, ok := ti.ColumnExists(name)
+	return !ok
+}
+
+// setOrderByCount orders a grouped selector by the number of its documents,
+// on mongodb the groups are counted and ordered with a $sortByCount stage
+func (co *Compiler) setOrderByCount(sel *Select, ob *OrderBy, name string) error {
+	if co.s.DBType() != "mongodb" {
+		return fmt.Errorf("ordering by %s is only supported on mongodb", name)
+	}
+	col, err := sel.Ti.GetColumn(name[len("count_"):])
+	if err != nil {
+		return err
+	}
+	ob.Count = true
+	ob.Col = col
+	return nil
+}
+
 func compileOrderBy(sel *Select,
 	keyVar, key string,
 	values [][2]string,
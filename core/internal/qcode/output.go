@@ -0,0 +1,65 @@
+package qcode
+
+import (
+	"strings"
+
+	"github.com/dosco/graphjin/core/v3/internal/sdata"
+	"github.com/dosco/graphjin/core/v3/internal/util"
+)
+
+// setOutputNames renames the selectors and fields without an alias to the
+// output case. The hidden fields, the keys selected from inside json columns
+// and the fields of remote selectors, which match the remote response, keep
+// their names
+func (co *Compiler) setOutputNames(qc *QCode) {
+	if co.c.OutputCase == "" {
+		return
+	}
+
+	for i := range qc.Selects {
+		sel := &qc.Selects[i]
+
+		if name := co.outputName(sel.Field); name != sel.FieldName {
+			// the parent holds the key of the cross-database join under the
+			// name of the selector
+			if sel.SkipRender == SkipTypeDatabaseJoin && sel.ParentID != -1 {
+				psel := &qc.Selects[sel.ParentID]
+				if j := psel.fieldExists("__" + sel.FieldName + "_db_join"); j != -1 {
+					psel.Fields[j].FieldName = "__" + name + "_db_join"
+				}
+			}
+			sel.FieldName = name
+		}
+
+		if sel.Rel.Type == sdata.RelRemote {
+			continue
+		}
+
+		for j := range sel.Fields {
+			sel.Fields[j].FieldName = co.outputName(sel.Fields[j])
+		}
+		for j := range sel.Computed {
+			if cf := &sel.Computed[j]; !cf.aliased {
+				cf.FieldName = co.outputCase(cf.FieldName)
+			}
+		}
+	}
+}
+
+// outputName returns the name the field is returned under
+func (co *Compiler) outputName(f Field) string {
+	if f.aliased || strings.HasPrefix(f.FieldName, "__") {
+		return f.FieldName
+	}
+	return co.outputCase(f.FieldName)
+}
+
+func (co *Compiler) outputCase(name string) string {
+	switch co.c.OutputCase {
+	case OutputCaseCamel:
+		return util.ToCamel(name)
+	case OutputCaseSnake:
+		return util.ToSnake(name)
+	}
+	return name
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/dosco/graphjin/core/v3/internal/graph"
 	"github.com/dosco/graphjin/core/v3/internal/sdata"
@@ -55,6 +56,25 @@ const (
 	SkipTypeDatabaseJoin
 )
 
+// ChangeStream is how a MongoDB subscription delivers the changes
+// it watches for using a change stream
+type ChangeStream int8
+
+const (
+	ChangeStreamNone ChangeStream = iota
+	// ChangeStreamDocument delivers the changed document
+	ChangeStreamDocument
+	// ChangeStreamFields delivers only the fields changed by an update
+	ChangeStreamFields
+)
+
+// Materialize is the MongoDB collection the results of a root selector are
+// cached in, the results are read from it until they are older than the TTL
+type Materialize struct {
+	Collection string
+	TTL        int // seconds
+}
+
 type ColKey struct {
 	Name string
 	Base bool
@@ -75,12 +95,25 @@ type QCode struct {
 	MUnions   map[string][]int32
 	Schema    *sdata.DBSchema
 	Remotes   int32
+	Computed  int32
 	Cache     Cache
+	// Throttle is the minimum time between the results delivered to a
+	// subscriber, the results in between are coalesced into the latest
+	Throttle  time.Duration
 	Typename  bool
 	Query     []byte
 	Fragments []Fragment
+	// Namespace is the namespace the query was compiled in
+	Namespace  string
+	// UnknownFields are the paths of the fields that are not in the
+	// schema and were left out of the query
+	UnknownFields []string
 	actionArg  graph.Arg
 	actionArgs map[string]graph.Arg
+	// conflictArgs are the on_conflict arguments of the root inserts
+	conflictArgs map[string]graph.Arg
+	// allArgs are the roots of updates and deletes of all the rows
+	allArgs map[string]bool
 }
 
 type Fragment struct {
@@ -93,6 +126,32 @@ type Select struct {
 	Type       SelType
 	Singular   bool
 	Typename   bool
+	// Flatten merges the fields of a singular child into its parent
+	Flatten    bool
+	// Exists returns whether the relationship has a match instead of the match
+	Exists     bool
+	// KeepDuplicates returns a related row once for every row of the join
+	// table linking it instead of once
+	KeepDuplicates bool
+	// Collation is the locale used to sort and match strings ignoring case
+	Collation  string
+	// StaticRows are the rows of a static lookup table
+	StaticRows json.RawMessage
+	// Default is the document of a singular relationship that finds no row
+	Default map[string]json.RawMessage
+	// ChangeStream is set when the subscription watches a change stream
+	ChangeStream ChangeStream
+	// Materialize is set when the results are cached in a collection
+	Materialize Materialize
+	// Connection is set when the page info of the cursor pagination is
+	// returned, PageSize is the number of rows of a page
+	Connection bool
+	PageSize   int32
+	// WithDeleted includes the soft deleted rows of the table
+	WithDeleted bool
+	// SoftDelete is the filter leaving out the soft deleted rows, it is
+	// also part of Where
+	SoftDelete *Exp
 	Table      string
 	Schema     string
 	// Database is the target database for this select (multi-database support).
@@ -100,6 +159,7 @@ type Select struct {
 	Database   string
 	Fields     []Field
 	BCols      []Column
+	Computed   []ComputedField
 	IArgs      []Arg
 	Where      Filter
 	OrderBy    []OrderBy
@@ -147,6 +207,91 @@ type Field struct {
 	FieldFilter Filter
 	Args        []Arg
 	SkipRender  SkipType
+	Mask        Mask
+	ArrayProj   ArrayProj
+	// Compare is set for a boolean field computed from a comparison
+	Compare *Compare
+	// JSONPaths are the keys selected from inside a json column
+	JSONPaths []JSONPath
+	// Labels is set when the label of the value is returned (@label)
+	Labels *Labels
+	// DateDiff is set when the time from the date of the column to another
+	// date is returned (@dateDiff)
+	DateDiff *DateDiff
+	// aliased is set when the field has an alias, the alias is the output
+	// name whatever the output case
+	aliased bool
+}
+
+// DateDiff is the time from the date of a column to the date of EndCol or
+// to now when it is not set, in Unit
+type DateDiff struct {
+	Unit   string
+	EndCol string
+}
+
+// Labels maps the values of a column to labels, Default is the json label
+// of the values without one
+type Labels struct {
+	Cases   []Label
+	Default json.RawMessage
+}
+
+// Label is the label of a json value of a column
+type Label struct {
+	Val   json.RawMessage
+	Label string
+}
+
+// JSONPath is a key selected from inside a json column with a sub-selection
+// on the column (MongoDB only). Path is the dot separated path of the key
+// from the column and Paths holds the keys selected from inside it
+type JSONPath struct {
+	FieldName string
+	Path      string
+	Paths     []JSONPath
+}
+
+// Compare is a boolean field computed by comparing a column with a value
+// or another column, it is null when either side is null (MongoDB only)
+type Compare struct {
+	Op     string
+	Col    sdata.DBColumn
+	Val    json.RawMessage
+	ValCol sdata.DBColumn
+}
+
+type ArrayProjType int8
+
+const (
+	ArrayProjNone ArrayProjType = iota
+	ArrayProjSize
+	ArrayProjSlice
+	ArrayProjSum
+	ArrayProjAvg
+)
+
+// ArrayProj returns the size, a slice, the sum or the average of an array
+// column instead of the whole array (@size, @slice, @sum and @avg directives)
+type ArrayProj struct {
+	Type  ArrayProjType
+	Skip  int
+	Limit int
+}
+
+// ComputedDepPrefix prefixes the hidden fields added to fetch the
+// columns required by computed fields
+const ComputedDepPrefix = "__cf_"
+
+// ComputedField is a virtual field whose value is computed after
+// the query is executed from the other fields in the row
+type ComputedField struct {
+	Name      string
+	FieldName string
+	// Number of fields selected before this one, used to keep the query order
+	Pos int
+
+	aliased bool
 }
 
 type Column struct {
@@ -224,6 +369,11 @@ type OrderBy struct {
 	Col    sdata.DBColumn
 	Var    string
 	Order  Order
+	// SearchRank orders by the relevance of the full-text search
+	SearchRank bool
+	// Count orders a grouped selector by the number of documents in each
+	// group, Col is the column of the count_<column> function
+	Count bool
 }
 
 type PagingType int8
@@ -243,10 +393,19 @@ type Paging struct {
 	Cursor    bool
 	CursorVar string // "cursor" or "<fieldname>_cursor" for named cursor pagination
 	NoLimit   bool
+	WithTies  bool // include rows tied with the last row by the order key
+	Percent   bool // limit is a percentage of the rows
 }
 
+// Cache is the cache-control of the response combined from the @cacheControl
+// hints on the operation and its fields. The lowest max-age wins and the
+// response is private if any of the hints is.
 type Cache struct {
-	Header string
+	Header    string
+	MaxAge    int // seconds, only set when HasMaxAge is true
+	HasMaxAge bool
+	Private   bool
+	public    bool
 }
 
 type Var struct {
@@ -307,6 +466,10 @@ const (
 	OpGeoTouches    // ST_Touches - geometries touch at boundary
 	OpGeoOverlaps   // ST_Overlaps - geometries overlap
 	OpGeoNear       // MongoDB $near / $nearSphere
+
+	OpHasNull     // MongoDB array has a null element
+	OpSelectCount // MongoDB count of the related documents
+	OpArraySize   // MongoDB length of an array
 )
 
 type ValType int8
@@ -411,6 +574,26 @@ func NewCompiler(s *sdata.DBSchema, c Config) (*Compiler, error) {
 	c.defTrv.upsert.block = c.DefaultBlock
 	c.defTrv.delete.block = c.DefaultBlock
 
+	switch c.IdentifierCase {
+	case "", IdentCasePreserve, IdentCaseLower, IdentCaseSnake:
+	default:
+		return nil, fmt.Errorf("invalid identifier case '%s' (expected preserve, lower or snake)",
+			c.IdentifierCase)
+	}
+
+	switch c.OutputCase {
+	case "", OutputCaseCamel, OutputCaseSnake:
+	default:
+		return nil, fmt.Errorf("invalid output case '%s' (expected camel or snake)",
+			c.OutputCase)
+	}
+
+	for name := range c.Directives {
+		if _, ok := builtinDirectives[name]; ok {
+			return nil, fmt.Errorf("directive @%s: cannot replace a built-in directive", name)
+		}
+	}
+
 	return &Compiler{c: c, s: s, tr: make(map[string]trval)}, nil
 }
 
@@ -430,6 +613,7 @@ func (co *Compiler) Compile(
 		SType:     QTQuery,
 		Schema:    co.s,
 		Query:     op.Query,
+		Namespace: namespace,
 		Fragments: make([]Fragment, len(op.Frags)),
 		Vars:      make([]Var, len(op.VarDef)),
 	}
@@ -457,6 +641,8 @@ func (co *Compiler) Compile(
 			return
 		}
 	}
+
+	co.setOutputNames(qc)
 	return
 }
 
@@ -528,6 +714,7 @@ func (co *Compiler) compileQuery(qc *QCode, op *graph.Operation, role string) er
 
 		if field.Alias != "" {
 			sel.FieldName = field.Alias
+			sel.aliased = true
 		} else {
 			sel.FieldName = field.Name
 		}
@@ -553,6 +740,14 @@ func (co *Compiler) compileQuery(qc *QCode, op *graph.Operation, role string) er
 			return err
 		}
 
+		if err := co.checkMaxLimit(sel); err != nil {
+			return err
+		}
+
+		if err := co.compileCustomDirectives(sel, field.Directives, role); err != nil {
+			return err
+		}
+
 		if err := co.compileFields(st, op, qc, sel, field, tr, role); err != nil {
 			return err
 		}
@@ -562,6 +757,14 @@ func (co *Compiler) compileQuery(qc *QCode, op *graph.Operation, role string) er
 			sel.SkipRender = SkipTypeUserNeeded
 		}
 
+		// The soft deleted rows of the root of an update or delete are
+		// filtered out once it's checked that it has a where clause
+		if qc.Type != QTMutation || sel.ParentID != -1 {
+			if err := co.addSoftDeleteFilter(sel); err != nil {
+				return err
+			}
+		}
+
 		// If an actual cursor is available
 		if sel.Paging.Cursor {
 			// Set tie-breaker order column for the cursor direction
@@ -576,6 +779,12 @@ func (co *Compiler) compileQuery(qc *QCode, op *graph.Operation, role string) er
 			}
 		}
 
+		if sel.Connection {
+			if err := co.setConnection(qc, sel); err != nil {
+				return fmt.Errorf("directive @connection: %w", err)
+			}
+		}
+
 		// Compute and set the relevant where clause required to join
 		// this table with its parent
 		co.setRelFilters(qc, sel)
@@ -584,6 +793,10 @@ func (co *Compiler) compileQuery(qc *QCode, op *graph.Operation, role string) er
 			return err
 		}
 
+		if co.s.DBType() == "mongodb" {
+			sel.Collation = sel.tc.collation(sel)
+		}
+
 		qc.Selects = append(qc.Selects, s1)
 		id++
 	}
@@ -592,9 +805,188 @@ func (co *Compiler) compileQuery(qc *QCode, op *graph.Operation, role string) er
 		return errors.New("invalid query: no selectors found")
 	}
 
+	if err := co.validateChangeStreams(qc); err != nil {
+		return fmt.Errorf("directive @changeStream: %w", err)
+	}
+	if err := validateScalarCounts(qc); err != nil {
+		return fmt.Errorf("count: %w", err)
+	}
+	if err := co.validateMaterialize(qc, role); err != nil {
+		return fmt.Errorf("directive @materialize: %w", err)
+	}
+	if err := validateSelectCounts(qc); err != nil {
+		return fmt.Errorf("where _count: %w", err)
+	}
+	if err := co.checkMaxRelationships(qc, role); err != nil {
+		return err
+	}
+	return nil
+}
+
+// IsScalarCount returns true if the field is a count without a column
+func (f *Field) IsScalarCount() bool {
+	return f.Type == FieldTypeFunc && f.Func.Name == "count" && len(f.Args) == 0
+}
+
+// validateScalarCounts checks that a count without a column is the only
+// field of a root selector since its value replaces the selector
+func validateScalarCounts(qc *QCode) error {
+	for i := range qc.Selects {
+		sel := &qc.Selects[i]
+		var counts, fields int
+		for j := range sel.Fields {
+			f := &sel.Fields[j]
+			if f.IsScalarCount() {
+				counts++
+			}
+			// ignore the field added for cache tracking
+			if f.FieldName != "__gj_id" {
+				fields++
+			}
+		}
+		if counts == 0 {
+			continue
+		}
+		switch {
+		case qc.Type == QTMutation:
+			return fmt.Errorf("not supported in mutations")
+		case sel.ParentID != -1:
+			return fmt.Errorf("can only be used on a root selector")
+		case fields != 1 || len(sel.Children) != 0 || len(sel.Computed) != 0 || sel.Typename:
+			return fmt.Errorf("must be the only field of the selector")
+		}
+	}
+	return nil
+}
+
+// HasChangeStream returns true if the subscription watches a change stream
+func (qc *QCode) HasChangeStream() bool {
+	for _, id := range qc.Roots {
+		if qc.Selects[id].ChangeStream != ChangeStreamNone {
+			return true
+		}
+	}
+	return false
+}
+
+// Materialized returns true if the results of a root selector are cached
+// in a collection
+func (qc *QCode) Materialized() bool {
+	for _, id := range qc.Roots {
+		if qc.Selects[id].Materialize.Collection != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// validateChangeStreams checks that change streams are only watched by
+// subscriptions on a single collection
+func (co *Compiler) validateChangeStreams(qc *QCode) error {
+	for _, id := range qc.Roots {
+		sel := &qc.Selects[id]
+		if sel.ChangeStream == ChangeStreamNone {
+			continue
+		}
+		switch {
+		case co.s.DBType() != "mongodb":
+			return fmt.Errorf("only supported on mongodb")
+		case qc.Type != QTSubscription:
+			return fmt.Errorf("can only be used in a subscription")
+		case qc.Throttle != 0:
+			return fmt.Errorf("cannot be combined with @throttle")
+		case len(qc.Roots) != 1 || qc.Typename:
+			return fmt.Errorf("cannot be combined with other root selectors")
+		case len(sel.Children) != 0:
+			return fmt.Errorf("cannot be used with related selectors")
+		case len(sel.Computed) != 0:
+			return fmt.Errorf("cannot be used with computed fields")
+		}
+		// the changed values are delivered as is so columns
+		// that are computed or masked cannot be watched
+		for _, f := range sel.Fields {
+			switch {
+			case f.Type != FieldTypeCol:
+				return fmt.Errorf("cannot be used with functions: %s", f.FieldName)
+			case f.Mask.Type != MaskTypeNone || f.ArrayProj.Type != ArrayProjNone:
+				return fmt.Errorf("cannot be used with masked or projected columns: %s", f.FieldName)
+			case f.FieldFilter.Exp != nil:
+				return fmt.Errorf("cannot be used with conditional fields: %s", f.FieldName)
+			}
+		}
+	}
+	for i := range qc.Selects {
+		if sel := &qc.Selects[i]; sel.ParentID != -1 && sel.ChangeStream != ChangeStreamNone {
+			return fmt.Errorf("can only be used on a root selector")
+		}
+	}
+	return nil
+}
+
+// validateMaterialize checks that only the root selectors of
+// MongoDB queries are materialized and only in the collections
+// the role is allowed to use for the table
+func (co *Compiler) validateMaterialize(qc *QCode, role string) error {
+	for i := range qc.Selects {
+		sel := &qc.Selects[i]
+		if sel.Materialize.Collection == "" {
+			continue
+		}
+		switch {
+		case co.s.DBType() != "mongodb":
+			return fmt.Errorf("only supported on mongodb")
+		case qc.Type != QTQuery:
+			return fmt.Errorf("can only be used in a query")
+		case sel.ParentID != -1:
+			return fmt.Errorf("can only be used on a root selector")
+		case sel.Materialize.Collection == sel.Table:
+			return fmt.Errorf("collection must not be the collection queried")
+		}
+		tr := co.getRole(role, sel.Ti.Schema, sel.Ti.Name, sel.FieldName)
+		if !tr.materializeAllowed(sel.Materialize.Collection) {
+			return fmt.Errorf("%w: collection '%s' is not allowed for table '%s' and role '%s'",
+				ErrForbidden, sel.Materialize.Collection, sel.Table, role)
+		}
+	}
 	return nil
 }
 
+// validateSelectCounts checks that the count of related documents is
+// only compared in the filters of root selectors of a query
+func validateSelectCounts(qc *QCode) error {
+	for i := range qc.Selects {
+		sel := &qc.Selects[i]
+		if !sel.Where.Exp.hasOp(OpSelectCount) {
+			continue
+		}
+		switch {
+		case qc.Type == QTMutation:
+			return fmt.Errorf("not supported in mutations")
+		case sel.ChangeStream != ChangeStreamNone:
+			return fmt.Errorf("cannot be used with change streams")
+		case sel.ParentID != -1:
+			return fmt.Errorf("can only be used on a root selector")
+		}
+	}
+	return nil
+}
+
+// hasOp returns true if the expression or any of its children uses the operator
+func (ex *Exp) hasOp(op ExpOp) bool {
+	if ex == nil {
+		return false
+	}
+	if ex.Op == op {
+		return true
+	}
+	for _, c := range ex.Children {
+		if c.hasOp(op) {
+			return true
+		}
+	}
+	return false
+}
+
 func (co *Compiler) addRelInfo(
 	name string,
 	op *graph.Operation,
@@ -701,6 +1093,13 @@ func (co *Compiler) addRelInfo(
 	sel.Table = sel.Ti.Name
 	sel.tc = co.getTConfig(sel.Ti.Schema, sel.Ti.Name)
 
+	if sel.Ti.Type == "static" {
+		if sel.ParentID == -1 {
+			return fmt.Errorf("static table '%s' can only be selected through a relationship", name)
+		}
+		sel.StaticRows = sel.tc.StaticRows
+	}
+
 	if sel.Rel.Type == sdata.RelRemote {
 		sel.Table = name
 		qc.Remotes++
@@ -708,6 +1107,22 @@ func (co *Compiler) addRelInfo(
 	}
 
 	co.setSingular(name, sel)
+	return co.setDefault(sel)
+}
+
+// setDefault sets the default document of a relationship to a table that
+// has one configured, it's only used when the relationship is singular
+// (MongoDB only)
+func (co *Compiler) setDefault(sel *Select) error {
+	if sel.ParentID == -1 || len(sel.tc.Default) == 0 || co.s.DBType() != "mongodb" {
+		return nil
+	}
+	for k := range sel.tc.Default {
+		if _, err := sel.Ti.GetColumn(k); err != nil {
+			return fmt.Errorf("default: %w", err)
+		}
+	}
+	sel.Default = sel.tc.Default
 	return nil
 }
 
@@ -837,7 +1252,7 @@ func (co *Compiler) setRelFilters(qc *QCode, sel *Select) {
 }
 
 func (co *Compiler) Find(schema, name string) (sdata.DBTable, error) {
-	if co.c.EnableCamelcase {
+	if co.snakeNames() {
 		name = strings.TrimSuffix(name, singularSuffixSnake)
 	} else {
 		name = strings.TrimSuffix(name, singularSuffixCamel)
@@ -846,7 +1261,7 @@ func (co *Compiler) Find(schema, name string) (sdata.DBTable, error) {
 }
 
 func (co *Compiler) FindPath(from, to, through string) ([]sdata.TPath, error) {
-	if co.c.EnableCamelcase {
+	if co.snakeNames() {
 		from = strings.TrimSuffix(from, singularSuffixSnake)
 		to = strings.TrimSuffix(to, singularSuffixSnake)
 	} else {
@@ -912,6 +1327,10 @@ func (co *Compiler) setSingular(fieldName string, sel *Select) {
 func (co *Compiler) setSelectorRoleConfig(role, fieldName string, qc *QCode, sel *Select) (trval, error) {
 	tr := co.getRole(role, sel.Ti.Schema, sel.Ti.Name, fieldName)
 
+	if err := co.checkRoleTable(role, sel.Ti); err != nil {
+		return tr, err
+	}
+
 	if tr.isBlocked(qc.SType) {
 		if qc.SType != QTQuery {
 			return tr, fmt.Errorf("%s blocked: %s (role: %s)", qc.SType, fieldName, role)
@@ -929,6 +1348,10 @@ func (co *Compiler) setLimit(tr trval, qc *QCode, sel *Select) {
 	if l := tr.limit(qc.Type); l != 0 {
 		sel.Paging.Limit = l
 
+		// Else use default limit of the table
+	} else if sel.tc.DefaultLimit != 0 {
+		sel.Paging.Limit = sel.tc.DefaultLimit
+
 		// Else use default limit from config
 	} else if co.c.DefaultLimit != 0 {
 		sel.Paging.Limit = int32(co.c.DefaultLimit)
@@ -937,6 +1360,11 @@ func (co *Compiler) setLimit(tr trval, qc *QCode, sel *Select) {
 	} else {
 		sel.Paging.Limit = 20
 	}
+
+	// The default is never above the maximum limit of the table
+	if max := sel.tc.MaxLimit; max > 0 && sel.Paging.Limit > max {
+		sel.Paging.Limit = max
+	}
 }
 
 // This
@@ -1043,6 +1471,128 @@ func (co *Compiler) validateSelect(sel *Select) error {
 			return fmt.Errorf("valid values for 'find' are 'parents' and 'children'")
 		}
 	}
+
+	if sel.Paging.WithTies || sel.Paging.Percent {
+		if err := co.validateTopLimit(sel); err != nil {
+			return err
+		}
+	}
+
+	if sel.Flatten {
+		if err := co.validateFlatten(sel); err != nil {
+			return fmt.Errorf("directive @flatten: %w", err)
+		}
+	}
+
+	if sel.Exists {
+		if err := co.validateExists(sel); err != nil {
+			return fmt.Errorf("directive @exists: %w", err)
+		}
+	}
+
+	if err := validateSearchRankOrder(sel); err != nil {
+		return fmt.Errorf("order_by search_rank: %w", err)
+	}
+
+	if err := validateCountOrder(sel); err != nil {
+		return fmt.Errorf("order_by count: %w", err)
+	}
+	return nil
+}
+
+// validateCountOrder checks that a selector ordered by a count is an
+// aggregate at the query root
+func validateCountOrder(sel *Select) error {
+	var counted bool
+	for _, ob := range sel.OrderBy {
+		counted = counted || ob.Count
+	}
+	switch {
+	case !counted:
+		return nil
+	case sel.ParentID != -1:
+		return fmt.Errorf("can only be specified at the query root")
+	case sel.Paging.Cursor:
+		return fmt.Errorf("cannot be combined with cursor pagination")
+	case !sel.GroupCols:
+		return fmt.Errorf("the selector has no aggregate functions")
+	}
+	return nil
+}
+
+// validateSearchRankOrder checks that a selector ordered by the relevance
+// of the full-text search is a search at the query root
+func validateSearchRankOrder(sel *Select) error {
+	var ranked bool
+	for _, ob := range sel.OrderBy {
+		ranked = ranked || ob.SearchRank
+	}
+	switch {
+	case !ranked:
+		return nil
+	case sel.ParentID != -1:
+		return fmt.Errorf("can only be specified at the query root")
+	case sel.Paging.Cursor:
+		return fmt.Errorf("cannot be combined with cursor pagination")
+	}
+	if _, ok := sel.GetInternalArg("search"); !ok {
+		return fmt.Errorf("search argument not found")
+	}
+	return nil
+}
+
+// validateFlatten checks that the flattened selector is a singular relationship
+func (co *Compiler) validateFlatten(sel *Select) error {
+	switch {
+	case co.s.DBType() != "mongodb":
+		return fmt.Errorf("only supported on mongodb")
+	case sel.ParentID == -1 || sel.Rel.Type == sdata.RelNone:
+		return fmt.Errorf("can only be used on a related selector")
+	case sel.Rel.Type == sdata.RelPolymorphic || sel.Rel.Type == sdata.RelEmbedded ||
+		sel.Rel.Type == sdata.RelRecursive:
+		return fmt.Errorf("not supported on %s relationships", sel.Rel.Type)
+	case !sel.Singular:
+		return fmt.Errorf("can only be used on a singular relationship")
+	}
+	return nil
+}
+
+// validateExists checks that the selector whose existence is returned is a
+// relationship
+func (co *Compiler) validateExists(sel *Select) error {
+	switch {
+	case co.s.DBType() != "mongodb":
+		return fmt.Errorf("only supported on mongodb")
+	case sel.ParentID == -1 || sel.Rel.Type == sdata.RelNone:
+		return fmt.Errorf("can only be used on a related selector")
+	case sel.Rel.Type == sdata.RelPolymorphic || sel.Rel.Type == sdata.RelEmbedded ||
+		sel.Rel.Type == sdata.RelRecursive || sel.Rel.Type == sdata.RelRemote:
+		return fmt.Errorf("not supported on %s relationships", sel.Rel.Type)
+	case sel.Flatten:
+		return fmt.Errorf("cannot be combined with @flatten")
+	}
+	return nil
+}
+
+// validateTopLimit checks the 'with_ties' and 'percent' limits, these are
+// rendered as TOP (n) WITH TIES / TOP (n) PERCENT instead of OFFSET/FETCH
+func (co *Compiler) validateTopLimit(sel *Select) error {
+	switch {
+	case co.s.DBType() != "mssql":
+		return fmt.Errorf("arguments 'with_ties' and 'percent' are only supported on mssql")
+	case sel.ParentID != -1:
+		return fmt.Errorf("arguments 'with_ties' and 'percent' can only be specified at the query root")
+	case sel.Singular:
+		return fmt.Errorf("arguments 'with_ties' and 'percent' cannot be used with a singular selector")
+	case sel.Paging.Offset != 0 || sel.Paging.OffsetVar != "":
+		return fmt.Errorf("arguments 'with_ties' and 'percent' cannot be combined with 'offset'")
+	case sel.Paging.Cursor:
+		return fmt.Errorf("arguments 'with_ties' and 'percent' cannot be combined with cursor pagination")
+	case len(sel.OrderBy) == 0:
+		return fmt.Errorf("arguments 'with_ties' and 'percent' require 'order_by'")
+	case sel.Paging.Percent && sel.Paging.LimitVar == "" && sel.Paging.Limit > 100:
+		return fmt.Errorf("argument 'limit' must be between 0 and 100 when 'percent' is set")
+	}
 	return nil
 }
 
@@ -1086,13 +1636,23 @@ func (co *Compiler) setMutationType(qc *QCode, op *graph.Operation, role string)
 	}
 
 	qc.actionArgs = make(map[string]graph.Arg, len(rootFields))
+	qc.conflictArgs = make(map[string]graph.Arg)
+	qc.allArgs = make(map[string]bool)
 
 	for ri, rf := range rootFields {
 		var fieldType QType
-		var actionArg graph.Arg
+		var actionArg, conflictArg graph.Arg
+		var all bool
 
 		for _, arg := range rf.Args {
 			switch arg.Name {
+			case "onConflict", "on_conflict":
+				conflictArg = arg
+			case "all":
+				if ifNotArg(arg, graph.NodeBool) || ifNotArgVal(arg, "true") {
+					err = errors.New("value for 'all' must be 'true'")
+				}
+				all = true
 			case "insert":
 				fieldType = QTInsert
 				actionArg = arg
@@ -1137,6 +1697,20 @@ func (co *Compiler) setMutationType(qc *QCode, op *graph.Operation, role string)
 			key = rf.Name
 		}
 		qc.actionArgs[key] = actionArg
+
+		if all {
+			if fieldType != QTUpdate && fieldType != QTDelete {
+				return errors.New("all: can only be used with update or delete")
+			}
+			qc.allArgs[key] = true
+		}
+
+		if conflictArg.Val != nil {
+			if fieldType != QTInsert {
+				return errors.New("on_conflict: can only be used with insert")
+			}
+			qc.conflictArgs[key] = conflictArg
+		}
 	}
 
 	return nil
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/dosco/graphjin/core/v3/internal/qcode"
 	"github.com/dosco/graphjin/core/v3/internal/sdata"
@@ -262,6 +263,103 @@ func TestFragmentsCompile3(t *testing.T) {
 	}
 }
 
+func TestCacheControl(t *testing.T) {
+	qcc, _ := qcode.NewCompiler(dbs, qcode.Config{})
+
+	tests := []struct {
+		gql    string
+		header string
+	}{
+		{`query @cacheControl(maxAge: 60) { products { id } }`, "max-age=60"},
+		{`query @cacheControl(maxAge: 60, scope: "public") { products { id } }`, "max-age=60, public"},
+		// the lowest max-age wins
+		{`query @cacheControl(maxAge: 60) {
+			products @cacheControl(maxAge: 30) { id name @cacheControl(maxAge: 10) }
+			users @cacheControl(maxAge: 20) { id }
+		}`, "max-age=10"},
+		// any private hint makes the response private
+		{`query @cacheControl(maxAge: 60, scope: "public") {
+			products { id }
+			users { id email @cacheControl(scope: "PRIVATE") }
+		}`, "max-age=60, private"},
+		{`query { products { id } }`, ""},
+	}
+
+	for _, tt := range tests {
+		qc, err := qcc.Compile([]byte(tt.gql), nil, "user", "")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.gql, err)
+		}
+		if qc.Cache.Header != tt.header {
+			t.Errorf("%s: expected header '%s', got '%s'", tt.gql, tt.header, qc.Cache.Header)
+		}
+	}
+
+	invalid := []string{
+		`query { products @cacheControl { id } }`,
+		`query { products @cacheControl(maxAge: -1) { id } }`,
+		`query { products @cacheControl(scope: "shared") { id } }`,
+	}
+	for _, gql := range invalid {
+		if _, err := qcc.Compile([]byte(gql), nil, "user", ""); err == nil {
+			t.Errorf("expected an error: %s", gql)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	qcc, _ := qcode.NewCompiler(dbs, qcode.Config{})
+
+	qc, err := qcc.Compile([]byte(`subscription @throttle(ms: 500) { products { id } }`), nil, "user", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qc.Throttle != 500*time.Millisecond {
+		t.Errorf("expected a throttle of 500ms, got %s", qc.Throttle)
+	}
+
+	invalid := []string{
+		`query @throttle(ms: 500) { products { id } }`,
+		`subscription @throttle { products { id } }`,
+		`subscription @throttle(ms: -1) { products { id } }`,
+		`subscription @throttle(seconds: 1) { products { id } }`,
+	}
+	for _, gql := range invalid {
+		if _, err := qcc.Compile([]byte(gql), nil, "user", ""); err == nil {
+			t.Errorf("expected an error: %s", gql)
+		}
+	}
+}
+
+func TestMaxRelationships(t *testing.T) {
+	qcc, _ := qcode.NewCompiler(dbs, qcode.Config{
+		MaxRelationships:     1,
+		RoleMaxRelationships: map[string]int{"admin": 5},
+	})
+
+	if _, err := qcc.Compile([]byte(`query { users { id products { id } } }`), nil, "user", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// the purchases of a user also count the customers join table
+	gql := `query { users { id purchases { id } } }`
+	_, err := qcc.Compile([]byte(gql), nil, "user", "")
+	if err == nil || err.Error() != "too many relationships: 'users.purchases' exceeds the maximum of 1 per query" {
+		t.Fatalf("expected a relationship limit error, got: %v", err)
+	}
+
+	// sibling relationships count too
+	_, err = qcc.Compile([]byte(`query { products { id user { id } customers { id } } }`), nil, "user", "")
+	if err == nil {
+		t.Fatal("expected a relationship limit error")
+	}
+
+	// the role overrides the maximum
+	if _, err := qcc.Compile([]byte(gql), nil, "admin", ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
 var gql = []byte(`
 	{products(
 		# returns only 30 items
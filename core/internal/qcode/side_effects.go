@@ -0,0 +1,28 @@
+package qcode
+
+import "fmt"
+
+// setSideEffects sets the side effects of the tables of the mutations,
+// they only run after the insert, update or delete of a single document
+// so the other mutations of a table with side effects fail
+func (co *Compiler) setSideEffects(qc *QCode) error {
+	for i := range qc.Mutates {
+		m := &qc.Mutates[i]
+		if m.Type == MTNone {
+			continue
+		}
+		tc := co.getTConfig(m.Ti.Schema, m.Ti.Name)
+		if len(tc.SideEffects) == 0 {
+			continue
+		}
+		// bulk inserts and upserts, nested mutations and the changes of
+		// all the rows are refused instead of skipping the side effects
+		if len(qc.Mutates) != 1 || m.Type == MTUpsert || m.All ||
+			m.Array || m.OnConflict != nil {
+			return fmt.Errorf("side effects of '%s': only run after the insert, update or delete of a single document",
+				m.Ti.Name)
+		}
+		m.SideEffects = tc.sideEffects(m.Type)
+	}
+	return nil
+}
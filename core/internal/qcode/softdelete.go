@@ -0,0 +1,85 @@
+package qcode
+
+import (
+	"fmt"
+
+	"github.com/dosco/graphjin/core/v3/internal/graph"
+)
+
+// compileArgWithDeleted compiles the 'with_deleted' argument that includes
+// the soft deleted rows, it's only valid on a table with a soft-delete column
+func (co *Compiler) compileArgWithDeleted(sel *Select, arg graph.Arg) error {
+	if sel.tc.SoftDeleteColumn == "" {
+		return fmt.Errorf("table '%s' has no soft-delete column", sel.Table)
+	}
+	return co.compileArgBool(arg, &sel.WithDeleted)
+}
+
+// addSoftDeleteFilter filters out the soft deleted rows of the table unless
+// the selector asked for them, the filter is added like the role filters
+// so it can't be removed by the client
+func (co *Compiler) addSoftDeleteFilter(sel *Select) error {
+	ex, err := softDeleteFilter(sel)
+	if ex != nil {
+		addAndFilter(&sel.Where, ex)
+		sel.SoftDelete = ex
+	}
+	return err
+}
+
+// softDeleteFilter returns the filter that leaves out the soft deleted rows
+// or nil if the table has no soft-delete column
+func softDeleteFilter(sel *Select) (*Exp, error) {
+	if sel.tc.SoftDeleteColumn == "" || sel.WithDeleted {
+		return nil, nil
+	}
+	col, err := sel.Ti.GetColumn(sel.tc.SoftDeleteColumn)
+	if err != nil {
+		return nil, fmt.Errorf("soft-delete %w", err)
+	}
+
+	ex := newExpOp(OpIsNull)
+	ex.Left.Col = col
+	ex.Right.ValType = ValBool
+	ex.Right.Val = "true"
+	return ex, nil
+}
+
+// setSoftDelete turns the delete of a table with a soft-delete column into
+// setting the column to the current time. The rows already deleted are only
+// left out of the filter of the mutation (Mutate.Where) and not of the root
+// selector so that the deleted rows are still returned.
+func (co *Compiler) setSoftDelete(m *Mutate, sel *Select) error {
+	if sel.tc.SoftDeleteColumn == "" {
+		return nil
+	}
+	col, err := m.Ti.GetColumn(sel.tc.SoftDeleteColumn)
+	if err != nil {
+		return fmt.Errorf("soft-delete %w", err)
+	}
+	m.Cols = []MColumn{{
+		Col:        col,
+		FieldName:  col.Name,
+		Value:      "sql:CURRENT_TIMESTAMP",
+		Set:        true,
+		SoftDelete: true,
+	}}
+
+	m.Where.Exp = sel.Where.Exp
+	ex, err := softDeleteFilter(sel)
+	if ex != nil {
+		addAndFilter(&m.Where, ex)
+	}
+	return err
+}
+
+// SoftDeleteCol returns the soft-delete column of the delete or nil
+// if the row is deleted
+func (m *Mutate) SoftDeleteCol() *MColumn {
+	for i := range m.Cols {
+		if m.Cols[i].SoftDelete {
+			return &m.Cols[i]
+		}
+	}
+	return nil
+}
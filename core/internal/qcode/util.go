@@ -2,18 +2,44 @@ package qcode
 
 import (
 	"bytes"
+	"strings"
 
 	"github.com/dosco/graphjin/core/v3/internal/graph"
 	"github.com/dosco/graphjin/core/v3/internal/util"
 )
 
+const (
+	IdentCasePreserve = "preserve"
+	IdentCaseLower    = "lower"
+	IdentCaseSnake    = "snake"
+)
+
+const (
+	OutputCaseCamel = "camel"
+	OutputCaseSnake = "snake"
+)
+
+// ParseName maps a field name to the database identifier using the identifier
+// case policy, without a policy camel case names are converted to snake case
+// when camel case is enabled
 func (co *Compiler) ParseName(name string) string {
-	if co.c.EnableCamelcase {
+	switch {
+	case co.c.IdentifierCase == IdentCaseLower:
+		return strings.ToLower(name)
+	case co.snakeNames():
 		return util.ToSnake(name)
 	}
 	return name
 }
 
+// snakeNames returns true if field names are converted to snake case
+func (co *Compiler) snakeNames() bool {
+	if co.c.IdentifierCase == "" {
+		return co.c.EnableCamelcase
+	}
+	return co.c.IdentifierCase == IdentCaseSnake
+}
+
 func GetQType(t graph.ParserType) QType {
 	switch t {
 	case graph.OpQuery:
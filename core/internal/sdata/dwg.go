@@ -164,6 +164,12 @@ func (s *DBSchema) addToGraph(
 		}
 	}
 
+	s.rels = append(s.rels, DBRel{
+		Type:  rt,
+		Left:  DBRelLeft{Ti: lti, Col: lcol},
+		Right: DBRelRight{Ti: rti, Col: rcol},
+	})
+
 	// fmt.Printf("1. (%s, %d) %s.%s (%d) -> %s.%s (%d) == %s\n", lti.Name, e1.ID(), lti.Name, lcol.Name, ln.ID(), rti.Name, rcol.Name, rn.ID(), rt.String())
 	// fmt.Printf("2. (%s, %d) %s.%s (%d) -> %s.%s (%d) == %s\n", rti.Name, e2.ID(), rti.Name, rcol.Name, rn.ID(), lti.Name, lcol.Name, ln.ID(), rt2.String())
 	// fmt.Printf("3. (%s, %d) %s.%s (%d) -> %s.%s (%d) == %s\n", relT, e2.ID(), rti.Name, rcol.Name, rn.ID(), lti.Name, lcol.Name, ln.ID(), rt2.String())
@@ -30,6 +30,7 @@ type DBSchema struct {
 	tableAliasIndex   map[string]nodeInfo     // table alias index
 	edgesIndex        map[string][]edgeInfo   // edges index
 	allEdges          map[int32]TEdge         // all edges
+	rels              []DBRel                 // relationships in the order added
 	relationshipGraph *util.Graph             // relationship graph
 }
 
@@ -331,6 +332,12 @@ func (s *DBSchema) GetTables() []DBTable {
 	return s.tables
 }
 
+// GetRelationships returns the relationships between the tables, each
+// relationship is returned once from the table holding the join column
+func (s *DBSchema) GetRelationships() []DBRel {
+	return s.rels
+}
+
 // RelNode represents a relationship node
 type RelNode struct {
 	Name  string
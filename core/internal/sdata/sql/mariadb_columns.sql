@@ -36,7 +36,8 @@ SELECT col.table_schema as "schema",
 	) AS full_text,
 	'' AS foreignkey_schema,
 	'' AS foreignkey_table,
-	'' AS foreignkey_column
+	'' AS foreignkey_column,
+	COALESCE(NULLIF(col.column_default, 'NULL'), '') AS column_default
 FROM information_schema.columns col
 	LEFT JOIN information_schema.statistics stat ON col.table_schema = stat.table_schema
 	AND col.table_name = stat.table_name
@@ -86,7 +87,8 @@ SELECT kcu.table_schema as "schema",
 			WHEN tc.constraint_type = 'FOREIGN KEY' THEN kcu.referenced_column_name
 			ELSE ''
 		END
-	) AS foreignkey_column
+	) AS foreignkey_column,
+	'' AS column_default
 FROM information_schema.key_column_usage kcu
 	JOIN information_schema.table_constraints tc ON kcu.table_schema = tc.table_schema
 	AND kcu.table_name = tc.table_name
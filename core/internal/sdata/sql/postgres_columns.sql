@@ -76,7 +76,8 @@ SELECT n.nspname as "schema",
 			)
 			ELSE ''::text
 		END
-	) AS foreignkey_column
+	) AS foreignkey_column,
+	COALESCE(pg_get_expr(d.adbin, d.adrelid), '') AS column_default
 FROM pg_attribute f
 	JOIN pg_class c ON c.oid = f.attrelid
 	LEFT JOIN pg_attrdef d ON d.adrelid = c.oid
@@ -22,7 +22,8 @@ SELECT
   ) as full_text,
   'main' as foreignkey_schema,
   COALESCE(fk."table", '') as foreignkey_table,
-  COALESCE(fk."to", '') as foreignkey_column
+  COALESCE(fk."to", '') as foreignkey_column,
+  COALESCE(p.dflt_value, '') as column_default
 FROM sqlite_master m
 JOIN pragma_table_info(m.name) p
 LEFT JOIN pragma_foreign_key_list(m.name) fk ON fk."from" = p.name
@@ -293,6 +293,7 @@ type DBColumn struct {
 	PrimaryKey  bool
 	UniqueKey   bool
 	FullText    bool
+	ObjectID    bool // MongoDB ObjectId, string values are converted to ObjectIds
 	FKRecursive bool
 	FKeySchema  string
 	FKeyTable   string
@@ -301,11 +302,14 @@ type DBColumn struct {
 	Table       string
 	Schema      string
 	Database    string
-	Default     string
+	Default     string // SQL expression or on MongoDB the JSON value set on insert
 	Index       bool
 	IndexName   string
 	FKOnDelete  string
 	FKOnUpdate  string
+	// FKArraySide is the side of the relationship that holds the array of
+	// ids: "column", "related" or "none", when empty it's inferred from Array
+	FKArraySide string
 
 	// Original names before normalization (used to build dialect name maps for MSSQL)
 	OrigTable      string
@@ -355,6 +359,13 @@ func DiscoverColumns(db *sql.DB, dbtype string, blockList []string, schemas []st
 	}
 	defer rows.Close()
 
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	// the columns query of some databases doesn't return the default
+	hasDefault := len(names) > 12
+
 	cmap := make(map[string]DBColumn)
 
 	i := 0
@@ -365,7 +376,7 @@ func DiscoverColumns(db *sql.DB, dbtype string, blockList []string, schemas []st
 		var c DBColumn
 		c.ID = int32(i)
 
-		err = rows.Scan(&c.Schema,
+		dest := []interface{}{&c.Schema,
 			&c.Table,
 			&c.Name,
 			&c.Type,
@@ -376,7 +387,11 @@ func DiscoverColumns(db *sql.DB, dbtype string, blockList []string, schemas []st
 			&c.FullText,
 			&c.FKeySchema,
 			&c.FKeyTable,
-			&c.FKeyCol)
+			&c.FKeyCol}
+		if hasDefault {
+			dest = append(dest, &c.Default)
+		}
+		err = rows.Scan(dest...)
 
 		c.FKeySchema = strings.TrimSpace(c.FKeySchema)
 		c.FKeyTable = strings.TrimSpace(c.FKeyTable)
@@ -443,6 +458,9 @@ func DiscoverColumns(db *sql.DB, dbtype string, blockList []string, schemas []st
 		if c.FKeyCol != "" {
 			v.FKeyCol = c.FKeyCol
 		}
+		if c.Default != "" {
+			v.Default = c.Default
+		}
 		if v.FKeySchema == v.Schema && v.FKeyTable == v.Table {
 			v.FKRecursive = true
 		}
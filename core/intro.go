@@ -1,12 +1,15 @@
 package core
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/dosco/graphjin/core/v3/internal/graph"
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
 	"github.com/dosco/graphjin/core/v3/internal/sdata"
 	"github.com/dosco/graphjin/core/v3/internal/util"
 	"github.com/dosco/graphjin/core/v3/internal/valid"
@@ -106,6 +109,9 @@ type IntroResult struct {
 	Schema IntrospectionSchema `json:"__schema"`
 }
 
+// defaultDeprecationReason is the reason of a deprecated field that has none
+const defaultDeprecationReason = "No longer supported"
+
 // const singularSuffix = "ByID"
 
 var stdTypes = []FullType{
@@ -196,6 +202,7 @@ type Introspection struct {
 	types       map[string]FullType
 	enumValues  map[string]EnumValue
 	inputValues map[string]InputValue
+	tmap        map[string]qcode.TConfig
 	result      IntroResult
 }
 
@@ -207,6 +214,7 @@ func (gj *graphjinEngine) introQuery() (result json.RawMessage, err error) {
 		types:       make(map[string]FullType),
 		enumValues:  make(map[string]EnumValue),
 		inputValues: make(map[string]InputValue),
+		tmap:        gj.tmap,
 	}
 
 	// Initialize the schema
@@ -294,6 +302,13 @@ func (gj *graphjinEngine) introQuery() (result json.RawMessage, err error) {
 	for _, dt := range dirTypes {
 		in.addDirType(dt)
 	}
+	for _, name := range gj.directiveNames() {
+		in.addDirType(dir{
+			name: name,
+			desc: "Custom directive",
+			locs: []string{LOC_FIELD},
+		})
+	}
 	in.addDirValidateType()
 
 	// Add the types to the schema (sorted for determinism)
@@ -310,6 +325,94 @@ func (gj *graphjinEngine) introQuery() (result json.RawMessage, err error) {
 	return
 }
 
+// introTypeResult returns the result of an introspection query that selects
+// __type, it is resolved to the type named by its name argument or null.
+// Other introspection queries get the whole schema
+func (gj *graphjinEngine) introTypeResult(r GraphqlReq) (json.RawMessage, error) {
+	data, err := gj.getIntroResult()
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := graph.Parse(r.query)
+	if err != nil {
+		return data, nil
+	}
+	var hasType bool
+	for _, f := range op.Fields {
+		if f.ParentID == -1 && f.Name == "__type" {
+			hasType = true
+		}
+	}
+	if !hasType {
+		return data, nil
+	}
+
+	var ir IntroResult
+	if err := json.Unmarshal(data, &ir); err != nil {
+		return nil, err
+	}
+	var vars map[string]json.RawMessage
+	if len(r.vars) != 0 {
+		if err := json.Unmarshal(r.vars, &vars); err != nil {
+			return nil, err
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`{`)
+	for _, f := range op.Fields {
+		if f.ParentID != -1 || (f.Name != "__type" && f.Name != "__schema") {
+			continue
+		}
+		if b.Len() > 1 {
+			b.WriteString(`,`)
+		}
+		key := f.Name
+		if f.Alias != "" {
+			key = f.Alias
+		}
+		b.WriteString(strconv.Quote(key))
+		b.WriteString(`:`)
+
+		var v any = ir.Schema
+		if f.Name == "__type" {
+			v = ir.Schema.findType(introTypeName(f, vars))
+		}
+		vb, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(vb)
+	}
+	b.WriteString(`}`)
+	return b.Bytes(), nil
+}
+
+// introTypeName returns the value of the name argument of a __type field
+func introTypeName(f graph.Field, vars map[string]json.RawMessage) (name string) {
+	for _, a := range f.Args {
+		if a.Name != "name" || a.Val == nil {
+			continue
+		}
+		if a.Val.Type != graph.NodeVar {
+			return a.Val.Val
+		}
+		_ = json.Unmarshal(vars[a.Val.Val], &name)
+	}
+	return
+}
+
+// findType returns the type with the name or nil if there is none
+func (s IntrospectionSchema) findType(name string) *FullType {
+	for i := range s.Types {
+		if s.Types[i].Name == name {
+			return &s.Types[i]
+		}
+	}
+	return nil
+}
+
 // addTable adds a table to the introspection schema
 func (in *Introspection) addTable(table sdata.DBTable, alias string) (err error) {
 	if table.Blocked || len(table.Columns) == 0 {
@@ -483,6 +586,11 @@ func (in *Introspection) addTableTypeWithDepth(
 	ft.addArg("id", newTypeRef("", "ID", nil))
 	ft.addArg("limit", newTypeRef("", "Int", nil))
 	ft.addArg("offset", newTypeRef("", "Int", nil))
+	// only mssql supports TOP ... WITH TIES and PERCENT
+	if in.schema.DBType() == "mssql" {
+		ft.addArg("withTies", newTypeRef("", "Boolean", nil))
+		ft.addArg("percent", newTypeRef("", "Boolean", nil))
+	}
 	ft.addArg("distinctOn", newTypeRef("LIST", "", newTypeRef("", "String", nil)))
 	ft.addArg("first", newTypeRef("", "Int", nil))
 	ft.addArg("last", newTypeRef("", "Int", nil))
@@ -497,6 +605,10 @@ func (in *Introspection) addTableTypeWithDepth(
 		ft.addArg("search", newTypeRef("", "String", nil))
 	}
 
+	if in.tmap[table.Schema+table.Name].SoftDeleteColumn != "" {
+		ft.addArg("withDeleted", newTypeRef("", "Boolean", nil))
+	}
+
 	if depth > 1 {
 		return
 	}
@@ -656,7 +768,7 @@ func (in *Introspection) addInputType(table sdata.DBTable, ft FullType) (retFT F
 		ft1 := getTypeFromColumn(c)
 		ty.InputFields = append(ty.InputFields, InputValue{
 			Name:        in.getName(c.Name),
-			Description: c.Comment,
+			Description: inputDescription(c),
 			Type:        newTypeRef("", ft1, nil),
 		})
 	}
@@ -779,6 +891,18 @@ func (in *Introspection) addArgsType(table sdata.DBTable, fn sdata.DBFunction) (
 	return
 }
 
+// inputDescription returns the description of the input field of a column,
+// a column with a default can be omitted on insert
+func inputDescription(c sdata.DBColumn) string {
+	if c.Default == "" {
+		return c.Comment
+	}
+	if c.Comment == "" {
+		return "Default: " + c.Default
+	}
+	return c.Comment + "\nDefault: " + c.Default
+}
+
 // getColumnField returns the field object for the given column
 func (in *Introspection) getColumnField(column sdata.DBColumn) (field FieldObject, err error) {
 	field.Args = []InputValue{}
@@ -800,6 +924,11 @@ func (in *Introspection) getColumnField(column sdata.DBColumn) (field FieldObjec
 
 	field.Type = typeValue
 
+	if reason, ok := in.tmap[column.Schema+column.Table].Deprecated[column.Name]; ok {
+		field.IsDeprecated = true
+		field.DeprecationReason = &reason
+	}
+
 	field.Args = append(field.Args, InputValue{
 		Name: "includeIf", Type: newTypeRef("", (column.Table + SUFFIX_WHERE), nil),
 	})
@@ -0,0 +1,97 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestIntrospectionColumnDefaults(t *testing.T) {
+	db := newTestDB(t, "coldefaults")
+
+	_, err := db.Exec(`
+		CREATE TABLE orders (
+			id INTEGER PRIMARY KEY,
+			status TEXT NOT NULL DEFAULT 'pending',
+			note TEXT
+		);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query {
+		__type(name: "insertordersInput") {
+			inputFields { name description }
+		}
+	}`
+	res, err := gj.GraphQL(context.Background(), gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data struct {
+		Type struct {
+			InputFields []struct {
+				Name        string
+				Description string
+			}
+		} `json:"__type"`
+	}
+	if err := json.Unmarshal(res.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	desc := map[string]string{}
+	for _, f := range data.Type.InputFields {
+		desc[f.Name] = f.Description
+	}
+	if desc["status"] != "Default: 'pending'" || desc["note"] != "" {
+		t.Fatalf("unexpected input fields: %s", res.Data)
+	}
+
+	ts, err := gj.GetTableSchema("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range ts.Columns {
+		if c.Name == "status" && c.Default != "'pending'" {
+			t.Fatalf("expected the default of status, got: %q", c.Default)
+		}
+		if c.Name == "note" && c.Default != "" {
+			t.Fatalf("expected no default for note, got: %q", c.Default)
+		}
+	}
+
+	// the inserted row gets the default of the omitted column
+	res, err = gj.GraphQL(context.Background(),
+		`mutation { orders(insert: { id: 1, note: "a" }) { id status } }`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Data) != `{"orders":[{"id":1,"status":"pending"}]}` {
+		t.Fatalf("unexpected data: %s", res.Data)
+	}
+
+	// defaults in the config are only supported on mongodb
+	conf = &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		Tables: []core.Table{{
+			Name:    "orders",
+			Columns: []core.Column{{Name: "note", Default: "none"}},
+		}},
+	}
+	_, err = core.NewGraphJin(conf, db)
+	if err == nil || !strings.Contains(err.Error(), "only supported on mongodb") {
+		t.Fatalf("expected an error for the default, got: %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestIntrospectionDeprecatedFields(t *testing.T) {
+	db := newTestDB(t, "deprecated")
+
+	conf := &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		Tables: []core.Table{{
+			Name: "users",
+			Columns: []core.Column{
+				{Name: "full_name", Deprecated: true, DeprecationReason: "Use name instead"},
+				{Name: "email", Deprecated: true},
+			},
+		}},
+	}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query ($name: String!) {
+		users: __type(name: $name) {
+			name
+			fields(includeDeprecated: true) { name isDeprecated deprecationReason }
+		}
+		missing: __type(name: "nope") { name }
+	}`
+	res, err := gj.GraphQL(context.Background(), gql, json.RawMessage(`{"name":"users"}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data struct {
+		Users struct {
+			Name   string
+			Fields []struct {
+				Name              string
+				IsDeprecated      bool
+				DeprecationReason *string
+			}
+		}
+		Missing *struct{}
+	}
+	if err := json.Unmarshal(res.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Users.Name != "users" || data.Missing != nil {
+		t.Fatalf("unexpected types: %s", res.Data)
+	}
+
+	reasons := map[string]string{}
+	for _, f := range data.Users.Fields {
+		if f.IsDeprecated && f.DeprecationReason != nil {
+			reasons[f.Name] = *f.DeprecationReason
+		}
+	}
+	if len(reasons) != 2 ||
+		reasons["full_name"] != "Use name instead" ||
+		reasons["email"] != "No longer supported" {
+		t.Fatalf("unexpected deprecated fields: %v", reasons)
+	}
+
+	// deprecated fields can still be queried
+	res, err = gj.GraphQL(context.Background(), `query { users(id: 1) { full_name } }`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Data) != `{"users":{"full_name":"User One"}}` {
+		t.Fatalf("unexpected data: %s", res.Data)
+	}
+}
@@ -216,3 +216,64 @@ func TestIntrospectionIncludesBothOperatorFormats(t *testing.T) {
 		}
 	}
 }
+
+func TestIntrospectionWithTiesOnlyForMSSQL(t *testing.T) {
+	for _, dbType := range []string{"postgres", "mssql"} {
+		di := sdata.GetTestDBInfo()
+		di.Type = dbType
+		schema, err := sdata.NewDBSchema(di, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gj := &graphjinEngine{
+			conf:      &Config{DBType: dbType},
+			roles:     make(map[string]*Role),
+			defaultDB: "default",
+			databases: map[string]*dbContext{
+				"default": {name: "default", schema: schema},
+			},
+		}
+
+		result, err := gj.introQuery()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var introResult IntroResult
+		if err := json.Unmarshal(result, &introResult); err != nil {
+			t.Fatal(err)
+		}
+
+		var args []InputValue
+		for _, typ := range introResult.Schema.Types {
+			if typ.Name != "Query" {
+				continue
+			}
+			for _, f := range typ.Fields {
+				if f.Name == "users" {
+					args = f.Args
+				}
+			}
+		}
+		if len(args) == 0 {
+			t.Fatalf("%s: users query field not found", dbType)
+		}
+
+		var hasWithTies, hasPercent bool
+		for _, a := range args {
+			switch a.Name {
+			case "withTies":
+				hasWithTies = true
+			case "percent":
+				hasPercent = true
+			}
+		}
+
+		want := dbType == "mssql"
+		if hasWithTies != want || hasPercent != want {
+			t.Errorf("%s: expected withTies and percent args to be %v, got %v and %v",
+				dbType, want, hasWithTies, hasPercent)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+	"github.com/dosco/graphjin/core/v3/internal/sdata"
+)
+
+// ErrTooManyRows is returned when a response has more rows than the
+// maximum number of rows allowed per response
+var ErrTooManyRows = errors.New("TOO_MANY_ROWS")
+
+// Values of the max_response_rows_mode config
+const (
+	MaxRowsModeError    = "error"
+	MaxRowsModeTruncate = "truncate"
+)
+
+// maxRows returns the maximum number of rows per response for the role and
+// true when larger responses are truncated instead of failing
+func (gj *graphjinEngine) maxRows(roc *Role) (int, bool) {
+	max, mode := gj.conf.MaxResponseRows, gj.conf.MaxResponseRowsMode
+	if roc != nil {
+		if roc.MaxResponseRows > 0 {
+			max = roc.MaxResponseRows
+		}
+		if roc.MaxResponseRowsMode != "" {
+			mode = roc.MaxResponseRowsMode
+		}
+	}
+	return max, mode == MaxRowsModeTruncate
+}
+
+// validateMaxRowsMode checks the max_response_rows_mode config value
+func validateMaxRowsMode(mode string) error {
+	switch mode {
+	case "", MaxRowsModeError, MaxRowsModeTruncate:
+		return nil
+	}
+	return fmt.Errorf("max_response_rows_mode: invalid value '%s': must be '%s' or '%s'",
+		mode, MaxRowsModeError, MaxRowsModeTruncate)
+}
+
+// limitRows lowers the limits of the selectors so that a query does not
+// fetch more rows than needed to enforce the maximum. When truncating the
+// limits are lowered until all selectors together cannot return more than
+// the maximum, the selectors whose limit was lowered are returned.
+// Limits set with variables are not lowered.
+func limitRows(qc *qcode.QCode, max int, truncate bool) map[int32]bool {
+	if max <= 0 || (qc.Type != qcode.QTQuery && qc.Type != qcode.QTSubscription) {
+		return nil
+	}
+
+	// when not truncating a single extra row is enough to detect
+	// that a selector has more rows than the maximum
+	lmax := int32(max)
+	if !truncate {
+		lmax++
+	}
+
+	capped := make(map[int32]bool)
+	for i := range qc.Selects {
+		sel := &qc.Selects[i]
+		if !limitedSelect(sel) {
+			continue
+		}
+		if sel.Paging.NoLimit || sel.Paging.Limit <= 0 || sel.Paging.Limit > lmax {
+			sel.Paging.NoLimit = false
+			sel.Paging.Limit = lmax
+			capped[sel.ID] = true
+		}
+	}
+
+	if !truncate {
+		return capped
+	}
+
+	// halve the largest limit until the selectors fit the maximum
+	for maxFetchRows(qc) > max {
+		var sel *qcode.Select
+		for i := range qc.Selects {
+			s := &qc.Selects[i]
+			if limitedSelect(s) && s.Paging.Limit > 1 &&
+				(sel == nil || s.Paging.Limit >= sel.Paging.Limit) {
+				sel = s
+			}
+		}
+		if sel == nil {
+			break
+		}
+		sel.Paging.Limit = (sel.Paging.Limit + 1) / 2
+		capped[sel.ID] = true
+	}
+	return capped
+}
+
+// fetchedSelect returns true if the rows of the selector are fetched by
+// the query, remote and database joins are resolved separately
+func fetchedSelect(sel *qcode.Select) bool {
+	return sel.SkipRender == qcode.SkipTypeNone &&
+		sel.Rel.Type != sdata.RelRemote && sel.Rel.Type != sdata.RelDatabaseJoin
+}
+
+// limitedSelect returns true if the limit of the selector can be lowered
+func limitedSelect(sel *qcode.Select) bool {
+	return fetchedSelect(sel) && !sel.Singular &&
+		sel.Paging.LimitVar == "" && !sel.Paging.Percent
+}
+
+// maxFetchRows returns the maximum number of rows the selectors can return,
+// a nested selector can return up to its limit for each row of its parent
+func maxFetchRows(qc *qcode.QCode) int {
+	rows := make([]int, len(qc.Selects))
+	total := 0
+	for i := range qc.Selects {
+		sel := &qc.Selects[i]
+		if !fetchedSelect(sel) {
+			continue
+		}
+		n := 1
+		if !sel.Singular && sel.Paging.Limit > 0 {
+			n = int(sel.Paging.Limit)
+		}
+		// selects are ordered so a parent always comes before its children
+		if sel.ParentID != -1 && int(sel.ParentID) < len(rows) {
+			n *= rows[sel.ParentID]
+		}
+		rows[i] = n
+		total += n
+	}
+	return total
+}
+
+// checkMaxRows counts the rows in the response and fails when there are
+// more than the maximum, the response is then dropped. The rows of a query
+// spanning several databases are counted across all its sub-queries.
+func (s *gstate) checkMaxRows() error {
+	max, _ := s.gj.maxRows(s.cs.st.roc)
+	if max <= 0 || len(s.data) == 0 {
+		return nil
+	}
+
+	stmts := []stmt{s.cs.st}
+	if s.multiDB {
+		stmts = s.dbStmts
+	}
+
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(s.data, &data); err != nil {
+		return err
+	}
+
+	var rows int
+	var truncated bool
+	for _, st := range stmts {
+		if st.qc == nil ||
+			(st.qc.Type != qcode.QTQuery && st.qc.Type != qcode.QTSubscription) {
+			continue
+		}
+		rc := rowCounter{qc: st.qc, capped: st.capped}
+		for _, id := range st.qc.Roots {
+			if err := rc.count(&st.qc.Selects[id], data); err != nil {
+				return err
+			}
+		}
+		rows += rc.rows
+		truncated = truncated || rc.truncated
+	}
+
+	if rows > max {
+		s.data = nil
+		return fmt.Errorf("%w: response has more than %d rows", ErrTooManyRows, max)
+	}
+	s.truncated = truncated
+	return nil
+}
+
+// rowCounter counts the rows of the selectors in a response
+type rowCounter struct {
+	qc        *qcode.QCode
+	capped    map[int32]bool
+	rows      int
+	truncated bool
+}
+
+// count counts the rows of the selector in the object
+func (rc *rowCounter) count(sel *qcode.Select, obj map[string]json.RawMessage) error {
+	if !fetchedSelect(sel) {
+		return nil
+	}
+	v := bytes.TrimSpace(obj[sel.FieldName])
+
+	var rows []map[string]json.RawMessage
+	switch {
+	case len(v) == 0 || v[0] == 'n':
+		return nil
+	case v[0] == '[':
+		if err := json.Unmarshal(v, &rows); err != nil {
+			return nil // not a list of rows
+		}
+		if rc.capped[sel.ID] && len(rows) >= int(sel.Paging.Limit) {
+			rc.truncated = true
+		}
+	case v[0] == '{':
+		var row map[string]json.RawMessage
+		if err := json.Unmarshal(v, &row); err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	default:
+		// scalars like a mongodb count are not rows
+		return nil
+	}
+
+	rc.rows += len(rows)
+	for _, row := range rows {
+		for _, id := range sel.Children {
+			if err := rc.count(&rc.qc.Selects[id], row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestMaxResponseRows(t *testing.T) {
+	db := newTestDB(t, "maxrowsdb1")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true, MaxResponseRows: 3}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	res, err := gj.GraphQL(ctx, `query { users(order_by: { id: asc }) { id } }`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":1},{"id":2}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+	if res.Truncated() {
+		t.Fatal("expected the response not to be truncated")
+	}
+
+	// the nested products count towards the maximum
+	_, err = gj.GraphQL(ctx, `query { users { id products { id } } }`, nil, nil)
+	if !errors.Is(err, core.ErrTooManyRows) {
+		t.Fatalf("expected a too many rows error, got: %v", err)
+	}
+}
+
+func TestMaxResponseRowsTruncate(t *testing.T) {
+	db := newTestDB(t, "maxrowsdb2")
+
+	conf := &core.Config{
+		DBType:              "sqlite",
+		DisableAllowList:    true,
+		MaxResponseRows:     1,
+		MaxResponseRowsMode: core.MaxRowsModeTruncate,
+	}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := gj.GraphQL(context.Background(),
+		`query { users(order_by: { id: asc }) { id } }`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":1}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+	if !res.Truncated() {
+		t.Fatal("expected the response to be truncated")
+	}
+}
+
+func TestMaxResponseRowsRole(t *testing.T) {
+	db := newTestDB(t, "maxrowsdb3")
+
+	conf := &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		MaxResponseRows:  1,
+		Roles: []core.Role{
+			{Name: "user", MaxResponseRows: 10},
+		},
+	}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gql := `query { users { id } }`
+
+	_, err = gj.GraphQL(context.Background(), gql, nil, nil)
+	if !errors.Is(err, core.ErrTooManyRows) {
+		t.Fatalf("expected a too many rows error, got: %v", err)
+	}
+
+	// the role allows more rows than the default
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 1)
+	if _, err := gj.GraphQL(ctx, gql, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMaxResponseRowsModeInvalid(t *testing.T) {
+	db := newTestDB(t, "maxrowsdb4")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true, MaxResponseRowsMode: "drop"}
+	if _, err := core.NewGraphJin(conf, db); err == nil {
+		t.Fatal("expected an error for an invalid max rows mode")
+	}
+}
+
+func TestMaxRelationshipsPerQuery(t *testing.T) {
+	db := newTestDB(t, "maxrelsdb1")
+
+	conf := &core.Config{
+		DBType:                   "sqlite",
+		DisableAllowList:         true,
+		MaxRelationshipsPerQuery: 1,
+		Roles: []core.Role{
+			{Name: "user", MaxRelationshipsPerQuery: 2},
+		},
+	}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := gj.GraphQL(context.Background(), `query { products { id owner { id } } }`, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query { users { id products { id owner { id } } } }`
+	_, err = gj.GraphQL(context.Background(), gql, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "'users.products.owner' exceeds the maximum of 1") {
+		t.Fatalf("expected a relationship limit error, got: %v", err)
+	}
+
+	// the role allows more relationships than the default
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 1)
+	if _, err := gj.GraphQL(ctx, gql, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMaxResponseRowsMultiDB(t *testing.T) {
+	gj := newMultiTestGraphJin(t, "maxrowsdb5", &core.Config{
+		DisableAllowList: true,
+		MaxResponseRows:  4,
+	})
+	ctx := context.Background()
+
+	res, err := gj.GraphQL(ctx,
+		`query { users(order_by: { id: asc }) { id } audit_logs(limit: 2) { id } }`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Truncated() {
+		t.Fatal("expected the response not to be truncated")
+	}
+
+	// the rows of both databases count towards the maximum
+	_, err = gj.GraphQL(ctx, `query { users { id } audit_logs { id } }`, nil, nil)
+	if !errors.Is(err, core.ErrTooManyRows) {
+		t.Fatalf("expected a too many rows error, got: %v", err)
+	}
+}
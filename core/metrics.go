@@ -0,0 +1,63 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// QueryMetrics describes a completed request, it is passed to the
+// metrics hook set with OptionSetMetrics
+type QueryMetrics struct {
+	Name      string
+	Operation OpType
+	Role      string
+
+	// Cost of the query as computed by the cost function
+	Cost int
+
+	// Time taken to compile and execute the query
+	Duration time.Duration
+
+	// True if the response was served from the response cache
+	CacheHit bool
+
+	// Error returned by the request if any
+	Err error
+}
+
+// MetricsFn is called after every request with its metrics
+type MetricsFn func(c context.Context, m QueryMetrics)
+
+// OptionSetMetrics sets a hook function that is called with the metrics
+// of every request
+func OptionSetMetrics(fn MetricsFn) Option {
+	return func(s *graphjinEngine) error {
+		s.metricsFn = fn
+		return nil
+	}
+}
+
+// recordMetrics calls the metrics hook and records the duration in the
+// histograms if they are set
+func (s *gstate) recordMetrics(c context.Context, start time.Time, err error) {
+	if s.gj.metricsFn == nil && s.gj.histograms == nil {
+		return
+	}
+	m := QueryMetrics{
+		Name:      s.r.name,
+		Operation: opTypeOf(s.r.operation),
+		Role:      s.role,
+		Duration:  time.Since(start),
+		CacheHit:  s.cacheHit,
+		Err:       err,
+	}
+	if s.cs != nil {
+		m.Cost = s.cs.st.cost
+	}
+	if s.gj.histograms != nil {
+		s.gj.histograms.Observe(c, m)
+	}
+	if s.gj.metricsFn != nil {
+		s.gj.metricsFn(c, m)
+	}
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"reflect"
 	"testing"
@@ -109,6 +110,35 @@ func TestDatabaseConfigParsing(t *testing.T) {
 	}
 }
 
+// TestDatabaseConfigApplyPool verifies that the pool settings of a database
+// are applied and fall back to the legacy settings.
+func TestDatabaseConfigApplyPool(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    DatabaseConfig
+		maxOpen int
+	}{
+		{name: "max_open_conns", conf: DatabaseConfig{MaxOpenConns: 5, MaxConnections: 10}, maxOpen: 5},
+		{name: "max_connections", conf: DatabaseConfig{MaxConnections: 10}, maxOpen: 10},
+		{name: "unset", conf: DatabaseConfig{}, maxOpen: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := sql.Open("sqlite3", ":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close() //nolint:errcheck
+
+			tt.conf.ApplyPool(db)
+			if n := db.Stats().MaxOpenConnections; n != tt.maxOpen {
+				t.Errorf("MaxOpenConnections = %d, want %d", n, tt.maxOpen)
+			}
+		})
+	}
+}
+
 // TestMultiDBConfigInConfig verifies that Databases map is properly defined in Config.
 func TestMultiDBConfigInConfig(t *testing.T) {
 	conf := Config{
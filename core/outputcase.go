@@ -0,0 +1,36 @@
+package core
+
+import (
+	"bytes"
+
+	"github.com/dosco/graphjin/core/v3/internal/jsn"
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// outputData applies the configured null handling to the response data, the
+// output case is applied when the query is compiled
+func (gj *graphjinEngine) outputData(data []byte) []byte {
+	return gj.omitNulls(data)
+}
+
+// omitNulls leaves the fields whose value is null out of the response data
+func (gj *graphjinEngine) omitNulls(data []byte) []byte {
+	if !gj.conf.OmitNullFields || len(data) == 0 {
+		return data
+	}
+
+	var b bytes.Buffer
+	if err := jsn.StripNulls(&b, data); err != nil {
+		return data
+	}
+	return b.Bytes()
+}
+
+// identifierCase returns how field names are mapped to database identifiers,
+// a camel case output maps camel case field names back to snake case columns
+func (gj *graphjinEngine) identifierCase() string {
+	if gj.conf.IdentifierCase == "" && gj.conf.OutputCase == "camel" {
+		return qcode.IdentCaseSnake
+	}
+	return gj.conf.IdentifierCase
+}
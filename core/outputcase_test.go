@@ -0,0 +1,180 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestOutputCase(t *testing.T) {
+	db := newTestDB(t, "outputcasedb1")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true, OutputCase: "camel"}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nested objects and lists are renamed whatever the case of the query
+	gql := `query {
+		users(where: { id: 1 }) {
+			id
+			full_name
+			products(order_by: { id: asc }) { id owner_id }
+		}
+	}`
+	res, err := gj.GraphQL(context.Background(), gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"users":[{"id":1,"fullName":"User One","products":[{"id":1,"ownerId":1},{"id":2,"ownerId":1}]}]}`
+	if got := string(res.Data); got != exp {
+		t.Fatalf("expected: %s, got: %s", exp, got)
+	}
+
+	// and camel case field names map to snake case columns
+	gql = `mutation { users(insert: $data) { id fullName } }`
+	vars := json.RawMessage(`{"data": {"id": 3, "fullName": "User Three", "email": "user3@test.com"}}`)
+	res, err = gj.GraphQL(context.Background(), gql, vars, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp = `{"users":[{"id":3,"fullName":"User Three"}]}`
+	if got := string(res.Data); got != exp {
+		t.Fatalf("expected: %s, got: %s", exp, got)
+	}
+
+	// the aliases and the contents of json columns are left as they are
+	_, err = db.Exec(`
+		CREATE TABLE user_settings (id INTEGER PRIMARY KEY, display_prefs JSON);
+		INSERT INTO user_settings (id, display_prefs) VALUES (1, '{"font_size":12,"dark_mode":true}');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gj, err = core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql = `query { user_settings { id display_prefs prefs_id: id } }`
+	res, err = gj.GraphQL(context.Background(), gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp = `{"userSettings":[{"id":1,"displayPrefs":{"font_size":12,"dark_mode":true},"prefs_id":1}]}`
+	if got := string(res.Data); got != exp {
+		t.Fatalf("expected: %s, got: %s", exp, got)
+	}
+}
+
+func TestOutputCaseComputed(t *testing.T) {
+	db := newTestDB(t, "outputcasedb3")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true, OutputCase: "camel"}
+	gj, err := core.NewGraphJin(conf, db,
+		core.OptionAddComputedField(core.ComputedField{
+			Table:    "products",
+			Name:     "owner_label",
+			Requires: []string{"owner_id"},
+			Resolver: func(c context.Context, req core.FieldResolverReq) ([]core.FieldResult, error) {
+				res := make([]core.FieldResult, len(req.Rows))
+				for i, row := range req.Rows {
+					res[i].Value = "owner " + string(row["owner_id"])
+				}
+				return res, nil
+			},
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the columns a computed field requires are found whatever their output name
+	gql := `query { products(where: { id: 1 }) { id owner_id ownerLabel } }`
+	res, err := gj.GraphQL(context.Background(), gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"products":[{"id":1,"ownerId":1,"ownerLabel":"owner 1"}]}`
+	if got := string(res.Data); got != exp {
+		t.Fatalf("expected: %s, got: %s", exp, got)
+	}
+}
+
+func TestOutputCaseSnake(t *testing.T) {
+	db := newTestDB(t, "outputcasedb2")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true, OutputCase: "snake"}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fields with an alias keep it
+	res, err := gj.GraphQL(context.Background(),
+		`query { users(where: { id: 2 }) { id fullName: full_name } }`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"users":[{"id":2,"fullName":"User Two"}]}`
+	if got := string(res.Data); got != exp {
+		t.Fatalf("expected: %s, got: %s", exp, got)
+	}
+
+	conf = &core.Config{DBType: "sqlite", DisableAllowList: true, OutputCase: "snake", IdentifierCase: "snake"}
+	gj, err = core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = gj.GraphQL(context.Background(),
+		`query { users(where: { id: 2 }) { id fullName } }`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp = `{"users":[{"id":2,"full_name":"User Two"}]}`
+	if got := string(res.Data); got != exp {
+		t.Fatalf("expected: %s, got: %s", exp, got)
+	}
+
+	conf = &core.Config{DBType: "sqlite", DisableAllowList: true, OutputCase: "kebab"}
+	if _, err := core.NewGraphJin(conf, db); err == nil {
+		t.Fatal("expected an error for an invalid output case")
+	}
+}
+
+func TestOmitNullFields(t *testing.T) {
+	db := newTestDB(t, "omitnullsdb1")
+	_, err := db.Exec(`
+		INSERT INTO users (id, full_name, email) VALUES (3, 'User Three', NULL);
+		INSERT INTO products (id, name, price, owner_id) VALUES (3, 'Product Three', NULL, 3);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true, OmitNullFields: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the null fields are left out of nested objects as well
+	gql := `query {
+		products(where: { id: { in: [1, 3] } }, order_by: { id: asc }) {
+			id
+			price
+			owner { id email }
+		}
+	}`
+	res, err := gj.GraphQL(context.Background(), gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"products":[{"id":1,"price":10.5,"owner":{"id":1,"email":"user1@test.com"}},{"id":3,"owner":{"id":3}}]}`
+	if got := string(res.Data); got != exp {
+		t.Fatalf("expected: %s, got: %s", exp, got)
+	}
+}
@@ -0,0 +1,44 @@
+package core
+
+// redactedValue replaces the value of a redacted query parameter
+const redactedValue = "[REDACTED]"
+
+// QueryParam is a parameter bound to the executed query
+type QueryParam struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// setDebugParams keeps the parameters bound to the query so they can be
+// returned with the result, this is only done in debug mode
+func (s *gstate) setDebugParams(ar args) {
+	conf := s.gj.conf
+	if !conf.Debug || conf.Production {
+		return
+	}
+
+	params := s.cs.st.md.Params()
+	s.params = make([]QueryParam, len(params))
+
+	for i, p := range params {
+		qp := QueryParam{Name: p.Name, Type: p.Type}
+		if i < len(ar.values) {
+			qp.Value = ar.values[i]
+		}
+		if conf.RedactDebugParams || s.gj.isSensitiveVar(p.Name) {
+			qp.Value = redactedValue
+		}
+		s.params[i] = qp
+	}
+}
+
+// isSensitiveVar returns true if the variable value must never be returned
+func (gj *graphjinEngine) isSensitiveVar(name string) bool {
+	for _, v := range gj.conf.SensitiveVars {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package core_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestDebugParams(t *testing.T) {
+	gql := `query { products(where: { id: $id, name: $name }) { id } }`
+	vars := []byte(`{"id": 1, "name": "Product One"}`)
+
+	tests := []struct {
+		name string
+		conf core.Config
+		exp  []core.QueryParam
+	}{
+		{
+			name: "debug",
+			conf: core.Config{Debug: true, SensitiveVars: []string{"name"}},
+			exp: []core.QueryParam{
+				{Name: "name", Type: "text", Value: "[REDACTED]"},
+				{Name: "id", Type: "integer", Value: int64(1)},
+			},
+		},
+		{
+			name: "redacted",
+			conf: core.Config{Debug: true, RedactDebugParams: true},
+			exp: []core.QueryParam{
+				{Name: "name", Type: "text", Value: "[REDACTED]"},
+				{Name: "id", Type: "integer", Value: "[REDACTED]"},
+			},
+		},
+		{
+			name: "production",
+			conf: core.Config{Debug: true, Production: true, DisableProdSecurity: true},
+		},
+		{
+			name: "not debug",
+			conf: core.Config{},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, "paramsdb"+string(rune('a'+i)))
+
+			conf := tt.conf
+			conf.DBType = "sqlite"
+			conf.DisableAllowList = true
+
+			gj, err := core.NewGraphJin(&conf, db)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := gj.GraphQL(context.Background(), gql, vars, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exp := `{"products":[{"id":1}]}`; string(res.Data) != exp {
+				t.Fatalf("expected: %s, got: %s", exp, res.Data)
+			}
+
+			params := res.Params()
+			if len(params) != len(tt.exp) {
+				t.Fatalf("expected params: %v, got: %v", tt.exp, params)
+			}
+			for i, p := range params {
+				if p != tt.exp[i] {
+					t.Fatalf("expected param: %v, got: %v", tt.exp[i], p)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+
+	"github.com/dosco/graphjin/core/v3/internal/graph"
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// rootError is the error of a root that failed in a multi-root query,
+// the root is returned as null while the other roots return data
+type rootError struct {
+	key string
+	err error
+}
+
+// rootField is a root field of the query and the key it has in the result
+type rootField struct {
+	id   int32
+	name string
+	key  string
+}
+
+// queryRootFields returns the root fields of the query in the order they are selected
+func (s *gstate) queryRootFields() (roots []rootField) {
+	op, err := graph.Parse(s.r.query)
+	if err != nil {
+		return
+	}
+	for _, f := range op.Fields {
+		if f.ParentID != -1 || f.Type == graph.FieldKeyword {
+			continue
+		}
+		key := f.Name
+		if f.Alias != "" {
+			key = f.Alias
+		}
+		roots = append(roots, rootField{id: f.ID, name: f.Name, key: key})
+	}
+	return
+}
+
+// namedRootFields returns the root fields of the query with the given names
+func (s *gstate) namedRootFields(names []string) (roots []rootField) {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	for _, r := range s.queryRootFields() {
+		if wanted[r.name] {
+			roots = append(roots, r)
+		}
+	}
+	return
+}
+
+// buildRootQuery creates a new GraphQL query containing only the root field
+func (s *gstate) buildRootQuery(r rootField) ([]byte, error) {
+	return s.buildRootsQuery(func(f *graph.Field) bool { return f.ID == r.id })
+}
+
+// canExecRootsSeparately returns true if the failed query can be retried
+// one root at a time to return the roots that succeed
+func (s *gstate) canExecRootsSeparately(err error) bool {
+	// mutations are all or nothing and in a transaction a failed
+	// statement can abort the statements that follow it
+	if s.r.operation != qcode.QTQuery || s.tx() != nil {
+		return false
+	}
+	if errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return len(s.queryRootFields()) > 1
+}
+
+// execQueryRootsSeparately executes each root of the query as its own statement
+// so that a failed root is returned as null with an error scoped to it.
+// The original error is returned if all the roots fail.
+func (s *gstate) execQueryRootsSeparately(c context.Context, qerr error) error {
+	data, rerrs, ferrs, err := s.execRootsSeparately(s.queryRootFields(),
+		func(r rootField) (json.RawMessage, []error, error) {
+			return s.execRoot(c, r)
+		})
+	if err != nil {
+		return qerr
+	}
+	s.data = data
+	s.dhash = sha256.Sum256(s.data)
+	s.rerrs = append(s.rerrs, rerrs...)
+	s.ferrs = append(s.ferrs, ferrs...)
+	return nil
+}
+
+// execRoot executes a query with only the root field
+func (s *gstate) execRoot(c context.Context, r rootField) (json.RawMessage, []error, error) {
+	query, err := s.buildRootQuery(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rs := gstate{
+		gj:        s.gj,
+		r:         s.r,
+		role:      s.role,
+		skipCache: true,
+		// the root is only charged when the query failed before its
+		// cost was charged
+		costCharged: s.costCharged,
+	}
+	rs.r.query = query
+	// keep the compiled root apart from the full query in production mode
+	rs.r.name = s.r.name + "." + r.key
+
+	if len(s.vmap) != 0 {
+		rs.vmap = make(map[string]json.RawMessage, len(s.vmap))
+		for k, v := range s.vmap {
+			rs.vmap[k] = v
+		}
+	}
+
+	if err := rs.compileAndExecuteWrapper(c); err != nil {
+		return nil, nil, err
+	}
+	return rs.data, rs.ferrs, nil
+}
+
+// execRootsSeparately calls fn for each of the root fields and merges the results.
+// The roots that fail are set to null and an error is returned only when all
+// of them fail.
+func (s *gstate) execRootsSeparately(
+	roots []rootField,
+	fn func(r rootField) (json.RawMessage, []error, error),
+) (data json.RawMessage, rerrs []rootError, ferrs []error, err error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+
+	for i, r := range roots {
+		if i != 0 {
+			b.WriteByte(',')
+		}
+		kb, err1 := json.Marshal(r.key)
+		if err1 != nil {
+			return nil, nil, nil, err1
+		}
+		b.Write(kb)
+		b.WriteByte(':')
+
+		d, fe, err1 := fn(r)
+		var v json.RawMessage
+		if err1 == nil && len(d) != 0 {
+			var obj map[string]json.RawMessage
+			if err1 = json.Unmarshal(d, &obj); err1 == nil {
+				v = obj[r.key]
+			}
+		}
+		if err1 != nil {
+			rerrs = append(rerrs, rootError{key: r.key, err: err1})
+			b.WriteString("null")
+			continue
+		}
+		if len(v) == 0 {
+			v = json.RawMessage(`null`)
+		}
+		b.Write(v)
+		ferrs = append(ferrs, fe...)
+	}
+	b.WriteByte('}')
+
+	if len(rerrs) == len(roots) {
+		return nil, nil, nil, rerrs[0].err
+	}
+	data = b.Bytes()
+	return
+}
@@ -0,0 +1,100 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestMultiRootPartialResult(t *testing.T) {
+	db := newTestDB(t, "partialdb1")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db,
+		core.OptionAddDirective("deny", func(d *core.Directive) error {
+			return errors.New("permission denied")
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	gql := `query {
+		users(order_by: { id: asc }) { id }
+		items: products @deny { id }
+		products(where: { id: 1 }) { id name }
+	}`
+
+	res, err := gj.GraphQL(ctx, gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the aliased root fails and not the other root on the same table
+	exp := `{"users":[{"id":1},{"id":2}],"items":null,"products":[{"id":1,"name":"Product One"}]}`
+	if got := string(res.Data); got != exp {
+		t.Fatalf("expected: %s, got: %s", exp, got)
+	}
+
+	if len(res.Errors) != 1 {
+		t.Fatalf("expected an error for the failed root, got: %v", res.Errors)
+	}
+	if e := res.Errors[0]; len(e.Path) != 1 || e.Path[0] != "items" ||
+		!strings.Contains(e.Message, "permission denied") {
+		t.Fatalf("expected a path scoped error, got: %v", e)
+	}
+
+	gql = `query {
+		users(where: { id: $id }) { id full_name }
+		products { id missing_column }
+	}`
+
+	res, err = gj.GraphQL(ctx, gql, []byte(`{"id": 2}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp = `{"users":[{"id":2,"full_name":"User Two"}],"products":null}`
+	if got := string(res.Data); got != exp {
+		t.Fatalf("expected: %s, got: %s", exp, got)
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Path[0] != "products" {
+		t.Fatalf("expected an error for the products root, got: %v", res.Errors)
+	}
+
+	// the whole query fails when all the roots fail
+	_, err = gj.GraphQL(ctx, `query { users { missing } products { missing } }`, nil, nil)
+	if err == nil {
+		t.Fatal("expected an error when all roots fail")
+	}
+}
+
+func TestMultiRootPartialResultMutation(t *testing.T) {
+	db := newTestDB(t, "partialdb2")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// mutations are not split into separate statements
+	gql := `mutation {
+		users(insert: { id: 3, full_name: "User Three", email: "user3@test.com" }) { id }
+		products(insert: { id: 3, name: "Product Three", price: 1.5 }) { id missing }
+	}`
+	if _, err := gj.GraphQL(context.Background(), gql, nil, nil); err == nil {
+		t.Fatal("expected the mutation to fail")
+	}
+
+	var n int
+	if err := db.QueryRow(`SELECT count(*) FROM users`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected no users to be inserted, got: %d", n)
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// ErrReadOnly is returned for mutations and subscriptions when GraphJin is
+// read-only or when they run against a read-only database
+var ErrReadOnly = errors.New("READ_ONLY")
+
+// checkReadOnly rejects mutations and subscriptions when GraphJin is
+// read-only, it is checked before the operation is compiled
+func (gj *graphjinEngine) checkReadOnly(op qcode.QType) error {
+	if !gj.conf.ReadOnly {
+		return nil
+	}
+	switch op {
+	case qcode.QTMutation:
+		return fmt.Errorf("%w: mutations blocked, graphjin is read-only", ErrReadOnly)
+	case qcode.QTSubscription:
+		return fmt.Errorf("%w: subscriptions blocked, graphjin is read-only", ErrReadOnly)
+	}
+	return nil
+}
+
+// checkReadOnlyMaterialize rejects queries that cache their results with
+// @materialize when GraphJin or the database is read-only, caching the
+// results writes to a collection
+func (gj *graphjinEngine) checkReadOnlyMaterialize(qc *qcode.QCode, dbName string) error {
+	if qc == nil || !qc.Materialized() {
+		return nil
+	}
+	if gj.conf.ReadOnly {
+		return fmt.Errorf("%w: materialized queries blocked, graphjin is read-only", ErrReadOnly)
+	}
+	if dbName == "" {
+		dbName = gj.defaultDB
+	}
+	if dbCtx, ok := gj.GetDatabase(dbName); ok && dbCtx.readOnly {
+		return fmt.Errorf("%w: materialized queries blocked, database %s is read-only", ErrReadOnly, dbName)
+	}
+	return nil
+}
+
+// checkReadOnlyDB rejects mutations and subscriptions against a read-only
+// database, the database is only known once the operation is compiled
+func (gj *graphjinEngine) checkReadOnlyDB(op qcode.QType, dbName string) error {
+	var kind string
+	switch op {
+	case qcode.QTMutation:
+		kind = "mutations"
+	case qcode.QTSubscription:
+		kind = "subscriptions"
+	default:
+		return nil
+	}
+	if dbName == "" {
+		dbName = gj.defaultDB
+	}
+	if dbCtx, ok := gj.GetDatabase(dbName); ok && dbCtx.readOnly {
+		return fmt.Errorf("%w: %s blocked, database %s is read-only", ErrReadOnly, kind, dbName)
+	}
+	return nil
+}
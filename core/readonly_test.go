@@ -0,0 +1,44 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+func TestReadOnlyMaterialize(t *testing.T) {
+	qc := &qcode.QCode{
+		Type:  qcode.QTQuery,
+		Roots: []int32{0},
+		Selects: []qcode.Select{{
+			Materialize: qcode.Materialize{Collection: "order_totals", TTL: 60},
+		}},
+	}
+	gj := &graphjinEngine{
+		conf:      &Config{},
+		defaultDB: "main",
+		databases: map[string]*dbContext{
+			"main":    {dbtype: "mongodb"},
+			"replica": {dbtype: "mongodb", readOnly: true},
+		},
+	}
+
+	if err := gj.checkReadOnlyMaterialize(qc, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := gj.checkReadOnlyMaterialize(qc, "replica"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected a read-only error for the database, got: %v", err)
+	}
+
+	gj.conf.ReadOnly = true
+	if err := gj.checkReadOnlyMaterialize(qc, ""); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected a read-only error for graphjin, got: %v", err)
+	}
+
+	// queries that are not materialized are not affected
+	qc.Selects[0].Materialize = qcode.Materialize{}
+	if err := gj.checkReadOnlyMaterialize(qc, ""); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrDatabase is returned in production in place of the errors of the
+// database when redact_errors is enabled
+var ErrDatabase = errors.New("DATABASE_ERROR")
+
+// dbError marks an error returned by the database driver, its message can
+// contain the names of tables and columns
+type dbError struct {
+	err error
+}
+
+func (e *dbError) Error() string { return e.err.Error() }
+func (e *dbError) Unwrap() error { return e.err }
+
+// wrapDBError marks an error returned by the database driver. No rows is
+// not an error of the database and is returned as is
+func wrapDBError(err error) error {
+	if err == nil || err == sql.ErrNoRows {
+		return err
+	}
+	var de *dbError
+	if errors.As(err, &de) {
+		return err
+	}
+	return &dbError{err: err}
+}
+
+// redactError replaces the errors of the database with a generic one in
+// production. The error is logged with a correlation id that is also
+// returned so the two can be matched. Errors of GraphJin like validation
+// and authorization errors are returned as is and so are constraint
+// violations since their message only names the constraint and columns
+func (gj *graphjinEngine) redactError(err error) error {
+	if !gj.prod || !gj.conf.RedactErrors {
+		return err
+	}
+	var de *dbError
+	var ce *ConstraintError
+	if !errors.As(err, &de) || errors.As(err, &ce) {
+		return err
+	}
+
+	id := correlationID()
+	gj.log.Printf("%s: correlation id %s: %s", ErrDatabase, id, err)
+	return fmt.Errorf("%w: the query failed, correlation id %s", ErrDatabase, id)
+}
+
+// correlationID returns a random id to match a client error with the log
+func correlationID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
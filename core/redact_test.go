@@ -0,0 +1,63 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestRedactErrors(t *testing.T) {
+	newEngine := func(name string, prod, redact bool) *core.GraphJin {
+		t.Helper()
+		db := newTestDB(t, name)
+		conf := &core.Config{
+			DBType:              "sqlite",
+			Production:          prod,
+			DisableProdSecurity: true,
+			RedactErrors:        redact,
+		}
+		gj, err := core.NewGraphJin(conf, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the query fails in the database once the table is gone
+		if _, err := db.Exec(`DROP TABLE products`); err != nil {
+			t.Fatal(err)
+		}
+		return gj
+	}
+
+	gql := `query { products { id name } }`
+
+	gj := newEngine("redact_prod", true, true)
+	res, err := gj.GraphQL(context.Background(), gql, nil, nil)
+	if !errors.Is(err, core.ErrDatabase) {
+		t.Fatalf("expected a database error, got %v", err)
+	}
+	if len(res.Errors) != 1 {
+		t.Fatalf("expected an error, got %v", res.Errors)
+	}
+	msg := res.Errors[0].Message
+	if strings.Contains(msg, "products") || !strings.Contains(msg, "correlation id ") {
+		t.Fatalf("expected a redacted error with a correlation id, got %s", msg)
+	}
+	if msg != err.Error() {
+		t.Fatalf("expected the same error in the result, got %s and %s", msg, err)
+	}
+
+	// the errors of GraphJin are not redacted
+	_, err = gj.GraphQL(context.Background(), `query { users { id password } }`, nil, nil)
+	if err == nil || errors.Is(err, core.ErrDatabase) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+
+	// errors are only redacted in production
+	gj = newEngine("redact_dev", false, true)
+	_, err = gj.GraphQL(context.Background(), gql, nil, nil)
+	if err == nil || errors.Is(err, core.ErrDatabase) || !strings.Contains(err.Error(), "products") {
+		t.Fatalf("expected the database error, got %v", err)
+	}
+}
@@ -29,10 +29,11 @@ func (s *gstate) execRemoteJoin(c context.Context) (err error) {
 		return
 	}
 
-	to, err := s.resolveRemotes(c, from, sfmap)
+	to, ferrs, err := s.resolveRemotes(c, from, sfmap)
 	if err != nil {
 		return
 	}
+	s.fderrs = append(s.fderrs, ferrs...)
 
 	var ob bytes.Buffer
 	if err = jsn.Replace(&ob, s.data, from, to); err != nil {
@@ -42,12 +43,20 @@ func (s *gstate) execRemoteJoin(c context.Context) (err error) {
 	return
 }
 
-// resolveRemotes fetches remote data for the marked insertion points
+// fieldError is the error of a remote field that failed, the field is
+// returned as null while the rest of the response returns data
+type fieldError struct {
+	path []string
+	err  error
+}
+
+// resolveRemotes fetches remote data for the marked insertion points, the
+// failures of non-fatal resolvers are returned as field errors
 func (s *gstate) resolveRemotes(
 	ctx context.Context,
 	from []jsn.Field,
 	sfmap map[string]*qcode.Select,
-) ([]jsn.Field, error) {
+) ([]jsn.Field, []fieldError, error) {
 	selects := s.cs.st.qc.Selects
 
 	// replacement data for the marked insertion points
@@ -57,6 +66,9 @@ func (s *gstate) resolveRemotes(
 	var wg sync.WaitGroup
 	wg.Add(len(from))
 
+	// errors of the fields returned as null
+	ferrs := make([]error, len(from))
+
 	var cerr error
 	var cerrMutex sync.Mutex
 
@@ -64,7 +76,7 @@ func (s *gstate) resolveRemotes(
 		// use the json key to find the related Select object
 		sel, ok := sfmap[string(id.Key)]
 		if !ok {
-			return nil, fmt.Errorf("invalid remote field key")
+			return nil, nil, fmt.Errorf("invalid remote field key")
 		}
 		p := selects[sel.ParentID]
 
@@ -72,12 +84,12 @@ func (s *gstate) resolveRemotes(
 		// to find the resolver to use for this relationship
 		r, ok := s.gj.rmap[(sel.Table + p.Table)]
 		if !ok {
-			return nil, fmt.Errorf("no resolver found")
+			return nil, nil, fmt.Errorf("no resolver found")
 		}
 
 		id := jsn.Value(id.Value)
 		if len(id) == 0 {
-			return nil, fmt.Errorf("invalid remote field id")
+			return nil, nil, fmt.Errorf("invalid remote field id")
 		}
 
 		go func(n int, id []byte, sel *qcode.Select) {
@@ -87,15 +99,25 @@ func (s *gstate) resolveRemotes(
 
 			ctx1, span := s.gj.spanStart(ctx, "Execute Remote Request")
 
+			// a failed non-fatal resolver returns null for the field
+			fail := func(err error) {
+				if !r.Fatal {
+					ferrs[n] = err
+					to[n] = jsn.Field{Key: []byte(sel.FieldName), Value: []byte("null")}
+					return
+				}
+				cerrMutex.Lock()
+				cerr = err
+				cerrMutex.Unlock()
+			}
+
 			b, err := r.Fn.Resolve(ctx1, ResolverReq{
 				ID: string(id), Sel: sel, Log: s.gj.log, RequestConfig: s.r.requestconfig,
 			})
 			if err != nil {
-				cerrMutex.Lock()
-				cerr = fmt.Errorf("%s: %s", sel.Table, err)
-				spanErr := cerr
-				cerrMutex.Unlock()
-				span.Error(spanErr)
+				err = fmt.Errorf("%s: %s", sel.Table, err)
+				fail(err)
+				span.Error(err)
 			}
 			span.End()
 
@@ -112,9 +134,7 @@ func (s *gstate) resolveRemotes(
 			if len(sel.Fields) != 0 {
 				err = jsn.Filter(&ob, b, fieldsToList(sel.Fields))
 				if err != nil {
-					cerrMutex.Lock()
-					cerr = fmt.Errorf("%s: %w", sel.Table, err)
-					cerrMutex.Unlock()
+					fail(fmt.Errorf("%s: %w", sel.Table, err))
 					return
 				}
 
@@ -126,7 +146,33 @@ func (s *gstate) resolveRemotes(
 		}(i, id, sel)
 	}
 	wg.Wait()
-	return to, cerr
+
+	if cerr != nil {
+		return nil, nil, cerr
+	}
+
+	var fes []fieldError
+	for i, err := range ferrs {
+		if err != nil {
+			sel := sfmap[string(from[i].Key)]
+			fes = append(fes, fieldError{path: s.selectPath(sel), err: err})
+		}
+	}
+	return to, fes, nil
+}
+
+// selectPath returns the field names from the root of the query to the select
+func (s *gstate) selectPath(sel *qcode.Select) []string {
+	selects := s.cs.st.qc.Selects
+
+	var path []string
+	for {
+		path = append([]string{sel.FieldName}, path...)
+		if sel.ParentID == -1 {
+			return path
+		}
+		sel = &selects[sel.ParentID]
+	}
 }
 
 // parentFieldIds fetches the field name used within the db response json
@@ -0,0 +1,74 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+type paymentsResolver struct{}
+
+func (paymentsResolver) Resolve(_ context.Context, req core.ResolverReq) ([]byte, error) {
+	if req.ID == "2" {
+		return nil, errors.New("service unavailable")
+	}
+	return []byte(`{"amount": 100}`), nil
+}
+
+func TestRemoteJoinFieldErrors(t *testing.T) {
+	db := newTestDB(t, "remotedb1")
+
+	newGJ := func(fatal bool) *core.GraphJin {
+		conf := &core.Config{
+			DBType:           "sqlite",
+			DisableAllowList: true,
+			Resolvers: []core.ResolverConfig{{
+				Name: "payments", Type: "payments", Table: "users", Fatal: fatal,
+			}},
+		}
+		gj, err := core.NewGraphJin(conf, db,
+			core.OptionSetResolver("payments", func(core.ResolverProps) (core.Resolver, error) {
+				return paymentsResolver{}, nil
+			}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gj
+	}
+
+	gql := `query { users(order_by: { id: asc }) { id payments { amount } } }`
+
+	// a failed resolver returns null for its field and the rest of the data
+	res, err := newGJ(false).GraphQL(context.Background(), gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data struct {
+		Users []struct {
+			ID       int             `json:"id"`
+			Payments json.RawMessage `json:"payments"`
+		} `json:"users"`
+	}
+	if err := json.Unmarshal(res.Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Users) != 2 ||
+		string(data.Users[0].Payments) == `null` ||
+		string(data.Users[1].Payments) != `null` {
+		t.Fatalf("unexpected data: %s", res.Data)
+	}
+	if len(res.Errors) != 1 ||
+		res.Errors[0].Message != "payments: service unavailable" ||
+		len(res.Errors[0].Path) != 2 ||
+		res.Errors[0].Path[0] != "users" || res.Errors[0].Path[1] != "payments" {
+		t.Fatalf("unexpected errors: %+v", res.Errors)
+	}
+
+	// and a fatal resolver fails the query
+	if _, err := newGJ(true).GraphQL(context.Background(), gql, nil, nil); err == nil {
+		t.Fatal("expected the query to fail")
+	}
+}
@@ -13,6 +13,7 @@ type resItem struct {
 	IDField []byte
 	Path    [][]byte
 	Fn      Resolver
+	Fatal   bool
 }
 
 // newRTMap returns a map of resolver functions
@@ -114,6 +115,7 @@ func (gj *graphjinEngine) initRemote(
 		IDField: []byte(idk),
 		Path:    path,
 		Fn:      fn,
+		Fatal:   rc.Fatal,
 	}
 
 	// Index resolver obj by parent and child names
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"time"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+const (
+	defaultMutationRetryDelay = 50 * time.Millisecond
+	defaultQueryRetryDelay    = 50 * time.Millisecond
+)
+
+// retryMutation calls fn again when a mutation fails with an error the
+// dialect considers retryable (serialization failures, deadlocks, etc),
+// waiting twice as long before each retry.
+//
+// Only the database statement is retried, database triggers and functions
+// called by it run again but remote joins, computed fields and other
+// resolvers run once after it succeeds. Mutations in a transaction passed
+// to GraphQLTx are not retried since the failure aborts the transaction.
+// The multi-statement scripts of MySQL and SQLite run in a transaction of
+// their own when retries are enabled, see scriptTx.
+func (s *gstate) retryMutation(c context.Context, fn func() error) (err error) {
+	if !s.retriesMutation() {
+		return fn()
+	}
+	retries := s.gj.conf.MutationRetries
+
+	delay := s.gj.conf.MutationRetryDelay
+	if delay <= 0 {
+		delay = defaultMutationRetryDelay
+	}
+
+	dialect := s.getTargetPsqlCompiler().GetDialect()
+	return s.retry(c, "mutation", retries, delay, dialect.IsRetryableError, fn)
+}
+
+// retriesMutation reports whether a failed mutation is retried
+func (s *gstate) retriesMutation() bool {
+	return s.r.operation == qcode.QTMutation && s.tx() == nil &&
+		s.gj.conf.MutationRetries > 0
+}
+
+// scriptTx begins the transaction a multi-statement script runs in when the
+// mutation is retried. The statements of a script otherwise commit one at a
+// time so a retry after a failed statement would run the ones before it
+// again. It returns the transaction passed to GraphQLTx if there is one.
+func (s *gstate) scriptTx(c context.Context, conn *sql.Conn) (tx *sql.Tx, own bool, err error) {
+	if tx = s.tx(); tx != nil || !s.retriesMutation() {
+		return
+	}
+	if tx, err = conn.BeginTx(c, nil); err != nil {
+		return
+	}
+	own = true
+	return
+}
+
+// retryQuery calls fn again when a query fails because the connection to
+// the database was lost, like after a failover. Every retry runs on a new
+// connection from the pool. Mutations are never retried this way since
+// the write may have happened before the connection was lost, and neither
+// are queries in a transaction passed to GraphQLTx.
+func (s *gstate) retryQuery(c context.Context, fn func() error) (err error) {
+	retries := s.gj.conf.QueryRetries
+	if s.r.operation != qcode.QTQuery || s.tx() != nil || retries <= 0 {
+		return fn()
+	}
+
+	delay := s.gj.conf.QueryRetryDelay
+	if delay <= 0 {
+		delay = defaultQueryRetryDelay
+	}
+
+	dialect := s.getTargetPsqlCompiler().GetDialect()
+	return s.retry(c, "query", retries, delay, dialect.IsConnectionError, fn)
+}
+
+// retryStatement runs a query statement again on the same connection when it
+// fails, mutation statements run once since the write may have happened
+// before the error was returned
+func (s *gstate) retryStatement(c context.Context, fn func() error) error {
+	if s.r.operation == qcode.QTMutation {
+		return fn()
+	}
+	return retryOperation(c, fn)
+}
+
+// retry calls fn up to retries more times while it fails with an error
+// accepted by retryable, the delay doubles with every retry. The retries
+// stop once the context is done so its deadline bounds the total time
+func (s *gstate) retry(c context.Context,
+	op string,
+	retries int,
+	delay time.Duration,
+	retryable func(error) bool,
+	fn func() error,
+) (err error) {
+	for i := 0; ; i++ {
+		if err = fn(); err == nil || i == retries || c.Err() != nil || !retryable(err) {
+			return
+		}
+		s.data = nil
+
+		if s.gj.conf.Debug {
+			s.gj.log.Printf("retrying %s (%d/%d): %s", op, i+1, retries, err)
+		}
+
+		// jitter keeps the failed requests from being retried together
+		d := delay<<i + time.Duration(rand.Int63n(int64(delay)))
+		select {
+		case <-c.Done():
+			return
+		case <-time.After(d):
+		}
+	}
+}
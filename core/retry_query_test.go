@@ -0,0 +1,103 @@
+package core_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+	"github.com/mattn/go-sqlite3"
+)
+
+// flakyFails is the number of queries named flakyUsers that fail with a
+// connection reset before they start to succeed
+var flakyFails int32
+
+func init() {
+	sql.Register("sqlite3_flaky", flakyDriver{})
+}
+
+type flakyDriver struct{}
+
+func (flakyDriver) Open(name string) (driver.Conn, error) {
+	c, err := (&sqlite3.SQLiteDriver{}).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return flakyConn{c.(*sqlite3.SQLiteConn)}, nil
+}
+
+type flakyConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c flakyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := flakyErr(query); err != nil {
+		return nil, err
+	}
+	return c.SQLiteConn.QueryContext(ctx, query, args)
+}
+
+func (c flakyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := flakyErr(query); err != nil {
+		return nil, err
+	}
+	return c.SQLiteConn.ExecContext(ctx, query, args)
+}
+
+func flakyErr(query string) error {
+	if strings.Contains(query, "flakyUsers") && atomic.AddInt32(&flakyFails, -1) >= 0 {
+		return &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	}
+	return nil
+}
+
+func TestQueryRetry(t *testing.T) {
+	newTestDB(t, "retryquery")
+
+	db, err := sql.Open("sqlite3_flaky", "file:retryquery?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true, QueryRetries: 2}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	gql := `query flakyUsers { users(id: 1) { id } }`
+
+	// the statement is tried three times on the same connection so the
+	// query only succeeds by being retried on a new connection
+	atomic.StoreInt32(&flakyFails, 5)
+	res, err := gj.GraphQL(ctx, gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":{"id":1}}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+
+	// the query fails once the retries run out
+	atomic.StoreInt32(&flakyFails, 9)
+	if _, err := gj.GraphQL(ctx, gql, nil, nil); err == nil {
+		t.Fatal("expected the query to fail after the retries")
+	}
+
+	// mutations are never retried
+	atomic.StoreInt32(&flakyFails, 1)
+	mut := `mutation flakyUsers { users(id: 1, update: { full_name: "Retried" }) { id } }`
+	if _, err := gj.GraphQL(ctx, mut, nil, nil); err == nil {
+		t.Fatal("expected the mutation not to be retried")
+	}
+	if n := atomic.LoadInt32(&flakyFails); n != 0 {
+		t.Fatalf("expected the mutation to run once, %d failures left", n)
+	}
+}
@@ -0,0 +1,120 @@
+package core_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestMutationRetry(t *testing.T) {
+	db := newTestDB(t, "retrydb1")
+	ctx := context.Background()
+
+	gql := `mutation {
+		users(id: 1, update: { full_name: $name }) { id full_name }
+	}`
+
+	// lockUsers holds a write lock on the users table until the returned
+	// function is called
+	lockUsers := func() func() {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.Exec(`UPDATE users SET email = email WHERE id = 2`); err != nil {
+			t.Fatal(err)
+		}
+		return func() { tx.Commit() } //nolint:errcheck
+	}
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unlock := lockUsers()
+	_, err = gj.GraphQL(ctx, gql, []byte(`{"name": "Locked"}`), nil)
+	unlock()
+	if err == nil {
+		t.Fatal("expected the mutation to fail on the locked table")
+	}
+
+	conf = &core.Config{
+		DBType:             "sqlite",
+		DisableAllowList:   true,
+		MutationRetries:    5,
+		MutationRetryDelay: 100 * time.Millisecond,
+	}
+	gj, err = core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unlock = lockUsers()
+	time.AfterFunc(time.Second, unlock)
+
+	res, err := gj.GraphQL(ctx, gql, []byte(`{"name": "Retried"}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":{"id":1,"full_name":"Retried"}}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+}
+
+func TestMutationRetryScript(t *testing.T) {
+	db := newTestDB(t, "retrydb2")
+	ctx := context.Background()
+
+	// the insert of a product fails while the flag is set, after the
+	// insert of its owner in the same script
+	_, err := db.Exec(`
+		CREATE TABLE fail_inserts (fail INTEGER);
+		INSERT INTO fail_inserts (fail) VALUES (1);
+		CREATE TRIGGER products_fail BEFORE INSERT ON products
+		WHEN (SELECT fail FROM fail_inserts) = 1
+		BEGIN
+			SELECT RAISE(ABORT, 'database is locked');
+		END;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(300*time.Millisecond, func() {
+		db.Exec(`UPDATE fail_inserts SET fail = 0`) //nolint:errcheck
+	})
+
+	conf := &core.Config{
+		DBType:             "sqlite",
+		DisableAllowList:   true,
+		MutationRetries:    5,
+		MutationRetryDelay: 100 * time.Millisecond,
+	}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the nested insert runs as a script of several statements, the retry
+	// must not insert the owner committed by the failed attempt again
+	gql := `mutation {
+		users(insert: {
+			full_name: "User Three", email: "user3@test.com",
+			products: { name: "Lamp", price: 10 }
+		}) { id }
+	}`
+	if _, err := gj.GraphQL(ctx, gql, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	if err := db.QueryRow(`SELECT count(*) FROM users WHERE email = 'user3@test.com'`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected the user to be inserted once, got %d", n)
+	}
+}
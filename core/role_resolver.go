@@ -0,0 +1,40 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// RoleResolverFn returns the role to execute an operation with. Returning
+// an empty role falls back to the role from the context or the role query.
+type RoleResolverFn func(c context.Context, op Header) (string, error)
+
+// OptionSetRoleResolver sets the function used to resolve the role of every
+// request, the role it returns is used instead of the default resolution
+func OptionSetRoleResolver(fn RoleResolverFn) Option {
+	return func(s *graphjinEngine) error {
+		s.roleResolver = fn
+		return nil
+	}
+}
+
+// resolveRole sets the role returned by the role resolver if one is set
+func (s *gstate) resolveRole(c context.Context) error {
+	if s.gj.roleResolver == nil {
+		return nil
+	}
+	role, err := s.gj.roleResolver(c, Header{Type: opTypeOf(s.r.operation), Name: s.r.name})
+	if err != nil {
+		return fmt.Errorf("role resolver: %w", err)
+	}
+	if role != "" {
+		s.role = role
+		s.roleResolved = true
+	}
+	return nil
+}
+
+// useRoleQuery returns true if the role is set by the role query
+func (s *gstate) useRoleQuery() bool {
+	return s.role == "user" && s.gj.abacEnabled && !s.roleResolved
+}
@@ -0,0 +1,78 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+type planKey struct{}
+
+func TestRoleResolver(t *testing.T) {
+	db := newTestDB(t, "roleresolverdb1")
+
+	conf := &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		Roles: []core.Role{{
+			Name: "free",
+			Tables: []core.RoleTable{{
+				Name:  "users",
+				Query: &core.Query{Filters: []string{"{ id: { eq: 1 } }"}},
+			}},
+		}},
+	}
+
+	var ops []core.Header
+	gj, err := core.NewGraphJin(conf, db,
+		core.OptionSetRoleResolver(func(c context.Context, op core.Header) (string, error) {
+			ops = append(ops, op)
+			switch c.Value(planKey{}) {
+			case "free":
+				return "free", nil
+			case "broken":
+				return "", errors.New("plan not found")
+			}
+			return "", nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query getUsers { users(order_by: { id: asc }) { id } }`
+
+	// the role filters of the resolved role are applied
+	c := context.WithValue(context.Background(), planKey{}, "free")
+	res, err := gj.GraphQL(c, gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":1}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+	if res.Role() != "free" {
+		t.Fatalf("expected the role free, got: %s", res.Role())
+	}
+	if op := ops[0]; op.Type != core.OpQuery || op.Name != "getUsers" {
+		t.Fatalf("unexpected operation: %+v", op)
+	}
+
+	// an empty role falls back to the default resolution
+	res, err = gj.GraphQL(context.Background(), gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":1},{"id":2}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+	if res.Role() != "anon" {
+		t.Fatalf("expected the role anon, got: %s", res.Role())
+	}
+
+	c = context.WithValue(context.Background(), planKey{}, "broken")
+	if _, err := gj.GraphQL(c, gql, nil, nil); err == nil {
+		t.Fatal("expected the error of the role resolver")
+	}
+}
@@ -0,0 +1,26 @@
+package core
+
+import (
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// ErrForbidden is returned when a query or a mutation uses a table that is
+// not one of the allowed tables of its role
+var ErrForbidden = qcode.ErrForbidden
+
+// setRoleTables sets the tables each role with allowed tables can use
+func (gj *graphjinEngine) setRoleTables(qcc *qcode.Config) {
+	for _, r := range gj.conf.Roles {
+		if len(r.AllowedTables) == 0 {
+			continue
+		}
+		if qcc.RoleTables == nil {
+			qcc.RoleTables = make(map[string]map[string]bool)
+		}
+		tables := make(map[string]bool, len(r.AllowedTables))
+		for _, t := range r.AllowedTables {
+			tables[t] = true
+		}
+		qcc.RoleTables[r.Name] = tables
+	}
+}
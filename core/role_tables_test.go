@@ -0,0 +1,55 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestRoleAllowedTables(t *testing.T) {
+	db := newTestDB(t, "roletablesdb1")
+
+	conf := &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		Roles: []core.Role{
+			{Name: "user", AllowedTables: []string{"products"}},
+		},
+	}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 1)
+
+	res, err := gj.GraphQL(ctx, `query { products(order_by: { id: asc }) { id } }`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"products":[{"id":1},{"id":2}]}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+
+	forbidden := []string{
+		`query { users { id } }`,
+		`query { products { id owner { id } } }`,
+		`mutation { users(insert: { id: 3, full_name: "C", email: "c@test.com" }) { id } }`,
+		`mutation { products(insert: { id: 3, name: "P3", price: 3,
+			owner: { id: 3, full_name: "C", email: "c@test.com" } }) { id } }`,
+	}
+	for _, gql := range forbidden {
+		_, err := gj.GraphQL(ctx, gql, nil, nil)
+		if !errors.Is(err, core.ErrForbidden) || !strings.Contains(err.Error(), "'users'") {
+			t.Fatalf("expected a forbidden error naming users for %s, got: %v", gql, err)
+		}
+	}
+
+	// roles without allowed tables can use all tables
+	if _, err := gj.GraphQL(context.Background(), `query { products { id owner { id } } }`, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
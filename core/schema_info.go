@@ -0,0 +1,207 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/dosco/graphjin/core/v3/internal/sdata"
+)
+
+// SchemaInfo describes the tables, columns and relationships discovered in a database
+type SchemaInfo struct {
+	Database      string               `json:"database"`
+	Type          string               `json:"type"` // postgres, mysql, mongodb, etc.
+	Tables        []SchemaTable        `json:"tables"`
+	Relationships []SchemaRelationship `json:"relationships"`
+}
+
+// SchemaTable describes a table and its columns
+type SchemaTable struct {
+	Name       string       `json:"name"`
+	Schema     string       `json:"schema,omitempty"`
+	Type       string       `json:"type"`
+	Comment    string       `json:"comment,omitempty"`
+	PrimaryKey string       `json:"primary_key,omitempty"`
+	Columns    []ColumnInfo `json:"columns"`
+}
+
+// SchemaRelationship describes how two tables are joined. The type is
+// one_to_one, one_to_many, many_to_many, recursive, polymorphic, embedded
+// or remote. Many-to-many relationships are joined through a join table.
+type SchemaRelationship struct {
+	Type    string         `json:"type"`
+	From    SchemaJoin     `json:"from"`
+	To      SchemaJoin     `json:"to"`
+	Through *SchemaThrough `json:"through,omitempty"`
+}
+
+// SchemaJoin is a table and the column it is joined on
+type SchemaJoin struct {
+	Table  string `json:"table"`
+	Schema string `json:"schema,omitempty"`
+	Column string `json:"column"`
+}
+
+// SchemaThrough is the join table of a many-to-many relationship and
+// its columns referencing the tables on either side
+type SchemaThrough struct {
+	Table      string `json:"table"`
+	Schema     string `json:"schema,omitempty"`
+	FromColumn string `json:"from_column"`
+	ToColumn   string `json:"to_column"`
+}
+
+// Schema returns the tables and relationships of the default database
+func (g *GraphJin) Schema() (*SchemaInfo, error) {
+	return g.SchemaForDatabase("")
+}
+
+// SchemaForDatabase returns the tables and relationships of a database,
+// if database is empty the default database is used.
+func (g *GraphJin) SchemaForDatabase(database string) (*SchemaInfo, error) {
+	gj, err := g.getEngine()
+	if err != nil {
+		return nil, err
+	}
+	ctx, ok := gj.GetDatabase(database)
+	if !ok {
+		return nil, fmt.Errorf("database not found: %s", database)
+	}
+	if ctx.schema == nil {
+		return nil, fmt.Errorf("schema not initialized: %s", ctx.name)
+	}
+	return buildSchemaInfo(ctx.schema, ctx.name), nil
+}
+
+// buildSchemaInfo builds a SchemaInfo from a database schema, virtual
+// and blocked tables are left out like they are from the table list
+func buildSchemaInfo(dbSchema *sdata.DBSchema, dbName string) *SchemaInfo {
+	si := &SchemaInfo{
+		Database:      dbName,
+		Type:          dbSchema.DBType(),
+		Tables:        []SchemaTable{},
+		Relationships: []SchemaRelationship{},
+	}
+
+	for _, t := range dbSchema.GetTables() {
+		if t.Type == "virtual" || t.Blocked {
+			continue
+		}
+		st := SchemaTable{
+			Name:       t.Name,
+			Schema:     t.Schema,
+			Type:       t.Type,
+			Comment:    t.Comment,
+			PrimaryKey: t.PrimaryCol.Name,
+			Columns:    make([]ColumnInfo, 0, len(t.Columns)),
+		}
+		for _, col := range t.Columns {
+			ci := ColumnInfo{
+				Name:       col.Name,
+				Type:       col.Type,
+				Nullable:   !col.NotNull,
+				PrimaryKey: col.PrimaryKey,
+				Array:      col.Array,
+				Default:    col.Default,
+			}
+			if col.FKeyTable != "" {
+				ci.ForeignKey = fmt.Sprintf("%s.%s", col.FKeyTable, col.FKeyCol)
+			}
+			st.Columns = append(st.Columns, ci)
+		}
+		si.Tables = append(si.Tables, st)
+	}
+
+	for _, rel := range dbSchema.GetRelationships() {
+		if rel.Left.Ti.Blocked || rel.Right.Ti.Blocked {
+			continue
+		}
+		si.Relationships = append(si.Relationships, SchemaRelationship{
+			Type: relTypeToString(rel.Type),
+			From: SchemaJoin{
+				Table:  rel.Left.Ti.Name,
+				Schema: rel.Left.Ti.Schema,
+				Column: rel.Left.Col.Name,
+			},
+			To: SchemaJoin{
+				Table:  rel.Right.Ti.Name,
+				Schema: rel.Right.Ti.Schema,
+				Column: rel.Right.Col.Name,
+			},
+		})
+	}
+
+	for _, t := range dbSchema.GetTables() {
+		if t.Type == "virtual" || t.Blocked {
+			continue
+		}
+		si.Relationships = append(si.Relationships, throughRelationships(dbSchema, t)...)
+	}
+
+	return si
+}
+
+// throughRelationships returns the many-to-many relationships joined
+// through a table, these are the paths through the table that are used
+// to join its related tables in a query
+func throughRelationships(dbSchema *sdata.DBSchema, jt sdata.DBTable) []SchemaRelationship {
+	relNodes, err := dbSchema.GetFirstDegree(jt)
+	if err != nil {
+		return nil
+	}
+
+	var tables []sdata.DBTable
+	seen := make(map[string]struct{})
+	for _, rn := range relNodes {
+		k := rn.Table.Schema + ":" + rn.Table.Name
+		if _, ok := seen[k]; ok || rn.Table.Blocked || rn.Table.Name == jt.Name {
+			continue
+		}
+		seen[k] = struct{}{}
+		tables = append(tables, rn.Table)
+	}
+
+	var rels []SchemaRelationship
+	for _, from := range tables {
+		for _, to := range tables {
+			if from.Name == to.Name {
+				continue
+			}
+			paths, err := dbSchema.FindPath(from.Name, to.Name, jt.Name)
+			if err != nil || len(paths) != 2 {
+				continue
+			}
+			// the path enters the join table from one side and leaves
+			// it through a foreign key to the other side
+			p1, p2 := paths[0], paths[1]
+			if p1.Rel != sdata.RelOneToMany || p1.RT.Name != jt.Name ||
+				p2.Rel != sdata.RelOneToOne || p2.LT.Name != jt.Name {
+				continue
+			}
+			// each pair is returned once in the order of the columns
+			// of the join table
+			if c1, c2 := p1.RC, p2.LC; c1.ID > c2.ID || (c1.ID == c2.ID && c1.Name > c2.Name) {
+				continue
+			}
+			rels = append(rels, SchemaRelationship{
+				Type: "many_to_many",
+				From: SchemaJoin{
+					Table:  p1.LT.Name,
+					Schema: p1.LT.Schema,
+					Column: p1.LC.Name,
+				},
+				To: SchemaJoin{
+					Table:  p2.RT.Name,
+					Schema: p2.RT.Schema,
+					Column: p2.RC.Name,
+				},
+				Through: &SchemaThrough{
+					Table:      jt.Name,
+					Schema:     jt.Schema,
+					FromColumn: p1.RC.Name,
+					ToColumn:   p2.LC.Name,
+				},
+			})
+		}
+	}
+	return rels
+}
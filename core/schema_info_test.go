@@ -0,0 +1,90 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestSchema(t *testing.T) {
+	db := newTestDB(t, "schemadb1")
+
+	_, err := db.Exec(`
+		CREATE TABLE tags (
+			id INTEGER PRIMARY KEY,
+			name TEXT
+		);
+		CREATE TABLE product_tags (
+			id INTEGER PRIMARY KEY,
+			product_id INTEGER REFERENCES products(id),
+			tag_id INTEGER REFERENCES tags(id)
+		);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	si, err := gj.Schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if si.Type != "sqlite" {
+		t.Fatalf("expected the sqlite database, got: %s", si.Type)
+	}
+
+	var products *core.SchemaTable
+	for i, st := range si.Tables {
+		if st.Name == "products" {
+			products = &si.Tables[i]
+		}
+	}
+	if products == nil {
+		t.Fatalf("expected the products table, got: %v", si.Tables)
+	}
+	if products.PrimaryKey != "id" || len(products.Columns) != 4 {
+		t.Fatalf("unexpected products table: %+v", products)
+	}
+
+	find := func(typ, from, to string) *core.SchemaRelationship {
+		for i, r := range si.Relationships {
+			if r.Type == typ && r.From.Table == from && r.To.Table == to {
+				return &si.Relationships[i]
+			}
+		}
+		t.Fatalf("expected a %s relationship from %s to %s, got: %+v",
+			typ, from, to, si.Relationships)
+		return nil
+	}
+
+	r := find("one_to_one", "products", "users")
+	if r.From.Column != "owner_id" || r.To.Column != "id" {
+		t.Fatalf("unexpected join columns: %+v", r)
+	}
+
+	r = find("many_to_many", "products", "tags")
+	if r.Through == nil || r.Through.Table != "product_tags" ||
+		r.Through.FromColumn != "product_id" || r.Through.ToColumn != "tag_id" {
+		t.Fatalf("unexpected join table: %+v", r.Through)
+	}
+
+	// products only links users to product_tags, it is not a join table
+	var m2m int
+	for _, r := range si.Relationships {
+		if r.Type == "many_to_many" {
+			m2m++
+		}
+	}
+	if m2m != 1 {
+		t.Fatalf("expected a single many_to_many relationship, got: %+v", si.Relationships)
+	}
+
+	if _, err := gj.SchemaForDatabase("missing"); err == nil {
+		t.Fatal("expected an error for an unknown database")
+	}
+}
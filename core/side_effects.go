@@ -0,0 +1,82 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+	"github.com/dosco/graphjin/core/v3/internal/sdata"
+)
+
+// checkSideEffects checks the side effects of a table against the database
+// info, the column whose value selects the documents updated must exist
+func checkSideEffects(dbInfo *sdata.DBInfo, t *sdata.DBTable, table Table) error {
+	if len(table.SideEffects) == 0 {
+		return nil
+	}
+	if dbInfo.Type != "mongodb" {
+		return fmt.Errorf("side_effects: '%s' is only supported on mongodb", table.Name)
+	}
+	for _, se := range table.SideEffects {
+		if _, err := t.GetColumn(se.Column); err != nil {
+			return fmt.Errorf("side_effects: %s: %w", table.Name, err)
+		}
+	}
+	return nil
+}
+
+// newSideEffects returns the side effects of the table for the compiler
+func newSideEffects(t Table) (ses []qcode.SideEffect, err error) {
+	for _, se := range t.SideEffects {
+		se1, err := newSideEffect(se)
+		if err != nil {
+			return nil, fmt.Errorf("side_effects: %s: %s: %w", t.Name, se.Collection, err)
+		}
+		ses = append(ses, se1)
+	}
+	return
+}
+
+func newSideEffect(se SideEffect) (se1 qcode.SideEffect, err error) {
+	switch {
+	case se.Collection == "":
+		return se1, errors.New("collection required")
+	case se.Column == "":
+		return se1, errors.New("column required")
+	case (se.Update == nil) == (len(se.Pipeline) == 0):
+		return se1, errors.New("either update or pipeline required")
+	}
+
+	for _, on := range se.On {
+		switch on {
+		case "insert":
+			se1.On = append(se1.On, qcode.MTInsert)
+		case "update":
+			se1.On = append(se1.On, qcode.MTUpdate)
+		case "delete":
+			se1.On = append(se1.On, qcode.MTDelete)
+		default:
+			return se1, fmt.Errorf("on: invalid value '%s': must be 'insert', 'update' or 'delete'", on)
+		}
+	}
+
+	se1.Collection = se.Collection
+	se1.Col = se.Column
+	se1.Key = se.Key
+	if se1.Key == "" {
+		se1.Key = "_id"
+	}
+
+	if se.Update != nil {
+		if se1.Update, err = json.Marshal(se.Update); err != nil {
+			return se1, fmt.Errorf("update: %w", err)
+		}
+	}
+	if len(se.Pipeline) != 0 {
+		if se1.Pipeline, err = json.Marshal(se.Pipeline); err != nil {
+			return se1, fmt.Errorf("pipeline: %w", err)
+		}
+	}
+	return se1, nil
+}
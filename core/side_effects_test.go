@@ -0,0 +1,30 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestSideEffectsConfig(t *testing.T) {
+	db := newTestDB(t, "sideeffectsdb1")
+
+	// side effects are only supported on mongodb
+	conf := &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		Tables: []core.Table{{
+			Name: "products",
+			SideEffects: []core.SideEffect{{
+				Collection: "users",
+				Column:     "owner_id",
+				Update:     map[string]interface{}{"$inc": map[string]interface{}{"product_count": 1}},
+			}},
+		}},
+	}
+	_, err := core.NewGraphJin(conf, db)
+	if err == nil || !strings.Contains(err.Error(), "side_effects: 'products' is only supported on mongodb") {
+		t.Fatalf("expected an error for the side effects, got: %v", err)
+	}
+}
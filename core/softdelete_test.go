@@ -0,0 +1,70 @@
+package core_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestSoftDelete(t *testing.T) {
+	db := newTestDB(t, "softdeletedb1")
+
+	if _, err := db.Exec(`ALTER TABLE products ADD COLUMN deleted_at TEXT`); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		Tables:           []core.Table{{Name: "products", SoftDeleteColumn: "deleted_at"}},
+	}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	run := func(gql, vars, exp string) {
+		t.Helper()
+		res, err := gj.GraphQL(ctx, gql, []byte(vars), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res.Data) != exp {
+			t.Fatalf("expected: %s, got: %s", exp, res.Data)
+		}
+	}
+
+	run(`mutation { products(id: 2, delete: true) { id } }`, ``, `{"products":{"id":2}}`)
+
+	// the row is kept with the soft-delete column set
+	var deletedAt sql.NullString
+	err = db.QueryRow(`SELECT deleted_at FROM products WHERE id = 2`).Scan(&deletedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deletedAt.Valid {
+		t.Fatal("expected the soft-delete column to be set")
+	}
+
+	run(`query { products { id } }`, ``, `{"products":[{"id":1}]}`)
+	run(`query { users(id: 1) { products { id } } }`, ``, `{"users":{"products":[{"id":1}]}}`)
+	run(`query { products(with_deleted: true, order_by: { id: asc }) { id } }`, ``,
+		`{"products":[{"id":1},{"id":2}]}`)
+
+	// a soft deleted row is not deleted again
+	run(`mutation { products(id: 2, delete: true) { id } }`, ``, `{"products":null}`)
+
+	// restore the row by clearing the column
+	run(`mutation { products(id: 2, with_deleted: true, update: $data) { id } }`,
+		`{"data": {"deleted_at": null}}`, `{"products":{"id":2}}`)
+	run(`query { products(order_by: { id: asc }) { id } }`, ``, `{"products":[{"id":1},{"id":2}]}`)
+
+	// only tables with a soft-delete column have deleted rows
+	_, err = gj.GraphQL(ctx, `query { users(with_deleted: true) { id } }`, nil, nil)
+	if err == nil {
+		t.Fatal("expected an error for with_deleted on a table without a soft-delete column")
+	}
+}
@@ -0,0 +1,25 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestStaticTableUnsupported(t *testing.T) {
+	db := newTestDB(t, "staticdb1")
+
+	conf := &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		Tables: []core.Table{{
+			Name:    "statuses",
+			Type:    "static",
+			Columns: []core.Column{{Name: "code", Type: "text", Primary: true}},
+			Rows:    []map[string]interface{}{{"code": "N"}},
+		}},
+	}
+	if _, err := core.NewGraphJin(conf, db); err == nil {
+		t.Fatal("expected an error for a static table on sqlite")
+	}
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// stmtCache is a bounded cache of the prepared statements of the compiled
+// queries of a database keyed by the hash of the query. Statements are
+// prepared on the connection pool and closed once evicted from the cache.
+type stmtCache struct {
+	db    *sql.DB
+	stmts *lru.Cache[[32]byte, *cachedStmt]
+}
+
+// cachedStmt is a prepared statement in the cache, it is only closed
+// once the queries running on it are done
+type cachedStmt struct {
+	mu     sync.RWMutex
+	stmt   *sql.Stmt
+	closed bool
+}
+
+func newStmtCache(db *sql.DB, size int) (*stmtCache, error) {
+	stmts, err := lru.NewWithEvict(size, func(_ [32]byte, cs *cachedStmt) {
+		go cs.close()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stmtCache{db: db, stmts: stmts}, nil
+}
+
+// initStmtCache creates the prepared statement cache of the database when
+// it's enabled in its config. There is no use for one with MongoDB and
+// Snowflake since their queries are not prepared by the database.
+func (gj *graphjinEngine) initStmtCache(ctx *dbContext) (err error) {
+	dc, ok := gj.conf.Databases[ctx.name]
+	if !ok || dc.PreparedStatements <= 0 || ctx.db == nil {
+		return nil
+	}
+	switch ctx.dbtype {
+	case "mongodb", "snowflake":
+		return nil
+	}
+	ctx.stmts, err = newStmtCache(ctx.db, dc.PreparedStatements)
+	return
+}
+
+// closeStmtCaches closes the prepared statements of all databases, they
+// are invalid once the schema is reloaded
+func (gj *graphjinEngine) closeStmtCaches() {
+	for _, ctx := range gj.databases {
+		if ctx.stmts != nil {
+			ctx.stmts.purge()
+		}
+	}
+}
+
+// queryRow runs the query on its prepared statement and scans the row it returns
+func (sc *stmtCache) queryRow(c context.Context, query string, args []interface{}, dest interface{}) error {
+	for {
+		cs, err := sc.get(c, query)
+		if err != nil {
+			return err
+		}
+		cs.mu.RLock()
+		if cs.closed {
+			// evicted before it could be used, it's prepared again
+			cs.mu.RUnlock()
+			continue
+		}
+		err = cs.stmt.QueryRowContext(c, args...).Scan(dest)
+		cs.mu.RUnlock()
+		return err
+	}
+}
+
+// get returns the prepared statement of the query, it's prepared
+// and added to the cache if it's not in it
+func (sc *stmtCache) get(c context.Context, query string) (*cachedStmt, error) {
+	key := sha256.Sum256([]byte(query))
+	if cs, ok := sc.stmts.Get(key); ok {
+		return cs, nil
+	}
+
+	stmt, err := sc.db.PrepareContext(c, query)
+	if err != nil {
+		return nil, err
+	}
+	cs := &cachedStmt{stmt: stmt}
+
+	// another execution of the query may have prepared it meanwhile
+	if prev, ok, _ := sc.stmts.PeekOrAdd(key, cs); ok {
+		stmt.Close() //nolint:errcheck
+		return prev, nil
+	}
+	return cs, nil
+}
+
+// len returns the number of prepared statements in the cache
+func (sc *stmtCache) len() int {
+	return sc.stmts.Len()
+}
+
+// purge removes and closes all the prepared statements
+func (sc *stmtCache) purge() {
+	sc.stmts.Purge()
+}
+
+func (cs *cachedStmt) close() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if !cs.closed {
+		cs.closed = true
+		cs.stmt.Close() //nolint:errcheck
+	}
+}
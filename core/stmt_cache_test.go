@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestStmtCache(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:stmtcache?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() //nolint:errcheck
+
+	_, err = db.Exec(`
+		CREATE TABLE products (id INTEGER PRIMARY KEY, name TEXT, price REAL);
+		INSERT INTO products (id, name, price) VALUES (1, 'One', 10), (2, 'Two', 20);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		Databases: map[string]DatabaseConfig{
+			DefaultDBName: {Type: "sqlite", PreparedStatements: 2},
+		},
+	}
+	gj, err := NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []string{
+		`query { products(where: { id: $id }) { id } }`,
+		`query { products(where: { id: $id }) { name } }`,
+		`query { products(where: { id: $id }) { price } }`,
+	}
+	exp := []string{
+		`{"products":[{"id":1}]}`, `{"products":[{"id":2}]}`,
+		`{"products":[{"name":"One"}]}`, `{"products":[{"name":"Two"}]}`,
+		`{"products":[{"price":10.0}]}`, `{"products":[{"price":20.0}]}`,
+	}
+
+	// queries are run concurrently on statements evicted from the
+	// cache and prepared again
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n, id := i%len(queries), i%2+1
+			res, err := gj.GraphQL(context.Background(), queries[n],
+				[]byte(fmt.Sprintf(`{"id": %d}`, id)), nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if want, got := exp[n*2+id-1], string(res.Data); got != want {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	e, err := gj.getEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := e.databases[DefaultDBName].stmts
+	if sc == nil {
+		t.Fatal("expected a prepared statement cache")
+	}
+	if n := sc.len(); n != 2 {
+		t.Fatalf("expected the cache to be bounded to 2 statements, got %d", n)
+	}
+
+	// the statements of the previous schema are closed on reload
+	if err := gj.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if n := sc.len(); n != 0 {
+		t.Fatalf("expected the statements to be closed on reload, got %d", n)
+	}
+	res, err := gj.GraphQL(context.Background(), queries[0], []byte(`{"id": 2}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(res.Data); got != `{"products":[{"id":2}]}` {
+		t.Fatalf("unexpected result after reload: %s", got)
+	}
+}
@@ -76,6 +76,13 @@ type Member struct {
 	mm     mmsg
 	// indices of cursor value in the arguments array
 	cindxs []int
+	// cancel stops the change stream watched by the member
+	cancel context.CancelFunc
+	// res is the channel the results are sent to, it's Result unless the
+	// results are throttled
+	res chan *Result
+	// stop ends the throttling of the results
+	stop chan struct{}
 }
 
 // Subscribe function is called on the GraphJin struct to subscribe to query.
@@ -103,9 +110,21 @@ func (g *GraphJin) Subscribe(
 	// create the request object
 	r := gj.newGraphqlReq(rc, "subscription", h.Name, nil, vars)
 
+	if err = gj.checkVarsLimits(r.operation, vars); err != nil {
+		return
+	}
+
+	if err = gj.checkReadOnly(r.operation); err != nil {
+		return
+	}
+
+	if r.bypass, err = gj.allowListBypassed(rc, h.Name, []byte(query)); err != nil {
+		return
+	}
+
 	// if production security enabled then get query and metadata
 	// from allow list
-	if gj.prodSec {
+	if gj.prodSec && !r.bypass {
 		var item allow.Item
 		item, err = gj.allowList.GetByName(h.Name, true)
 		if err != nil {
@@ -140,6 +159,14 @@ func (g *GraphJin) SubscribeByName(
 	r := gj.newGraphqlReq(rc, "subscription", name, nil, vars)
 	r.Set(item)
 
+	if err = gj.checkVarsLimits(r.operation, vars); err != nil {
+		return
+	}
+
+	if err = gj.checkReadOnly(r.operation); err != nil {
+		return
+	}
+
 	m, err = gj.subscribe(c, r)
 	return
 }
@@ -157,6 +184,10 @@ func (gj *graphjinEngine) subscribe(c context.Context, r GraphqlReq) (
 		return nil, errors.New("subscription: database transactions not supported")
 	}
 
+	if r.requestconfig != nil && r.requestconfig.Dialect != "" {
+		return nil, errDialectOverride
+	}
+
 	if r.name == "" {
 		h := sha256.Sum256([]byte(r.query))
 		r.name = hex.EncodeToString(h[:])
@@ -167,7 +198,7 @@ func (gj *graphjinEngine) subscribe(c context.Context, r GraphqlReq) (
 		return
 	}
 
-	if s.role == "user" && gj.abacEnabled {
+	if s.useRoleQuery() {
 		if err = s.executeRoleQuery(c, nil); err != nil {
 			return
 		}
@@ -194,6 +225,17 @@ func (gj *graphjinEngine) subscribe(c context.Context, r GraphqlReq) (
 			return
 		}
 
+		// every subscriber is charged the cost of the first query and then
+		// of each poll of the subscription
+		if err = gj.spendCost(sub.s.cs.st.roc, sub.s.cs.st.cost); err != nil {
+			return
+		}
+
+		// change streams are watched by each member instead of being polled
+		if sub.s.cs.st.qc.HasChangeStream() {
+			return gj.watchChangeStream(c, sub, s)
+		}
+
 		// don't use the vmap in the sub gstate use the new
 		// one that was created this current subscription
 		args, err1 := sub.s.argListForSub(c, s.vmap)
@@ -210,9 +252,18 @@ func (gj *graphjinEngine) subscribe(c context.Context, r GraphqlReq) (
 			params: args.json,
 			cindxs: args.cindxs,
 		}
+		m.res = m.Result
+
+		// throttled results are coalesced before they are delivered
+		if d := sub.s.cs.st.qc.Throttle; d != 0 {
+			m.res = make(chan *Result, 10)
+			m.stop = make(chan struct{})
+			go throttleResults(m.res, m.Result, d, m.stop)
+		}
 
 		m.mm, err = gj.subFirstQuery(sub, m)
 		if err != nil {
+			m.stopThrottle()
 			return nil, err
 		}
 
@@ -220,6 +271,7 @@ func (gj *graphjinEngine) subscribe(c context.Context, r GraphqlReq) (
 		case sub.add <- m:
 			return
 		case <-sub.done:
+			m.stopThrottle()
 			gj.subs.Delete(k)
 			continue
 		}
@@ -232,6 +284,10 @@ func (gj *graphjinEngine) initSub(c context.Context, sub *sub) (err error) {
 		return
 	}
 
+	if err = gj.checkReadOnlyDB(sub.s.r.operation, sub.s.database); err != nil {
+		return
+	}
+
 	if !gj.prod {
 		err = gj.saveToAllowList(sub.s.cs.st.qc, sub.s.r.namespace)
 		if err != nil {
@@ -239,6 +295,10 @@ func (gj *graphjinEngine) initSub(c context.Context, sub *sub) (err error) {
 		}
 	}
 
+	if sub.s.cs.st.qc.HasChangeStream() {
+		return
+	}
+
 	// Only wrap subscriptions for batching if the dialect supports it
 	targetCtx := sub.s.getTargetDBCtx()
 	if len(sub.s.cs.st.md.Params()) != 0 && dialectSupportsSubscriptionBatching(targetCtx.schema.DBType()) {
@@ -316,7 +376,7 @@ func (s *sub) addMember(m *Member) error {
 
 	s.params = append(s.params, m.params)
 	s.mi = append(s.mi, mi)
-	s.res = append(s.res, m.Result)
+	s.res = append(s.res, m.res)
 	s.ids = append(s.ids, m.id)
 
 	return nil
@@ -416,6 +476,14 @@ func (s *sub) fanOutJobs(gj *graphjinEngine) {
 		return
 	}
 
+	// the poll is skipped until the role has budget for all the members
+	st := s.s.cs.st
+	if err := gj.spendCost(st.roc, st.cost*len(mv.ids)); err != nil {
+		gj.log.Printf(errSubs, "poll", err)
+		s.endPollCycle()
+		return
+	}
+
 	// Run the full poll cycle asynchronously so the controller can continue
 	// handling add/remove/update events while polling is in progress.
 	go func(mv mval) {
@@ -616,7 +684,7 @@ func (gj *graphjinEngine) subFirstQuery(sub *sub, m *Member) (mmsg, error) {
 		[32]byte{},
 		m.cindxs,
 		m.id,
-		m.Result, js, false)
+		m.res, js, false)
 
 	return mm, err
 }
@@ -688,7 +756,7 @@ func (gj *graphjinEngine) subNotifyMemberEx(sub *sub,
 		name:      sub.s.r.name,
 		sql:       sub.s.cs.st.sql,
 		role:      sub.s.cs.st.role,
-		Data:      ejs,
+		Data:      gj.outputData(ejs),
 	}
 
 	// If this is an update notification, avoid blocking indefinitely by using a timeout.
@@ -824,11 +892,24 @@ func (s *sub) findByID(id uint64) (int, bool) {
 // Unsubscribe function is called on the member struct to unsubscribe.
 func (m *Member) Unsubscribe() {
 	if m != nil && !m.done {
-		m.sub.del <- m
+		if m.cancel != nil {
+			m.cancel()
+		} else {
+			m.sub.del <- m
+		}
+		m.stopThrottle()
 		m.done = true
 	}
 }
 
+// stopThrottle ends the throttling of the results of the member
+func (m *Member) stopThrottle() {
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
+
 // ID function is called on the member struct to get the id.
 func (m *Member) ID() uint64 {
 	return m.id
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// watchChangeStream subscribes the member to the change stream of the
+// subscription's collection. Unlike polled subscriptions every member watches
+// its own change stream since the filter uses the member's variables.
+func (gj *graphjinEngine) watchChangeStream(c context.Context, sub *sub, s gstate) (*Member, error) {
+	args, err := sub.s.argListForSub(c, s.vmap)
+	if err != nil {
+		return nil, err
+	}
+
+	subDBCtx := sub.s.getTargetDBCtx()
+	q, qargs, err := prepareQueryArgsForDB(subDBCtx.dbtype, sub.s.cs.st.sql, args.values)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// the change stream is opened before returning so that changes made
+	// after subscribing are not missed
+	//nolint: sqlclosecheck
+	rows, err := subDBCtx.db.QueryContext(ctx, q, qargs...)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf(errSubs, "change-stream", err)
+	}
+
+	m := &Member{
+		ns:     s.r.namespace,
+		id:     atomic.AddUint64(&sub.idgen, 1),
+		Result: make(chan *Result, 10),
+		sub:    sub,
+		params: args.json,
+		cancel: cancel,
+	}
+
+	go func() {
+		defer cancel()
+		defer rows.Close() //nolint:errcheck
+
+		go func() {
+			select {
+			case <-gj.done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		var b []byte
+		for rows.Next() {
+			if err := rows.Scan(&b); err != nil {
+				gj.log.Printf(errSubs, "scan", err)
+				return
+			}
+			if err := gj.notifyChange(ctx, sub, m, b); err != nil {
+				gj.log.Printf(errSubs, "change-stream", err)
+				return
+			}
+		}
+		if err := rows.Err(); err != nil && ctx.Err() == nil {
+			gj.log.Printf(errSubs, "change-stream", err)
+		}
+	}()
+
+	return m, nil
+}
+
+// notifyChange sends a change read from the change stream to the member
+func (gj *graphjinEngine) notifyChange(c context.Context, sub *sub, m *Member, js []byte) error {
+	nonce := sha256.Sum256(js)
+
+	ejs, err := encryptValues(js,
+		gj.printFormat,
+		decPrefix,
+		nonce[:],
+		gj.encryptionKey)
+	if err != nil {
+		return err
+	}
+
+	res := &Result{
+		operation: qcode.QTQuery,
+		name:      sub.s.r.name,
+		sql:       sub.s.cs.st.sql,
+		role:      sub.s.cs.st.role,
+		Data:      gj.outputData(ejs),
+	}
+
+	// changes are not dropped like polled results since every
+	// change is only delivered once
+	select {
+	case m.Result <- res:
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("member %d is not reading changes", m.id)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package core
+
+import "time"
+
+// throttleResults delivers the results sent to in to out at most once every
+// d. The results sent within a window are coalesced and only the latest is
+// delivered when the window ends, so the final state is always delivered.
+func throttleResults(in <-chan *Result, out chan<- *Result, d time.Duration, stop <-chan struct{}) {
+	var pending *Result
+	var last time.Time
+	var tc <-chan time.Time
+
+	deliver := func(r *Result) bool {
+		select {
+		case out <- r:
+			last = time.Now()
+			return true
+		case <-stop:
+			return false
+		}
+	}
+
+	for {
+		select {
+		case r := <-in:
+			if tc != nil {
+				pending = r
+				continue
+			}
+			if wait := d - time.Since(last); wait > 0 {
+				pending = r
+				tc = time.After(wait)
+				continue
+			}
+			if !deliver(r) {
+				return
+			}
+
+		case <-tc:
+			tc = nil
+			if !deliver(pending) {
+				return
+			}
+			pending = nil
+
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottleResults(t *testing.T) {
+	in := make(chan *Result, 10)
+	out := make(chan *Result, 10)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	d := 100 * time.Millisecond
+	go throttleResults(in, out, d, stop)
+
+	r1, r2, r3 := &Result{name: "1"}, &Result{name: "2"}, &Result{name: "3"}
+
+	// the first result is delivered right away
+	in <- r1
+	select {
+	case r := <-out:
+		if r != r1 {
+			t.Fatalf("expected the first result, got %s", r.name)
+		}
+	case <-time.After(d / 2):
+		t.Fatal("expected the first result to be delivered right away")
+	}
+
+	// the results within the window are coalesced into the latest
+	in <- r2
+	in <- r3
+	select {
+	case r := <-out:
+		t.Fatalf("expected no result within the window, got %s", r.name)
+	case <-time.After(d / 2):
+	}
+	select {
+	case r := <-out:
+		if r != r3 {
+			t.Fatalf("expected the latest result, got %s", r.name)
+		}
+	case <-time.After(d):
+		t.Fatal("expected the latest result at the end of the window")
+	}
+
+	// and nothing else is delivered once idle
+	select {
+	case r := <-out:
+		t.Fatalf("expected no more results, got %s", r.name)
+	case <-time.After(2 * d):
+	}
+}
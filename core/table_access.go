@@ -0,0 +1,141 @@
+package core
+
+import (
+	"sort"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+	"github.com/dosco/graphjin/core/v3/internal/sdata"
+)
+
+// TableAccess is a table a query reads or writes and the columns it uses,
+// a mutation also reads the tables of the rows it returns
+type TableAccess struct {
+	Schema  string   `json:"schema,omitempty"`
+	Table   string   `json:"table"`
+	Columns []string `json:"columns,omitempty"`
+	Write   bool     `json:"write,omitempty"`
+}
+
+// Tables returns the tables the query read or wrote, reads and writes of a
+// table are listed apart
+func (r *Result) Tables() []TableAccess {
+	return tableAccess(r.qc)
+}
+
+type tableAccessKey struct {
+	schema, table string
+	write         bool
+}
+
+type tableAccessSet map[tableAccessKey]map[string]struct{}
+
+// tableAccess returns the tables and columns the selects of the query read
+// and its mutations write, sorted by table with the read before the write
+func tableAccess(qc *qcode.QCode) []TableAccess {
+	if qc == nil {
+		return nil
+	}
+	ts := make(tableAccessSet)
+
+	for i := range qc.Selects {
+		sel := &qc.Selects[i]
+		if sel.SkipRender != qcode.SkipTypeNone ||
+			sel.Rel.Type == sdata.RelRemote || sel.Ti.Name == "" {
+			continue
+		}
+		cols := ts.add(sel.Ti.Schema, sel.Ti.Name, false)
+		for _, f := range sel.Fields {
+			if f.Type == qcode.FieldTypeCol && f.SkipRender == qcode.SkipTypeNone {
+				cols[f.Col.Name] = struct{}{}
+			}
+		}
+		for _, ob := range sel.OrderBy {
+			ts.addCol(ob.Col)
+		}
+		ts.addRel(sel.Rel)
+		for _, j := range sel.Joins {
+			ts.addRel(j.Rel)
+		}
+		ts.addExp(sel.Where.Exp)
+	}
+
+	for i := range qc.Mutates {
+		m := &qc.Mutates[i]
+		switch m.Type {
+		case qcode.MTInsert, qcode.MTUpdate, qcode.MTUpsert, qcode.MTDelete,
+			qcode.MTConnect, qcode.MTDisconnect:
+		default:
+			continue
+		}
+		cols := ts.add(m.Ti.Schema, m.Ti.Name, true)
+		for _, c := range m.Cols {
+			cols[c.Col.Name] = struct{}{}
+		}
+		for _, c := range m.RCols {
+			cols[c.Col.Name] = struct{}{}
+		}
+	}
+
+	tables := make([]TableAccess, 0, len(ts))
+	for k, cols := range ts {
+		t := TableAccess{Schema: k.schema, Table: k.table, Write: k.write}
+		for c := range cols {
+			t.Columns = append(t.Columns, c)
+		}
+		sort.Strings(t.Columns)
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		a, b := tables[i], tables[j]
+		if a.Schema != b.Schema {
+			return a.Schema < b.Schema
+		}
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		return !a.Write && b.Write
+	})
+	return tables
+}
+
+func (ts tableAccessSet) add(schema, table string, write bool) map[string]struct{} {
+	k := tableAccessKey{schema, table, write}
+	cols, ok := ts[k]
+	if !ok {
+		cols = make(map[string]struct{})
+		ts[k] = cols
+	}
+	return cols
+}
+
+// addCol adds a column read by the query to its table
+func (ts tableAccessSet) addCol(col sdata.DBColumn) {
+	if col.Table == "" || col.Name == "" {
+		return
+	}
+	ts.add(col.Schema, col.Table, false)[col.Name] = struct{}{}
+}
+
+// addRel adds the columns a relationship is joined on
+func (ts tableAccessSet) addRel(rel sdata.DBRel) {
+	if rel.Type == sdata.RelNone || rel.Type == sdata.RelRemote {
+		return
+	}
+	ts.addCol(rel.Left.Col)
+	ts.addCol(rel.Right.Col)
+}
+
+// addExp adds the columns a filter compares
+func (ts tableAccessSet) addExp(ex *qcode.Exp) {
+	if ex == nil {
+		return
+	}
+	ts.addCol(ex.Left.Col)
+	ts.addCol(ex.Right.Col)
+	for _, j := range ex.Joins {
+		ts.addRel(j.Rel)
+	}
+	for _, c := range ex.Children {
+		ts.addExp(c)
+	}
+}
@@ -0,0 +1,47 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestTableAccess(t *testing.T) {
+	db := newTestDB(t, "tableaccessdb1")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the tables are known without executing the query
+	cq, err := gj.Compile(context.Background(), `query {
+		products(where: { price: { gt: 1 } }, order_by: { name: asc }) {
+			id
+			owner { full_name }
+		}
+	}`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `[{"schema":"main","table":"products","columns":["id","name","owner_id","price"]},` +
+		`{"schema":"main","table":"users","columns":["full_name","id"]}]`
+	if b, _ := json.Marshal(cq.Tables); string(b) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, b)
+	}
+
+	// a mutation writes its table and reads the rows it returns
+	res, err := gj.GraphQL(context.Background(),
+		`mutation { products(id: 1, update: { price: 5 }) { id name } }`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp = `[{"schema":"main","table":"products","columns":["id","name"]},` +
+		`{"schema":"main","table":"products","columns":["price"],"write":true}]`
+	if b, _ := json.Marshal(res.Tables()); string(b) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, b)
+	}
+}
@@ -0,0 +1,80 @@
+package core_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestTableLimits(t *testing.T) {
+	db := newTestDB(t, "tablelimitdb1")
+
+	_, err := db.Exec(`INSERT INTO products (id, name, price, owner_id) VALUES
+		(3, 'Product Three', 1, 1), (4, 'Product Four', 1, 1), (5, 'Product Five', 1, 1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newGJ := func(limitErr bool) *core.GraphJin {
+		conf := &core.Config{
+			DBType:           "sqlite",
+			DisableAllowList: true,
+			Tables: []core.Table{{
+				Name:          "products",
+				DefaultLimit:  2,
+				MaxLimit:      3,
+				MaxLimitError: limitErr,
+			}},
+		}
+		gj, err := core.NewGraphJin(conf, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gj
+	}
+	ctx := context.Background()
+
+	gj := newGJ(false)
+	run := func(gql, vars, exp string) {
+		t.Helper()
+		res, err := gj.GraphQL(ctx, gql, []byte(vars), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res.Data) != exp {
+			t.Fatalf("expected: %s, got: %s", exp, res.Data)
+		}
+	}
+
+	run(`query { products(order_by: { id: asc }) { id } }`, ``,
+		`{"products":[{"id":1},{"id":2}]}`)
+	run(`query { products(limit: 10, order_by: { id: asc }) { id } }`, ``,
+		`{"products":[{"id":1},{"id":2},{"id":3}]}`)
+	run(`query { users(id: 1) { products(limit: 10, order_by: { id: asc }) { id } } }`, ``,
+		`{"users":{"products":[{"id":1},{"id":2},{"id":3}]}}`)
+	run(`query { users(id: 1) { products(order_by: { id: asc }) { id } } }`, ``,
+		`{"users":{"products":[{"id":1},{"id":2}]}}`)
+	run(`query { products(limit: $n, order_by: { id: asc }) { id } }`, `{"n": 10}`,
+		`{"products":[{"id":1},{"id":2},{"id":3}]}`)
+	run(`query { products(limit: $n, order_by: { id: asc }) { id } }`, `{"n": 1}`,
+		`{"products":[{"id":1}]}`)
+
+	// tables without limits keep the global default
+	run(`query { users(limit: 10, order_by: { id: asc }) { id } }`, ``,
+		`{"users":[{"id":1},{"id":2}]}`)
+
+	gj = newGJ(true)
+	run(`query { products(order_by: { id: asc }) { id } }`, ``,
+		`{"products":[{"id":1},{"id":2}]}`)
+
+	_, err = gj.GraphQL(ctx, `query { products(limit: 10) { id } }`, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "exceeds the maximum of 3") {
+		t.Fatalf("expected the limit to be rejected, got: %v", err)
+	}
+	_, err = gj.GraphQL(ctx, `query { products(limit: $n) { id } }`, []byte(`{"n": 10}`), nil)
+	if err == nil || !strings.Contains(err.Error(), "exceeds the maximum limit of 3") {
+		t.Fatalf("expected the limit variable to be rejected, got: %v", err)
+	}
+}
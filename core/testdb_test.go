@@ -0,0 +1,86 @@
+package core_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// newTestDB returns an in-memory sqlite database with a small
+// users and products schema
+func newTestDB(t *testing.T, name string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", "file:"+name+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() }) //nolint:errcheck
+
+	_, err = db.Exec(`
+		CREATE TABLE users (
+			id INTEGER PRIMARY KEY,
+			full_name TEXT,
+			email TEXT
+		);
+		CREATE TABLE products (
+			id INTEGER PRIMARY KEY,
+			name TEXT,
+			price REAL,
+			owner_id INTEGER REFERENCES users(id)
+		);
+		INSERT INTO users (id, full_name, email) VALUES
+			(1, 'User One', 'user1@test.com'),
+			(2, 'User Two', 'user2@test.com');
+		INSERT INTO products (id, name, price, owner_id) VALUES
+			(1, 'Product One', 10.5, 1),
+			(2, 'Product Two', 20.5, 1);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// newMultiTestGraphJin returns a GraphJin across two in-memory sqlite
+// databases, the users and products of newTestDB in the app database and
+// an audit_logs table with three rows in the logs database
+func newMultiTestGraphJin(t *testing.T, name string, conf *core.Config, opts ...core.Option) *core.GraphJin {
+	t.Helper()
+
+	db := newTestDB(t, name)
+	logs, err := sql.Open("sqlite3", "file:"+name+"_logs?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logs.Close() }) //nolint:errcheck
+
+	_, err = logs.Exec(`
+		CREATE TABLE audit_logs (
+			id INTEGER PRIMARY KEY,
+			action TEXT
+		);
+		INSERT INTO audit_logs (id, action) VALUES
+			(1, 'login'), (2, 'update'), (3, 'logout');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf.DBType = "sqlite"
+	conf.Databases = map[string]core.DatabaseConfig{
+		"app":  {Type: "sqlite", Schema: "main"},
+		"logs": {Type: "sqlite", Schema: "main"},
+	}
+	conf.Tables = append(conf.Tables,
+		core.Table{Name: "audit_logs", Schema: "main", Database: "logs"})
+
+	opts = append(opts, core.OptionSetDatabases(map[string]*sql.DB{"logs": logs}))
+	gj, err := core.NewGraphJin(conf, db, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gj
+}
@@ -0,0 +1,77 @@
+package core
+
+import "time"
+
+// Extensions are the extensions of the result of a query
+type Extensions struct {
+	Timing *Timing `json:"timing,omitempty"`
+}
+
+// Timing is the time spent in each phase of a query, it is returned in the
+// extensions of the result when requested with RequestConfig.Timing.
+// Durations are in nanoseconds. A query compiled by an earlier request
+// has no compile and generate time, and one served from the response
+// cache only has a total.
+type Timing struct {
+	// Compile is the time spent compiling the GraphQL query
+	Compile time.Duration `json:"compile"`
+	// Generate is the time spent generating the SQL or the MongoDB query
+	Generate time.Duration `json:"generate"`
+	// Execute is the time spent running the query on the database,
+	// including retries
+	Execute time.Duration `json:"execute"`
+	// Assemble is the time spent on the remote joins, the computed fields,
+	// caching and the output of the result
+	Assemble time.Duration `json:"assemble"`
+	// Total is the time spent on the whole query
+	Total time.Duration `json:"total"`
+}
+
+type timingPhase int
+
+const (
+	timingCompile timingPhase = iota
+	timingGenerate
+	timingExecute
+	timingAssemble
+	timingTotal
+)
+
+// now returns the current time when the timing is collected, the zero time
+// otherwise so nothing is done when it is not
+func (t *Timing) now() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// add adds the time since start to a phase when the timing is collected
+func (t *Timing) add(p timingPhase, start time.Time) {
+	if t == nil {
+		return
+	}
+	d := time.Since(start)
+	switch p {
+	case timingCompile:
+		t.Compile += d
+	case timingGenerate:
+		t.Generate += d
+	case timingExecute:
+		t.Execute += d
+	case timingAssemble:
+		t.Assemble += d
+	case timingTotal:
+		t.Total += d
+	}
+}
+
+// setExtensions sets the timing of the query in the extensions of the
+// result when it was requested
+func (s *gstate) setExtensions(res *Result, start time.Time) {
+	if s.timing == nil {
+		return
+	}
+	s.timing.add(timingTotal, start)
+	res.Extensions = &Extensions{Timing: s.timing}
+}
@@ -0,0 +1,48 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestTiming(t *testing.T) {
+	db := newTestDB(t, "timingdb1")
+
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query getUsers { users(order_by: { id: asc }) { id } }`
+
+	res, err := gj.GraphQL(context.Background(), gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Extensions != nil {
+		t.Fatalf("expected no extensions, got: %+v", res.Extensions)
+	}
+
+	res, err = gj.GraphQL(context.Background(), gql, nil, &core.RequestConfig{Timing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := res.Extensions.Timing
+	if tm.Compile <= 0 || tm.Generate <= 0 || tm.Execute <= 0 || tm.Assemble <= 0 ||
+		tm.Total < tm.Compile+tm.Generate+tm.Execute+tm.Assemble {
+		t.Fatalf("unexpected timing: %+v", tm)
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"extensions":{"timing":{"compile":`) {
+		t.Fatalf("expected the timing in the extensions: %s", b)
+	}
+}
@@ -2,7 +2,11 @@ package core
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strconv"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
 )
 
 type Tracer interface {
@@ -52,3 +56,86 @@ func (s *span) IsRecording() bool {
 // SetAttributesString sets the attributes
 func (s *span) SetAttributesString(attrs ...StringAttr) {
 }
+
+// traceAttrs are the attributes added to the span of a query
+var traceAttrs = []string{
+	"name", "operation", "namespace", "role", "database", "dialect", "cache_hit", "roots",
+}
+
+// initTraceAttrs sets the attributes added to the span of a query
+func (gj *graphjinEngine) initTraceAttrs() error {
+	if len(gj.conf.TraceAttributes) == 0 {
+		gj.traceAttrs = traceAttrs
+		return nil
+	}
+	gj.traceAttrs = nil
+	for _, a := range gj.conf.TraceAttributes {
+		found := false
+		for _, v := range traceAttrs {
+			if a == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("trace_attributes: unknown attribute '%s'", a)
+		}
+		gj.traceAttrs = append(gj.traceAttrs, a)
+	}
+	return nil
+}
+
+// setSpanAttributes annotates the span of a query with the attributes of
+// the query, the values of the variables are never added
+func (s *gstate) setSpanAttributes(span Spaner, qc *qcode.QCode) {
+	if span == nil || !span.IsRecording() {
+		return
+	}
+
+	attrs := make([]StringAttr, 0, len(s.gj.traceAttrs))
+	for _, a := range s.gj.traceAttrs {
+		var v string
+		switch a {
+		case "name":
+			v = s.r.name
+		case "operation":
+			v = opTypeName(opTypeOf(s.r.operation))
+		case "namespace":
+			v = s.r.namespace
+		case "role":
+			v = s.role
+		case "database":
+			if v = s.database; v == "" {
+				v = s.gj.defaultDB
+			}
+		case "dialect":
+			if dbCtx := s.getTargetDBCtx(); dbCtx != nil {
+				v = dbCtx.dbtype
+			}
+		case "cache_hit":
+			v = strconv.FormatBool(s.cacheHit)
+		case "roots":
+			if qc != nil {
+				v = strconv.Itoa(len(qc.Roots))
+			}
+		}
+		if v != "" {
+			attrs = append(attrs, StringAttr{"query." + a, v})
+		}
+	}
+	span.SetAttributesString(attrs...)
+}
+
+// opTypeName returns the name of an operation type
+func opTypeName(op OpType) string {
+	switch op {
+	case OpQuery:
+		return "query"
+	case OpMutation:
+		return "mutation"
+	case OpSubscription:
+		return "subscription"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,99 @@
+package core_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+// recordingTracer keeps the attributes set on the spans it starts
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans map[string]map[string]string
+}
+
+type recordingSpan struct {
+	t    *recordingTracer
+	name string
+}
+
+func (t *recordingTracer) Start(c context.Context, name string) (context.Context, core.Spaner) {
+	return c, &recordingSpan{t: t, name: name}
+}
+
+func (t *recordingTracer) NewHTTPClient() *http.Client {
+	return &http.Client{}
+}
+
+func (s *recordingSpan) SetAttributesString(attrs ...core.StringAttr) {
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	if s.t.spans[s.name] == nil {
+		s.t.spans[s.name] = make(map[string]string)
+	}
+	for _, a := range attrs {
+		s.t.spans[s.name][a.Name] = a.Value
+	}
+}
+
+func (s *recordingSpan) IsRecording() bool { return true }
+func (s *recordingSpan) Error(err error)   {}
+func (s *recordingSpan) End()              {}
+
+func TestQuerySpanAttributes(t *testing.T) {
+	db := newTestDB(t, "traceattrsdb1")
+
+	tr := &recordingTracer{spans: make(map[string]map[string]string)}
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db, core.OptionSetTrace(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query getUsers { users(where: { id: { eq: $id } }) { id } products { id } }`
+	if _, err := gj.GraphQL(context.Background(), gql, []byte(`{"id":1}`), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	attrs := tr.spans["GraphJin Query"]
+	exp := map[string]string{
+		"query.name":      "getUsers",
+		"query.operation": "query",
+		"query.role":      "anon",
+		"query.dialect":   "sqlite",
+		"query.cache_hit": "false",
+		"query.roots":     "2",
+	}
+	for k, v := range exp {
+		if attrs[k] != v {
+			t.Errorf("expected %s to be '%s', got '%s'", k, v, attrs[k])
+		}
+	}
+	for _, v := range attrs {
+		if v == "1" {
+			t.Errorf("the value of a variable was added to the span: %v", attrs)
+		}
+	}
+
+	// only the configured attributes are added
+	tr = &recordingTracer{spans: make(map[string]map[string]string)}
+	conf = &core.Config{DBType: "sqlite", DisableAllowList: true, TraceAttributes: []string{"name"}}
+	gj, err = core.NewGraphJin(conf, db, core.OptionSetTrace(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gj.GraphQL(context.Background(), gql, []byte(`{"id":1}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	if attrs := tr.spans["GraphJin Query"]; len(attrs) != 1 || attrs["query.name"] != "getUsers" {
+		t.Fatalf("expected only the query name, got: %v", attrs)
+	}
+
+	conf = &core.Config{DBType: "sqlite", DisableAllowList: true, TraceAttributes: []string{"vars"}}
+	if _, err := core.NewGraphJin(conf, db); err == nil {
+		t.Fatal("expected an error for an unknown trace attribute")
+	}
+}
@@ -41,18 +41,28 @@ type dir struct {
 var dirTypes []dir = []dir{
 	{
 		name: "cacheControl",
-		desc: "Set the cache-control header to be passed back with the query result",
-		locs: []string{LOC_QUERY, LOC_MUTATION, LOC_SUBSCRIPTION},
+		desc: "Set the cache-control header to be passed back with the query result, hints on fields are combined with the lowest max-age winning",
+		locs: []string{LOC_QUERY, LOC_MUTATION, LOC_SUBSCRIPTION, LOC_FIELD},
 		args: []dirArg{{
 			name:  "maxAge",
 			desc:  "The maximum amount of time (in seconds) a resource is considered fresh",
 			atype: "Int",
 		}, {
 			name:  "scope",
-			desc:  "Set to 'public' when any cache can store the data and 'private' when only the browser cache should",
+			desc:  "Set to 'public' when any cache can store the data and 'private' when only the browser cache should, any private hint makes the response private",
 			atype: "String",
 		}},
 	},
+	{
+		name: "throttle",
+		desc: "Deliver the results of a subscription at most once per window, the changes within a window are coalesced into the latest result",
+		locs: []string{LOC_SUBSCRIPTION},
+		args: []dirArg{{
+			name:  "ms",
+			desc:  "The minimum time (in milliseconds) between the results delivered to a subscriber",
+			atype: "Int",
+		}},
+	},
 	{
 		name: "skip",
 		desc: "Skip field if defined condition is met",
@@ -126,6 +136,99 @@ var dirTypes []dir = []dir{
 			atype: "tables" + SUFFIX_ENUM,
 		}},
 	},
+	{
+		name: "insertOptions",
+		desc: "Options for bulk inserts (MongoDB specific)",
+		locs: []string{LOC_FIELD},
+		args: []dirArg{{
+			name:  "ordered",
+			desc:  "Stop at the first failed document (default true), when false an error with the index of each failed document is returned",
+			atype: "Boolean",
+		}},
+	},
+	{
+		name: "getOrCreate",
+		desc: "Insert the document of an upsert only if no document matches, the matching document is returned unchanged (MongoDB specific)",
+		locs: []string{LOC_FIELD},
+	},
+	{
+		name: "returnBefore",
+		desc: "Return the document of an update as it was before the update (MongoDB specific)",
+		locs: []string{LOC_FIELD},
+	},
+	{
+		name: "flatten",
+		desc: "Merge the fields of a singular related object into its parent (MongoDB specific)",
+		locs: []string{LOC_FIELD},
+	},
+	{
+		name: "exists",
+		desc: "Return whether a relationship has a match instead of the related objects (MongoDB specific)",
+		locs: []string{LOC_FIELD},
+	},
+	{
+		name: "keepDuplicates",
+		desc: "Return a related object for every join row linking it in a many-to-many relationship instead of once (MongoDB specific)",
+		locs: []string{LOC_FIELD},
+	},
+	{
+		name: "changeStream",
+		desc: "Stream the changes to a collection to the subscription instead of polling (MongoDB specific)",
+		locs: []string{LOC_FIELD},
+		args: []dirArg{{
+			name:  "mode",
+			desc:  "Deliver the changed 'document' (default) or only the updated 'fields'",
+			atype: "String",
+		}},
+	},
+	{
+		name: "materialize",
+		desc: "Cache the results of a query in a collection until they are older than the TTL (MongoDB specific)",
+		locs: []string{LOC_FIELD},
+		args: []dirArg{{
+			name:  "collection",
+			desc:  "Collection the results are cached in",
+			atype: "String",
+		}, {
+			name:  "ttlSeconds",
+			desc:  "Number of seconds the cached results are used for",
+			atype: "Int",
+		}},
+	},
+	{
+		name: "connection",
+		desc: "Return the page info of a cursor paginated query with the start and end cursors and if there are more pages",
+		locs: []string{LOC_FIELD},
+	},
+	{
+		name: "size",
+		desc: "Return the length of an array column (MongoDB specific)",
+		locs: []string{LOC_FIELD},
+	},
+	{
+		name: "slice",
+		desc: "Return a window of an array column (MongoDB specific)",
+		locs: []string{LOC_FIELD},
+		args: []dirArg{{
+			name:  "skip",
+			desc:  "Number of elements to skip",
+			atype: "Int",
+		}, {
+			name:  "limit",
+			desc:  "Number of elements to return",
+			atype: "Int",
+		}},
+	},
+	{
+		name: "sum",
+		desc: "Return the sum of the elements of an array column, 0 for an empty array (MongoDB specific)",
+		locs: []string{LOC_FIELD},
+	},
+	{
+		name: "avg",
+		desc: "Return the average of the elements of an array column, null for an empty array (MongoDB specific)",
+		locs: []string{LOC_FIELD},
+	},
 }
 
 type exp struct {
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// Values of the unknown_fields config
+const (
+	UnknownFieldsStrict = "strict"
+	UnknownFieldsDrop   = "drop"
+)
+
+// validateUnknownFields checks the unknown_fields config value
+func validateUnknownFields(mode string) error {
+	switch mode {
+	case "", UnknownFieldsStrict, UnknownFieldsDrop:
+		return nil
+	}
+	return fmt.Errorf("unknown_fields: invalid value '%s': must be '%s' or '%s'",
+		mode, UnknownFieldsStrict, UnknownFieldsDrop)
+}
+
+// setUnknownFields sets which namespaces leave the fields that are not in
+// the schema out of their queries
+func (gj *graphjinEngine) setUnknownFields(qcc *qcode.Config) {
+	qcc.DropUnknownFields = gj.conf.UnknownFields == UnknownFieldsDrop
+	for ns, mode := range gj.conf.NamespaceUnknownFields {
+		if qcc.NamespaceDropUnknownFields == nil {
+			qcc.NamespaceDropUnknownFields = make(map[string]bool)
+		}
+		qcc.NamespaceDropUnknownFields[ns] = mode == UnknownFieldsDrop
+	}
+}
+
+// unknownFieldErrors returns an error for each field left out of the query
+// because it is not in the schema so clients can detect the drift
+func unknownFieldErrors(qc *qcode.QCode) (errs []Error) {
+	if qc == nil {
+		return
+	}
+	for _, p := range qc.UnknownFields {
+		errs = append(errs, Error{
+			Message: fmt.Sprintf("unknown field '%s' was left out", p),
+			Path:    strings.Split(p, "."),
+		})
+	}
+	return
+}
@@ -0,0 +1,92 @@
+package core_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestUnknownFields(t *testing.T) {
+	db := newTestDB(t, "unknownfieldsdb")
+
+	gql := `query getUsers { users(order_by: { id: asc }) { id nickname products { id removed } } }`
+
+	// unknown fields fail the query by default
+	conf := &core.Config{DBType: "sqlite", DisableAllowList: true}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gj.GraphQL(context.Background(), gql, nil, nil); err == nil {
+		t.Fatal("expected an error for the unknown fields")
+	}
+
+	conf = &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		UnknownFields:    core.UnknownFieldsDrop,
+		Roles: []core.Role{{
+			Name: "anon",
+			Tables: []core.RoleTable{{
+				Name:  "users",
+				Query: &core.Query{Columns: []string{"id"}},
+			}},
+		}},
+	}
+	gj, err = core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the unknown fields are left out and reported
+	res, err := gj.GraphQL(context.Background(), gql, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"users":[{"id":1,"products":[{"id":1},{"id":2}]},{"id":2,"products":[]}]}`
+	if string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+	if len(res.Errors) != 2 ||
+		strings.Join(res.Errors[0].Path, ".") != "users.nickname" ||
+		strings.Join(res.Errors[1].Path, ".") != "users.products.removed" {
+		t.Fatalf("unexpected errors: %+v", res.Errors)
+	}
+
+	// fields blocked for the role still fail the query
+	gql = `query getEmails { users { id email } }`
+	if _, err := gj.GraphQL(context.Background(), gql, nil, nil); err == nil {
+		t.Fatal("expected an error for the blocked field")
+	}
+
+	// the mode can be set by namespace
+	conf = &core.Config{
+		DBType:                 "sqlite",
+		DisableAllowList:       true,
+		NamespaceUnknownFields: map[string]string{"legacy": core.UnknownFieldsDrop},
+	}
+	gj, err = core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gql = `query getNames { users(id: 1) { id nickname } }`
+	if _, err := gj.GraphQL(context.Background(), gql, nil, nil); err == nil {
+		t.Fatal("expected an error outside of the namespace")
+	}
+	rc := &core.RequestConfig{}
+	rc.SetNamespace("legacy")
+	res, err = gj.GraphQL(context.Background(), gql, nil, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Data) != `{"users":{"id":1}}` || len(res.Errors) != 1 {
+		t.Fatalf("unexpected result: %s %+v", res.Data, res.Errors)
+	}
+
+	conf = &core.Config{DBType: "sqlite", UnknownFields: "lenient"}
+	if _, err := core.NewGraphJin(conf, db); err == nil {
+		t.Fatal("expected an error for an invalid mode")
+	}
+}
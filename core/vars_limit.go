@@ -0,0 +1,81 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// ErrVariablesTooLarge is returned when the variables of a request are over
+// the configured size, depth or bulk insert limits
+var ErrVariablesTooLarge = errors.New("VARIABLES_TOO_LARGE")
+
+// checkVarsLimits rejects variables over the configured limits before they
+// are parsed. The variables are scanned byte by byte so nothing is allocated
+// for their values. The depth does not count the object holding the variables
+func (gj *graphjinEngine) checkVarsLimits(op qcode.QType, vars json.RawMessage) error {
+	conf := gj.conf
+	if conf.MaxVariablesSize > 0 && len(vars) > conf.MaxVariablesSize {
+		return fmt.Errorf("%w: variables are larger than %d bytes",
+			ErrVariablesTooLarge, conf.MaxVariablesSize)
+	}
+
+	maxDepth := conf.MaxVariablesDepth
+	maxBulk := 0
+	if op == qcode.QTMutation {
+		maxBulk = conf.MaxBulkInsert
+	}
+	if maxDepth <= 0 && maxBulk <= 0 {
+		return nil
+	}
+
+	// rows counts the objects and arrays in an array variable
+	var depth, rows int
+	var inStr, esc, inArray bool
+
+	for _, b := range vars {
+		if inStr {
+			switch {
+			case esc:
+				esc = false
+			case b == '\\':
+				esc = true
+			case b == '"':
+				inStr = false
+			}
+			continue
+		}
+
+		switch b {
+		case '"':
+			inStr = true
+
+		case '{', '[':
+			depth++
+			if maxDepth > 0 && depth-1 > maxDepth {
+				return fmt.Errorf("%w: variables are nested deeper than %d levels",
+					ErrVariablesTooLarge, maxDepth)
+			}
+			if maxBulk <= 0 {
+				continue
+			}
+			if depth == 2 && b == '[' {
+				inArray, rows = true, 0
+			} else if depth == 3 && inArray {
+				if rows++; rows > maxBulk {
+					return fmt.Errorf("%w: array variables of mutations can have at most %d rows",
+						ErrVariablesTooLarge, maxBulk)
+				}
+			}
+
+		case '}', ']':
+			depth--
+			if depth == 1 {
+				inArray = false
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestVariablesLimits(t *testing.T) {
+	db := newTestDB(t, "varslimitdb1")
+
+	conf := &core.Config{
+		DBType:            "sqlite",
+		DisableAllowList:  true,
+		MaxVariablesSize:  200,
+		MaxVariablesDepth: 3,
+		MaxBulkInsert:     2,
+	}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := `query getUsers { users(where: { id: { in: $ids } }, order_by: { id: asc }) { id } }`
+	mutation := `mutation addProducts { products(insert: $data) { id } }`
+
+	tests := []struct {
+		name     string
+		gql      string
+		vars     string
+		tooLarge bool
+	}{
+		{"within the limits", query, `{"ids":[1,2]}`, false},
+		{"too large", query, `{"ids":[` + strings.Repeat("1,", 100) + `2]}`, true},
+		{"too deep", query, `{"ids":[1],"x":{"a":{"b":{"c":[1]}}}}`, true},
+		{"string brackets are ignored", query, `{"ids":[1],"x":"{{{{[[[["}`, false},
+		{"too many rows", mutation, `{"data":[{"name":"a"},{"name":"b"},{"name":"c"}]}`, true},
+		{"rows of a query are not limited", query, `{"ids":[1],"x":[{},{},{}]}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gj.GraphQL(context.Background(), tt.gql, json.RawMessage(tt.vars), nil)
+			if got := errors.Is(err, core.ErrVariablesTooLarge); got != tt.tooLarge {
+				t.Fatalf("expected VARIABLES_TOO_LARGE to be %v, got: %v", tt.tooLarge, err)
+			}
+		})
+	}
+}
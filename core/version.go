@@ -0,0 +1,52 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+// ErrConflict is returned when an update on a table with a version column
+// did not update any row since the row was changed after it was read
+var ErrConflict = errors.New("CONFLICT")
+
+// checkVersionConflict returns ErrConflict if an update that checks the
+// version column of a table did not return the updated row
+func (s *gstate) checkVersionConflict() error {
+	qc := s.qcode()
+	if qc == nil || qc.SType != qcode.QTUpdate || !qc.HasVersionCheck() {
+		return nil
+	}
+
+	var data map[string]json.RawMessage
+	if len(s.data) != 0 {
+		if err := json.Unmarshal(s.data, &data); err != nil {
+			return err
+		}
+	}
+
+	for i := range qc.Mutates {
+		m := &qc.Mutates[i]
+		if m.ParentID != -1 || m.VersionCol() == nil {
+			continue
+		}
+		if noRows(data[qc.Selects[m.SelID].FieldName]) {
+			return ErrConflict
+		}
+	}
+	return nil
+}
+
+// noRows returns true if the value of a root field has no rows
+func noRows(v json.RawMessage) bool {
+	v = bytes.TrimSpace(v)
+	if len(v) == 0 || bytes.Equal(v, []byte(`null`)) {
+		return true
+	}
+	if v[0] != '[' {
+		return false
+	}
+	return len(bytes.TrimSpace(v[1:len(v)-1])) == 0
+}
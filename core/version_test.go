@@ -0,0 +1,80 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestVersionColumnUpdate(t *testing.T) {
+	db := newTestDB(t, "versiondb1")
+
+	_, err := db.Exec(`
+		CREATE TABLE notes (
+			id INTEGER PRIMARY KEY,
+			body TEXT,
+			version INTEGER NOT NULL DEFAULT 1
+		);
+		INSERT INTO notes (id, body, version) VALUES (1, 'First', 1);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &core.Config{
+		DBType:           "sqlite",
+		DisableAllowList: true,
+		Tables:           []core.Table{{Name: "notes", VersionColumn: "version"}},
+	}
+	gj, err := core.NewGraphJin(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	gql := `mutation {
+		notes(id: 1, update: { body: $body, version: $version }) { id body version }
+	}`
+
+	res, err := gj.GraphQL(ctx, gql, []byte(`{"body": "Second", "version": 1}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"notes":{"id":1,"body":"Second","version":2}}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+
+	// a stale version does not update the row
+	_, err = gj.GraphQL(ctx, gql, []byte(`{"body": "Stale", "version": 1}`), nil)
+	if !errors.Is(err, core.ErrConflict) {
+		t.Fatalf("expected a conflict error, got: %v", err)
+	}
+
+	gql = `mutation { notes(id: 1, update: $data) { id body version } }`
+
+	res, err = gj.GraphQL(ctx, gql, []byte(`{"data": {"body": "Third", "version": 2}}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"notes":{"id":1,"body":"Third","version":3}}`; string(res.Data) != exp {
+		t.Fatalf("expected: %s, got: %s", exp, res.Data)
+	}
+
+	var body string
+	var version int
+	err = db.QueryRow(`SELECT body, version FROM notes WHERE id = 1`).Scan(&body, &version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "Third" || version != 3 {
+		t.Fatalf("expected the row to be updated once per version, got: %s %d", body, version)
+	}
+
+	// the expected version is required
+	_, err = gj.GraphQL(ctx, `mutation { notes(id: 1, update: { body: "None" }) { id } }`, nil, nil)
+	if err == nil || errors.Is(err, core.ErrConflict) {
+		t.Fatalf("expected an error for the missing version, got: %v", err)
+	}
+}
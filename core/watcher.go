@@ -40,6 +40,7 @@ func (g *GraphJin) startDBWatcher(ps time.Duration) {
 		gj := g.Load().(*graphjinEngine)
 
 		needsReload := false
+		var reloadDB string
 
 		// Check all databases for schema changes
 		for _, ctx := range gj.databases {
@@ -54,14 +55,17 @@ func (g *GraphJin) startDBWatcher(ps time.Duration) {
 				ctx.schemas)
 			if err != nil {
 				gj.log.Printf("database %s: schema poll error: %v", ctx.name, err)
+				ctx.setErr(err)
 				continue
 			}
+			ctx.setErr(nil)
 
 			// Check if we're waiting for tables (schema is nil)
 			if ctx.schema == nil {
 				if len(latestDi.Tables) > 0 {
 					gj.log.Printf("database %s: tables discovered, reinitializing...", ctx.name)
 					needsReload = true
+					reloadDB = ctx.name
 					break
 				}
 				continue
@@ -71,6 +75,7 @@ func (g *GraphJin) startDBWatcher(ps time.Duration) {
 			if latestDi.Hash() != ctx.dbinfo.Hash() {
 				gj.log.Printf("database %s: schema change detected, reinitializing...", ctx.name)
 				needsReload = true
+				reloadDB = ctx.name
 				break
 			}
 		}
@@ -83,6 +88,10 @@ func (g *GraphJin) startDBWatcher(ps time.Duration) {
 			if pdb != nil {
 				if err := g.newGraphJin(gj.conf, pdb.db, nil, gj.fs, gj.opts...); err != nil {
 					gj.log.Println(err)
+					// the previous schema stays in use
+					if ctx, ok := gj.databases[reloadDB]; ok {
+						ctx.setErr(err)
+					}
 				}
 			}
 			g.reloadMu.Unlock()
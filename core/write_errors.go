@@ -0,0 +1,49 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/dosco/graphjin/mongodriver"
+)
+
+// writeErrors moves the documents that failed to insert out of the result
+// and into field errors with the path of the root and the index of the
+// document, the documents inserted are still returned
+func (s *gstate) writeErrors() error {
+	if len(s.data) == 0 || s.getTargetDBCtx().dbtype != "mongodb" ||
+		!bytes.Contains(s.data, []byte(mongodriver.WriteErrorsField)) {
+		return nil
+	}
+
+	fields, err := jsonObjectFields(s.data)
+	if err != nil {
+		return nil
+	}
+	i := jsonFieldIndex(fields, mongodriver.WriteErrorsField)
+	if i == -1 {
+		return nil
+	}
+
+	var werrs []mongodriver.WriteError
+	if err := json.Unmarshal(fields[i].val, &werrs); err != nil {
+		return fmt.Errorf("write errors: %w", err)
+	}
+	fields = append(fields[:i], fields[i+1:]...)
+	s.data = marshalJSONFields(fields)
+
+	var root string
+	if qc := s.qcode(); qc != nil && len(qc.Roots) != 0 {
+		root = qc.Selects[qc.Roots[0]].FieldName
+	}
+	for _, we := range werrs {
+		s.fderrs = append(s.fderrs, fieldError{
+			path: []string{root, strconv.Itoa(we.Index)},
+			err:  errors.New(we.Message),
+		})
+	}
+	return nil
+}
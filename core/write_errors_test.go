@@ -0,0 +1,32 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3/internal/qcode"
+)
+
+func TestWriteErrors(t *testing.T) {
+	s := gstate{
+		gj: &graphjinEngine{
+			defaultDB: "main",
+			databases: map[string]*dbContext{"main": {dbtype: "mongodb"}},
+		},
+		cs: &cstate{st: stmt{qc: &qcode.QCode{
+			Roots:   []int32{0},
+			Selects: []qcode.Select{{Field: qcode.Field{FieldName: "users"}}},
+		}}},
+		data: []byte(`{"users":[{"id":1}],"__write_errors":[{"index":1,"message":"E11000 duplicate key"}]}`),
+	}
+
+	if err := s.writeErrors(); err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"users":[{"id":1}]}`; string(s.data) != exp {
+		t.Fatalf("expected %s, got %s", exp, s.data)
+	}
+	if len(s.fderrs) != 1 || s.fderrs[0].err.Error() != "E11000 duplicate key" ||
+		s.fderrs[0].path[0] != "users" || s.fderrs[0].path[1] != "1" {
+		t.Fatalf("expected the error of the second document, got %v", s.fderrs)
+	}
+}
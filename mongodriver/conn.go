@@ -72,6 +72,11 @@ func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.Nam
 		return nil, err
 	}
 
+	// Convert the string ids of ObjectId collections
+	if err := q.ConvertObjectIDs(); err != nil {
+		return nil, err
+	}
+
 	// Execute based on operation
 	return c.executeQuery(ctx, q)
 }
@@ -97,12 +102,20 @@ func (c *Conn) ExecContext(ctx context.Context, query string, args []driver.Name
 		return nil, err
 	}
 
+	// Convert the string ids of ObjectId collections
+	if err := q.ConvertObjectIDs(); err != nil {
+		return nil, err
+	}
+
 	// Execute based on operation
 	return c.executeExec(ctx, q)
 }
 
 // executeQuery handles query operations (aggregate, find).
 func (c *Conn) executeQuery(ctx context.Context, q *QueryDSL) (driver.Rows, error) {
+	if err := checkSideEffects(q); err != nil {
+		return nil, err
+	}
 	switch q.Operation {
 	case OpEmpty:
 		// Return empty result for dropped root selections (@add/@remove directives)
@@ -130,15 +143,27 @@ func (c *Conn) executeQuery(ctx context.Context, q *QueryDSL) (driver.Rows, erro
 	case OpInsertMany:
 		// Handle insertMany as a query that returns the inserted documents
 		return c.executeInsertManyAsQuery(ctx, q)
+	case OpBulkUpsert:
+		// Handle bulkUpsert as a query that returns the inserted and updated documents
+		return c.executeBulkUpsertAsQuery(ctx, q)
 	case OpNestedInsert:
 		// Handle nested insert (insert into multiple related collections)
 		return c.executeNestedInsert(ctx, q)
 	case OpUpdateOne:
 		// Handle updateOne as a query that returns the updated document
 		return c.executeUpdateOneAsQuery(ctx, q)
+	case OpFindOneAndUpdate:
+		// Handle findOneAndUpdate as a query that returns the previous document
+		return c.executeFindOneAndUpdateAsQuery(ctx, q)
 	case OpDeleteOne:
 		// Handle deleteOne as a query that returns the deleted document
 		return c.executeDeleteOneAsQuery(ctx, q)
+	case OpUpdateMany:
+		// Handle updateMany as a query that returns the updated documents
+		return c.executeUpdateManyAsQuery(ctx, q)
+	case OpDeleteMany:
+		// Handle deleteMany as a query that returns the deleted documents
+		return c.executeDeleteManyAsQuery(ctx, q)
 	case OpNestedUpdate:
 		// Handle nested update (update multiple related collections)
 		return c.executeNestedUpdate(ctx, q)
@@ -146,6 +171,8 @@ func (c *Conn) executeQuery(ctx context.Context, q *QueryDSL) (driver.Rows, erro
 		return c.executeFind(ctx, q)
 	case OpFindOne:
 		return c.executeFindOne(ctx, q)
+	case OpWatch:
+		return c.executeWatch(ctx, q)
 	default:
 		return nil, fmt.Errorf("mongodriver: unsupported query operation: %s", q.Operation)
 	}
@@ -153,11 +180,16 @@ func (c *Conn) executeQuery(ctx context.Context, q *QueryDSL) (driver.Rows, erro
 
 // executeExec handles mutation operations (insert, update, delete).
 func (c *Conn) executeExec(ctx context.Context, q *QueryDSL) (driver.Result, error) {
+	if err := checkSideEffects(q); err != nil {
+		return nil, err
+	}
 	switch q.Operation {
 	case OpInsertOne:
 		return c.executeInsertOne(ctx, q)
 	case OpInsertMany:
 		return c.executeInsertMany(ctx, q)
+	case OpBulkUpsert:
+		return c.executeBulkUpsert(ctx, q)
 	case OpUpdateOne:
 		return c.executeUpdateOne(ctx, q)
 	case OpUpdateMany:
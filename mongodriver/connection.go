@@ -0,0 +1,59 @@
+package mongodriver
+
+import (
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// extendLimit adds one to the last $limit stage of the pipeline so a
+// document more than the page size is fetched, it returns the page size
+// or -1 if the pipeline has no limit
+func extendLimit(pipeline bson.A) int64 {
+	for i := len(pipeline) - 1; i >= 0; i-- {
+		stage, ok := pipeline[i].(map[string]any)
+		if !ok {
+			continue
+		}
+		v, ok := stage["$limit"]
+		if !ok {
+			continue
+		}
+		var n int64
+		switch l := v.(type) {
+		case float64:
+			n = int64(l)
+		case int:
+			n = int64(l)
+		case int32:
+			n = int64(l)
+		case int64:
+			n = l
+		default:
+			return -1
+		}
+		stage["$limit"] = n + 1
+		return n
+	}
+	return -1
+}
+
+// connectionPage trims the document fetched after the page and returns the
+// page info with the cursors of the first and last document of the page
+func connectionPage(q *QueryDSL, results []bson.M, pageSize int64) ([]bson.M, map[string]any) {
+	hasNext := false
+	if pageSize >= 0 && int64(len(results)) > pageSize {
+		results = results[:pageSize]
+		hasNext = true
+	}
+
+	pi := map[string]any{
+		"hasNextPage":     hasNext,
+		"hasPreviousPage": q.hasCursor,
+		"startCursor":     nil,
+		"endCursor":       nil,
+	}
+	if len(results) != 0 {
+		pi["startCursor"] = buildCursorValue(q.CursorInfo, results[0])
+		pi["endCursor"] = buildCursorValue(q.CursorInfo, results[len(results)-1])
+	}
+	return results, pi
+}
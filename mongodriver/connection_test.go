@@ -0,0 +1,49 @@
+package mongodriver
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestConnectionPage(t *testing.T) {
+	pipeline := bson.A{
+		map[string]any{"$match": map[string]any{"status": "paid"}},
+		map[string]any{"$limit": float64(2)},
+		map[string]any{"$project": map[string]any{"_id": 1}},
+	}
+	if n := extendLimit(pipeline); n != 2 {
+		t.Fatalf("expected a page size of 2, got %d", n)
+	}
+	if l := pipeline[1].(map[string]any)["$limit"]; l != int64(3) {
+		t.Fatalf("expected the $limit to fetch a document more, got %v", l)
+	}
+
+	q := &QueryDSL{CursorInfo: &CursorInfo{
+		SelID:   0,
+		Prefix:  "gj-1:",
+		OrderBy: []CursorColumn{{Col: "id", Order: "asc"}},
+	}}
+
+	// a document more than the page size means there is a next page
+	results, pi := connectionPage(q, []bson.M{{"_id": 1}, {"_id": 2}, {"_id": 3}}, 2)
+	if len(results) != 2 || pi["hasNextPage"] != true || pi["hasPreviousPage"] != false {
+		t.Fatalf("unexpected page: %v %v", results, pi)
+	}
+	if pi["startCursor"] != "gj-1:0:1" || pi["endCursor"] != "gj-1:0:2" {
+		t.Fatalf("unexpected cursors: %v", pi)
+	}
+
+	// the last page
+	q.hasCursor = true
+	results, pi = connectionPage(q, []bson.M{{"_id": 3}}, 2)
+	if len(results) != 1 || pi["hasNextPage"] != false || pi["hasPreviousPage"] != true {
+		t.Fatalf("unexpected page: %v %v", results, pi)
+	}
+
+	// no documents
+	_, pi = connectionPage(q, nil, 2)
+	if pi["startCursor"] != nil || pi["endCursor"] != nil {
+		t.Fatalf("expected no cursors: %v", pi)
+	}
+}
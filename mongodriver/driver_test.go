@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +14,26 @@ import (
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
+// TestTranslateDocumentFields tests that only the id of an inserted document
+// is translated and not the id keys of its sub-documents
+func TestTranslateDocumentFields(t *testing.T) {
+	var doc map[string]any
+	err := json.Unmarshal([]byte(`{"id":1,"name":"lamp",`+
+		`"metadata":{"id":7,"tags":[{"id":1},{"id":2}],"deep":{"a":{"id":3}}}}`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(translateDocumentFields(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"_id":1,"metadata":{"deep":{"a":{"id":3}},"id":7,"tags":[{"id":1},{"id":2}]},"name":"lamp"}`
+	if string(b) != exp {
+		t.Fatalf("expected %s, got %s", exp, b)
+	}
+}
+
 // TestQueryDSLParsing tests the JSON query DSL parsing
 func TestQueryDSLParsing(t *testing.T) {
 	tests := []struct {
@@ -117,6 +138,54 @@ func TestParamSubstitutionMultiMutation(t *testing.T) {
 	}
 }
 
+func TestParamSubstitutionArrayFilters(t *testing.T) {
+	query := `{"operation":"updateOne","collection":"orders","filter":{"id":1},` +
+		`"update":{"$set":{"items.$[e1].qty":"$1"}},"options":{"arrayFilters":[{"e1.sku":"$2"}]},"params":["$1","$2"]}`
+
+	q, err := ParseQuery(query)
+	if err != nil {
+		t.Fatalf("ParseQuery() error = %v", err)
+	}
+
+	if err := q.SubstituteParams([]any{5, "X"}); err != nil {
+		t.Fatalf("SubstituteParams() error = %v", err)
+	}
+
+	af := q.Options["arrayFilters"].([]any)
+	if got := af[0].(map[string]any)["e1.sku"]; got != "X" {
+		t.Fatalf("arrayFilters[0].e1.sku = %v, want X", got)
+	}
+	if got := q.Update["$set"].(map[string]any)["items.$[e1].qty"]; got != 5 {
+		t.Fatalf("update.$set.items.$[e1].qty = %v, want 5", got)
+	}
+}
+
+// TestUpdateManyOptions tests that the array filters and the collation of
+// an updateMany are passed to the driver
+func TestUpdateManyOptions(t *testing.T) {
+	query := `{"operation":"updateMany","collection":"orders","filter":{"status":"open"},` +
+		`"update":{"$set":{"items.$[e1].qty":5}},` +
+		`"options":{"arrayFilters":[{"e1.sku":"X"}],"collation":{"locale":"en","strength":2}}}`
+
+	q, err := ParseQuery(query)
+	if err != nil {
+		t.Fatalf("ParseQuery() error = %v", err)
+	}
+
+	var opts options.UpdateManyOptions
+	for _, fn := range updateManyOptions(q).Opts {
+		if err := fn(&opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(opts.ArrayFilters) != 1 {
+		t.Fatalf("expected the array filters to be set, got %v", opts.ArrayFilters)
+	}
+	if opts.Collation == nil || opts.Collation.Locale != "en" || opts.Collation.Strength != 2 {
+		t.Fatalf("expected the collation to be set, got %+v", opts.Collation)
+	}
+}
+
 func TestExecuteMultiMutationAsQueryWithNullOps(t *testing.T) {
 	conn := &Conn{}
 	q := &QueryDSL{
@@ -148,6 +217,30 @@ func TestExecuteMultiMutationAsQueryWithNullOps(t *testing.T) {
 	}
 }
 
+// TestInsertManyOrdered tests the ordered option and the filtering of failed inserts
+func TestInsertManyOrdered(t *testing.T) {
+	var q QueryDSL
+	if err := json.Unmarshal([]byte(`{"operation":"insertMany","collection":"users"}`), &q); err != nil {
+		t.Fatal(err)
+	}
+	if !isOrdered(&q) {
+		t.Error("insertMany should be ordered by default")
+	}
+
+	if err := json.Unmarshal([]byte(`{"operation":"insertMany","collection":"users","options":{"ordered":false}}`), &q); err != nil {
+		t.Fatal(err)
+	}
+	if isOrdered(&q) {
+		t.Error("insertMany should be unordered when options.ordered is false")
+	}
+
+	ids := []any{1, 2, 3, 4}
+	got := filterInsertedIDs(ids, map[int]struct{}{1: {}, 3: {}})
+	if len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Errorf("filterInsertedIDs() = %v, want [1 3]", got)
+	}
+}
+
 // TestConnectorCreation tests creating a MongoDB connector
 func TestConnectorCreation(t *testing.T) {
 	// This test doesn't require a running MongoDB instance
@@ -310,6 +403,1396 @@ func TestWithMongoDB(t *testing.T) {
 		}
 	})
 
+	t.Run("update embedded array elements by filter", func(t *testing.T) {
+		orders := db.Collection("orders")
+		orders.Drop(ctx)
+		defer orders.Drop(ctx)
+
+		_, err := orders.InsertOne(ctx, bson.M{"_id": 1, "items": bson.A{
+			bson.M{"sku": "X", "qty": 1},
+			bson.M{"sku": "Y", "qty": 1},
+		}})
+		if err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+
+		update := func(sku string) []any {
+			query := `{"operation":"updateOne","collection":"orders","filter":{"_id":1},` +
+				`"update":{"$set":{"items.$[e1].qty":5}},"options":{"arrayFilters":[{"e1.sku":"$1"}]},"params":["$1"]}`
+			if _, err := sqlDB.ExecContext(ctx, query, sku); err != nil {
+				t.Fatalf("Update failed: %v", err)
+			}
+			var doc struct{ Items []struct{ Qty int } }
+			if err := orders.FindOne(ctx, bson.M{"_id": 1}).Decode(&doc); err != nil {
+				t.Fatalf("FindOne failed: %v", err)
+			}
+			qty := make([]any, len(doc.Items))
+			for i, item := range doc.Items {
+				qty[i] = item.Qty
+			}
+			return qty
+		}
+
+		if got := update("X"); got[0] != 5 || got[1] != 1 {
+			t.Errorf("Expected only the matching element to be updated, got %v", got)
+		}
+		// No element matches so the document is left unchanged
+		if got := update("Z"); got[0] != 5 || got[1] != 1 {
+			t.Errorf("Expected no elements to be updated, got %v", got)
+		}
+	})
+
+	t.Run("replace an embedded array", func(t *testing.T) {
+		orders := db.Collection("orders")
+		orders.Drop(ctx)
+		defer orders.Drop(ctx)
+
+		_, err := orders.InsertOne(ctx, bson.M{"_id": 1, "items": bson.A{
+			bson.M{"_id": "a", "sku": "X", "qty": 1},
+			bson.M{"_id": "b", "sku": "Y", "qty": 1},
+			bson.M{"_id": "c", "sku": "Z", "qty": 1},
+		}})
+		if err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+
+		query := `{"operation":"updateOne","collection":"orders","filter":{"_id":1},` +
+			`"update":{"$set":{"items":[{"_id":"a","sku":"X","qty":2},{"sku":"W","qty":"$1"}]}},"params":["$1"]}`
+		if _, err := sqlDB.ExecContext(ctx, query, 3); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+
+		var doc struct {
+			Items []struct {
+				ID  string `bson:"_id"`
+				Sku string
+				Qty int
+			}
+		}
+		if err := orders.FindOne(ctx, bson.M{"_id": 1}).Decode(&doc); err != nil {
+			t.Fatalf("FindOne failed: %v", err)
+		}
+		if len(doc.Items) != 2 ||
+			doc.Items[0].ID != "a" || doc.Items[0].Qty != 2 ||
+			doc.Items[1].Sku != "W" || doc.Items[1].Qty != 3 {
+			t.Errorf("Expected the three items to be replaced by two, got %+v", doc.Items)
+		}
+	})
+
+	t.Run("sum decimal values", func(t *testing.T) {
+		payments := db.Collection("payments")
+		payments.Drop(ctx)
+		defer payments.Drop(ctx)
+
+		amount, err := bson.ParseDecimal128("0.01")
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs := make([]any, 1000)
+		for i := range docs {
+			docs[i] = bson.M{"amount": amount}
+		}
+		if _, err := payments.InsertMany(ctx, docs); err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+
+		query := `{"operation":"aggregate","collection":"payments","pipeline":[` +
+			`{"$group":{"_id":null,"sum_amount":{"$sum":{"$toDecimal":"$amount"}}}},` +
+			`{"$project":{"_id":0,"sum_amount":1}}]}`
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, query).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		var rows []map[string]any
+		if err := json.Unmarshal(result, &rows); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if len(rows) != 1 || rows[0]["sum_amount"] != "10.00" {
+			t.Errorf("Expected an exact decimal total of 10.00, got %s", result)
+		}
+	})
+
+	t.Run("flatten singular relationship", func(t *testing.T) {
+		products := db.Collection("products")
+		products.Drop(ctx)
+		defer products.Drop(ctx)
+
+		_, err := products.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "name": "Product One", "owner": bson.A{bson.M{"name": "Owner", "email": "o@test.com"}}},
+			bson.M{"_id": 2, "name": "Product Two", "owner": bson.A{}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+
+		query := `{"operation":"aggregate","collection":"products","pipeline":[` +
+			`{"$project":{"name":1,"owner":{"$ifNull":[{"$arrayElemAt":["$owner",0]},null]}}},` +
+			`{"$replaceRoot":{"newRoot":{"$mergeObjects":[{"$ifNull":["$owner",{}]},"$$ROOT"]}}},` +
+			`{"$project":{"owner":0}},{"$sort":{"_id":1}}]}`
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, query).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		var rows []map[string]any
+		if err := json.Unmarshal(result, &rows); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if len(rows) != 2 {
+			t.Fatalf("Expected 2 products, got %s", result)
+		}
+		// the parent's name wins over the owner's name
+		if rows[0]["name"] != "Product One" || rows[0]["email"] != "o@test.com" {
+			t.Errorf("Expected the owner to be merged into the product, got %v", rows[0])
+		}
+		// no owner leaves the product unchanged
+		if rows[1]["name"] != "Product Two" || len(rows[1]) != 2 {
+			t.Errorf("Expected the product without an owner to be unchanged, got %v", rows[1])
+		}
+	})
+
+	t.Run("update with version field", func(t *testing.T) {
+		notes := db.Collection("notes")
+		notes.Drop(ctx)
+		defer notes.Drop(ctx)
+
+		if _, err := notes.InsertOne(ctx, bson.M{"_id": 1, "body": "First", "version": 1}); err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+
+		query := `{"operation":"updateOne","collection":"notes","filter":{"id":1,"version":1},` +
+			`"update":{"$set":{"body":"Second"},"$inc":{"version":1}},"version_field":"version","field_name":"notes","singular":true}`
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, query).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		var res map[string]map[string]any
+		if err := json.Unmarshal(result, &res); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if n := res["notes"]; n == nil || n["body"] != "Second" || n["version"] != float64(2) {
+			t.Fatalf("Expected the updated note with the next version, got %s", result)
+		}
+
+		// the same expected version no longer matches
+		if err := sqlDB.QueryRowContext(ctx, query).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if string(result) != `{"notes":null}` {
+			t.Errorf("Expected no note for a stale version, got %s", result)
+		}
+	})
+
+	t.Run("case insensitive collation", func(t *testing.T) {
+		tags := db.Collection("tags")
+		tags.Drop(ctx)
+		defer tags.Drop(ctx)
+
+		_, err := tags.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "label": "beta"},
+			bson.M{"_id": 2, "label": "Alpha"},
+			bson.M{"_id": 3, "label": "Gamma"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+
+		query := `{"operation":"aggregate","collection":"tags","field_name":"tags",` +
+			`"options":{"collation":{"locale":"en","strength":2}},"pipeline":[` +
+			`{"$match":{"label":{"$in":["ALPHA","beta","gamma"]}}},{"$sort":{"label":1}},{"$project":{"label":1}}]}`
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, query).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		var res map[string][]map[string]any
+		if err := json.Unmarshal(result, &res); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		rows := res["tags"]
+		if len(rows) != 3 {
+			t.Fatalf("Expected all tags to match ignoring case, got %s", result)
+		}
+		if rows[0]["label"] != "Alpha" || rows[1]["label"] != "beta" || rows[2]["label"] != "Gamma" {
+			t.Errorf("Expected the tags sorted ignoring case, got %s", result)
+		}
+	})
+
+	t.Run("static lookup table", func(t *testing.T) {
+		orders := db.Collection("orders")
+		orders.Drop(ctx)
+		defer orders.Drop(ctx)
+
+		_, err := orders.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "status_code": "N"},
+			bson.M{"_id": 2, "status_code": "X"},
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+
+		query := `{"operation":"aggregate","collection":"orders","field_name":"orders","pipeline":[` +
+			`{"$lookup":{"let":{"joinValue":"$status_code"},"pipeline":[` +
+			`{"$documents":[{"code":"N","label":"New"},{"code":"S","label":"Shipped"}]},` +
+			`{"$match":{"$expr":{"$eq":["$code","$$joinValue"]}}},{"$project":{"_id":0,"label":1}}],"as":"status"}},` +
+			`{"$sort":{"_id":1}}]}`
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, query).Scan(&result); err != nil {
+			if strings.Contains(err.Error(), "MongoDB 5.1") {
+				t.Skipf("static lookup tables are not supported: %v", err)
+			}
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		var res map[string][]map[string]any
+		if err := json.Unmarshal(result, &res); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		rows := res["orders"]
+		if len(rows) != 2 {
+			t.Fatalf("Expected 2 orders, got %s", result)
+		}
+		if st, _ := rows[0]["status"].([]any); len(st) != 1 || st[0].(map[string]any)["label"] != "New" {
+			t.Errorf("Expected the status from the static rows, got %v", rows[0])
+		}
+		if st, _ := rows[1]["status"].([]any); len(st) != 0 {
+			t.Errorf("Expected no status for an unknown code, got %v", rows[1])
+		}
+	})
+
+	t.Run("watch updated fields", func(t *testing.T) {
+		query := `{"operation":"watch","collection":"users","field_name":"users","changes":"fields",` +
+			`"filter":{"name":"Alice"},"fields":{"id":"_id","name":"name","age":"age"}}`
+
+		wctx, wcancel := context.WithTimeout(ctx, 5*time.Second)
+		defer wcancel()
+
+		rows, err := sqlDB.QueryContext(wctx, query)
+		if err != nil {
+			if strings.Contains(err.Error(), "replica set") || strings.Contains(err.Error(), "MongoDB 6.0") {
+				t.Skipf("change streams are not supported: %v", err)
+			}
+			t.Fatalf("Watch failed: %v", err)
+		}
+		defer rows.Close()
+
+		// only the change to the matching document is delivered
+		if _, err := coll.UpdateOne(ctx, bson.M{"name": "Bob"}, bson.M{"$set": bson.M{"age": 26}}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if _, err := coll.UpdateOne(ctx, bson.M{"name": "Alice"}, bson.M{"$set": bson.M{"age": 31}}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+
+		if !rows.Next() {
+			t.Fatalf("Expected a change: %v", rows.Err())
+		}
+		var result []byte
+		if err := rows.Scan(&result); err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+
+		var res struct {
+			Users       map[string]any `json:"users"`
+			DocumentKey map[string]any `json:"documentKey"`
+		}
+		if err := json.Unmarshal(result, &res); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if len(res.Users) != 1 || res.Users["age"] != float64(31) {
+			t.Errorf("Expected only the updated age, got %s", result)
+		}
+		if res.DocumentKey["id"] == nil {
+			t.Errorf("Expected the document key, got %s", result)
+		}
+	})
+
+	t.Run("root count", func(t *testing.T) {
+		query := `{"operation":"multi_aggregate","queries":[` +
+			`{"operation":"aggregate","collection":"users","field_name":"older","pipeline":[{"$match":{"age":{"$gt":25}}},{"$count":"total"}],"count":true},` +
+			`{"operation":"aggregate","collection":"users","field_name":"none","pipeline":[{"$match":{"age":{"$gt":99}}},{"$count":"total"}],"count":true,"count_field":"count_id"}]}`
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, query).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if exp := `{"none":[{"count_id":0}],"older":2}`; string(result) != exp {
+			t.Errorf("Expected %s, got %s", exp, result)
+		}
+	})
+
+	t.Run("array null checks", func(t *testing.T) {
+		posts := db.Collection("null_posts")
+		posts.Drop(ctx)
+		defer posts.Drop(ctx)
+
+		_, err := posts.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "tags": bson.A{}},
+			bson.M{"_id": 2},
+			bson.M{"_id": 3, "tags": nil},
+			bson.M{"_id": 4, "tags": bson.A{nil}},
+			bson.M{"_id": 5, "tags": bson.A{"go", nil}},
+			bson.M{"_id": 6, "tags": bson.A{"go"}},
+		})
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		isNull := `{"$or":[{"tags":{"$size":0}},{"$and":[{"tags":null},{"tags":{"$not":{"$type":"array"}}}]}]}`
+		hasNull := `{"tags":{"$elemMatch":{"$eq":null}}}`
+
+		tests := []struct {
+			name  string
+			match string
+			exp   string
+		}{
+			{"is null", isNull, `[{"id":1},{"id":2},{"id":3}]`},
+			{"is not null", `{"$nor":[` + isNull + `]}`, `[{"id":4},{"id":5},{"id":6}]`},
+			{"has null", hasNull, `[{"id":4},{"id":5}]`},
+			{"has no null", `{"$nor":[` + hasNull + `]}`, `[{"id":1},{"id":2},{"id":3},{"id":6}]`},
+		}
+		for _, tt := range tests {
+			query := `{"operation":"aggregate","collection":"null_posts","field_name":"posts","pipeline":[` +
+				`{"$match":` + tt.match + `},{"$sort":{"_id":1}},{"$project":{"_id":1}}]}`
+
+			var result []byte
+			if err := sqlDB.QueryRowContext(ctx, query).Scan(&result); err != nil {
+				t.Fatalf("%s: Query failed: %v", tt.name, err)
+			}
+			if exp := `{"posts":` + tt.exp + `}`; string(result) != exp {
+				t.Errorf("%s: Expected %s, got %s", tt.name, exp, result)
+			}
+		}
+	})
+
+	t.Run("embedded element projection", func(t *testing.T) {
+		orders := db.Collection("embedded_orders")
+		orders.Drop(ctx)
+		defer orders.Drop(ctx)
+
+		_, err := orders.InsertOne(ctx, bson.M{"_id": 1, "items": bson.A{
+			bson.M{"_id": "a", "sku": "x", "dims": bson.M{"width": 2}},
+			bson.M{"id": "b", "sku": "y", "dims": bson.A{bson.M{"width": 3}}},
+		}})
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		dims := `{"$switch":{"branches":[{"case":{"$isArray":"$$elem.dims"},"then":"$$elem.dims"},` +
+			`{"case":{"$eq":[{"$type":"$$elem.dims"},"object"]},"then":["$$elem.dims"]}],"default":[]}}`
+		query := `{"operation":"aggregate","collection":"embedded_orders","field_name":"orders","pipeline":[` +
+			`{"$addFields":{"items":{"$map":{"input":"$items","as":"elem","in":{` +
+			`"code":"$$elem.sku",` +
+			`"dims":{"$map":{"input":` + dims + `,"as":"sub","in":{"w":"$$sub.width"}}},` +
+			`"id":{"$ifNull":["$$elem._id","$$elem.id"]}}}}}},` +
+			`{"$project":{"_id":1,"items":1}}]}`
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, query).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		exp := `{"orders":[{"id":1,"items":[{"code":"x","dims":[{"w":2}],"id":"a"},{"code":"y","dims":[{"w":3}],"id":"b"}]}]}`
+		if string(result) != exp {
+			t.Errorf("Expected %s, got %s", exp, result)
+		}
+	})
+
+	t.Run("materialized aggregate", func(t *testing.T) {
+		orders := db.Collection("mat_orders")
+		cache := db.Collection("mat_order_totals")
+		orders.Drop(ctx)
+		cache.Drop(ctx)
+		defer orders.Drop(ctx)
+		defer cache.Drop(ctx)
+
+		_, err := orders.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "status": "paid", "total": 10},
+			bson.M{"_id": 2, "status": "paid", "total": 20},
+		})
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		query := func(status string) string {
+			var result []byte
+			q := `{"operation":"aggregate","collection":"mat_orders","field_name":"totals",` +
+				`"materialize":{"collection":"mat_order_totals","ttl":60},"pipeline":[` +
+				`{"$match":{"status":"` + status + `"}},{"$group":{"_id":null,"sum":{"$sum":"$total"}}},` +
+				`{"$project":{"_id":0,"sum":1}}]}`
+			if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			return string(result)
+		}
+
+		if got, exp := query("paid"), `{"totals":[{"sum":30}]}`; got != exp {
+			t.Fatalf("Expected %s, got %s", exp, got)
+		}
+
+		// the cached results are returned until they expire
+		if _, err := orders.InsertOne(ctx, bson.M{"_id": 3, "status": "paid", "total": 5}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if got, exp := query("paid"), `{"totals":[{"sum":30}]}`; got != exp {
+			t.Fatalf("Expected the cached results %s, got %s", exp, got)
+		}
+
+		// other values are cached separately, empty results too
+		if got, exp := query("open"), `{"totals":[]}`; got != exp {
+			t.Fatalf("Expected %s, got %s", exp, got)
+		}
+		if n, _ := cache.CountDocuments(ctx, bson.M{}); n != 2 {
+			t.Fatalf("Expected 2 cached results, got %d", n)
+		}
+
+		// expired results are regenerated
+		if _, err := cache.UpdateMany(ctx, bson.M{}, bson.M{"$set": bson.M{"at": time.Now().Add(-time.Hour)}}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if got, exp := query("paid"), `{"totals":[{"sum":35}]}`; got != exp {
+			t.Fatalf("Expected the regenerated results %s, got %s", exp, got)
+		}
+	})
+
+	t.Run("count of related documents", func(t *testing.T) {
+		users := db.Collection("cnt_users")
+		orders := db.Collection("cnt_orders")
+		users.Drop(ctx)
+		orders.Drop(ctx)
+		defer users.Drop(ctx)
+		defer orders.Drop(ctx)
+
+		_, err := users.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "email": "a@test.com"},
+			bson.M{"_id": 2, "email": "b@test.com"},
+		})
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		_, err = orders.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "user_id": 1},
+			bson.M{"_id": 2, "user_id": 1},
+			bson.M{"_id": 3, "user_id": 2},
+		})
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		var result []byte
+		q := `{"operation":"aggregate","collection":"cnt_users","field_name":"users","pipeline":[` +
+			`{"$lookup":{"from":"cnt_orders","let":{"joinValue":"$_id"},"pipeline":[` +
+			`{"$match":{"$expr":{"$eq":["$user_id","$$joinValue"]}}},{"$project":{"_id":1}}],` +
+			`"as":"__count_cnt_orders_user_id_id"}},` +
+			`{"$match":{"$expr":{"$gt":[{"$size":"$__count_cnt_orders_user_id_id"},1]}}},` +
+			`{"$project":{"_id":1,"email":1}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if got, exp := string(result), `{"users":[{"email":"a@test.com","id":1}]}`; got != exp {
+			t.Fatalf("Expected %s, got %s", exp, got)
+		}
+	})
+
+	t.Run("filter on array length", func(t *testing.T) {
+		orders := db.Collection("size_orders")
+		orders.Drop(ctx)
+		defer orders.Drop(ctx)
+
+		_, err := orders.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "items": bson.A{"a", "b", "c"}},
+			bson.M{"_id": 2, "items": bson.A{"a"}},
+			bson.M{"_id": 3, "items": bson.A{}},
+			bson.M{"_id": 4},
+			bson.M{"_id": 5, "items": nil},
+		})
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		size := `{"$size":{"$ifNull":["$items",[]]}}`
+		tests := []struct {
+			match string
+			exp   string
+		}{
+			{`{"$expr":{"$gte":[` + size + `,2]}}`, `{"orders":[{"id":1}]}`},
+			{`{"$expr":{"$lt":[` + size + `,2]}}`, `{"orders":[{"id":2},{"id":3},{"id":4},{"id":5}]}`},
+			{`{"$expr":{"$eq":[` + size + `,0]}}`, `{"orders":[{"id":3},{"id":4},{"id":5}]}`},
+		}
+		for _, tt := range tests {
+			var result []byte
+			q := `{"operation":"aggregate","collection":"size_orders","field_name":"orders","pipeline":[` +
+				`{"$match":` + tt.match + `},{"$sort":{"_id":1}},{"$project":{"_id":1}}]}`
+			if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if got := string(result); got != tt.exp {
+				t.Fatalf("%s: expected %s, got %s", tt.match, tt.exp, got)
+			}
+		}
+	})
+
+	t.Run("insert and filter with ObjectIds", func(t *testing.T) {
+		products := db.Collection("oid_products")
+		products.Drop(ctx)
+		defer products.Drop(ctx)
+
+		id, ownerID := bson.NewObjectID(), bson.NewObjectID()
+
+		var result []byte
+		q := `{"operation":"insertOne","collection":"oid_products","raw_document":"$1",` +
+			`"object_ids":["_id","owner_id"],"fk_connect":{"path":"owner","column":"owner_id"},` +
+			`"field_name":"products","return_pipeline":[{"$project":{"_id":1}}]}`
+		doc := `{"id":"` + id.Hex() + `","name":"Apple","owner":{"connect":{"id":"` + ownerID.Hex() + `"}}}`
+		if err := sqlDB.QueryRowContext(ctx, q, json.RawMessage(doc)).Scan(&result); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		var stored bson.M
+		if err := products.FindOne(ctx, bson.M{"_id": id}).Decode(&stored); err != nil {
+			t.Fatalf("expected the document to be stored with an ObjectId: %v", err)
+		}
+		if stored["owner_id"] != ownerID {
+			t.Fatalf("expected the owner_id to be an ObjectId, got: %#v", stored["owner_id"])
+		}
+
+		q = `{"operation":"aggregate","collection":"oid_products","field_name":"products","pipeline":[` +
+			`{"$match":{"owner_id":{"$in":{"$oid":"$1"}}}},{"$project":{"_id":0,"name":1}}]}`
+		ids := json.RawMessage(`["` + ownerID.Hex() + `"]`)
+		if err := sqlDB.QueryRowContext(ctx, q, ids).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if exp := `{"products":[{"name":"Apple"}]}`; string(result) != exp {
+			t.Fatalf("expected %s, got %s", exp, result)
+		}
+
+		if err := sqlDB.QueryRowContext(ctx, q, json.RawMessage(`["not-an-id"]`)).Scan(&result); err == nil {
+			t.Fatal("expected an error for the invalid ObjectId")
+		}
+	})
+
+	t.Run("get or create with an upsert", func(t *testing.T) {
+		products := db.Collection("goc_products")
+		products.Drop(ctx)
+		defer products.Drop(ctx)
+
+		q := `{"operation":"updateOne","collection":"goc_products","filter":{"name":"$1"},` +
+			`"update":{"$setOnInsert":{"name":"$1","price":"$2"}},"options":{"upsert":true},` +
+			`"field_name":"products","singular":true,"return_pipeline":[{"$project":{"_id":0,"name":1,"price":1}}]}`
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, q, "Apple", 1).Scan(&result); err != nil {
+			t.Fatalf("Upsert failed: %v", err)
+		}
+		if exp := `{"products":{"name":"Apple","price":1}}`; string(result) != exp {
+			t.Fatalf("expected the created document %s, got %s", exp, result)
+		}
+
+		// the existing document is returned unchanged
+		if err := sqlDB.QueryRowContext(ctx, q, "Apple", 2).Scan(&result); err != nil {
+			t.Fatalf("Upsert failed: %v", err)
+		}
+		if exp := `{"products":{"name":"Apple","price":1}}`; string(result) != exp {
+			t.Fatalf("expected the existing document %s, got %s", exp, result)
+		}
+
+		if n, err := products.CountDocuments(ctx, bson.M{}); err != nil || n != 1 {
+			t.Fatalf("expected a single document, got %d: %v", n, err)
+		}
+	})
+
+	t.Run("bulk upsert of documents", func(t *testing.T) {
+		users := db.Collection("bu_users")
+		users.Drop(ctx)
+		defer users.Drop(ctx)
+
+		if _, err := users.InsertOne(ctx, bson.M{"email": "one@test.com", "name": "One", "role": "admin"}); err != nil {
+			t.Fatal(err)
+		}
+
+		q := `{"operation":"bulkUpsert","collection":"bu_users","raw_document":"$1",` +
+			`"conflict_target":["email"],"conflict_update":["name"],"field_name":"users",` +
+			`"return_pipeline":[{"$sort_ordered":[["email",1]]},{"$project":{"_id":0,"email":1,"name":1,"role":1}}]}`
+		data := json.RawMessage(`[{"email":"one@test.com","name":"One Updated","role":"user"},` +
+			`{"email":"two@test.com","name":"Two","role":"user"}]`)
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, q, data).Scan(&result); err != nil {
+			t.Fatalf("Bulk upsert failed: %v", err)
+		}
+		// the role is only set on the inserted document
+		exp := `{"users":[{"email":"one@test.com","name":"One Updated","role":"admin"},` +
+			`{"email":"two@test.com","name":"Two","role":"user"}]}`
+		if string(result) != exp {
+			t.Fatalf("expected %s, got %s", exp, result)
+		}
+
+		if n, err := users.CountDocuments(ctx, bson.M{}); err != nil || n != 2 {
+			t.Fatalf("expected two documents, got %d: %v", n, err)
+		}
+	})
+
+	t.Run("bulk upsert with a filter and conflict presets", func(t *testing.T) {
+		users := db.Collection("bu_owned_users")
+		users.Drop(ctx)
+		defer users.Drop(ctx)
+
+		if _, err := users.InsertMany(ctx, []any{
+			bson.M{"email": "one@test.com", "name": "One", "owner": int64(1)},
+			bson.M{"email": "two@test.com", "name": "Two", "owner": int64(2)},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		// only the documents of owner 1 are updated
+		q := `{"operation":"bulkUpsert","collection":"bu_owned_users","raw_document":"$1",` +
+			`"conflict_target":["email"],"conflict_update":["name"],"conflict_presets":{"updated":true},` +
+			`"filter":{"owner":"$2"},"field_name":"users",` +
+			`"return_pipeline":[{"$sort_ordered":[["email",1]]},{"$project":{"_id":0,"email":1,"name":1,"updated":1}}]}`
+		data := json.RawMessage(`[{"email":"one@test.com","name":"One Updated"},` +
+			`{"email":"two@test.com","name":"Two Updated"},{"email":"three@test.com","name":"Three"}]`)
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, q, data, int64(1)).Scan(&result); err != nil {
+			t.Fatalf("Bulk upsert failed: %v", err)
+		}
+		exp := `{"users":[{"email":"one@test.com","name":"One Updated","updated":true},` +
+			`{"email":"three@test.com","name":"Three"},{"email":"two@test.com","name":"Two"}]}`
+		if string(result) != exp {
+			t.Fatalf("expected %s, got %s", exp, result)
+		}
+
+		if n, err := users.CountDocuments(ctx, bson.M{}); err != nil || n != 3 {
+			t.Fatalf("expected three documents, got %d: %v", n, err)
+		}
+	})
+
+	t.Run("regex on an array of strings", func(t *testing.T) {
+		posts := db.Collection("rx_posts")
+		posts.Drop(ctx)
+		defer posts.Drop(ctx)
+
+		docs := []any{
+			bson.M{"_id": 1, "tags": bson.A{"Prod-A", "other"}},
+			bson.M{"_id": 2, "tags": bson.A{"misc", "PROD-B"}},
+			bson.M{"_id": 3, "tags": bson.A{"staging"}},
+		}
+		if _, err := posts.InsertMany(ctx, docs); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		tests := []struct {
+			match string
+			exp   string
+		}{
+			{`{"tags":{"$elemMatch":{"$regex":"^prod.*$","$options":"i"}}}`, `{"posts":[{"id":1},{"id":2}]}`},
+			{`{"tags":{"$elemMatch":{"$regex":"^Prod.*$"}}}`, `{"posts":[{"id":1}]}`},
+			{`{"tags":{"$not":{"$elemMatch":{"$regex":"^prod.*$","$options":"i"}}}}`, `{"posts":[{"id":3}]}`},
+		}
+		for _, tt := range tests {
+			q := `{"operation":"aggregate","collection":"rx_posts","field_name":"posts","pipeline":[` +
+				`{"$match":` + tt.match + `},{"$sort_ordered":[["_id",1]]},{"$project":{"_id":1}}]}`
+			var result []byte
+			if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if string(result) != tt.exp {
+				t.Fatalf("%s: expected %s, got %s", tt.match, tt.exp, result)
+			}
+		}
+	})
+
+	t.Run("nested lookup within a polymorphic member", func(t *testing.T) {
+		notifications := db.Collection("poly_notifications")
+		posts := db.Collection("poly_posts")
+		users := db.Collection("poly_users")
+		for _, c := range []*mongo.Collection{notifications, posts, users} {
+			c.Drop(ctx)
+			defer c.Drop(ctx)
+		}
+
+		if _, err := users.InsertOne(ctx, bson.M{"_id": 1, "name": "Ann"}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if _, err := posts.InsertOne(ctx, bson.M{"_id": 1, "title": "Hello", "author_id": 1}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if _, err := notifications.InsertOne(ctx, bson.M{"_id": 1, "subject_type": "poly_posts", "subject_id": 1}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		var result []byte
+		q := `{"operation":"aggregate","collection":"poly_notifications","field_name":"notifications","pipeline":[` +
+			`{"$lookup":{"from":"poly_posts","let":{"typeVal":"$subject_type","idVal":"$subject_id"},"pipeline":[` +
+			`{"$match":{"$expr":{"$and":[{"$eq":["$$typeVal","poly_posts"]},{"$eq":["$_id","$$idVal"]}]}}},` +
+			`{"$lookup":{"from":"poly_users","let":{"joinValue":"$author_id"},"pipeline":[` +
+			`{"$match":{"$expr":{"$eq":["$_id","$$joinValue"]}}},{"$project":{"_id":0,"name":"$name"}}],"as":"author"}},` +
+			`{"$project":{"_id":0,"title":1,"author":{"$ifNull":[{"$arrayElemAt":["$author",0]},null]}}}],"as":"__poly_poly_posts"}},` +
+			`{"$project":{"_id":1,"subject":{"$switch":{"branches":[{"case":{"$eq":["$subject_type","poly_posts"]},` +
+			`"then":{"$arrayElemAt":["$__poly_poly_posts",0]}}],"default":null}}}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		exp := `{"notifications":[{"id":1,"subject":{"author":{"name":"Ann"},"title":"Hello"}}]}`
+		if got := string(result); got != exp {
+			t.Fatalf("Expected %s, got %s", exp, got)
+		}
+	})
+
+	t.Run("soft delete", func(t *testing.T) {
+		notes := db.Collection("sd_notes")
+		notes.Drop(ctx)
+		defer notes.Drop(ctx)
+
+		if _, err := notes.InsertOne(ctx, bson.M{"_id": 1, "body": "First"}); err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+
+		query := `{"operation":"deleteOne","collection":"sd_notes","filter":{"$and":[{"deleted_at":null},{"_id":1}]},` +
+			`"soft_delete":"deleted_at","field_name":"notes","singular":true,"return_pipeline":[{"$project":{"_id":1,"body":1}}]}`
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, query).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if got, exp := string(result), `{"notes":{"body":"First","id":1}}`; got != exp {
+			t.Fatalf("Expected %s, got %s", exp, got)
+		}
+
+		// the document is kept with the field set
+		var doc bson.M
+		if err := notes.FindOne(ctx, bson.M{"_id": 1}).Decode(&doc); err != nil {
+			t.Fatalf("Expected the document to be kept: %v", err)
+		}
+		if _, ok := doc["deleted_at"].(bson.DateTime); !ok {
+			t.Fatalf("Expected deleted_at to be set to the current time, got %v", doc)
+		}
+
+		// a deleted document is not deleted again
+		if err := sqlDB.QueryRowContext(ctx, query).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if string(result) != `{"notes":null}` {
+			t.Errorf("Expected no note for a deleted document, got %s", result)
+		}
+	})
+
+	t.Run("text search ranked by score", func(t *testing.T) {
+		posts := db.Collection("ts_posts")
+		posts.Drop(ctx)
+		defer posts.Drop(ctx)
+
+		_, err := posts.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.M{"body": "text"}})
+		if err != nil {
+			t.Fatalf("Failed to create text index: %v", err)
+		}
+		_, err = posts.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "body": "coffee", "draft": false},
+			bson.M{"_id": 2, "body": "coffee and more coffee with coffee", "draft": false},
+			bson.M{"_id": 3, "body": "coffee coffee coffee", "draft": true},
+			bson.M{"_id": 4, "body": "tea", "draft": false},
+		})
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		var result []byte
+		q := `{"operation":"aggregate","collection":"ts_posts","field_name":"posts","pipeline":[` +
+			`{"$match":{"$text":{"$search":"coffee"}}},` +
+			`{"$addFields":{"__search_rank":{"$meta":"textScore"}}},` +
+			`{"$match":{"draft":false}},` +
+			`{"$sort_ordered":[["__search_rank",-1],["_id",1]]},` +
+			`{"$project":{"_id":1,"rank":"$__search_rank"}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		var res map[string][]map[string]any
+		if err := json.Unmarshal(result, &res); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		rows := res["posts"]
+		if len(rows) != 2 || rows[0]["id"] != float64(2) || rows[1]["id"] != float64(1) {
+			t.Fatalf("Expected the posts ordered by relevance, got %s", result)
+		}
+		if r0, r1 := rows[0]["rank"].(float64), rows[1]["rank"].(float64); r0 <= r1 {
+			t.Errorf("Expected a higher rank for the more relevant post, got %s", result)
+		}
+	})
+
+	t.Run("nested lookup ordered by related field", func(t *testing.T) {
+		users, posts, cats := db.Collection("ob_users"), db.Collection("ob_posts"), db.Collection("ob_categories")
+		for _, c := range []*mongo.Collection{users, posts, cats} {
+			c.Drop(ctx)
+			defer c.Drop(ctx)
+		}
+		if _, err := users.InsertOne(ctx, bson.M{"_id": 1}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if _, err := cats.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "rank": 3},
+			bson.M{"_id": 2, "rank": 1},
+			bson.M{"_id": 3, "rank": 2},
+		}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if _, err := posts.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "user_id": 1, "category_id": 1},
+			bson.M{"_id": 2, "user_id": 1, "category_id": 2},
+			bson.M{"_id": 3, "user_id": 1, "category_id": 3},
+		}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		var result []byte
+		q := `{"operation":"aggregate","collection":"ob_users","field_name":"users","pipeline":[` +
+			`{"$lookup":{"from":"ob_posts","let":{"joinValue":"$_id"},"pipeline":[` +
+			`{"$match":{"$expr":{"$eq":["$user_id","$$joinValue"]}}},` +
+			`{"$lookup":{"from":"ob_categories","localField":"category_id","foreignField":"_id","as":"__ob_ob_categories"}},` +
+			`{"$addFields":{"__ob_ob_categories":{"$arrayElemAt":["$__ob_ob_categories",0]}}},` +
+			`{"$sort_ordered":[["__ob_ob_categories.rank",-1]]},` +
+			`{"$project":{"_id":"$_id"}}],"as":"posts"}},` +
+			`{"$project":{"_id":1,"posts":1}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		var res map[string][]struct {
+			Posts []map[string]any `json:"posts"`
+		}
+		if err := json.Unmarshal(result, &res); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if len(res["users"]) != 1 {
+			t.Fatalf("Expected one user, got %s", result)
+		}
+		p := res["users"][0].Posts
+		if len(p) != 3 || p[0]["id"] != float64(1) || p[1]["id"] != float64(3) || p[2]["id"] != float64(2) {
+			t.Fatalf("Expected the posts ordered by category rank, got %s", result)
+		}
+		if _, ok := p[0]["__ob_ob_categories"]; ok {
+			t.Errorf("Expected the looked up category to be projected away, got %s", result)
+		}
+	})
+
+	t.Run("singular lookup with no match returns the default", func(t *testing.T) {
+		users, profiles := db.Collection("def_users"), db.Collection("def_profiles")
+		for _, c := range []*mongo.Collection{users, profiles} {
+			c.Drop(ctx)
+			defer c.Drop(ctx)
+		}
+		if _, err := profiles.InsertOne(ctx, bson.M{"_id": 1, "bio": "Hello"}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if _, err := users.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "profile_id": 1},
+			bson.M{"_id": 2, "profile_id": 5},
+		}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		var result []byte
+		q := `{"operation":"aggregate","collection":"def_users","field_name":"users","pipeline":[` +
+			`{"$lookup":{"from":"def_profiles","let":{"joinValue":"$profile_id"},"pipeline":[` +
+			`{"$match":{"$expr":{"$eq":["$_id","$$joinValue"]}}},` +
+			`{"$project":{"_id":0,"bio":"$bio"}}],"as":"profile"}},` +
+			`{"$sort_ordered":[["_id",1]]},` +
+			`{"$project":{"_id":1,"profile":{"$ifNull":[{"$arrayElemAt":["$profile",0]},{"$literal":{"bio":"No bio yet"}}]}}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		var res map[string][]struct {
+			Profile map[string]any `json:"profile"`
+		}
+		if err := json.Unmarshal(result, &res); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		rows := res["users"]
+		if len(rows) != 2 || rows[0].Profile["bio"] != "Hello" || rows[1].Profile["bio"] != "No bio yet" {
+			t.Fatalf("Expected the default profile for the user without one, got %s", result)
+		}
+	})
+
+	t.Run("project aggregates of array elements", func(t *testing.T) {
+		players := db.Collection("agg_players")
+		players.Drop(ctx)
+		defer players.Drop(ctx)
+
+		if _, err := players.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "scores": bson.A{1, 2, 3}},
+			bson.M{"_id": 2, "scores": bson.A{}},
+			bson.M{"_id": 3},
+		}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		var result []byte
+		q := `{"operation":"aggregate","collection":"agg_players","field_name":"players","pipeline":[` +
+			`{"$sort_ordered":[["_id",1]]},` +
+			`{"$project":{"_id":1,"scores_sum":{"$sum":"$scores"},"scores_avg":{"$avg":"$scores"}}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		var res map[string][]struct {
+			Sum float64  `json:"scores_sum"`
+			Avg *float64 `json:"scores_avg"`
+		}
+		if err := json.Unmarshal(result, &res); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		rows := res["players"]
+		// empty and missing arrays sum to 0 and have no average
+		if len(rows) != 3 || rows[0].Sum != 6 || rows[0].Avg == nil || *rows[0].Avg != 2 ||
+			rows[1].Sum != 0 || rows[1].Avg != nil || rows[2].Sum != 0 || rows[2].Avg != nil {
+			t.Fatalf("Expected the aggregates of each document, got %s", result)
+		}
+	})
+
+	t.Run("project a comparison as a boolean", func(t *testing.T) {
+		products := db.Collection("cmp_products")
+		products.Drop(ctx)
+		defer products.Drop(ctx)
+
+		if _, err := products.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "price": 150},
+			bson.M{"_id": 2, "price": 50},
+			bson.M{"_id": 3, "price": nil},
+			bson.M{"_id": 4},
+		}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		var result []byte
+		q := `{"operation":"aggregate","collection":"cmp_products","field_name":"products","pipeline":[` +
+			`{"$sort_ordered":[["_id",1]]},` +
+			`{"$project":{"_id":1,"is_cheap":{"$cond":[{"$eq":[{"$ifNull":["$price",null]},null]},null,` +
+			`{"$lt":["$price",{"$literal":100}]}]}}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		var res map[string][]struct {
+			IsCheap *bool `json:"is_cheap"`
+		}
+		if err := json.Unmarshal(result, &res); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		rows := res["products"]
+		// a null or missing price is not less than 100
+		if len(rows) != 4 || rows[0].IsCheap == nil || *rows[0].IsCheap ||
+			rows[1].IsCheap == nil || !*rows[1].IsCheap ||
+			rows[2].IsCheap != nil || rows[3].IsCheap != nil {
+			t.Fatalf("Expected the products compared with null for no price, got %s", result)
+		}
+	})
+
+	t.Run("project nested paths of a json column", func(t *testing.T) {
+		users := db.Collection("jp_users")
+		users.Drop(ctx)
+		defer users.Drop(ctx)
+
+		if _, err := users.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "metadata": bson.M{"theme": "dark", "prefs": bson.M{"font": "mono"}, "secret": "x"}},
+			bson.M{"_id": 2, "metadata": bson.M{"lang": "en"}},
+			bson.M{"_id": 3, "metadata": "plain"},
+			bson.M{"_id": 4},
+		}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		var result []byte
+		q := `{"operation":"aggregate","collection":"jp_users","field_name":"users","pipeline":[` +
+			`{"$sort_ordered":[["_id",1]]},` +
+			`{"$project":{"_id":1,"metadata":{"$cond":[{"$eq":[{"$type":"$metadata"},"object"]},{` +
+			`"theme":{"$ifNull":["$metadata.theme",null]},` +
+			`"prefs":{"$cond":[{"$eq":[{"$type":"$metadata.prefs"},"object"]},{"font":{"$ifNull":["$metadata.prefs.font",null]}},null]}` +
+			`},null]}}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		var res map[string][]struct {
+			Metadata json.RawMessage `json:"metadata"`
+		}
+		if err := json.Unmarshal(result, &res); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		rows := res["users"]
+		exp := []string{
+			`{"theme":"dark","prefs":{"font":"mono"}}`,
+			`{"theme":null,"prefs":null}`,
+			`null`,
+			`null`,
+		}
+		if len(rows) != len(exp) {
+			t.Fatalf("Expected %d users, got %s", len(exp), result)
+		}
+		for i, e := range exp {
+			if string(rows[i].Metadata) != e {
+				t.Fatalf("Expected the metadata of user %d to be %s, got %s", i+1, e, rows[i].Metadata)
+			}
+		}
+	})
+
+	t.Run("lookup excluded by a variable directive", func(t *testing.T) {
+		users := db.Collection("cl_users")
+		products := db.Collection("cl_products")
+		users.Drop(ctx)
+		products.Drop(ctx)
+		defer users.Drop(ctx)
+		defer products.Drop(ctx)
+
+		if _, err := users.InsertOne(ctx, bson.M{"_id": 1}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if _, err := products.InsertOne(ctx, bson.M{"_id": 10, "user_id": 1}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		q := `{"operation":"aggregate","collection":"cl_users","field_name":"users","pipeline":[` +
+			`{"$lookup":{"from":"cl_products","let":{"joinValue":"$_id"},"pipeline":[` +
+			`{"$match":{"$expr":{"$eq":["$1",true]}}},{"$match":{"$expr":{"$eq":["$user_id","$$joinValue"]}}},` +
+			`{"$project":{"_id":"$_id"}}],"as":"products"}},` +
+			`{"$project":{"_id":1,"products":{"$cond":{"if":{"$eq":["$1",true]},` +
+			`"then":{"$ifNull":["$products",[]]},"else":null}}}}],"params":["$1"]}`
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, q, true).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if exp := `{"users":[{"id":1,"products":[{"id":10}]}]}`; string(result) != exp {
+			t.Fatalf("expected the included products %s, got %s", exp, result)
+		}
+
+		if err := sqlDB.QueryRowContext(ctx, q, false).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if exp := `{"users":[{"id":1,"products":null}]}`; string(result) != exp {
+			t.Fatalf("expected the excluded products to be null %s, got %s", exp, result)
+		}
+	})
+
+	t.Run("project relationship existence", func(t *testing.T) {
+		users := db.Collection("ex_users")
+		products := db.Collection("ex_products")
+		users.Drop(ctx)
+		products.Drop(ctx)
+		defer users.Drop(ctx)
+		defer products.Drop(ctx)
+
+		if _, err := users.InsertMany(ctx, []any{bson.M{"_id": 1}, bson.M{"_id": 2}}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		if _, err := products.InsertMany(ctx, []any{
+			bson.M{"_id": 10, "owner_id": 1},
+			bson.M{"_id": 11, "owner_id": 1},
+		}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		q := `{"operation":"aggregate","collection":"ex_users","field_name":"users","pipeline":[` +
+			`{"$lookup":{"from":"ex_products","let":{"joinValue":"$_id"},"pipeline":[` +
+			`{"$match":{"$expr":{"$eq":["$owner_id","$$joinValue"]}}},{"$project":{"_id":1}},` +
+			`{"$sort_ordered":[["_id",1]]},{"$limit":1}],"as":"has_products"}},` +
+			`{"$sort_ordered":[["_id",1]]},` +
+			`{"$project":{"_id":1,"has_products":{"$gt":[{"$size":{"$ifNull":["$has_products",[]]}},0]}}}]}`
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		exp := `{"users":[{"id":1,"has_products":true},{"id":2,"has_products":false}]}`
+		if string(result) != exp {
+			t.Fatalf("expected %s, got %s", exp, result)
+		}
+	})
+
+	t.Run("field included by a condition on a sibling field", func(t *testing.T) {
+		tickets := db.Collection("fc_tickets")
+		tickets.Drop(ctx)
+		defer tickets.Drop(ctx)
+
+		if _, err := tickets.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "status": "closed", "priority": 1, "notes": "a"},
+			bson.M{"_id": 2, "status": "open", "priority": 5, "notes": "b"},
+			bson.M{"_id": 3, "status": "open", "priority": 1, "notes": "c"},
+		}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		q := `{"operation":"aggregate","collection":"fc_tickets","field_name":"tickets","pipeline":[` +
+			`{"$sort_ordered":[["_id",1]]},` +
+			`{"$project":{"_id":1,"notes":{"$cond":{"if":{"$or":[` +
+			`{"$eq":["$status",{"$literal":"closed"}]},{"$gt":["$priority",{"$literal":"$1"}]}]},` +
+			`"then":"$notes","else":null}}}}],"params":["$1"]}`
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, q, 3).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		exp := `{"tickets":[{"id":1,"notes":"a"},{"id":2,"notes":"b"},{"id":3,"notes":null}]}`
+		if string(result) != exp {
+			t.Fatalf("expected %s, got %s", exp, result)
+		}
+	})
+
+	t.Run("update returns the previous document", func(t *testing.T) {
+		products := db.Collection("rb_products")
+		products.Drop(ctx)
+		defer products.Drop(ctx)
+
+		if _, err := products.InsertOne(ctx, bson.M{"_id": 1, "name": "A", "price": 10}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		q := `{"operation":"findOneAndUpdate","collection":"rb_products","filter":{"_id":"$1"},` +
+			`"update":{"$set":{"price":"$2"}},"field_name":"products",` +
+			`"return_pipeline":[{"$project":{"_id":1,"price":1}}],"params":["$1","$2"]}`
+
+		var result []byte
+		if err := sqlDB.QueryRowContext(ctx, q, 1, 20).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if exp := `{"products":[{"id":1,"price":10}]}`; string(result) != exp {
+			t.Fatalf("expected the previous document %s, got %s", exp, result)
+		}
+
+		var doc bson.M
+		if err := products.FindOne(ctx, bson.M{"_id": 1}).Decode(&doc); err != nil {
+			t.Fatalf("FindOne failed: %v", err)
+		}
+		if doc["price"] != int32(20) && doc["price"] != int64(20) {
+			t.Fatalf("expected the document to be updated, got %v", doc)
+		}
+
+		// no document matched
+		if err := sqlDB.QueryRowContext(ctx, q, 2, 20).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if exp := `{"products":null}`; string(result) != exp {
+			t.Fatalf("expected %s, got %s", exp, result)
+		}
+	})
+
+	t.Run("graph lookup prunes the traversal with restrictSearchWithMatch", func(t *testing.T) {
+		comments := db.Collection("rs_comments")
+		comments.Drop(ctx)
+		defer comments.Drop(ctx)
+
+		// 1 <- 2 (hidden) <- 3, and 1 <- 4
+		if _, err := comments.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "hidden": false},
+			bson.M{"_id": 2, "reply_to_id": 1, "hidden": true},
+			bson.M{"_id": 3, "reply_to_id": 2, "hidden": false},
+			bson.M{"_id": 4, "reply_to_id": 1, "hidden": false},
+		}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		var result []byte
+		q := `{"operation":"aggregate","collection":"rs_comments","field_name":"comments","pipeline":[` +
+			`{"$match":{"_id":1}},` +
+			`{"$graphLookup":{"from":"rs_comments","startWith":"$_id","connectFromField":"_id","connectToField":"reply_to_id",` +
+			`"restrictSearchWithMatch":{"hidden":"$1"},"as":"replies"}},` +
+			`{"$project":{"_id":1,"replies":"$replies._id"}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q, false).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		var res map[string][]struct {
+			Replies []int `json:"replies"`
+		}
+		if err := json.Unmarshal(result, &res); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		// the hidden reply and the replies below it are not visited
+		rows := res["comments"]
+		if len(rows) != 1 || len(rows[0].Replies) != 1 || rows[0].Replies[0] != 4 {
+			t.Fatalf("Expected only the visible reply, got %s", result)
+		}
+	})
+
+	t.Run("side effects keep a denormalized count", func(t *testing.T) {
+		products := db.Collection("se_products")
+		categories := db.Collection("se_categories")
+		products.Drop(ctx)
+		categories.Drop(ctx)
+		defer products.Drop(ctx)
+		defer categories.Drop(ctx)
+
+		if _, err := categories.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "product_count": 0},
+			bson.M{"_id": 2, "product_count": 0},
+		}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		count := `{"collection":"se_categories","field":"category_id","key":"_id",` +
+			`"pipeline":[{"$group":{"_id":"$category_id","product_count":{"$sum":1}}}]}`
+		productCount := func(id int) int {
+			t.Helper()
+			var doc struct {
+				ProductCount int `bson:"product_count"`
+			}
+			if err := categories.FindOne(ctx, bson.M{"_id": id}).Decode(&doc); err != nil {
+				t.Fatalf("FindOne failed: %v", err)
+			}
+			return doc.ProductCount
+		}
+
+		var result []byte
+		q := `{"operation":"insertOne","collection":"se_products","document":{"_id":10,"name":"P","category_id":1},` +
+			`"field_name":"products","side_effects":[` + count + `]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if n := productCount(1); n != 1 {
+			t.Fatalf("Expected a product count of 1 after the insert, got %d", n)
+		}
+
+		// the previous and the new category are both recomputed
+		if _, err := products.InsertOne(ctx, bson.M{"_id": 11, "name": "Q", "category_id": 1}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+		q = `{"operation":"updateOne","collection":"se_products","filter":{"_id":10},"update":{"$set":{"category_id":2}},` +
+			`"field_name":"products","side_effects":[` + count + `]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if n1, n2 := productCount(1), productCount(2); n1 != 1 || n2 != 1 {
+			t.Fatalf("Expected a product count of 1 for both categories, got %d and %d", n1, n2)
+		}
+
+		q = `{"operation":"deleteOne","collection":"se_products","filter":{"_id":11},"field_name":"products",` +
+			`"side_effects":[{"collection":"se_categories","field":"category_id","update":{"$inc":{"product_count":-1}}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if n := productCount(1); n != 0 {
+			t.Fatalf("Expected a product count of 0 after the delete, got %d", n)
+		}
+
+		// a failed side effect is returned, the mutation is not undone
+		q = `{"operation":"updateOne","collection":"se_products","filter":{"_id":10},"update":{"$set":{"name":"R"}},` +
+			`"field_name":"products","side_effects":[{"collection":"se_categories","field":"category_id","update":{"$bogus":{"a":1}}}]}`
+		err := sqlDB.QueryRowContext(ctx, q).Scan(&result)
+		if err == nil || !strings.Contains(err.Error(), "side effect on se_categories") {
+			t.Fatalf("Expected the error of the side effect, got %v", err)
+		}
+		if n, _ := products.CountDocuments(ctx, bson.M{"_id": 10, "name": "R"}); n != 1 {
+			t.Fatal("Expected the update to be kept")
+		}
+	})
+
+	t.Run("unordered insertMany returns the errors of the failed documents", func(t *testing.T) {
+		users := db.Collection("uo_users")
+		users.Drop(ctx)
+		defer users.Drop(ctx)
+
+		if _, err := users.InsertOne(ctx, bson.M{"_id": 2, "name": "Existing"}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		var result []byte
+		q := `{"operation":"insertMany","collection":"uo_users","documents":[{"_id":1},{"_id":2},{"_id":3}],` +
+			`"options":{"ordered":false},"field_name":"users","return_pipeline":[{"$sort_ordered":[["_id",1]]},{"$project":{"_id":1}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+
+		var res struct {
+			Users  []map[string]any `json:"users"`
+			Errors []WriteError     `json:"__write_errors"`
+		}
+		if err := json.Unmarshal(result, &res); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if len(res.Users) != 2 {
+			t.Fatalf("Expected the two inserted documents, got %s", result)
+		}
+		if len(res.Errors) != 1 || res.Errors[0].Index != 1 || !strings.Contains(res.Errors[0].Message, "duplicate key") {
+			t.Fatalf("Expected the error of the second document, got %s", result)
+		}
+	})
+
+	t.Run("updateMany and deleteMany return the changed documents", func(t *testing.T) {
+		products := db.Collection("am_products")
+		products.Drop(ctx)
+		defer products.Drop(ctx)
+
+		_, err := products.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "price": 10},
+			bson.M{"_id": 2, "price": 20},
+		})
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		var result []byte
+		q := `{"operation":"updateMany","collection":"am_products","filter":{"_id":{"$ne":null}},` +
+			`"update":{"$set":{"price":5}},"field_name":"products",` +
+			`"return_pipeline":[{"$sort_ordered":[["_id",1]]},{"$project":{"_id":1,"price":1}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if exp := `{"products":[{"id":1,"price":5},{"id":2,"price":5}]}`; string(result) != exp {
+			t.Fatalf("expected %s, got %s", exp, result)
+		}
+
+		q = `{"operation":"deleteMany","collection":"am_products","filter":{"_id":{"$ne":null}},` +
+			`"field_name":"products","return_pipeline":[{"$sort_ordered":[["_id",1]]},{"$project":{"_id":1}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if exp := `{"products":[{"id":1},{"id":2}]}`; string(result) != exp {
+			t.Fatalf("expected %s, got %s", exp, result)
+		}
+		if n, _ := products.CountDocuments(ctx, bson.M{}); n != 0 {
+			t.Fatalf("expected all the documents to be deleted, %d left", n)
+		}
+
+		// an empty filter is refused
+		q = `{"operation":"deleteMany","collection":"am_products","filter":{},"field_name":"products"}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err == nil {
+			t.Fatal("expected an error for a deleteMany without a filter")
+		}
+	})
+
+	t.Run("updateMany with array filters and a collation", func(t *testing.T) {
+		orders := db.Collection("am_orders")
+		orders.Drop(ctx)
+		defer orders.Drop(ctx)
+
+		_, err := orders.InsertMany(ctx, []any{
+			bson.M{"_id": 1, "status": "open", "items": bson.A{bson.M{"sku": "X", "qty": 1}, bson.M{"sku": "Y", "qty": 1}}},
+			bson.M{"_id": 2, "status": "OPEN", "items": bson.A{bson.M{"sku": "X", "qty": 1}}},
+			bson.M{"_id": 3, "status": "closed", "items": bson.A{bson.M{"sku": "X", "qty": 1}}},
+		})
+		if err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+
+		var result []byte
+		q := `{"operation":"updateMany","collection":"am_orders","filter":{"status":"open"},` +
+			`"update":{"$set":{"items.$[e1].qty":5}},` +
+			`"options":{"arrayFilters":[{"e1.sku":"X"}],"collation":{"locale":"en","strength":2}},` +
+			`"field_name":"orders","return_pipeline":[{"$sort_ordered":[["_id",1]]},{"$project":{"_id":1,"items":1}}]}`
+		if err := sqlDB.QueryRowContext(ctx, q).Scan(&result); err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		exp := `{"orders":[{"id":1,"items":[{"qty":5,"sku":"X"},{"qty":1,"sku":"Y"}]},{"id":2,"items":[{"qty":5,"sku":"X"}]}]}`
+		if string(result) != exp {
+			t.Fatalf("expected %s, got %s", exp, result)
+		}
+	})
+
 	// Clean up
 	coll.Drop(ctx)
 }
@@ -284,6 +284,8 @@ func inferBSONType(v any) string {
 		return "long"
 	case float32, float64:
 		return "double"
+	case bson.Decimal128:
+		return "decimal"
 	case bool:
 		return "bool"
 	case bson.DateTime:
@@ -411,8 +413,10 @@ func bsonTypeToSQL(bsonType string) string {
 		return "text"
 	case "int", "long":
 		return "bigint"
-	case "double", "decimal":
+	case "double":
 		return "double precision"
+	case "decimal":
+		return "numeric" // Decimal128 is kept exact
 	case "bool":
 		return "boolean"
 	case "date":
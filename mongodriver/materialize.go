@@ -0,0 +1,195 @@
+package mongodriver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// Materialize is the collection the results of an aggregate are cached in,
+// the cached results are used until they are older than the TTL
+type Materialize struct {
+	Collection string `json:"collection"`
+	TTL        int    `json:"ttl"` // seconds
+}
+
+// materialized is a document in the cache collection, the results of
+// an aggregate are stored under a key derived from its pipeline
+type materialized struct {
+	At   time.Time `bson:"at"`
+	Rows []bson.M  `bson:"rows"`
+}
+
+// results returns the cached results, empty results are an empty list
+func (m *materialized) results() []bson.M {
+	if m.Rows == nil {
+		return []bson.M{}
+	}
+	return m.Rows
+}
+
+// materializing deduplicates the concurrent regeneration of the same results
+var materializing flightGroup
+
+// aggregate runs the pipeline, the results of a materialized aggregate are
+// read from its cache collection and regenerated once they expired
+func (c *Conn) aggregate(ctx context.Context, q *QueryDSL, pipeline bson.A) ([]bson.M, error) {
+	m := q.Materialize
+	if m == nil {
+		return c.runAggregate(ctx, q, pipeline)
+	}
+
+	key, err := materializeKey(q, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("materialize: %w", err)
+	}
+	cache := c.db.Collection(m.Collection)
+
+	if rows, ok, err := readMaterialized(ctx, cache, key, m.TTL); err != nil || ok {
+		return rows, err
+	}
+
+	fkey := c.db.Name() + "." + m.Collection + "." + key
+	return materializing.do(ctx, fkey, func() ([]bson.M, error) {
+		// the results may have been regenerated while waiting for the flight
+		if rows, ok, err := readMaterialized(ctx, cache, key, m.TTL); err != nil || ok {
+			return rows, err
+		}
+		return c.materialize(ctx, q, pipeline, cache, key)
+	})
+}
+
+// runAggregate runs the pipeline and returns all its results
+func (c *Conn) runAggregate(ctx context.Context, q *QueryDSL, pipeline bson.A) ([]bson.M, error) {
+	cursor, err := c.db.Collection(q.Collection).Aggregate(ctx, pipeline, aggregateOptions(q))
+	if err != nil {
+		return nil, aggregateErr(err)
+	}
+	defer cursor.Close(ctx) //nolint:errcheck
+
+	var results []bson.M
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("results: %w", err)
+	}
+	return results, nil
+}
+
+// materialize runs the pipeline with a final $merge into the cache collection
+// and reads the results back. $facet collects the results into a single
+// document even when nothing matched so empty results are cached too.
+func (c *Conn) materialize(ctx context.Context, q *QueryDSL, pipeline bson.A,
+	cache *mongo.Collection, key string,
+) ([]bson.M, error) {
+	stages := make(bson.A, 0, len(pipeline)+3)
+	stages = append(stages, pipeline...)
+	stages = append(stages,
+		bson.M{"$facet": bson.M{"rows": bson.A{bson.M{"$match": bson.M{}}}}},
+		bson.M{"$set": bson.M{"_id": key, "at": time.Now().UTC()}},
+		bson.M{"$merge": bson.M{
+			"into":           q.Materialize.Collection,
+			"on":             "_id",
+			"whenMatched":    "replace",
+			"whenNotMatched": "insert",
+		}},
+	)
+
+	cursor, err := c.db.Collection(q.Collection).Aggregate(ctx, stages, aggregateOptions(q))
+	if err != nil {
+		return nil, fmt.Errorf("materialize: %w", aggregateErr(err))
+	}
+	cursor.Close(ctx) //nolint:errcheck
+
+	var doc materialized
+	if err := cache.FindOne(ctx, bson.M{"_id": key}).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("materialize: %w", err)
+	}
+	return doc.results(), nil
+}
+
+// readMaterialized returns the cached results when they are not older than the TTL
+func readMaterialized(ctx context.Context, cache *mongo.Collection, key string, ttl int) ([]bson.M, bool, error) {
+	since := time.Now().Add(-time.Duration(ttl) * time.Second)
+
+	var doc materialized
+	err := cache.FindOne(ctx, bson.M{"_id": key, "at": bson.M{"$gt": since}}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("materialize: %w", err)
+	}
+	return doc.results(), true, nil
+}
+
+// materializeKey returns the key of the cached results, variables and the
+// filters of the role are part of the pipeline so each gets its own results
+func materializeKey(q *QueryDSL, pipeline bson.A) (string, error) {
+	b, err := json.Marshal([]any{q.Collection, q.Options, pipeline})
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// flightGroup runs a function once for all concurrent callers with the same key
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	dups int // callers waiting for the results
+	rows []bson.M
+	err  error
+}
+
+// do runs the function unless it is already running for the key, then it
+// waits for its results. Every caller gets its own copy of the results.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() ([]bson.M, error)) ([]bson.M, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flight)
+	}
+	if f, ok := g.calls[key]; ok {
+		f.dups++
+		g.mu.Unlock()
+		select {
+		case <-f.done:
+			return copyRows(f.rows), f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f := &flight{done: make(chan struct{})}
+	g.calls[key] = f
+	g.mu.Unlock()
+
+	f.rows, f.err = fn()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(f.done)
+
+	return copyRows(f.rows), f.err
+}
+
+// copyRows returns a copy of the list of results, the results are not copied
+func copyRows(rows []bson.M) []bson.M {
+	if rows == nil {
+		return nil
+	}
+	r := make([]bson.M, len(rows))
+	copy(r, rows)
+	return r
+}
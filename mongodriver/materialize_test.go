@@ -0,0 +1,92 @@
+package mongodriver
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestMaterializeFlightDedup(t *testing.T) {
+	var g flightGroup
+	var runs int32
+	release := make(chan struct{})
+
+	fn := func() ([]bson.M, error) {
+		atomic.AddInt32(&runs, 1)
+		<-release
+		return []bson.M{{"_id": 1}}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]bson.M, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rows, err := g.do(context.Background(), "key", fn)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = rows
+		}(i)
+	}
+
+	// wait for all callers to join the flight before it completes
+	for {
+		g.mu.Lock()
+		f := g.calls["key"]
+		joined := f != nil && f.dups == len(results)-1
+		g.mu.Unlock()
+		if joined {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&runs); n != 1 {
+		t.Fatalf("expected the results to be regenerated once, got %d", n)
+	}
+	for _, rows := range results {
+		if len(rows) != 1 {
+			t.Fatalf("expected every caller to get the results, got %v", rows)
+		}
+	}
+
+	// the flight is done so the next call runs again
+	if _, err := g.do(context.Background(), "key", func() ([]bson.M, error) {
+		atomic.AddInt32(&runs, 1)
+		return nil, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&runs); n != 2 {
+		t.Fatalf("expected a second run, got %d", n)
+	}
+}
+
+func TestMaterializeKey(t *testing.T) {
+	q := &QueryDSL{Collection: "orders"}
+	pipeline := func(status string) bson.A {
+		return bson.A{bson.M{"$match": bson.M{"status": status}}, bson.M{"$limit": 20}}
+	}
+
+	k1, err := materializeKey(q, pipeline("paid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, _ := materializeKey(q, pipeline("paid"))
+	k3, _ := materializeKey(q, pipeline("open"))
+
+	if k1 != k2 {
+		t.Fatalf("expected the same pipeline to have the same key: %s != %s", k1, k2)
+	}
+	if k1 == k3 {
+		t.Fatal("expected a pipeline with other values to have another key")
+	}
+}
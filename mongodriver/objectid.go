@@ -0,0 +1,152 @@
+package mongodriver
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ConvertObjectIDs converts the values in the {"$oid": ...} markers the
+// dialect renders for ObjectId columns to ObjectIds, a marker holds a hex
+// string or an array of them. The fields listed in ObjectIDs are converted
+// in the documents read from a variable. It's called after the parameters
+// are substituted.
+func (q *QueryDSL) ConvertObjectIDs() error {
+	var err error
+
+	for i := range q.Pipeline {
+		if q.Pipeline[i], err = convertOIDsInMap(q.Pipeline[i]); err != nil {
+			return err
+		}
+	}
+	for i := range q.ReturnPipeline {
+		if q.ReturnPipeline[i], err = convertOIDsInMap(q.ReturnPipeline[i]); err != nil {
+			return err
+		}
+	}
+	for i := range q.Documents {
+		if q.Documents[i], err = convertOIDsInMap(q.Documents[i]); err != nil {
+			return err
+		}
+		if err = convertOIDFields(q.Documents[i], q.ObjectIDs); err != nil {
+			return err
+		}
+	}
+	for _, m := range []*map[string]any{&q.Filter, &q.Document, &q.Update, &q.Options, &q.Presets, &q.ConflictPresets, &q.FKValues} {
+		if *m, err = convertOIDsInMap(*m); err != nil {
+			return err
+		}
+	}
+	if err = convertOIDFields(q.Document, q.ObjectIDs); err != nil {
+		return err
+	}
+
+	for i := range q.Inserts {
+		if q.Inserts[i].Document, err = convertOIDsInMap(q.Inserts[i].Document); err != nil {
+			return err
+		}
+	}
+	for i := range q.Updates {
+		if q.Updates[i].Filter, err = convertOIDsInMap(q.Updates[i].Filter); err != nil {
+			return err
+		}
+		if q.Updates[i].Update, err = convertOIDsInMap(q.Updates[i].Update); err != nil {
+			return err
+		}
+	}
+
+	for _, subQ := range q.Queries {
+		if err := subQ.ConvertObjectIDs(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertOIDFields converts the values of the ObjectId fields of a document,
+// the _id field is also looked up as id
+func convertOIDFields(doc map[string]any, fields []string) error {
+	if doc == nil {
+		return nil
+	}
+	for _, f := range fields {
+		keys := []string{f}
+		if f == "_id" {
+			keys = append(keys, "id")
+		}
+		for _, k := range keys {
+			v, ok := doc[k]
+			if !ok {
+				continue
+			}
+			oid, err := toObjectID(v)
+			if err != nil {
+				return err
+			}
+			doc[k] = oid
+		}
+	}
+	return nil
+}
+
+// convertOIDsInMap replaces the $oid markers in a map
+func convertOIDsInMap(m map[string]any) (map[string]any, error) {
+	if m == nil {
+		return nil, nil
+	}
+	for k, v := range m {
+		nv, err := convertOIDs(v)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = nv
+	}
+	return m, nil
+}
+
+// convertOIDs replaces the $oid markers in a value
+func convertOIDs(v any) (any, error) {
+	switch val := v.(type) {
+	case map[string]any:
+		if oid, ok := val["$oid"]; ok && len(val) == 1 {
+			return toObjectID(oid)
+		}
+		return convertOIDsInMap(val)
+	case []any:
+		for i := range val {
+			nv, err := convertOIDs(val[i])
+			if err != nil {
+				return nil, err
+			}
+			val[i] = nv
+		}
+		return val, nil
+	default:
+		return v, nil
+	}
+}
+
+// toObjectID converts a hex string or an array of them to ObjectIds, other
+// values like null are returned as is
+func toObjectID(v any) (any, error) {
+	switch val := v.(type) {
+	case string:
+		oid, err := bson.ObjectIDFromHex(val)
+		if err != nil {
+			return nil, fmt.Errorf("mongodriver: invalid ObjectId '%s': %w", val, err)
+		}
+		return oid, nil
+	case []any:
+		ids := make([]any, len(val))
+		for i := range val {
+			id, err := toObjectID(val[i])
+			if err != nil {
+				return nil, err
+			}
+			ids[i] = id
+		}
+		return ids, nil
+	default:
+		return v, nil
+	}
+}
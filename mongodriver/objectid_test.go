@@ -0,0 +1,75 @@
+package mongodriver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestConvertObjectIDs(t *testing.T) {
+	id1, id2 := bson.NewObjectID(), bson.NewObjectID()
+
+	t.Run("filter", func(t *testing.T) {
+		q, err := ParseQuery(`{"operation":"aggregate","collection":"users","pipeline":[
+			{"$match":{"$and":[{"_id":{"$oid":"$1"}},{"owner_id":{"$in":{"$oid":"$2"}}},{"parent_id":{"$oid":null}}]}}]}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := json.RawMessage(`["` + id1.Hex() + `","` + id2.Hex() + `"]`)
+		if err := q.SubstituteParams([]any{id1.Hex(), ids}); err != nil {
+			t.Fatal(err)
+		}
+		if err := q.ConvertObjectIDs(); err != nil {
+			t.Fatal(err)
+		}
+		and := q.Pipeline[0]["$match"].(map[string]any)["$and"].([]any)
+		if v := and[0].(map[string]any)["_id"]; v != id1 {
+			t.Fatalf("expected the ObjectId %s, got: %#v", id1.Hex(), v)
+		}
+		in := and[1].(map[string]any)["owner_id"].(map[string]any)["$in"].([]any)
+		if len(in) != 2 || in[0] != id1 || in[1] != id2 {
+			t.Fatalf("expected the ObjectIds %s and %s, got: %#v", id1.Hex(), id2.Hex(), in)
+		}
+		if v := and[2].(map[string]any)["parent_id"]; v != nil {
+			t.Fatalf("expected null, got: %#v", v)
+		}
+	})
+
+	t.Run("raw document with connect", func(t *testing.T) {
+		q, err := ParseQuery(`{"operation":"insertOne","collection":"products","raw_document":"$1",
+			"fk_connect":{"path":"owner","column":"owner_id"},"object_ids":["_id","owner_id"]}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc := json.RawMessage(`{"id":"` + id1.Hex() + `","name":"Apple","owner":{"connect":{"id":"` + id2.Hex() + `"}}}`)
+		if err := q.SubstituteParams([]any{doc}); err != nil {
+			t.Fatal(err)
+		}
+		if err := q.ConvertObjectIDs(); err != nil {
+			t.Fatal(err)
+		}
+		if v := q.Document["id"]; v != id1 {
+			t.Fatalf("expected the ObjectId %s, got: %#v", id1.Hex(), v)
+		}
+		if v := q.Document["owner_id"]; v != id2 {
+			t.Fatalf("expected the ObjectId %s, got: %#v", id2.Hex(), v)
+		}
+		if v := q.Document["name"]; v != "Apple" {
+			t.Fatalf("expected the name to be unchanged, got: %#v", v)
+		}
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		q, err := ParseQuery(`{"operation":"findOne","collection":"users","filter":{"_id":{"$oid":"$1"}}}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := q.SubstituteParams([]any{"not-an-id"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := q.ConvertObjectIDs(); err == nil {
+			t.Fatal("expected an error for the invalid ObjectId")
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -51,6 +52,17 @@ func translateFieldsInValue(v any) any {
 	}
 }
 
+// translateDocumentFields translates the field names of a document to be
+// inserted. Only the fields of the document itself are translated, the id
+// keys of its sub-documents are data and are kept as is.
+func translateDocumentFields(doc map[string]any) map[string]any {
+	result := make(map[string]any, len(doc))
+	for k, v := range doc {
+		result[translateFieldName(k)] = v
+	}
+	return result
+}
+
 // translateIDFieldsBack converts MongoDB _id fields back to id for GraphQL response.
 func translateIDFieldsBack(m bson.M) bson.M {
 	result := make(bson.M)
@@ -227,8 +239,6 @@ func (c *Conn) executeAggregate(ctx context.Context, q *QueryDSL) (driver.Rows,
 		return nil, fmt.Errorf("mongodriver: aggregate requires collection")
 	}
 
-	coll := c.db.Collection(q.Collection)
-
 	// Convert pipeline to bson.A, translating field names (id -> _id)
 	// and converting $sort_ordered to proper ordered $sort stages
 	pipeline := make(bson.A, len(q.Pipeline))
@@ -237,18 +247,21 @@ func (c *Conn) executeAggregate(ctx context.Context, q *QueryDSL) (driver.Rows,
 		pipeline[i] = convertSortOrderedToSort(translated)
 	}
 
-	cursor, err := coll.Aggregate(ctx, pipeline)
+	var pageSize int64 = -1
+	if q.Connection {
+		pageSize = extendLimit(pipeline)
+	}
+
+	// Collect all results into a JSON array
+	results, err := c.aggregate(ctx, q, pipeline)
 	if err != nil {
 		return nil, fmt.Errorf("mongodriver: aggregate: %w", err)
 	}
 
-	// Collect all results into a JSON array
-	var results []bson.M
-	if err := cursor.All(ctx, &results); err != nil {
-		cursor.Close(ctx)
-		return nil, fmt.Errorf("mongodriver: aggregate results: %w", err)
+	var pi map[string]any
+	if q.Connection {
+		results, pi = connectionPage(q, results, pageSize)
 	}
-	cursor.Close(ctx)
 
 	// Extract cursor value before transforming results
 	var cursorValue string
@@ -259,18 +272,14 @@ func (c *Conn) executeAggregate(ctx context.Context, q *QueryDSL) (driver.Rows,
 
 	// Transform _id to id and remove __cursor_ prefixed fields
 	for i := range results {
-		results[i] = translateIDFieldsBack(results[i])
-		// Remove cursor helper fields from result
-		for key := range results[i] {
-			if strings.HasPrefix(key, "__cursor_") {
-				delete(results[i], key)
-			}
-		}
+		results[i] = dropCursorFields(translateIDFieldsBack(results[i]))
 	}
 
 	// Wrap results in field name and handle singular vs plural
 	finalResult := make(map[string]any)
-	if q.Singular {
+	if q.Count {
+		finalResult[q.FieldName] = countResult(q, results)
+	} else if q.Singular {
 		// For singular queries, return first result or null
 		if len(results) > 0 {
 			finalResult[q.FieldName] = results[0]
@@ -286,6 +295,9 @@ func (c *Conn) executeAggregate(ctx context.Context, q *QueryDSL) (driver.Rows,
 	if cursorValue != "" {
 		finalResult[q.FieldName+"_cursor"] = cursorValue
 	}
+	if pi != nil {
+		finalResult[q.FieldName+"_pageInfo"] = pi
+	}
 
 	jsonBytes, err := json.Marshal(finalResult)
 	if err != nil {
@@ -295,6 +307,36 @@ func (c *Conn) executeAggregate(ctx context.Context, q *QueryDSL) (driver.Rows,
 	return NewSingleValueRows(jsonBytes, []string{"__root"}), nil
 }
 
+// dropCursorFields removes the __cursor_ fields the order-by columns are
+// projected into for the cursor, they are read before the results are returned
+func dropCursorFields(doc bson.M) bson.M {
+	for key := range doc {
+		if strings.HasPrefix(key, "__cursor_") {
+			delete(doc, key)
+		}
+	}
+	return doc
+}
+
+// countResult returns the result of a pipeline that ends in a $count stage,
+// $count returns no document when nothing matched so the count is then 0
+func countResult(q *QueryDSL, results []bson.M) any {
+	var n any = 0
+	if len(results) > 0 {
+		if v, ok := results[0]["total"]; ok {
+			n = v
+		}
+	}
+	if q.CountField == "" {
+		return n
+	}
+	row := map[string]any{q.CountField: n}
+	if q.Singular {
+		return row
+	}
+	return []map[string]any{row}
+}
+
 // buildCursorValue builds a cursor string from the last document's order-by values.
 // Format: prefix + hex(selID) + ":" + value1 + ":" + value2 + ...
 func buildCursorValue(info *CursorInfo, lastDoc bson.M) string {
@@ -389,8 +431,6 @@ func (c *Conn) executeMultiAggregate(ctx context.Context, q *QueryDSL) (driver.R
 			return nil, fmt.Errorf("mongodriver: aggregate requires collection")
 		}
 
-		coll := c.db.Collection(subQ.Collection)
-
 		// Convert pipeline to bson.A, translating field names (id -> _id)
 		// and converting $sort_ordered to proper ordered $sort stages
 		pipeline := make(bson.A, len(subQ.Pipeline))
@@ -399,19 +439,12 @@ func (c *Conn) executeMultiAggregate(ctx context.Context, q *QueryDSL) (driver.R
 			pipeline[i] = convertSortOrderedToSort(translated)
 		}
 
-		cursor, err := coll.Aggregate(ctx, pipeline)
+		// Collect all results
+		results, err := c.aggregate(ctx, subQ, pipeline)
 		if err != nil {
 			return nil, fmt.Errorf("mongodriver: aggregate on %s: %w", subQ.Collection, err)
 		}
 
-		// Collect all results
-		var results []bson.M
-		if err := cursor.All(ctx, &results); err != nil {
-			cursor.Close(ctx)
-			return nil, fmt.Errorf("mongodriver: aggregate results on %s: %w", subQ.Collection, err)
-		}
-		cursor.Close(ctx)
-
 		// Extract cursor value before transforming results
 		var cursorValue string
 		if subQ.CursorInfo != nil && len(results) > 0 {
@@ -421,13 +454,7 @@ func (c *Conn) executeMultiAggregate(ctx context.Context, q *QueryDSL) (driver.R
 
 		// Transform _id to id and remove __cursor_ prefixed fields
 		for i := range results {
-			results[i] = translateIDFieldsBack(results[i])
-			// Remove cursor helper fields from result
-			for key := range results[i] {
-				if strings.HasPrefix(key, "__cursor_") {
-					delete(results[i], key)
-				}
-			}
+			results[i] = dropCursorFields(translateIDFieldsBack(results[i]))
 			// Add __typename field if requested
 			if subQ.Typename != "" {
 				results[i]["__typename"] = subQ.Typename
@@ -435,7 +462,9 @@ func (c *Conn) executeMultiAggregate(ctx context.Context, q *QueryDSL) (driver.R
 		}
 
 		// Add to final result under the field name
-		if subQ.Singular {
+		if subQ.Count {
+			finalResult[subQ.FieldName] = countResult(subQ, results)
+		} else if subQ.Singular {
 			if len(results) > 0 {
 				finalResult[subQ.FieldName] = results[0]
 			} else {
@@ -502,6 +531,10 @@ func (c *Conn) executeMultiMutationAsQuery(ctx context.Context, q *QueryDSL) (dr
 			err  error
 		)
 
+		if err := checkSideEffects(subQ); err != nil {
+			return nil, err
+		}
+
 		switch subQ.Operation {
 		case OpInsertOne:
 			rows, err = c.executeInsertOneAsQuery(ctx, subQ)
@@ -509,8 +542,14 @@ func (c *Conn) executeMultiMutationAsQuery(ctx context.Context, q *QueryDSL) (dr
 			rows, err = c.executeInsertManyAsQuery(ctx, subQ)
 		case OpUpdateOne:
 			rows, err = c.executeUpdateOneAsQuery(ctx, subQ)
+		case OpUpdateMany:
+			rows, err = c.executeUpdateManyAsQuery(ctx, subQ)
+		case OpFindOneAndUpdate:
+			rows, err = c.executeFindOneAndUpdateAsQuery(ctx, subQ)
 		case OpDeleteOne:
 			rows, err = c.executeDeleteOneAsQuery(ctx, subQ)
+		case OpDeleteMany:
+			rows, err = c.executeDeleteManyAsQuery(ctx, subQ)
 		case OpNestedInsert:
 			rows, err = c.executeNestedInsert(ctx, subQ)
 		case OpNestedUpdate:
@@ -658,11 +697,16 @@ func (c *Conn) executeInsertOne(ctx context.Context, q *QueryDSL) (driver.Result
 		return nil, fmt.Errorf("mongodriver: insertOne requires document")
 	}
 
+	doc := translateDocumentFields(q.Document)
+
 	coll := c.db.Collection(q.Collection)
-	result, err := coll.InsertOne(ctx, q.Document)
+	result, err := coll.InsertOne(ctx, doc)
 	if err != nil {
 		return nil, fmt.Errorf("mongodriver: insertOne: %w", err)
 	}
+	if err := c.runSideEffects(ctx, q, doc, nil); err != nil {
+		return nil, err
+	}
 
 	return &Result{
 		lastInsertID: fmt.Sprintf("%v", result.InsertedID),
@@ -681,7 +725,7 @@ func (c *Conn) executeInsertOneAsQuery(ctx context.Context, q *QueryDSL) (driver
 	}
 
 	// Translate field names in document (id -> _id)
-	doc := translateFieldsInMap(q.Document)
+	doc := translateDocumentFields(q.Document)
 
 	// Merge presets into document (presets override user-provided values)
 	if q.Presets != nil {
@@ -695,6 +739,9 @@ func (c *Conn) executeInsertOneAsQuery(ctx context.Context, q *QueryDSL) (driver
 	if err != nil {
 		return nil, fmt.Errorf("mongodriver: insertOne: %w", err)
 	}
+	if err := c.runSideEffects(ctx, q, doc, nil); err != nil {
+		return nil, err
+	}
 
 	var finalDoc bson.M
 
@@ -772,7 +819,7 @@ func (c *Conn) executeInsertMany(ctx context.Context, q *QueryDSL) (driver.Resul
 		// Translate id -> _id for each document
 		docs = make([]any, len(q.Documents))
 		for i, doc := range q.Documents {
-			docs[i] = translateFieldsInMap(doc)
+			docs[i] = translateDocumentFields(doc)
 		}
 	} else if optDocs, ok := q.Options["documents"].([]any); ok && len(optDocs) > 0 {
 		docs = optDocs
@@ -781,16 +828,74 @@ func (c *Conn) executeInsertMany(ctx context.Context, q *QueryDSL) (driver.Resul
 	}
 
 	coll := c.db.Collection(q.Collection)
-	result, err := coll.InsertMany(ctx, docs)
+	insertedIDs, _, err := insertMany(ctx, coll, docs, isOrdered(q))
 	if err != nil {
-		return nil, fmt.Errorf("mongodriver: insertMany: %w", err)
+		return nil, err
 	}
 
 	return &Result{
-		rowsAffected: int64(len(result.InsertedIDs)),
+		rowsAffected: int64(len(insertedIDs)),
 	}, nil
 }
 
+// isOrdered reports whether an insertMany should stop at the first failed
+// document. Inserts are ordered unless options.ordered is set to false.
+func isOrdered(q *QueryDSL) bool {
+	if v, ok := q.Options["ordered"].(bool); ok {
+		return v
+	}
+	return true
+}
+
+// WriteErrorsField is the field of the result of an insertMany holding the
+// documents that failed to insert
+const WriteErrorsField = "__write_errors"
+
+// WriteError is the error of a document that failed to insert in an
+// unordered insertMany, Index is its position in the documents
+type WriteError struct {
+	Index   int    `json:"index"`
+	Message string `json:"message"`
+}
+
+// insertMany inserts the documents and returns the IDs of the documents that
+// were inserted. Unordered inserts continue past failed documents, the IDs
+// of the successful inserts are returned with the errors of the failed ones.
+func insertMany(ctx context.Context, coll *mongo.Collection, docs []any, ordered bool) ([]any, []WriteError, error) {
+	opts := options.InsertMany().SetOrdered(ordered)
+
+	result, err := coll.InsertMany(ctx, docs, opts)
+	if err == nil {
+		return result.InsertedIDs, nil, nil
+	}
+
+	var bwe mongo.BulkWriteException
+	if ordered || result == nil || !errors.As(err, &bwe) || bwe.WriteConcernError != nil {
+		return nil, nil, fmt.Errorf("mongodriver: insertMany: %w", err)
+	}
+
+	failed := make(map[int]struct{}, len(bwe.WriteErrors))
+	werrs := make([]WriteError, 0, len(bwe.WriteErrors))
+	for _, we := range bwe.WriteErrors {
+		failed[we.Index] = struct{}{}
+		werrs = append(werrs, WriteError{Index: we.Index, Message: we.Message})
+	}
+	return filterInsertedIDs(result.InsertedIDs, failed), werrs, nil
+}
+
+// filterInsertedIDs removes the IDs at the failed indexes. The driver reports
+// an ID for every document sent, including the ones that failed to insert.
+func filterInsertedIDs(ids []any, failed map[int]struct{}) []any {
+	inserted := make([]any, 0, len(ids))
+	for i, id := range ids {
+		if _, ok := failed[i]; ok {
+			continue
+		}
+		inserted = append(inserted, id)
+	}
+	return inserted
+}
+
 // executeInsertManyAsQuery inserts multiple documents and returns them as query results.
 // This is used when GraphQL mutations need to return the inserted data.
 func (c *Conn) executeInsertManyAsQuery(ctx context.Context, q *QueryDSL) (driver.Rows, error) {
@@ -804,7 +909,7 @@ func (c *Conn) executeInsertManyAsQuery(ctx context.Context, q *QueryDSL) (drive
 		// Translate id -> _id for each document
 		docs = make([]any, len(q.Documents))
 		for i, doc := range q.Documents {
-			docs[i] = translateFieldsInMap(doc)
+			docs[i] = translateDocumentFields(doc)
 		}
 	} else if optDocs, ok := q.Options["documents"].([]any); ok && len(optDocs) > 0 {
 		docs = optDocs
@@ -813,9 +918,9 @@ func (c *Conn) executeInsertManyAsQuery(ctx context.Context, q *QueryDSL) (drive
 	}
 
 	coll := c.db.Collection(q.Collection)
-	result, err := coll.InsertMany(ctx, docs)
+	insertedIDs, werrs, err := insertMany(ctx, coll, docs, isOrdered(q))
 	if err != nil {
-		return nil, fmt.Errorf("mongodriver: insertMany: %w", err)
+		return nil, err
 	}
 
 	// Fetch all inserted documents
@@ -825,7 +930,7 @@ func (c *Conn) executeInsertManyAsQuery(ctx context.Context, q *QueryDSL) (drive
 		pipeline := make(bson.A, 0, len(q.ReturnPipeline)+1)
 
 		// First stage: match all inserted documents
-		pipeline = append(pipeline, bson.M{"$match": bson.M{"_id": bson.M{"$in": result.InsertedIDs}}})
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"_id": bson.M{"$in": insertedIDs}}})
 
 		// Add return pipeline stages, converting $sort_ordered to $sort
 		for _, stage := range q.ReturnPipeline {
@@ -845,7 +950,7 @@ func (c *Conn) executeInsertManyAsQuery(ctx context.Context, q *QueryDSL) (drive
 		cursor.Close(ctx)
 	} else {
 		// No return_pipeline, just fetch the inserted documents
-		cursor, err := coll.Find(ctx, bson.M{"_id": bson.M{"$in": result.InsertedIDs}})
+		cursor, err := coll.Find(ctx, bson.M{"_id": bson.M{"$in": insertedIDs}})
 		if err != nil {
 			return nil, fmt.Errorf("mongodriver: find after insertMany: %w", err)
 		}
@@ -863,10 +968,15 @@ func (c *Conn) executeInsertManyAsQuery(ctx context.Context, q *QueryDSL) (drive
 		resultDocs[i] = translateIDFieldsBack(doc)
 	}
 
-	// Wrap result in field name if provided
+	// Wrap result in field name if provided, the documents that failed to
+	// insert are returned next to it
 	var finalResult any
 	if q.FieldName != "" {
-		finalResult = map[string]any{q.FieldName: resultDocs}
+		res := map[string]any{q.FieldName: resultDocs}
+		if len(werrs) != 0 {
+			res[WriteErrorsField] = werrs
+		}
+		finalResult = res
 	} else {
 		finalResult = resultDocs
 	}
@@ -900,18 +1010,37 @@ func (c *Conn) executeUpdateOneAsQuery(ctx context.Context, q *QueryDSL) (driver
 
 	coll := c.db.Collection(q.Collection)
 
-	updateOpts := options.UpdateOne()
-	if q.Options != nil {
-		if upsert, ok := q.Options["upsert"].(bool); ok && upsert {
-			updateOpts.SetUpsert(true)
-		}
+	updateOpts := updateOneOptions(q)
+
+	prev, err := sideEffectDoc(ctx, coll, q, filter)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err := coll.UpdateOne(ctx, filter, update, updateOpts)
+	result, err := coll.UpdateOne(ctx, filter, update, updateOpts)
 	if err != nil {
 		return nil, fmt.Errorf("mongodriver: updateOne: %w", err)
 	}
 
+	if q.VersionField != "" {
+		// The document was changed since it was read
+		if result.MatchedCount == 0 {
+			return updateConflictRows(q)
+		}
+		// The version was incremented by the update
+		delete(filter, q.VersionField)
+	}
+
+	if err := c.runUpdateSideEffects(ctx, coll, q, prev); err != nil {
+		return nil, err
+	}
+
+	// An upsert that inserted a document returns the inserted one, else the
+	// matching document is returned whether it was changed or not
+	if result.UpsertedID != nil {
+		filter = bson.M{"_id": result.UpsertedID}
+	}
+
 	// Fetch the updated document
 	var finalDoc bson.M
 
@@ -985,6 +1114,112 @@ func (c *Conn) executeUpdateOneAsQuery(ctx context.Context, q *QueryDSL) (driver
 	return NewSingleValueRows(jsonBytes, []string{"__root"}), nil
 }
 
+// executeFindOneAndUpdateAsQuery updates a document and returns it as it was
+// before the update, the return pipeline is run on the previous document so
+// the returned fields match the selection
+func (c *Conn) executeFindOneAndUpdateAsQuery(ctx context.Context, q *QueryDSL) (driver.Rows, error) {
+	if q.Collection == "" {
+		return nil, fmt.Errorf("mongodriver: findOneAndUpdate requires collection")
+	}
+
+	filter := bson.M{}
+	if q.Filter != nil {
+		filter = translateFieldsInMap(q.Filter)
+	}
+
+	update := bson.M{}
+	if q.Update != nil {
+		update = translateFieldsInMap(q.Update)
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	if q.Options != nil {
+		if af, ok := q.Options["arrayFilters"].([]any); ok && len(af) != 0 {
+			opts.SetArrayFilters(af)
+		}
+	}
+
+	coll := c.db.Collection(q.Collection)
+
+	var prevDoc bson.M
+	err := coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&prevDoc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// No document matched the filter or the expected version
+		return updateConflictRows(q)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mongodriver: findOneAndUpdate: %w", err)
+	}
+	if err := c.runUpdateSideEffects(ctx, coll, q, prevDoc); err != nil {
+		return nil, err
+	}
+
+	if len(q.ReturnPipeline) > 0 {
+		pipeline := make(bson.A, 0, len(q.ReturnPipeline)+1)
+		pipeline = append(pipeline, bson.M{"$documents": bson.A{prevDoc}})
+		for _, stage := range q.ReturnPipeline {
+			translated := translateFieldsInMap(stage)
+			pipeline = append(pipeline, convertSortOrderedToSort(translated))
+		}
+
+		cursor, err := c.db.Aggregate(ctx, pipeline)
+		if err != nil {
+			return nil, aggregateErr(fmt.Errorf("mongodriver: aggregate after findOneAndUpdate: %w", err))
+		}
+
+		var results []bson.M
+		if err := cursor.All(ctx, &results); err != nil {
+			cursor.Close(ctx)
+			return nil, fmt.Errorf("mongodriver: aggregate results: %w", err)
+		}
+		cursor.Close(ctx)
+
+		if len(results) > 0 {
+			prevDoc = results[0]
+		}
+	}
+
+	// Translate _id back to id
+	prevDoc = translateIDFieldsBack(prevDoc)
+
+	// Wrap result in field name if provided
+	var finalResult any
+	if q.FieldName != "" {
+		if q.Singular {
+			finalResult = map[string]any{q.FieldName: prevDoc}
+		} else {
+			finalResult = map[string]any{q.FieldName: []any{prevDoc}}
+		}
+	} else {
+		if q.Singular {
+			finalResult = prevDoc
+		} else {
+			finalResult = []any{prevDoc}
+		}
+	}
+
+	jsonBytes, err := json.Marshal(finalResult)
+	if err != nil {
+		return nil, fmt.Errorf("mongodriver: marshal findOneAndUpdate result: %w", err)
+	}
+
+	return NewSingleValueRows(jsonBytes, []string{"__root"}), nil
+}
+
+// updateConflictRows returns a null result for an update that did not match
+// a document or the expected version of it
+func updateConflictRows(q *QueryDSL) (driver.Rows, error) {
+	jsonBytes := []byte(`null`)
+	if q.FieldName != "" {
+		b, err := json.Marshal(map[string]any{q.FieldName: nil})
+		if err != nil {
+			return nil, fmt.Errorf("mongodriver: marshal update result: %w", err)
+		}
+		jsonBytes = b
+	}
+	return NewSingleValueRows(jsonBytes, []string{"__root"}), nil
+}
+
 // executeNestedInsert handles inserting documents into multiple related collections.
 // It executes inserts in topological order based on dependencies and links FK values.
 func (c *Conn) executeNestedInsert(ctx context.Context, q *QueryDSL) (driver.Rows, error) {
@@ -1043,7 +1278,7 @@ func (c *Conn) executeNestedInsert(ctx context.Context, q *QueryDSL) (driver.Row
 			continue
 		}
 
-		doc := translateFieldsInMap(ins.Document)
+		doc := translateDocumentFields(ins.Document)
 
 		// For root document, apply FK values (direct FK values from connect operations)
 		if ins.ID == q.RootMutateID && len(q.FKValues) > 0 {
@@ -1481,6 +1716,91 @@ func (c *Conn) executeNestedUpdate(ctx context.Context, q *QueryDSL) (driver.Row
 	return NewSingleValueRows(jsonBytes, []string{"__root"}), nil
 }
 
+// aggregateOptions returns the aggregate options set in the query,
+// a collation sorts and matches strings using the rules of a locale.
+func aggregateOptions(q *QueryDSL) *options.AggregateOptionsBuilder {
+	aggOpts := options.Aggregate()
+	if q.Comment != "" {
+		aggOpts.SetComment(q.Comment)
+	}
+	if q.Options == nil {
+		return aggOpts
+	}
+	if c, ok := q.Options["collation"].(map[string]any); ok {
+		aggOpts.SetCollation(parseCollation(c))
+	}
+	return aggOpts
+}
+
+// aggregateErr explains the error returned when a static lookup table is used
+// on a server without support for the $documents stage.
+func aggregateErr(err error) error {
+	if strings.Contains(err.Error(), "$documents") {
+		return fmt.Errorf("static lookup tables require MongoDB 5.1 or later: %w", err)
+	}
+	return err
+}
+
+// parseCollation converts a collation document to the driver collation
+func parseCollation(c map[string]any) *options.Collation {
+	col := &options.Collation{}
+	if v, ok := c["locale"].(string); ok {
+		col.Locale = v
+	}
+	if v, ok := c["strength"].(float64); ok {
+		col.Strength = int(v)
+	}
+	if v, ok := c["caseLevel"].(bool); ok {
+		col.CaseLevel = v
+	}
+	if v, ok := c["numericOrdering"].(bool); ok {
+		col.NumericOrdering = v
+	}
+	return col
+}
+
+// updateOneOptions returns the updateOne options set in the query,
+// arrayFilters select the embedded array elements to update.
+func updateOneOptions(q *QueryDSL) *options.UpdateOneOptionsBuilder {
+	updateOpts := options.UpdateOne()
+	if q.Options == nil {
+		return updateOpts
+	}
+	if upsert, ok := q.Options["upsert"].(bool); ok && upsert {
+		updateOpts.SetUpsert(true)
+	}
+	if af, ok := q.Options["arrayFilters"].([]any); ok && len(af) != 0 {
+		updateOpts.SetArrayFilters(af)
+	}
+	return updateOpts
+}
+
+// updateManyOptions returns the updateMany options set in the query,
+// arrayFilters select the embedded array elements to update and the
+// collation matches strings using the rules of a locale.
+func updateManyOptions(q *QueryDSL) *options.UpdateManyOptionsBuilder {
+	updateOpts := options.UpdateMany()
+	if q.Options == nil {
+		return updateOpts
+	}
+	if af, ok := q.Options["arrayFilters"].([]any); ok && len(af) != 0 {
+		updateOpts.SetArrayFilters(af)
+	}
+	if c, ok := q.Options["collation"].(map[string]any); ok {
+		updateOpts.SetCollation(parseCollation(c))
+	}
+	return updateOpts
+}
+
+// deleteManyOptions returns the deleteMany options set in the query
+func deleteManyOptions(q *QueryDSL) *options.DeleteManyOptionsBuilder {
+	deleteOpts := options.DeleteMany()
+	if c, ok := q.Options["collation"].(map[string]any); ok {
+		deleteOpts.SetCollation(parseCollation(c))
+	}
+	return deleteOpts
+}
+
 // executeUpdateOne updates a single document.
 func (c *Conn) executeUpdateOne(ctx context.Context, q *QueryDSL) (driver.Result, error) {
 	if q.Collection == "" {
@@ -1501,17 +1821,20 @@ func (c *Conn) executeUpdateOne(ctx context.Context, q *QueryDSL) (driver.Result
 
 	coll := c.db.Collection(q.Collection)
 
-	updateOpts := options.UpdateOne()
-	if q.Options != nil {
-		if upsert, ok := q.Options["upsert"].(bool); ok && upsert {
-			updateOpts.SetUpsert(true)
-		}
+	updateOpts := updateOneOptions(q)
+
+	prev, err := sideEffectDoc(ctx, coll, q, filter)
+	if err != nil {
+		return nil, err
 	}
 
 	result, err := coll.UpdateOne(ctx, filter, update, updateOpts)
 	if err != nil {
 		return nil, fmt.Errorf("mongodriver: updateOne: %w", err)
 	}
+	if err := c.runUpdateSideEffects(ctx, coll, q, prev); err != nil {
+		return nil, err
+	}
 
 	affected := result.MatchedCount
 	if result.UpsertedCount > 0 {
@@ -1535,6 +1858,11 @@ func (c *Conn) executeUpdateMany(ctx context.Context, q *QueryDSL) (driver.Resul
 		filter = translateFieldsInMap(q.Filter)
 	}
 
+	// an empty filter would update every document
+	if len(filter) == 0 {
+		return nil, fmt.Errorf("mongodriver: updateMany requires a filter")
+	}
+
 	update := bson.M{}
 	if q.Update != nil {
 		// Translate field names in update as well
@@ -1542,7 +1870,7 @@ func (c *Conn) executeUpdateMany(ctx context.Context, q *QueryDSL) (driver.Resul
 	}
 
 	coll := c.db.Collection(q.Collection)
-	result, err := coll.UpdateMany(ctx, filter, update)
+	result, err := coll.UpdateMany(ctx, filter, update, updateManyOptions(q))
 	if err != nil {
 		return nil, fmt.Errorf("mongodriver: updateMany: %w", err)
 	}
@@ -1565,10 +1893,36 @@ func (c *Conn) executeDeleteOne(ctx context.Context, q *QueryDSL) (driver.Result
 	}
 
 	coll := c.db.Collection(q.Collection)
+
+	prev, err := sideEffectDoc(ctx, coll, q, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.SoftDelete != "" {
+		_, ok, err := softDeleteOne(ctx, coll, filter, q.SoftDelete)
+		if err != nil {
+			return nil, err
+		}
+		var n int64
+		if ok {
+			n = 1
+			if err := c.runSideEffects(ctx, q, prev, nil); err != nil {
+				return nil, err
+			}
+		}
+		return &Result{rowsAffected: n}, nil
+	}
+
 	result, err := coll.DeleteOne(ctx, filter)
 	if err != nil {
 		return nil, fmt.Errorf("mongodriver: deleteOne: %w", err)
 	}
+	if result.DeletedCount != 0 {
+		if err := c.runSideEffects(ctx, q, prev, nil); err != nil {
+			return nil, err
+		}
+	}
 
 	return &Result{
 		rowsAffected: result.DeletedCount,
@@ -1590,35 +1944,42 @@ func (c *Conn) executeDeleteOneAsQuery(ctx context.Context, q *QueryDSL) (driver
 	coll := c.db.Collection(q.Collection)
 	var finalDoc bson.M
 
-	// Read the document before deletion so we can return it.
-	if len(q.ReturnPipeline) > 0 {
-		pipeline := make(bson.A, 0, len(q.ReturnPipeline)+1)
-		pipeline = append(pipeline, bson.M{"$match": filter})
-		for _, stage := range q.ReturnPipeline {
-			translated := translateFieldsInMap(stage)
-			pipeline = append(pipeline, convertSortOrderedToSort(translated))
-		}
+	prev, err := sideEffectDoc(ctx, coll, q, filter)
+	if err != nil {
+		return nil, err
+	}
 
-		cursor, err := coll.Aggregate(ctx, pipeline)
+	// A soft delete sets the field to the current time, the document is
+	// then read by its id as the filter no longer matches it
+	if q.SoftDelete != "" {
+		id, ok, err := softDeleteOne(ctx, coll, filter, q.SoftDelete)
 		if err != nil {
-			return nil, fmt.Errorf("mongodriver: aggregate before delete: %w", err)
-		}
-
-		var results []bson.M
-		if err := cursor.All(ctx, &results); err != nil {
-			cursor.Close(ctx)
-			return nil, fmt.Errorf("mongodriver: aggregate results before delete: %w", err)
+			return nil, err
 		}
-		cursor.Close(ctx)
-		if len(results) > 0 {
-			finalDoc = results[0]
+		if ok {
+			if finalDoc, err = readDeletedDoc(ctx, coll, q, bson.M{"_id": id}); err != nil {
+				return nil, err
+			}
+		} else {
+			prev = nil
 		}
 	} else {
-		_ = coll.FindOne(ctx, filter).Decode(&finalDoc)
+		// Read the document before deletion so we can return it.
+		var err error
+		if finalDoc, err = readDeletedDoc(ctx, coll, q, filter); err != nil {
+			return nil, err
+		}
+		result, err := coll.DeleteOne(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("mongodriver: deleteOne: %w", err)
+		}
+		if result.DeletedCount == 0 {
+			prev = nil
+		}
 	}
 
-	if _, err := coll.DeleteOne(ctx, filter); err != nil {
-		return nil, fmt.Errorf("mongodriver: deleteOne: %w", err)
+	if err := c.runSideEffects(ctx, q, prev, nil); err != nil {
+		return nil, err
 	}
 
 	var finalResult any
@@ -1649,6 +2010,55 @@ func (c *Conn) executeDeleteOneAsQuery(ctx context.Context, q *QueryDSL) (driver
 	return NewSingleValueRows(jsonBytes, []string{"__root"}), nil
 }
 
+// readDeletedDoc reads the document returned by a deleteOne
+func readDeletedDoc(ctx context.Context, coll *mongo.Collection, q *QueryDSL, filter bson.M) (bson.M, error) {
+	var doc bson.M
+
+	if len(q.ReturnPipeline) == 0 {
+		_ = coll.FindOne(ctx, filter).Decode(&doc)
+		return doc, nil
+	}
+
+	pipeline := make(bson.A, 0, len(q.ReturnPipeline)+1)
+	pipeline = append(pipeline, bson.M{"$match": filter})
+	for _, stage := range q.ReturnPipeline {
+		translated := translateFieldsInMap(stage)
+		pipeline = append(pipeline, convertSortOrderedToSort(translated))
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("mongodriver: aggregate before delete: %w", err)
+	}
+
+	var results []bson.M
+	if err := cursor.All(ctx, &results); err != nil {
+		cursor.Close(ctx)
+		return nil, fmt.Errorf("mongodriver: aggregate results before delete: %w", err)
+	}
+	cursor.Close(ctx)
+	if len(results) > 0 {
+		doc = results[0]
+	}
+	return doc, nil
+}
+
+// softDeleteOne sets the field of the first matching document to the current
+// time and returns its id, ok is false when no document matched
+func softDeleteOne(ctx context.Context, coll *mongo.Collection, filter bson.M, field string) (any, bool, error) {
+	opts := options.FindOneAndUpdate().SetProjection(bson.M{"_id": 1})
+
+	var doc bson.M
+	err := coll.FindOneAndUpdate(ctx, filter, bson.M{"$currentDate": bson.M{field: true}}, opts).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("mongodriver: soft delete: %w", err)
+	}
+	return doc["_id"], true, nil
+}
+
 // executeDeleteMany deletes multiple documents.
 func (c *Conn) executeDeleteMany(ctx context.Context, q *QueryDSL) (driver.Result, error) {
 	if q.Collection == "" {
@@ -1661,8 +2071,13 @@ func (c *Conn) executeDeleteMany(ctx context.Context, q *QueryDSL) (driver.Resul
 		filter = translateFieldsInMap(q.Filter)
 	}
 
+	// an empty filter would delete every document
+	if len(filter) == 0 {
+		return nil, fmt.Errorf("mongodriver: deleteMany requires a filter")
+	}
+
 	coll := c.db.Collection(q.Collection)
-	result, err := coll.DeleteMany(ctx, filter)
+	result, err := coll.DeleteMany(ctx, filter, deleteManyOptions(q))
 	if err != nil {
 		return nil, fmt.Errorf("mongodriver: deleteMany: %w", err)
 	}
@@ -1672,6 +2087,167 @@ func (c *Conn) executeDeleteMany(ctx context.Context, q *QueryDSL) (driver.Resul
 	}, nil
 }
 
+// executeUpdateManyAsQuery updates all the documents matching the filter
+// and returns them, the updated documents are read by their ids since the
+// update can change the fields the filter matches on
+func (c *Conn) executeUpdateManyAsQuery(ctx context.Context, q *QueryDSL) (driver.Rows, error) {
+	if q.Collection == "" {
+		return nil, fmt.Errorf("mongodriver: updateMany requires collection")
+	}
+
+	filter := bson.M{}
+	if q.Filter != nil {
+		filter = translateFieldsInMap(q.Filter)
+	}
+	// an empty filter would update every document
+	if len(filter) == 0 {
+		return nil, fmt.Errorf("mongodriver: updateMany requires a filter")
+	}
+
+	update := bson.M{}
+	if q.Update != nil {
+		update = translateFieldsInMap(q.Update)
+	}
+
+	coll := c.db.Collection(q.Collection)
+
+	ids, err := matchingIDs(ctx, coll, q, filter)
+	if err != nil {
+		return nil, fmt.Errorf("mongodriver: updateMany: %w", err)
+	}
+	idFilter := bson.M{"_id": bson.M{"$in": ids}}
+
+	if len(ids) != 0 {
+		if _, err := coll.UpdateMany(ctx, idFilter, update, updateManyOptions(q)); err != nil {
+			return nil, fmt.Errorf("mongodriver: updateMany: %w", err)
+		}
+	}
+
+	docs, err := readDocs(ctx, coll, q, idFilter)
+	if err != nil {
+		return nil, err
+	}
+	return manyResultRows(q, docs)
+}
+
+// executeDeleteManyAsQuery deletes all the documents matching the filter
+// and returns them as they were before the delete, a soft delete returns
+// them with the field set
+func (c *Conn) executeDeleteManyAsQuery(ctx context.Context, q *QueryDSL) (driver.Rows, error) {
+	if q.Collection == "" {
+		return nil, fmt.Errorf("mongodriver: deleteMany requires collection")
+	}
+
+	filter := bson.M{}
+	if q.Filter != nil {
+		filter = translateFieldsInMap(q.Filter)
+	}
+	// an empty filter would delete every document
+	if len(filter) == 0 {
+		return nil, fmt.Errorf("mongodriver: deleteMany requires a filter")
+	}
+
+	coll := c.db.Collection(q.Collection)
+
+	ids, err := matchingIDs(ctx, coll, q, filter)
+	if err != nil {
+		return nil, fmt.Errorf("mongodriver: deleteMany: %w", err)
+	}
+	idFilter := bson.M{"_id": bson.M{"$in": ids}}
+
+	var docs []bson.M
+	if q.SoftDelete != "" {
+		if len(ids) != 0 {
+			_, err := coll.UpdateMany(ctx, idFilter, bson.M{"$currentDate": bson.M{q.SoftDelete: true}})
+			if err != nil {
+				return nil, fmt.Errorf("mongodriver: soft delete: %w", err)
+			}
+		}
+		if docs, err = readDocs(ctx, coll, q, idFilter); err != nil {
+			return nil, err
+		}
+	} else {
+		if docs, err = readDocs(ctx, coll, q, idFilter); err != nil {
+			return nil, err
+		}
+		if len(ids) != 0 {
+			if _, err := coll.DeleteMany(ctx, idFilter); err != nil {
+				return nil, fmt.Errorf("mongodriver: deleteMany: %w", err)
+			}
+		}
+	}
+	return manyResultRows(q, docs)
+}
+
+// matchingIDs returns the ids of the documents matching the filter with
+// the collation of the query
+func matchingIDs(ctx context.Context, coll *mongo.Collection, q *QueryDSL, filter bson.M) ([]any, error) {
+	findOpts := options.Find().SetProjection(bson.M{"_id": 1})
+	if c, ok := q.Options["collation"].(map[string]any); ok {
+		findOpts.SetCollation(parseCollation(c))
+	}
+	cursor, err := coll.Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	var docs []bson.M
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ids := make([]any, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc["_id"])
+	}
+	return ids, nil
+}
+
+// readDocs reads the documents returned by an updateMany or a deleteMany
+// with the return pipeline
+func readDocs(ctx context.Context, coll *mongo.Collection, q *QueryDSL, filter bson.M) ([]bson.M, error) {
+	pipeline := make(bson.A, 0, len(q.ReturnPipeline)+1)
+	pipeline = append(pipeline, bson.M{"$match": filter})
+	for _, stage := range q.ReturnPipeline {
+		translated := translateFieldsInMap(stage)
+		pipeline = append(pipeline, convertSortOrderedToSort(translated))
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("mongodriver: aggregate documents: %w", err)
+	}
+	var docs []bson.M
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("mongodriver: aggregate results: %w", err)
+	}
+	return docs, nil
+}
+
+// manyResultRows returns the documents of an updateMany or a deleteMany
+// wrapped in the field name
+func manyResultRows(q *QueryDSL, docs []bson.M) (driver.Rows, error) {
+	list := make([]any, 0, len(docs))
+	for _, doc := range docs {
+		list = append(list, translateIDFieldsBack(doc))
+	}
+
+	var finalResult any = list
+	if q.Singular {
+		finalResult = nil
+		if len(list) != 0 {
+			finalResult = list[0]
+		}
+	}
+	if q.FieldName != "" {
+		finalResult = map[string]any{q.FieldName: finalResult}
+	}
+
+	jsonBytes, err := json.Marshal(finalResult)
+	if err != nil {
+		return nil, fmt.Errorf("mongodriver: marshal %s result: %w", q.Operation, err)
+	}
+	return NewSingleValueRows(jsonBytes, []string{"__root"}), nil
+}
+
 // Result implements driver.Result.
 type Result struct {
 	lastInsertID string
@@ -32,7 +32,8 @@ type QueryDSL struct {
 	Filter            map[string]any   `json:"filter,omitempty"`
 	Update            map[string]any   `json:"update,omitempty"`
 	Options           map[string]any   `json:"options,omitempty"`
-	Presets           map[string]any   `json:"presets,omitempty"` // Preset values to merge with document
+	Presets           map[string]any   `json:"presets,omitempty"`  // Preset values to merge with document
+	Defaults          map[string]any   `json:"defaults,omitempty"` // Default values of the fields missing from the document
 	Params            []string         `json:"params,omitempty"`
 	Queries           []*QueryDSL      `json:"queries,omitempty"`             // For multi_aggregate operations
 	Inserts           []NestedInsert   `json:"inserts,omitempty"`             // For nested_insert operations
@@ -43,6 +44,55 @@ type QueryDSL struct {
 	Condition         *QueryCondition  `json:"condition,omitempty"`           // Condition for variable-based directives
 	CursorInfo        *CursorInfo      `json:"cursor_info,omitempty"`         // Cursor pagination metadata
 	CursorParam       string           `json:"cursor_param,omitempty"`        // Parameter placeholder for cursor value (e.g., "$1")
+
+	// VersionField is the version field checked by the update filter, no
+	// document is returned when the version did not match
+	VersionField string `json:"version_field,omitempty"`
+
+	// SoftDelete is the field a deleteOne sets to the current time instead
+	// of deleting the document
+	SoftDelete string `json:"soft_delete,omitempty"`
+
+	// Connection is set when the page info of the cursor pagination is
+	// returned, a document more than the $limit is fetched to know if
+	// there is a next page
+	Connection bool `json:"connection,omitempty"`
+
+	// hasCursor is set when the query continues from a cursor
+	hasCursor bool
+
+	// Count is set when the pipeline ends in a $count stage, the count is
+	// returned under CountField or as a scalar when CountField is not set
+	Count      bool   `json:"count,omitempty"`
+	CountField string `json:"count_field,omitempty"`
+
+	// ObjectIDs are the fields of a document read from a variable that hold
+	// ObjectIds, their string values are converted to ObjectIds
+	ObjectIDs []string `json:"object_ids,omitempty"`
+
+	// ConflictTarget are the fields a bulkUpsert matches the existing documents
+	// on and ConflictUpdate the fields it sets on them, ConflictPresets are the
+	// values also set on them. Only the existing documents matching the Filter
+	// are updated.
+	ConflictTarget  []string       `json:"conflict_target,omitempty"`
+	ConflictUpdate  []string       `json:"conflict_update,omitempty"`
+	ConflictPresets map[string]any `json:"conflict_presets,omitempty"`
+
+	// Materialize caches the results of an aggregate in a collection
+	Materialize *Materialize `json:"materialize,omitempty"`
+
+	// SideEffects are the updates of other collections run after the
+	// insertOne, updateOne or deleteOne
+	SideEffects []SideEffect `json:"side_effects,omitempty"`
+
+	// Changes is the change stream mode of a watch, 'document' or 'fields'
+	// and Fields maps the GraphQL field names to the columns they select
+	Changes string         `json:"changes,omitempty"`
+	Fields  map[string]any `json:"fields,omitempty"`
+
+	// Comment is added to the aggregate command to identify the query in
+	// the profiler and the slow query log
+	Comment string `json:"comment,omitempty"`
 }
 
 // NestedInsert represents a single insert in a nested mutation operation.
@@ -97,8 +147,21 @@ type CursorInfo struct {
 
 // CursorColumn represents an order-by column for cursor extraction.
 type CursorColumn struct {
-	Col   string `json:"col"`   // Column name
-	Order string `json:"order"` // "asc" or "desc"
+	Col   string `json:"col"`             // Column name
+	Field string `json:"field,omitempty"` // Field to seek on if not the column (e.g. a looked up related document)
+	Order string `json:"order"`           // "asc" or "desc"
+}
+
+// seekField returns the field the seek filter matches the column on
+func (c CursorColumn) seekField() string {
+	switch {
+	case c.Field != "":
+		return c.Field
+	case c.Col == "id":
+		// Translate "id" to "_id" for MongoDB
+		return "_id"
+	}
+	return c.Col
 }
 
 // Supported operations
@@ -112,6 +175,7 @@ const (
 	OpInsertMany        = "insertMany"
 	OpUpdateOne         = "updateOne"
 	OpUpdateMany        = "updateMany"
+	OpFindOneAndUpdate  = "findOneAndUpdate"
 	OpDeleteOne         = "deleteOne"
 	OpDeleteMany        = "deleteMany"
 	OpNestedInsert      = "nested_insert"
@@ -121,6 +185,8 @@ const (
 	OpIntrospectFuncs   = "introspect_functions"
 	OpEmpty             = "empty" // For dropped root selections (@add/@remove directives)
 	OpNull              = "null"  // For nulled selections (@skip/@include directives)
+	OpWatch             = "watch" // For subscriptions that watch a change stream
+	OpBulkUpsert        = "bulkUpsert"
 )
 
 // ParseQuery parses a JSON query DSL string into a QueryDSL struct.
@@ -174,10 +240,18 @@ func (q *QueryDSL) SubstituteParams(args []any) error {
 		q.Update = substituteInMap(q.Update, paramMap)
 	}
 
+	// Substitute in options (e.g. values in arrayFilters)
+	if q.Options != nil {
+		q.Options = substituteInMap(q.Options, paramMap)
+	}
+
 	// Substitute in presets
 	if q.Presets != nil {
 		q.Presets = substituteInMap(q.Presets, paramMap)
 	}
+	if q.ConflictPresets != nil {
+		q.ConflictPresets = substituteInMap(q.ConflictPresets, paramMap)
+	}
 
 	// Handle RawDocument - parse the parameter value as JSON into Document or Documents
 	if q.RawDocument != "" {
@@ -202,6 +276,9 @@ func (q *QueryDSL) SubstituteParams(args []any) error {
 				if err := json.Unmarshal(rawBytes, &docs); err != nil {
 					return fmt.Errorf("mongodriver: failed to parse raw_document JSON array: %w", err)
 				}
+				if len(q.SideEffects) != 0 {
+					return fmt.Errorf("mongodriver: side effects only run after the insert of a single document")
+				}
 				q.Documents = docs
 				// Switch operation from insertOne to insertMany for bulk inserts
 				if q.Operation == OpInsertOne {
@@ -215,6 +292,13 @@ func (q *QueryDSL) SubstituteParams(args []any) error {
 				}
 				q.Document = doc
 			}
+
+			// Set the defaults of the fields missing from the documents,
+			// a field set to null keeps the null
+			setDefaults(q.Document, q.Defaults)
+			for _, doc := range q.Documents {
+				setDefaults(doc, q.Defaults)
+			}
 		}
 	}
 
@@ -244,6 +328,13 @@ func (q *QueryDSL) SubstituteParams(args []any) error {
 		}
 	}
 
+	// Substitute in nested inserts (for nested_insert)
+	for i := range q.Inserts {
+		if q.Inserts[i].Document != nil {
+			q.Inserts[i].Document = substituteInMap(q.Inserts[i].Document, paramMap)
+		}
+	}
+
 	// Substitute in nested updates (for nested_update)
 	for i := range q.Updates {
 		if q.Updates[i].Filter != nil {
@@ -271,8 +362,10 @@ func (q *QueryDSL) SubstituteParams(args []any) error {
 			if isString && cursorStr != "" {
 				seekFilter := buildCursorSeekFilter(q.CursorInfo, cursorStr)
 				if seekFilter != nil {
-					// Prepend the seek filter to the pipeline
-					q.Pipeline = append([]map[string]any{seekFilter}, q.Pipeline...)
+					// Insert the seek filter ahead of the rest of the pipeline
+					i := seekFilterPos(q.CursorInfo, q.Pipeline)
+					q.Pipeline = append(q.Pipeline[:i:i], append([]map[string]any{seekFilter}, q.Pipeline[i:]...)...)
+					q.hasCursor = true
 				}
 			}
 		}
@@ -281,6 +374,34 @@ func (q *QueryDSL) SubstituteParams(args []any) error {
 	return nil
 }
 
+// seekFilterPos returns the position of the seek filter in the pipeline. It
+// goes first unless the order-by columns are fields of related documents,
+// then it follows the stages that look them up.
+func seekFilterPos(info *CursorInfo, pipeline []map[string]any) int {
+	related := false
+	for _, col := range info.OrderBy {
+		if col.Field != "" {
+			related = true
+		}
+	}
+	if !related {
+		return 0
+	}
+	pos := 0
+	for i, stage := range pipeline {
+		fields, ok := stage["$addFields"].(map[string]any)
+		if !ok {
+			continue
+		}
+		for k := range fields {
+			if strings.HasPrefix(k, "__ob_") {
+				pos = i + 1
+			}
+		}
+	}
+	return pos
+}
+
 // buildCursorSeekFilter builds a $match stage for cursor-based seek pagination.
 // Supported cursor inputs:
 // - prefixed: gj-hexTs:selID:val1:val2:...
@@ -324,11 +445,7 @@ func buildCursorSeekFilter(info *CursorInfo, cursorStr string) map[string]any {
 
 		// Add equality conditions for all preceding columns
 		for j := 0; j < i; j++ {
-			colName := info.OrderBy[j].Col
-			// Translate "id" to "_id" for MongoDB
-			if colName == "id" {
-				colName = "_id"
-			}
+			colName := info.OrderBy[j].seekField()
 			val := parseCursorValue(cursorValues[j])
 			andConditions = append(andConditions, map[string]any{
 				colName: val,
@@ -336,11 +453,7 @@ func buildCursorSeekFilter(info *CursorInfo, cursorStr string) map[string]any {
 		}
 
 		// Add the comparison condition for this column
-		colName := info.OrderBy[i].Col
-		// Translate "id" to "_id" for MongoDB
-		if colName == "id" {
-			colName = "_id"
-		}
+		colName := info.OrderBy[i].seekField()
 		val := parseCursorValue(cursorValues[i])
 		order := info.OrderBy[i].Order
 
@@ -497,6 +610,18 @@ func transformFKConnect(doc map[string]any, path, column string) {
 	delete(doc, path)
 }
 
+// setDefaults sets the default values of the fields missing from the document.
+func setDefaults(doc map[string]any, defaults map[string]any) {
+	if doc == nil {
+		return
+	}
+	for k, v := range defaults {
+		if _, ok := doc[k]; !ok {
+			doc[k] = v
+		}
+	}
+}
+
 // substituteInMap recursively replaces parameter placeholders in a map.
 func substituteInMap(m map[string]any, params map[string]any) map[string]any {
 	result := make(map[string]any)
@@ -1,6 +1,10 @@
 package mongodriver
 
-import "testing"
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
 
 func TestNormalizeCursorForSeek(t *testing.T) {
 	tests := []struct {
@@ -117,3 +121,45 @@ func TestBuildCursorSeekFilterInvalid(t *testing.T) {
 		t.Fatalf("expected nil filter for invalid cursor, got: %#v", got)
 	}
 }
+
+func TestBuildCursorSeekFilterRelated(t *testing.T) {
+	info := &CursorInfo{
+		SelID:  2,
+		Prefix: "gj-abc:",
+		OrderBy: []CursorColumn{
+			{Col: "__ob_users_full_name", Field: "__ob_users.full_name", Order: "desc"},
+		},
+	}
+
+	match := buildCursorSeekFilter(info, "gj-abc:2:Bob")
+	if match == nil {
+		t.Fatalf("buildCursorSeekFilter() returned nil")
+	}
+	nameCmp := match["$match"].(map[string]any)["__ob_users.full_name"].(map[string]any)
+	if got := nameCmp["$lt"]; got != "Bob" {
+		t.Fatalf("related field cmp = %v, want Bob", got)
+	}
+
+	// the filter follows the stages that look up the related document
+	pipeline := []map[string]any{
+		{"$match": map[string]any{"price": 1}},
+		{"$lookup": map[string]any{"from": "users", "as": "__ob_users"}},
+		{"$addFields": map[string]any{"__ob_users": map[string]any{"$arrayElemAt": []any{"$__ob_users", 0}}}},
+		{"$sort_ordered": []any{[]any{"__ob_users.full_name", -1}}},
+	}
+	if got := seekFilterPos(info, pipeline); got != 3 {
+		t.Fatalf("seekFilterPos() = %d, want 3", got)
+	}
+	info.OrderBy[0] = CursorColumn{Col: "price", Order: "desc"}
+	if got := seekFilterPos(info, pipeline); got != 0 {
+		t.Fatalf("seekFilterPos() = %d, want 0", got)
+	}
+}
+
+func TestDropCursorFields(t *testing.T) {
+	doc := bson.M{"_id": 1, "title": "a", "__cursor_price": 10, "__cursor___ob_users_name": "b"}
+	doc = dropCursorFields(translateIDFieldsBack(doc))
+	if len(doc) != 2 || doc["id"] != 1 || doc["title"] != "a" {
+		t.Fatalf("expected the cursor fields to be removed, got: %v", doc)
+	}
+}
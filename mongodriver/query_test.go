@@ -0,0 +1,94 @@
+package mongodriver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSubstituteParamsDefaults(t *testing.T) {
+	t.Run("single document", func(t *testing.T) {
+		q, err := ParseQuery(`{"operation":"insertOne","collection":"orders","raw_document":"$1",
+			"defaults":{"status":"pending","qty":1}}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := q.SubstituteParams([]any{json.RawMessage(`{"id":1,"status":null}`)}); err != nil {
+			t.Fatal(err)
+		}
+		if v, ok := q.Document["status"]; !ok || v != nil {
+			t.Fatalf("expected the explicit null to be kept, got: %#v", q.Document)
+		}
+		if v := q.Document["qty"]; v != float64(1) {
+			t.Fatalf("expected the default qty, got: %#v", q.Document)
+		}
+	})
+
+	t.Run("bulk documents", func(t *testing.T) {
+		q, err := ParseQuery(`{"operation":"insertOne","collection":"orders","raw_document":"$1",
+			"defaults":{"status":"pending"}}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := q.SubstituteParams([]any{json.RawMessage(`[{"id":1},{"id":2,"status":"paid"}]`)}); err != nil {
+			t.Fatal(err)
+		}
+		if len(q.Documents) != 2 ||
+			q.Documents[0]["status"] != "pending" ||
+			q.Documents[1]["status"] != "paid" {
+			t.Fatalf("unexpected documents: %#v", q.Documents)
+		}
+	})
+}
+
+func TestSubstituteParamsSideEffects(t *testing.T) {
+	q, err := ParseQuery(`{"operation":"insertOne","collection":"products","raw_document":"$1",
+		"side_effects":[{"collection":"categories","field":"category_id","update":{"$inc":{"product_count":1}}}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.SideEffects) != 1 || q.SideEffects[0].Collection != "categories" {
+		t.Fatalf("unexpected side effects: %#v", q.SideEffects)
+	}
+
+	// the side effects only run after the insert of a single document
+	err = q.SubstituteParams([]any{json.RawMessage(`[{"category_id":1},{"category_id":2}]`)})
+	if err == nil {
+		t.Fatal("expected an error for a bulk insert with side effects")
+	}
+}
+
+func TestCheckSideEffects(t *testing.T) {
+	se := `"side_effects":[{"collection":"categories","field":"category_id","update":{"$inc":{"product_count":1}}}]`
+
+	// the operations changing several documents are refused before they
+	// run instead of skipping the side effects
+	for _, op := range []string{OpInsertMany, OpUpdateMany, OpDeleteMany, OpBulkUpsert, OpNestedInsert, OpNestedUpdate} {
+		q, err := ParseQuery(`{"operation":"` + op + `","collection":"products",` + se + `}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &Conn{}
+		if _, err := c.executeQuery(context.Background(), q); err == nil || !strings.Contains(err.Error(), "side effects of "+op) {
+			t.Fatalf("expected the query of %s to be refused, got: %v", op, err)
+		}
+		if _, err := c.executeExec(context.Background(), q); err == nil || !strings.Contains(err.Error(), "side effects of "+op) {
+			t.Fatalf("expected the exec of %s to be refused, got: %v", op, err)
+		}
+
+		mq, err := ParseQuery(`{"operation":"multi_mutation","queries":[{"operation":"` + op + `","collection":"products",` + se + `}]}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.executeQuery(context.Background(), mq); err == nil || !strings.Contains(err.Error(), "side effects of "+op) {
+			t.Fatalf("expected the sub-mutation %s to be refused, got: %v", op, err)
+		}
+	}
+
+	for _, op := range []string{OpInsertOne, OpUpdateOne, OpFindOneAndUpdate, OpDeleteOne} {
+		if err := checkSideEffects(&QueryDSL{Operation: op, SideEffects: []SideEffect{{Collection: "categories"}}}); err != nil {
+			t.Fatalf("expected the side effects of %s to run, got: %v", op, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package mongodriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// SideEffect is an update of another collection run after the insert,
+// update or delete of a document. The documents of the Collection whose Key
+// is the value of the Field of the mutated document are updated with Update,
+// or the Pipeline is run on the documents of the mutated collection with
+// that value and its output is merged into them.
+//
+// Side effects run after the mutation and are not part of a transaction,
+// when one fails its error is returned but the mutation is not undone.
+type SideEffect struct {
+	Collection string           `json:"collection"`
+	Field      string           `json:"field"`
+	Key        string           `json:"key,omitempty"`
+	Update     any              `json:"update,omitempty"` // update document or pipeline
+	Pipeline   []map[string]any `json:"pipeline,omitempty"`
+}
+
+// checkSideEffects refuses the side effects of the operations that change
+// several documents, they only run after the insert, update or delete of a
+// single document and would be skipped otherwise
+func checkSideEffects(q *QueryDSL) error {
+	if len(q.SideEffects) == 0 {
+		return nil
+	}
+	switch q.Operation {
+	case OpInsertOne, OpUpdateOne, OpFindOneAndUpdate, OpDeleteOne:
+		return nil
+	}
+	return fmt.Errorf("mongodriver: side effects of %s: only run after the insert, update or delete of a single document",
+		q.Operation)
+}
+
+// sideEffectDoc reads the fields the side effects match on from the document
+// matching the filter, it is nil when there are no side effects or no match
+func sideEffectDoc(ctx context.Context, coll *mongo.Collection, q *QueryDSL, filter bson.M) (bson.M, error) {
+	if len(q.SideEffects) == 0 {
+		return nil, nil
+	}
+	proj := bson.M{"_id": 1}
+	for _, se := range q.SideEffects {
+		proj[se.Field] = 1
+	}
+
+	var doc bson.M
+	err := coll.FindOne(ctx, filter, options.FindOne().SetProjection(proj)).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mongodriver: side effects: %w", err)
+	}
+	return doc, nil
+}
+
+// runUpdateSideEffects runs the side effects of the update of the document
+// prev was read from before the update
+func (c *Conn) runUpdateSideEffects(ctx context.Context, coll *mongo.Collection, q *QueryDSL, prev bson.M) error {
+	if len(q.SideEffects) == 0 || prev == nil {
+		return nil
+	}
+	doc, err := sideEffectDoc(ctx, coll, q, bson.M{"_id": prev["_id"]})
+	if err != nil {
+		return err
+	}
+	return c.runSideEffects(ctx, q, doc, prev)
+}
+
+// runSideEffects runs the side effects of the mutation of the document. The
+// updates use its values, the pipelines recompute the targets of the values
+// of the document before an update too as it no longer matches them.
+func (c *Conn) runSideEffects(ctx context.Context, q *QueryDSL, doc, prev bson.M) error {
+	for _, se := range q.SideEffects {
+		var vals []any
+		addValue := func(d bson.M) {
+			v, ok := d[se.Field]
+			if !ok || v == nil || slices.ContainsFunc(vals, func(v1 any) bool {
+				return reflect.DeepEqual(v1, v)
+			}) {
+				return
+			}
+			vals = append(vals, v)
+		}
+		addValue(doc)
+		if se.Pipeline != nil {
+			addValue(prev)
+		}
+
+		for _, v := range vals {
+			if err := c.runSideEffect(ctx, q.Collection, se, v); err != nil {
+				return fmt.Errorf("mongodriver: side effect on %s: %w", se.Collection, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runSideEffect updates the documents of the side effect whose key is val
+func (c *Conn) runSideEffect(ctx context.Context, collection string, se SideEffect, val any) error {
+	key := translateFieldName(se.Key)
+	if key == "" {
+		key = "_id"
+	}
+
+	if se.Pipeline == nil {
+		_, err := c.db.Collection(se.Collection).UpdateMany(ctx, bson.M{key: val}, se.Update)
+		return err
+	}
+
+	pipeline := make(bson.A, 0, len(se.Pipeline)+2)
+	pipeline = append(pipeline, bson.M{"$match": bson.M{se.Field: val}})
+	for _, stage := range se.Pipeline {
+		pipeline = append(pipeline, stage)
+	}
+	pipeline = append(pipeline, bson.M{"$merge": bson.M{
+		"into":           se.Collection,
+		"on":             key,
+		"whenMatched":    "merge",
+		"whenNotMatched": "discard",
+	}})
+
+	cursor, err := c.db.Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.Close(ctx)
+}
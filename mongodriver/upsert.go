@@ -0,0 +1,175 @@
+package mongodriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// executeBulkUpsert upserts the documents of a bulkUpsert.
+func (c *Conn) executeBulkUpsert(ctx context.Context, q *QueryDSL) (driver.Result, error) {
+	coll := c.db.Collection(q.Collection)
+	_, n, err := bulkUpsert(ctx, coll, q)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{rowsAffected: n}, nil
+}
+
+// executeBulkUpsertAsQuery upserts the documents of a bulkUpsert and returns
+// the inserted and the updated documents as query results.
+func (c *Conn) executeBulkUpsertAsQuery(ctx context.Context, q *QueryDSL) (driver.Rows, error) {
+	coll := c.db.Collection(q.Collection)
+	filters, _, err := bulkUpsert(ctx, coll, q)
+	if err != nil {
+		return nil, err
+	}
+
+	// The documents are found again by the values of their conflict target
+	pipeline := bson.A{bson.M{"$match": bson.M{"$or": filters}}}
+	for _, stage := range q.ReturnPipeline {
+		translated := translateFieldsInMap(stage)
+		pipeline = append(pipeline, convertSortOrderedToSort(translated))
+	}
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("mongodriver: aggregate after bulkUpsert: %w", err)
+	}
+	var docs []bson.M
+	if err := cursor.All(ctx, &docs); err != nil {
+		cursor.Close(ctx)
+		return nil, fmt.Errorf("mongodriver: aggregate results: %w", err)
+	}
+	cursor.Close(ctx)
+
+	resultDocs := make([]any, len(docs))
+	for i, doc := range docs {
+		resultDocs[i] = translateIDFieldsBack(doc)
+	}
+
+	var finalResult any
+	if q.FieldName != "" {
+		finalResult = map[string]any{q.FieldName: resultDocs}
+	} else {
+		finalResult = resultDocs
+	}
+
+	jsonBytes, err := json.Marshal(finalResult)
+	if err != nil {
+		return nil, fmt.Errorf("mongodriver: marshal bulkUpsert result: %w", err)
+	}
+	return NewSingleValueRows(jsonBytes, []string{"__root"}), nil
+}
+
+// bulkUpsert runs an ordered bulk write with an upserting updateOne for each
+// document and returns the filters matching the documents and the number of
+// documents inserted or updated.
+func bulkUpsert(ctx context.Context, coll *mongo.Collection, q *QueryDSL) (bson.A, int64, error) {
+	if q.Collection == "" {
+		return nil, 0, fmt.Errorf("mongodriver: bulkUpsert requires collection")
+	}
+	if len(q.ConflictTarget) == 0 {
+		return nil, 0, fmt.Errorf("mongodriver: bulkUpsert requires conflict_target")
+	}
+
+	docs := q.Documents
+	if len(docs) == 0 && q.Document != nil {
+		docs = []map[string]any{q.Document}
+	}
+	if len(docs) == 0 {
+		return nil, 0, fmt.Errorf("mongodriver: bulkUpsert requires documents")
+	}
+
+	var roleFilter bson.M
+	if q.Filter != nil {
+		roleFilter = translateFieldsInMap(q.Filter)
+	}
+
+	models := make([]mongo.WriteModel, 0, len(docs)*2)
+	filters := make(bson.A, 0, len(docs))
+	var updates int64
+
+	for _, d := range docs {
+		filter, set, insert, err := upsertModel(translateDocumentFields(d), q)
+		if err != nil {
+			return nil, 0, err
+		}
+		// The existing document is updated when it matches the filter and
+		// the new document only inserted when none exists, a document that
+		// exists but doesn't match the filter is left unchanged
+		if len(set) != 0 {
+			updFilter := filter
+			if roleFilter != nil {
+				updFilter = bson.M{"$and": bson.A{filter, roleFilter}}
+			}
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(updFilter).
+				SetUpdate(bson.M{"$set": set}))
+			updates++
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.M{"$setOnInsert": insert}).
+			SetUpsert(true))
+		filters = append(filters, filter)
+	}
+
+	res, err := coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(true))
+	if err != nil {
+		return nil, 0, fmt.Errorf("mongodriver: bulkUpsert: %w", err)
+	}
+	// the inserts of the documents that exist match them without a change
+	existing := int64(len(docs)) - res.UpsertedCount
+	n := res.UpsertedCount + res.MatchedCount - existing
+	if updates == 0 {
+		n = int64(len(docs))
+	}
+	return filters, n, nil
+}
+
+// upsertModel returns the filter matching the existing document on the
+// conflict target, the fields set on the existing document and the fields
+// of the inserted document. The conflict update fields and the conflict
+// presets are set on the existing document, all the fields are inserted.
+func upsertModel(doc map[string]any, q *QueryDSL) (filter, set, insert bson.M, err error) {
+	// Presets override the values of the document like on insert
+	for k, v := range q.Presets {
+		doc[k] = v
+	}
+
+	filter = bson.M{}
+	for _, f := range q.ConflictTarget {
+		v, ok := doc[f]
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("mongodriver: bulkUpsert: document has no value for the conflict target '%s'", f)
+		}
+		filter[f] = v
+	}
+
+	set, insert = bson.M{}, bson.M{}
+	for _, f := range q.ConflictUpdate {
+		if v, ok := doc[f]; ok {
+			set[f] = v
+		}
+	}
+	for k, v := range q.ConflictPresets {
+		set[k] = v
+	}
+	for k, v := range doc {
+		// the upsert inserts the values of the filter
+		if _, ok := filter[k]; !ok {
+			insert[k] = v
+		}
+	}
+	if len(insert) == 0 {
+		// an update can't be empty, the document only has the target fields
+		insert = filter
+	}
+	return filter, set, insert, nil
+}
@@ -0,0 +1,35 @@
+package mongodriver
+
+import (
+	"testing"
+)
+
+func TestUpsertModel(t *testing.T) {
+	q := &QueryDSL{
+		ConflictTarget:  []string{"email"},
+		ConflictUpdate:  []string{"name"},
+		ConflictPresets: map[string]any{"updated_by": int64(2)},
+		Presets:         map[string]any{"org": "acme"},
+	}
+
+	doc := map[string]any{"email": "one@test.com", "name": "One", "role": "user"}
+	filter, set, insert, err := upsertModel(doc, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter["email"] != "one@test.com" || len(filter) != 1 {
+		t.Fatalf("unexpected filter: %v", filter)
+	}
+	// the update fields and the conflict presets are set on the existing document
+	if set["name"] != "One" || set["updated_by"] != int64(2) || len(set) != 2 {
+		t.Fatalf("unexpected set: %v", set)
+	}
+	// all the fields and the presets are inserted
+	if insert["name"] != "One" || insert["role"] != "user" || insert["org"] != "acme" || len(insert) != 3 {
+		t.Fatalf("unexpected insert: %v", insert)
+	}
+
+	if _, _, _, err := upsertModel(map[string]any{"name": "Two"}, q); err == nil {
+		t.Fatal("expected an error for a document without the conflict target")
+	}
+}
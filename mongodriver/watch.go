@@ -0,0 +1,285 @@
+package mongodriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Change stream modes set in the query DSL
+const (
+	ChangesDocument = "document" // deliver the changed document
+	ChangesFields   = "fields"   // deliver only the fields changed by an update
+)
+
+// changeEvent is an event read from a change stream
+type changeEvent struct {
+	OperationType     string `bson:"operationType"`
+	DocumentKey       bson.M `bson:"documentKey"`
+	FullDocument      bson.M `bson:"fullDocument"`
+	UpdateDescription struct {
+		UpdatedFields bson.M   `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+}
+
+// executeWatch opens a change stream on the collection, the returned rows
+// block until the next change that matches the filter.
+func (c *Conn) executeWatch(ctx context.Context, q *QueryDSL) (driver.Rows, error) {
+	if q.Collection == "" {
+		return nil, fmt.Errorf("mongodriver: watch requires collection")
+	}
+
+	rows := newChangeStreamRows(ctx, q)
+	filtered := len(q.Filter) != 0
+
+	csOpts := options.ChangeStream()
+	// the current document is needed to match the filter against updates
+	if filtered || !rows.fieldsOnly {
+		csOpts.SetFullDocument(options.UpdateLookup)
+	}
+	// deleted documents can only be matched against their pre-image
+	if filtered {
+		csOpts.SetFullDocumentBeforeChange(options.WhenAvailable)
+	}
+
+	stream, err := c.db.Collection(q.Collection).Watch(ctx, rows.pipeline(q.Filter), csOpts)
+	if err != nil {
+		if filtered && strings.Contains(err.Error(), "fullDocumentBeforeChange") {
+			err = fmt.Errorf("filtered change streams require MongoDB 6.0 or later: %w", err)
+		}
+		return nil, fmt.Errorf("mongodriver: watch: %w", err)
+	}
+	rows.stream = stream
+	return rows, nil
+}
+
+// ChangeStreamRows implements driver.Rows for a change stream, every row
+// is a change to the collection as JSON in a single column.
+type ChangeStreamRows struct {
+	ctx        context.Context
+	stream     *mongo.ChangeStream
+	fieldName  string
+	fieldsOnly bool
+	// cols maps the field names to their columns, an empty
+	// column is a field that is always null
+	cols map[string]string
+	// fields maps the columns to the field names that select them
+	fields map[string][]string
+}
+
+func newChangeStreamRows(ctx context.Context, q *QueryDSL) *ChangeStreamRows {
+	r := &ChangeStreamRows{
+		ctx:        ctx,
+		fieldName:  q.FieldName,
+		fieldsOnly: q.Changes == ChangesFields,
+		cols:       make(map[string]string, len(q.Fields)),
+		fields:     make(map[string][]string, len(q.Fields)),
+	}
+	for f, v := range q.Fields {
+		col, _ := v.(string)
+		col = translateFieldName(col)
+		r.cols[f] = col
+		if col != "" {
+			r.fields[col] = append(r.fields[col], f)
+		}
+	}
+	return r
+}
+
+// pipeline returns the change stream pipeline, the filter is matched against
+// the current document or for deletes the document before it was deleted
+func (r *ChangeStreamRows) pipeline(filter map[string]any) bson.A {
+	var match bson.M
+	if len(filter) == 0 {
+		match = bson.M{"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete"}}}
+	} else {
+		filter = translateFieldsInMap(filter)
+		match = bson.M{"$or": bson.A{
+			bson.M{"$and": bson.A{
+				bson.M{"operationType": bson.M{"$in": bson.A{"insert", "update", "replace"}}},
+				prefixFilterFields(filter, "fullDocument."),
+			}},
+			bson.M{"$and": bson.A{
+				bson.M{"operationType": "delete"},
+				prefixFilterFields(filter, "fullDocumentBeforeChange."),
+			}},
+		}}
+	}
+
+	project := bson.M{
+		"operationType":     1,
+		"documentKey":       1,
+		"updateDescription": 1,
+	}
+	for col := range r.fields {
+		project["fullDocument."+col] = 1
+	}
+	if len(r.fields) == 0 {
+		project["fullDocument._id"] = 1
+	}
+
+	return bson.A{bson.M{"$match": match}, bson.M{"$project": project}}
+}
+
+// prefixFilterFields prefixes the field names in a filter so that it
+// can be matched against a document embedded in the change event
+func prefixFilterFields(filter map[string]any, prefix string) map[string]any {
+	result := make(map[string]any, len(filter))
+	for k, v := range filter {
+		switch k {
+		case "$and", "$or", "$nor":
+			if list, ok := v.([]any); ok {
+				l := make([]any, len(list))
+				for i, item := range list {
+					if m, ok := item.(map[string]any); ok {
+						l[i] = prefixFilterFields(m, prefix)
+					} else {
+						l[i] = item
+					}
+				}
+				v = l
+			}
+			result[k] = v
+		default:
+			if strings.HasPrefix(k, "$") {
+				result[k] = v
+			} else {
+				result[prefix+k] = v
+			}
+		}
+	}
+	return result
+}
+
+// Columns returns the column names.
+func (r *ChangeStreamRows) Columns() []string {
+	return []string{"__root"}
+}
+
+// Close closes the change stream.
+func (r *ChangeStreamRows) Close() error {
+	if r.stream == nil {
+		return nil
+	}
+	return r.stream.Close(context.Background())
+}
+
+// Next blocks until the next change, changes that do not
+// change any of the selected fields are skipped.
+func (r *ChangeStreamRows) Next(dest []driver.Value) error {
+	for r.stream.Next(r.ctx) {
+		var ev changeEvent
+		if err := r.stream.Decode(&ev); err != nil {
+			return err
+		}
+		v, ok := r.change(&ev)
+		if !ok {
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if len(dest) > 0 {
+			dest[0] = b
+		}
+		return nil
+	}
+	if err := r.stream.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// change returns the payload of a change event, deletes are null and
+// the key of the changed document is always returned as documentKey
+func (r *ChangeStreamRows) change(ev *changeEvent) (map[string]any, bool) {
+	var v any
+
+	switch ev.OperationType {
+	case "delete":
+		v = nil
+	case "update":
+		if r.fieldsOnly {
+			fields := r.updatedFields(ev)
+			if len(fields) == 0 {
+				return nil, false
+			}
+			v = fields
+			break
+		}
+		fallthrough
+	default:
+		// the document was deleted before the update could be looked up
+		if ev.FullDocument == nil {
+			return nil, false
+		}
+		v = r.document(ev.FullDocument)
+	}
+
+	return map[string]any{
+		r.fieldName:   v,
+		"documentKey": translateIDFieldsBack(ev.DocumentKey),
+	}, true
+}
+
+// document returns the selected fields of the document
+func (r *ChangeStreamRows) document(doc bson.M) map[string]any {
+	result := make(map[string]any, len(r.cols))
+	for f, col := range r.cols {
+		if col == "" {
+			result[f] = nil
+			continue
+		}
+		result[f] = translateIDValueBack(doc[col])
+	}
+	return result
+}
+
+// updatedFields returns the selected fields changed by an update, nested
+// changes are returned as partial objects with only the changed values
+func (r *ChangeStreamRows) updatedFields(ev *changeEvent) map[string]any {
+	result := make(map[string]any)
+
+	set := func(path string, v any) {
+		col, rest, nested := strings.Cut(path, ".")
+		for _, f := range r.fields[col] {
+			if nested {
+				result[f] = setPath(result[f], rest, v)
+			} else {
+				result[f] = v
+			}
+		}
+	}
+
+	for path, v := range ev.UpdateDescription.UpdatedFields {
+		set(path, translateIDValueBack(v))
+	}
+	for _, path := range ev.UpdateDescription.RemovedFields {
+		set(path, nil)
+	}
+	return result
+}
+
+// setPath sets the value at the dotted path in the object
+func setPath(obj any, path string, v any) map[string]any {
+	m, ok := obj.(map[string]any)
+	if !ok {
+		m = make(map[string]any)
+	}
+	key, rest, nested := strings.Cut(path, ".")
+	if nested {
+		m[key] = setPath(m[key], rest, v)
+	} else {
+		m[key] = v
+	}
+	return m
+}
@@ -0,0 +1,100 @@
+package mongodriver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestChangeStreamEvents(t *testing.T) {
+	q := &QueryDSL{
+		FieldName: "users",
+		Fields:    map[string]any{"id": "_id", "name": "full_name", "address": "address", "secret": nil},
+	}
+
+	update := &changeEvent{OperationType: "update", DocumentKey: bson.M{"_id": 1}}
+	update.UpdateDescription.UpdatedFields = bson.M{"full_name": "Jane", "address.city": "Paris", "email": "jane@test.com"}
+	update.UpdateDescription.RemovedFields = []string{"address.zip"}
+
+	tests := []struct {
+		name    string
+		changes string
+		ev      *changeEvent
+		want    string
+	}{
+		{
+			name:    "insert",
+			changes: ChangesFields,
+			ev: &changeEvent{
+				OperationType: "insert",
+				DocumentKey:   bson.M{"_id": 1},
+				FullDocument:  bson.M{"_id": 1, "full_name": "John"},
+			},
+			want: `{"documentKey":{"id":1},"users":{"address":null,"id":1,"name":"John","secret":null}}`,
+		},
+		{
+			name:    "update fields",
+			changes: ChangesFields,
+			ev:      update,
+			want:    `{"documentKey":{"id":1},"users":{"address":{"city":"Paris","zip":null},"name":"Jane"}}`,
+		},
+		{
+			name:    "update document",
+			changes: ChangesDocument,
+			ev: &changeEvent{
+				OperationType: "update",
+				DocumentKey:   bson.M{"_id": 1},
+				FullDocument:  bson.M{"_id": 1, "full_name": "Jane"},
+			},
+			want: `{"documentKey":{"id":1},"users":{"address":null,"id":1,"name":"Jane","secret":null}}`,
+		},
+		{
+			name:    "delete",
+			changes: ChangesFields,
+			ev:      &changeEvent{OperationType: "delete", DocumentKey: bson.M{"_id": 1}},
+			want:    `{"documentKey":{"id":1},"users":null}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q.Changes = tt.changes
+			v, ok := newChangeStreamRows(context.Background(), q).change(tt.ev)
+			if !ok {
+				t.Fatal("expected the change to be delivered")
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("expected: %s, got: %s", tt.want, b)
+			}
+		})
+	}
+
+	// updates to columns that are not selected are skipped
+	q.Changes = ChangesFields
+	ev := &changeEvent{OperationType: "update", DocumentKey: bson.M{"_id": 1}}
+	ev.UpdateDescription.UpdatedFields = bson.M{"email": "jane@test.com"}
+	if _, ok := newChangeStreamRows(context.Background(), q).change(ev); ok {
+		t.Error("expected the change to be skipped")
+	}
+}
+
+func TestChangeStreamFilter(t *testing.T) {
+	filter := map[string]any{
+		"active": true,
+		"$or":    []any{map[string]any{"id": 1}, map[string]any{"age": map[string]any{"$gt": 20}}},
+	}
+	got, err := json.Marshal(prefixFilterFields(translateFieldsInMap(filter), "fullDocument."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"$or":[{"fullDocument._id":1},{"fullDocument.age":{"$gt":20}}],"fullDocument.active":true}`
+	if string(got) != want {
+		t.Errorf("expected: %s, got: %s", want, got)
+	}
+}
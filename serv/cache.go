@@ -26,3 +26,18 @@ type ResponseCache interface {
 	// Close releases resources
 	Close() error
 }
+
+// entryTTLs returns how long a response stays fresh and how long it is kept,
+// both are capped by the max-age set with @cacheControl on the query
+func entryTTLs(ctx context.Context, conf CachingConfig) (ttl, freshTTL time.Duration) {
+	ttl = time.Duration(conf.TTL) * time.Second
+	freshTTL = time.Duration(conf.FreshTTL) * time.Second
+	if freshTTL == 0 {
+		freshTTL = ttl // No SWR - fresh until hard TTL
+	}
+	if maxAge, ok := core.ResponseMaxAge(ctx); ok {
+		ttl = min(ttl, maxAge)
+		freshTTL = min(freshTTL, maxAge)
+	}
+	return
+}
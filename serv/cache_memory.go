@@ -162,11 +162,7 @@ func (mc *MemoryCache) Set(
 	}
 
 	now := time.Now()
-	ttl := time.Duration(mc.conf.TTL) * time.Second
-	freshTTL := time.Duration(mc.conf.FreshTTL) * time.Second
-	if freshTTL == 0 {
-		freshTTL = ttl // No SWR - fresh until hard TTL
-	}
+	ttl, freshTTL := entryTTLs(ctx, mc.conf)
 
 	entry := &memoryCacheEntry{
 		entry: CacheEntry{
@@ -233,11 +233,7 @@ func (c *RedisCache) Set(
 	}
 
 	now := time.Now()
-	ttl := time.Duration(c.conf.TTL) * time.Second
-	freshTTL := time.Duration(c.conf.FreshTTL) * time.Second
-	if freshTTL == 0 {
-		freshTTL = ttl // No SWR - fresh until hard TTL
-	}
+	ttl, freshTTL := entryTTLs(ctx, c.conf)
 
 	entry := CacheEntry{
 		Data:         data,
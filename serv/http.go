@@ -55,6 +55,19 @@ type errorResp struct {
 	Errors []string `json:"errors"`
 }
 
+// apqErrorResp is the error response expected by APQ clients
+// to decide if the full query must be sent
+type apqErrorResp struct {
+	Errors []apqError `json:"errors"`
+}
+
+type apqError struct {
+	Message    string `json:"message"`
+	Extensions struct {
+		Code string `json:"code"`
+	} `json:"extensions"`
+}
+
 // apiV1Handler is the main handler for all API requests
 func apiV1Handler(s1 *HttpService, ns *string, h http.Handler, ah auth.HandlerFunc) http.Handler {
 	var zlog *zap.Logger
@@ -161,6 +174,7 @@ func (s1 *HttpService) apiV1GraphQL(ns *string, ah auth.HandlerFunc) http.Handle
 
 		if req.apqEnabled() {
 			rc.APQKey = (req.OpName + req.Ext.Persisted.Sha256Hash)
+			rc.APQHash = req.Ext.Persisted.Sha256Hash
 		}
 
 		if rc.Vars == nil && len(s.conf.HeaderVars) != 0 {
@@ -185,7 +199,11 @@ func (s1 *HttpService) apiV1GraphQL(ns *string, ah auth.HandlerFunc) http.Handle
 
 		res, err := s.gj.GraphQL(ctx, req.Query, req.Vars, &rc)
 		if res == nil && err != nil {
-			renderErr(w, err)
+			if isAPQErr(err) {
+				renderAPQErr(w, err)
+			} else {
+				renderErr(w, err)
+			}
 			return
 		}
 
@@ -377,6 +395,10 @@ func (s *graphjinService) reqLog(res *core.Result, rc core.RequestConfig, resTim
 		fields = append(fields, zap.String("sql", sql))
 	}
 
+	if res != nil && len(res.Params()) != 0 && s.conf.LogVars && s.logLevel >= logLevelDebug {
+		fields = append(fields, zap.Any("params", res.Params()))
+	}
+
 	if err != nil {
 		fields = append(fields, zap.Error(err))
 		s.zlog.Error("query failed", fields...)
@@ -404,6 +426,28 @@ func (r gqlReq) apqEnabled() bool {
 	return r.Ext.Persisted.Sha256Hash != ""
 }
 
+// isAPQErr checks if the error is a persisted query error
+func isAPQErr(err error) bool {
+	return errors.Is(err, core.ErrPersistedQueryNotFound) ||
+		errors.Is(err, core.ErrPersistedQueryHashMismatch)
+}
+
+// renderAPQErr renders a persisted query error with its code
+func renderAPQErr(w http.ResponseWriter, err error) {
+	var e apqError
+	e.Message = err.Error()
+	e.Extensions.Code = err.Error()
+
+	if errors.Is(err, core.ErrPersistedQueryHashMismatch) {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+
+	err1 := json.NewEncoder(w).Encode(apqErrorResp{[]apqError{e}})
+	if err1 != nil {
+		panic(fmt.Errorf("%s: %w", err, err1))
+	}
+}
+
 // renderErr renders the error response
 func renderErr(w http.ResponseWriter, err error) {
 	if err == errUnauthorized {
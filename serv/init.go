@@ -241,8 +241,19 @@ func (s *graphjinService) initLegacyDB() error {
 	return nil
 }
 
-// newDBFromDatabaseConfig creates a *sql.DB from a core.DatabaseConfig.
+// newDBFromDatabaseConfig creates a *sql.DB from a core.DatabaseConfig
+// with the connection pool settings of the database.
 func (s *graphjinService) newDBFromDatabaseConfig(name string, dbConf core.DatabaseConfig) (*sql.DB, error) {
+	db, err := connectDatabaseConfig(name, dbConf)
+	if err != nil {
+		return nil, err
+	}
+	dbConf.ApplyPool(db)
+	return db, nil
+}
+
+// connectDatabaseConfig opens a connection to the database of a core.DatabaseConfig.
+func connectDatabaseConfig(name string, dbConf core.DatabaseConfig) (*sql.DB, error) {
 	dbType := strings.ToLower(dbConf.Type)
 	if dbType == "" {
 		dbType = "postgres"
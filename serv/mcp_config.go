@@ -1383,11 +1383,20 @@ func syncDBFromDatabases(conf *Config) bool {
 	if dbConf.PoolSize > 0 {
 		conf.DB.PoolSize = dbConf.PoolSize
 	}
+	if dbConf.MaxIdleConns > 0 {
+		conf.DB.PoolSize = dbConf.MaxIdleConns
+	}
 	if dbConf.MaxConnections > 0 {
 		conf.DB.MaxConnections = dbConf.MaxConnections
 	}
+	if dbConf.MaxOpenConns > 0 {
+		conf.DB.MaxConnections = dbConf.MaxOpenConns
+	}
 	conf.DB.MaxConnIdleTime = dbConf.MaxConnIdleTime
 	conf.DB.MaxConnLifeTime = dbConf.MaxConnLifeTime
+	if dbConf.ConnMaxLifetime > 0 {
+		conf.DB.MaxConnLifeTime = dbConf.ConnMaxLifetime
+	}
 	conf.DB.PingTimeout = dbConf.PingTimeout
 
 	// TLS settings
@@ -187,16 +187,20 @@ var querySyntaxReference = QuerySyntaxReference{
 	},
 	FullTextSearch: "products(search: \"search term\") - uses database full-text search",
 	Directives: map[string]string{
-		"@include(ifRole:)":      "Include field if user has specified role",
-		"@skip(ifRole:)":         "Skip field if user has specified role",
-		"@include(ifVar:)":       "Include field if variable is true",
-		"@skip(ifVar:)":          "Skip field if variable is true",
-		"@object":                "Return single object instead of array",
-		"@schema(name:)":         "Use specific database schema",
-		"@through(table:)":       "Specify join table for many-to-many",
-		"@notRelated":            "Disable automatic relationship detection for a field",
-		"@cacheControl(maxAge:)": "Set cache TTL in seconds for this query",
-		"@database(name:)":       "Assign table to a named database (REQUIRED on every table when multiple databases are configured). Used in schema definitions, e.g.: type users @database(name: \"mydb\") { ... }",
+		"@include(ifRole:)":        "Include field if user has specified role",
+		"@skip(ifRole:)":           "Skip field if user has specified role",
+		"@include(ifVar:)":         "Include field if variable is true",
+		"@skip(ifVar:)":            "Skip field if variable is true",
+		"@object":                  "Return single object instead of array",
+		"@schema(name:)":           "Use specific database schema",
+		"@through(table:)":         "Specify join table for many-to-many",
+		"@notRelated":              "Disable automatic relationship detection for a field",
+		"@insertOptions(ordered:)": "MongoDB bulk inserts: ordered: false continues past failed documents, returns the inserted ones and an error for each failed one",
+		"@cacheControl(maxAge:)":   "Set cache TTL in seconds for this query",
+		"@flatten":                 "MongoDB singular relationships: merge the related object's fields into the parent, parent fields win on collisions",
+		"@size":                    "MongoDB array columns: return the length of the array instead of the array",
+		"@slice(skip:, limit:)":    "MongoDB array columns: return only a window of the array",
+		"@database(name:)":         "Assign table to a named database (REQUIRED on every table when multiple databases are configured). Used in schema definitions, e.g.: type users @database(name: \"mydb\") { ... }",
 	},
 	Variables: VariablesSyntax{
 		Declaration: "Variables are declared with $ prefix and passed via variables parameter",
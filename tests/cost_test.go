@@ -0,0 +1,44 @@
+package tests_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dosco/graphjin/core/v3"
+)
+
+func TestQueryCostRateLimit(t *testing.T) {
+	conf := newConfig(&core.Config{
+		DBType:           dbType,
+		DisableAllowList: true,
+		Roles:            []core.Role{{Name: "user", CostBudget: 10}},
+	})
+
+	var costs []int
+	gj, err := core.NewGraphJin(conf, db,
+		core.OptionSetCostFn(func(qs core.QueryShape) int {
+			return qs.Selects * 3
+		}),
+		core.OptionSetMetrics(func(c context.Context, m core.QueryMetrics) {
+			costs = append(costs, m.Cost)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gql := `query { users(limit: 1) { id products(limit: 1) { id } } }`
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 1)
+
+	// each request costs 6 so the second one exceeds the budget of 10
+	if _, err := gj.GraphQL(ctx, gql, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	_, err = gj.GraphQL(ctx, gql, nil, nil)
+	if !errors.Is(err, core.ErrRateLimited) {
+		t.Fatalf("expected rate limited error, got: %v", err)
+	}
+	if len(costs) == 0 || costs[0] != 6 {
+		t.Fatalf("expected the cost to be reported to the metrics hook, got: %v", costs)
+	}
+}
@@ -805,3 +805,89 @@ func TestAllowListWithMutations(t *testing.T) {
 	require.NoError(t, err)
 	assert.JSONEq(t, exp3, string(res3.Data))
 }
+
+func TestBulkUpsertRole(t *testing.T) {
+	if dbType == "oracle" || dbType == "snowflake" {
+		t.Skip("skipping test for oracle and snowflake")
+	}
+
+	conf := newConfig(&core.Config{DBType: dbType, DisableAllowList: true})
+	err := conf.AddRoleTable("user", "users", core.Update{
+		Filters: []string{"{ id: { eq: $user_id } }"},
+		Columns: []string{"full_name", "stripe_id"},
+		Presets: map[string]string{"stripe_id": "upserted"},
+	})
+	require.NoError(t, err)
+
+	gj, err := core.NewGraphJin(conf, db)
+	require.NoError(t, err)
+
+	gj1, err := core.NewGraphJin(newConfig(&core.Config{DBType: dbType, DisableAllowList: true}), db)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		gql := `mutation {
+			users(update: $data, where: { id: { eq: 2 } }) { id }
+		}`
+		vars := json.RawMessage(`{"data": {"full_name": "User 2", "stripe_id": "payment_id_1002"}}`)
+		_, _ = gj1.GraphQL(context.Background(), gql, vars, nil)
+
+		gql = `mutation {
+			users(delete: true, where: { id: { eq: 9101 } }) { id }
+		}`
+		_, _ = gj1.GraphQL(context.Background(), gql, nil, nil)
+	})
+
+	// only the row the role can update is updated, the new one is inserted
+	gql := `mutation {
+		users(insert: $data, on_conflict: { target: [email], update: [full_name] }) { id }
+	}`
+	vars := json.RawMessage(`{"data": [
+		{"id": 2, "email": "user2@test.com", "full_name": "User 2 Upserted"},
+		{"id": 3, "email": "user3@test.com", "full_name": "User 3 Upserted"},
+		{"id": 9101, "email": "upsert9101@test.com", "full_name": "User 9101"}
+	]}`)
+
+	ctx := context.WithValue(context.Background(), core.UserIDKey, 2)
+	_, err = gj.GraphQL(ctx, gql, vars, nil)
+	require.NoError(t, err)
+
+	gql = `query {
+		users(where: { id: { in: [2, 3, 9101] } }, order_by: { id: asc }) {
+			id
+			full_name
+			stripe_id
+		}
+	}`
+	res, err := gj1.GraphQL(context.Background(), gql, nil, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"users": [
+		{"id": 2, "full_name": "User 2 Upserted", "stripe_id": "upserted"},
+		{"id": 3, "full_name": "User 3", "stripe_id": "payment_id_1003"},
+		{"id": 9101, "full_name": "User 9101", "stripe_id": null}
+	]}`, string(res.Data))
+
+	// the column is not one the role can update
+	gql = `mutation {
+		users(insert: $data, on_conflict: { target: [email], update: [email] }) { id }
+	}`
+	vars = json.RawMessage(`{"data": [{"id": 2, "email": "user2@test.com"}]}`)
+	_, err = gj.GraphQL(ctx, gql, vars, nil)
+	assert.Error(t, err)
+
+	// the role cannot update the table
+	conf2 := newConfig(&core.Config{DBType: dbType, DisableAllowList: true})
+	err = conf2.AddRoleTable("reader", "users", core.Update{Block: true})
+	require.NoError(t, err)
+
+	gj2, err := core.NewGraphJin(conf2, db)
+	require.NoError(t, err)
+
+	gql = `mutation {
+		users(insert: $data, on_conflict: { target: [email], update: [full_name] }) { id }
+	}`
+	vars = json.RawMessage(`{"data": [{"id": 2, "email": "user2@test.com", "full_name": "User 2"}]}`)
+	ctx = context.WithValue(ctx, core.UserRoleKey, "reader")
+	_, err = gj2.GraphQL(ctx, gql, vars, nil)
+	assert.Error(t, err)
+}